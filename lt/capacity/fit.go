@@ -0,0 +1,261 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package capacity
+
+import (
+	"fmt"
+	"math"
+)
+
+// The load factors resource usage is fitted against, in order of priority
+// when they can't be told apart.
+const (
+	FactorSessions     = "sessions"
+	FactorSpeakers     = "speakers"
+	FactorScreenShares = "screen_shares"
+	FactorRecordings   = "recordings"
+)
+
+var factors = []string{FactorSessions, FactorSpeakers, FactorScreenShares, FactorRecordings}
+
+func (s Sample) factor(name string) float64 {
+	switch name {
+	case FactorSessions:
+		return s.Sessions
+	case FactorSpeakers:
+		return s.Speakers
+	case FactorScreenShares:
+		return s.ScreenShares
+	case FactorRecordings:
+		return s.Recordings
+	}
+	return 0
+}
+
+// Usage is an amount of resources.
+type Usage struct {
+	// CPU, in cores.
+	CPU      float64 `json:"cpu"`
+	MemoryMB float64 `json:"memory_mb"`
+}
+
+func (u Usage) add(o Usage, n float64) Usage {
+	return Usage{
+		CPU:      u.CPU + o.CPU*n,
+		MemoryMB: u.MemoryMB + o.MemoryMB*n,
+	}
+}
+
+// Model is the linear model of a node's resource usage, fitted from samples:
+// usage = base + sessions*per_session + speakers*per_speaker + ...
+//
+// Each term is the cost on top of the previous ones, so an unmuted speaker
+// costs PerSession+PerSpeaker.
+type Model struct {
+	Samples        int   `json:"samples"`
+	Base           Usage `json:"base"`
+	PerSession     Usage `json:"per_session"`
+	PerSpeaker     Usage `json:"per_speaker"`
+	PerScreenShare Usage `json:"per_screen_share"`
+	PerRecording   Usage `json:"per_recording"`
+	// The factors the samples didn't vary enough to be measured, either
+	// because they stayed constant or because they varied along with a
+	// previous factor (e.g. a fixed share of speakers). Their cost is
+	// accounted for in the other terms.
+	Unmeasured []string `json:"unmeasured,omitempty"`
+	// The coefficient of determination of each fit, 1 being a perfect fit.
+	R2 Usage `json:"r2"`
+}
+
+// cost returns the term of the model for the given factor, the empty one
+// being the base usage.
+func (m *Model) cost(factor string) *Usage {
+	switch factor {
+	case FactorSessions:
+		return &m.PerSession
+	case FactorSpeakers:
+		return &m.PerSpeaker
+	case FactorScreenShares:
+		return &m.PerScreenShare
+	case FactorRecordings:
+		return &m.PerRecording
+	default:
+		return &m.Base
+	}
+}
+
+// Predict returns the usage of a node under the given load.
+func (m *Model) Predict(load Target) Usage {
+	return m.Base.
+		add(m.PerSession, load.Sessions).
+		add(m.PerSpeaker, load.Speakers).
+		add(m.PerScreenShare, load.ScreenShares).
+		add(m.PerRecording, load.Recordings)
+}
+
+// Fit fits a model to the samples through least squares, with the
+// constraint that no cost is negative.
+func Fit(samples []Sample) (*Model, error) {
+	if len(samples) < 2 {
+		return nil, fmt.Errorf("at least 2 samples are needed, got %d", len(samples))
+	}
+
+	// Adding factors one at a time, skipping the ones that are constant or
+	// a combination of the previous ones.
+	cols := []string{""}
+	var unmeasured []string
+	for _, f := range factors {
+		if _, ok := solveLeastSquares(samples, append(cols, f), func(Sample) float64 { return 0 }); ok {
+			cols = append(cols, f)
+		} else {
+			unmeasured = append(unmeasured, f)
+		}
+	}
+	if len(cols) == 1 {
+		return nil, fmt.Errorf("the load didn't vary across samples")
+	}
+	if len(samples) <= len(cols) {
+		return nil, fmt.Errorf("at least %d samples are needed to measure %d factors, got %d", len(cols)+1, len(cols)-1, len(samples))
+	}
+
+	m := &Model{
+		Samples:    len(samples),
+		Unmeasured: unmeasured,
+	}
+
+	for _, isCPU := range []bool{true, false} {
+		y := func(s Sample) float64 { return s.MemoryMB }
+		if isCPU {
+			y = func(s Sample) float64 { return s.CPU }
+		}
+
+		coefs, active := fitNonNegative(samples, cols, y)
+		r2 := rSquared(samples, active, coefs, y)
+		if isCPU {
+			for i, col := range active {
+				m.cost(col).CPU = coefs[i]
+			}
+			m.R2.CPU = r2
+		} else {
+			for i, col := range active {
+				m.cost(col).MemoryMB = coefs[i]
+			}
+			m.R2.MemoryMB = r2
+		}
+	}
+
+	return m, nil
+}
+
+// fitNonNegative solves the least squares problem, dropping the columns with
+// negative coefficients until all of them are positive. The empty column is
+// the intercept.
+func fitNonNegative(samples []Sample, cols []string, y func(Sample) float64) ([]float64, []string) {
+	active := append([]string(nil), cols...)
+	for len(active) > 0 {
+		coefs, ok := solveLeastSquares(samples, active, y)
+		if !ok {
+			// Can't happen as columns were checked to be independent.
+			return nil, nil
+		}
+
+		worst := -1
+		for i, c := range coefs {
+			if c < 0 && (worst < 0 || c < coefs[worst]) {
+				worst = i
+			}
+		}
+		if worst < 0 {
+			return coefs, active
+		}
+		active = append(active[:worst], active[worst+1:]...)
+	}
+	return nil, nil
+}
+
+func column(s Sample, col string) float64 {
+	if col == "" {
+		return 1
+	}
+	return s.factor(col)
+}
+
+// solveLeastSquares solves the normal equations of the given columns, or
+// returns false if the columns are not linearly independent.
+func solveLeastSquares(samples []Sample, cols []string, y func(Sample) float64) ([]float64, bool) {
+	n := len(cols)
+	a := make([][]float64, n)
+	for i := range a {
+		a[i] = make([]float64, n+1)
+	}
+
+	for _, s := range samples {
+		for i, ci := range cols {
+			xi := column(s, ci)
+			for j, cj := range cols {
+				a[i][j] += xi * column(s, cj)
+			}
+			a[i][n] += xi * y(s)
+		}
+	}
+
+	// Gaussian elimination with partial pivoting.
+	var scale float64
+	for i := range n {
+		scale = max(scale, math.Abs(a[i][i]))
+	}
+	for i := range n {
+		pivot := i
+		for j := i + 1; j < n; j++ {
+			if math.Abs(a[j][i]) > math.Abs(a[pivot][i]) {
+				pivot = j
+			}
+		}
+		if math.Abs(a[pivot][i]) <= 1e-9*scale {
+			return nil, false
+		}
+		a[i], a[pivot] = a[pivot], a[i]
+
+		for j := i + 1; j < n; j++ {
+			f := a[j][i] / a[i][i]
+			for k := i; k <= n; k++ {
+				a[j][k] -= f * a[i][k]
+			}
+		}
+	}
+
+	coefs := make([]float64, n)
+	for i := n - 1; i >= 0; i-- {
+		v := a[i][n]
+		for j := i + 1; j < n; j++ {
+			v -= a[i][j] * coefs[j]
+		}
+		coefs[i] = v / a[i][i]
+	}
+
+	return coefs, true
+}
+
+func rSquared(samples []Sample, cols []string, coefs []float64, y func(Sample) float64) float64 {
+	var mean float64
+	for _, s := range samples {
+		mean += y(s)
+	}
+	mean /= float64(len(samples))
+
+	var ssRes, ssTot float64
+	for _, s := range samples {
+		var pred float64
+		for i, col := range cols {
+			pred += coefs[i] * column(s, col)
+		}
+		ssRes += (y(s) - pred) * (y(s) - pred)
+		ssTot += (y(s) - mean) * (y(s) - mean)
+	}
+
+	if ssTot == 0 {
+		return 1
+	}
+	return 1 - ssRes/ssTot
+}
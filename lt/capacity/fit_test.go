@@ -0,0 +1,123 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package capacity
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// newTestSamples returns samples following an exact model, over a varied mix
+// of load.
+func newTestSamples(base, perSession, perSpeaker, perScreenShare Usage) []Sample {
+	var samples []Sample
+	for _, sessions := range []float64{10, 50, 100, 200} {
+		for _, speakers := range []float64{0, 2, 8} {
+			for _, screenShares := range []float64{0, 1, 3} {
+				u := base.add(perSession, sessions).add(perSpeaker, speakers).add(perScreenShare, screenShares)
+				samples = append(samples, Sample{
+					Sessions:     sessions,
+					Speakers:     speakers,
+					ScreenShares: screenShares,
+					CPU:          u.CPU,
+					MemoryMB:     u.MemoryMB,
+				})
+			}
+		}
+	}
+	return samples
+}
+
+func almostEqual(a, b Usage) bool {
+	return math.Abs(a.CPU-b.CPU) < 1e-6 && math.Abs(a.MemoryMB-b.MemoryMB) < 1e-6
+}
+
+func TestFit(t *testing.T) {
+	base := Usage{CPU: 0.1, MemoryMB: 60}
+	perSession := Usage{CPU: 0.01, MemoryMB: 2}
+	perSpeaker := Usage{CPU: 0.05, MemoryMB: 0.5}
+	perScreenShare := Usage{CPU: 0.2, MemoryMB: 8}
+
+	t.Run("exact", func(t *testing.T) {
+		m, err := Fit(newTestSamples(base, perSession, perSpeaker, perScreenShare))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		for name, got := range map[string][2]Usage{
+			"base":         {m.Base, base},
+			"session":      {m.PerSession, perSession},
+			"speaker":      {m.PerSpeaker, perSpeaker},
+			"screen share": {m.PerScreenShare, perScreenShare},
+		} {
+			if !almostEqual(got[0], got[1]) {
+				t.Errorf("%s: got %+v, want %+v", name, got[0], got[1])
+			}
+		}
+
+		// Recordings never varied.
+		if !slices.Equal(m.Unmeasured, []string{FactorRecordings}) {
+			t.Errorf("got unmeasured %v", m.Unmeasured)
+		}
+		if m.R2.CPU < 0.999 || m.R2.MemoryMB < 0.999 {
+			t.Errorf("got R2 %+v", m.R2)
+		}
+	})
+
+	t.Run("collinear", func(t *testing.T) {
+		// A fixed share of speakers can't be told apart from sessions.
+		var samples []Sample
+		for _, sessions := range []float64{10, 20, 40, 80} {
+			samples = append(samples, Sample{
+				Sessions: sessions,
+				Speakers: sessions / 4,
+				CPU:      0.1 + sessions*0.01 + sessions/4*0.04,
+				MemoryMB: 50 + sessions*2,
+			})
+		}
+
+		m, err := Fit(samples)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !slices.Equal(m.Unmeasured, []string{FactorSpeakers, FactorScreenShares, FactorRecordings}) {
+			t.Errorf("got unmeasured %v", m.Unmeasured)
+		}
+		// The cost of speakers is folded into sessions.
+		if want := (Usage{CPU: 0.02, MemoryMB: 2}); !almostEqual(m.PerSession, want) {
+			t.Errorf("got per session %+v, want %+v", m.PerSession, want)
+		}
+	})
+
+	t.Run("non negative", func(t *testing.T) {
+		// Memory going down as screen shares go up, e.g. through noise.
+		samples := newTestSamples(base, perSession, perSpeaker, Usage{CPU: 0.2, MemoryMB: -1})
+		m, err := Fit(samples)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if m.PerScreenShare.MemoryMB != 0 || m.PerScreenShare.CPU <= 0 {
+			t.Errorf("got per screen share %+v", m.PerScreenShare)
+		}
+		for _, u := range []Usage{m.Base, m.PerSession, m.PerSpeaker} {
+			if u.CPU < 0 || u.MemoryMB < 0 {
+				t.Errorf("got negative cost %+v", u)
+			}
+		}
+	})
+
+	t.Run("not enough samples", func(t *testing.T) {
+		for _, samples := range [][]Sample{
+			nil,
+			{{Sessions: 1, CPU: 1}},
+			{{Sessions: 1, CPU: 1}, {Sessions: 1, CPU: 2}, {Sessions: 1, CPU: 3}},
+			{{Sessions: 1, CPU: 1}, {Sessions: 2, CPU: 2}},
+		} {
+			if _, err := Fit(samples); err == nil {
+				t.Errorf("%+v: expected error", samples)
+			}
+		}
+	})
+}
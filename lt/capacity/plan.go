@@ -0,0 +1,187 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package capacity
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+const defaultHeadroom = 0.7
+
+// Target is the load to plan for, across all nodes.
+type Target struct {
+	Sessions     float64 `json:"sessions"`
+	Speakers     float64 `json:"speakers"`
+	ScreenShares float64 `json:"screen_shares"`
+	Recordings   float64 `json:"recordings"`
+}
+
+func (t Target) IsValid() error {
+	if t.Sessions <= 0 {
+		return fmt.Errorf("sessions should be > 0")
+	}
+
+	if t.Speakers < 0 || t.Speakers > t.Sessions {
+		return fmt.Errorf("speakers should be in the range [0, sessions]")
+	}
+
+	if t.ScreenShares < 0 || t.Recordings < 0 {
+		return fmt.Errorf("screen shares and recordings should be >= 0")
+	}
+
+	return nil
+}
+
+// scale returns the target load scaled to the given number of sessions.
+func (t Target) scale(sessions float64) Target {
+	f := sessions / t.Sessions
+	return Target{
+		Sessions:     sessions,
+		Speakers:     t.Speakers * f,
+		ScreenShares: t.ScreenShares * f,
+		Recordings:   t.Recordings * f,
+	}
+}
+
+// Node describes the resources of a single node.
+type Node struct {
+	CPU      float64 `json:"cpu"`
+	MemoryMB float64 `json:"memory_mb"`
+	// The share of resources usable before a node is considered full, in the
+	// range (0, 1]. Defaults to 0.7.
+	Headroom float64 `json:"headroom"`
+}
+
+func (n Node) IsValid() error {
+	if n.CPU <= 0 || n.MemoryMB <= 0 {
+		return fmt.Errorf("cpu and memory should be > 0")
+	}
+
+	if n.Headroom <= 0 || n.Headroom > 1 {
+		return fmt.Errorf("headroom should be in the range (0, 1]")
+	}
+
+	return nil
+}
+
+// Plan is the recommended deployment for a target load.
+type Plan struct {
+	Target Target `json:"target"`
+	Node   Node   `json:"node"`
+	// The number of nodes needed to sustain the target load.
+	Nodes int `json:"nodes"`
+	// The usage of each node, with the load spread evenly.
+	NodeUsage Usage `json:"node_usage"`
+	// The resource running out first, cpu or memory.
+	Bottleneck string `json:"bottleneck"`
+	// The number of sessions a single node sustains with the target's mix
+	// of speakers, screen shares and recordings.
+	SessionsPerNode int `json:"sessions_per_node"`
+	// The suggested value of MM_CALLS_CONCURRENT_SESSIONS_THRESHOLD when
+	// running without rtcd, given a Mattermost server with the resources of
+	// a node to spare.
+	SessionsThreshold int `json:"sessions_threshold"`
+}
+
+// Plan returns the number of nodes needed to sustain the target load.
+func (m *Model) Plan(target Target, node Node) (*Plan, error) {
+	if err := target.IsValid(); err != nil {
+		return nil, fmt.Errorf("invalid target: %w", err)
+	}
+
+	if node.Headroom == 0 {
+		node.Headroom = defaultHeadroom
+	}
+	if err := node.IsValid(); err != nil {
+		return nil, fmt.Errorf("invalid node: %w", err)
+	}
+
+	available := Usage{
+		CPU:      node.CPU*node.Headroom - m.Base.CPU,
+		MemoryMB: node.MemoryMB*node.Headroom - m.Base.MemoryMB,
+	}
+	if available.CPU <= 0 || available.MemoryMB <= 0 {
+		return nil, fmt.Errorf("node is too small to run even without load")
+	}
+
+	// The usage of a single session, with its share of speakers, screen
+	// shares and recordings.
+	perSession := m.Predict(target.scale(1))
+	perSession = perSession.add(m.Base, -1)
+
+	p := &Plan{
+		Target: target,
+		Node:   node,
+	}
+
+	sessionsPerNode := math.Inf(1)
+	if perSession.CPU > 0 {
+		sessionsPerNode = available.CPU / perSession.CPU
+		p.Bottleneck = "cpu"
+	}
+	if perSession.MemoryMB > 0 && available.MemoryMB/perSession.MemoryMB < sessionsPerNode {
+		sessionsPerNode = available.MemoryMB / perSession.MemoryMB
+		p.Bottleneck = "memory"
+	}
+	if math.IsInf(sessionsPerNode, 1) {
+		return nil, fmt.Errorf("the model has no cost per session")
+	}
+
+	p.SessionsPerNode = int(math.Floor(sessionsPerNode))
+	if p.SessionsPerNode == 0 {
+		return nil, fmt.Errorf("node is too small to sustain a single session")
+	}
+	p.SessionsThreshold = p.SessionsPerNode
+
+	p.Nodes = int(math.Ceil(target.Sessions / sessionsPerNode))
+	p.NodeUsage = m.Predict(target.scale(target.Sessions / float64(p.Nodes)))
+
+	return p, nil
+}
+
+// WriteMarkdown writes the model along with the plan.
+func (p *Plan) WriteMarkdown(w io.Writer, m *Model) error {
+	var err error
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	printf("# Capacity plan\n\n")
+
+	printf("## Resource model\n\n")
+	printf("Fitted from %d samples (R²: %.2f for CPU, %.2f for memory).\n\n", m.Samples, m.R2.CPU, m.R2.MemoryMB)
+	printf("| Term | CPU (cores) | Memory (MB) |\n")
+	printf("|---|---|---|\n")
+	for _, term := range []struct {
+		name  string
+		usage Usage
+	}{
+		{"Base", m.Base},
+		{"Per session", m.PerSession},
+		{"Per speaker", m.PerSpeaker},
+		{"Per screen share", m.PerScreenShare},
+		{"Per recording", m.PerRecording},
+	} {
+		printf("| %s | %.4f | %.2f |\n", term.name, term.usage.CPU, term.usage.MemoryMB)
+	}
+	printf("\n")
+	if len(m.Unmeasured) > 0 {
+		printf("Not measured, as the samples didn't vary independently: %s.\n\n", strings.Join(m.Unmeasured, ", "))
+	}
+
+	printf("## Recommendation\n\n")
+	printf("For %g sessions, %g speakers, %g screen shares and %g recordings, on nodes with %g cores and %g MB of memory (%.0f%% usable):\n\n",
+		p.Target.Sessions, p.Target.Speakers, p.Target.ScreenShares, p.Target.Recordings, p.Node.CPU, p.Node.MemoryMB, p.Node.Headroom*100)
+	printf("- Nodes: **%d**, limited by %s\n", p.Nodes, p.Bottleneck)
+	printf("- Usage per node: %.2f cores, %.0f MB\n", p.NodeUsage.CPU, p.NodeUsage.MemoryMB)
+	printf("- Sessions per node: %d\n", p.SessionsPerNode)
+	printf("- `MM_CALLS_CONCURRENT_SESSIONS_THRESHOLD`: %d\n\n", p.SessionsThreshold)
+
+	return err
+}
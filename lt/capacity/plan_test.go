@@ -0,0 +1,76 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package capacity
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	m := &Model{
+		Samples:        10,
+		Base:           Usage{CPU: 0.2, MemoryMB: 100},
+		PerSession:     Usage{CPU: 0.01, MemoryMB: 5},
+		PerSpeaker:     Usage{CPU: 0.04, MemoryMB: 1},
+		PerScreenShare: Usage{CPU: 0.5, MemoryMB: 20},
+	}
+
+	t.Run("cpu bound", func(t *testing.T) {
+		// 0.01 + 0.04*0.1 + 0.5*0.01 = 0.019 cores, 5.3MB per session.
+		p, err := m.Plan(Target{Sessions: 1000, Speakers: 100, ScreenShares: 10}, Node{CPU: 4, MemoryMB: 16384, Headroom: 0.5})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		// (2 - 0.2) / 0.019 = 94.7 sessions per node.
+		if p.SessionsPerNode != 94 || p.SessionsThreshold != 94 {
+			t.Errorf("got %d sessions per node, threshold %d, want 94", p.SessionsPerNode, p.SessionsThreshold)
+		}
+		if p.Nodes != 11 || p.Bottleneck != "cpu" {
+			t.Errorf("got %d nodes limited by %s, want 11 limited by cpu", p.Nodes, p.Bottleneck)
+		}
+		if p.NodeUsage.CPU > 2 {
+			t.Errorf("node usage %+v exceeds the headroom", p.NodeUsage)
+		}
+
+		var buf bytes.Buffer
+		if err := p.WriteMarkdown(&buf, m); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !strings.Contains(buf.String(), "`MM_CALLS_CONCURRENT_SESSIONS_THRESHOLD`: 94") {
+			t.Errorf("unexpected markdown:\n%s", buf.String())
+		}
+	})
+
+	t.Run("memory bound", func(t *testing.T) {
+		p, err := m.Plan(Target{Sessions: 100}, Node{CPU: 16, MemoryMB: 1024})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		// (716.8 - 100) / 5 = 123.36 sessions per node.
+		if p.SessionsPerNode != 123 || p.Nodes != 1 || p.Bottleneck != "memory" {
+			t.Errorf("got %+v", p)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			target Target
+			node   Node
+		}{
+			"no sessions":       {Target{}, Node{CPU: 4, MemoryMB: 8192}},
+			"too many speakers": {Target{Sessions: 10, Speakers: 20}, Node{CPU: 4, MemoryMB: 8192}},
+			"no node":           {Target{Sessions: 10}, Node{}},
+			"invalid headroom":  {Target{Sessions: 10}, Node{CPU: 4, MemoryMB: 8192, Headroom: 2}},
+			"node too small":    {Target{Sessions: 10}, Node{CPU: 0.1, MemoryMB: 8192}},
+		} {
+			if _, err := m.Plan(tc.target, tc.node); err == nil {
+				t.Errorf("%s: expected error", name)
+			}
+		}
+	})
+}
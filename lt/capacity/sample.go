@@ -0,0 +1,65 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package capacity estimates the resources needed by RTC nodes (rtcd, or the
+// plugin itself when running the SFU in process) from samples of their load
+// and resource usage, either recorded during load-tests or scraped live.
+package capacity
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Sample is a measurement of the load and resource usage of a single RTC
+// node.
+type Sample struct {
+	Time time.Time `json:"time"`
+	// The node the sample was taken from.
+	Node         string  `json:"node,omitempty"`
+	Sessions     float64 `json:"sessions"`
+	Speakers     float64 `json:"speakers"`
+	ScreenShares float64 `json:"screen_shares"`
+	Recordings   float64 `json:"recordings"`
+	// CPU usage, in cores.
+	CPU      float64 `json:"cpu"`
+	MemoryMB float64 `json:"memory_mb"`
+}
+
+// LoadSamples reads samples either from a load-test report (see
+// report.Report) or from a JSON array of samples.
+func LoadSamples(r io.Reader) ([]Sample, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read samples: %w", err)
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no samples found")
+	}
+
+	var samples []Sample
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &samples); err != nil {
+			return nil, fmt.Errorf("failed to decode samples: %w", err)
+		}
+	} else {
+		var report struct {
+			Resources []Sample `json:"resources"`
+		}
+		if err := json.Unmarshal(data, &report); err != nil {
+			return nil, fmt.Errorf("failed to decode report: %w", err)
+		}
+		samples = report.Resources
+	}
+
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no samples found")
+	}
+
+	return samples, nil
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package capacity
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadSamples(t *testing.T) {
+	for name, tc := range map[string]struct {
+		data    string
+		samples int
+		err     bool
+	}{
+		"array":          {data: `[{"sessions": 10, "cpu": 0.5}, {"sessions": 20, "cpu": 1}]`, samples: 2},
+		"report":         {data: ` {"start_at": "2024-01-01T00:00:00Z", "resources": [{"sessions": 10, "memory_mb": 100}]}`, samples: 1},
+		"report without": {data: `{"start_at": "2024-01-01T00:00:00Z"}`, err: true},
+		"empty":          {data: "  ", err: true},
+		"empty array":    {data: "[]", err: true},
+		"invalid":        {data: `[{"sessions": "ten"}]`, err: true},
+		"invalid report": {data: `{"resources": {}}`, err: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			samples, err := LoadSamples(strings.NewReader(tc.data))
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(samples) != tc.samples {
+				t.Errorf("got %d samples, want %d", len(samples), tc.samples)
+			}
+		})
+	}
+}
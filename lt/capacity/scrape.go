@@ -0,0 +1,297 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package capacity
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const scrapeTimeout = 10 * time.Second
+
+// Suffixes of the Prometheus metrics read from RTC nodes. The prefix depends
+// on the namespace (e.g. rtcd_ for rtcd).
+const (
+	metricSessions   = "rtc_sessions_total"
+	metricTracks     = "rtc_rtp_tracks_total"
+	metricCPUSeconds = "process_cpu_seconds_total"
+	metricMemory     = "process_resident_memory_bytes"
+)
+
+// counters are the raw values scraped from a node.
+type counters struct {
+	at           time.Time
+	sessions     float64
+	speakers     float64
+	screenShares float64
+	cpuSeconds   float64
+	memoryBytes  float64
+}
+
+// Scraper samples an RTC node through its Prometheus metrics endpoint
+// (e.g. http://rtcd:8045/metrics).
+//
+// Speakers are counted as the incoming audio tracks and screen shares as the
+// incoming video tracks, so simulcast layers count as separate screen shares.
+// Recordings are not exposed by RTC nodes and left to the caller to fill in.
+type Scraper struct {
+	url    string
+	client *http.Client
+	last   *counters
+}
+
+func NewScraper(url string) *Scraper {
+	return &Scraper{
+		url:    url,
+		client: &http.Client{Timeout: scrapeTimeout},
+	}
+}
+
+// Scrape returns the current sample of the node. As CPU usage is computed
+// from the time spent since the previous scrape, the first call only returns
+// false.
+func (s *Scraper) Scrape(ctx context.Context) (Sample, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return Sample{}, false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Sample{}, false, fmt.Errorf("failed to scrape %s: %w", s.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Sample{}, false, fmt.Errorf("failed to scrape %s: unexpected status %d", s.url, resp.StatusCode)
+	}
+
+	c, err := parseCounters(resp.Body)
+	if err != nil {
+		return Sample{}, false, fmt.Errorf("failed to parse metrics from %s: %w", s.url, err)
+	}
+	c.at = time.Now()
+
+	last := s.last
+	s.last = c
+	if last == nil || !c.at.After(last.at) || c.cpuSeconds < last.cpuSeconds {
+		// First scrape, or the node restarted.
+		return Sample{}, false, nil
+	}
+
+	return Sample{
+		Time:         c.at,
+		Node:         s.url,
+		Sessions:     c.sessions,
+		Speakers:     c.speakers,
+		ScreenShares: c.screenShares,
+		CPU:          (c.cpuSeconds - last.cpuSeconds) / c.at.Sub(last.at).Seconds(),
+		MemoryMB:     c.memoryBytes / (1024 * 1024),
+	}, true, nil
+}
+
+// parseCounters reads the metrics we need from the Prometheus text format.
+func parseCounters(r io.Reader) (*counters, error) {
+	var c counters
+	var gotSessions, gotCPU bool
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, labels, value, err := parseLine(line)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case strings.HasSuffix(name, metricSessions):
+			c.sessions += value
+			gotSessions = true
+		case strings.HasSuffix(name, metricTracks):
+			if labels["direction"] != "in" {
+				continue
+			}
+			if strings.HasPrefix(labels["type"], "audio") {
+				c.speakers += value
+			} else if strings.HasPrefix(labels["type"], "video") {
+				c.screenShares += value
+			}
+		case strings.HasSuffix(name, metricCPUSeconds):
+			c.cpuSeconds += value
+			gotCPU = true
+		case strings.HasSuffix(name, metricMemory):
+			c.memoryBytes += value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !gotSessions || !gotCPU {
+		return nil, fmt.Errorf("missing %s or %s metrics", metricSessions, metricCPUSeconds)
+	}
+
+	return &c, nil
+}
+
+// parseLine parses a sample line, e.g. `name{key="value"} 42`.
+func parseLine(line string) (string, map[string]string, float64, error) {
+	name := line
+	var labels map[string]string
+	var rest string
+
+	if idx := strings.IndexByte(line, '{'); idx >= 0 {
+		end := strings.LastIndexByte(line, '}')
+		if end < idx {
+			return "", nil, 0, fmt.Errorf("invalid line %q", line)
+		}
+		name = line[:idx]
+		var err error
+		labels, err = parseLabels(line[idx+1 : end])
+		if err != nil {
+			return "", nil, 0, fmt.Errorf("invalid labels in line %q: %w", line, err)
+		}
+		rest = line[end+1:]
+	} else {
+		var ok bool
+		name, rest, ok = strings.Cut(line, " ")
+		if !ok {
+			return "", nil, 0, fmt.Errorf("invalid line %q", line)
+		}
+	}
+
+	// The value is optionally followed by a timestamp.
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", nil, 0, fmt.Errorf("missing value in line %q", line)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("invalid value in line %q: %w", line, err)
+	}
+
+	return name, labels, value, nil
+}
+
+func parseLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	for s = strings.TrimSpace(s); s != ""; {
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok || !strings.HasPrefix(rest, `"`) {
+			return nil, fmt.Errorf("invalid label %q", s)
+		}
+
+		var value strings.Builder
+		i := 1
+		for ; i < len(rest) && rest[i] != '"'; i++ {
+			if rest[i] == '\\' && i+1 < len(rest) {
+				i++
+				if rest[i] == 'n' {
+					value.WriteByte('\n')
+					continue
+				}
+			}
+			value.WriteByte(rest[i])
+		}
+		if i >= len(rest) {
+			return nil, fmt.Errorf("unterminated label value %q", rest)
+		}
+		labels[strings.TrimSpace(key)] = value.String()
+
+		s = strings.TrimSpace(rest[i+1:])
+		s = strings.TrimSpace(strings.TrimPrefix(s, ","))
+	}
+
+	return labels, nil
+}
+
+// Sampler periodically scrapes a set of RTC nodes.
+type Sampler struct {
+	scrapers []*Scraper
+	interval time.Duration
+	log      *slog.Logger
+
+	mut        sync.Mutex
+	recordings float64
+	samples    []Sample
+}
+
+func NewSampler(urls []string, interval time.Duration, log *slog.Logger) *Sampler {
+	s := &Sampler{
+		interval: interval,
+		log:      log,
+	}
+	for _, url := range urls {
+		s.scrapers = append(s.scrapers, NewScraper(url))
+	}
+	return s
+}
+
+// SetRecordings sets the number of ongoing recordings, spread evenly across
+// nodes in the following samples.
+func (s *Sampler) SetRecordings(n int) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.recordings = float64(n)
+}
+
+// Run scrapes all nodes until stopCh gets closed.
+func (s *Sampler) Run(stopCh <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stopCh
+		cancel()
+	}()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		for _, scraper := range s.scrapers {
+			sample, ok, err := scraper.Scrape(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				s.log.Warn("failed to scrape node", slog.String("err", err.Error()))
+				continue
+			}
+			if !ok {
+				continue
+			}
+
+			s.mut.Lock()
+			sample.Recordings = s.recordings / float64(len(s.scrapers))
+			s.samples = append(s.samples, sample)
+			s.mut.Unlock()
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// Samples returns the samples taken so far.
+func (s *Sampler) Samples() []Sample {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]Sample(nil), s.samples...)
+}
@@ -0,0 +1,166 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package capacity
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testMetrics = `# HELP rtcd_rtc_sessions_total Total number of active RTC sessions
+# TYPE rtcd_rtc_sessions_total gauge
+rtcd_rtc_sessions_total{groupID="g1"} 30
+rtcd_rtc_sessions_total{groupID="g2"} 12
+# TYPE rtcd_rtc_rtp_tracks_total gauge
+rtcd_rtc_rtp_tracks_total{direction="in",groupID="g1",type="audio/opus"} 4
+rtcd_rtc_rtp_tracks_total{direction="in",groupID="g1",type="video/VP8"} 2
+rtcd_rtc_rtp_tracks_total{direction="out",groupID="g1",type="audio/opus"} 120
+rtcd_rtc_rtp_tracks_total{direction="in",groupID="g2",type="audio/opus"} 1
+# TYPE rtcd_process_cpu_seconds_total counter
+rtcd_process_cpu_seconds_total %g
+rtcd_process_resident_memory_bytes 1.048576e+08 1700000000000
+`
+
+func TestParseCounters(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		c, err := parseCounters(strings.NewReader(fmt.Sprintf(testMetrics, 12.5)))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if c.sessions != 42 || c.speakers != 5 || c.screenShares != 2 || c.cpuSeconds != 12.5 || c.memoryBytes != 100*1024*1024 {
+			t.Errorf("got %+v", c)
+		}
+	})
+
+	t.Run("missing metrics", func(t *testing.T) {
+		_, err := parseCounters(strings.NewReader("go_goroutines 10\n"))
+		if err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, line := range []string{
+			"rtcd_rtc_sessions_total",
+			"rtcd_rtc_sessions_total{groupID=\"g1\" 10",
+			"rtcd_rtc_sessions_total{groupID=g1} 10",
+			"rtcd_rtc_sessions_total{groupID=\"g1} 10",
+			"rtcd_rtc_sessions_total{} abc",
+		} {
+			if _, err := parseCounters(strings.NewReader(line)); err == nil {
+				t.Errorf("%q: expected error", line)
+			}
+		}
+	})
+}
+
+func TestParseLabels(t *testing.T) {
+	labels, err := parseLabels(`a="1", b="with \"quotes\", and\nnewline",c=""`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(labels) != 3 || labels["a"] != "1" || labels["b"] != "with \"quotes\", and\nnewline" || labels["c"] != "" {
+		t.Errorf("got %q", labels)
+	}
+}
+
+func TestScraper(t *testing.T) {
+	var cpuSeconds atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, testMetrics, float64(cpuSeconds.Load()))
+	}))
+	defer srv.Close()
+
+	s := NewScraper(srv.URL)
+
+	_, ok, err := s.Scrape(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if ok {
+		t.Fatalf("the first scrape should not return a sample")
+	}
+
+	// Spending 1s of CPU over at least 10ms.
+	time.Sleep(10 * time.Millisecond)
+	cpuSeconds.Store(1)
+	sample, ok, err := s.Scrape(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !ok {
+		t.Fatalf("expected a sample")
+	}
+	if sample.Node != srv.URL || sample.Sessions != 42 || sample.MemoryMB != 100 {
+		t.Errorf("got %+v", sample)
+	}
+	if sample.CPU <= 0 || sample.CPU > 100 {
+		t.Errorf("got CPU %g", sample.CPU)
+	}
+
+	// A restart resets the CPU counter.
+	cpuSeconds.Store(0)
+	if _, ok, err := s.Scrape(context.Background()); err != nil || ok {
+		t.Errorf("got ok %t, err %v after restart", ok, err)
+	}
+
+	t.Run("unexpected status", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		defer srv.Close()
+		if _, _, err := NewScraper(srv.URL).Scrape(context.Background()); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+}
+
+func TestSampler(t *testing.T) {
+	var cpuSeconds atomic.Int64
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, testMetrics, float64(cpuSeconds.Add(1)))
+	})
+	srv1 := httptest.NewServer(handler)
+	defer srv1.Close()
+	srv2 := httptest.NewServer(handler)
+	defer srv2.Close()
+
+	s := NewSampler([]string{srv1.URL, srv2.URL}, 5*time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.SetRecordings(4)
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		s.Run(stopCh)
+		close(doneCh)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.Samples()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(stopCh)
+	<-doneCh
+
+	samples := s.Samples()
+	if len(samples) < 4 {
+		t.Fatalf("got %d samples", len(samples))
+	}
+	nodes := map[string]bool{}
+	for _, sample := range samples {
+		nodes[sample.Node] = true
+		if sample.Recordings != 2 {
+			t.Errorf("got %g recordings, want 2", sample.Recordings)
+		}
+	}
+	if len(nodes) != 2 {
+		t.Errorf("got samples from %d nodes", len(nodes))
+	}
+}
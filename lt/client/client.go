@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"math"
 	"net/http"
 	"os"
 	"strings"
@@ -23,11 +24,11 @@ import (
 
 	"github.com/mattermost/mattermost/server/public/model"
 
+	"github.com/pion/interceptor"
 	"github.com/pion/rtp"
 	"github.com/pion/rtp/codecs"
 	"github.com/pion/webrtc/v4"
 	"github.com/pion/webrtc/v4/pkg/media"
-	"github.com/pion/webrtc/v4/pkg/media/ivfreader"
 	"github.com/pion/webrtc/v4/pkg/media/oggreader"
 
 	"github.com/aws/aws-sdk-go/service/polly"
@@ -78,6 +79,8 @@ const (
 	HTTPRequestTimeout = 10 * time.Second
 )
 
+var ErrNotConnected = errors.New("user is not connected")
+
 type Config struct {
 	Username      string
 	Password      string
@@ -93,8 +96,19 @@ type Config struct {
 	Recording     bool
 	Setup         bool
 	SpeechFile    string
-	PollySession  *polly.Polly
-	PollyVoiceID  *string
+	// Optional, the source of the media sent by the user. Defaults to a
+	// FileMediaSource looping over SpeechFile and the ./samples videos.
+	MediaSource MediaSource
+	// Optional, enables checking the continuity of received tracks.
+	Sink *SinkConfig
+	// The source of the audio spoken by users with Speak set.
+	SpeechSource SpeechSource
+	// Optional, the network conditions simulated for the media sent and
+	// received by the user.
+	Network *NetworkProfile
+	// Deprecated: use SpeechSource with a PollySpeechSource instead.
+	PollySession *polly.Polly
+	PollyVoiceID *string
 }
 
 type User struct {
@@ -104,12 +118,28 @@ type User struct {
 	callsClient *client.Client
 	callsConfig map[string]any
 	hostID      atomic.Value
+	connectedCh chan struct{}
+
+	voiceTrack   atomic.Pointer[webrtc.TrackLocalStaticSample]
+	transmitting atomic.Bool
+	muted        atomic.Bool
 
-	pollySession   *polly.Polly
-	pollyVoiceID   *string
+	speechSource   SpeechSource
 	speechTextCh   chan string
 	doneSpeakingCh chan struct{}
 
+	metrics MetricsRecorder
+	timings *connTimings
+
+	mediaSource MediaSource
+	tracksMut   sync.Mutex
+	trackSinks  []*trackSink
+
+	impairment *impairmentInterceptor
+	// Local tracks wrapped with the impairment, so that they can be reused.
+	impairedTracksMut sync.Mutex
+	impairedTracks    map[webrtc.TrackLocal]webrtc.TrackLocal
+
 	// Used to inject the CGO dependency (speech) without requiring it for the base case (i.e. ./cmd/lt binary).
 	newOpusEncoder func() (OpusEncoder, error)
 
@@ -137,10 +167,11 @@ func WithOpusEncoderFactory(f func() (OpusEncoder, error)) Option {
 func NewUser(cfg Config, opts ...Option) *User {
 	u := &User{
 		cfg:            cfg,
+		connectedCh:    make(chan struct{}),
 		speechTextCh:   make(chan string, 8),
 		doneSpeakingCh: make(chan struct{}),
-		pollySession:   cfg.PollySession,
-		pollyVoiceID:   cfg.PollyVoiceID,
+		speechSource:   cfg.SpeechSource,
+		mediaSource:    cfg.MediaSource,
 	}
 
 	for _, opt := range opts {
@@ -151,10 +182,48 @@ func NewUser(cfg Config, opts ...Option) *User {
 		u.log = slog.Default()
 	}
 
+	if u.speechSource == nil && cfg.PollySession != nil {
+		u.speechSource = NewPollySpeechSource(cfg.PollySession, cfg.PollyVoiceID)
+	}
+
+	if u.metrics == nil {
+		u.metrics = noopMetrics{}
+	}
+
+	if u.mediaSource == nil {
+		u.mediaSource = &FileMediaSource{
+			AudioFile: cfg.SpeechFile,
+			VideoDir:  "./samples",
+		}
+	}
+
+	if cfg.Network != nil {
+		u.impairment = newImpairmentInterceptor(*cfg.Network)
+		u.impairedTracks = map[webrtc.TrackLocal]webrtc.TrackLocal{}
+	}
+
 	return u
 }
 
-func (u *User) sendVideoFile(track *webrtc.TrackLocalStaticRTP, trx *webrtc.RTPTransceiver, videoType string) {
+// localTrack returns the track to publish for the given one, applying the
+// network profile if any.
+func (u *User) localTrack(track webrtc.TrackLocal) webrtc.TrackLocal {
+	if u.impairment == nil {
+		return track
+	}
+
+	u.impairedTracksMut.Lock()
+	defer u.impairedTracksMut.Unlock()
+
+	if t, ok := u.impairedTracks[track]; ok {
+		return t
+	}
+	t := newImpairedTrack(track, u.impairment)
+	u.impairedTracks[track] = t
+	return t
+}
+
+func (u *User) sendVideo(track *webrtc.TrackLocalStaticRTP, trx *webrtc.RTPTransceiver, videoType string) {
 	getExtensionID := func(URI string) uint8 {
 		for _, ext := range trx.Sender().GetParameters().RTPParameters.HeaderExtensions {
 			if ext.URI == URI {
@@ -168,11 +237,9 @@ func (u *User) sendVideoFile(track *webrtc.TrackLocalStaticRTP, trx *webrtc.RTPT
 	payloader = &codecs.VP8Payloader{
 		EnablePictureID: true,
 	}
-	filename := fmt.Sprintf("./samples/%s_%s.ivf", videoType, track.RID())
 	if track.Codec().MimeType == webrtc.MimeTypeAV1 {
 		u.log.Info("sending AV1 screen track")
 		payloader = &codecs.AV1Payloader{}
-		filename = fmt.Sprintf("./samples/%s_av1_%s.ivf", videoType, track.RID())
 	}
 
 	packetizer := rtp.NewPacketizer(
@@ -184,51 +251,36 @@ func (u *User) sendVideoFile(track *webrtc.TrackLocalStaticRTP, trx *webrtc.RTPT
 		track.Codec().ClockRate,
 	)
 
-	// Open a IVF file and start reading using our IVFReader
-	file, ivfErr := os.Open(filename)
-	if ivfErr != nil {
-		u.log.Error(ivfErr.Error())
-		os.Exit(1)
-	}
-	defer file.Close()
-
-	ivf, header, ivfErr := ivfreader.NewWith(file)
-	if ivfErr != nil {
-		u.log.Error(ivfErr.Error())
+	stream, err := u.mediaSource.Video(videoType, track.RID(), track.Codec().MimeType)
+	if err != nil {
+		u.log.Error(err.Error())
 		os.Exit(1)
 	}
+	defer stream.Close()
 
-	// Send our video file frame at a time. Pace our sending so we send it at the same speed it should be played back as.
+	// Send our video frame at a time. Pace our sending so we send it at the same speed it should be played back as.
 	// This isn't required since the video is timestamped, but we will such much higher loss if we send all at once.
 	//
 	// It is important to use a time.Ticker instead of time.Sleep because
 	// * avoids accumulating skew, just calling time.Sleep didn't compensate for the time spent parsing the data
 	// * works around latency issues with Sleep (see https://github.com/golang/go/issues/44343)
-	frameDuration := time.Millisecond * time.Duration((float32(header.TimebaseNumerator)/float32(header.TimebaseDenominator))*1000)
-
+	frameDuration := time.Second / 30
 	ticker := time.NewTicker(frameDuration)
-	for ; true; <-ticker.C {
-		var frame []byte
-		var ivfErr error
-		frame, _, ivfErr = ivf.ParseNextFrame()
-		if ivfErr == io.EOF || (ivfErr != nil && ivfErr.Error() == "incomplete frame data") {
-			ivf.ResetReader(func(_ int64) io.Reader {
-				_, _ = file.Seek(0, 0)
-				ivf, header, ivfErr = ivfreader.NewWith(file)
-				if ivfErr != nil {
-					u.log.Error(ivfErr.Error())
-					os.Exit(1)
-				}
-				return file
-			})
-			frame, _, ivfErr = ivf.ParseNextFrame()
-		}
-		if ivfErr != nil {
-			u.log.Error(ivfErr.Error())
+	defer ticker.Stop()
+	for {
+		sample, err := stream.Next()
+		if err != nil {
+			u.log.Error(err.Error())
 			os.Exit(1)
 		}
 
-		packets := packetizer.Packetize(frame, track.Codec().ClockRate/header.TimebaseDenominator)
+		if sample.Duration > 0 && sample.Duration != frameDuration {
+			frameDuration = sample.Duration
+			ticker.Reset(frameDuration)
+		}
+
+		samples := uint32(math.Round(sample.Duration.Seconds() * float64(track.Codec().ClockRate)))
+		packets := packetizer.Packetize(sample.Data, samples)
 		for _, p := range packets {
 			if u.callsConfig["EnableSimulcast"].(bool) {
 				if err := p.Header.SetExtension(getExtensionID(rtpVideoExtensions[0]), []byte(trx.Mid())); err != nil {
@@ -245,6 +297,8 @@ func (u *User) sendVideoFile(track *webrtc.TrackLocalStaticRTP, trx *webrtc.RTPT
 				return
 			}
 		}
+
+		<-ticker.C
 	}
 }
 
@@ -277,6 +331,10 @@ func (u *User) transmitVideo(videoType string) {
 		tracks = []webrtc.TrackLocal{trackLow, trackHigh}
 	}
 
+	for i, track := range tracks {
+		tracks[i] = u.localTrack(track)
+	}
+
 	var trx *webrtc.RTPTransceiver
 	if videoType == "screen" {
 		trx, err = u.callsClient.StartScreenShare(tracks)
@@ -289,7 +347,7 @@ func (u *User) transmitVideo(videoType string) {
 	}
 
 	if enableSimulcast {
-		go u.sendVideoFile(trackLow, trx, videoType)
+		go u.sendVideo(trackLow, trx, videoType)
 	} else if enableAV1 {
 		// Fallback VP8 track
 		fallbackTrack, err := webrtc.NewTrackLocalStaticRTP(rtpVideoCodecVP8, "video", streamID, webrtc.WithRTPStreamID(simulcastLevelHigh))
@@ -300,18 +358,18 @@ func (u *User) transmitVideo(videoType string) {
 
 		var trx2 *webrtc.RTPTransceiver
 		if videoType == "screen" {
-			trx2, err = u.callsClient.StartScreenShare([]webrtc.TrackLocal{fallbackTrack})
+			trx2, err = u.callsClient.StartScreenShare([]webrtc.TrackLocal{u.localTrack(fallbackTrack)})
 		} else {
-			trx2, err = u.callsClient.StartVideo([]webrtc.TrackLocal{fallbackTrack})
+			trx2, err = u.callsClient.StartVideo([]webrtc.TrackLocal{u.localTrack(fallbackTrack)})
 		}
 		if err != nil {
 			u.log.Error(err.Error())
 			os.Exit(1)
 		}
-		go u.sendVideoFile(fallbackTrack, trx2, videoType)
+		go u.sendVideo(fallbackTrack, trx2, videoType)
 	}
 
-	u.sendVideoFile(trackHigh, trx, videoType)
+	u.sendVideo(trackHigh, trx, videoType)
 }
 
 func (u *User) transmitAudio() {
@@ -321,28 +379,18 @@ func (u *User) transmitAudio() {
 		os.Exit(1)
 	}
 
-	// Open a OGG file and start reading using our OGGReader
-	file, oggErr := os.Open(u.cfg.SpeechFile)
-	if oggErr != nil {
-		u.log.Error(oggErr.Error())
-		os.Exit(1)
-	}
-	defer file.Close()
-
-	// Open on oggfile in non-checksum mode.
-	ogg, _, oggErr := oggreader.NewWith(file)
-	if oggErr != nil {
-		u.log.Error(oggErr.Error())
+	stream, err := u.mediaSource.Audio()
+	if err != nil {
+		u.log.Error(err.Error())
 		os.Exit(1)
 	}
+	defer stream.Close()
 
-	if err := u.callsClient.Unmute(track); err != nil {
-		u.log.Error(oggErr.Error())
+	if err := u.callsClient.Unmute(u.localTrack(track)); err != nil {
+		u.log.Error(err.Error())
 		os.Exit(1)
 	}
-
-	// Keep track of last granule, the difference is the amount of samples in the buffer
-	var lastGranule uint64
+	u.voiceTrack.Store(track)
 
 	// It is important to use a time.Ticker instead of time.Sleep because
 	// * avoids accumulating skew, just calling time.Sleep didn't compensate for the time spent parsing the data
@@ -350,28 +398,18 @@ func (u *User) transmitAudio() {
 	oggPageDuration := time.Millisecond * 20
 	ticker := time.NewTicker(oggPageDuration)
 	for ; true; <-ticker.C {
-		var oggErr error
-		var pageData []byte
-		var pageHeader *oggreader.OggPageHeader
-		pageData, pageHeader, oggErr = ogg.ParseNextPage()
-		if oggErr == io.EOF {
-			ogg.ResetReader(func(_ int64) io.Reader {
-				_, _ = file.Seek(0, 0)
-				return file
-			})
-			pageData, pageHeader, oggErr = ogg.ParseNextPage()
+		// Pause the stream while muted so that it resumes where it stopped.
+		if u.muted.Load() {
+			continue
 		}
-		if oggErr != nil {
-			u.log.Error(oggErr.Error())
+
+		sample, err := stream.Next()
+		if err != nil {
+			u.log.Error(err.Error())
 			os.Exit(1)
 		}
 
-		// The amount of samples is the difference between the last and current timestamp
-		sampleCount := float64(pageHeader.GranulePosition - lastGranule)
-		lastGranule = pageHeader.GranulePosition
-		sampleDuration := time.Duration((sampleCount/48000)*1000) * time.Millisecond
-
-		if err := track.WriteSample(media.Sample{Data: pageData, Duration: sampleDuration}); err != nil {
+		if err := track.WriteSample(sample); err != nil {
 			u.log.Error("failed to write audio sample", slog.String("err", err.Error()))
 		}
 	}
@@ -386,13 +424,85 @@ func (u *User) Mute() error {
 }
 
 func (u *User) Unmute(track webrtc.TrackLocal) error {
-	err := u.callsClient.Unmute(track)
+	err := u.callsClient.Unmute(u.localTrack(track))
 	if err != nil {
 		u.log.Error("failed to unmute", slog.String("err", err.Error()))
 	}
 	return err
 }
 
+// SetMuted mutes or unmutes the user. Audio transmission is started the first
+// time a user that joined muted is unmuted.
+func (u *User) SetMuted(muted bool) error {
+	if !u.isConnected() {
+		return ErrNotConnected
+	}
+
+	u.muted.Store(muted)
+	if muted {
+		return u.Mute()
+	}
+
+	if track := u.voiceTrack.Load(); track != nil {
+		return u.Unmute(track)
+	}
+
+	if u.transmitting.CompareAndSwap(false, true) {
+		go u.transmitAudio()
+	}
+
+	return nil
+}
+
+func (u *User) RaiseHand() error {
+	if !u.isConnected() {
+		return ErrNotConnected
+	}
+	return u.callsClient.RaiseHand()
+}
+
+func (u *User) LowerHand() error {
+	if !u.isConnected() {
+		return ErrNotConnected
+	}
+	return u.callsClient.LowerHand()
+}
+
+// React sends an emoji reaction to the call. The unified value is the
+// hyphen separated list of code points of the emoji (e.g. "1F44D").
+func (u *User) React(name, unified string) error {
+	if !u.isConnected() {
+		return ErrNotConnected
+	}
+
+	data, err := json.Marshal(map[string]string{
+		"name":    name,
+		"unified": unified,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal emoji data: %w", err)
+	}
+
+	return u.callsClient.SendWS("custom_com.mattermost.calls_react", map[string]any{
+		"data": string(data),
+	}, false)
+}
+
+// Connected returns a channel that gets closed once the user has connected
+// to the call.
+func (u *User) Connected() <-chan struct{} {
+	return u.connectedCh
+}
+
+func (u *User) isConnected() bool {
+	select {
+	case <-u.connectedCh:
+		return true
+	default:
+		return false
+	}
+}
+
 func (u *User) transmitSpeech() {
 	track, err := webrtc.NewTrackLocalStaticSample(rtpAudioCodec, "audio", "voice"+model.NewId())
 	if err != nil {
@@ -400,11 +510,7 @@ func (u *User) transmitSpeech() {
 		os.Exit(1)
 	}
 
-	enc, err := u.newOpusEncoder()
-	if err != nil {
-		u.log.Error("failed to create opus encoder", slog.String("err", err.Error()))
-	}
-
+	var enc OpusEncoder
 	for text := range u.speechTextCh {
 		func() {
 			defer func() {
@@ -418,63 +524,122 @@ func (u *User) transmitSpeech() {
 			}()
 			u.log.Debug("received text to speak: " + text)
 
+			if u.speechSource == nil {
+				u.log.Error("no speech source configured")
+				return
+			}
+
+			speech, err := u.speechSource.Speech(text)
+			if err != nil {
+				u.log.Error("failed to get speech", slog.String("err", err.Error()))
+				return
+			}
+			if speech.Close != nil {
+				defer speech.Close()
+			}
+
 			if err := u.Unmute(track); err != nil {
 				u.log.Error(err.Error())
 				os.Exit(1)
 			}
 			u.log.Debug("unmuted")
 
-			var rd io.Reader
-			var rate int
-			var err error
-			if u.pollySession != nil {
-				rd, rate, err = u.pollyToSpeech(text)
-			}
-			if err != nil {
-				u.log.Error("textToSpeech failed", slog.String("err", err.Error()))
+			if speech.Ogg != nil {
+				u.sendOggSpeech(track, speech.Ogg)
 				return
 			}
 
-			u.log.Debug("raw speech samples decoded", slog.Int("rate", rate))
+			// Encoders are only needed for raw samples.
+			if enc == nil {
+				if u.newOpusEncoder == nil {
+					u.log.Error("no opus encoder available to encode speech")
+					return
+				}
+				enc, err = u.newOpusEncoder()
+				if err != nil {
+					u.log.Error("failed to create opus encoder", slog.String("err", err.Error()))
+					return
+				}
+			}
 
-			audioSamplesDataBuf := bytes.NewBuffer([]byte{})
-			if _, err := audioSamplesDataBuf.ReadFrom(rd); err != nil {
-				u.log.Error("failed to read samples data", slog.String("err", err.Error()))
-				return
+			u.sendPCMSpeech(track, enc, speech.PCM, speech.SampleRate)
+		}()
+	}
+}
+
+func (u *User) sendPCMSpeech(track *webrtc.TrackLocalStaticSample, enc OpusEncoder, rd io.Reader, rate int) {
+	u.log.Debug("raw speech samples decoded", slog.Int("rate", rate))
+
+	audioSamplesDataBuf := bytes.NewBuffer([]byte{})
+	if _, err := audioSamplesDataBuf.ReadFrom(rd); err != nil {
+		u.log.Error("failed to read samples data", slog.String("err", err.Error()))
+		return
+	}
+
+	u.log.Debug("read samples bytes", slog.Int("len", audioSamplesDataBuf.Len()))
+
+	// 20ms worth of samples.
+	frameSize := rate / 50
+	sampleDuration := time.Millisecond * 20
+	ticker := time.NewTicker(sampleDuration)
+	defer ticker.Stop()
+	audioSamplesData := make([]byte, frameSize*4)
+	audioSamples := make([]int16, frameSize)
+	opusData := make([]byte, 8192)
+	for ; true; <-ticker.C {
+		n, err := audioSamplesDataBuf.Read(audioSamplesData)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				u.log.Error("failed to read audio samples", slog.String("err", err.Error()))
 			}
+			break
+		}
 
-			u.log.Debug("read samples bytes", slog.Int("len", audioSamplesDataBuf.Len()))
+		// Convert []byte to []int16
+		for i := 0; i < n; i += 4 {
+			audioSamples[i/4] = int16(binary.LittleEndian.Uint16(audioSamplesData[i : i+4]))
+		}
+		// Pad the last frame with silence.
+		clear(audioSamples[n/4:])
 
-			sampleDuration := time.Millisecond * 20
-			ticker := time.NewTicker(sampleDuration)
-			audioSamplesData := make([]byte, 480*4)
-			audioSamples := make([]int16, 480)
-			opusData := make([]byte, 8192)
-			for ; true; <-ticker.C {
-				n, err := audioSamplesDataBuf.Read(audioSamplesData)
-				if err != nil {
-					if !errors.Is(err, io.EOF) {
-						u.log.Error("failed to read audio samples", slog.String("err", err.Error()))
-					}
-					break
-				}
+		n, err = enc.Encode(audioSamples, opusData)
+		if err != nil {
+			u.log.Error("failed to encode", slog.String("err", err.Error()))
+			continue
+		}
 
-				// Convert []byte to []int16
-				for i := 0; i < n; i += 4 {
-					audioSamples[i/4] = int16(binary.LittleEndian.Uint16(audioSamplesData[i : i+4]))
-				}
+		if err := track.WriteSample(media.Sample{Data: opusData[:n], Duration: sampleDuration}); err != nil {
+			u.log.Error("failed to write audio sample", slog.String("err", err.Error()))
+		}
+	}
+}
 
-				n, err = enc.Encode(audioSamples, opusData)
-				if err != nil {
-					u.log.Error("failed to encode: %s", u.cfg.Username, err.Error())
-					continue
-				}
+func (u *User) sendOggSpeech(track *webrtc.TrackLocalStaticSample, rd io.Reader) {
+	ogg, _, err := oggreader.NewWith(rd)
+	if err != nil {
+		u.log.Error("failed to read ogg speech", slog.String("err", err.Error()))
+		return
+	}
 
-				if err := track.WriteSample(media.Sample{Data: opusData[:n], Duration: sampleDuration}); err != nil {
-					u.log.Error("failed to write audio sample: %s", u.cfg.Username, err.Error())
-				}
+	var lastGranule uint64
+	ticker := time.NewTicker(time.Millisecond * 20)
+	defer ticker.Stop()
+	for ; true; <-ticker.C {
+		pageData, pageHeader, err := ogg.ParseNextPage()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				u.log.Error("failed to parse ogg page", slog.String("err", err.Error()))
 			}
-		}()
+			return
+		}
+
+		sampleCount := float64(pageHeader.GranulePosition - lastGranule)
+		lastGranule = pageHeader.GranulePosition
+		sampleDuration := time.Duration((sampleCount/48000)*1000) * time.Millisecond
+
+		if err := track.WriteSample(media.Sample{Data: pageData, Duration: sampleDuration}); err != nil {
+			u.log.Error("failed to write audio sample", slog.String("err", err.Error()))
+		}
 	}
 }
 
@@ -484,7 +649,10 @@ func (u *User) Speak(text string) chan struct{} {
 }
 
 func (u *User) onConnect() {
+	defer close(u.connectedCh)
+
 	if u.cfg.Unmuted {
+		u.transmitting.Store(true)
 		go u.transmitAudio()
 	} else if u.cfg.Speak {
 		go u.transmitSpeech()
@@ -539,11 +707,54 @@ func (u *User) handleTrack(ctx any) error {
 		return fmt.Errorf("failed to convert track")
 	}
 
-	// We don't currently do anything with the packets but we should still read
-	// them to properly calculate client stats.
+	u.timings.onTrack()
+
+	var reader interceptor.RTPReader = interceptor.RTPReaderFunc(func(b []byte, _ interceptor.Attributes) (int, interceptor.Attributes, error) {
+		return track.Read(b)
+	})
+	if u.impairment != nil {
+		reader = u.impairment.BindRemoteStream(&interceptor.StreamInfo{
+			ID:   track.ID(),
+			SSRC: uint32(track.SSRC()),
+		}, reader)
+	}
+
+	if u.cfg.Sink == nil {
+		// We don't need the packets but we should still read them to
+		// properly calculate client stats.
+		buf := make([]byte, receiveMTU)
+		for {
+			_, _, readErr := reader.Read(buf, nil)
+			if readErr != nil {
+				if !errors.Is(readErr, io.EOF) {
+					u.log.Error("failed to read RTP packet for track",
+						slog.String("err", readErr.Error()),
+						slog.String("trackID", track.ID()))
+				}
+				return nil
+			}
+		}
+	}
+
+	sink, err := newTrackSink(*u.cfg.Sink, u.metrics, track.ID(), track.Codec().MimeType, track.Kind(),
+		trackFilename(u.cfg.Username, track.ID(), track.SSRC()))
+	if err != nil {
+		return fmt.Errorf("failed to create track sink: %w", err)
+	}
+	u.tracksMut.Lock()
+	u.trackSinks = append(u.trackSinks, sink)
+	u.tracksMut.Unlock()
+
+	defer func() {
+		if err := sink.close(); err != nil {
+			u.log.Error("failed to close track sink", slog.String("err", err.Error()), slog.String("trackID", track.ID()))
+		}
+		u.log.Debug("track ended", slog.Any("stats", sink.getStats()))
+	}()
+
 	buf := make([]byte, receiveMTU)
 	for {
-		_, _, readErr := track.Read(buf)
+		n, _, readErr := reader.Read(buf, nil)
 		if readErr != nil {
 			if !errors.Is(readErr, io.EOF) {
 				u.log.Error("failed to read RTP packet for track",
@@ -552,19 +763,52 @@ func (u *User) handleTrack(ctx any) error {
 			}
 			return nil
 		}
+
+		var pkt rtp.Packet
+		if err := pkt.Unmarshal(buf[:n]); err != nil {
+			u.log.Error("failed to unmarshal RTP packet", slog.String("err", err.Error()), slog.String("trackID", track.ID()))
+			continue
+		}
+		if err := sink.onPacket(&pkt, time.Now()); err != nil {
+			u.log.Error("failed to write RTP packet", slog.String("err", err.Error()), slog.String("trackID", track.ID()))
+		}
 	}
 }
 
-func (u *User) Connect(stopCh chan struct{}) error {
+// TrackStats returns the continuity stats of the tracks received so far, if
+// Config.Sink is set.
+func (u *User) TrackStats() []TrackStats {
+	u.tracksMut.Lock()
+	defer u.tracksMut.Unlock()
+
+	stats := make([]TrackStats, 0, len(u.trackSinks))
+	for _, sink := range u.trackSinks {
+		stats = append(stats, sink.getStats())
+	}
+	return stats
+}
+
+func (u *User) Connect(stopCh chan struct{}) (err error) {
 	u.log.Debug("connecting user")
+	if u.cfg.Network != nil {
+		u.log.Debug("simulating network conditions", slog.String("profile", u.cfg.Network.String()))
+	}
+
+	stage := MetricLogin
+	defer func() {
+		if err != nil {
+			u.metrics.RecordError(stage, err)
+		}
+	}()
 
 	var user *model.User
 	apiClient := model.NewAPIv4Client(u.cfg.SiteURL)
 	u.apiClient = apiClient
 	// login (or create) user
+	loginStart := time.Now()
 	ctx, cancel := context.WithTimeout(context.Background(), HTTPRequestTimeout)
 	defer cancel()
-	user, _, err := apiClient.Login(ctx, u.cfg.Username, u.cfg.Password)
+	user, _, err = apiClient.Login(ctx, u.cfg.Username, u.cfg.Password)
 	appErr, ok := err.(*model.AppError)
 	if err != nil && !ok {
 		return err
@@ -603,7 +847,9 @@ func (u *User) Connect(stopCh chan struct{}) error {
 	}
 
 	u.log.Debug("logged in")
+	u.metrics.RecordTiming(MetricLogin, time.Since(loginStart))
 	u.userID = user.Id
+	stage = MetricSetup
 
 	// Need to sleep a little here since login can be racy
 	time.Sleep(time.Second)
@@ -639,6 +885,7 @@ func (u *User) Connect(stopCh chan struct{}) error {
 	}
 
 	u.log.Debug("creating calls client")
+	stage = MetricCall
 
 	callsConfig, err := u.getCallsConfig()
 	if err != nil {
@@ -671,6 +918,7 @@ func (u *User) Connect(stopCh chan struct{}) error {
 	var connectOnce sync.Once
 	err = callsClient.On(client.RTCConnectEvent, func(_ any) error {
 		u.log.Debug("connected to call")
+		u.timings.onICEConnect()
 		connectOnce.Do(u.onConnect)
 		return nil
 	})
@@ -696,6 +944,30 @@ func (u *User) Connect(stopCh chan struct{}) error {
 		return fmt.Errorf("failed to subscribe to host changed event: %w", err)
 	}
 
+	err = callsClient.On(client.WSConnectEvent, func(_ any) error {
+		u.timings.onWSConnect()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to ws connect event: %w", err)
+	}
+
+	err = callsClient.On(client.WSDisconnectEvent, func(_ any) error {
+		u.timings.onWSDisconnect()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to ws disconnect event: %w", err)
+	}
+
+	err = callsClient.On(client.WSCallJoinEvent, func(_ any) error {
+		u.timings.onJoin()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to join event: %w", err)
+	}
+
 	errCh := make(chan error, 1)
 	err = callsClient.On(client.ErrorEvent, func(ctx any) error {
 		errCh <- ctx.(error)
@@ -705,6 +977,7 @@ func (u *User) Connect(stopCh chan struct{}) error {
 		return fmt.Errorf("failed to subscribe to close event: %w", err)
 	}
 
+	u.timings = newConnTimings(u.metrics)
 	if err := callsClient.Connect(); err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
@@ -0,0 +1,251 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client
+
+import (
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	// maxQueueDelay is how long packets can be queued when exceeding the
+	// bandwidth before getting dropped.
+	maxQueueDelay = 300 * time.Millisecond
+	// impairedReadQueueSize is the number of received packets that can be
+	// waiting to be read.
+	impairedReadQueueSize = 512
+)
+
+// link simulates the network conditions of a profile in one direction.
+type link struct {
+	profile NetworkProfile
+
+	mut sync.Mutex
+	rnd *rand.Rand
+	// When the bandwidth queue is empty again.
+	queueFreeAt time.Time
+}
+
+func newLink(profile NetworkProfile, seed int64) *link {
+	return &link{
+		profile: profile,
+		rnd:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// schedule returns how long the packet of the given size should be held
+// before being delivered, or false if it should be dropped.
+func (l *link) schedule(size int, now time.Time) (time.Duration, bool) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	if l.profile.Loss > 0 && l.rnd.Float64() < l.profile.Loss {
+		return 0, false
+	}
+
+	var delay time.Duration
+	if l.profile.Bandwidth > 0 {
+		start := now
+		if l.queueFreeAt.After(now) {
+			start = l.queueFreeAt
+		}
+		if start.Sub(now) > maxQueueDelay {
+			return 0, false
+		}
+		l.queueFreeAt = start.Add(time.Duration(size*8) * time.Second / time.Duration(l.profile.Bandwidth))
+		delay = l.queueFreeAt.Sub(now)
+	}
+
+	delay += l.profile.Delay
+	if l.profile.Jitter > 0 {
+		delay += time.Duration(l.rnd.Int63n(int64(l.profile.Jitter)))
+	}
+
+	return delay, true
+}
+
+// impairmentInterceptor applies a network profile to the RTP packets sent
+// and received by a user.
+//
+// The rtcd client owns the interceptor registry of its peer connection, so
+// the interceptor gets bound to local tracks by wrapping them (see
+// newImpairedTrack) and to remote tracks when reading them (see
+// User.handleTrack). Incoming packets are thus impaired after the default
+// interceptors (e.g. NACK, reports) have processed them.
+type impairmentInterceptor struct {
+	interceptor.NoOp
+	outgoing *link
+	incoming *link
+}
+
+func newImpairmentInterceptor(profile NetworkProfile) *impairmentInterceptor {
+	seed := time.Now().UnixNano()
+	return &impairmentInterceptor{
+		outgoing: newLink(profile, seed),
+		incoming: newLink(profile, seed+1),
+	}
+}
+
+func (i *impairmentInterceptor) BindLocalStream(_ *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attrs interceptor.Attributes) (int, error) {
+		n := header.MarshalSize() + len(payload)
+		delay, ok := i.outgoing.schedule(n, time.Now())
+		if !ok {
+			return n, nil
+		}
+		if delay == 0 {
+			return writer.Write(header, payload, attrs)
+		}
+
+		// The caller is free to reuse the packet once we return.
+		hdr := header.Clone()
+		data := slices.Clone(payload)
+		time.AfterFunc(delay, func() {
+			_, _ = writer.Write(&hdr, data, attrs)
+		})
+
+		return n, nil
+	})
+}
+
+func (i *impairmentInterceptor) BindRemoteStream(_ *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	return &impairedReader{
+		link:     i.incoming,
+		reader:   reader,
+		packetCh: make(chan impairedPacket, impairedReadQueueSize),
+		doneCh:   make(chan struct{}),
+	}
+}
+
+type impairedPacket struct {
+	data  []byte
+	attrs interceptor.Attributes
+}
+
+type impairedReader struct {
+	link     *link
+	reader   interceptor.RTPReader
+	once     sync.Once
+	packetCh chan impairedPacket
+	// doneCh is closed when reading from the underlying reader fails, err
+	// holding the error returned to any subsequent read.
+	doneCh chan struct{}
+	err    error
+}
+
+func (r *impairedReader) Read(b []byte, _ interceptor.Attributes) (int, interceptor.Attributes, error) {
+	r.once.Do(func() {
+		go r.readLoop()
+	})
+
+	// Packets that were queued before the failure are returned first.
+	var pkt impairedPacket
+	select {
+	case pkt = <-r.packetCh:
+	default:
+		select {
+		case pkt = <-r.packetCh:
+		case <-r.doneCh:
+			return 0, nil, r.err
+		}
+	}
+
+	return copy(b, pkt.data), pkt.attrs, nil
+}
+
+// readLoop reads packets as they arrive, so that they can be delayed
+// independently of each other.
+func (r *impairedReader) readLoop() {
+	for {
+		buf := make([]byte, receiveMTU)
+		n, attrs, err := r.reader.Read(buf, nil)
+		if err != nil {
+			r.err = err
+			close(r.doneCh)
+			return
+		}
+
+		delay, ok := r.link.schedule(n, time.Now())
+		if !ok {
+			continue
+		}
+
+		pkt := impairedPacket{data: buf[:n], attrs: attrs}
+		if delay == 0 {
+			r.deliver(pkt)
+			continue
+		}
+		time.AfterFunc(delay, func() {
+			r.deliver(pkt)
+		})
+	}
+}
+
+func (r *impairedReader) deliver(pkt impairedPacket) {
+	select {
+	case r.packetCh <- pkt:
+	default:
+		// The reader is falling behind, drop the packet.
+	}
+}
+
+// impairedTrack binds the interceptor to the streams of a local track.
+type impairedTrack struct {
+	webrtc.TrackLocal
+	ic *impairmentInterceptor
+}
+
+func newImpairedTrack(track webrtc.TrackLocal, ic *impairmentInterceptor) *impairedTrack {
+	return &impairedTrack{
+		TrackLocal: track,
+		ic:         ic,
+	}
+}
+
+func (t *impairedTrack) Bind(ctx webrtc.TrackLocalContext) (webrtc.RTPCodecParameters, error) {
+	info := &interceptor.StreamInfo{
+		ID:   ctx.ID(),
+		SSRC: uint32(ctx.SSRC()),
+	}
+	stream := ctx.WriteStream()
+	writer := t.ic.BindLocalStream(info, interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, _ interceptor.Attributes) (int, error) {
+		return stream.WriteRTP(header, payload)
+	}))
+
+	return t.TrackLocal.Bind(&impairedTrackContext{
+		TrackLocalContext: ctx,
+		stream:            &impairedWriteStream{writer: writer},
+	})
+}
+
+type impairedTrackContext struct {
+	webrtc.TrackLocalContext
+	stream webrtc.TrackLocalWriter
+}
+
+func (c *impairedTrackContext) WriteStream() webrtc.TrackLocalWriter {
+	return c.stream
+}
+
+type impairedWriteStream struct {
+	writer interceptor.RTPWriter
+}
+
+func (s *impairedWriteStream) WriteRTP(header *rtp.Header, payload []byte) (int, error) {
+	return s.writer.Write(header, payload, nil)
+}
+
+func (s *impairedWriteStream) Write(b []byte) (int, error) {
+	var pkt rtp.Packet
+	if err := pkt.Unmarshal(b); err != nil {
+		return 0, err
+	}
+	return s.WriteRTP(&pkt.Header, pkt.Payload)
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+)
+
+func TestLinkSchedule(t *testing.T) {
+	now := time.Now()
+
+	t.Run("loss", func(t *testing.T) {
+		l := newLink(NetworkProfile{Loss: 0.2}, 1)
+		var dropped int
+		for range 10000 {
+			if _, ok := l.schedule(100, now); !ok {
+				dropped++
+			}
+		}
+		if dropped < 1800 || dropped > 2200 {
+			t.Errorf("got %d dropped packets, want about 2000", dropped)
+		}
+	})
+
+	t.Run("delay and jitter", func(t *testing.T) {
+		l := newLink(NetworkProfile{Delay: 50 * time.Millisecond, Jitter: 20 * time.Millisecond}, 1)
+		for range 1000 {
+			delay, ok := l.schedule(100, now)
+			if !ok {
+				t.Fatalf("unexpected drop")
+			}
+			if delay < 50*time.Millisecond || delay >= 70*time.Millisecond {
+				t.Fatalf("got delay %s, want [50ms, 70ms)", delay)
+			}
+		}
+	})
+
+	t.Run("bandwidth", func(t *testing.T) {
+		// 1000 bytes take 10ms to go through at 800kbps.
+		l := newLink(NetworkProfile{Bandwidth: 800_000}, 1)
+		for i := range 31 {
+			delay, ok := l.schedule(1000, now)
+			if !ok {
+				t.Fatalf("unexpected drop")
+			}
+			if want := time.Duration(i+1) * 10 * time.Millisecond; delay != want {
+				t.Fatalf("got delay %s, want %s", delay, want)
+			}
+		}
+		// The queue is full.
+		if _, ok := l.schedule(1000, now); ok {
+			t.Fatalf("expected drop")
+		}
+		// And drains over time.
+		if delay, ok := l.schedule(1000, now.Add(time.Second)); !ok || delay != 10*time.Millisecond {
+			t.Fatalf("got delay %s (%t), want 10ms", delay, ok)
+		}
+	})
+}
+
+type testWriteStream struct {
+	mut     sync.Mutex
+	packets []rtp.Packet
+}
+
+func (s *testWriteStream) WriteRTP(header *rtp.Header, payload []byte) (int, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.packets = append(s.packets, rtp.Packet{Header: header.Clone(), Payload: append([]byte(nil), payload...)})
+	return header.MarshalSize() + len(payload), nil
+}
+
+func (s *testWriteStream) Write(_ []byte) (int, error) {
+	return 0, nil
+}
+
+func (s *testWriteStream) numPackets() int {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return len(s.packets)
+}
+
+type testTrackContext struct {
+	webrtc.TrackLocalContext
+	stream *testWriteStream
+}
+
+func (c *testTrackContext) ID() string { return "id" }
+
+func (c *testTrackContext) SSRC() webrtc.SSRC { return 42 }
+
+func (c *testTrackContext) SSRCRetransmission() webrtc.SSRC { return 0 }
+
+func (c *testTrackContext) SSRCForwardErrorCorrection() webrtc.SSRC { return 0 }
+
+func (c *testTrackContext) WriteStream() webrtc.TrackLocalWriter { return c.stream }
+
+func (c *testTrackContext) CodecParameters() []webrtc.RTPCodecParameters {
+	return []webrtc.RTPCodecParameters{{RTPCodecCapability: rtpAudioCodec, PayloadType: 111}}
+}
+
+func TestImpairedTrack(t *testing.T) {
+	track, err := webrtc.NewTrackLocalStaticRTP(rtpAudioCodec, "audio", "voice")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ic := newImpairmentInterceptor(NetworkProfile{Delay: 50 * time.Millisecond})
+	stream := &testWriteStream{}
+	if _, err := newImpairedTrack(track, ic).Bind(&testTrackContext{stream: stream}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	start := time.Now()
+	payload := []byte{1, 2, 3}
+	if err := track.WriteRTP(&rtp.Packet{Header: rtp.Header{SequenceNumber: 1}, Payload: payload}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	// The packet can be reused right away.
+	payload[0] = 9
+
+	if n := stream.numPackets(); n != 0 {
+		t.Fatalf("got %d packets, want 0", n)
+	}
+	for stream.numPackets() == 0 {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("timed out waiting for packet")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("packet delivered after %s, want >= 50ms", elapsed)
+	}
+
+	stream.mut.Lock()
+	defer stream.mut.Unlock()
+	pkt := stream.packets[0]
+	if pkt.SSRC != 42 || pkt.PayloadType != 111 || pkt.SequenceNumber != 1 || pkt.Payload[0] != 1 {
+		t.Errorf("unexpected packet: %+v", pkt)
+	}
+}
+
+func TestImpairedReader(t *testing.T) {
+	const numPackets = 1000
+
+	var readCount int
+	src := interceptor.RTPReaderFunc(func(b []byte, _ interceptor.Attributes) (int, interceptor.Attributes, error) {
+		if readCount == numPackets {
+			return 0, nil, io.EOF
+		}
+		readCount++
+		b[0] = byte(readCount)
+		return 1, nil, nil
+	})
+
+	ic := newImpairmentInterceptor(NetworkProfile{Loss: 0.5})
+	reader := ic.BindRemoteStream(&interceptor.StreamInfo{}, src)
+
+	buf := make([]byte, receiveMTU)
+	var received int
+	for {
+		n, _, err := reader.Read(buf, nil)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if n != 1 {
+			t.Fatalf("got %d bytes, want 1", n)
+		}
+		received++
+	}
+
+	if received < 400 || received > 600 {
+		t.Errorf("got %d packets, want about %d", received, numPackets/2)
+	}
+}
+
+func TestImpairedReaderError(t *testing.T) {
+	// The source fails right away while nobody is reading, the error should
+	// still be returned to every subsequent read.
+	src := interceptor.RTPReaderFunc(func(_ []byte, _ interceptor.Attributes) (int, interceptor.Attributes, error) {
+		return 0, nil, io.ErrUnexpectedEOF
+	})
+
+	ic := newImpairmentInterceptor(NetworkProfile{})
+	reader := ic.BindRemoteStream(&interceptor.StreamInfo{}, src).(*impairedReader)
+
+	buf := make([]byte, receiveMTU)
+	for range 3 {
+		if _, _, err := reader.Read(buf, nil); err != io.ErrUnexpectedEOF {
+			t.Fatalf("got error %v, want %v", err, io.ErrUnexpectedEOF)
+		}
+	}
+
+	select {
+	case <-reader.doneCh:
+	default:
+		t.Fatal("read loop should be done")
+	}
+
+	t.Run("queue full", func(t *testing.T) {
+		// The read loop should exit even if nobody reads the queued packets.
+		var readCount int
+		src := interceptor.RTPReaderFunc(func(_ []byte, _ interceptor.Attributes) (int, interceptor.Attributes, error) {
+			if readCount == 2*impairedReadQueueSize {
+				return 0, nil, io.EOF
+			}
+			readCount++
+			return 1, nil, nil
+		})
+
+		reader := ic.BindRemoteStream(&interceptor.StreamInfo{}, src).(*impairedReader)
+		if _, _, err := reader.Read(buf, nil); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		select {
+		case <-reader.doneCh:
+		case <-time.After(time.Second):
+			t.Fatal("read loop should be done")
+		}
+	})
+}
@@ -0,0 +1,417 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media"
+	"github.com/pion/webrtc/v4/pkg/media/ivfreader"
+	"github.com/pion/webrtc/v4/pkg/media/oggreader"
+)
+
+// MediaStream is a never ending stream of encoded media samples: Opus packets
+// for audio, VP8 or AV1 frames for video.
+type MediaStream interface {
+	Next() (media.Sample, error)
+	Close() error
+}
+
+// MediaSource provides the media sent by users.
+type MediaSource interface {
+	// Audio returns the stream sent by unmuted users.
+	Audio() (MediaStream, error)
+	// Video returns the stream sent as a video or screen track (videoType),
+	// for the given simulcast level (rid) and codec.
+	Video(videoType, rid, mimeType string) (MediaStream, error)
+}
+
+// oggStream loops over Ogg/Opus files, one Opus packet per page.
+type oggStream struct {
+	files       []string
+	idx         int
+	file        *os.File
+	reader      *oggreader.OggReader
+	lastGranule uint64
+}
+
+func newOggStream(files []string) (*oggStream, error) {
+	s := &oggStream{files: files}
+	if err := s.open(0); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *oggStream) open(idx int) error {
+	if s.file != nil {
+		s.file.Close()
+	}
+
+	f, err := os.Open(s.files[idx])
+	if err != nil {
+		return fmt.Errorf("failed to open ogg file: %w", err)
+	}
+
+	reader, _, err := oggreader.NewWith(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to read ogg file %s: %w", s.files[idx], err)
+	}
+
+	s.idx = idx
+	s.file = f
+	s.reader = reader
+	s.lastGranule = 0
+
+	return nil
+}
+
+func (s *oggStream) Next() (media.Sample, error) {
+	for reopened := 0; reopened <= len(s.files); {
+		data, header, err := s.reader.ParseNextPage()
+		if errors.Is(err, io.EOF) {
+			if err := s.open((s.idx + 1) % len(s.files)); err != nil {
+				return media.Sample{}, err
+			}
+			reopened++
+			continue
+		} else if err != nil {
+			return media.Sample{}, fmt.Errorf("failed to parse ogg page: %w", err)
+		}
+
+		// The first page (OpusHead) is consumed when opening the file.
+		if bytes.HasPrefix(data, []byte("OpusTags")) {
+			continue
+		}
+
+		// The amount of samples is the difference between the last and current granule.
+		sampleCount := float64(header.GranulePosition - s.lastGranule)
+		s.lastGranule = header.GranulePosition
+
+		return media.Sample{
+			Data:     data,
+			Duration: time.Duration((sampleCount/48000)*1000) * time.Millisecond,
+		}, nil
+	}
+
+	return media.Sample{}, fmt.Errorf("no pages found in %s", strings.Join(s.files, ", "))
+}
+
+func (s *oggStream) Close() error {
+	return s.file.Close()
+}
+
+// ivfStream loops over IVF files.
+type ivfStream struct {
+	files  []string
+	idx    int
+	file   *os.File
+	reader *ivfreader.IVFReader
+	header *ivfreader.IVFFileHeader
+	// The timestamp of the last frame, and how long the last frame lasted.
+	lastPTS       uint64
+	lastDuration  time.Duration
+	gotFirstFrame bool
+}
+
+func newIVFStream(files []string) (*ivfStream, error) {
+	s := &ivfStream{files: files}
+	if err := s.open(0); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *ivfStream) open(idx int) error {
+	if s.file != nil {
+		s.file.Close()
+	}
+
+	f, err := os.Open(s.files[idx])
+	if err != nil {
+		return fmt.Errorf("failed to open ivf file: %w", err)
+	}
+
+	reader, header, err := ivfreader.NewWith(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to read ivf file %s: %w", s.files[idx], err)
+	}
+
+	s.idx = idx
+	s.file = f
+	s.reader = reader
+	s.header = header
+	s.gotFirstFrame = false
+	if s.lastDuration == 0 {
+		// Assuming a timebase of one frame (e.g. 1/30s), as the samples have.
+		s.lastDuration = s.ptsDuration(1)
+	}
+
+	return nil
+}
+
+func (s *ivfStream) Next() (media.Sample, error) {
+	for reopened := 0; reopened <= len(s.files); reopened++ {
+		frame, header, err := s.reader.ParseNextFrame()
+		if errors.Is(err, io.EOF) || (err != nil && err.Error() == "incomplete frame data") {
+			if err := s.open((s.idx + 1) % len(s.files)); err != nil {
+				return media.Sample{}, err
+			}
+			continue
+		} else if err != nil {
+			return media.Sample{}, fmt.Errorf("failed to parse ivf frame: %w", err)
+		}
+
+		// Frames last until the next one, approximated by the time elapsed
+		// since the previous one. The reader scales the frame timestamps
+		// which we need in timebase units.
+		pts := header.Timestamp * uint64(s.header.TimebaseNumerator) / uint64(s.header.TimebaseDenominator)
+		if s.gotFirstFrame && pts > s.lastPTS {
+			s.lastDuration = s.ptsDuration(pts - s.lastPTS)
+		}
+		s.lastPTS = pts
+		s.gotFirstFrame = true
+
+		return media.Sample{
+			Data:     frame,
+			Duration: s.lastDuration,
+		}, nil
+	}
+
+	return media.Sample{}, fmt.Errorf("no frames found in %s", strings.Join(s.files, ", "))
+}
+
+func (s *ivfStream) ptsDuration(pts uint64) time.Duration {
+	return time.Duration(pts) * time.Duration(s.header.TimebaseNumerator) * time.Second / time.Duration(s.header.TimebaseDenominator)
+}
+
+func (s *ivfStream) Close() error {
+	return s.file.Close()
+}
+
+// FileMediaSource loops over single sample files.
+type FileMediaSource struct {
+	// The Ogg/Opus audio file.
+	AudioFile string
+	// The directory of IVF video files, named after the video type,
+	// codec and simulcast level (e.g. screen_h.ivf, video_av1_h.ivf).
+	VideoDir string
+}
+
+func (s *FileMediaSource) Audio() (MediaStream, error) {
+	return newOggStream([]string{s.AudioFile})
+}
+
+func (s *FileMediaSource) Video(videoType, rid, mimeType string) (MediaStream, error) {
+	filename := fmt.Sprintf("%s_%s.ivf", videoType, rid)
+	if mimeType == webrtc.MimeTypeAV1 {
+		filename = fmt.Sprintf("%s_av1_%s.ivf", videoType, rid)
+	}
+	return newIVFStream([]string{filepath.Join(s.VideoDir, filename)})
+}
+
+// DirMediaSource loops over all the Ogg/Opus (.ogg) and IVF (.ivf) files of
+// a directory, in lexical order. Only the IVF files matching the track's
+// codec are used.
+type DirMediaSource struct {
+	Dir string
+}
+
+func (s *DirMediaSource) Audio() (MediaStream, error) {
+	files, err := filepath.Glob(filepath.Join(s.Dir, "*.ogg"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audio files: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no audio files found in %s", s.Dir)
+	}
+	return newOggStream(files)
+}
+
+func (s *DirMediaSource) Video(_, _, mimeType string) (MediaStream, error) {
+	files, err := filepath.Glob(filepath.Join(s.Dir, "*.ivf"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list video files: %w", err)
+	}
+
+	fourCC := "VP80"
+	if mimeType == webrtc.MimeTypeAV1 {
+		fourCC = "AV01"
+	}
+
+	var matching []string
+	for _, file := range files {
+		cc, err := readIVFFourCC(file)
+		if err != nil {
+			return nil, err
+		}
+		if cc == fourCC {
+			matching = append(matching, file)
+		}
+	}
+	if len(matching) == 0 {
+		return nil, fmt.Errorf("no %s video files found in %s", fourCC, s.Dir)
+	}
+
+	return newIVFStream(matching)
+}
+
+func readIVFFourCC(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to open ivf file: %w", err)
+	}
+	defer f.Close()
+
+	_, header, err := ivfreader.NewWith(f)
+	if err != nil {
+		return "", fmt.Errorf("failed to read ivf file %s: %w", filename, err)
+	}
+
+	return header.FourCC, nil
+}
+
+const (
+	patternMagicAudio = "LTPA"
+	patternMagicVideo = "LTPV"
+	// The Opus TOC byte of a SILK-only, narrowband, 20ms, single frame packet.
+	patternOpusTOC    = 0x08
+	patternFrameRate  = 30
+	patternGOPSize    = 60
+	patternKeyFrameSz = 4000
+	patternFrameSz    = 1200
+	patternAudioSz    = 40
+	patternWidth      = 1280
+	patternHeight     = 720
+)
+
+// PatternMediaSource generates test patterns rather than real media, each
+// frame carrying a counter so that receivers can verify that no frame went
+// missing end to end (see MediaSink).
+//
+// Audio frames are 20ms Opus packets and video frames are VP8 frames at 30
+// fps, with a key frame every 2 seconds. Both only have valid headers and
+// don't decode to anything meaningful. AV1 is not supported.
+type PatternMediaSource struct{}
+
+func (s *PatternMediaSource) Audio() (MediaStream, error) {
+	return &patternStream{
+		magic:    patternMagicAudio,
+		duration: 20 * time.Millisecond,
+	}, nil
+}
+
+func (s *PatternMediaSource) Video(_, rid, mimeType string) (MediaStream, error) {
+	if mimeType != webrtc.MimeTypeVP8 {
+		return nil, fmt.Errorf("unsupported codec %s for pattern video", mimeType)
+	}
+
+	var layer byte
+	if rid != "" {
+		layer = rid[0]
+	}
+
+	return &patternStream{
+		magic:    patternMagicVideo,
+		layer:    layer,
+		duration: time.Second / patternFrameRate,
+	}, nil
+}
+
+type patternStream struct {
+	magic    string
+	layer    byte
+	duration time.Duration
+	counter  uint32
+}
+
+func (s *patternStream) Next() (media.Sample, error) {
+	var data []byte
+	if s.magic == patternMagicAudio {
+		data = make([]byte, 1, patternAudioSz)
+		data[0] = patternOpusTOC
+	} else {
+		data = newVP8PatternFrame(s.counter%patternGOPSize == 0)
+	}
+
+	data = append(data, s.magic...)
+	data = append(data, s.layer)
+	data = binary.BigEndian.AppendUint32(data, s.counter)
+	s.counter++
+
+	return media.Sample{
+		Data:     data[:cap(data)],
+		Duration: s.duration,
+	}, nil
+}
+
+func (s *patternStream) Close() error {
+	return nil
+}
+
+// newVP8PatternFrame returns the VP8 frame header (RFC 6386, section 9.1) of
+// a pattern frame, with enough capacity for the whole frame.
+func newVP8PatternFrame(keyFrame bool) []byte {
+	size := patternFrameSz
+	if keyFrame {
+		size = patternKeyFrameSz
+	}
+	data := make([]byte, 3, size)
+
+	// Version 0, shown frame, first partition spanning the whole frame.
+	tag := uint32(size-3)<<5 | 1<<4
+	if !keyFrame {
+		tag |= 1
+	}
+	data[0], data[1], data[2] = byte(tag), byte(tag>>8), byte(tag>>16)
+
+	if keyFrame {
+		data = append(data, 0x9d, 0x01, 0x2a)
+		data = binary.LittleEndian.AppendUint16(data, patternWidth)
+		data = binary.LittleEndian.AppendUint16(data, patternHeight)
+	}
+
+	return data
+}
+
+// parsePatternFrame returns the layer and counter of a pattern frame, or
+// false if data is not one.
+func parsePatternFrame(data []byte, kind webrtc.RTPCodecType) (byte, uint32, bool) {
+	magic := patternMagicAudio
+	if kind == webrtc.RTPCodecTypeVideo {
+		magic = patternMagicVideo
+		if len(data) < 3 {
+			return 0, 0, false
+		}
+		if data[0]&1 == 0 {
+			// Key frame, skipping the start code and dimensions.
+			data = data[10:]
+		} else {
+			data = data[3:]
+		}
+	} else {
+		if len(data) < 1 {
+			return 0, 0, false
+		}
+		data = data[1:]
+	}
+
+	if len(data) < len(magic)+5 || !strings.HasPrefix(string(data), magic) {
+		return 0, 0, false
+	}
+	data = data[len(magic):]
+
+	return data[0], binary.BigEndian.Uint32(data[1:5]), true
+}
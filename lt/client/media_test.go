@@ -0,0 +1,237 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media/ivfwriter"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+)
+
+// packetizeStream returns the RTP packets of the next n samples of stream.
+func packetizeStream(t *testing.T, stream MediaStream, n int, kind webrtc.RTPCodecType) []*rtp.Packet {
+	t.Helper()
+
+	var payloader rtp.Payloader = &codecs.OpusPayloader{}
+	clockRate := uint32(48000)
+	if kind == webrtc.RTPCodecTypeVideo {
+		payloader = &codecs.VP8Payloader{EnablePictureID: true}
+		clockRate = 90000
+	}
+	packetizer := rtp.NewPacketizer(sendMTU, 96, 1, payloader, rtp.NewFixedSequencer(0), clockRate)
+
+	var packets []*rtp.Packet
+	for range n {
+		sample, err := stream.Next()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		samples := uint32(math.Round(sample.Duration.Seconds() * float64(clockRate)))
+		packets = append(packets, packetizer.Packetize(sample.Data, samples)...)
+	}
+
+	return packets
+}
+
+// writePatternFiles writes n pattern frames of the given layer to both an
+// Ogg and an IVF file.
+func writePatternFiles(t *testing.T, dir, name string, layer byte, n int) {
+	t.Helper()
+
+	audio := &patternStream{magic: patternMagicAudio, layer: layer, duration: 20 * time.Millisecond}
+	ogg, err := oggwriter.New(filepath.Join(dir, name+".ogg"), 48000, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	video := &patternStream{magic: patternMagicVideo, layer: layer, duration: time.Second / patternFrameRate}
+	ivf, err := ivfwriter.New(filepath.Join(dir, name+".ivf"), ivfwriter.WithCodec(webrtc.MimeTypeVP8), ivfwriter.WithFrameRate(1, 90000), ivfwriter.WithDirectPTS())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, w := range []struct {
+		stream MediaStream
+		writer rtpWriter
+		kind   webrtc.RTPCodecType
+	}{
+		{audio, ogg, webrtc.RTPCodecTypeAudio},
+		{video, ivf, webrtc.RTPCodecTypeVideo},
+	} {
+		for _, pkt := range packetizeStream(t, w.stream, n, w.kind) {
+			if err := w.writer.WriteRTP(pkt); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+		if err := w.writer.Close(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+}
+
+func TestPatternMediaSource(t *testing.T) {
+	var src PatternMediaSource
+
+	t.Run("audio", func(t *testing.T) {
+		stream, err := src.Audio()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		for i := range 5 {
+			sample, err := stream.Next()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if sample.Duration != 20*time.Millisecond {
+				t.Errorf("got duration %s, want 20ms", sample.Duration)
+			}
+			if sample.Data[0] != patternOpusTOC {
+				t.Errorf("got TOC %#x, want %#x", sample.Data[0], patternOpusTOC)
+			}
+			layer, counter, ok := parsePatternFrame(sample.Data, webrtc.RTPCodecTypeAudio)
+			if !ok || layer != 0 || counter != uint32(i) {
+				t.Errorf("got layer %d, counter %d (%t), want counter %d", layer, counter, ok, i)
+			}
+		}
+	})
+
+	t.Run("video", func(t *testing.T) {
+		stream, err := src.Video("screen", "h", webrtc.MimeTypeVP8)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		for i := range patternGOPSize + 2 {
+			sample, err := stream.Next()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			keyFrame := sample.Data[0]&1 == 0
+			if wantKey := i%patternGOPSize == 0; keyFrame != wantKey {
+				t.Errorf("frame %d: got key frame %t, want %t", i, keyFrame, wantKey)
+			}
+			if keyFrame && len(sample.Data) != patternKeyFrameSz {
+				t.Errorf("frame %d: got size %d, want %d", i, len(sample.Data), patternKeyFrameSz)
+			}
+
+			layer, counter, ok := parsePatternFrame(sample.Data, webrtc.RTPCodecTypeVideo)
+			if !ok || layer != 'h' || counter != uint32(i) {
+				t.Errorf("got layer %c, counter %d (%t), want counter %d", layer, counter, ok, i)
+			}
+		}
+	})
+
+	t.Run("unsupported codec", func(t *testing.T) {
+		if _, err := src.Video("video", "h", webrtc.MimeTypeAV1); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+
+	t.Run("not a pattern", func(t *testing.T) {
+		for _, data := range [][]byte{nil, {0x08}, {0x08, 'L', 'T', 'P', 'V', 0, 0, 0, 0, 1}, []byte("\x08some opus data")} {
+			if _, _, ok := parsePatternFrame(data, webrtc.RTPCodecTypeAudio); ok {
+				t.Errorf("%q: unexpected pattern frame", data)
+			}
+		}
+	})
+}
+
+func TestDirMediaSource(t *testing.T) {
+	dir := t.TempDir()
+	src := &DirMediaSource{Dir: dir}
+
+	t.Run("empty", func(t *testing.T) {
+		if _, err := src.Audio(); err == nil {
+			t.Fatalf("expected error")
+		}
+		if _, err := src.Video("video", "h", webrtc.MimeTypeVP8); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+
+	writePatternFiles(t, dir, "b", 'b', 2)
+	writePatternFiles(t, dir, "a", 'a', 3)
+
+	// Files are played in order, then looped over.
+	type frame struct {
+		layer   byte
+		counter uint32
+	}
+	want := []frame{{'a', 0}, {'a', 1}, {'a', 2}, {'b', 0}, {'b', 1}, {'a', 0}, {'a', 1}}
+
+	for _, kind := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeAudio, webrtc.RTPCodecTypeVideo} {
+		t.Run(kind.String(), func(t *testing.T) {
+			var stream MediaStream
+			var err error
+			if kind == webrtc.RTPCodecTypeAudio {
+				stream, err = src.Audio()
+			} else {
+				stream, err = src.Video("video", "h", webrtc.MimeTypeVP8)
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			defer stream.Close()
+
+			for i, w := range want {
+				sample, err := stream.Next()
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				layer, counter, ok := parsePatternFrame(sample.Data, kind)
+				if !ok || layer != w.layer || counter != w.counter {
+					t.Errorf("sample %d: got %c/%d (%t), want %c/%d", i, layer, counter, ok, w.layer, w.counter)
+				}
+				// Timestamps are rounded to the RTP clock rate.
+				if kind == webrtc.RTPCodecTypeVideo && i > 0 && (sample.Duration < 33*time.Millisecond || sample.Duration > 34*time.Millisecond) {
+					t.Errorf("sample %d: got duration %s", i, sample.Duration)
+				}
+			}
+		})
+	}
+
+	t.Run("no matching codec", func(t *testing.T) {
+		if _, err := src.Video("video", "h", webrtc.MimeTypeAV1); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+}
+
+func TestFileMediaSource(t *testing.T) {
+	dir := t.TempDir()
+	writePatternFiles(t, dir, "screen_h", 'h', 2)
+
+	src := &FileMediaSource{
+		AudioFile: filepath.Join(dir, "screen_h.ogg"),
+		VideoDir:  dir,
+	}
+
+	stream, err := src.Audio()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	stream.Close()
+
+	stream, err = src.Video("screen", "h", webrtc.MimeTypeVP8)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	stream.Close()
+
+	if _, err := src.Video("video", "h", webrtc.MimeTypeVP8); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := src.Video("screen", "h", webrtc.MimeTypeAV1); err == nil {
+		t.Fatalf("expected error")
+	}
+}
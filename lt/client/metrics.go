@@ -0,0 +1,128 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// Names of the metrics recorded by users.
+const (
+	// The time to log in (and register when needed) the user.
+	MetricLogin = "login"
+	// The time between connecting the calls client and the WebSocket being ready.
+	MetricWSConnect = "ws_connect"
+	// The time between connecting the calls client and the join being acknowledged.
+	MetricJoin = "join"
+	// The time between the join being acknowledged and ICE connecting.
+	MetricICEConnect = "ice_connect"
+	// The time between connecting the calls client and the first remote track arriving.
+	MetricFirstTrack = "first_track"
+	// The time it takes to reconnect the WebSocket after a disconnection.
+	MetricReconnect = "reconnect"
+	// The duration of received video freezes (see SinkConfig).
+	MetricFreeze = "freeze"
+
+	// Failures that happen while setting up the user (team and channel membership).
+	MetricSetup = "setup"
+	// Failures that happen while connecting or during the call.
+	MetricCall = "call"
+	// Received tracks that missed packets or pattern frames (see SinkConfig).
+	MetricMediaGap = "media_gap"
+)
+
+// MetricsRecorder receives the timings and failures measured by users.
+// Implementations must be safe for concurrent use.
+type MetricsRecorder interface {
+	RecordTiming(metric string, d time.Duration)
+	RecordError(metric string, err error)
+}
+
+func WithMetrics(m MetricsRecorder) Option {
+	return func(u *User) {
+		u.metrics = m
+	}
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) RecordTiming(_ string, _ time.Duration) {}
+func (noopMetrics) RecordError(_ string, _ error)          {}
+
+// connTimings measures the timings of a single call connection.
+type connTimings struct {
+	metrics MetricsRecorder
+
+	mut          sync.Mutex
+	connectAt    time.Time
+	joinAt       time.Time
+	disconnectAt time.Time
+	wsConnected  bool
+	iceConnected bool
+	gotTrack     bool
+}
+
+func newConnTimings(metrics MetricsRecorder) *connTimings {
+	return &connTimings{
+		metrics:   metrics,
+		connectAt: time.Now(),
+	}
+}
+
+func (t *connTimings) onWSConnect() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if !t.wsConnected {
+		t.wsConnected = true
+		t.metrics.RecordTiming(MetricWSConnect, time.Since(t.connectAt))
+		return
+	}
+
+	if !t.disconnectAt.IsZero() {
+		t.metrics.RecordTiming(MetricReconnect, time.Since(t.disconnectAt))
+		t.disconnectAt = time.Time{}
+	}
+}
+
+func (t *connTimings) onWSDisconnect() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	// Reconnection attempts can fail, we measure from the first disconnection.
+	if t.disconnectAt.IsZero() {
+		t.disconnectAt = time.Now()
+	}
+}
+
+func (t *connTimings) onJoin() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if t.joinAt.IsZero() {
+		t.joinAt = time.Now()
+		t.metrics.RecordTiming(MetricJoin, t.joinAt.Sub(t.connectAt))
+	}
+}
+
+func (t *connTimings) onICEConnect() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if !t.iceConnected && !t.joinAt.IsZero() {
+		t.iceConnected = true
+		t.metrics.RecordTiming(MetricICEConnect, time.Since(t.joinAt))
+	}
+}
+
+func (t *connTimings) onTrack() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if !t.gotTrack {
+		t.gotTrack = true
+		t.metrics.RecordTiming(MetricFirstTrack, time.Since(t.connectAt))
+	}
+}
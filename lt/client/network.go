@@ -0,0 +1,150 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NetworkProfile describes the network conditions simulated for a user, on
+// both the media it sends and receives.
+type NetworkProfile struct {
+	// The share of packets dropped, in the range [0, 1].
+	Loss float64
+	// The delay added to every packet.
+	Delay time.Duration
+	// The random delay added on top of Delay, uniformly distributed in
+	// [0, Jitter). Packets can get reordered as a result.
+	Jitter time.Duration
+	// The maximum throughput in bits per second, zero for unlimited. Packets
+	// get queued when exceeding it, and dropped once the queue is full.
+	Bandwidth int
+}
+
+// NetworkProfiles are the built-in profiles, to be referred to by name.
+var NetworkProfiles = map[string]NetworkProfile{
+	"wifi": {
+		Loss:   0.01,
+		Delay:  20 * time.Millisecond,
+		Jitter: 10 * time.Millisecond,
+	},
+	"bad_wifi": {
+		Loss:      0.05,
+		Delay:     50 * time.Millisecond,
+		Jitter:    40 * time.Millisecond,
+		Bandwidth: 2_000_000,
+	},
+	"3g": {
+		Loss:      0.02,
+		Delay:     150 * time.Millisecond,
+		Jitter:    50 * time.Millisecond,
+		Bandwidth: 750_000,
+	},
+	"lossy": {
+		Loss: 0.1,
+	},
+}
+
+func (p NetworkProfile) IsValid() error {
+	if p.Loss < 0 || p.Loss > 1 {
+		return fmt.Errorf("loss should be in the range [0, 1]")
+	}
+
+	if p.Delay < 0 {
+		return fmt.Errorf("delay should be >= 0")
+	}
+
+	if p.Jitter < 0 {
+		return fmt.Errorf("jitter should be >= 0")
+	}
+
+	if p.Bandwidth < 0 {
+		return fmt.Errorf("bandwidth should be >= 0")
+	}
+
+	return nil
+}
+
+func (p NetworkProfile) String() string {
+	return fmt.Sprintf("loss=%g%%,delay=%s,jitter=%s,bandwidth=%d", p.Loss*100, p.Delay, p.Jitter, p.Bandwidth)
+}
+
+// ParseNetworkProfile parses either the name of a built-in profile (e.g.
+// bad_wifi) or a comma separated list of settings, e.g.
+// "loss=5%,delay=100ms,jitter=30ms,bandwidth=500k". Loss can also be given as
+// a ratio (e.g. 0.05) and bandwidth accepts the k and m suffixes.
+func ParseNetworkProfile(s string) (NetworkProfile, error) {
+	s = strings.TrimSpace(s)
+	if p, ok := NetworkProfiles[s]; ok {
+		return p, nil
+	}
+
+	if !strings.Contains(s, "=") {
+		names := make([]string, 0, len(NetworkProfiles))
+		for name := range NetworkProfiles {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return NetworkProfile{}, fmt.Errorf("unknown network profile %q, should be one of %s or a list of settings", s, strings.Join(names, ", "))
+	}
+
+	var p NetworkProfile
+	for setting := range strings.SplitSeq(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(setting), "=")
+		if !ok {
+			return NetworkProfile{}, fmt.Errorf("invalid setting %q", setting)
+		}
+
+		var err error
+		switch key {
+		case "loss":
+			p.Loss, err = parseLoss(value)
+		case "delay":
+			p.Delay, err = time.ParseDuration(value)
+		case "jitter":
+			p.Jitter, err = time.ParseDuration(value)
+		case "bandwidth":
+			p.Bandwidth, err = parseBandwidth(value)
+		default:
+			return NetworkProfile{}, fmt.Errorf("unknown setting %q", key)
+		}
+		if err != nil {
+			return NetworkProfile{}, fmt.Errorf("invalid %s value %q: %w", key, value, err)
+		}
+	}
+
+	if err := p.IsValid(); err != nil {
+		return NetworkProfile{}, err
+	}
+
+	return p, nil
+}
+
+func parseLoss(s string) (float64, error) {
+	if pct, ok := strings.CutSuffix(s, "%"); ok {
+		v, err := strconv.ParseFloat(pct, 64)
+		return v / 100, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func parseBandwidth(s string) (int, error) {
+	mult := 1
+	switch {
+	case strings.HasSuffix(s, "k"):
+		mult = 1000
+	case strings.HasSuffix(s, "m"):
+		mult = 1000_000
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+
+	v, err := strconv.Atoi(s)
+	return v * mult, err
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNetworkProfile(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    NetworkProfile
+		wantErr bool
+	}{
+		{input: "bad_wifi", want: NetworkProfiles["bad_wifi"]},
+		{input: "loss=5%", want: NetworkProfile{Loss: 0.05}},
+		{input: "loss=0.1,delay=100ms", want: NetworkProfile{Loss: 0.1, Delay: 100 * time.Millisecond}},
+		{
+			input: " loss=2%, delay=50ms, jitter=30ms, bandwidth=500k ",
+			want:  NetworkProfile{Loss: 0.02, Delay: 50 * time.Millisecond, Jitter: 30 * time.Millisecond, Bandwidth: 500_000},
+		},
+		{input: "bandwidth=2m", want: NetworkProfile{Bandwidth: 2_000_000}},
+		{input: "bandwidth=64000", want: NetworkProfile{Bandwidth: 64000}},
+		{input: "", wantErr: true},
+		{input: "unknown", wantErr: true},
+		{input: "loss", wantErr: true},
+		{input: "loss=150%", wantErr: true},
+		{input: "delay=-1s", wantErr: true},
+		{input: "jitter=fast", wantErr: true},
+		{input: "bandwidth=1g", wantErr: true},
+		{input: "latency=10ms", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		got, err := ParseNetworkProfile(tc.input)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected error", tc.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tc.input, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %+v, want %+v", tc.input, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v4"
+	"github.com/pion/webrtc/v4/pkg/media/ivfwriter"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+)
+
+const defaultFreezeThreshold = 500 * time.Millisecond
+
+// SinkConfig enables checking the continuity of the tracks received by a
+// user.
+type SinkConfig struct {
+	// Optional, the directory where received tracks get written, as IVF
+	// files for video and Ogg files for audio.
+	Dir string
+	// How long a video track can go without a new frame before being
+	// considered frozen. Defaults to 500ms.
+	FreezeThreshold time.Duration
+}
+
+// TrackStats summarizes the continuity of a received track.
+type TrackStats struct {
+	TrackID  string `json:"track_id"`
+	Kind     string `json:"kind"`
+	MimeType string `json:"mime_type"`
+	Packets  int    `json:"packets"`
+	// The number of packets that never arrived.
+	Lost int `json:"lost"`
+	// The number of jumps in sequence numbers, each missing one or more packets.
+	Gaps int `json:"gaps"`
+	// The number of packets that arrived out of order.
+	Late   int `json:"late"`
+	Frames int `json:"frames"`
+	// Video only, the number of times no frame arrived for longer than the
+	// freeze threshold.
+	Freezes       int           `json:"freezes"`
+	LongestFreeze time.Duration `json:"longest_freeze"`
+	// The number of frames generated by a PatternMediaSource that were
+	// received, and of the ones that went missing.
+	PatternFrames int `json:"pattern_frames"`
+	PatternLost   int `json:"pattern_lost"`
+}
+
+type rtpWriter interface {
+	WriteRTP(pkt *rtp.Packet) error
+	Close() error
+}
+
+// trackSink checks the packets of a received track, writing them to disk if
+// configured to.
+type trackSink struct {
+	cfg     SinkConfig
+	metrics MetricsRecorder
+	kind    webrtc.RTPCodecType
+	writer  rtpWriter
+
+	mut   sync.Mutex
+	stats TrackStats
+	// The highest sequence number received, extended to not wrap around.
+	maxSeq      uint64
+	started     bool
+	lastTS      uint32
+	lastFrameAt time.Time
+	// The layer and counter of the last pattern frame.
+	patternLayer   byte
+	patternCounter uint32
+	gotPattern     bool
+}
+
+func newTrackSink(cfg SinkConfig, metrics MetricsRecorder, trackID, mimeType string, kind webrtc.RTPCodecType, filename string) (*trackSink, error) {
+	if cfg.FreezeThreshold <= 0 {
+		cfg.FreezeThreshold = defaultFreezeThreshold
+	}
+
+	s := &trackSink{
+		cfg:     cfg,
+		metrics: metrics,
+		kind:    kind,
+		stats: TrackStats{
+			TrackID:  trackID,
+			Kind:     kind.String(),
+			MimeType: mimeType,
+		},
+	}
+
+	if cfg.Dir != "" {
+		var err error
+		switch mimeType {
+		case webrtc.MimeTypeOpus:
+			s.writer, err = oggwriter.New(filepath.Join(cfg.Dir, filename+".ogg"), 48000, 2)
+		case webrtc.MimeTypeVP8, webrtc.MimeTypeAV1:
+			// Keeping the RTP timestamps as they are, which the default
+			// conversion to frames only approximates.
+			s.writer, err = ivfwriter.New(filepath.Join(cfg.Dir, filename+".ivf"), ivfwriter.WithCodec(mimeType),
+				ivfwriter.WithFrameRate(1, 90000), ivfwriter.WithDirectPTS())
+		default:
+			err = fmt.Errorf("unsupported codec %s", mimeType)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create track writer: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+func (s *trackSink) onPacket(pkt *rtp.Packet, now time.Time) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.stats.Packets++
+
+	if !s.started {
+		s.started = true
+		// Starting away from zero so that late packets don't underflow.
+		s.maxSeq = 1<<16 + uint64(pkt.SequenceNumber)
+		s.onFrame(pkt, now)
+		return s.write(pkt)
+	}
+
+	seq := s.maxSeq + uint64(int16(pkt.SequenceNumber-uint16(s.maxSeq)))
+	if seq <= s.maxSeq {
+		// Either a retransmission or a reordered packet, which already got
+		// accounted as lost.
+		s.stats.Late++
+		if s.stats.Lost > 0 {
+			s.stats.Lost--
+		}
+		s.checkPattern(pkt)
+		return nil
+	}
+
+	if missing := int(seq - s.maxSeq - 1); missing > 0 {
+		s.stats.Gaps++
+		s.stats.Lost += missing
+	}
+	s.maxSeq = seq
+
+	if pkt.Timestamp != s.lastTS {
+		s.onFrame(pkt, now)
+	}
+
+	return s.write(pkt)
+}
+
+// onFrame handles the first packet of a frame.
+func (s *trackSink) onFrame(pkt *rtp.Packet, now time.Time) {
+	s.stats.Frames++
+
+	if s.kind == webrtc.RTPCodecTypeVideo && !s.lastFrameAt.IsZero() {
+		// Audio is not checked as muting stops it altogether.
+		if d := now.Sub(s.lastFrameAt); d > s.cfg.FreezeThreshold {
+			s.stats.Freezes++
+			s.stats.LongestFreeze = max(s.stats.LongestFreeze, d)
+			s.metrics.RecordTiming(MetricFreeze, d)
+		}
+	}
+	s.lastTS = pkt.Timestamp
+	s.lastFrameAt = now
+
+	s.checkPattern(pkt)
+}
+
+func (s *trackSink) checkPattern(pkt *rtp.Packet) {
+	data := pkt.Payload
+	if s.stats.MimeType == webrtc.MimeTypeVP8 {
+		var vp8 codecs.VP8Packet
+		if _, err := vp8.Unmarshal(pkt.Payload); err != nil || vp8.S != 1 || vp8.PID != 0 {
+			return
+		}
+		data = vp8.Payload
+	}
+
+	layer, counter, ok := parsePatternFrame(data, s.kind)
+	if !ok {
+		return
+	}
+	s.stats.PatternFrames++
+
+	// Switching simulcast layers restarts the count.
+	if s.gotPattern && layer == s.patternLayer {
+		if counter <= s.patternCounter {
+			// A late frame, which already got accounted as lost.
+			if s.stats.PatternLost > 0 {
+				s.stats.PatternLost--
+			}
+			return
+		}
+		s.stats.PatternLost += int(counter - s.patternCounter - 1)
+	}
+	s.gotPattern = true
+	s.patternLayer = layer
+	s.patternCounter = counter
+}
+
+func (s *trackSink) write(pkt *rtp.Packet) error {
+	if s.writer == nil {
+		return nil
+	}
+	return s.writer.WriteRTP(pkt)
+}
+
+func (s *trackSink) getStats() TrackStats {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.stats
+}
+
+// close flushes the written track and reports the packets and pattern frames
+// that went missing.
+func (s *trackSink) close() error {
+	stats := s.getStats()
+	if stats.Lost > 0 || stats.PatternLost > 0 {
+		s.metrics.RecordError(MetricMediaGap, fmt.Errorf("track %s: %d packets and %d pattern frames lost",
+			stats.TrackID, stats.Lost, stats.PatternLost))
+	}
+
+	if s.writer != nil {
+		return s.writer.Close()
+	}
+
+	return nil
+}
+
+// trackFilename returns the name of the file a received track gets written
+// to, without extension.
+func trackFilename(username, trackID string, ssrc webrtc.SSRC) string {
+	return strings.NewReplacer("/", "_", "\\", "_").Replace(fmt.Sprintf("%s_%s_%d", username, trackID, ssrc))
+}
@@ -0,0 +1,256 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+)
+
+type testMetrics struct {
+	mut     sync.Mutex
+	timings map[string][]time.Duration
+	errors  map[string]int
+}
+
+func newTestMetrics() *testMetrics {
+	return &testMetrics{
+		timings: map[string][]time.Duration{},
+		errors:  map[string]int{},
+	}
+}
+
+func (m *testMetrics) RecordTiming(metric string, d time.Duration) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.timings[metric] = append(m.timings[metric], d)
+}
+
+func (m *testMetrics) RecordError(metric string, _ error) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+	m.errors[metric]++
+}
+
+// patternPackets returns the packets of n pattern frames, along with the
+// index of the first packet of each frame.
+func patternPackets(t *testing.T, kind webrtc.RTPCodecType, n int) ([]*rtp.Packet, []int) {
+	t.Helper()
+
+	var src PatternMediaSource
+	stream, _ := src.Audio()
+	if kind == webrtc.RTPCodecTypeVideo {
+		stream, _ = src.Video("video", "h", webrtc.MimeTypeVP8)
+	}
+
+	var packets []*rtp.Packet
+	var frames []int
+	for range n {
+		frames = append(frames, len(packets))
+		packets = append(packets, packetizeStream(t, stream, 1, kind)...)
+	}
+
+	// packetizeStream starts over the sequence and timestamps on each call.
+	for i, pkt := range packets {
+		pkt.SequenceNumber = uint16(65530 + i)
+	}
+	ts := uint32(0)
+	for i := range packets {
+		if slices.Contains(frames, i) {
+			ts += 3000
+		}
+		packets[i].Timestamp = ts
+	}
+
+	return packets, frames
+}
+
+func TestTrackSink(t *testing.T) {
+	newSink := func(t *testing.T, kind webrtc.RTPCodecType, cfg SinkConfig) (*trackSink, *testMetrics) {
+		t.Helper()
+		mimeType := webrtc.MimeTypeOpus
+		if kind == webrtc.RTPCodecTypeVideo {
+			mimeType = webrtc.MimeTypeVP8
+		}
+		metrics := newTestMetrics()
+		sink, err := newTrackSink(cfg, metrics, "trackID", mimeType, kind, "user_track")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return sink, metrics
+	}
+
+	// feed passes packets to the sink, 33ms apart per frame unless
+	// overridden by delays.
+	feed := func(t *testing.T, sink *trackSink, packets []*rtp.Packet, delays map[uint32]time.Duration) {
+		t.Helper()
+		now := time.Now()
+		var lastTS uint32
+		for _, pkt := range packets {
+			if pkt.Timestamp != lastTS {
+				lastTS = pkt.Timestamp
+				now = now.Add(33 * time.Millisecond)
+				now = now.Add(delays[pkt.Timestamp])
+			}
+			if err := sink.onPacket(pkt, now); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+	}
+
+	t.Run("continuous", func(t *testing.T) {
+		for _, kind := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeAudio, webrtc.RTPCodecTypeVideo} {
+			packets, _ := patternPackets(t, kind, 10)
+			sink, metrics := newSink(t, kind, SinkConfig{})
+			feed(t, sink, packets, nil)
+			if err := sink.close(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			stats := sink.getStats()
+			want := TrackStats{
+				TrackID:       "trackID",
+				Kind:          kind.String(),
+				MimeType:      sink.stats.MimeType,
+				Packets:       len(packets),
+				Frames:        10,
+				PatternFrames: 10,
+			}
+			if stats != want {
+				t.Errorf("got stats %+v, want %+v", stats, want)
+			}
+			if len(metrics.errors) != 0 || len(metrics.timings) != 0 {
+				t.Errorf("unexpected metrics: %v, %v", metrics.errors, metrics.timings)
+			}
+		}
+	})
+
+	t.Run("lost packets", func(t *testing.T) {
+		packets, frames := patternPackets(t, webrtc.RTPCodecTypeVideo, 10)
+		// Dropping a whole frame, and a packet of another one.
+		dropped := map[int]bool{frames[1] + 1: true}
+		for i := frames[4]; i < frames[5]; i++ {
+			dropped[i] = true
+		}
+		var kept []*rtp.Packet
+		for i, pkt := range packets {
+			if !dropped[i] {
+				kept = append(kept, pkt)
+			}
+		}
+
+		sink, metrics := newSink(t, webrtc.RTPCodecTypeVideo, SinkConfig{})
+		feed(t, sink, kept, nil)
+		if err := sink.close(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		stats := sink.getStats()
+		if stats.Lost != len(dropped) || stats.Gaps != 2 {
+			t.Errorf("got %d lost packets in %d gaps, want %d in 2", stats.Lost, stats.Gaps, len(dropped))
+		}
+		if stats.PatternFrames != 9 || stats.PatternLost != 1 {
+			t.Errorf("got %d pattern frames, %d lost, want 9 and 1", stats.PatternFrames, stats.PatternLost)
+		}
+		if metrics.errors[MetricMediaGap] != 1 {
+			t.Errorf("got %d gap errors, want 1", metrics.errors[MetricMediaGap])
+		}
+	})
+
+	t.Run("reordered packets", func(t *testing.T) {
+		packets, _ := patternPackets(t, webrtc.RTPCodecTypeAudio, 10)
+		packets[3], packets[4] = packets[4], packets[3]
+
+		sink, metrics := newSink(t, webrtc.RTPCodecTypeAudio, SinkConfig{})
+		feed(t, sink, packets, nil)
+		if err := sink.close(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		stats := sink.getStats()
+		if stats.Lost != 0 || stats.Gaps != 1 || stats.Late != 1 {
+			t.Errorf("got %d lost, %d gaps, %d late, want 0, 1 and 1", stats.Lost, stats.Gaps, stats.Late)
+		}
+		if stats.PatternLost != 0 {
+			t.Errorf("got %d pattern frames lost, want 0", stats.PatternLost)
+		}
+		if metrics.errors[MetricMediaGap] != 0 {
+			t.Errorf("got %d gap errors, want 0", metrics.errors[MetricMediaGap])
+		}
+	})
+
+	t.Run("freezes", func(t *testing.T) {
+		for _, kind := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeAudio, webrtc.RTPCodecTypeVideo} {
+			packets, _ := patternPackets(t, kind, 10)
+			sink, metrics := newSink(t, kind, SinkConfig{FreezeThreshold: 200 * time.Millisecond})
+			feed(t, sink, packets, map[uint32]time.Duration{
+				3000 * 3: 100 * time.Millisecond,
+				3000 * 6: time.Second,
+			})
+
+			stats := sink.getStats()
+			wantFreezes := 0
+			if kind == webrtc.RTPCodecTypeVideo {
+				wantFreezes = 1
+			}
+			if stats.Freezes != wantFreezes || len(metrics.timings[MetricFreeze]) != wantFreezes {
+				t.Errorf("%s: got %d freezes, want %d", kind, stats.Freezes, wantFreezes)
+			}
+			if wantFreezes > 0 && stats.LongestFreeze != time.Second+33*time.Millisecond {
+				t.Errorf("got longest freeze %s", stats.LongestFreeze)
+			}
+		}
+	})
+
+	t.Run("write", func(t *testing.T) {
+		dir := t.TempDir()
+		for _, kind := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeAudio, webrtc.RTPCodecTypeVideo} {
+			packets, _ := patternPackets(t, kind, 5)
+			sink, _ := newSink(t, kind, SinkConfig{Dir: dir})
+			feed(t, sink, packets, nil)
+			if err := sink.close(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+
+		// The written tracks can be sent again.
+		src := &DirMediaSource{Dir: dir}
+		audio, err := src.Audio()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer audio.Close()
+		video, err := src.Video("video", "h", webrtc.MimeTypeVP8)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		defer video.Close()
+
+		for i := range 5 {
+			for kind, stream := range map[webrtc.RTPCodecType]MediaStream{
+				webrtc.RTPCodecTypeAudio: audio,
+				webrtc.RTPCodecTypeVideo: video,
+			} {
+				sample, err := stream.Next()
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if _, counter, ok := parsePatternFrame(sample.Data, kind); !ok || counter != uint32(i) {
+					t.Errorf("%s: got counter %d (%t), want %d", kind, counter, ok, i)
+				}
+			}
+		}
+	})
+}
+
+func TestTrackFilename(t *testing.T) {
+	if got, want := trackFilename("user", "screen/abc", 42), "user_screen_abc_42"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
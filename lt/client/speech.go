@@ -0,0 +1,149 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Speech is the audio spoken by a user for a line of text. Either PCM or Ogg
+// is set.
+type Speech struct {
+	// Raw 16-bit little-endian interleaved stereo samples, encoded to Opus
+	// before being sent.
+	PCM        io.Reader
+	SampleRate int
+	// An Ogg/Opus stream, sent as is.
+	Ogg io.Reader
+	// Optional, called once the speech has been sent.
+	Close func() error
+}
+
+// SpeechSource provides the audio users speak from scripted lines.
+type SpeechSource interface {
+	Speech(text string) (*Speech, error)
+}
+
+// normalizeSpeechText returns the text with whitespace collapsed so that clips
+// can be matched regardless of formatting.
+func normalizeSpeechText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// OggDirSpeechSource serves pre-rendered Ogg/Opus clips from a directory.
+// Each clip (e.g. line_0.ogg) is keyed by the text found in the .txt file
+// with the same name (e.g. line_0.txt).
+type OggDirSpeechSource struct {
+	clips map[string]string
+}
+
+func NewOggDirSpeechSource(dir string) (*OggDirSpeechSource, error) {
+	txtFiles, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clips: %w", err)
+	}
+
+	s := &OggDirSpeechSource{
+		clips: make(map[string]string, len(txtFiles)),
+	}
+	for _, txtFile := range txtFiles {
+		oggFile := strings.TrimSuffix(txtFile, ".txt") + ".ogg"
+		if _, err := os.Stat(oggFile); err != nil {
+			continue
+		}
+
+		text, err := os.ReadFile(txtFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read clip text: %w", err)
+		}
+		s.clips[normalizeSpeechText(string(text))] = oggFile
+	}
+
+	if len(s.clips) == 0 {
+		return nil, fmt.Errorf("no clips found in %s", dir)
+	}
+
+	return s, nil
+}
+
+// Has returns whether a clip exists for the given text.
+func (s *OggDirSpeechSource) Has(text string) bool {
+	_, ok := s.clips[normalizeSpeechText(text)]
+	return ok
+}
+
+func (s *OggDirSpeechSource) Speech(text string) (*Speech, error) {
+	path, ok := s.clips[normalizeSpeechText(text)]
+	if !ok {
+		return nil, fmt.Errorf("no clip found for text %q", text)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open clip: %w", err)
+	}
+
+	return &Speech{
+		Ogg:   f,
+		Close: f.Close,
+	}, nil
+}
+
+const (
+	syntheticSpeechDefaultSampleRate = 24000
+	// Roughly 150 words per minute.
+	syntheticSpeechWordDuration = 400 * time.Millisecond
+)
+
+// SyntheticSpeechSource generates a tone, or white noise, lasting as long as
+// the text would take to be read out loud.
+type SyntheticSpeechSource struct {
+	// The frequency of the tone in Hz. White noise is generated if zero.
+	Frequency float64
+	// Defaults to 24000.
+	SampleRate int
+	// The seed used to generate noise, making it reproducible.
+	Seed int64
+}
+
+func (s *SyntheticSpeechSource) Speech(text string) (*Speech, error) {
+	rate := s.SampleRate
+	if rate == 0 {
+		rate = syntheticSpeechDefaultSampleRate
+	}
+
+	words := len(strings.Fields(text))
+	if words == 0 {
+		return nil, fmt.Errorf("nothing to speak")
+	}
+
+	numSamples := int(time.Duration(words) * syntheticSpeechWordDuration * time.Duration(rate) / time.Second)
+	data := make([]byte, numSamples*4)
+	rnd := rand.New(rand.NewSource(s.Seed))
+	for i := range numSamples {
+		var v float64
+		if s.Frequency > 0 {
+			v = math.Sin(2 * math.Pi * s.Frequency * float64(i) / float64(rate))
+		} else {
+			v = rnd.Float64()*2 - 1
+		}
+		sample := uint16(int16(v * math.MaxInt16 / 2))
+		binary.LittleEndian.PutUint16(data[i*4:], sample)
+		binary.LittleEndian.PutUint16(data[i*4+2:], sample)
+	}
+
+	return &Speech{
+		PCM:        bytes.NewReader(data),
+		SampleRate: rate,
+	}, nil
+}
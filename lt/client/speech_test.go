@@ -0,0 +1,117 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package client
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOggDirSpeechSource(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile := func(name, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		if _, err := NewOggDirSpeechSource(dir); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+
+	writeFile("line_000.txt", "Hi there.\n")
+	writeFile("line_000.ogg", "clip0")
+	writeFile("line_001.txt", "How are   you\ndoing?")
+	writeFile("line_001.ogg", "clip1")
+	// Missing clip.
+	writeFile("line_002.txt", "Bye.")
+
+	s, err := NewOggDirSpeechSource(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for text, want := range map[string]string{
+		"Hi there.":              "clip0",
+		"How are you doing?":     "clip1",
+		"  How are you doing?  ": "clip1",
+	} {
+		if !s.Has(text) {
+			t.Errorf("missing clip for %q", text)
+		}
+
+		speech, err := s.Speech(text)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		data, err := io.ReadAll(speech.Ogg)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if err := speech.Close(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(data) != want {
+			t.Errorf("got %q, want %q", data, want)
+		}
+	}
+
+	if s.Has("Bye.") {
+		t.Errorf("unexpected clip without ogg file")
+	}
+	if _, err := s.Speech("Bye."); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestSyntheticSpeechSource(t *testing.T) {
+	read := func(t *testing.T, s *SyntheticSpeechSource, text string) []byte {
+		t.Helper()
+		speech, err := s.Speech(text)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if speech.SampleRate != syntheticSpeechDefaultSampleRate {
+			t.Fatalf("got rate %d, want %d", speech.SampleRate, syntheticSpeechDefaultSampleRate)
+		}
+		data, err := io.ReadAll(speech.PCM)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return data
+	}
+
+	t.Run("duration", func(t *testing.T) {
+		// 5 words, 400ms each, 4 bytes per sample.
+		data := read(t, &SyntheticSpeechSource{Frequency: 440}, "one two three four five")
+		if want := 2 * syntheticSpeechDefaultSampleRate * 4; len(data) != want {
+			t.Errorf("got %d bytes, want %d", len(data), want)
+		}
+	})
+
+	t.Run("reproducible noise", func(t *testing.T) {
+		a := read(t, &SyntheticSpeechSource{Seed: 1}, "some noise")
+		b := read(t, &SyntheticSpeechSource{Seed: 1}, "some noise")
+		c := read(t, &SyntheticSpeechSource{Seed: 2}, "some noise")
+		if !bytes.Equal(a, b) {
+			t.Errorf("expected same samples for the same seed")
+		}
+		if bytes.Equal(a, c) {
+			t.Errorf("expected different samples for different seeds")
+		}
+	})
+
+	t.Run("empty text", func(t *testing.T) {
+		if _, err := (&SyntheticSpeechSource{}).Speech(" "); err == nil {
+			t.Errorf("expected error")
+		}
+	})
+}
@@ -5,31 +5,48 @@ package client
 
 import (
 	"fmt"
-	"io"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/polly"
 	mp3 "github.com/hajimehoshi/go-mp3"
 )
 
-func (u *User) pollyToSpeech(text string) (io.Reader, int, error) {
+// PollySpeechSource synthesizes speech through AWS Polly.
+type PollySpeechSource struct {
+	session *polly.Polly
+	voiceID *string
+}
+
+func NewPollySpeechSource(session *polly.Polly, voiceID *string) *PollySpeechSource {
+	return &PollySpeechSource{
+		session: session,
+		voiceID: voiceID,
+	}
+}
+
+func (s *PollySpeechSource) Speech(text string) (*Speech, error) {
 	input := &polly.SynthesizeSpeechInput{
 		Engine:       aws.String("neural"),
 		LanguageCode: aws.String(polly.LanguageCodeEnUs),
 		OutputFormat: aws.String("mp3"),
 		SampleRate:   aws.String("24000"),
-		VoiceId:      u.pollyVoiceID,
+		VoiceId:      s.voiceID,
 		Text:         aws.String(text),
 	}
-	output, err := u.pollySession.SynthesizeSpeech(input)
+	output, err := s.session.SynthesizeSpeech(input)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to synthesize speech with polly, err: %v", err)
+		return nil, fmt.Errorf("failed to synthesize speech with polly, err: %v", err)
 	}
 
 	dec, err := mp3.NewDecoder(output.AudioStream)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to create decoder: %w", err)
+		output.AudioStream.Close()
+		return nil, fmt.Errorf("failed to create decoder: %w", err)
 	}
 
-	return dec, dec.SampleRate(), nil
+	return &Speech{
+		PCM:        dec,
+		SampleRate: dec.SampleRate(),
+		Close:      output.AudioStream.Close,
+	}, nil
 }
@@ -0,0 +1,110 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/lt/capacity"
+)
+
+// runCapacity implements the capacity subcommand, fitting a resource model
+// from load-test reports or live metrics and printing the deployment needed
+// for a target load.
+func runCapacity(args []string) {
+	fs := flag.NewFlagSet("capacity", flag.ExitOnError)
+
+	var reports string
+	var metricsURLs string
+	var sampleDuration time.Duration
+	var sampleInterval time.Duration
+	var target capacity.Target
+	var node capacity.Node
+	var output string
+
+	fs.StringVar(&reports, "report", "", "Comma separated list of load-test JSON reports (or JSON arrays of samples) to fit the model from")
+	fs.StringVar(&metricsURLs, "metrics-url", "", "Comma separated list of the Prometheus metrics endpoints of the RTC nodes to sample live, instead of or on top of reports")
+	fs.DurationVar(&sampleDuration, "sample-duration", 5*time.Minute, "For how long to sample the RTC nodes live")
+	fs.DurationVar(&sampleInterval, "sample-interval", 15*time.Second, "The interval at which to sample the RTC nodes live")
+	fs.Float64Var(&target.Sessions, "target-sessions", 0, "The number of concurrent sessions to plan for")
+	fs.Float64Var(&target.Speakers, "target-speakers", 0, "The number of concurrent unmuted speakers to plan for")
+	fs.Float64Var(&target.ScreenShares, "target-screen-shares", 0, "The number of concurrent screen shares to plan for")
+	fs.Float64Var(&target.Recordings, "target-recordings", 0, "The number of concurrent recordings to plan for")
+	fs.Float64Var(&node.CPU, "node-cpu", 4, "The number of cores of a node")
+	fs.Float64Var(&node.MemoryMB, "node-memory", 8192, "The memory of a node, in MB")
+	fs.Float64Var(&node.Headroom, "headroom", 0.7, "The share of a node's resources usable before it's considered full, in the range (0, 1]")
+	fs.StringVar(&output, "output", "", "The path to write the model and plan to, as JSON")
+
+	if err := fs.Parse(args); err != nil {
+		log.Fatal(err)
+	}
+
+	if reports == "" && metricsURLs == "" {
+		log.Fatalf("report or metrics-url must be set")
+	}
+
+	var samples []capacity.Sample
+	if reports != "" {
+		for path := range strings.SplitSeq(reports, ",") {
+			f, err := os.Open(path)
+			if err != nil {
+				log.Fatalf("failed to open report: %s", err.Error())
+			}
+			s, err := capacity.LoadSamples(f)
+			f.Close()
+			if err != nil {
+				log.Fatalf("failed to load samples from %s: %s", path, err.Error())
+			}
+			samples = append(samples, s...)
+		}
+	}
+
+	if metricsURLs != "" {
+		sampler := capacity.NewSampler(strings.Split(metricsURLs, ","), sampleInterval, slog.Default())
+		stopCh := make(chan struct{})
+		go func() {
+			select {
+			case <-time.After(sampleDuration):
+			case <-stopOnSignal():
+			}
+			close(stopCh)
+		}()
+		slog.Info("sampling RTC nodes", slog.String("duration", sampleDuration.String()))
+		sampler.Run(stopCh)
+		samples = append(samples, sampler.Samples()...)
+	}
+
+	model, err := capacity.Fit(samples)
+	if err != nil {
+		log.Fatalf("failed to fit model: %s", err.Error())
+	}
+
+	plan, err := model.Plan(target, node)
+	if err != nil {
+		log.Fatalf("failed to plan: %s", err.Error())
+	}
+
+	if err := plan.WriteMarkdown(os.Stdout, model); err != nil {
+		log.Fatalf("failed to print plan: %s", err.Error())
+	}
+
+	if output != "" {
+		data, err := json.MarshalIndent(struct {
+			Model *capacity.Model `json:"model"`
+			Plan  *capacity.Plan  `json:"plan"`
+		}{model, plan}, "", "  ")
+		if err != nil {
+			log.Fatalf("failed to encode plan: %s", err.Error())
+		}
+		if err := os.WriteFile(output, data, 0644); err != nil {
+			log.Fatalf("failed to write plan: %s", err.Error())
+		}
+	}
+}
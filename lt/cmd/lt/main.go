@@ -8,17 +8,24 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"log/slog"
 	"math/rand"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/mattermost/mattermost-plugin-calls/lt/capacity"
 	"github.com/mattermost/mattermost-plugin-calls/lt/client"
+	"github.com/mattermost/mattermost-plugin-calls/lt/distributed"
+	"github.com/mattermost/mattermost-plugin-calls/lt/report"
+	"github.com/mattermost/mattermost-plugin-calls/lt/scenario"
 
 	"github.com/mattermost/mattermost/server/public/model"
 
@@ -42,6 +49,98 @@ func slogReplaceAttr(_ []string, a slog.Attr) slog.Attr {
 	return a
 }
 
+// writeReport prints the results report, optionally writing it to dir, and
+// exits with a non-zero status if any of the thresholds is not met.
+func writeReport(collector *report.Collector, resources []capacity.Sample, dir string, thresholds []report.Threshold) {
+	r := collector.Report()
+	r.Resources = resources
+	r.Check(thresholds)
+
+	if err := r.WriteMarkdown(os.Stdout); err != nil {
+		log.Fatalf("failed to print report: %s", err.Error())
+	}
+
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Fatalf("failed to create report directory: %s", err.Error())
+		}
+		for name, write := range map[string]func(io.Writer) error{
+			"report.json": r.WriteJSON,
+			"report.md":   r.WriteMarkdown,
+		} {
+			f, err := os.Create(filepath.Join(dir, name))
+			if err != nil {
+				log.Fatalf("failed to create report file: %s", err.Error())
+			}
+			if err := write(f); err != nil {
+				log.Fatalf("failed to write report file: %s", err.Error())
+			}
+			if err := f.Close(); err != nil {
+				log.Fatalf("failed to close report file: %s", err.Error())
+			}
+		}
+	}
+
+	if r.Failed() {
+		log.Fatalf("thresholds not met")
+	}
+}
+
+// stopOnSignal returns a channel closed on the first interrupt or termination
+// signal.
+func stopOnSignal() chan struct{} {
+	stopCh := make(chan struct{})
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+		close(stopCh)
+	}()
+	return stopCh
+}
+
+// startSampler starts sampling the RTC nodes at the given comma separated
+// metrics URLs, if any. The returned function stops it and returns the
+// samples taken.
+func startSampler(urls string, interval time.Duration, recordings int, logger *slog.Logger) func() []capacity.Sample {
+	if urls == "" {
+		return func() []capacity.Sample { return nil }
+	}
+
+	sampler := capacity.NewSampler(strings.Split(urls, ","), interval, logger)
+	// Recordings are assumed to last for the whole test.
+	sampler.SetRecordings(recordings)
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		sampler.Run(stopCh)
+		close(doneCh)
+	}()
+
+	return func() []capacity.Sample {
+		close(stopCh)
+		<-doneCh
+		return sampler.Samples()
+	}
+}
+
+func newMediaSource(kind, dir, speechFile string) (client.MediaSource, error) {
+	switch kind {
+	case "files":
+		return &client.FileMediaSource{AudioFile: speechFile, VideoDir: "./samples"}, nil
+	case "dir":
+		if dir == "" {
+			return nil, fmt.Errorf("media-dir should be set")
+		}
+		return &client.DirMediaSource{Dir: dir}, nil
+	case "pattern":
+		return &client.PatternMediaSource{}, nil
+	default:
+		return nil, fmt.Errorf("unknown media source %q, should be one of files, dir or pattern", kind)
+	}
+}
+
 func main() {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		AddSource:   true,
@@ -50,6 +149,11 @@ func main() {
 	}))
 	slog.SetDefault(logger)
 
+	if len(os.Args) > 1 && os.Args[1] == "capacity" {
+		runCapacity(os.Args[2:])
+		return
+	}
+
 	// TODO: consider using a config file instead.
 	var teamID string
 	var channelID string
@@ -69,6 +173,21 @@ func main() {
 	var setup bool
 	var speechFile string
 	var numVideo int
+	var scenarioFile string
+	var reportDir string
+	var thresholdsStr string
+	var coordinatorAddr string
+	var numAgents int
+	var coordinatorURL string
+	var agentName string
+	var networkProfileStr string
+	var numNetworkUsers int
+	var mediaSourceStr string
+	var mediaDir string
+	var checkMedia bool
+	var sinkDir string
+	var metricsURLs string
+	var metricsInterval time.Duration
 
 	flag.StringVar(&teamID, "team", "", "The team ID to start calls in")
 	flag.StringVar(&channelID, "channel", "", "The channel ID to start the call in")
@@ -88,9 +207,87 @@ func main() {
 	flag.BoolVar(&setup, "setup", true, "Whether or not setup actions like creating users, channels, teams and/or members should be executed.")
 	flag.StringVar(&speechFile, "speech-file", "./samples/speech_0.ogg", "The path to a speech OGG file to read to simulate real voice samples")
 	flag.IntVar(&numVideo, "video", 0, "The number of users with video on per call")
+	flag.StringVar(&networkProfileStr, "network-profile", "", "The network conditions to simulate, either a built-in profile (wifi, bad_wifi, 3g, lossy) or a list of settings (e.g. loss=5%,delay=100ms,jitter=30ms,bandwidth=500k)")
+	flag.IntVar(&numNetworkUsers, "network-users", 0, "The number of users per call the network profile applies to, all of them if 0")
+	flag.StringVar(&mediaSourceStr, "media-source", "files", "The media sent by users: files (the speech-file and ./samples videos), dir (all the OGG and IVF files of media-dir, looped over) or pattern (generated test patterns carrying frame counters, VP8 only)")
+	flag.StringVar(&mediaDir, "media-dir", "", "The directory of OGG and IVF files sent by users when media-source is dir")
+	flag.BoolVar(&checkMedia, "check-media", false, "Whether or not to check received tracks for continuity, reporting lost packets and pattern frames as media_gap errors and frozen video as freeze timings")
+	flag.StringVar(&sinkDir, "sink-dir", "", "The directory to write the tracks received by users to, implies check-media")
+	flag.StringVar(&scenarioFile, "scenario", "", "The path to a YAML or JSON scenario file. When set, it replaces the calls, users-per-call, unmuted, screen-sharing, video, recordings, duration, join-duration, network-profile and network-users flags")
+
+	flag.StringVar(&reportDir, "report-dir", "", "The directory to write the JSON and Markdown results reports to")
+	flag.StringVar(&metricsURLs, "metrics-url", "", "Comma separated list of the Prometheus metrics endpoints of the RTC nodes (e.g. http://rtcd:8045/metrics) to sample during the test, for capacity planning")
+	flag.DurationVar(&metricsInterval, "metrics-interval", 15*time.Second, "The interval at which to sample the RTC nodes")
+	flag.StringVar(&thresholdsStr, "thresholds", "", "Comma separated list of limits making the test fail when exceeded (e.g. join.p95=2s,first_track.p99=5s,errors=0)")
+
+	flag.StringVar(&coordinatorAddr, "coordinator-addr", "", "Run as a coordinator listening on this address (e.g. :4545), splitting the scenario across agents")
+	flag.IntVar(&numAgents, "agents", 1, "The number of agents the coordinator waits for before starting")
+	flag.StringVar(&coordinatorURL, "coordinator-url", "", "Run as an agent of the coordinator at this URL (e.g. http://localhost:4545)")
+	flag.StringVar(&agentName, "agent-name", "", "The name of the agent, defaults to the hostname")
 
 	flag.Parse()
 
+	mediaSource, err := newMediaSource(mediaSourceStr, mediaDir, speechFile)
+	if err != nil {
+		log.Fatalf("invalid media source: %s", err.Error())
+	}
+
+	var sink *client.SinkConfig
+	if checkMedia || sinkDir != "" {
+		if sinkDir != "" {
+			if err := os.MkdirAll(sinkDir, 0700); err != nil {
+				log.Fatalf("failed to create sink directory: %s", err.Error())
+			}
+		}
+		sink = &client.SinkConfig{Dir: sinkDir}
+	}
+
+	if coordinatorURL != "" {
+		// Everything else comes from the coordinator.
+		if agentName == "" {
+			agentName, _ = os.Hostname()
+		}
+		agent, err := distributed.NewAgent(distributed.AgentConfig{
+			CoordinatorURL: coordinatorURL,
+			Name:           agentName,
+			Runner: scenario.Config{
+				SiteURL:      siteURL,
+				UserPrefix:   userPrefix,
+				UserPassword: userPassword,
+				SpeechFile:   speechFile,
+				MediaSource:  mediaSource,
+				Sink:         sink,
+				Logger:       logger,
+			},
+		})
+		if err != nil {
+			log.Fatalf("failed to create agent: %s", err.Error())
+		}
+		if err := agent.Run(stopOnSignal()); err != nil {
+			log.Fatalf("agent failed: %s", err.Error())
+		}
+		fmt.Println("DONE")
+		return
+	}
+
+	thresholds, err := report.ParseThresholds(thresholdsStr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var sc *scenario.Scenario
+	if scenarioFile != "" {
+		sc, err = scenario.Load(scenarioFile)
+		if err != nil {
+			log.Fatalf("failed to load scenario: %s", err.Error())
+		}
+		numCalls = sc.Calls
+	}
+
+	if coordinatorAddr != "" && sc == nil {
+		log.Fatalf("a scenario is required when running as a coordinator")
+	}
+
 	if numCalls == 0 {
 		log.Fatalf("calls should be > 0")
 	}
@@ -141,6 +338,23 @@ func main() {
 		log.Fatalf("recordings cannot be greater than the number of calls")
 	}
 
+	var networkProfile *client.NetworkProfile
+	if networkProfileStr != "" {
+		p, err := client.ParseNetworkProfile(networkProfileStr)
+		if err != nil {
+			log.Fatalf("invalid network profile: %s", err.Error())
+		}
+		networkProfile = &p
+	}
+
+	if numNetworkUsers < 0 || numNetworkUsers > numUsersPerCall {
+		log.Fatalf("network-users should be in the range [0, %d]", numUsersPerCall)
+	}
+
+	if numNetworkUsers == 0 {
+		numNetworkUsers = numUsersPerCall
+	}
+
 	var channels []*model.Channel
 	if setup {
 		adminClient := model.NewAPIv4Client(siteURL)
@@ -210,13 +424,82 @@ func main() {
 		}
 	}
 
-	stopCh := make(chan struct{})
+	collector := report.NewCollector()
+
+	stopCh := stopOnSignal()
+
+	if coordinatorAddr != "" {
+		coordinator, err := distributed.NewCoordinator(distributed.CoordinatorConfig{
+			Agents:     numAgents,
+			Scenario:   sc,
+			Channels:   channels,
+			UserOffset: offset,
+			Setup:      setup,
+			Logger:     logger,
+		})
+		if err != nil {
+			log.Fatalf("failed to create coordinator: %s", err.Error())
+		}
+
+		srv := &http.Server{
+			Addr:    coordinatorAddr,
+			Handler: coordinator,
+		}
+		go func() {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("failed to serve: %s", err.Error())
+			}
+		}()
+
+		logger.Info("waiting for agents", slog.String("addr", coordinatorAddr), slog.Int("agents", numAgents))
+		stopSampler := startSampler(metricsURLs, metricsInterval, sc.Recordings, logger)
+		collector := coordinator.Wait(stopCh)
+		resources := stopSampler()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Error("failed to shutdown server", slog.String("err", err.Error()))
+		}
+
+		writeReport(collector, resources, reportDir, thresholds)
+		fmt.Println("DONE")
+		return
+	}
+
+	if sc != nil {
+		runner, err := scenario.NewRunner(sc, scenario.Config{
+			SiteURL:      siteURL,
+			UserPrefix:   userPrefix,
+			UserPassword: userPassword,
+			UserOffset:   offset,
+			Setup:        setup,
+			SpeechFile:   speechFile,
+			MediaSource:  mediaSource,
+			Sink:         sink,
+			Channels:     channels,
+			Logger:       logger,
+			Metrics:      collector,
+		})
+		if err != nil {
+			log.Fatalf("failed to create scenario runner: %s", err.Error())
+		}
+		logger.Info("running scenario", slog.String("name", sc.Name), slog.String("duration", sc.Duration().String()))
+		stopSampler := startSampler(metricsURLs, metricsInterval, sc.Recordings, logger)
+		runner.Run(stopCh)
+		writeReport(collector, stopSampler(), reportDir, thresholds)
+		fmt.Println("DONE")
+		return
+	}
+
+	stopSampler := startSampler(metricsURLs, metricsInterval, numRecordings, logger)
+
 	var wg sync.WaitGroup
 	wg.Add(numUsersPerCall * numCalls)
 	for j := 0; j < numCalls; j++ {
 		logger.Debug("starting call in " + channels[j].DisplayName)
 		for i := 0; i < numUsersPerCall; i++ {
-			go func(idx int, channelID string, teamID string, unmuted, screenSharing, recording, video bool) {
+			go func(idx int, channelID string, teamID string, unmuted, screenSharing, recording, video, impaired bool) {
 				username := fmt.Sprintf("%s%d", userPrefix, idx)
 				userLogger := logger.With("username", username)
 				if unmuted {
@@ -248,10 +531,15 @@ func main() {
 					Recording:     recording,
 					Setup:         setup,
 					SpeechFile:    speechFile,
+					MediaSource:   mediaSource,
+					Sink:          sink,
+				}
+				if impaired {
+					cfg.Network = networkProfile
 				}
 
 				for {
-					user := client.NewUser(cfg, client.WithLogger(userLogger))
+					user := client.NewUser(cfg, client.WithLogger(userLogger), client.WithMetrics(collector))
 					if err := user.Connect(stopCh); err != nil {
 						userLogger.Error("connectUser failed", slog.String("err", err.Error()))
 						var invalidStateErr *rtcerr.InvalidModificationError
@@ -262,18 +550,13 @@ func main() {
 					}
 					return
 				}
-			}((numUsersPerCall*j)+i+offset, channels[j].Id, channels[j].TeamId, i < numUnmuted, i == 0 && j < numScreenSharing, j < numRecordings, i < numVideo)
+			}((numUsersPerCall*j)+i+offset, channels[j].Id, channels[j].TeamId, i < numUnmuted, i == 0 && j < numScreenSharing, j < numRecordings, i < numVideo, i < numNetworkUsers)
 		}
 	}
 
-	go func() {
-		sig := make(chan os.Signal, 1)
-		signal.Notify(sig, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-		<-sig
-		close(stopCh)
-	}()
-
 	wg.Wait()
 
+	writeReport(collector, stopSampler(), reportDir, thresholds)
+
 	fmt.Println("DONE")
 }
@@ -4,7 +4,9 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -22,8 +24,17 @@ import (
 	"github.com/mattermost/mattermost-plugin-calls/lt/client"
 )
 
-var script, siteURL, wsURL, channelID, teamID, userPassword, profile string
-var setup bool
+const (
+	speechSourcePolly = "polly"
+	speechSourceClips = "clips"
+	speechSourceTone  = "tone"
+	speechSourceNoise = "noise"
+)
+
+var script, siteURL, wsURL, channelID, teamID, userPassword, profile, speechSource, clipsDir, renderDir string
+var scoreReport string
+var setup, record, score bool
+var transcriptionTimeout time.Duration
 
 func main() {
 	flag.StringVar(&script, "script", "script.txt", "Script for the tts, to be found in lt/scripts; assumes you will be running the lt program from the repo/lt directory")
@@ -34,16 +45,30 @@ func main() {
 	flag.BoolVar(&setup, "setup", false, "setup users (needs teamID and valid sysadmin login)")
 	flag.StringVar(&userPassword, "userPassword", "testPass123$", "password for users (default testPass123$)")
 	flag.StringVar(&profile, "profile", "default", "named aws profile, located in .aws/config, see https://aws.github.io/aws-sdk-go-v2/docs/configuring-sdk/")
+	flag.StringVar(&speechSource, "speechSource", speechSourcePolly, "source of the spoken audio: polly, clips (pre-rendered, see -clipsDir), tone or noise")
+	flag.StringVar(&clipsDir, "clipsDir", "", "directory of pre-rendered Ogg/Opus clips, each with a .txt file holding its script line")
+	flag.StringVar(&renderDir, "renderDir", "", "render the script lines as clips into this directory, to be used with -speechSource clips, then exit")
+	flag.BoolVar(&record, "record", false, "start recording the call, along with its transcription and live captions if enabled on the server")
+	flag.BoolVar(&score, "score", false, "score the live captions, and the transcription if recording, against the script")
+	flag.StringVar(&scoreReport, "scoreReport", "", "write the scores as JSON to this file")
+	flag.DurationVar(&transcriptionTimeout, "transcriptionTimeout", 5*time.Minute, "how long to wait for the transcription to be posted once the call ends")
 	flag.Parse()
 
-	if channelID == "" {
-		log.Fatalf("need a -channelID flag")
-	}
-
 	if script == "" {
 		log.Fatalf("need a -script flag")
 	}
 
+	if renderDir != "" {
+		if err := renderScript(script); err != nil {
+			log.Fatalf("error rendering script: %v", err)
+		}
+		return
+	}
+
+	if channelID == "" {
+		log.Fatalf("need a -channelID flag")
+	}
+
 	if setup && teamID == "" {
 		log.Fatalf("need a -teamID flag")
 	}
@@ -53,23 +78,91 @@ func main() {
 	}
 }
 
-func performScript(filename string) error {
-	awsSess := session.Must(session.NewSessionWithOptions(session.Options{
-		Profile:           profile,
-		SharedConfigState: session.SharedConfigEnable,
-	}))
-	svc := polly.New(awsSess)
-
+func loadScript(filename string) Script {
 	f, err := os.Open(filepath.Join("scripts", filename))
 	if err != nil {
 		log.Fatalf("open script %s failed: %v", filename, err)
 	}
+	defer f.Close()
 
 	script, err := importScript(f)
 	if err != nil {
 		log.Fatalf("parsing script %s failed: %v", filename, err)
 	}
 
+	return script
+}
+
+// newSpeechSources returns the speech source for each of the script users.
+func newSpeechSources(script Script, source string) ([]client.SpeechSource, error) {
+	sources := make([]client.SpeechSource, len(script.users))
+	switch source {
+	case speechSourcePolly:
+		awsSess := session.Must(session.NewSessionWithOptions(session.Options{
+			Profile:           profile,
+			SharedConfigState: session.SharedConfigEnable,
+		}))
+		svc := polly.New(awsSess)
+		for i := range sources {
+			sources[i] = client.NewPollySpeechSource(svc, aws.String(script.voiceIDs[i]))
+		}
+	case speechSourceClips:
+		if clipsDir == "" {
+			return nil, fmt.Errorf("need a -clipsDir flag")
+		}
+		clips, err := client.NewOggDirSpeechSource(clipsDir)
+		if err != nil {
+			return nil, err
+		}
+		// Fail early rather than halfway through the conversation.
+		for _, block := range script.blocks {
+			for _, text := range block.text {
+				if !clips.Has(text) {
+					return nil, fmt.Errorf("no clip found for line %q", text)
+				}
+			}
+		}
+		for i := range sources {
+			sources[i] = clips
+		}
+	case speechSourceTone, speechSourceNoise:
+		for i := range sources {
+			s := &client.SyntheticSpeechSource{Seed: int64(i)}
+			if source == speechSourceTone {
+				// A different pitch for each speaker.
+				s.Frequency = 220 * float64(i+1)
+			}
+			sources[i] = s
+		}
+	default:
+		return nil, fmt.Errorf("invalid speech source %q", source)
+	}
+
+	return sources, nil
+}
+
+func performScript(filename string) error {
+	script := loadScript(filename)
+
+	sources, err := newSpeechSources(script, speechSource)
+	if err != nil {
+		return fmt.Errorf("failed to create speech sources: %w", err)
+	}
+
+	var results *resultsClient
+	var captions *captionsListener
+	if score {
+		results, err = newResultsClient(script)
+		if err != nil {
+			return fmt.Errorf("failed to create results client: %w", err)
+		}
+		captions, err = results.listenCaptions()
+		if err != nil {
+			return fmt.Errorf("failed to listen for captions: %w", err)
+		}
+	}
+	startAt := time.Now()
+
 	stopCh := make(chan struct{})
 	var userWg sync.WaitGroup
 
@@ -85,10 +178,11 @@ func performScript(filename string) error {
 			Speak:        true,
 			Setup:        setup,
 			TeamID:       teamID,
-			PollySession: svc,
-			PollyVoiceID: aws.String(script.voiceIDs[i]),
+			SpeechSource: sources[i],
+			// Only the host of the call starts the recording.
+			Recording: record,
 		}, client.WithOpusEncoderFactory(func() (client.OpusEncoder, error) {
-			return opus.NewEncoder(24000, 1, opus.AppVoIP)
+			return opus.NewEncoder(speechSampleRate, 1, opus.AppVoIP)
 		}))
 		userClients = append(userClients, user)
 
@@ -133,5 +227,41 @@ func performScript(filename string) error {
 
 	userWg.Wait()
 
+	if !score {
+		return nil
+	}
+
+	scores := []Score{scoreUtterances(script, "Live captions", captions.Close())}
+	if record {
+		log.Printf("waiting for transcription")
+		utterances, err := results.waitForTranscription(startAt, transcriptionTimeout)
+		if err != nil {
+			return fmt.Errorf("failed to get transcription: %w", err)
+		}
+		scores = append(scores, scoreUtterances(script, "Transcription", utterances))
+	}
+
+	return writeScores(scores)
+}
+
+func writeScores(scores []Score) error {
+	for _, s := range scores {
+		if err := s.WriteMarkdown(os.Stdout); err != nil {
+			return fmt.Errorf("failed to write scores: %w", err)
+		}
+	}
+
+	if scoreReport == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(scores, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal scores: %w", err)
+	}
+	if err := os.WriteFile(scoreReport, data, 0644); err != nil {
+		return fmt.Errorf("failed to write score report: %w", err)
+	}
+
 	return nil
 }
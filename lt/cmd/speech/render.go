@@ -0,0 +1,129 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4/pkg/media/oggwriter"
+
+	"gopkg.in/hraban/opus.v2"
+
+	"github.com/mattermost/mattermost-plugin-calls/lt/client"
+)
+
+// The sample rate speech is encoded at.
+const speechSampleRate = 24000
+
+// renderScript writes every line of the script as an Ogg/Opus clip, along
+// with a .txt file holding the line, so that the script can later be
+// performed offline through a client.OggDirSpeechSource.
+func renderScript(filename string) error {
+	if speechSource == speechSourceClips {
+		return fmt.Errorf("cannot render clips from clips")
+	}
+
+	script := loadScript(filename)
+
+	sources, err := newSpeechSources(script, speechSource)
+	if err != nil {
+		return fmt.Errorf("failed to create speech sources: %w", err)
+	}
+
+	if err := os.MkdirAll(renderDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	rendered := map[string]bool{}
+	for _, block := range script.blocks {
+		for i, userIdx := range block.speakers {
+			text := block.text[i]
+			if rendered[text] {
+				continue
+			}
+
+			name := filepath.Join(renderDir, fmt.Sprintf("line_%03d", len(rendered)))
+			if err := renderClip(sources[userIdx], text, name+".ogg"); err != nil {
+				return fmt.Errorf("failed to render line %q: %w", text, err)
+			}
+			if err := os.WriteFile(name+".txt", []byte(text), 0644); err != nil {
+				return fmt.Errorf("failed to write clip text: %w", err)
+			}
+			rendered[text] = true
+
+			log.Printf("rendered %s.ogg", name)
+		}
+	}
+
+	return nil
+}
+
+func renderClip(source client.SpeechSource, text, filename string) error {
+	speech, err := source.Speech(text)
+	if err != nil {
+		return err
+	}
+	if speech.Close != nil {
+		defer speech.Close()
+	}
+
+	if speech.PCM == nil {
+		return fmt.Errorf("raw samples are required")
+	}
+	if speech.SampleRate != speechSampleRate {
+		return fmt.Errorf("unexpected sample rate %d", speech.SampleRate)
+	}
+
+	enc, err := opus.NewEncoder(speechSampleRate, 1, opus.AppVoIP)
+	if err != nil {
+		return fmt.Errorf("failed to create opus encoder: %w", err)
+	}
+
+	w, err := oggwriter.New(filename, speechSampleRate, 1)
+	if err != nil {
+		return fmt.Errorf("failed to create ogg writer: %w", err)
+	}
+	defer w.Close()
+
+	// 20ms frames of stereo 16-bit samples, of which only the left channel is kept.
+	frameSize := speechSampleRate / 50
+	data := make([]byte, frameSize*4)
+	samples := make([]int16, frameSize)
+	opusData := make([]byte, 8192)
+	pkt := &rtp.Packet{}
+	for {
+		n, err := io.ReadFull(speech.PCM, data)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("failed to read samples: %w", err)
+		}
+
+		for i := 0; i < n; i += 4 {
+			samples[i/4] = int16(binary.LittleEndian.Uint16(data[i : i+2]))
+		}
+		clear(samples[n/4:])
+
+		n, err = enc.Encode(samples, opusData)
+		if err != nil {
+			return fmt.Errorf("failed to encode: %w", err)
+		}
+
+		pkt.Payload = opusData[:n]
+		if err := w.WriteRTP(pkt); err != nil {
+			return fmt.Errorf("failed to write clip: %w", err)
+		}
+		// Opus granule positions are always based on a 48KHz clock.
+		pkt.Timestamp += 960
+	}
+
+	return w.Close()
+}
@@ -0,0 +1,244 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-calls/lt/client"
+)
+
+const (
+	wsEventCaption        = "custom_com.mattermost.calls_caption"
+	transcriptionPostType = "custom_calls_transcription"
+)
+
+// resultsClient is used to fetch the captions and transcriptions produced
+// for the call, as one of the script users.
+type resultsClient struct {
+	apiClient *model.Client4
+	// Script user names, keyed by user ID.
+	names map[string]string
+}
+
+func newResultsClient(script Script) (*resultsClient, error) {
+	apiClient := model.NewAPIv4Client(siteURL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), client.HTTPRequestTimeout)
+	defer cancel()
+	if _, _, err := apiClient.Login(ctx, script.users[0], userPassword); err != nil {
+		return nil, fmt.Errorf("failed to login: %w", err)
+	}
+
+	users, _, err := apiClient.GetUsersByUsernames(ctx, script.users)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	c := &resultsClient{
+		apiClient: apiClient,
+		names:     make(map[string]string, len(users)),
+	}
+	for _, u := range users {
+		// Usernames are stored lowercased.
+		for _, name := range script.users {
+			if strings.EqualFold(u.Username, name) {
+				c.names[u.Id] = name
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// captionsListener collects the live captions sent to a call participant.
+type captionsListener struct {
+	ws     *model.WebSocketClient
+	doneCh chan struct{}
+
+	mut        sync.Mutex
+	utterances []Utterance
+}
+
+func (c *resultsClient) listenCaptions() (*captionsListener, error) {
+	ws, err := model.NewWebSocketClient4(wsURL, c.apiClient.AuthToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect websocket: %w", err)
+	}
+	ws.Listen()
+
+	l := &captionsListener{
+		ws:     ws,
+		doneCh: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(l.doneCh)
+		for ev := range ws.EventChannel {
+			if ev.EventType() != wsEventCaption {
+				continue
+			}
+			data := ev.GetData()
+			if data["channel_id"] != channelID {
+				continue
+			}
+			userID, _ := data["user_id"].(string)
+			text, _ := data["text"].(string)
+
+			l.mut.Lock()
+			l.utterances = append(l.utterances, Utterance{
+				Speaker: c.names[userID],
+				Text:    text,
+			})
+			l.mut.Unlock()
+		}
+	}()
+
+	return l, nil
+}
+
+// Close stops listening and returns the received captions.
+func (l *captionsListener) Close() []Utterance {
+	l.ws.Close()
+	<-l.doneCh
+
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	return l.utterances
+}
+
+// waitForTranscription waits for the transcription of the call to be posted
+// in the channel and returns its cues.
+func (c *resultsClient) waitForTranscription(since time.Time, timeout time.Duration) ([]Utterance, error) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	timeoutCh := time.After(timeout)
+
+	for {
+		fileID, err := c.getTranscriptionFileID(since)
+		if err != nil {
+			return nil, err
+		}
+
+		if fileID != "" {
+			ctx, cancel := context.WithTimeout(context.Background(), client.HTTPRequestTimeout)
+			data, _, err := c.apiClient.GetFile(ctx, fileID)
+			cancel()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get transcription file: %w", err)
+			}
+
+			cues, err := parseVTT(strings.NewReader(string(data)))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse transcription: %w", err)
+			}
+
+			utterances := make([]Utterance, len(cues))
+			for i, cue := range cues {
+				utterances[i] = Utterance{
+					Speaker: c.matchSpeaker(cue.Speaker),
+					Text:    cue.Text,
+				}
+			}
+			return utterances, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-timeoutCh:
+			return nil, fmt.Errorf("timed out waiting for transcription")
+		}
+	}
+}
+
+func (c *resultsClient) getTranscriptionFileID(since time.Time) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), client.HTTPRequestTimeout)
+	defer cancel()
+	posts, _, err := c.apiClient.GetPostsSince(ctx, channelID, since.UnixMilli(), false)
+	if err != nil {
+		return "", fmt.Errorf("failed to get posts: %w", err)
+	}
+
+	for _, post := range posts.Posts {
+		if post.Type != transcriptionPostType {
+			continue
+		}
+		// The captions prop points to the WebVTT version of the transcription.
+		captions, _ := post.GetProp("captions").([]any)
+		if len(captions) == 0 {
+			continue
+		}
+		caption, _ := captions[0].(map[string]any)
+		if fileID, _ := caption["file_id"].(string); fileID != "" {
+			return fileID, nil
+		}
+	}
+
+	return "", nil
+}
+
+// matchSpeaker returns the script user the transcription speaker name refers to.
+func (c *resultsClient) matchSpeaker(name string) string {
+	for _, scriptName := range c.names {
+		if strings.EqualFold(name, scriptName) {
+			return scriptName
+		}
+	}
+	return ""
+}
+
+type vttCue struct {
+	Speaker string
+	Text    string
+}
+
+// parseVTT returns the cues of a WebVTT file, extracting speakers from voice
+// tags (e.g. "<v Alice>Hi.</v>").
+func parseVTT(r io.Reader) ([]vttCue, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	if !scanner.Scan() || !strings.HasPrefix(scanner.Text(), "WEBVTT") {
+		return nil, fmt.Errorf("missing WEBVTT header")
+	}
+
+	var cues []vttCue
+	var inCue bool
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			inCue = false
+		case strings.Contains(line, "-->"):
+			inCue = true
+			cues = append(cues, vttCue{})
+		case inCue:
+			cue := &cues[len(cues)-1]
+			if rest, ok := strings.CutPrefix(line, "<v "); ok {
+				if name, text, ok := strings.Cut(rest, ">"); ok {
+					cue.Speaker = strings.TrimSpace(name)
+					line = text
+				}
+			}
+			line = strings.TrimSpace(strings.TrimSuffix(line, "</v>"))
+			if cue.Text != "" {
+				cue.Text += " "
+			}
+			cue.Text += line
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return cues, nil
+}
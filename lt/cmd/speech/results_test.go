@@ -0,0 +1,45 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseVTT(t *testing.T) {
+	t.Run("missing header", func(t *testing.T) {
+		if _, err := parseVTT(strings.NewReader("00:00:00.000 --> 00:00:01.000\nHi.\n")); err == nil {
+			t.Fatalf("expected error")
+		}
+	})
+
+	cues, err := parseVTT(strings.NewReader(`WEBVTT
+
+1
+00:00:00.000 --> 00:00:01.500
+<v Alice>Hi there,
+how are you?</v>
+
+2
+00:00:02.000 --> 00:00:03.000
+<v Bob Smith>I am fine.
+
+00:00:03.000 --> 00:00:04.000
+No speaker.
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []vttCue{
+		{Speaker: "Alice", Text: "Hi there, how are you?"},
+		{Speaker: "Bob Smith", Text: "I am fine."},
+		{Text: "No speaker."},
+	}
+	if !reflect.DeepEqual(cues, want) {
+		t.Errorf("got %+v, want %+v", cues, want)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Utterance is a piece of recognized speech (e.g. a caption or a
+// transcription cue) attributed to a script user.
+type Utterance struct {
+	// The script user the speech was attributed to, empty if unknown.
+	Speaker string
+	Text    string
+}
+
+type SegmentScore struct {
+	Index      int     `json:"index"`
+	Speaker    string  `json:"speaker"`
+	Reference  string  `json:"reference"`
+	Hypothesis string  `json:"hypothesis"`
+	Words      int     `json:"words"`
+	Errors     int     `json:"errors"`
+	WER        float64 `json:"wer"`
+}
+
+type SpeakerScore struct {
+	Speaker string  `json:"speaker"`
+	Words   int     `json:"words"`
+	Errors  int     `json:"errors"`
+	WER     float64 `json:"wer"`
+	// The number of recognized words spoken by the speaker, and how many of
+	// them were attributed to them.
+	RecognizedWords     int     `json:"recognized_words"`
+	AttributedWords     int     `json:"attributed_words"`
+	AttributionAccuracy float64 `json:"attribution_accuracy"`
+}
+
+type Score struct {
+	Source string  `json:"source"`
+	Words  int     `json:"words"`
+	Errors int     `json:"errors"`
+	WER    float64 `json:"wer"`
+	// Recognized words that couldn't be matched to any line of the script.
+	// They count as insertions.
+	UnmatchedWords      int            `json:"unmatched_words"`
+	AttributionAccuracy float64        `json:"attribution_accuracy"`
+	Speakers            []SpeakerScore `json:"speakers"`
+	Segments            []SegmentScore `json:"segments"`
+}
+
+// normalizeWords lowercases the text and splits it into words, ignoring
+// punctuation.
+func normalizeWords(text string) []string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == '\'' || r == '’':
+			// Drop apostrophes so that "it's" and "its" match.
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Fields(b.String())
+}
+
+// editDistance returns the minimum number of word substitutions, insertions
+// and deletions to turn ref into hyp.
+func editDistance(ref, hyp []string) int {
+	prev := make([]int, len(hyp)+1)
+	cur := make([]int, len(hyp)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ref); i++ {
+		cur[0] = i
+		for j := 1; j <= len(hyp); j++ {
+			cost := 1
+			if ref[i-1] == hyp[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(hyp)]
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// scoreUtterances scores the recognized utterances against the script. Each
+// utterance is matched to the script line sharing the most words with it,
+// preferring lines of the speaker it was attributed to on ties. Word error
+// rates are then computed per line, per speaker and overall.
+func scoreUtterances(script Script, source string, utterances []Utterance) Score {
+	type segment struct {
+		speaker string
+		text    string
+		words   []string
+		counts  map[string]int
+		hyp     []string
+		hypText []string
+	}
+
+	var segments []*segment
+	for _, block := range script.blocks {
+		for i, userIdx := range block.speakers {
+			seg := &segment{
+				speaker: script.users[userIdx],
+				text:    block.text[i],
+				words:   normalizeWords(block.text[i]),
+				counts:  map[string]int{},
+			}
+			for _, w := range seg.words {
+				seg.counts[w]++
+			}
+			segments = append(segments, seg)
+		}
+	}
+
+	score := Score{
+		Source: source,
+	}
+
+	speakers := make(map[string]*SpeakerScore, len(script.users))
+	for _, name := range script.users {
+		speakers[name] = &SpeakerScore{Speaker: name}
+	}
+
+	for _, u := range utterances {
+		words := normalizeWords(u.Text)
+		if len(words) == 0 {
+			continue
+		}
+
+		var best *segment
+		bestOverlap := 0
+		for _, seg := range segments {
+			var overlap int
+			for _, w := range words {
+				if seg.counts[w] > 0 {
+					overlap++
+				}
+			}
+			if overlap > bestOverlap || (overlap == bestOverlap && overlap > 0 && best.speaker != u.Speaker && seg.speaker == u.Speaker) {
+				best = seg
+				bestOverlap = overlap
+			}
+		}
+
+		if best == nil {
+			score.UnmatchedWords += len(words)
+			continue
+		}
+
+		best.hyp = append(best.hyp, words...)
+		best.hypText = append(best.hypText, u.Text)
+
+		sp := speakers[best.speaker]
+		sp.RecognizedWords += len(words)
+		if u.Speaker == best.speaker {
+			sp.AttributedWords += len(words)
+		}
+	}
+
+	for i, seg := range segments {
+		errors := editDistance(seg.words, seg.hyp)
+		score.Segments = append(score.Segments, SegmentScore{
+			Index:      i,
+			Speaker:    seg.speaker,
+			Reference:  seg.text,
+			Hypothesis: strings.Join(seg.hypText, " "),
+			Words:      len(seg.words),
+			Errors:     errors,
+			WER:        ratio(errors, len(seg.words)),
+		})
+
+		sp := speakers[seg.speaker]
+		sp.Words += len(seg.words)
+		sp.Errors += errors
+		score.Words += len(seg.words)
+		score.Errors += errors
+	}
+	score.Errors += score.UnmatchedWords
+	score.WER = ratio(score.Errors, score.Words)
+
+	var recognized, attributed int
+	for _, name := range script.users {
+		sp := speakers[name]
+		sp.WER = ratio(sp.Errors, sp.Words)
+		sp.AttributionAccuracy = ratio(sp.AttributedWords, sp.RecognizedWords)
+		recognized += sp.RecognizedWords
+		attributed += sp.AttributedWords
+		score.Speakers = append(score.Speakers, *sp)
+	}
+	score.AttributionAccuracy = ratio(attributed, recognized)
+
+	return score
+}
+
+func (s Score) WriteMarkdown(w io.Writer) error {
+	var err error
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	printf("# %s accuracy\n\n", s.Source)
+	printf("WER: %.1f%% (%d errors over %d words, %d unmatched words)\n\n", s.WER*100, s.Errors, s.Words, s.UnmatchedWords)
+	printf("Speaker attribution accuracy: %.1f%%\n\n", s.AttributionAccuracy*100)
+
+	printf("## Speakers\n\n")
+	printf("| Speaker | Words | Errors | WER | Attribution |\n")
+	printf("|---|---|---|---|---|\n")
+	for _, sp := range s.Speakers {
+		printf("| %s | %d | %d | %.1f%% | %.1f%% |\n", sp.Speaker, sp.Words, sp.Errors, sp.WER*100, sp.AttributionAccuracy*100)
+	}
+	printf("\n")
+
+	printf("## Segments\n\n")
+	printf("| # | Speaker | Words | Errors | WER |\n")
+	printf("|---|---|---|---|---|\n")
+	for _, seg := range s.Segments {
+		printf("| %d | %s | %d | %d | %.1f%% |\n", seg.Index, seg.Speaker, seg.Words, seg.Errors, seg.WER*100)
+	}
+	printf("\n")
+
+	return err
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeWords(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", []string{}},
+		{"Hi.", []string{"hi"}},
+		{"It's the archduke’s, my cousin's!", []string{"its", "the", "archdukes", "my", "cousins"}},
+		{"(Come in under-the shadow)", []string{"come", "in", "under", "the", "shadow"}},
+		{"  Room   101 ", []string{"room", "101"}},
+	}
+
+	for _, tc := range tests {
+		if got := normalizeWords(tc.text); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("normalizeWords(%q) = %v, want %v", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		ref  string
+		hyp  string
+		want int
+	}{
+		{"", "", 0},
+		{"a b c", "", 3},
+		{"", "a b", 2},
+		{"a b c", "a b c", 0},
+		{"a b c", "a x c", 1},
+		{"a b c", "a c", 1},
+		{"a b c", "a b c d", 1},
+		{"a b c d", "b a d", 2},
+	}
+
+	for _, tc := range tests {
+		if got := editDistance(strings.Fields(tc.ref), strings.Fields(tc.hyp)); got != tc.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tc.ref, tc.hyp, got, tc.want)
+		}
+	}
+}
+
+func TestScoreUtterances(t *testing.T) {
+	script, err := importScript(strings.NewReader(`Alice-F Bob-M
+
+1s
+Alice
+Hi there, how are you?
+
+1s
+Bob
+I am fine, thanks.
+Alice
+Great to hear.
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	t.Run("perfect", func(t *testing.T) {
+		score := scoreUtterances(script, "test", []Utterance{
+			{Speaker: "Alice", Text: "hi there how are you"},
+			{Speaker: "Bob", Text: "I am fine thanks"},
+			{Speaker: "Alice", Text: "great to hear"},
+		})
+		if score.Words != 12 || score.Errors != 0 || score.WER != 0 {
+			t.Errorf("unexpected score: %+v", score)
+		}
+		if score.AttributionAccuracy != 1 {
+			t.Errorf("got attribution accuracy %f, want 1", score.AttributionAccuracy)
+		}
+		if len(score.Segments) != 3 || len(score.Speakers) != 2 {
+			t.Fatalf("unexpected score: %+v", score)
+		}
+	})
+
+	t.Run("errors and misattribution", func(t *testing.T) {
+		score := scoreUtterances(script, "test", []Utterance{
+			// One substitution.
+			{Speaker: "Alice", Text: "Hi there, who are you?"},
+			// One deletion, attributed to the wrong speaker.
+			{Speaker: "Alice", Text: "I am fine."},
+			// Nothing recognized for the last line, and an unmatched
+			// utterance.
+			{Speaker: "Bob", Text: "completely unrelated"},
+		})
+
+		if got := []int{score.Segments[0].Errors, score.Segments[1].Errors, score.Segments[2].Errors}; !reflect.DeepEqual(got, []int{1, 1, 3}) {
+			t.Errorf("got segment errors %v", got)
+		}
+		if score.Segments[1].Hypothesis != "I am fine." {
+			t.Errorf("got hypothesis %q", score.Segments[1].Hypothesis)
+		}
+		if score.UnmatchedWords != 2 {
+			t.Errorf("got %d unmatched words, want 2", score.UnmatchedWords)
+		}
+		if score.Errors != 7 || score.Words != 12 {
+			t.Errorf("got %d errors over %d words", score.Errors, score.Words)
+		}
+
+		alice, bob := score.Speakers[0], score.Speakers[1]
+		if alice.Speaker != "Alice" || alice.Errors != 4 || alice.Words != 8 || alice.AttributionAccuracy != 1 {
+			t.Errorf("unexpected alice score: %+v", alice)
+		}
+		if bob.Speaker != "Bob" || bob.Errors != 1 || bob.RecognizedWords != 3 || bob.AttributionAccuracy != 0 {
+			t.Errorf("unexpected bob score: %+v", bob)
+		}
+		// 5 recognized words out of 8 were attributed to the right speaker.
+		if score.AttributionAccuracy != 5.0/8 {
+			t.Errorf("got attribution accuracy %f", score.AttributionAccuracy)
+		}
+	})
+
+	t.Run("ties prefer attributed speaker", func(t *testing.T) {
+		script, err := importScript(strings.NewReader(`Alice-F Bob-M
+
+1s
+Alice
+Yes.
+Bob
+Yes.
+`))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		score := scoreUtterances(script, "test", []Utterance{
+			{Speaker: "Bob", Text: "yes"},
+		})
+		if score.Segments[0].Errors != 1 || score.Segments[1].Errors != 0 {
+			t.Errorf("unexpected segments: %+v", score.Segments)
+		}
+		if score.AttributionAccuracy != 1 {
+			t.Errorf("got attribution accuracy %f, want 1", score.AttributionAccuracy)
+		}
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		var b strings.Builder
+		score := scoreUtterances(script, "Live captions", nil)
+		if err := score.WriteMarkdown(&b); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !strings.Contains(b.String(), "# Live captions accuracy") || !strings.Contains(b.String(), "WER: 100.0%") {
+			t.Errorf("unexpected markdown:\n%s", b.String())
+		}
+	})
+}
@@ -0,0 +1,275 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/lt/client"
+	"github.com/mattermost/mattermost-plugin-calls/lt/report"
+	"github.com/mattermost/mattermost-plugin-calls/lt/scenario"
+)
+
+// defaultPollInterval is how often agents poll the coordinator.
+const defaultPollInterval = time.Second
+
+// errStopped is returned when the agent is stopped before starting its run.
+var errStopped = errors.New("stopped")
+
+type AgentConfig struct {
+	// The base URL of the coordinator (e.g. http://localhost:4545).
+	CoordinatorURL string
+	Name           string
+	// The settings local to the agent. Channels, UserOffset, Setup, Part,
+	// Parts and Metrics are filled from the assignment.
+	Runner scenario.Config
+	// Optional, defaults to 1s.
+	PollInterval time.Duration
+}
+
+// Agent runs the share of a scenario assigned by the coordinator.
+type Agent struct {
+	cfg        AgentConfig
+	httpClient *http.Client
+	log        *slog.Logger
+	id         int
+}
+
+func NewAgent(cfg AgentConfig) (*Agent, error) {
+	if cfg.CoordinatorURL == "" {
+		return nil, fmt.Errorf("coordinator URL should not be empty")
+	}
+	cfg.CoordinatorURL = strings.TrimSuffix(cfg.CoordinatorURL, "/")
+
+	if cfg.Runner.Logger == nil {
+		cfg.Runner.Logger = slog.Default()
+	}
+
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+
+	return &Agent{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: client.HTTPRequestTimeout},
+		log:        cfg.Runner.Logger.With("agent", cfg.Name),
+	}, nil
+}
+
+// Run registers with the coordinator, waits for the assignment and runs it,
+// posting the recorded metrics back once done. It returns early, without
+// posting results, if stopCh gets closed before the run starts.
+func (a *Agent) Run(stopCh <-chan struct{}) error {
+	if err := a.register(stopCh); err != nil {
+		return fmt.Errorf("failed to register: %w", err)
+	}
+
+	assignment, err := a.getAssignment(stopCh)
+	if err != nil {
+		return fmt.Errorf("failed to get assignment: %w", err)
+	}
+
+	a.log.Info("got assignment", slog.Int("part", assignment.Part), slog.Int("parts", assignment.Parts),
+		slog.String("startIn", assignment.StartIn.String()))
+
+	collector := report.NewCollector()
+	cfg := a.cfg.Runner
+	cfg.Channels = assignment.Channels
+	cfg.UserOffset = assignment.UserOffset
+	cfg.Setup = assignment.Setup
+	cfg.Part = assignment.Part
+	cfg.Parts = assignment.Parts
+	cfg.Metrics = collector
+
+	runner, err := scenario.NewRunner(assignment.Scenario, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create scenario runner: %w", err)
+	}
+
+	timer := time.NewTimer(assignment.StartIn)
+	select {
+	case <-timer.C:
+	case <-stopCh:
+		timer.Stop()
+		return errStopped
+	}
+
+	a.log.Info("starting run")
+
+	// Stopping either locally or when asked by the coordinator.
+	runStopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.watchStatus(stopCh, runStopCh, doneCh)
+	}()
+
+	runner.Run(runStopCh)
+	close(doneCh)
+	wg.Wait()
+
+	a.log.Info("run done, posting results")
+
+	if err := a.postJSON(fmt.Sprintf(pathResults, a.id), collector.Snapshot(), nil); err != nil {
+		return fmt.Errorf("failed to post results: %w", err)
+	}
+
+	return nil
+}
+
+func (a *Agent) register(stopCh <-chan struct{}) error {
+	var res RegisterResponse
+	// The coordinator may not be up yet.
+	err := a.poll(stopCh, func() (bool, error) {
+		if err := a.postJSON(pathAgents, RegisterRequest{Name: a.cfg.Name}, &res); err != nil {
+			var statusErr *statusError
+			if errors.As(err, &statusErr) {
+				return false, err
+			}
+			a.log.Debug("failed to reach coordinator, retrying", slog.String("err", err.Error()))
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	a.id = res.AgentID
+	a.log.Info("registered", slog.Int("id", a.id))
+
+	return nil
+}
+
+func (a *Agent) getAssignment(stopCh <-chan struct{}) (*Assignment, error) {
+	var assignment Assignment
+	err := a.poll(stopCh, func() (bool, error) {
+		return a.getJSON(fmt.Sprintf(pathAssignment, a.id), &assignment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &assignment, nil
+}
+
+// watchStatus closes runStopCh when stopCh gets closed or the coordinator
+// asks agents to stop, until doneCh gets closed.
+func (a *Agent) watchStatus(stopCh <-chan struct{}, runStopCh chan struct{}, doneCh <-chan struct{}) {
+	defer close(runStopCh)
+
+	ticker := time.NewTicker(a.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			var status Status
+			if _, err := a.getJSON(pathStatus, &status); err != nil {
+				a.log.Error("failed to get coordinator status", slog.String("err", err.Error()))
+				continue
+			}
+			if status.Stopping {
+				a.log.Info("stopping as requested by coordinator")
+				return
+			}
+		case <-stopCh:
+			return
+		case <-doneCh:
+			return
+		}
+	}
+}
+
+// poll calls fn every poll interval until it returns true or fails.
+func (a *Agent) poll(stopCh <-chan struct{}, fn func() (bool, error)) error {
+	ticker := time.NewTicker(a.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		done, err := fn()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return errStopped
+		}
+	}
+}
+
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("request failed with status %d: %s", e.code, e.msg)
+}
+
+func (a *Agent) do(method, path string, body any, out any) (int, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), method, a.cfg.CoordinatorURL+path, reqBody)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return resp.StatusCode, nil
+	case resp.StatusCode != http.StatusOK:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, &statusError{code: resp.StatusCode, msg: strings.TrimSpace(string(msg))}
+	case out != nil:
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	return resp.StatusCode, nil
+}
+
+// getJSON returns whether a response body was received.
+func (a *Agent) getJSON(path string, out any) (bool, error) {
+	code, err := a.do(http.MethodGet, path, nil, out)
+	return code == http.StatusOK, err
+}
+
+func (a *Agent) postJSON(path string, body any, out any) error {
+	_, err := a.do(http.MethodPost, path, body, out)
+	return err
+}
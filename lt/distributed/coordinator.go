@@ -0,0 +1,252 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package distributed
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/lt/report"
+	"github.com/mattermost/mattermost-plugin-calls/lt/scenario"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// defaultStartDelay leaves agents time to get their assignment before
+	// starting together.
+	defaultStartDelay = 5 * time.Second
+	// defaultResultsTimeout is how long to wait for the agents' results once
+	// stopped early.
+	defaultResultsTimeout = time.Minute
+)
+
+type CoordinatorConfig struct {
+	// The number of agents to wait for before starting.
+	Agents     int
+	Scenario   *scenario.Scenario
+	Channels   []*model.Channel
+	UserOffset int
+	Setup      bool
+	Logger     *slog.Logger
+	// Optional, defaults to 5s.
+	StartDelay time.Duration
+	// Optional, defaults to 1m.
+	ResultsTimeout time.Duration
+}
+
+type agentState struct {
+	name    string
+	results bool
+}
+
+// Coordinator hands out assignments to agents and merges their metrics. It
+// implements http.Handler, to be served on an address reachable by agents.
+type Coordinator struct {
+	cfg       CoordinatorConfig
+	mux       *http.ServeMux
+	collector *report.Collector
+
+	mut      sync.Mutex
+	agents   []*agentState
+	startAt  time.Time
+	stopping bool
+	// Closed once all agents have posted their results.
+	doneCh chan struct{}
+}
+
+func NewCoordinator(cfg CoordinatorConfig) (*Coordinator, error) {
+	if cfg.Agents <= 0 {
+		return nil, fmt.Errorf("agents should be > 0")
+	}
+
+	if cfg.Scenario == nil {
+		return nil, fmt.Errorf("scenario should not be nil")
+	}
+
+	if err := cfg.Scenario.IsValid(); err != nil {
+		return nil, fmt.Errorf("invalid scenario: %w", err)
+	}
+
+	if cfg.Agents > cfg.Scenario.UsersPerCall {
+		return nil, fmt.Errorf("agents cannot be greater than the number of users per call")
+	}
+
+	if len(cfg.Channels) < cfg.Scenario.Calls {
+		return nil, fmt.Errorf("not enough channels for %d calls", cfg.Scenario.Calls)
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	if cfg.StartDelay == 0 {
+		cfg.StartDelay = defaultStartDelay
+	}
+
+	if cfg.ResultsTimeout == 0 {
+		cfg.ResultsTimeout = defaultResultsTimeout
+	}
+
+	c := &Coordinator{
+		cfg:       cfg,
+		mux:       http.NewServeMux(),
+		collector: report.NewCollector(),
+		doneCh:    make(chan struct{}),
+	}
+
+	c.mux.HandleFunc("POST "+pathAgents, c.handleRegister)
+	c.mux.HandleFunc("GET /agents/{id}/assignment", c.handleAssignment)
+	c.mux.HandleFunc("POST /agents/{id}/results", c.handleResults)
+	c.mux.HandleFunc("GET "+pathStatus, c.handleStatus)
+
+	return c, nil
+}
+
+func (c *Coordinator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mux.ServeHTTP(w, r)
+}
+
+// Wait blocks until all agents have posted their results and returns the
+// collector holding the merged metrics. If stopCh gets closed first, agents
+// are asked to stop and results are waited for up to the configured timeout.
+func (c *Coordinator) Wait(stopCh <-chan struct{}) *report.Collector {
+	select {
+	case <-c.doneCh:
+		return c.collector
+	case <-stopCh:
+	}
+
+	c.mut.Lock()
+	c.stopping = true
+	c.mut.Unlock()
+	c.cfg.Logger.Info("stopping agents")
+
+	timer := time.NewTimer(c.cfg.ResultsTimeout)
+	defer timer.Stop()
+	select {
+	case <-c.doneCh:
+	case <-timer.C:
+		c.cfg.Logger.Error("timed out waiting for agents' results")
+	}
+
+	return c.collector
+}
+
+func (c *Coordinator) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var req RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if len(c.agents) == c.cfg.Agents {
+		http.Error(w, "all agents have already registered", http.StatusConflict)
+		return
+	}
+
+	id := len(c.agents)
+	c.agents = append(c.agents, &agentState{name: req.Name})
+	c.cfg.Logger.Info("agent registered", slog.Int("id", id), slog.String("name", req.Name),
+		slog.Int("registered", len(c.agents)), slog.Int("expected", c.cfg.Agents))
+
+	if len(c.agents) == c.cfg.Agents {
+		c.startAt = time.Now().Add(c.cfg.StartDelay)
+		c.cfg.Logger.Info("all agents registered, starting", slog.Time("startAt", c.startAt))
+	}
+
+	writeJSON(w, RegisterResponse{AgentID: id})
+}
+
+// getAgent returns the agent the request is for. It must be called with
+// c.mut held.
+func (c *Coordinator) getAgent(w http.ResponseWriter, r *http.Request) (int, *agentState) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 0 || id >= len(c.agents) {
+		http.Error(w, "agent not found", http.StatusNotFound)
+		return 0, nil
+	}
+	return id, c.agents[id]
+}
+
+func (c *Coordinator) handleAssignment(w http.ResponseWriter, r *http.Request) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	id, agent := c.getAgent(w, r)
+	if agent == nil {
+		return
+	}
+
+	// Still waiting for agents to register.
+	if c.startAt.IsZero() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, Assignment{
+		Part:       id,
+		Parts:      c.cfg.Agents,
+		Scenario:   c.cfg.Scenario,
+		Channels:   c.cfg.Channels,
+		UserOffset: c.cfg.UserOffset,
+		Setup:      c.cfg.Setup,
+		StartIn:    max(time.Until(c.startAt), 0),
+	})
+}
+
+func (c *Coordinator) handleResults(w http.ResponseWriter, r *http.Request) {
+	var snapshot report.Snapshot
+	if err := json.NewDecoder(r.Body).Decode(&snapshot); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode results: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	id, agent := c.getAgent(w, r)
+	if agent == nil {
+		return
+	}
+
+	if agent.results {
+		http.Error(w, "results already posted", http.StatusConflict)
+		return
+	}
+
+	c.collector.Merge(&snapshot)
+	agent.results = true
+	c.cfg.Logger.Info("agent results received", slog.Int("id", id), slog.String("name", agent.name))
+
+	for _, a := range c.agents {
+		if !a.results {
+			return
+		}
+	}
+	if len(c.agents) == c.cfg.Agents {
+		close(c.doneCh)
+	}
+}
+
+func (c *Coordinator) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	writeJSON(w, Status{Stopping: c.stopping})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("failed to write response", slog.String("err", err.Error()))
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package distributed
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/lt/client"
+	"github.com/mattermost/mattermost-plugin-calls/lt/scenario"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+type fakeParticipant struct {
+	cfg         client.Config
+	users       *testUsers
+	connectedCh chan struct{}
+}
+
+func (p *fakeParticipant) Connect(stopCh chan struct{}) error {
+	p.users.join(p.cfg.Username)
+	close(p.connectedCh)
+	<-stopCh
+	return nil
+}
+
+func (p *fakeParticipant) Connected() <-chan struct{} {
+	return p.connectedCh
+}
+
+func (p *fakeParticipant) SetMuted(_ bool) error {
+	return nil
+}
+
+func (p *fakeParticipant) RaiseHand() error {
+	return nil
+}
+
+func (p *fakeParticipant) LowerHand() error {
+	return nil
+}
+
+func (p *fakeParticipant) React(_, _ string) error {
+	return errors.New("react failed")
+}
+
+// testUsers tracks the users that joined across all agents.
+type testUsers struct {
+	mut    sync.Mutex
+	joined map[string]int
+}
+
+func (u *testUsers) join(username string) {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+	u.joined[username]++
+}
+
+func newTestScenario(dur time.Duration) *scenario.Scenario {
+	return &scenario.Scenario{
+		Calls:        1,
+		UsersPerCall: 4,
+		Phases: []scenario.Phase{
+			{Type: scenario.PhaseSteady, Duration: scenario.Duration(dur), Target: 4},
+		},
+		Behaviors: []scenario.Behavior{
+			{
+				Users:   4,
+				Actions: []scenario.Action{{Type: scenario.ActionReact, Every: scenario.Duration(20 * time.Millisecond)}},
+			},
+		},
+	}
+}
+
+func newTestAgents(t *testing.T, n int, url string, users *testUsers) []*Agent {
+	t.Helper()
+
+	agents := make([]*Agent, n)
+	for i := range agents {
+		a, err := NewAgent(AgentConfig{
+			CoordinatorURL: url,
+			Name:           fmt.Sprintf("agent-%d", i),
+			Runner: scenario.Config{
+				UserPrefix: "testuser-",
+				Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+				NewParticipant: func(cfg client.Config, _ *slog.Logger) scenario.Participant {
+					return &fakeParticipant{cfg: cfg, users: users, connectedCh: make(chan struct{})}
+				},
+			},
+			PollInterval: 10 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		agents[i] = a
+	}
+
+	return agents
+}
+
+func runAgents(agents []*Agent, stopCh chan struct{}) chan error {
+	errCh := make(chan error, len(agents))
+	for _, a := range agents {
+		go func() {
+			errCh <- a.Run(stopCh)
+		}()
+	}
+	return errCh
+}
+
+func newTestCoordinator(t *testing.T, s *scenario.Scenario, agents int) (*Coordinator, *httptest.Server) {
+	t.Helper()
+
+	c, err := NewCoordinator(CoordinatorConfig{
+		Agents:     agents,
+		Scenario:   s,
+		Channels:   []*model.Channel{{Id: model.NewId(), TeamId: model.NewId()}},
+		UserOffset: 10,
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		StartDelay: 50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	srv := httptest.NewServer(c)
+	t.Cleanup(srv.Close)
+
+	return c, srv
+}
+
+func TestDistributedRun(t *testing.T) {
+	t.Run("merged results", func(t *testing.T) {
+		c, srv := newTestCoordinator(t, newTestScenario(300*time.Millisecond), 2)
+		users := &testUsers{joined: map[string]int{}}
+		errCh := runAgents(newTestAgents(t, 2, srv.URL, users), make(chan struct{}))
+
+		doneCh := make(chan struct{})
+		go func() {
+			defer close(doneCh)
+			r := c.Wait(make(chan struct{})).Report()
+			if r.TotalErrors == 0 || len(r.Errors) != 1 || r.Errors[0].Metric != "action" {
+				t.Errorf("unexpected errors: %+v", r.Errors)
+			}
+		}()
+
+		for range 2 {
+			if err := <-errCh; err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+		<-doneCh
+
+		// Each user of the call was driven by exactly one agent.
+		users.mut.Lock()
+		defer users.mut.Unlock()
+		if len(users.joined) != 4 {
+			t.Fatalf("got %d users, want 4: %v", len(users.joined), users.joined)
+		}
+		for i := range 4 {
+			if n := users.joined[fmt.Sprintf("testuser-%d", 10+i)]; n != 1 {
+				t.Errorf("user %d joined %d times, want 1", 10+i, n)
+			}
+		}
+	})
+
+	t.Run("stopped by coordinator", func(t *testing.T) {
+		c, srv := newTestCoordinator(t, newTestScenario(time.Minute), 2)
+		users := &testUsers{joined: map[string]int{}}
+		errCh := runAgents(newTestAgents(t, 2, srv.URL, users), make(chan struct{}))
+
+		// Wait for the run to start.
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			users.mut.Lock()
+			n := len(users.joined)
+			users.mut.Unlock()
+			if n == 4 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for users to join")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+
+		stopCh := make(chan struct{})
+		close(stopCh)
+		c.Wait(stopCh)
+
+		for range 2 {
+			select {
+			case err := <-errCh:
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for agents to stop")
+			}
+		}
+	})
+
+	t.Run("too many agents", func(t *testing.T) {
+		_, srv := newTestCoordinator(t, newTestScenario(time.Minute), 1)
+
+		for i, want := range []int{http.StatusOK, http.StatusConflict} {
+			resp, err := http.Post(srv.URL+pathAgents, "application/json", strings.NewReader(fmt.Sprintf(`{"name": "agent-%d"}`, i)))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != want {
+				t.Errorf("got status %d, want %d", resp.StatusCode, want)
+			}
+		}
+	})
+
+	t.Run("stopped before start", func(t *testing.T) {
+		_, srv := newTestCoordinator(t, newTestScenario(time.Minute), 2)
+		stopCh := make(chan struct{})
+		errCh := runAgents(newTestAgents(t, 1, srv.URL, &testUsers{joined: map[string]int{}}), stopCh)
+
+		close(stopCh)
+		if err := <-errCh; !errors.Is(err, errStopped) {
+			t.Fatalf("got error %v, want %v", err, errStopped)
+		}
+	})
+}
+
+func TestNewCoordinator(t *testing.T) {
+	channels := []*model.Channel{{Id: model.NewId()}}
+
+	for name, cfg := range map[string]CoordinatorConfig{
+		"no agents":        {Scenario: newTestScenario(time.Minute), Channels: channels},
+		"no scenario":      {Agents: 1, Channels: channels},
+		"too many agents":  {Agents: 5, Scenario: newTestScenario(time.Minute), Channels: channels},
+		"missing channels": {Agents: 1, Scenario: newTestScenario(time.Minute)},
+	} {
+		if _, err := NewCoordinator(cfg); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package distributed splits a load-test scenario across several agent
+// processes, possibly on different machines, driven by a coordinator.
+//
+// Agents register with the coordinator over HTTP and poll for their
+// assignment, which is handed out once the expected number of agents has
+// registered. Each agent drives its share of the users of every call, starts
+// at the same time as the others and posts its metrics back to the
+// coordinator once done, where they get merged into a single report.
+package distributed
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/lt/scenario"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	pathAgents     = "/agents"
+	pathAssignment = "/agents/%d/assignment"
+	pathResults    = "/agents/%d/results"
+	pathStatus     = "/status"
+)
+
+type RegisterRequest struct {
+	Name string `json:"name"`
+}
+
+type RegisterResponse struct {
+	AgentID int `json:"agent_id"`
+}
+
+// Assignment is the part of the load-test an agent is in charge of.
+type Assignment struct {
+	// The share of each call's users driven by the agent, see scenario.Config.
+	Part  int `json:"part"`
+	Parts int `json:"parts"`
+
+	Scenario   *scenario.Scenario `json:"scenario"`
+	Channels   []*model.Channel   `json:"channels"`
+	UserOffset int                `json:"user_offset"`
+	Setup      bool               `json:"setup"`
+
+	// How long to wait before starting, relative to when the assignment was
+	// received so that start times don't depend on the agents' clocks.
+	StartIn time.Duration `json:"start_in"`
+}
+
+type Status struct {
+	// Whether agents should stop early, e.g. because the coordinator got
+	// interrupted.
+	Stopping bool `json:"stopping"`
+}
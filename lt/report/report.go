@@ -0,0 +1,318 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/lt/capacity"
+)
+
+// Collector aggregates the timings and failures recorded by load-test users.
+// It implements client.MetricsRecorder.
+type Collector struct {
+	startAt time.Time
+
+	mut     sync.Mutex
+	timings map[string][]time.Duration
+	errors  map[string]*ErrorStats
+}
+
+func NewCollector() *Collector {
+	return &Collector{
+		startAt: time.Now(),
+		timings: map[string][]time.Duration{},
+		errors:  map[string]*ErrorStats{},
+	}
+}
+
+func (c *Collector) RecordTiming(metric string, d time.Duration) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.timings[metric] = append(c.timings[metric], d)
+}
+
+func (c *Collector) RecordError(metric string, err error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	stats := c.errors[metric]
+	if stats == nil {
+		stats = &ErrorStats{Metric: metric}
+		c.errors[metric] = stats
+	}
+	stats.Count++
+	if err != nil {
+		stats.LastError = err.Error()
+	}
+}
+
+// Snapshot holds the raw metrics recorded by a collector, so that the
+// metrics of several collectors (e.g. distributed agents) can be merged
+// before computing percentiles.
+type Snapshot struct {
+	Timings map[string][]time.Duration `json:"timings"`
+	Errors  []ErrorStats               `json:"errors"`
+}
+
+// Snapshot returns a copy of the metrics recorded so far.
+func (c *Collector) Snapshot() *Snapshot {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	s := &Snapshot{
+		Timings: make(map[string][]time.Duration, len(c.timings)),
+		Errors:  []ErrorStats{},
+	}
+	for metric, samples := range c.timings {
+		s.Timings[metric] = slices.Clone(samples)
+	}
+	for _, stats := range c.errors {
+		s.Errors = append(s.Errors, *stats)
+	}
+
+	return s
+}
+
+// Merge adds the metrics from the snapshot to the collector.
+func (c *Collector) Merge(s *Snapshot) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for metric, samples := range s.Timings {
+		c.timings[metric] = append(c.timings[metric], samples...)
+	}
+	for _, es := range s.Errors {
+		stats := c.errors[es.Metric]
+		if stats == nil {
+			stats = &ErrorStats{Metric: es.Metric}
+			c.errors[es.Metric] = stats
+		}
+		stats.Count += es.Count
+		if es.LastError != "" {
+			stats.LastError = es.LastError
+		}
+	}
+}
+
+// TimingStats holds the distribution of a timing metric, in milliseconds.
+type TimingStats struct {
+	Metric string  `json:"metric"`
+	Count  int     `json:"count"`
+	Min    float64 `json:"min_ms"`
+	Mean   float64 `json:"mean_ms"`
+	P50    float64 `json:"p50_ms"`
+	P95    float64 `json:"p95_ms"`
+	P99    float64 `json:"p99_ms"`
+	Max    float64 `json:"max_ms"`
+}
+
+type ErrorStats struct {
+	Metric    string `json:"metric"`
+	Count     int    `json:"count"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+type Report struct {
+	StartAt     time.Time         `json:"start_at"`
+	EndAt       time.Time         `json:"end_at"`
+	Timings     []TimingStats     `json:"timings"`
+	Errors      []ErrorStats      `json:"errors"`
+	TotalErrors int               `json:"total_errors"`
+	Thresholds  []ThresholdResult `json:"thresholds,omitempty"`
+	// The load and resource usage of the RTC nodes sampled during the test,
+	// as used for capacity planning.
+	Resources []capacity.Sample `json:"resources,omitempty"`
+}
+
+// Report returns a snapshot of the metrics collected so far.
+func (c *Collector) Report() *Report {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	r := &Report{
+		StartAt: c.startAt,
+		EndAt:   time.Now(),
+		Timings: []TimingStats{},
+		Errors:  []ErrorStats{},
+	}
+
+	for metric, samples := range c.timings {
+		r.Timings = append(r.Timings, newTimingStats(metric, samples))
+	}
+	sort.Slice(r.Timings, func(i, j int) bool {
+		return r.Timings[i].Metric < r.Timings[j].Metric
+	})
+
+	for _, stats := range c.errors {
+		r.Errors = append(r.Errors, *stats)
+		r.TotalErrors += stats.Count
+	}
+	sort.Slice(r.Errors, func(i, j int) bool {
+		return r.Errors[i].Metric < r.Errors[j].Metric
+	})
+
+	return r
+}
+
+func newTimingStats(metric string, samples []time.Duration) TimingStats {
+	stats := TimingStats{
+		Metric: metric,
+		Count:  len(samples),
+	}
+	if len(samples) == 0 {
+		return stats
+	}
+
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+
+	stats.Min = toMillis(sorted[0])
+	stats.Max = toMillis(sorted[len(sorted)-1])
+	stats.Mean = toMillis(sum / time.Duration(len(sorted)))
+	stats.P50 = toMillis(percentile(sorted, 50))
+	stats.P95 = toMillis(percentile(sorted, 95))
+	stats.P99 = toMillis(percentile(sorted, 99))
+
+	return stats
+}
+
+// percentile returns the nearest-rank percentile of the sorted samples.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
+
+func toMillis(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
+
+func (r *Report) getTiming(metric string) (TimingStats, bool) {
+	for _, stats := range r.Timings {
+		if stats.Metric == metric {
+			return stats, true
+		}
+	}
+	return TimingStats{}, false
+}
+
+func (r *Report) getErrors(metric string) int {
+	for _, stats := range r.Errors {
+		if stats.Metric == metric {
+			return stats.Count
+		}
+	}
+	return 0
+}
+
+// Failed returns whether any of the checked thresholds was not met.
+func (r *Report) Failed() bool {
+	for _, res := range r.Thresholds {
+		if !res.Passed {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var err error
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	printf("# Load-test report\n\n")
+	printf("Started at %s, ran for %s.\n\n", r.StartAt.UTC().Format(time.RFC3339), r.EndAt.Sub(r.StartAt).Round(time.Second))
+
+	printf("## Timings (ms)\n\n")
+	if len(r.Timings) == 0 {
+		printf("No timings recorded.\n\n")
+	} else {
+		printf("| Metric | Count | Min | Mean | P50 | P95 | P99 | Max |\n")
+		printf("|---|---|---|---|---|---|---|---|\n")
+		for _, s := range r.Timings {
+			printf("| %s | %d | %.1f | %.1f | %.1f | %.1f | %.1f | %.1f |\n",
+				s.Metric, s.Count, s.Min, s.Mean, s.P50, s.P95, s.P99, s.Max)
+		}
+		printf("\n")
+	}
+
+	printf("## Errors\n\n")
+	if len(r.Errors) == 0 {
+		printf("No errors recorded.\n\n")
+	} else {
+		printf("| Metric | Count | Last error |\n")
+		printf("|---|---|---|\n")
+		for _, s := range r.Errors {
+			printf("| %s | %d | %s |\n", s.Metric, s.Count, s.LastError)
+		}
+		printf("\n")
+	}
+
+	if len(r.Thresholds) > 0 {
+		printf("## Thresholds\n\n")
+		printf("| Threshold | Limit | Value | Result |\n")
+		printf("|---|---|---|---|\n")
+		for _, res := range r.Thresholds {
+			result := "PASS"
+			if !res.Passed {
+				result = "FAIL"
+			}
+			value := fmt.Sprintf("%g", res.Value)
+			if res.Error != "" {
+				value = res.Error
+			}
+			printf("| %s | %g | %s | %s |\n", res.Name, res.Limit, value, result)
+		}
+		printf("\n")
+	}
+
+	if len(r.Resources) > 0 {
+		printf("## Resources\n\n")
+		printf("| Node | Samples | Max sessions | Max CPU (cores) | Max memory (MB) |\n")
+		printf("|---|---|---|---|---|\n")
+		var nodes []string
+		peaks := map[string]*capacity.Sample{}
+		counts := map[string]int{}
+		for _, s := range r.Resources {
+			peak, ok := peaks[s.Node]
+			if !ok {
+				nodes = append(nodes, s.Node)
+				peak = &capacity.Sample{}
+				peaks[s.Node] = peak
+			}
+			counts[s.Node]++
+			peak.Sessions = max(peak.Sessions, s.Sessions)
+			peak.CPU = max(peak.CPU, s.CPU)
+			peak.MemoryMB = max(peak.MemoryMB, s.MemoryMB)
+		}
+		for _, node := range nodes {
+			peak := peaks[node]
+			printf("| %s | %d | %g | %.2f | %.0f |\n", node, counts[node], peak.Sessions, peak.CPU, peak.MemoryMB)
+		}
+		printf("\n")
+	}
+
+	return err
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/lt/capacity"
+)
+
+func TestCollectorReport(t *testing.T) {
+	c := NewCollector()
+
+	// 1ms to 100ms in reverse order.
+	for i := 100; i > 0; i-- {
+		c.RecordTiming("join", time.Duration(i)*time.Millisecond)
+	}
+	c.RecordTiming("login", 250*time.Millisecond)
+	c.RecordError("call", errors.New("ws error: first"))
+	c.RecordError("call", errors.New("ws error: second"))
+	c.RecordError("login", errors.New("login failed"))
+
+	r := c.Report()
+
+	if len(r.Timings) != 2 || r.Timings[0].Metric != "join" || r.Timings[1].Metric != "login" {
+		t.Fatalf("unexpected timings: %+v", r.Timings)
+	}
+
+	join := r.Timings[0]
+	want := TimingStats{Metric: "join", Count: 100, Min: 1, Mean: 50.5, P50: 50, P95: 95, P99: 99, Max: 100}
+	if join != want {
+		t.Errorf("got %+v, want %+v", join, want)
+	}
+
+	login := r.Timings[1]
+	if login.P50 != 250 || login.P99 != 250 || login.Count != 1 {
+		t.Errorf("unexpected login stats: %+v", login)
+	}
+
+	if r.TotalErrors != 3 {
+		t.Errorf("got %d errors, want 3", r.TotalErrors)
+	}
+	if len(r.Errors) != 2 || r.Errors[0] != (ErrorStats{Metric: "call", Count: 2, LastError: "ws error: second"}) {
+		t.Errorf("unexpected errors: %+v", r.Errors)
+	}
+}
+
+func TestCollectorMerge(t *testing.T) {
+	a := NewCollector()
+	b := NewCollector()
+	for i := 1; i <= 100; i++ {
+		// Odd samples on one side, even ones on the other.
+		c := a
+		if i%2 == 0 {
+			c = b
+		}
+		c.RecordTiming("join", time.Duration(i)*time.Millisecond)
+	}
+	a.RecordError("call", errors.New("first"))
+	b.RecordError("call", errors.New("second"))
+	b.RecordError("login", errors.New("login failed"))
+
+	// Snapshots go over the wire between agents and the coordinator.
+	data, err := json.Marshal(b.Snapshot())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	merged := NewCollector()
+	merged.Merge(a.Snapshot())
+	merged.Merge(&s)
+	r := merged.Report()
+
+	want := TimingStats{Metric: "join", Count: 100, Min: 1, Mean: 50.5, P50: 50, P95: 95, P99: 99, Max: 100}
+	if len(r.Timings) != 1 || r.Timings[0] != want {
+		t.Errorf("unexpected timings: %+v", r.Timings)
+	}
+	if r.TotalErrors != 3 || len(r.Errors) != 2 || r.Errors[0] != (ErrorStats{Metric: "call", Count: 2, LastError: "second"}) {
+		t.Errorf("unexpected errors: %+v", r.Errors)
+	}
+}
+
+func TestReportWrite(t *testing.T) {
+	c := NewCollector()
+	c.RecordTiming("join", 1500*time.Millisecond)
+	c.RecordError("call", errors.New("ws error"))
+
+	r := c.Report()
+	r.Check([]Threshold{
+		{Name: "join.p95", Metric: "join", Stat: StatP95, Limit: 1000},
+		{Name: "errors", Stat: StatErrors, Limit: 1},
+	})
+	r.Resources = []capacity.Sample{
+		{Node: "rtcd-0", Sessions: 10, CPU: 0.5, MemoryMB: 120},
+		{Node: "rtcd-0", Sessions: 20, CPU: 0.9, MemoryMB: 150},
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := r.WriteJSON(&buf); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		var decoded Report
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if decoded.Timings[0].P95 != 1500 || decoded.TotalErrors != 1 || len(decoded.Thresholds) != 2 {
+			t.Errorf("unexpected report: %+v", decoded)
+		}
+		if len(decoded.Resources) != 2 {
+			t.Errorf("unexpected resources: %+v", decoded.Resources)
+		}
+		if !strings.Contains(buf.String(), `"p95_ms": 1500`) {
+			t.Errorf("missing p95 field: %s", buf.String())
+		}
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := r.WriteMarkdown(&buf); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		for _, line := range []string{
+			"| join | 1 | 1500.0 | 1500.0 | 1500.0 | 1500.0 | 1500.0 | 1500.0 |",
+			"| call | 1 | ws error |",
+			"| join.p95 | 1000 | 1500 | FAIL |",
+			"| errors | 1 | 1 | PASS |",
+			"| rtcd-0 | 2 | 20 | 0.90 | 150 |",
+		} {
+			if !strings.Contains(buf.String(), line) {
+				t.Errorf("missing line %q in:\n%s", line, buf.String())
+			}
+		}
+	})
+}
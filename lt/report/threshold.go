@@ -0,0 +1,136 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package report
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	StatP50    = "p50"
+	StatP95    = "p95"
+	StatP99    = "p99"
+	StatMax    = "max"
+	StatMean   = "mean"
+	StatErrors = "errors"
+)
+
+// Threshold is an upper limit for a metric statistic. Timing limits are in
+// milliseconds, error limits are counts.
+type Threshold struct {
+	Name   string  `json:"name"`
+	Metric string  `json:"metric"`
+	Stat   string  `json:"stat"`
+	Limit  float64 `json:"limit"`
+}
+
+type ThresholdResult struct {
+	Threshold
+	Value  float64 `json:"value"`
+	Passed bool    `json:"passed"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// ParseThresholds parses a comma separated list of thresholds such as
+// "join.p95=2s,first_track.p99=5s,errors=0,login.errors=10". A bare "errors"
+// threshold applies to the total number of errors.
+func ParseThresholds(s string) ([]Threshold, error) {
+	var thresholds []Threshold
+	for item := range strings.SplitSeq(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		th, err := parseThreshold(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold %q: %w", item, err)
+		}
+		thresholds = append(thresholds, th)
+	}
+	return thresholds, nil
+}
+
+func parseThreshold(s string) (Threshold, error) {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return Threshold{}, fmt.Errorf("expected name=value")
+	}
+
+	th := Threshold{Name: name}
+	if name == StatErrors {
+		th.Stat = StatErrors
+	} else {
+		metric, stat, ok := strings.Cut(name, ".")
+		if !ok || metric == "" {
+			return Threshold{}, fmt.Errorf("expected metric.stat name")
+		}
+		th.Metric = metric
+		th.Stat = stat
+	}
+
+	switch th.Stat {
+	case StatErrors:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return Threshold{}, fmt.Errorf("errors limit should be a non-negative integer")
+		}
+		th.Limit = float64(n)
+	case StatP50, StatP95, StatP99, StatMax, StatMean:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return Threshold{}, err
+		}
+		th.Limit = toMillis(d)
+	default:
+		return Threshold{}, fmt.Errorf("unsupported stat %q", th.Stat)
+	}
+
+	return th, nil
+}
+
+// Check evaluates the thresholds against the report, storing the results in it.
+func (r *Report) Check(thresholds []Threshold) {
+	r.Thresholds = make([]ThresholdResult, 0, len(thresholds))
+	for _, th := range thresholds {
+		res := ThresholdResult{Threshold: th}
+
+		if th.Stat == StatErrors {
+			if th.Metric == "" {
+				res.Value = float64(r.TotalErrors)
+			} else {
+				res.Value = float64(r.getErrors(th.Metric))
+			}
+			res.Passed = res.Value <= th.Limit
+			r.Thresholds = append(r.Thresholds, res)
+			continue
+		}
+
+		stats, ok := r.getTiming(th.Metric)
+		if !ok || stats.Count == 0 {
+			// Nothing to measure likely means nobody managed to connect.
+			res.Error = "no samples"
+			r.Thresholds = append(r.Thresholds, res)
+			continue
+		}
+
+		switch th.Stat {
+		case StatP50:
+			res.Value = stats.P50
+		case StatP95:
+			res.Value = stats.P95
+		case StatP99:
+			res.Value = stats.P99
+		case StatMax:
+			res.Value = stats.Max
+		case StatMean:
+			res.Value = stats.Mean
+		}
+		res.Passed = res.Value <= th.Limit
+		r.Thresholds = append(r.Thresholds, res)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package report
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseThresholds(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []Threshold
+		wantErr bool
+	}{
+		{
+			name:  "empty",
+			input: "",
+		},
+		{
+			name:  "valid",
+			input: "join.p95=2s, first_track.max=1m,errors=0,login.errors=10",
+			want: []Threshold{
+				{Name: "join.p95", Metric: "join", Stat: StatP95, Limit: 2000},
+				{Name: "first_track.max", Metric: "first_track", Stat: StatMax, Limit: 60000},
+				{Name: "errors", Stat: StatErrors, Limit: 0},
+				{Name: "login.errors", Metric: "login", Stat: StatErrors, Limit: 10},
+			},
+		},
+		{
+			name:    "missing value",
+			input:   "join.p95",
+			wantErr: true,
+		},
+		{
+			name:    "missing stat",
+			input:   "join=2s",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported stat",
+			input:   "join.p90=2s",
+			wantErr: true,
+		},
+		{
+			name:    "invalid duration",
+			input:   "join.p99=2",
+			wantErr: true,
+		},
+		{
+			name:    "invalid errors limit",
+			input:   "errors=-1",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseThresholds(tc.input)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("got error %v, wantErr %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReportCheck(t *testing.T) {
+	r := &Report{
+		Timings: []TimingStats{
+			{Metric: "join", Count: 10, P50: 100, P95: 900, P99: 1200, Max: 1500, Mean: 200},
+		},
+		Errors: []ErrorStats{
+			{Metric: "call", Count: 2},
+		},
+		TotalErrors: 2,
+	}
+
+	thresholds, err := ParseThresholds("join.p95=1s,join.p99=1s,ice_connect.p50=1s,errors=2,call.errors=1,login.errors=0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	r.Check(thresholds)
+
+	want := []struct {
+		value  float64
+		passed bool
+		err    string
+	}{
+		{900, true, ""},
+		{1200, false, ""},
+		{0, false, "no samples"},
+		{2, true, ""},
+		{2, false, ""},
+		{0, true, ""},
+	}
+
+	if len(r.Thresholds) != len(want) {
+		t.Fatalf("got %d results, want %d", len(r.Thresholds), len(want))
+	}
+	for i, res := range r.Thresholds {
+		if res.Value != want[i].value || res.Passed != want[i].passed || res.Error != want[i].err {
+			t.Errorf("%s: got %+v, want %+v", res.Name, res, want[i])
+		}
+	}
+
+	if !r.Failed() {
+		t.Errorf("expected report to fail")
+	}
+}
@@ -0,0 +1,556 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/lt/client"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/pion/webrtc/v4/pkg/rtcerr"
+)
+
+// adjustInterval is how often steady and churn phases bring the number of
+// participants back to the target.
+const adjustInterval = time.Second
+
+// metricAction is the metric failed participant actions are recorded under.
+const metricAction = "action"
+
+type emoji struct {
+	name    string
+	unified string
+}
+
+var emojis = map[string]emoji{
+	"+1":               {"+1", "1F44D"},
+	"heart":            {"heart", "2764-FE0F"},
+	"joy":              {"joy", "1F602"},
+	"tada":             {"tada", "1F389"},
+	"clap":             {"clap", "1F44F"},
+	"white_check_mark": {"white_check_mark", "2705"},
+}
+
+// Participant is the call participant driven by the runner.
+type Participant interface {
+	Connect(stopCh chan struct{}) error
+	Connected() <-chan struct{}
+	SetMuted(muted bool) error
+	RaiseHand() error
+	LowerHand() error
+	React(name, unified string) error
+}
+
+type Config struct {
+	SiteURL      string
+	UserPrefix   string
+	UserPassword string
+	UserOffset   int
+	Setup        bool
+	SpeechFile   string
+	// Optional, the source of the media sent by participants. Defaults to
+	// looping over SpeechFile and the sample videos.
+	MediaSource client.MediaSource
+	// Optional, enables checking the continuity of the tracks received by
+	// participants.
+	Sink *client.SinkConfig
+	// The channels to start calls in, one per call.
+	Channels []*model.Channel
+	Logger   *slog.Logger
+	// Optional, receives the metrics recorded by participants.
+	Metrics client.MetricsRecorder
+	// Optional, defaults to creating a client.User.
+	NewParticipant func(cfg client.Config, log *slog.Logger) Participant
+	// Optional, the share of each call's users driven by this runner when
+	// users are split across several runners (e.g. distributed agents). Part
+	// is in the range [0, Parts). Phase targets and churn are split the same
+	// way. Defaults to driving all users.
+	Part  int
+	Parts int
+}
+
+type Runner struct {
+	s              *Scenario
+	cfg            Config
+	adjustInterval time.Duration
+}
+
+func NewRunner(s *Scenario, cfg Config) (*Runner, error) {
+	if s == nil {
+		return nil, fmt.Errorf("scenario should not be nil")
+	}
+
+	if err := s.IsValid(); err != nil {
+		return nil, fmt.Errorf("invalid scenario: %w", err)
+	}
+
+	if len(cfg.Channels) < s.Calls {
+		return nil, fmt.Errorf("not enough channels for %d calls", s.Calls)
+	}
+
+	if cfg.Parts == 0 {
+		cfg.Parts = 1
+	}
+
+	if cfg.Parts > s.UsersPerCall {
+		return nil, fmt.Errorf("parts cannot be greater than the number of users per call")
+	}
+
+	if cfg.Part < 0 || cfg.Part >= cfg.Parts {
+		return nil, fmt.Errorf("part should be in the range [0, %d)", cfg.Parts)
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	if cfg.NewParticipant == nil {
+		metrics := cfg.Metrics
+		cfg.NewParticipant = func(cfg client.Config, log *slog.Logger) Participant {
+			opts := []client.Option{client.WithLogger(log)}
+			if metrics != nil {
+				opts = append(opts, client.WithMetrics(metrics))
+			}
+			return client.NewUser(cfg, opts...)
+		}
+	}
+
+	return &Runner{
+		s:              s,
+		cfg:            cfg,
+		adjustInterval: adjustInterval,
+	}, nil
+}
+
+// Run executes the scenario on all calls concurrently. It returns once
+// all phases have completed or stopCh gets closed, and all participants have
+// left.
+func (r *Runner) Run(stopCh <-chan struct{}) {
+	var wg sync.WaitGroup
+	for i := range r.s.Calls {
+		cr := r.newCallRunner(i, r.cfg.Channels[i])
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cr.run(stopCh)
+		}()
+	}
+	wg.Wait()
+}
+
+// share returns the runner's part of n, so that the parts of all runners add
+// up to n.
+func (r *Runner) share(n int) int {
+	return r.offset(n, r.cfg.Part+1) - r.offset(n, r.cfg.Part)
+}
+
+// offset returns where the given part of n starts.
+func (r *Runner) offset(n, part int) int {
+	return n * part / r.cfg.Parts
+}
+
+type user struct {
+	cfg      client.Config
+	behavior Behavior
+	log      *slog.Logger
+
+	// Guarded by callRunner.mut.
+	active  bool
+	leaveCh chan struct{}
+}
+
+type callRunner struct {
+	r   *Runner
+	log *slog.Logger
+
+	mut   sync.Mutex
+	users []*user
+	wg    sync.WaitGroup
+}
+
+func (r *Runner) newCallRunner(idx int, channel *model.Channel) *callRunner {
+	cr := &callRunner{
+		r:     r,
+		log:   r.cfg.Logger.With("channelID", channel.Id),
+		users: make([]*user, r.share(r.s.UsersPerCall)),
+	}
+	first := r.offset(r.s.UsersPerCall, r.cfg.Part)
+
+	var behaviors []Behavior
+	for _, b := range r.s.Behaviors {
+		for range b.Users {
+			behaviors = append(behaviors, b)
+		}
+	}
+
+	for j := range cr.users {
+		// The index of the user in the call.
+		i := first + j
+		username := fmt.Sprintf("%s%d", r.cfg.UserPrefix, r.s.UsersPerCall*idx+i+r.cfg.UserOffset)
+		u := &user{
+			cfg: client.Config{
+				Username:  username,
+				Password:  r.cfg.UserPassword,
+				TeamID:    channel.TeamId,
+				ChannelID: channel.Id,
+				SiteURL:   r.cfg.SiteURL,
+				// Participants are made to leave by the runner.
+				Duration:      r.s.Duration(),
+				ScreenSharing: i == 0 && idx < r.s.ScreenSharing,
+				Recording:     i == 0 && idx < r.s.Recordings,
+				Setup:         r.cfg.Setup,
+				SpeechFile:    r.cfg.SpeechFile,
+				MediaSource:   r.cfg.MediaSource,
+				Sink:          r.cfg.Sink,
+			},
+			log: cr.log.With("username", username),
+		}
+		if i < len(behaviors) {
+			u.behavior = behaviors[i]
+			u.cfg.Unmuted = u.behavior.Unmuted
+			u.cfg.Video = u.behavior.Video
+			if p, ok := r.s.networkProfile(u.behavior.Network); ok {
+				u.cfg.Network = &p
+			}
+		}
+		cr.users[j] = u
+	}
+
+	return cr
+}
+
+func (cr *callRunner) run(stopCh <-chan struct{}) {
+	defer func() {
+		cr.mut.Lock()
+		for _, u := range cr.users {
+			cr.leave(u)
+		}
+		cr.mut.Unlock()
+		cr.wg.Wait()
+	}()
+
+	for _, ph := range cr.r.s.Phases {
+		ph.Target = cr.r.share(ph.Target)
+		ph.Churn = cr.r.share(ph.Churn)
+		cr.log.Info("starting phase", slog.String("name", ph.Name), slog.String("type", string(ph.Type)),
+			slog.Int("target", ph.Target), slog.String("duration", ph.Duration.String()))
+
+		var ok bool
+		switch ph.Type {
+		case PhaseRampUp:
+			ok = cr.rampUp(ph, stopCh)
+		case PhaseSteady, PhaseChurn:
+			ok = cr.hold(ph, stopCh)
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// rampUp joins participants at random times until the phase target is met.
+func (cr *callRunner) rampUp(ph Phase, stopCh <-chan struct{}) bool {
+	dur := time.Duration(ph.Duration)
+	deadline := time.NewTimer(dur)
+	defer deadline.Stop()
+
+	cr.mut.Lock()
+	n := max(ph.Target-cr.numActive(), 0)
+	cr.mut.Unlock()
+
+	offsets := make([]time.Duration, n)
+	for i := range offsets {
+		offsets[i] = time.Duration(rand.Int63n(int64(dur)))
+	}
+	slices.Sort(offsets)
+
+	start := time.Now()
+	for _, offset := range offsets {
+		timer := time.NewTimer(time.Until(start.Add(offset)))
+		select {
+		case <-timer.C:
+		case <-stopCh:
+			timer.Stop()
+			return false
+		}
+
+		cr.mut.Lock()
+		if idle := cr.idleUsers(); len(idle) > 0 {
+			cr.join(idle[0])
+		}
+		cr.mut.Unlock()
+	}
+
+	select {
+	case <-deadline.C:
+		return true
+	case <-stopCh:
+		return false
+	}
+}
+
+// hold keeps the number of participants at the phase target, replacing some
+// of them periodically in churn phases.
+func (cr *callRunner) hold(ph Phase, stopCh <-chan struct{}) bool {
+	deadline := time.NewTimer(time.Duration(ph.Duration))
+	defer deadline.Stop()
+
+	adjustTicker := time.NewTicker(cr.r.adjustInterval)
+	defer adjustTicker.Stop()
+
+	var churnCh <-chan time.Time
+	if ph.Type == PhaseChurn {
+		churnTicker := time.NewTicker(time.Duration(ph.ChurnInterval))
+		defer churnTicker.Stop()
+		churnCh = churnTicker.C
+	}
+
+	cr.adjust(ph.Target)
+
+	for {
+		select {
+		case <-adjustTicker.C:
+			cr.adjust(ph.Target)
+		case <-churnCh:
+			cr.churn(ph.Churn)
+		case <-deadline.C:
+			return true
+		case <-stopCh:
+			return false
+		}
+	}
+}
+
+func (cr *callRunner) adjust(target int) {
+	cr.mut.Lock()
+	defer cr.mut.Unlock()
+
+	n := target - cr.numActive()
+	switch {
+	case n > 0:
+		idle := cr.idleUsers()
+		rand.Shuffle(len(idle), func(i, j int) { idle[i], idle[j] = idle[j], idle[i] })
+		for _, u := range idle[:min(n, len(idle))] {
+			cr.join(u)
+		}
+	case n < 0:
+		active := cr.activeUsers()
+		rand.Shuffle(len(active), func(i, j int) { active[i], active[j] = active[j], active[i] })
+		for _, u := range active[:min(-n, len(active))] {
+			cr.leave(u)
+		}
+	}
+}
+
+func (cr *callRunner) churn(n int) {
+	cr.mut.Lock()
+	defer cr.mut.Unlock()
+
+	active := cr.activeUsers()
+	idle := cr.idleUsers()
+	rand.Shuffle(len(active), func(i, j int) { active[i], active[j] = active[j], active[i] })
+	rand.Shuffle(len(idle), func(i, j int) { idle[i], idle[j] = idle[j], idle[i] })
+
+	n = min(n, len(active), len(idle))
+	cr.log.Debug("churning participants", slog.Int("count", n))
+	for i := range n {
+		cr.leave(active[i])
+		cr.join(idle[i])
+	}
+}
+
+// numActive returns the number of participants in the call, including the
+// ones leaving. It must be called with cr.mut held.
+func (cr *callRunner) numActive() int {
+	var n int
+	for _, u := range cr.users {
+		if u.active {
+			n++
+		}
+	}
+	return n
+}
+
+// activeUsers returns the participants that are in the call and not leaving.
+// It must be called with cr.mut held.
+func (cr *callRunner) activeUsers() []*user {
+	var users []*user
+	for _, u := range cr.users {
+		if u.active && u.leaveCh != nil {
+			users = append(users, u)
+		}
+	}
+	return users
+}
+
+// idleUsers returns the users that are not in the call. It must be called
+// with cr.mut held.
+func (cr *callRunner) idleUsers() []*user {
+	var users []*user
+	for _, u := range cr.users {
+		if !u.active {
+			users = append(users, u)
+		}
+	}
+	return users
+}
+
+// join must be called with cr.mut held.
+func (cr *callRunner) join(u *user) {
+	if u.active {
+		return
+	}
+
+	u.active = true
+	u.leaveCh = make(chan struct{})
+	cr.wg.Add(1)
+	go cr.runUser(u, u.leaveCh)
+}
+
+// leave must be called with cr.mut held.
+func (cr *callRunner) leave(u *user) {
+	if !u.active || u.leaveCh == nil {
+		return
+	}
+
+	close(u.leaveCh)
+	u.leaveCh = nil
+}
+
+func (cr *callRunner) runUser(u *user, leaveCh <-chan struct{}) {
+	defer func() {
+		cr.mut.Lock()
+		u.active = false
+		u.leaveCh = nil
+		cr.mut.Unlock()
+		cr.wg.Done()
+	}()
+
+	cfg := u.cfg
+	for {
+		reconnect, err := cr.connectUser(u, cfg, leaveCh)
+		// Recording should only be started once.
+		cfg.Recording = false
+		if err != nil {
+			u.log.Error("connectUser failed", slog.String("err", err.Error()))
+			var invalidStateErr *rtcerr.InvalidModificationError
+			if errors.As(err, &invalidStateErr) {
+				u.log.Error("invalid state error, re-initializing")
+				continue
+			}
+			return
+		}
+
+		if !reconnect {
+			return
+		}
+		u.log.Debug("reconnecting")
+	}
+}
+
+// connectUser connects a participant to the call and performs its actions
+// until it leaves. It returns whether the participant should connect again.
+func (cr *callRunner) connectUser(u *user, cfg client.Config, leaveCh <-chan struct{}) (bool, error) {
+	p := cr.r.cfg.NewParticipant(cfg, u.log)
+
+	stopCh := make(chan struct{})
+	var stopOnce sync.Once
+	var reconnect atomic.Bool
+	stop := func(rc bool) {
+		stopOnce.Do(func() {
+			reconnect.Store(rc)
+			close(stopCh)
+		})
+	}
+
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	go func() {
+		select {
+		case <-leaveCh:
+			stop(false)
+		case <-doneCh:
+		}
+	}()
+
+	for _, a := range u.behavior.Actions {
+		go cr.runAction(u, p, a, stopCh, stop)
+	}
+
+	err := p.Connect(stopCh)
+	stop(false)
+
+	return reconnect.Load(), err
+}
+
+// runAction repeatedly performs the action at random times, on average once
+// every a.Every, until the participant leaves.
+func (cr *callRunner) runAction(u *user, p Participant, a Action, stopCh <-chan struct{}, stop func(reconnect bool)) {
+	select {
+	case <-p.Connected():
+	case <-stopCh:
+		return
+	}
+
+	muted := !u.behavior.Unmuted
+	var raisedHand bool
+	for {
+		// Uniformly distributed in [every/2, every*3/2).
+		every := int64(a.Every)
+		timer := time.NewTimer(time.Duration(every/2 + rand.Int63n(every)))
+		select {
+		case <-timer.C:
+		case <-stopCh:
+			timer.Stop()
+			return
+		}
+
+		u.log.Debug("performing action", slog.String("type", string(a.Type)))
+
+		var err error
+		switch a.Type {
+		case ActionToggleMute:
+			muted = !muted
+			err = p.SetMuted(muted)
+		case ActionRaiseHand:
+			if raisedHand {
+				err = p.LowerHand()
+			} else {
+				err = p.RaiseHand()
+			}
+			raisedHand = !raisedHand
+		case ActionReact:
+			names := a.Emojis
+			if len(names) == 0 {
+				for name := range emojis {
+					names = append(names, name)
+				}
+			}
+			e := emojis[names[rand.Intn(len(names))]]
+			err = p.React(e.name, e.unified)
+		case ActionReconnect:
+			stop(true)
+			return
+		case ActionLeave:
+			stop(false)
+			return
+		}
+
+		if err != nil {
+			u.log.Error("failed to perform action", slog.String("type", string(a.Type)), slog.String("err", err.Error()))
+			if m := cr.r.cfg.Metrics; m != nil {
+				m.RecordError(metricAction, err)
+			}
+		}
+	}
+}
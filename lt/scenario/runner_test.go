@@ -0,0 +1,322 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package scenario
+
+import (
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/lt/client"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+type fakeParticipant struct {
+	cfg         client.Config
+	t           *testCall
+	connectedCh chan struct{}
+}
+
+func (p *fakeParticipant) Connect(stopCh chan struct{}) error {
+	p.t.update(p.cfg.Username, 1)
+	close(p.connectedCh)
+	<-stopCh
+	p.t.update(p.cfg.Username, -1)
+	return nil
+}
+
+func (p *fakeParticipant) Connected() <-chan struct{} {
+	return p.connectedCh
+}
+
+func (p *fakeParticipant) SetMuted(_ bool) error {
+	p.t.action(ActionToggleMute)
+	return nil
+}
+
+func (p *fakeParticipant) RaiseHand() error {
+	p.t.action(ActionRaiseHand)
+	return nil
+}
+
+func (p *fakeParticipant) LowerHand() error {
+	p.t.action(ActionRaiseHand)
+	return nil
+}
+
+func (p *fakeParticipant) React(_, _ string) error {
+	p.t.action(ActionReact)
+	return nil
+}
+
+// testCall tracks the fake participants connected to the call.
+type testCall struct {
+	mut       sync.Mutex
+	connected map[string]int
+	joins     int
+	actions   map[ActionType]int
+}
+
+func (c *testCall) update(username string, delta int) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.connected[username] += delta
+	if c.connected[username] == 0 {
+		delete(c.connected, username)
+	}
+	if delta > 0 {
+		c.joins++
+	}
+}
+
+func (c *testCall) action(a ActionType) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.actions[a]++
+}
+
+func (c *testCall) numConnected() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return len(c.connected)
+}
+
+func (c *testCall) numJoins() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.joins
+}
+
+func newTestRunner(t *testing.T, s *Scenario) (*Runner, *testCall) {
+	t.Helper()
+
+	call := &testCall{
+		connected: map[string]int{},
+		actions:   map[ActionType]int{},
+	}
+
+	r, err := NewRunner(s, Config{
+		UserPrefix: "testuser-",
+		Channels:   []*model.Channel{{Id: model.NewId(), TeamId: model.NewId()}},
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		NewParticipant: func(cfg client.Config, _ *slog.Logger) Participant {
+			return &fakeParticipant{cfg: cfg, t: call, connectedCh: make(chan struct{})}
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	r.adjustInterval = 10 * time.Millisecond
+
+	return r, call
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRunner(t *testing.T) {
+	t.Run("phases", func(t *testing.T) {
+		r, call := newTestRunner(t, &Scenario{
+			Calls:        1,
+			UsersPerCall: 6,
+			Phases: []Phase{
+				{Type: PhaseRampUp, Duration: Duration(100 * time.Millisecond), Target: 4},
+				{Type: PhaseSteady, Duration: Duration(500 * time.Millisecond), Target: 2},
+			},
+		})
+
+		doneCh := make(chan struct{})
+		go func() {
+			r.Run(make(chan struct{}))
+			close(doneCh)
+		}()
+
+		// Ramp up.
+		waitFor(t, func() bool { return call.numJoins() == 4 })
+
+		// Steady phase lowers the number of participants.
+		waitFor(t, func() bool { return call.numConnected() == 2 })
+
+		<-doneCh
+		if n := call.numConnected(); n != 0 {
+			t.Fatalf("got %d participants connected, want 0", n)
+		}
+		if n := call.numJoins(); n != 4 {
+			t.Fatalf("got %d joins, want 4", n)
+		}
+	})
+
+	t.Run("churn", func(t *testing.T) {
+		r, call := newTestRunner(t, &Scenario{
+			Calls:        1,
+			UsersPerCall: 6,
+			Phases: []Phase{
+				{Type: PhaseChurn, Duration: Duration(300 * time.Millisecond), Target: 3, Churn: 2, ChurnInterval: Duration(50 * time.Millisecond)},
+			},
+		})
+
+		doneCh := make(chan struct{})
+		go func() {
+			r.Run(make(chan struct{}))
+			close(doneCh)
+		}()
+
+		waitFor(t, func() bool { return call.numJoins() > 3 })
+
+		<-doneCh
+		if n := call.numConnected(); n != 0 {
+			t.Fatalf("got %d participants connected, want 0", n)
+		}
+	})
+
+	t.Run("parts", func(t *testing.T) {
+		r, call := newTestRunner(t, &Scenario{
+			Calls:        1,
+			UsersPerCall: 5,
+			Phases: []Phase{
+				{Type: PhaseSteady, Duration: Duration(10 * time.Second), Target: 4},
+			},
+		})
+		r.cfg.Part = 1
+		r.cfg.Parts = 2
+
+		stopCh := make(chan struct{})
+		doneCh := make(chan struct{})
+		go func() {
+			r.Run(stopCh)
+			close(doneCh)
+		}()
+
+		// The second half of the call's users, and of the target.
+		waitFor(t, func() bool { return call.numConnected() == 2 })
+		call.mut.Lock()
+		for username := range call.connected {
+			if username != "testuser-2" && username != "testuser-3" && username != "testuser-4" {
+				t.Errorf("unexpected participant %s", username)
+			}
+		}
+		call.mut.Unlock()
+
+		close(stopCh)
+		<-doneCh
+	})
+
+	t.Run("actions", func(t *testing.T) {
+		r, call := newTestRunner(t, &Scenario{
+			Calls:        1,
+			UsersPerCall: 2,
+			Phases: []Phase{
+				{Type: PhaseSteady, Duration: Duration(10 * time.Second), Target: 2},
+			},
+			Behaviors: []Behavior{
+				{
+					Users: 1,
+					Actions: []Action{
+						{Type: ActionToggleMute, Every: Duration(10 * time.Millisecond)},
+						{Type: ActionRaiseHand, Every: Duration(10 * time.Millisecond)},
+						{Type: ActionReact, Every: Duration(10 * time.Millisecond)},
+					},
+				},
+				{
+					Users:   1,
+					Actions: []Action{{Type: ActionReconnect, Every: Duration(10 * time.Millisecond)}},
+				},
+			},
+		})
+
+		stopCh := make(chan struct{})
+		doneCh := make(chan struct{})
+		go func() {
+			r.Run(stopCh)
+			close(doneCh)
+		}()
+
+		waitFor(t, func() bool {
+			call.mut.Lock()
+			defer call.mut.Unlock()
+			return call.actions[ActionToggleMute] > 1 && call.actions[ActionRaiseHand] > 1 &&
+				call.actions[ActionReact] > 1 && call.joins > 3
+		})
+
+		close(stopCh)
+		select {
+		case <-doneCh:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for runner to stop")
+		}
+		if n := call.numConnected(); n != 0 {
+			t.Fatalf("got %d participants connected, want 0", n)
+		}
+	})
+
+	t.Run("leave", func(t *testing.T) {
+		r, call := newTestRunner(t, &Scenario{
+			Calls:        1,
+			UsersPerCall: 1,
+			Phases: []Phase{
+				{Type: PhaseSteady, Duration: Duration(10 * time.Second), Target: 1},
+			},
+			Behaviors: []Behavior{
+				{Users: 1, Actions: []Action{{Type: ActionLeave, Every: Duration(20 * time.Millisecond)}}},
+			},
+		})
+
+		stopCh := make(chan struct{})
+		doneCh := make(chan struct{})
+		go func() {
+			r.Run(stopCh)
+			close(doneCh)
+		}()
+
+		// The steady phase brings the participant back after it leaves.
+		waitFor(t, func() bool { return call.numJoins() > 2 })
+
+		close(stopCh)
+		<-doneCh
+		if n := call.numConnected(); n != 0 {
+			t.Fatalf("got %d participants connected, want 0", n)
+		}
+	})
+}
+
+func TestRunnerNetworkProfiles(t *testing.T) {
+	r, _ := newTestRunner(t, &Scenario{
+		Calls:        1,
+		UsersPerCall: 3,
+		Phases: []Phase{
+			{Type: PhaseSteady, Duration: Duration(time.Second), Target: 3},
+		},
+		Behaviors: []Behavior{
+			{Users: 1, Network: "custom"},
+			{Users: 1, Network: "lossy"},
+		},
+		NetworkProfiles: map[string]NetworkProfile{
+			"custom": {Loss: 0.05, Delay: Duration(100 * time.Millisecond), BandwidthKbps: 500},
+		},
+	})
+
+	cr := r.newCallRunner(0, r.cfg.Channels[0])
+	want := []*client.NetworkProfile{
+		{Loss: 0.05, Delay: 100 * time.Millisecond, Bandwidth: 500_000},
+		{Loss: 0.1},
+		nil,
+	}
+	for i, u := range cr.users {
+		got := u.cfg.Network
+		if (got == nil) != (want[i] == nil) || (got != nil && *got != *want[i]) {
+			t.Errorf("user %d: got network %+v, want %+v", i, got, want[i])
+		}
+	}
+}
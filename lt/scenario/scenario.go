@@ -0,0 +1,351 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/lt/client"
+
+	"gopkg.in/yaml.v3"
+)
+
+type PhaseType string
+
+const (
+	// PhaseRampUp joins participants at random times during the phase until
+	// the target is reached.
+	PhaseRampUp PhaseType = "ramp_up"
+	// PhaseSteady keeps the number of participants at the target.
+	PhaseSteady PhaseType = "steady"
+	// PhaseChurn keeps the number of participants at the target while
+	// periodically replacing some of them.
+	PhaseChurn PhaseType = "churn"
+)
+
+type ActionType string
+
+const (
+	ActionToggleMute ActionType = "toggle_mute"
+	ActionRaiseHand  ActionType = "raise_hand"
+	ActionReact      ActionType = "react"
+	ActionReconnect  ActionType = "reconnect"
+	ActionLeave      ActionType = "leave"
+)
+
+// Duration is a time.Duration that can be decoded from strings such as "1m30s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration should be a string: %w", err)
+	}
+	return d.parse(s)
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return fmt.Errorf("duration should be a string: %w", err)
+	}
+	return d.parse(s)
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d Duration) MarshalYAML() (any, error) {
+	return d.String(), nil
+}
+
+func (d *Duration) parse(s string) error {
+	dur, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(dur)
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Scenario describes how participants behave during a load-test.
+type Scenario struct {
+	Name string `json:"name" yaml:"name"`
+	// The number of calls to start.
+	Calls int `json:"calls" yaml:"calls"`
+	// The number of users available to each call. Phase targets cannot exceed it.
+	UsersPerCall int `json:"users_per_call" yaml:"users_per_call"`
+	// The number of calls with a participant sharing their screen.
+	ScreenSharing int `json:"screen_sharing" yaml:"screen_sharing"`
+	// The number of calls to record.
+	Recordings int `json:"recordings" yaml:"recordings"`
+	// The phases to execute, in order. All participants leave once the last one ends.
+	Phases []Phase `json:"phases" yaml:"phases"`
+	// Behaviors are assigned to users in order, the users left over
+	// join muted and take no actions.
+	Behaviors []Behavior `json:"behaviors" yaml:"behaviors"`
+	// Network profiles to be referred to by behaviors, in addition to the
+	// built-in ones (see client.NetworkProfiles).
+	NetworkProfiles map[string]NetworkProfile `json:"network_profiles" yaml:"network_profiles"`
+}
+
+type Phase struct {
+	Name     string    `json:"name" yaml:"name"`
+	Type     PhaseType `json:"type" yaml:"type"`
+	Duration Duration  `json:"duration" yaml:"duration"`
+	// The number of participants per call. Defaults to UsersPerCall.
+	Target int `json:"target" yaml:"target"`
+	// Churn only: the number of participants to replace every ChurnInterval.
+	Churn         int      `json:"churn" yaml:"churn"`
+	ChurnInterval Duration `json:"churn_interval" yaml:"churn_interval"`
+}
+
+type Behavior struct {
+	Name string `json:"name" yaml:"name"`
+	// The number of users per call with this behavior.
+	Users   int      `json:"users" yaml:"users"`
+	Unmuted bool     `json:"unmuted" yaml:"unmuted"`
+	Video   bool     `json:"video" yaml:"video"`
+	Actions []Action `json:"actions" yaml:"actions"`
+	// Optional, the name of the network profile simulated for the users.
+	Network string `json:"network" yaml:"network"`
+}
+
+// NetworkProfile is the scenario counterpart of client.NetworkProfile.
+type NetworkProfile struct {
+	// The share of packets dropped, in the range [0, 1].
+	Loss   float64  `json:"loss" yaml:"loss"`
+	Delay  Duration `json:"delay" yaml:"delay"`
+	Jitter Duration `json:"jitter" yaml:"jitter"`
+	// Zero for unlimited.
+	BandwidthKbps int `json:"bandwidth_kbps" yaml:"bandwidth_kbps"`
+}
+
+func (p NetworkProfile) toClient() client.NetworkProfile {
+	return client.NetworkProfile{
+		Loss:      p.Loss,
+		Delay:     time.Duration(p.Delay),
+		Jitter:    time.Duration(p.Jitter),
+		Bandwidth: p.BandwidthKbps * 1000,
+	}
+}
+
+// networkProfile returns the profile with the given name, either defined
+// by the scenario or built-in.
+func (s *Scenario) networkProfile(name string) (client.NetworkProfile, bool) {
+	if p, ok := s.NetworkProfiles[name]; ok {
+		return p.toClient(), true
+	}
+	p, ok := client.NetworkProfiles[name]
+	return p, ok
+}
+
+// Action is performed repeatedly by a participant at random times, on
+// average once every Every.
+type Action struct {
+	Type  ActionType `json:"type" yaml:"type"`
+	Every Duration   `json:"every" yaml:"every"`
+	// React only: the names of the emojis to pick from. Defaults to all the
+	// supported ones.
+	Emojis []string `json:"emojis" yaml:"emojis"`
+}
+
+// Load reads a scenario from a YAML or JSON file, depending on its extension.
+func Load(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		return ParseYAML(data)
+	case ".json":
+		return ParseJSON(data)
+	default:
+		return nil, fmt.Errorf("unsupported scenario file extension %q", ext)
+	}
+}
+
+func ParseYAML(data []byte) (*Scenario, error) {
+	var s Scenario
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("failed to decode scenario: %w", err)
+	}
+
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func ParseJSON(data []byte) (*Scenario, error) {
+	var s Scenario
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return nil, fmt.Errorf("failed to decode scenario: %w", err)
+	}
+
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (s *Scenario) init() error {
+	for i := range s.Phases {
+		if s.Phases[i].Target == 0 {
+			s.Phases[i].Target = s.UsersPerCall
+		}
+	}
+
+	return s.IsValid()
+}
+
+// Duration returns the total duration of the scenario.
+func (s *Scenario) Duration() time.Duration {
+	var total time.Duration
+	for _, ph := range s.Phases {
+		total += time.Duration(ph.Duration)
+	}
+	return total
+}
+
+func (s *Scenario) IsValid() error {
+	if s.Calls <= 0 {
+		return fmt.Errorf("calls should be > 0")
+	}
+
+	if s.UsersPerCall <= 0 {
+		return fmt.Errorf("users_per_call should be > 0")
+	}
+
+	if s.ScreenSharing < 0 || s.ScreenSharing > s.Calls {
+		return fmt.Errorf("screen_sharing should be in the range [0, %d]", s.Calls)
+	}
+
+	if s.Recordings < 0 || s.Recordings > s.Calls {
+		return fmt.Errorf("recordings should be in the range [0, %d]", s.Calls)
+	}
+
+	if len(s.Phases) == 0 {
+		return fmt.Errorf("at least one phase is required")
+	}
+
+	target := 0
+	for i, ph := range s.Phases {
+		if err := ph.isValid(s.UsersPerCall); err != nil {
+			return fmt.Errorf("invalid phase %d: %w", i, err)
+		}
+
+		if ph.Type == PhaseRampUp && ph.Target < target {
+			return fmt.Errorf("invalid phase %d: ramp_up target cannot be lower than the previous one", i)
+		}
+		target = ph.Target
+	}
+
+	for name, p := range s.NetworkProfiles {
+		if err := p.toClient().IsValid(); err != nil {
+			return fmt.Errorf("invalid network profile %q: %w", name, err)
+		}
+	}
+
+	var users int
+	for i, b := range s.Behaviors {
+		if err := b.isValid(); err != nil {
+			return fmt.Errorf("invalid behavior %d: %w", i, err)
+		}
+		if _, ok := s.networkProfile(b.Network); b.Network != "" && !ok {
+			return fmt.Errorf("invalid behavior %d: unknown network profile %q", i, b.Network)
+		}
+		users += b.Users
+	}
+
+	if users > s.UsersPerCall {
+		return fmt.Errorf("behaviors users cannot be greater than users_per_call")
+	}
+
+	return nil
+}
+
+func (ph Phase) isValid(maxUsers int) error {
+	if ph.Duration <= 0 {
+		return fmt.Errorf("duration should be > 0")
+	}
+
+	if ph.Target < 0 || ph.Target > maxUsers {
+		return fmt.Errorf("target should be in the range [0, %d]", maxUsers)
+	}
+
+	switch ph.Type {
+	case PhaseRampUp, PhaseSteady:
+		if ph.Churn != 0 || ph.ChurnInterval != 0 {
+			return fmt.Errorf("churn is only supported in %s phases", PhaseChurn)
+		}
+	case PhaseChurn:
+		if ph.Churn <= 0 || ph.Churn > ph.Target {
+			return fmt.Errorf("churn should be in the range [1, %d]", ph.Target)
+		}
+		if ph.ChurnInterval <= 0 {
+			return fmt.Errorf("churn_interval should be > 0")
+		}
+	default:
+		return fmt.Errorf("invalid type %q", ph.Type)
+	}
+
+	return nil
+}
+
+func (b Behavior) isValid() error {
+	if b.Users <= 0 {
+		return fmt.Errorf("users should be > 0")
+	}
+
+	for i, a := range b.Actions {
+		if err := a.isValid(); err != nil {
+			return fmt.Errorf("invalid action %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func (a Action) isValid() error {
+	switch a.Type {
+	case ActionToggleMute, ActionRaiseHand, ActionReconnect, ActionLeave:
+		if len(a.Emojis) > 0 {
+			return fmt.Errorf("emojis are only supported by %s actions", ActionReact)
+		}
+	case ActionReact:
+		for _, name := range a.Emojis {
+			if _, ok := emojis[name]; !ok {
+				return fmt.Errorf("unsupported emoji %q", name)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid type %q", a.Type)
+	}
+
+	if a.Every <= 0 {
+		return fmt.Errorf("every should be > 0")
+	}
+
+	return nil
+}
@@ -0,0 +1,268 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package scenario
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	s, err := Load("../samples/scenario.yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if s.Calls != 2 || s.UsersPerCall != 20 || len(s.Phases) != 3 || len(s.Behaviors) != 4 {
+		t.Fatalf("unexpected scenario: %+v", s)
+	}
+
+	if got := s.Duration(); got != 11*time.Minute {
+		t.Errorf("got duration %s, want %s", got, 11*time.Minute)
+	}
+
+	churn := s.Phases[2]
+	if churn.Type != PhaseChurn || churn.Churn != 3 || time.Duration(churn.ChurnInterval) != 30*time.Second {
+		t.Errorf("unexpected churn phase: %+v", churn)
+	}
+
+	if _, err := Load("scenario.txt"); err == nil {
+		t.Errorf("expected error for missing file")
+	}
+}
+
+func TestParse(t *testing.T) {
+	yamlData := `
+name: test
+calls: 1
+users_per_call: 4
+phases:
+  - type: ramp_up
+    duration: 10s
+    target: 2
+  - type: steady
+    duration: 1m
+behaviors:
+  - users: 2
+    unmuted: true
+    actions:
+      - type: toggle_mute
+        every: 5s
+`
+
+	jsonData := `{
+  "name": "test",
+  "calls": 1,
+  "users_per_call": 4,
+  "phases": [
+    {"type": "ramp_up", "duration": "10s", "target": 2},
+    {"type": "steady", "duration": "1m"}
+  ],
+  "behaviors": [
+    {"users": 2, "unmuted": true, "actions": [{"type": "toggle_mute", "every": "5s"}]}
+  ]
+}`
+
+	check := func(t *testing.T, s *Scenario) {
+		t.Helper()
+		if s.Phases[0].Target != 2 {
+			t.Errorf("got target %d, want 2", s.Phases[0].Target)
+		}
+		// Target defaults to the users per call.
+		if s.Phases[1].Target != 4 {
+			t.Errorf("got target %d, want 4", s.Phases[1].Target)
+		}
+		if time.Duration(s.Phases[1].Duration) != time.Minute {
+			t.Errorf("got duration %s, want 1m", s.Phases[1].Duration)
+		}
+		if a := s.Behaviors[0].Actions[0]; a.Type != ActionToggleMute || time.Duration(a.Every) != 5*time.Second {
+			t.Errorf("unexpected action: %+v", a)
+		}
+	}
+
+	t.Run("yaml", func(t *testing.T) {
+		s, err := ParseYAML([]byte(yamlData))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		check(t, s)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		s, err := ParseJSON([]byte(jsonData))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		check(t, s)
+	})
+
+	t.Run("json round trip", func(t *testing.T) {
+		s, err := ParseYAML([]byte(yamlData))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		data, err := json.Marshal(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		s, err = ParseJSON(data)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		check(t, s)
+	})
+
+	t.Run("unknown fields", func(t *testing.T) {
+		if _, err := ParseYAML([]byte(yamlData + "unknown: true\n")); err == nil {
+			t.Errorf("expected error")
+		}
+		if _, err := ParseJSON([]byte(strings.Replace(jsonData, `"name"`, `"unknown": true, "name"`, 1))); err == nil {
+			t.Errorf("expected error")
+		}
+	})
+
+	t.Run("invalid duration", func(t *testing.T) {
+		if _, err := ParseYAML([]byte(strings.Replace(yamlData, "10s", "ten seconds", 1))); err == nil {
+			t.Errorf("expected error")
+		}
+	})
+}
+
+func TestScenarioIsValid(t *testing.T) {
+	newScenario := func() *Scenario {
+		return &Scenario{
+			Calls:        1,
+			UsersPerCall: 4,
+			Phases: []Phase{
+				{Type: PhaseRampUp, Duration: Duration(time.Second), Target: 2},
+				{Type: PhaseChurn, Duration: Duration(time.Second), Target: 2, Churn: 1, ChurnInterval: Duration(time.Second)},
+			},
+			Behaviors: []Behavior{
+				{Users: 1, Actions: []Action{{Type: ActionReact, Every: Duration(time.Second), Emojis: []string{"tada"}}}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(s *Scenario)
+		err    string
+	}{
+		{
+			name:   "valid",
+			modify: func(_ *Scenario) {},
+		},
+		{
+			name:   "no calls",
+			modify: func(s *Scenario) { s.Calls = 0 },
+			err:    "calls should be > 0",
+		},
+		{
+			name:   "no users",
+			modify: func(s *Scenario) { s.UsersPerCall = 0 },
+			err:    "users_per_call should be > 0",
+		},
+		{
+			name:   "too many recordings",
+			modify: func(s *Scenario) { s.Recordings = 2 },
+			err:    "recordings should be in the range [0, 1]",
+		},
+		{
+			name:   "no phases",
+			modify: func(s *Scenario) { s.Phases = nil },
+			err:    "at least one phase is required",
+		},
+		{
+			name:   "invalid phase type",
+			modify: func(s *Scenario) { s.Phases[0].Type = "ramp_down" },
+			err:    `invalid phase 0: invalid type "ramp_down"`,
+		},
+		{
+			name:   "target too high",
+			modify: func(s *Scenario) { s.Phases[0].Target = 5 },
+			err:    "invalid phase 0: target should be in the range [0, 4]",
+		},
+		{
+			name: "ramp up lowering target",
+			modify: func(s *Scenario) {
+				s.Phases = append(s.Phases, Phase{Type: PhaseRampUp, Duration: Duration(time.Second), Target: 1})
+			},
+			err: "invalid phase 2: ramp_up target cannot be lower than the previous one",
+		},
+		{
+			name:   "churn in steady phase",
+			modify: func(s *Scenario) { s.Phases[0].Churn = 1 },
+			err:    "invalid phase 0: churn is only supported in churn phases",
+		},
+		{
+			name:   "churn greater than target",
+			modify: func(s *Scenario) { s.Phases[1].Churn = 3 },
+			err:    "invalid phase 1: churn should be in the range [1, 2]",
+		},
+		{
+			name:   "missing churn interval",
+			modify: func(s *Scenario) { s.Phases[1].ChurnInterval = 0 },
+			err:    "invalid phase 1: churn_interval should be > 0",
+		},
+		{
+			name:   "too many behavior users",
+			modify: func(s *Scenario) { s.Behaviors[0].Users = 5 },
+			err:    "behaviors users cannot be greater than users_per_call",
+		},
+		{
+			name:   "invalid action type",
+			modify: func(s *Scenario) { s.Behaviors[0].Actions[0].Type = "dance" },
+			err:    `invalid behavior 0: invalid action 0: invalid type "dance"`,
+		},
+		{
+			name:   "unsupported emoji",
+			modify: func(s *Scenario) { s.Behaviors[0].Actions[0].Emojis = []string{"unknown"} },
+			err:    `invalid behavior 0: invalid action 0: unsupported emoji "unknown"`,
+		},
+		{
+			name:   "missing action interval",
+			modify: func(s *Scenario) { s.Behaviors[0].Actions[0].Every = 0 },
+			err:    "invalid behavior 0: invalid action 0: every should be > 0",
+		},
+		{
+			name: "network profiles",
+			modify: func(s *Scenario) {
+				s.NetworkProfiles = map[string]NetworkProfile{"custom": {Loss: 0.1, BandwidthKbps: 500}}
+				s.Behaviors[0].Network = "custom"
+				s.Behaviors = append(s.Behaviors, Behavior{Users: 1, Network: "bad_wifi"})
+			},
+		},
+		{
+			name:   "unknown network profile",
+			modify: func(s *Scenario) { s.Behaviors[0].Network = "dialup" },
+			err:    `invalid behavior 0: unknown network profile "dialup"`,
+		},
+		{
+			name: "invalid network profile",
+			modify: func(s *Scenario) {
+				s.NetworkProfiles = map[string]NetworkProfile{"custom": {Loss: 2}}
+			},
+			err: `invalid network profile "custom": loss should be in the range [0, 1]`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newScenario()
+			tc.modify(s)
+			err := s.IsValid()
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.err {
+				t.Fatalf("got error %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
@@ -216,6 +216,14 @@ func (p *Plugin) OnActivate() (retErr error) {
 	// Cluster events need to be handled regardless of whether the embedded RTC service or RTCD are in use.
 	go p.clusterEventsHandler()
 
+	go p.gatewayEventsSender()
+
+	go p.inCallStatusUpdater()
+
+	go p.runCaptionsRetentionJob()
+
+	go p.runS3RecordingsRetentionJob()
+
 	// Start historical metrics update job (runs every hour)
 	if p.metrics != nil {
 		p.metricsUpdateTicker = time.NewTicker(1 * time.Hour)
@@ -254,6 +262,12 @@ func (p *Plugin) OnDeactivate() error {
 		}
 	}
 
+	if jobService := p.getJobService(); jobService != nil {
+		if err := jobService.Close(); err != nil {
+			p.LogError(err.Error())
+		}
+	}
+
 	if p.isSingleHandler() {
 		if err := p.cleanUpState(); err != nil {
 			p.LogError(err.Error())
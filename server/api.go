@@ -408,6 +408,12 @@ func (p *Plugin) handlePostCallsChannel(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if err := p.validateChannelTranscriptionProps(channel.Props); err != nil {
+		res.Err = err.Error()
+		res.Code = http.StatusBadRequest
+		return
+	}
+
 	storedChannel, err := p.store.GetCallsChannel(channelID, db.GetCallsChannelOpts{})
 	if err != nil && !errors.Is(err, db.ErrNotFound) {
 		res.Err = fmt.Errorf("failed to get calls channel: %w", err).Error()
@@ -503,11 +509,11 @@ func (p *Plugin) handleConfig(w http.ResponseWriter, r *http.Request) error {
 	w.Header().Set("Content-Type", "application/json")
 
 	if isAdmin {
-		if err := json.NewEncoder(w).Encode(p.getAdminClientConfig(p.getConfiguration())); err != nil {
+		if err := json.NewEncoder(w).Encode(p.getAdminClientConfigResponse(p.getConfiguration())); err != nil {
 			return fmt.Errorf("error encoding config: %w", err)
 		}
 	} else {
-		if err := json.NewEncoder(w).Encode(p.getClientConfig(p.getConfiguration())); err != nil {
+		if err := json.NewEncoder(w).Encode(p.getClientConfigResponse(p.getConfiguration())); err != nil {
 			return fmt.Errorf("error encoding config: %w", err)
 		}
 	}
@@ -27,6 +27,26 @@ func (p *Plugin) newAPIRouter() *mux.Router {
 	}
 	standaloneRoute := router.PathPrefix("/standalone/").HandlerFunc(p.handleServeStandalone).Methods("GET")
 
+	// SIP gateway (authenticated through its own token)
+	gatewayRouter := router.PathPrefix("/gateway").Subrouter()
+	gatewayRouter.Use(p.gatewayAuthMiddleware)
+	gatewayRouter.HandleFunc("/calls/{channel_id:[a-z0-9]{26}}/sessions", p.handleGatewayJoin).Methods("POST")
+	gatewayRouter.HandleFunc("/calls/{channel_id:[a-z0-9]{26}}/sessions/{session_id:[a-z0-9]{26}}", p.handleGatewayLeave).Methods("DELETE")
+	gatewayRouter.HandleFunc("/calls/{channel_id:[a-z0-9]{26}}/sessions/{session_id:[a-z0-9]{26}}/messages", p.handleGatewayMessage).Methods("POST")
+
+	// Guests (authenticated through invite tokens)
+	guestRouter := router.PathPrefix("/guest").Subrouter()
+	guestRouter.Use(p.guestInvitesEnabledMiddleware)
+	guestRouter.HandleFunc("/join", p.handleGuestJoin).Methods("POST")
+	guestSessionRouter := guestRouter.PathPrefix("/calls/{channel_id:[a-z0-9]{26}}/sessions/{session_id:[a-z0-9]{26}}").Subrouter()
+	guestSessionRouter.Use(p.guestSessionAuthMiddleware)
+	guestSessionRouter.HandleFunc("", p.handleGuestLeave).Methods("DELETE")
+	guestSessionRouter.HandleFunc("/messages", p.handleGuestMessage).Methods("POST")
+	guestSessionRouter.HandleFunc("/events", p.handleGuestEvents).Methods("GET")
+
+	// Calendar feeds (authenticated through the secret in the URL)
+	calendarFeedRoute := router.HandleFunc("/calendar/feed/{user_id:[a-z0-9]{26}}/{secret:[a-z0-9]+}.ics", p.handleGetCalendarFeed).Methods("GET")
+
 	// Authenticated API handlers (user session required)
 
 	// Auth middleware
@@ -47,6 +67,21 @@ func (p *Plugin) newAPIRouter() *mux.Router {
 				return
 			}
 
+			if gatewayRouter.Match(r, &mux.RouteMatch{}) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if guestRouter.Match(r, &mux.RouteMatch{}) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if calendarFeedRoute.Match(r, &mux.RouteMatch{}) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			if userID := r.Header.Get("Mattermost-User-Id"); userID != "" {
 				next.ServeHTTP(w, r)
 				return
@@ -106,10 +141,24 @@ func (p *Plugin) newAPIRouter() *mux.Router {
 	router.HandleFunc("/calls/{channel_id:[a-z0-9]{26}}/dismiss-notification", p.handleDismissNotification).Methods("POST")
 	router.HandleFunc("/calls/{call_id:[a-z0-9]{26}}/recording/{action}", p.handleRecordingAction).Methods("POST")
 	router.HandleFunc("/calls/{channel_id:[a-z0-9]{26}}/active", p.handleGetCallActive).Methods("GET")
+	router.HandleFunc("/calls/{channel_id:[a-z0-9]{26}}/ring", p.handleRingUsers).Methods("POST")
+	router.HandleFunc("/calls/{channel_id:[a-z0-9]{26}}/ring/{action:accept|decline}", p.handleCallInviteResponse).Methods("POST")
+	router.HandleFunc("/calls/{channel_id:[a-z0-9]{26}}/call-back", p.handleCallBack).Methods("POST")
+	router.HandleFunc("/calls/{call_id:[a-z0-9]{26}}/captions", p.handleGetCallCaptions).Methods("GET")
+	router.HandleFunc("/calls/{call_id:[a-z0-9]{26}}/recordings/{file_id:[a-z0-9]{26}}/link", p.handleGetRecordingLink).Methods("GET")
 
 	// Deprecated for hostCtrlRounder /end, but needed for mobile backward compatibility (pre 2.18)
 	router.HandleFunc("/calls/{call_id:[a-z0-9]{26}}/end", p.handleEnd).Methods("POST")
 
+	// Call preferences
+	router.HandleFunc("/preferences", p.handleGetCallPreferences).Methods("GET")
+	router.HandleFunc("/preferences", p.handleUpdateCallPreferences).Methods("POST")
+
+	// Scheduled calls
+	router.HandleFunc("/calls/{channel_id:[a-z0-9]{26}}/scheduled/import", p.handleImportScheduledCall).Methods("POST")
+	router.HandleFunc("/calendar/feed", p.handleCreateCalendarFeed).Methods("POST")
+	router.HandleFunc("/calendar/feed", p.handleRevokeCalendarFeed).Methods("DELETE")
+
 	// Logs
 	router.HandleFunc("/logs/upload", p.handleUploadLogsToBot).Methods("POST")
 
@@ -120,9 +169,19 @@ func (p *Plugin) newAPIRouter() *mux.Router {
 	hostCtrlRouter.HandleFunc("/screen-off", p.handleScreenOff).Methods("POST")
 	hostCtrlRouter.HandleFunc("/lower-hand", p.handleLowerHand).Methods("POST")
 	hostCtrlRouter.HandleFunc("/remove", p.handleRemoveSession).Methods("POST")
+	hostCtrlRouter.HandleFunc("/spotlight", p.handleSpotlight).Methods("POST")
+	hostCtrlRouter.HandleFunc("/spotlight-off", p.handleSpotlightOff).Methods("POST")
+	hostCtrlRouter.HandleFunc("/webinar", p.handleWebinar).Methods("POST")
+	hostCtrlRouter.HandleFunc("/presenter", p.handlePresenter).Methods("POST")
+	hostCtrlRouter.HandleFunc("/presenter-off", p.handlePresenterOff).Methods("POST")
 	hostCtrlRouter.HandleFunc("/mute-others", p.handleMuteOthers).Methods("POST")
 	hostCtrlRouter.HandleFunc("/end", p.handleEnd).Methods("POST")
 
+	// Guest invites
+	router.HandleFunc("/calls/{call_id:[a-z0-9]{26}}/guest-invites", p.handleCreateGuestInvite).Methods("POST")
+	router.HandleFunc("/calls/{call_id:[a-z0-9]{26}}/guest-invites", p.handleGetGuestInvites).Methods("GET")
+	router.HandleFunc("/calls/{call_id:[a-z0-9]{26}}/guest-invites/{invite_id:[a-z0-9]{26}}", p.handleRevokeGuestInvite).Methods("DELETE")
+
 	// Bot
 	botRouter := router.PathPrefix("/bot").Subrouter()
 	botRouter.Use(func(next http.Handler) http.Handler {
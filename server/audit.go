@@ -7,6 +7,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+
+	"github.com/mattermost/mattermost/server/public/model"
 )
 
 // httpResponse holds data returned to API clients.
@@ -52,12 +54,29 @@ func (p *Plugin) httpAudit(handler string, res *httpResponse, w http.ResponseWri
 	p.LogDebug(handler, logFields...)
 }
 
+// auditRedactedHeaders holds the headers carrying credentials of external
+// clients, which shouldn't end up in logs.
+var auditRedactedHeaders = []string{guestTokenHeader, gatewayTokenHeader}
+
 func reqAuditFields(req *http.Request) []interface{} {
+	header := req.Header
+	for _, name := range auditRedactedHeaders {
+		if req.Header.Get(name) != "" {
+			header = req.Header.Clone()
+			break
+		}
+	}
+	for _, name := range auditRedactedHeaders {
+		if header.Get(name) != "" {
+			header.Set(name, model.FakeSetting)
+		}
+	}
+
 	fields := []interface{}{
 		"remoteAddr", req.RemoteAddr,
 		"method", req.Method,
 		"url", req.URL.String(),
-		"header", fmt.Sprintf("%+v", req.Header),
+		"header", fmt.Sprintf("%+v", header),
 		"host", req.Host,
 	}
 	return fields
@@ -0,0 +1,34 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReqAuditFields(t *testing.T) {
+	r := httptest.NewRequest("POST", "/guest/join", nil)
+	r.Header.Set(guestTokenHeader, "guestSecret")
+	r.Header.Set(gatewayTokenHeader, "gatewaySecret")
+	r.Header.Set("User-Agent", "test")
+
+	fields := reqAuditFields(r)
+	require.Len(t, fields, 10)
+	require.Equal(t, "header", fields[6])
+
+	header := fields[7].(string)
+	require.NotContains(t, header, "guestSecret")
+	require.NotContains(t, header, "gatewaySecret")
+	require.Contains(t, header, model.FakeSetting)
+	require.Contains(t, header, "test")
+
+	// The request itself is left untouched.
+	require.Equal(t, "guestSecret", r.Header.Get(guestTokenHeader))
+	require.Equal(t, "gatewaySecret", r.Header.Get(gatewayTokenHeader))
+}
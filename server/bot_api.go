@@ -6,6 +6,7 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -67,6 +68,22 @@ func (p *Plugin) handleBotGetUpload(w http.ResponseWriter, r *http.Request) {
 
 	uploadID := mux.Vars(r)["upload_id"]
 
+	if cfg := p.getConfiguration(); cfg.recordingsS3StorageEnabled() {
+		u, err := p.getS3Upload(uploadID)
+		if err != nil {
+			res.Err = err.Error()
+			res.Code = http.StatusInternalServerError
+			return
+		}
+		if u != nil {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(u.toUploadSession(p.getBotID())); err != nil {
+				p.LogError(err.Error())
+			}
+			return
+		}
+	}
+
 	us, err := p.API.GetUploadSession(uploadID)
 	if err != nil {
 		res.Err = err.Error()
@@ -91,6 +108,19 @@ func (p *Plugin) handleBotUploadData(w http.ResponseWriter, r *http.Request) {
 
 	uploadID := mux.Vars(r)["upload_id"]
 
+	if cfg := p.getConfiguration(); cfg.recordingsS3StorageEnabled() {
+		u, err := p.getS3Upload(uploadID)
+		if err != nil {
+			res.Err = err.Error()
+			res.Code = http.StatusInternalServerError
+			return
+		}
+		if u != nil {
+			p.handleBotUploadS3Data(w, r, u, &res)
+			return
+		}
+	}
+
 	us, err := p.API.GetUploadSession(uploadID)
 	if err != nil {
 		res.Err = err.Error()
@@ -149,6 +179,40 @@ func (p *Plugin) handleBotUploadData(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (p *Plugin) handleBotUploadS3Data(w http.ResponseWriter, r *http.Request, u *s3Upload, res *httpResponse) {
+	// Same as for regular uploads, the size of a single request is bounded by
+	// FileSettings.MaxFileSize.
+	serverCfg := p.API.GetConfig()
+	if serverCfg == nil {
+		res.Err = "failed to get server configuration"
+		res.Code = http.StatusInternalServerError
+		return
+	}
+
+	fi, err := p.uploadS3Data(u, http.MaxBytesReader(w, r.Body, *serverCfg.FileSettings.MaxFileSize))
+	if err != nil {
+		res.Err = err.Error()
+		var maxBytesErr *http.MaxBytesError
+		if errors.Is(err, errS3UploadSizeExceeded) || errors.As(err, &maxBytesErr) {
+			res.Code = http.StatusBadRequest
+		} else {
+			res.Code = http.StatusInternalServerError
+		}
+		return
+	}
+
+	// Upload is incomplete.
+	if fi == nil {
+		res.Code = http.StatusNoContent
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(fi); err != nil {
+		p.LogError(err.Error())
+	}
+}
+
 func (p *Plugin) handleBotCreateUpload(w http.ResponseWriter, r *http.Request) {
 	var res httpResponse
 	defer p.httpAudit("handleBotCreateUpload", &res, w, r)
@@ -160,6 +224,22 @@ func (p *Plugin) handleBotCreateUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Recordings can optionally be stored in an external S3 compatible bucket.
+	if cfg := p.getConfiguration(); cfg.recordingsS3StorageEnabled() && isRecordingFile(us.Filename) {
+		u, err := p.createS3Upload(us)
+		if err != nil {
+			res.Err = "failed to create S3 upload: " + err.Error()
+			res.Code = http.StatusInternalServerError
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(u.toUploadSession(p.getBotID())); err != nil {
+			p.LogError(err.Error())
+		}
+		return
+	}
+
 	us.Id = model.NewId()
 	us.Type = model.UploadTypeAttachment
 	us.UserId = p.getBotID()
@@ -249,6 +329,21 @@ func (p *Plugin) handleBotPostRecordings(w http.ResponseWriter, r *http.Request)
 	recPost.AddProp("recording_id", info.JobID)
 	recPost.AddProp("call_post_id", info.PostID)
 
+	// Recordings stored in S3 can't be attached to the post. Clients need to
+	// request a signed link to download them instead.
+	if cfg := p.getConfiguration(); cfg.recordingsS3StorageEnabled() {
+		rec, err := p.getS3Recording(info.FileIDs[0])
+		if err != nil {
+			res.Err = "failed to get S3 recording: " + err.Error()
+			res.Code = http.StatusInternalServerError
+			return
+		}
+		if rec != nil {
+			recPost.FileIds = nil
+			recPost.AddProp("recording_file", rec.toPostProp())
+		}
+	}
+
 	recPost, appErr := p.API.CreatePost(recPost)
 	if appErr != nil {
 		res.Err = "failed to create post: " + appErr.Error()
@@ -415,6 +510,12 @@ func (p *Plugin) handleBotPostTranscriptions(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	// Summarizing can take a while so we do it in the background. The
+	// status is tracked in the transcription job's props.
+	if summarizer := p.getSummarizer(); summarizer != nil {
+		go p.summarizeTranscription(summarizer, callID, threadID, info.PostID, info.JobID, info.Transcriptions[0].FileIDs[1])
+	}
+
 	res.Code = http.StatusOK
 	res.Msg = "success"
 }
@@ -0,0 +1,291 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	callPreferencesKeyPrefix  = "call_prefs_"
+	callPreferencesMaxMuted   = 100
+	callPreferencesTimeLayout = "15:04"
+)
+
+var errCallPreferencesInvalid = errors.New("invalid call preferences")
+
+// dndSchedule is a daily do-not-disturb window expressed in the user's
+// timezone. Windows can span midnight (e.g. 22:00 to 07:00).
+type dndSchedule struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+func (s *dndSchedule) IsValid() error {
+	start, err := time.Parse(callPreferencesTimeLayout, s.Start)
+	if err != nil {
+		return fmt.Errorf("invalid start time %q: should be in HH:MM format", s.Start)
+	}
+	end, err := time.Parse(callPreferencesTimeLayout, s.End)
+	if err != nil {
+		return fmt.Errorf("invalid end time %q: should be in HH:MM format", s.End)
+	}
+	if start.Equal(end) {
+		return fmt.Errorf("start and end times should be different")
+	}
+	return nil
+}
+
+// isActive returns whether the given time falls within the schedule. The
+// schedule is assumed to be valid.
+func (s *dndSchedule) isActive(t time.Time) bool {
+	start, _ := time.Parse(callPreferencesTimeLayout, s.Start)
+	end, _ := time.Parse(callPreferencesTimeLayout, s.End)
+
+	startMin := start.Hour()*60 + start.Minute()
+	endMin := end.Hour()*60 + end.Minute()
+	nowMin := t.Hour()*60 + t.Minute()
+
+	if startMin < endMin {
+		return nowMin >= startMin && nowMin < endMin
+	}
+	return nowMin >= startMin || nowMin < endMin
+}
+
+// callPreferences are the per-user settings controlling how the user gets
+// notified about calls.
+type callPreferences struct {
+	// Ring controls whether the user should get rung for incoming calls.
+	Ring bool `json:"ring"`
+	// RingDMsOnly restricts ringing to direct message calls.
+	RingDMsOnly bool `json:"ring_dms_only"`
+	// DND, if set, suppresses all call notifications during the window.
+	DND *dndSchedule `json:"dnd,omitempty"`
+	// MutedChannels lists the channels for which call start notifications
+	// should be suppressed.
+	MutedChannels []string `json:"muted_channels"`
+	// InCallStatus controls whether a custom status should be set while the
+	// user is in a call.
+	InCallStatus bool `json:"in_call_status"`
+}
+
+func newCallPreferences() *callPreferences {
+	return &callPreferences{
+		Ring:          true,
+		MutedChannels: []string{},
+	}
+}
+
+func (cp *callPreferences) IsValid() error {
+	if cp.DND != nil {
+		if err := cp.DND.IsValid(); err != nil {
+			return fmt.Errorf("%w: %w", errCallPreferencesInvalid, err)
+		}
+	}
+
+	if len(cp.MutedChannels) > callPreferencesMaxMuted {
+		return fmt.Errorf("%w: too many muted channels (max %d)", errCallPreferencesInvalid, callPreferencesMaxMuted)
+	}
+	for _, channelID := range cp.MutedChannels {
+		if !model.IsValidId(channelID) {
+			return fmt.Errorf("%w: invalid channel id %q", errCallPreferencesInvalid, channelID)
+		}
+	}
+
+	return nil
+}
+
+// allowsNotification returns whether the user should be notified about a call
+// in the given channel at the given time (in the user's timezone).
+func (cp *callPreferences) allowsNotification(channelID string, t time.Time) bool {
+	if slices.Contains(cp.MutedChannels, channelID) {
+		return false
+	}
+	return cp.DND == nil || !cp.DND.isActive(t)
+}
+
+// allowsRinging returns whether the user should get rung for a call in the
+// given channel at the given time (in the user's timezone).
+func (cp *callPreferences) allowsRinging(channel *model.Channel, t time.Time) bool {
+	if !cp.Ring || (cp.RingDMsOnly && channel.Type != model.ChannelTypeDirect) {
+		return false
+	}
+	return cp.allowsNotification(channel.Id, t)
+}
+
+func (cp *callPreferences) String() string {
+	onOff := func(v bool) string {
+		if v {
+			return "on"
+		}
+		return "off"
+	}
+
+	dnd := "off"
+	if cp.DND != nil {
+		dnd = cp.DND.Start + "-" + cp.DND.End
+	}
+
+	muted := "none"
+	if len(cp.MutedChannels) > 0 {
+		muted = fmt.Sprintf("%d", len(cp.MutedChannels))
+	}
+
+	return fmt.Sprintf("Ringing: %s\nRing for DMs only: %s\nDo not disturb: %s\nMuted channels: %s\nIn call status: %s",
+		onOff(cp.Ring), onOff(cp.RingDMsOnly), dnd, muted, onOff(cp.InCallStatus))
+}
+
+func callPreferencesKey(userID string) string {
+	return callPreferencesKeyPrefix + userID
+}
+
+// getCallPreferences returns the call preferences for the given user,
+// falling back to defaults if none were stored.
+func (p *Plugin) getCallPreferences(userID string) (*callPreferences, error) {
+	prefs := newCallPreferences()
+	if _, err := p.kvGetJSON(callPreferencesKey(userID), prefs); err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+func (p *Plugin) saveCallPreferences(userID string, prefs *callPreferences) error {
+	if err := prefs.IsValid(); err != nil {
+		return err
+	}
+	return p.kvSetJSON(callPreferencesKey(userID), prefs, 0)
+}
+
+// userAllowsCallNotification checks the user's preferences to decide whether
+// they should be notified about a call starting in the given channel. Errors
+// are logged and treated as allowing notifications.
+func (p *Plugin) userAllowsCallNotification(user *model.User, channelID string) bool {
+	prefs, err := p.getCallPreferences(user.Id)
+	if err != nil {
+		p.LogError("failed to get call preferences", "userID", user.Id, "err", err.Error())
+		return true
+	}
+	return prefs.allowsNotification(channelID, time.Now().In(user.GetTimezoneLocation()))
+}
+
+// userAllowsRinging checks the user's preferences to decide whether they
+// should get rung for a call in the given channel. Errors are logged and
+// treated as allowing ringing.
+func (p *Plugin) userAllowsRinging(user *model.User, channel *model.Channel) bool {
+	prefs, err := p.getCallPreferences(user.Id)
+	if err != nil {
+		p.LogError("failed to get call preferences", "userID", user.Id, "err", err.Error())
+		return true
+	}
+	return prefs.allowsRinging(channel, time.Now().In(user.GetTimezoneLocation()))
+}
+
+func (p *Plugin) handleGetCallPreferences(w http.ResponseWriter, r *http.Request) {
+	var res httpResponse
+	defer p.httpAudit("handleGetCallPreferences", &res, w, r)
+
+	userID := r.Header.Get("Mattermost-User-Id")
+
+	prefs, err := p.getCallPreferences(userID)
+	if err != nil {
+		res.Err = err.Error()
+		res.Code = http.StatusInternalServerError
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(prefs); err != nil {
+		p.LogError(err.Error())
+	}
+}
+
+func (p *Plugin) handleUpdateCallPreferences(w http.ResponseWriter, r *http.Request) {
+	var res httpResponse
+	defer p.httpAudit("handleUpdateCallPreferences", &res, w, r)
+
+	userID := r.Header.Get("Mattermost-User-Id")
+
+	prefs := newCallPreferences()
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, requestBodyMaxSizeBytes)).Decode(prefs); err != nil {
+		res.Err = err.Error()
+		res.Code = http.StatusBadRequest
+		return
+	}
+
+	if err := p.saveCallPreferences(userID, prefs); errors.Is(err, errCallPreferencesInvalid) {
+		res.Err = err.Error()
+		res.Code = http.StatusBadRequest
+		return
+	} else if err != nil {
+		res.Err = err.Error()
+		res.Code = http.StatusInternalServerError
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(prefs); err != nil {
+		p.LogError(err.Error())
+	}
+}
+
+// applyCallPreferencesCommand updates the given preferences according to
+// the settings subcommand arguments (e.g. "ring off", "dnd 22:00-07:00").
+func applyCallPreferencesCommand(prefs *callPreferences, channelID string, args []string) error {
+	parseOnOff := func(args []string) (bool, error) {
+		if len(args) != 2 {
+			return false, fmt.Errorf("invalid number of arguments provided")
+		}
+		switch args[1] {
+		case "on":
+			return true, nil
+		case "off":
+			return false, nil
+		default:
+			return false, fmt.Errorf("invalid value %q: should be on or off", args[1])
+		}
+	}
+
+	var err error
+	switch args[0] {
+	case "ring":
+		prefs.Ring, err = parseOnOff(args)
+	case "dms-only":
+		prefs.RingDMsOnly, err = parseOnOff(args)
+	case "status":
+		prefs.InCallStatus, err = parseOnOff(args)
+	case "dnd":
+		if len(args) != 2 {
+			return fmt.Errorf("invalid number of arguments provided")
+		}
+		if args[1] == "off" {
+			prefs.DND = nil
+			return nil
+		}
+		start, end, ok := strings.Cut(args[1], "-")
+		if !ok {
+			return fmt.Errorf("invalid schedule %q: should be in HH:MM-HH:MM format", args[1])
+		}
+		prefs.DND = &dndSchedule{Start: start, End: end}
+	case "mute":
+		if !slices.Contains(prefs.MutedChannels, channelID) {
+			prefs.MutedChannels = append(prefs.MutedChannels, channelID)
+		}
+	case "unmute":
+		prefs.MutedChannels = slices.DeleteFunc(prefs.MutedChannels, func(id string) bool {
+			return id == channelID
+		})
+	default:
+		return fmt.Errorf("invalid setting %q", args[0])
+	}
+
+	return err
+}
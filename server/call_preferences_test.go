@@ -0,0 +1,182 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	pluginMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNDSchedule(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, s := range []dndSchedule{
+			{},
+			{Start: "22:00"},
+			{Start: "25:00", End: "07:00"},
+			{Start: "22:00", End: "7"},
+			{Start: "22:00", End: "22:00"},
+		} {
+			require.Error(t, s.IsValid(), s)
+		}
+	})
+
+	t.Run("same day", func(t *testing.T) {
+		s := dndSchedule{Start: "09:00", End: "17:30"}
+		require.NoError(t, s.IsValid())
+		require.False(t, s.isActive(at(8, 59)))
+		require.True(t, s.isActive(at(9, 0)))
+		require.True(t, s.isActive(at(17, 29)))
+		require.False(t, s.isActive(at(17, 30)))
+	})
+
+	t.Run("overnight", func(t *testing.T) {
+		s := dndSchedule{Start: "22:00", End: "07:00"}
+		require.NoError(t, s.IsValid())
+		require.False(t, s.isActive(at(21, 59)))
+		require.True(t, s.isActive(at(22, 0)))
+		require.True(t, s.isActive(at(3, 0)))
+		require.False(t, s.isActive(at(7, 0)))
+		require.False(t, s.isActive(at(12, 0)))
+	})
+}
+
+func TestCallPreferences(t *testing.T) {
+	now := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+	dm := &model.Channel{Id: model.NewId(), Type: model.ChannelTypeDirect}
+	gm := &model.Channel{Id: model.NewId(), Type: model.ChannelTypeGroup}
+
+	t.Run("defaults", func(t *testing.T) {
+		prefs := newCallPreferences()
+		require.NoError(t, prefs.IsValid())
+		require.True(t, prefs.allowsRinging(dm, now))
+		require.True(t, prefs.allowsRinging(gm, now))
+		require.True(t, prefs.allowsNotification(gm.Id, now))
+	})
+
+	t.Run("ringing off", func(t *testing.T) {
+		prefs := newCallPreferences()
+		prefs.Ring = false
+		require.False(t, prefs.allowsRinging(dm, now))
+		require.True(t, prefs.allowsNotification(dm.Id, now))
+	})
+
+	t.Run("DMs only", func(t *testing.T) {
+		prefs := newCallPreferences()
+		prefs.RingDMsOnly = true
+		require.True(t, prefs.allowsRinging(dm, now))
+		require.False(t, prefs.allowsRinging(gm, now))
+	})
+
+	t.Run("muted channel", func(t *testing.T) {
+		prefs := newCallPreferences()
+		prefs.MutedChannels = []string{gm.Id}
+		require.NoError(t, prefs.IsValid())
+		require.True(t, prefs.allowsRinging(dm, now))
+		require.False(t, prefs.allowsRinging(gm, now))
+		require.False(t, prefs.allowsNotification(gm.Id, now))
+	})
+
+	t.Run("dnd", func(t *testing.T) {
+		prefs := newCallPreferences()
+		prefs.DND = &dndSchedule{Start: "22:00", End: "07:00"}
+		require.NoError(t, prefs.IsValid())
+		require.False(t, prefs.allowsRinging(dm, now))
+		require.False(t, prefs.allowsNotification(dm.Id, now))
+		require.True(t, prefs.allowsRinging(dm, now.Add(-2*time.Hour)))
+	})
+
+	t.Run("invalid muted channels", func(t *testing.T) {
+		prefs := newCallPreferences()
+		prefs.MutedChannels = []string{"invalid"}
+		require.ErrorIs(t, prefs.IsValid(), errCallPreferencesInvalid)
+
+		prefs.MutedChannels = make([]string, callPreferencesMaxMuted+1)
+		for i := range prefs.MutedChannels {
+			prefs.MutedChannels[i] = model.NewId()
+		}
+		require.ErrorIs(t, prefs.IsValid(), errCallPreferencesInvalid)
+	})
+}
+
+func TestApplyCallPreferencesCommand(t *testing.T) {
+	channelID := model.NewId()
+	prefs := newCallPreferences()
+
+	require.NoError(t, applyCallPreferencesCommand(prefs, channelID, []string{"ring", "off"}))
+	require.False(t, prefs.Ring)
+
+	require.NoError(t, applyCallPreferencesCommand(prefs, channelID, []string{"dms-only", "on"}))
+	require.True(t, prefs.RingDMsOnly)
+
+	require.NoError(t, applyCallPreferencesCommand(prefs, channelID, []string{"status", "on"}))
+	require.True(t, prefs.InCallStatus)
+
+	require.NoError(t, applyCallPreferencesCommand(prefs, channelID, []string{"dnd", "22:00-07:00"}))
+	require.Equal(t, &dndSchedule{Start: "22:00", End: "07:00"}, prefs.DND)
+
+	require.NoError(t, applyCallPreferencesCommand(prefs, channelID, []string{"dnd", "off"}))
+	require.Nil(t, prefs.DND)
+
+	require.NoError(t, applyCallPreferencesCommand(prefs, channelID, []string{"mute"}))
+	require.NoError(t, applyCallPreferencesCommand(prefs, channelID, []string{"mute"}))
+	require.Equal(t, []string{channelID}, prefs.MutedChannels)
+
+	require.NoError(t, applyCallPreferencesCommand(prefs, channelID, []string{"unmute"}))
+	require.Empty(t, prefs.MutedChannels)
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"ring"},
+			{"ring", "maybe"},
+			{"dnd", "22:00"},
+			{"unknown"},
+		} {
+			require.Error(t, applyCallPreferencesCommand(prefs, channelID, args), args)
+		}
+	})
+}
+
+func TestGetCallPreferences(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	defer mockAPI.AssertExpectations(t)
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+	}
+
+	userID := model.NewId()
+
+	t.Run("defaults", func(t *testing.T) {
+		mockAPI.On("KVGet", callPreferencesKey(userID)).Return(nil, nil).Once()
+		prefs, err := p.getCallPreferences(userID)
+		require.NoError(t, err)
+		require.Equal(t, newCallPreferences(), prefs)
+	})
+
+	t.Run("stored", func(t *testing.T) {
+		stored := newCallPreferences()
+		stored.RingDMsOnly = true
+		data, err := json.Marshal(stored)
+		require.NoError(t, err)
+
+		mockAPI.On("KVGet", callPreferencesKey(userID)).Return(data, nil).Once()
+		prefs, err := p.getCallPreferences(userID)
+		require.NoError(t, err)
+		require.Equal(t, stored, prefs)
+	})
+}
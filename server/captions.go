@@ -0,0 +1,366 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/batching"
+	"github.com/mattermost/mattermost-plugin-calls/server/db"
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	"github.com/gorilla/mux"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	captionsBatchingInterval = time.Second
+	captionsBatchMaxSize     = 256
+	captionsFlushTimeout     = 5 * time.Second
+	// The number of most recent captions sent as part of the call state on join.
+	recentCaptionsLimit = 50
+	// How long to wait after a call ends before generating the captions
+	// transcript, to give batchers (possibly on other nodes) a chance to flush
+	// any pending captions.
+	captionsTranscriptDelay = 3 * captionsBatchingInterval
+	// How long to wait for a transcription job that's still being processed
+	// before falling back to the captions transcript.
+	captionsTranscriptMaxWait      = time.Hour
+	captionsTranscriptPollInterval = time.Minute
+	// How long captions are kept at most. They are normally deleted as soon as
+	// the call ends and the transcript has been handled.
+	captionsRetention         = 7 * 24 * time.Hour
+	captionsRetentionInterval = time.Hour
+)
+
+// storeCaption queues the given caption to be persisted. Captions are written
+// in batches to avoid hitting the database for every single one.
+func (p *Plugin) storeCaption(caption *public.CallCaption) error {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if p.captionsBatchers == nil {
+		p.captionsBatchers = map[string]*batching.Batcher{}
+	}
+
+	callID := caption.CallID
+	batcher := p.captionsBatchers[callID]
+	if batcher == nil {
+		p.LogDebug("creating new captionsBatcher for call", "callID", callID)
+
+		var err error
+		batcher, err = newBatcher(batching.Config{
+			Interval: captionsBatchingInterval,
+			Size:     captionsBatchMaxSize,
+			PreRunCb: func(ctx batching.Context) error {
+				ctx["captions"] = make([]*public.CallCaption, 0, ctx[batching.ContextBatchSizeKey].(int))
+				return nil
+			},
+			PostRunCb: func(ctx batching.Context) error {
+				captions := ctx["captions"].([]*public.CallCaption)
+				if err := p.store.CreateCallCaptions(captions); err != nil {
+					p.LogError("failed to store captions", "callID", callID, "err", err.Error())
+					return err
+				}
+				return nil
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create batcher: %w", err)
+		}
+		p.captionsBatchers[callID] = batcher
+		batcher.Start()
+	}
+
+	if err := batcher.Push(func(ctx batching.Context) {
+		ctx["captions"] = append(ctx["captions"].([]*public.CallCaption), caption)
+	}); err != nil {
+		return fmt.Errorf("failed to push to batcher: %w", err)
+	}
+
+	return nil
+}
+
+// stopCaptionsBatcher flushes any pending captions for the given call and
+// stops the associated batcher, if any.
+func (p *Plugin) stopCaptionsBatcher(callID string) {
+	p.mut.Lock()
+	batcher := p.captionsBatchers[callID]
+	delete(p.captionsBatchers, callID)
+	p.mut.Unlock()
+
+	if batcher == nil {
+		return
+	}
+
+	p.LogDebug("stopping captionsBatcher for call", "callID", callID)
+
+	for start := time.Now(); !batcher.Empty(); {
+		if time.Since(start) > captionsFlushTimeout {
+			p.LogWarn("timed out waiting for captions to be flushed", "callID", callID)
+			break
+		}
+		time.Sleep(captionsBatchingInterval / 10)
+	}
+	batcher.Stop()
+
+	p.LogDebug("stopped captionsBatcher for call", "callID", callID)
+}
+
+// getRecentCaptions returns the most recent captions for the given call so
+// that participants joining late can catch up.
+func (p *Plugin) getRecentCaptions(callID string) []*public.CallCaption {
+	if cfg := p.getConfiguration(); !cfg.liveCaptionsEnabled() {
+		return nil
+	}
+
+	captions, err := p.store.GetCallCaptions(callID, db.GetCallCaptionsOpts{Limit: recentCaptionsLimit})
+	if err != nil {
+		p.LogError("failed to get recent captions", "callID", callID, "err", err.Error())
+		return nil
+	}
+
+	return captions
+}
+
+func (p *Plugin) handleGetCallCaptions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-Id")
+	channelID := mux.Vars(r)["call_id"]
+
+	if !p.API.HasPermissionToChannel(userID, channelID, model.PermissionReadChannel) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var since int64
+	if val := r.URL.Query().Get("since"); val != "" {
+		var err error
+		if since, err = strconv.ParseInt(val, 10, 64); err != nil || since < 0 {
+			http.Error(w, "invalid since parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	call, err := p.store.GetActiveCallByChannelID(channelID, db.GetCallOpts{})
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "no call ongoing", http.StatusNotFound)
+		return
+	} else if err != nil {
+		p.LogError(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Captions are only broadcasted to call participants so we apply the same
+	// restriction here.
+	sessions, err := p.store.GetCallSessions(call.ID, db.GetCallSessionOpts{})
+	if err != nil {
+		p.LogError(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !isUserInSessions(userID, sessions) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	captions, err := p.store.GetCallCaptions(call.ID, db.GetCallCaptionsOpts{Since: since})
+	if err != nil {
+		p.LogError(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(captions); err != nil {
+		p.LogError(err.Error())
+	}
+}
+
+func isUserInSessions(userID string, sessions map[string]*public.CallSession) bool {
+	for _, session := range sessions {
+		if session.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// getTranscriptionState returns whether a transcription was posted for the
+// call, or whether one is still expected, i.e. a transcription job ran without
+// failing but the transcriber hasn't posted the result yet.
+func getTranscriptionState(jobs []*public.CallJob, callPost *model.Post) (posted, pending bool) {
+	transcriptions, _ := callPost.GetProp("transcriptions").(map[string]any)
+
+	for _, job := range jobs {
+		if job.Type != public.JobTypeTranscribing || job.StartAt == 0 || job.Props.Err != "" {
+			continue
+		}
+
+		var tm jobMetadata
+		tm.fromMap(transcriptions[job.ID])
+		if job.EndAt > 0 && tm.PostID != "" {
+			return true, false
+		}
+		pending = true
+	}
+
+	return false, pending
+}
+
+// waitForTranscription waits for any transcription of the given call to be
+// posted, returning whether one was. It gives up after
+// captionsTranscriptMaxWait or if the plugin is stopping.
+func (p *Plugin) waitForTranscription(call public.Call) (bool, error) {
+	for start := time.Now(); ; {
+		jobs, err := p.store.GetCallJobs(call.ID, db.GetCallJobOpts{IncludeEnded: true, FromWriter: true})
+		if err != nil {
+			return false, fmt.Errorf("failed to get call jobs: %w", err)
+		}
+
+		callPost, err := p.store.GetPost(call.PostID)
+		if err != nil {
+			return false, fmt.Errorf("failed to get call post: %w", err)
+		}
+
+		posted, pending := getTranscriptionState(jobs, callPost)
+		if posted || !pending {
+			return posted, nil
+		}
+
+		if time.Since(start) > captionsTranscriptMaxWait {
+			p.LogWarn("timed out waiting for transcription", "callID", call.ID)
+			return false, nil
+		}
+
+		select {
+		case <-time.After(captionsTranscriptPollInterval):
+		case <-p.stopCh:
+			return false, fmt.Errorf("plugin is stopping")
+		}
+	}
+}
+
+// postCaptionsTranscript turns the live captions accumulated during a call
+// into a lightweight transcript, posted in the call thread. This only happens
+// if no full transcription was produced for the call. The captions are
+// deleted afterwards.
+func (p *Plugin) postCaptionsTranscript(call public.Call) {
+	p.stopCaptionsBatcher(call.ID)
+	time.Sleep(captionsTranscriptDelay)
+
+	captions, err := p.store.GetCallCaptions(call.ID, db.GetCallCaptionsOpts{FromWriter: true})
+	if err != nil {
+		p.LogError("failed to get call captions", "callID", call.ID, "err", err.Error())
+		return
+	}
+	if len(captions) == 0 {
+		return
+	}
+
+	if ok, err := p.waitForTranscription(call); err != nil {
+		// Captions are left for the retention job to clean up.
+		p.LogError("failed to check for transcription", "callID", call.ID, "err", err.Error())
+		return
+	} else if ok {
+		p.LogDebug("transcription completed, skipping captions transcript", "callID", call.ID)
+		p.deleteCallCaptions(call.ID)
+		return
+	}
+	defer p.deleteCallCaptions(call.ID)
+
+	p.LogDebug("posting captions transcript", "callID", call.ID, "numCaptions", len(captions))
+
+	filename := fmt.Sprintf("Call_transcript_%s.txt", time.UnixMilli(call.StartAt).UTC().Format("2006-01-02_15-04-05"))
+	fileInfo, appErr := p.API.UploadFile([]byte(p.formatCaptionsTranscript(call, captions)), call.ChannelID, filename)
+	if appErr != nil {
+		p.LogError("failed to upload captions transcript", "callID", call.ID, "err", appErr.Error())
+		return
+	}
+
+	T := p.getTranslationFunc("")
+	post := &model.Post{
+		UserId:    p.getBotID(),
+		ChannelId: call.ChannelID,
+		Message:   T("app.call.captions_transcript_message"),
+		RootId:    call.ThreadID,
+		FileIds:   []string{fileInfo.Id},
+	}
+	post.AddProp("call_post_id", call.PostID)
+	post.AddProp("captions_transcript", true)
+	if _, appErr := p.API.CreatePost(post); appErr != nil {
+		p.LogError("failed to create captions transcript post", "callID", call.ID, "err", appErr.Error())
+	}
+}
+
+func (p *Plugin) deleteCallCaptions(callID string) {
+	if err := p.store.DeleteCallCaptions(callID); err != nil {
+		p.LogError("failed to delete call captions", "callID", callID, "err", err.Error())
+	}
+}
+
+// runCaptionsRetentionJob periodically deletes captions that outlived
+// captionsRetention, e.g. because the node that should have cleaned them up
+// at the end of the call was stopped.
+func (p *Plugin) runCaptionsRetentionJob() {
+	ticker := time.NewTicker(captionsRetentionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n, err := p.store.DeleteCallCaptionsBefore(time.Now().Add(-captionsRetention).UnixMilli())
+			if err != nil {
+				p.LogError("failed to delete expired captions", "err", err.Error())
+				continue
+			}
+			if n > 0 {
+				p.LogDebug("deleted expired captions", "count", n)
+			}
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+// formatCaptionsTranscript renders captions as plain text lines, each prefixed
+// with the time elapsed since the call started and the speaker's name.
+func (p *Plugin) formatCaptionsTranscript(call public.Call, captions []*public.CallCaption) string {
+	nameFormat := model.ShowUsername
+	if cfg := p.API.GetConfig(); cfg != nil && cfg.PrivacySettings.ShowFullName != nil && *cfg.PrivacySettings.ShowFullName {
+		nameFormat = model.ShowFullName
+	}
+
+	names := map[string]string{}
+	getName := func(userID string) string {
+		if name, ok := names[userID]; ok {
+			return name
+		}
+		name := userID
+		if user, appErr := p.API.GetUser(userID); appErr != nil {
+			p.LogError("failed to get user", "userID", userID, "err", appErr.Error())
+		} else {
+			name = user.GetDisplayName(nameFormat)
+		}
+		names[userID] = name
+		return name
+	}
+
+	var b strings.Builder
+	for _, caption := range captions {
+		elapsed := max(time.Duration(caption.CreateAt-call.StartAt)*time.Millisecond, 0)
+		fmt.Fprintf(&b, "[%02d:%02d:%02d] %s: %s\n",
+			int(elapsed.Hours()), int(elapsed.Minutes())%60, int(elapsed.Seconds())%60,
+			getName(caption.UserID), caption.Text)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,254 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	serverMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost-plugin-calls/server/interfaces"
+	pluginMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"golang.org/x/time/rate"
+)
+
+func TestHandleGetCallCaptions(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	mockMetrics := &serverMocks.MockMetrics{}
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		metrics:     mockMetrics,
+		apiLimiters: map[string]*rate.Limiter{},
+	}
+
+	store, tearDown := NewTestStore(t)
+	t.Cleanup(tearDown)
+	p.store = store
+
+	mockMetrics.On("ObserveAppHandlersTime", mock.AnythingOfType("string"), mock.AnythingOfType("float64"))
+	mockMetrics.On("Handler").Return(nil).Once()
+
+	apiRouter := p.newAPIRouter()
+
+	channelID := model.NewId()
+	userID := model.NewId()
+
+	call := &public.Call{
+		ID:        model.NewId(),
+		ChannelID: channelID,
+		StartAt:   time.Now().UnixMilli(),
+		CreateAt:  time.Now().UnixMilli(),
+		OwnerID:   userID,
+	}
+	require.NoError(t, store.CreateCall(call))
+	require.NoError(t, store.CreateCallSession(&public.CallSession{
+		ID:     model.NewId(),
+		CallID: call.ID,
+		UserID: userID,
+		JoinAt: call.StartAt,
+	}))
+
+	captions := []*public.CallCaption{
+		{ID: model.NewId(), CallID: call.ID, SessionID: model.NewId(), UserID: userID, CreateAt: 1000, Text: "first", Language: "en"},
+		{ID: model.NewId(), CallID: call.ID, SessionID: model.NewId(), UserID: userID, CreateAt: 2000, Text: "second", Language: "en"},
+	}
+	require.NoError(t, store.CreateCallCaptions(captions))
+
+	getCaptions := func(t *testing.T, userID, query string) *http.Response {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/calls/"+channelID+"/captions"+query, nil)
+		r.Header.Set("Mattermost-User-Id", userID)
+		apiRouter.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	t.Run("no permission", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		otherUserID := model.NewId()
+		mockAPI.On("HasPermissionToChannel", otherUserID, channelID, model.PermissionReadChannel).Return(false).Once()
+
+		resp := getCaptions(t, otherUserID, "")
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("not a participant", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		otherUserID := model.NewId()
+		mockAPI.On("HasPermissionToChannel", otherUserID, channelID, model.PermissionReadChannel).Return(true).Once()
+
+		resp := getCaptions(t, otherUserID, "")
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("invalid since", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("HasPermissionToChannel", userID, channelID, model.PermissionReadChannel).Return(true).Once()
+
+		resp := getCaptions(t, userID, "?since=invalid")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("all", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("HasPermissionToChannel", userID, channelID, model.PermissionReadChannel).Return(true).Once()
+
+		resp := getCaptions(t, userID, "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var gotCaptions []*public.CallCaption
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&gotCaptions))
+		require.Equal(t, captions, gotCaptions)
+	})
+
+	t.Run("since", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("HasPermissionToChannel", userID, channelID, model.PermissionReadChannel).Return(true).Once()
+
+		resp := getCaptions(t, userID, "?since=1000")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var gotCaptions []*public.CallCaption
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&gotCaptions))
+		require.Equal(t, captions[1:], gotCaptions)
+	})
+}
+
+func TestFormatCaptionsTranscript(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	defer mockAPI.AssertExpectations(t)
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+	}
+
+	userA := &model.User{Id: model.NewId(), Username: "alice", FirstName: "Alice", LastName: "Smith"}
+	userB := &model.User{Id: model.NewId(), Username: "bob"}
+
+	mockAPI.On("GetConfig").Return(&model.Config{
+		PrivacySettings: model.PrivacySettings{
+			ShowFullName: model.NewPointer(true),
+		},
+	}).Once()
+	mockAPI.On("GetUser", userA.Id).Return(userA, nil).Once()
+	mockAPI.On("GetUser", userB.Id).Return(userB, nil).Once()
+
+	call := public.Call{StartAt: 10000}
+	transcript := p.formatCaptionsTranscript(call, []*public.CallCaption{
+		{UserID: userA.Id, CreateAt: 12000, Text: "Hello"},
+		{UserID: userB.Id, CreateAt: 75000, Text: "Hi there"},
+		{UserID: userA.Id, CreateAt: 3_735_000, Text: "Bye"},
+	})
+
+	require.Equal(t, "[00:00:02] Alice Smith: Hello\n[00:01:05] bob: Hi there\n[01:02:05] Alice Smith: Bye\n", transcript)
+}
+
+func TestGetTranscriptionState(t *testing.T) {
+	newTrJob := func(id string, startAt, endAt int64, jobErr string) *public.CallJob {
+		return &public.CallJob{
+			ID:      id,
+			Type:    public.JobTypeTranscribing,
+			StartAt: startAt,
+			EndAt:   endAt,
+			Props:   public.CallJobProps{Err: jobErr},
+		}
+	}
+
+	newCallPost := func(trPostIDs map[string]string) *model.Post {
+		post := &model.Post{}
+		transcriptions := map[string]any{}
+		for trID, postID := range trPostIDs {
+			tm := jobMetadata{PostID: postID}
+			transcriptions[trID] = tm.toMap()
+		}
+		post.AddProp("transcriptions", transcriptions)
+		return post
+	}
+
+	tcs := []struct {
+		name     string
+		jobs     []*public.CallJob
+		callPost *model.Post
+		posted   bool
+		pending  bool
+	}{
+		{
+			name:     "no jobs",
+			callPost: &model.Post{},
+		},
+		{
+			name: "recording only",
+			jobs: []*public.CallJob{
+				{ID: "recID", Type: public.JobTypeRecording, StartAt: 1000, EndAt: 2000},
+			},
+			callPost: &model.Post{},
+		},
+		{
+			name:     "never started",
+			jobs:     []*public.CallJob{newTrJob("trID", 0, 2000, "")},
+			callPost: newCallPost(map[string]string{"trID": ""}),
+		},
+		{
+			name:     "failed",
+			jobs:     []*public.CallJob{newTrJob("trID", 1000, 2000, "transcriber crashed")},
+			callPost: newCallPost(map[string]string{"trID": ""}),
+		},
+		{
+			name:     "still running",
+			jobs:     []*public.CallJob{newTrJob("trID", 1000, 0, "")},
+			callPost: newCallPost(map[string]string{"trID": ""}),
+			pending:  true,
+		},
+		{
+			name:     "ended but not posted yet",
+			jobs:     []*public.CallJob{newTrJob("trID", 1000, 2000, "")},
+			callPost: newCallPost(map[string]string{"trID": ""}),
+			pending:  true,
+		},
+		{
+			name:     "posted",
+			jobs:     []*public.CallJob{newTrJob("trID", 1000, 2000, "")},
+			callPost: newCallPost(map[string]string{"trID": "trPostID"}),
+			posted:   true,
+		},
+		{
+			name: "posted after a failure",
+			jobs: []*public.CallJob{
+				newTrJob("trA", 1000, 2000, "transcriber crashed"),
+				newTrJob("trB", 3000, 4000, ""),
+			},
+			callPost: newCallPost(map[string]string{"trA": "", "trB": "trPostID"}),
+			posted:   true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			posted, pending := getTranscriptionState(tc.jobs, tc.callPost)
+			require.Equal(t, tc.posted, posted)
+			require.Equal(t, tc.pending, pending)
+		})
+	}
+}
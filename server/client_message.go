@@ -5,6 +5,9 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+
+	"github.com/mattermost/rtcd/service/rtc"
 )
 
 type clientMessage struct {
@@ -13,25 +16,27 @@ type clientMessage struct {
 }
 
 const (
-	clientMessageTypeJoin        = "join"
-	clientMessageTypeLeave       = "leave"
-	clientMessageTypeReconnect   = "reconnect"
-	clientMessageTypeSDP         = "sdp"
-	clientMessageTypeICE         = "ice"
-	clientMessageTypeMute        = "mute"
-	clientMessageTypeUnmute      = "unmute"
-	clientMessageTypeVoiceOn     = "voice_on"
-	clientMessageTypeVoiceOff    = "voice_off"
-	clientMessageTypeScreenOn    = "screen_on"
-	clientMessageTypeScreenOff   = "screen_off"
-	clientMessageTypeVideoOn     = "video_on"
-	clientMessageTypeVideoOff    = "video_off"
-	clientMessageTypeRaiseHand   = "raise_hand"
-	clientMessageTypeUnraiseHand = "unraise_hand"
-	clientMessageTypeReact       = "react"
-	clientMessageTypeCaption     = "caption"
-	clientMessageTypeMetric      = "metric"
-	clientMessageTypeCallState   = "call_state"
+	clientMessageTypeJoin             = "join"
+	clientMessageTypeLeave            = "leave"
+	clientMessageTypeReconnect        = "reconnect"
+	clientMessageTypeTransfer         = "transfer"
+	clientMessageTypeSDP              = "sdp"
+	clientMessageTypeICE              = "ice"
+	clientMessageTypeMute             = "mute"
+	clientMessageTypeUnmute           = "unmute"
+	clientMessageTypeVoiceOn          = "voice_on"
+	clientMessageTypeVoiceOff         = "voice_off"
+	clientMessageTypeScreenOn         = "screen_on"
+	clientMessageTypeScreenOff        = "screen_off"
+	clientMessageTypeVideoOn          = "video_on"
+	clientMessageTypeVideoOff         = "video_off"
+	clientMessageTypeRaiseHand        = "raise_hand"
+	clientMessageTypeUnraiseHand      = "unraise_hand"
+	clientMessageTypeReact            = "react"
+	clientMessageTypeCaption          = "caption"
+	clientMessageTypeMetric           = "metric"
+	clientMessageTypeCallState        = "call_state"
+	clientMessageTypeCaptionsLanguage = "captions_language"
 )
 
 func (m *clientMessage) ToJSON() ([]byte, error) {
@@ -43,28 +48,51 @@ func (m *clientMessage) FromJSON(data []byte) error {
 }
 
 var validClientMessageTypes = map[string]bool{
-	clientMessageTypeJoin:        true,
-	clientMessageTypeLeave:       true,
-	clientMessageTypeReconnect:   true,
-	clientMessageTypeSDP:         true,
-	clientMessageTypeICE:         true,
-	clientMessageTypeMute:        true,
-	clientMessageTypeUnmute:      true,
-	clientMessageTypeVoiceOn:     true,
-	clientMessageTypeVoiceOff:    true,
-	clientMessageTypeScreenOn:    true,
-	clientMessageTypeScreenOff:   true,
-	clientMessageTypeVideoOn:     true,
-	clientMessageTypeVideoOff:    true,
-	clientMessageTypeRaiseHand:   true,
-	clientMessageTypeUnraiseHand: true,
-	clientMessageTypeReact:       true,
-	clientMessageTypeCaption:     true,
-	clientMessageTypeMetric:      true,
-	clientMessageTypeCallState:   true,
-	"ping":                       true, // Special case: standard ping message
+	clientMessageTypeJoin:             true,
+	clientMessageTypeLeave:            true,
+	clientMessageTypeReconnect:        true,
+	clientMessageTypeTransfer:         true,
+	clientMessageTypeSDP:              true,
+	clientMessageTypeICE:              true,
+	clientMessageTypeMute:             true,
+	clientMessageTypeUnmute:           true,
+	clientMessageTypeVoiceOn:          true,
+	clientMessageTypeVoiceOff:         true,
+	clientMessageTypeScreenOn:         true,
+	clientMessageTypeScreenOff:        true,
+	clientMessageTypeVideoOn:          true,
+	clientMessageTypeVideoOff:         true,
+	clientMessageTypeRaiseHand:        true,
+	clientMessageTypeUnraiseHand:      true,
+	clientMessageTypeReact:            true,
+	clientMessageTypeCaption:          true,
+	clientMessageTypeMetric:           true,
+	clientMessageTypeCallState:        true,
+	clientMessageTypeCaptionsLanguage: true,
+	"ping":                            true, // Special case: standard ping message
 }
 
 func isValidClientMessageType(msgType string) bool {
 	return validClientMessageTypes[msgType]
 }
+
+// getRTCUserStateMessageType returns the RTC message type matching the given
+// client message changing the media state of a session.
+func getRTCUserStateMessageType(msgType string) (rtc.MessageType, error) {
+	switch msgType {
+	case clientMessageTypeMute:
+		return rtc.MuteMessage, nil
+	case clientMessageTypeUnmute:
+		return rtc.UnmuteMessage, nil
+	case clientMessageTypeScreenOn:
+		return rtc.ScreenOnMessage, nil
+	case clientMessageTypeScreenOff:
+		return rtc.ScreenOffMessage, nil
+	case clientMessageTypeVideoOn:
+		return rtc.VideoOnMessage, nil
+	case clientMessageTypeVideoOff:
+		return rtc.VideoOffMessage, nil
+	default:
+		return 0, fmt.Errorf("unexpected client message type %q", msgType)
+	}
+}
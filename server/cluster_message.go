@@ -15,7 +15,8 @@ import (
 type clusterMessage struct {
 	ConnID string `json:"conn_id,omitempty"`
 	// NewConnID is used by clusterMessageTypeReconnect to inform other nodes
-	// about the new WS connection ID in case it changed.
+	// about the new WS connection ID in case it changed, and by
+	// clusterMessageTypeTransfer to pass the ID of the session taking over.
 	NewConnID     string           `json:"new_conn_id,omitempty"`
 	UserID        string           `json:"user_id,omitempty"`
 	ChannelID     string           `json:"channel_id,omitempty"`
@@ -23,6 +24,9 @@ type clusterMessage struct {
 	SenderID      string           `json:"sender_id,omitempty"`
 	SessionProps  rtc.SessionProps `json:"session_props,omitempty"`
 	ClientMessage clientMessage    `json:"client_message,omitempty"`
+	// RequestID is used by clusterMessageTypeGuestEventsPoll to match the
+	// clusterMessageTypeGuestEvents reply with the poll it answers.
+	RequestID string `json:"request_id,omitempty"`
 }
 
 type clusterMessageType string
@@ -34,6 +38,11 @@ const (
 	clusterMessageTypeReconnect  clusterMessageType = "reconnect"
 	clusterMessageTypeSignaling  clusterMessageType = "signaling"
 	clusterMessageTypeUserState  clusterMessageType = "user_state"
+	clusterMessageTypeGuestEvent clusterMessageType = "guest_event"
+	clusterMessageTypeTransfer   clusterMessageType = "transfer"
+
+	clusterMessageTypeGuestEventsPoll clusterMessageType = "guest_events_poll"
+	clusterMessageTypeGuestEvents     clusterMessageType = "guest_events"
 )
 
 func (m *clusterMessage) ToJSON() ([]byte, error) {
@@ -10,8 +10,11 @@ import (
 	"maps"
 	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
 	"os"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -64,6 +67,43 @@ type configuration struct {
 	JobServiceURL string
 	// The audio and video quality of call recordings.
 	RecordingQuality string
+	// When set to true call recordings are stored in an external S3 compatible
+	// bucket instead of the Mattermost file storage.
+	EnableRecordingsS3Storage *bool
+	// The URL of the S3 compatible service (e.g. https://s3.amazonaws.com or
+	// http://minio:9000) used to store call recordings.
+	RecordingsS3Endpoint string
+	// The name of the bucket used to store call recordings.
+	RecordingsS3Bucket string
+	// The region of the bucket used to store call recordings.
+	RecordingsS3Region string
+	// The access key ID used to authenticate against the S3 compatible service.
+	RecordingsS3AccessKeyID string
+	// The secret access key used to authenticate against the S3 compatible service.
+	RecordingsS3SecretAccessKey string
+	// The number of minutes signed links to recordings stored in S3 are valid for.
+	RecordingsS3SignedURLExpiryMinutes *int
+	// The number of days after which recordings stored in S3 are deleted. Zero
+	// means recordings are kept until their post is deleted.
+	RecordingsS3RetentionDays *int
+	// When set to true an external SIP gateway can join calls on behalf of
+	// phone participants.
+	EnableSIPGateway *bool
+	// The URL of the SIP gateway, used to send it signaling messages and host
+	// actions for phone participants.
+	SIPGatewayURL string
+	// The token shared with the SIP gateway to authenticate requests in both
+	// directions.
+	SIPGatewayToken string
+	// When set to true call hosts can generate invite links that let external
+	// people join a call as guests.
+	EnableGuestInvites *bool
+	// The maximum number of minutes guest invites can be valid for.
+	GuestInvitesMaxExpiryMinutes *int
+	// A comma separated list of IP addresses or CIDR ranges of the reverse
+	// proxies whose forwarding headers (e.g. X-Forwarded-For) are trusted to
+	// identify guests' addresses.
+	GuestInvitesTrustedProxies string
 	// When set to true the RTC service will work in dual-stack mode, listening for IPv6
 	// connections and generating candidates in addition to IPv4 ones.
 	EnableIPv6 *bool
@@ -80,6 +120,16 @@ type configuration struct {
 	TranscribeAPIAzureSpeechRegion string
 	// The number of threads to use to transcriber calls.
 	TranscriberNumThreads *int
+	// When set to true hosts can choose the language, API and model size for
+	// each transcription job, and channel admins can set a default
+	// transcription language for their channels.
+	EnableTranscriptionJobOptions *bool
+	// A comma separated list of languages that can be chosen for transcription
+	// jobs. If blank, any language is allowed.
+	TranscriptionAllowedLanguages string
+	// The largest speech-to-text model size that can be chosen for transcription
+	// jobs. If blank, it defaults to TranscriberModelSize.
+	TranscriberMaxModelSize transcriber.ModelSize
 	// When set to true live captions will be enabled when starting transcription jobs.
 	EnableLiveCaptions *bool
 	// The speech-to-text model size to use to transcribe live captions.
@@ -90,6 +140,19 @@ type configuration struct {
 	LiveCaptionsNumThreadsPerTranscriber *int
 	// The language to be passed to the live captions transcriber.
 	LiveCaptionsLanguage string
+	// The base URL of an OpenAI compatible API used to summarize call
+	// transcriptions. When empty, summaries are disabled.
+	SummarizerAPIURL string
+	// The API key used to authenticate against the summarizer API.
+	SummarizerAPIKey string
+	// The model to request when summarizing call transcriptions.
+	SummarizerModel string
+	// The API to use to translate live captions (http or stub).
+	LiveCaptionsTranslationAPI string
+	// The base URL of a LibreTranslate compatible API used to translate live captions.
+	LiveCaptionsTranslationAPIURL string
+	// The API key used to authenticate against the live captions translation API.
+	LiveCaptionsTranslationAPIKey string
 
 	ClientConfig
 }
@@ -122,6 +185,9 @@ type ClientConfig struct {
 	EnableTranscriptions *bool
 	// When set to true it enables the live captions functionality
 	EnableLiveCaptions *bool
+	// When set to true it allows participants to receive live captions
+	// translated into their preferred language.
+	EnableLiveCaptionsTranslation *bool
 	// The maximum duration (in minutes) for call recordings.
 	MaxRecordingDuration *int
 	// When set to true it enables simulcast for screen sharing. This can help to improve screen sharing quality.
@@ -142,12 +208,35 @@ type ClientConfig struct {
 	EnableVideo *bool
 }
 
+// clientConfigResponse is the configuration returned to clients along with
+// runtime state that isn't part of the plugin settings.
+type clientConfigResponse struct {
+	ClientConfig
+	// Let the server signal that recordings and transcriptions can't currently
+	// be started as the job service is down.
+	JobServiceUnavailable bool `json:"job_service_unavailable"`
+}
+
+// adminClientConfigResponse is the clientConfigResponse counterpart returned
+// to admins.
+type adminClientConfigResponse struct {
+	configuration
+	JobServiceUnavailable bool `json:"job_service_unavailable"`
+}
+
 const (
 	defaultRecDurationMinutes = 60
 	minRecDurationMinutes     = 15
 	maxRecDurationMinutes     = 180
 	minAllowedPort            = 80
 	maxAllowedPort            = 49151
+	defaultSummarizerModel    = "gpt-4o-mini"
+	defaultS3Region           = "us-east-1"
+	defaultS3URLExpiryMinutes = 60
+	// Signed URLs can't be valid for longer than a week.
+	maxS3URLExpiryMinutes               = 7 * 24 * 60
+	defaultGuestInvitesMaxExpiryMinutes = 24 * 60
+	maxGuestInvitesExpiryMinutes        = 7 * 24 * 60
 )
 
 type (
@@ -230,6 +319,27 @@ func (c *configuration) SetDefaults() {
 	if c.RecordingQuality == "" {
 		c.RecordingQuality = "medium"
 	}
+	if c.EnableRecordingsS3Storage == nil {
+		c.EnableRecordingsS3Storage = model.NewPointer(false)
+	}
+	if c.RecordingsS3Region == "" {
+		c.RecordingsS3Region = defaultS3Region
+	}
+	if c.RecordingsS3SignedURLExpiryMinutes == nil {
+		c.RecordingsS3SignedURLExpiryMinutes = model.NewPointer(defaultS3URLExpiryMinutes)
+	}
+	if c.RecordingsS3RetentionDays == nil {
+		c.RecordingsS3RetentionDays = model.NewPointer(0)
+	}
+	if c.EnableSIPGateway == nil {
+		c.EnableSIPGateway = model.NewPointer(false)
+	}
+	if c.EnableGuestInvites == nil {
+		c.EnableGuestInvites = model.NewPointer(false)
+	}
+	if c.GuestInvitesMaxExpiryMinutes == nil {
+		c.GuestInvitesMaxExpiryMinutes = model.NewPointer(defaultGuestInvitesMaxExpiryMinutes)
+	}
 	if c.EnableSimulcast == nil {
 		c.EnableSimulcast = model.NewPointer(false)
 	}
@@ -248,6 +358,9 @@ func (c *configuration) SetDefaults() {
 	if c.EnableLiveCaptions == nil {
 		c.EnableLiveCaptions = model.NewPointer(false)
 	}
+	if c.EnableTranscriptionJobOptions == nil {
+		c.EnableTranscriptionJobOptions = model.NewPointer(false)
+	}
 	if c.LiveCaptionsModelSize == "" {
 		c.LiveCaptionsModelSize = transcriber.LiveCaptionsModelSizeDefault
 	}
@@ -269,6 +382,15 @@ func (c *configuration) SetDefaults() {
 	if c.EnableVideo == nil {
 		c.EnableVideo = model.NewPointer(false)
 	}
+	if c.SummarizerModel == "" {
+		c.SummarizerModel = defaultSummarizerModel
+	}
+	if c.EnableLiveCaptionsTranslation == nil {
+		c.EnableLiveCaptionsTranslation = model.NewPointer(false)
+	}
+	if c.LiveCaptionsTranslationAPI == "" {
+		c.LiveCaptionsTranslationAPI = translatorAPIHTTP
+	}
 }
 
 func (c *configuration) IsValid() error {
@@ -312,6 +434,52 @@ func (c *configuration) IsValid() error {
 		return fmt.Errorf("RecordingQuality is not valid")
 	}
 
+	if c.recordingsS3StorageEnabled() {
+		if u, err := url.Parse(c.RecordingsS3Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.Trim(u.Path, "/") != "" {
+			return fmt.Errorf("RecordingsS3Endpoint is not valid: should be an http(s) URL with no path")
+		}
+
+		if c.RecordingsS3Bucket == "" {
+			return fmt.Errorf("RecordingsS3Bucket should not be empty")
+		}
+
+		if c.RecordingsS3Region == "" {
+			return fmt.Errorf("RecordingsS3Region should not be empty")
+		}
+
+		if c.RecordingsS3AccessKeyID == "" || c.RecordingsS3SecretAccessKey == "" {
+			return fmt.Errorf("RecordingsS3AccessKeyID and RecordingsS3SecretAccessKey should not be empty")
+		}
+
+		if c.RecordingsS3SignedURLExpiryMinutes == nil || *c.RecordingsS3SignedURLExpiryMinutes <= 0 || *c.RecordingsS3SignedURLExpiryMinutes > maxS3URLExpiryMinutes {
+			return fmt.Errorf("RecordingsS3SignedURLExpiryMinutes is not valid: range should be [1, %d]", maxS3URLExpiryMinutes)
+		}
+
+		if c.RecordingsS3RetentionDays == nil || *c.RecordingsS3RetentionDays < 0 {
+			return fmt.Errorf("RecordingsS3RetentionDays is not valid: should be a positive number or zero")
+		}
+	}
+
+	if c.sipGatewayEnabled() {
+		if u, err := url.Parse(c.SIPGatewayURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("SIPGatewayURL is not valid: should be an http(s) URL")
+		}
+
+		if c.SIPGatewayToken == "" {
+			return fmt.Errorf("SIPGatewayToken should not be empty")
+		}
+	}
+
+	if c.guestInvitesEnabled() {
+		if c.GuestInvitesMaxExpiryMinutes == nil || *c.GuestInvitesMaxExpiryMinutes <= 0 || *c.GuestInvitesMaxExpiryMinutes > maxGuestInvitesExpiryMinutes {
+			return fmt.Errorf("GuestInvitesMaxExpiryMinutes is not valid: range should be [1, %d]", maxGuestInvitesExpiryMinutes)
+		}
+
+		if _, err := c.getGuestInvitesTrustedProxies(); err != nil {
+			return fmt.Errorf("GuestInvitesTrustedProxies is not valid: %w", err)
+		}
+	}
+
 	if c.transcriptionsEnabled() {
 		if ok := c.TranscriberModelSize.IsValid(); !ok {
 			return fmt.Errorf("TranscriberModelSize is not valid")
@@ -324,6 +492,30 @@ func (c *configuration) IsValid() error {
 		if c.TranscriberNumThreads == nil || *c.TranscriberNumThreads <= 0 {
 			return fmt.Errorf("TranscriberNumThreads is not valid: should be greater than 0")
 		}
+
+		if c.TranscribeAPI == transcriber.TranscribeAPIAzure && !c.azureTranscribeAPIAvailable() {
+			return fmt.Errorf("TranscribeAPI is not valid: TranscribeAPIAzureSpeechKey and TranscribeAPIAzureSpeechRegion should be set")
+		}
+	}
+
+	if c.transcriptionJobOptionsEnabled() {
+		for _, lang := range c.getTranscriptionAllowedLanguages() {
+			if !isValidCaptionsLanguage(lang) {
+				return fmt.Errorf("TranscriptionAllowedLanguages is not valid: should be a comma separated list of 2-letter ISO 639 set 1 language codes")
+			}
+		}
+
+		if c.TranscriberMaxModelSize != "" && modelSizeRank(c.TranscriberMaxModelSize) < 0 {
+			return fmt.Errorf("TranscriberMaxModelSize is not valid")
+		}
+
+		if !c.isTranscriberModelSizeAllowed(c.TranscriberModelSize) {
+			return fmt.Errorf("TranscriberModelSize is not valid: should not be larger than TranscriberMaxModelSize")
+		}
+
+		if c.liveCaptionsEnabled() && !c.isTranscriptionLanguageAllowed(c.LiveCaptionsLanguage) {
+			return fmt.Errorf("LiveCaptionsLanguage is not valid: should be one of TranscriptionAllowedLanguages")
+		}
 	}
 
 	if c.ICEHostPortOverride != nil && *c.ICEHostPortOverride != 0 && (*c.ICEHostPortOverride < minAllowedPort || *c.ICEHostPortOverride > maxAllowedPort) {
@@ -347,6 +539,29 @@ func (c *configuration) IsValid() error {
 			return fmt.Errorf("LiveCaptionsLanguage is not valid: should be a 2-letter ISO 639 set 1 language code, or blank for default")
 		}
 	}
+
+	if c.liveCaptionsTranslationEnabled() {
+		switch c.LiveCaptionsTranslationAPI {
+		case translatorAPIHTTP:
+			if u, err := url.Parse(c.LiveCaptionsTranslationAPIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("LiveCaptionsTranslationAPIURL is not valid: should be an http(s) URL")
+			}
+		case translatorAPIStub:
+		default:
+			return fmt.Errorf("LiveCaptionsTranslationAPI is not valid")
+		}
+	}
+
+	if c.summariesEnabled() {
+		if u, err := url.Parse(c.SummarizerAPIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("SummarizerAPIURL is not valid: should be an http(s) URL")
+		}
+
+		if c.SummarizerModel == "" {
+			return fmt.Errorf("SummarizerModel should not be empty")
+		}
+	}
+
 	return nil
 }
 
@@ -361,12 +576,28 @@ func (c *configuration) Clone() *configuration {
 	cfg.JobServiceURL = c.JobServiceURL
 	cfg.TURNStaticAuthSecret = c.TURNStaticAuthSecret
 	cfg.RecordingQuality = c.RecordingQuality
+	cfg.RecordingsS3Endpoint = c.RecordingsS3Endpoint
+	cfg.RecordingsS3Bucket = c.RecordingsS3Bucket
+	cfg.RecordingsS3Region = c.RecordingsS3Region
+	cfg.RecordingsS3AccessKeyID = c.RecordingsS3AccessKeyID
+	cfg.RecordingsS3SecretAccessKey = c.RecordingsS3SecretAccessKey
+	cfg.SIPGatewayURL = c.SIPGatewayURL
+	cfg.SIPGatewayToken = c.SIPGatewayToken
+	cfg.GuestInvitesTrustedProxies = c.GuestInvitesTrustedProxies
 	cfg.TranscriberModelSize = c.TranscriberModelSize
 	cfg.TranscribeAPI = c.TranscribeAPI
 	cfg.TranscribeAPIAzureSpeechKey = c.TranscribeAPIAzureSpeechKey
 	cfg.TranscribeAPIAzureSpeechRegion = c.TranscribeAPIAzureSpeechRegion
+	cfg.TranscriptionAllowedLanguages = c.TranscriptionAllowedLanguages
+	cfg.TranscriberMaxModelSize = c.TranscriberMaxModelSize
 	cfg.LiveCaptionsModelSize = c.LiveCaptionsModelSize
 	cfg.LiveCaptionsLanguage = c.LiveCaptionsLanguage
+	cfg.SummarizerAPIURL = c.SummarizerAPIURL
+	cfg.SummarizerAPIKey = c.SummarizerAPIKey
+	cfg.SummarizerModel = c.SummarizerModel
+	cfg.LiveCaptionsTranslationAPI = c.LiveCaptionsTranslationAPI
+	cfg.LiveCaptionsTranslationAPIURL = c.LiveCaptionsTranslationAPIURL
+	cfg.LiveCaptionsTranslationAPIKey = c.LiveCaptionsTranslationAPIKey
 
 	if c.UDPServerPort != nil {
 		cfg.UDPServerPort = model.NewPointer(*c.UDPServerPort)
@@ -421,14 +652,46 @@ func (c *configuration) Clone() *configuration {
 		cfg.TranscriberNumThreads = model.NewPointer(*c.TranscriberNumThreads)
 	}
 
+	if c.EnableTranscriptionJobOptions != nil {
+		cfg.EnableTranscriptionJobOptions = model.NewPointer(*c.EnableTranscriptionJobOptions)
+	}
+
 	if c.EnableLiveCaptions != nil {
 		cfg.EnableLiveCaptions = model.NewPointer(*c.EnableLiveCaptions)
 	}
 
+	if c.EnableLiveCaptionsTranslation != nil {
+		cfg.EnableLiveCaptionsTranslation = model.NewPointer(*c.EnableLiveCaptionsTranslation)
+	}
+
 	if c.MaxRecordingDuration != nil {
 		cfg.MaxRecordingDuration = model.NewPointer(*c.MaxRecordingDuration)
 	}
 
+	if c.EnableRecordingsS3Storage != nil {
+		cfg.EnableRecordingsS3Storage = model.NewPointer(*c.EnableRecordingsS3Storage)
+	}
+
+	if c.RecordingsS3SignedURLExpiryMinutes != nil {
+		cfg.RecordingsS3SignedURLExpiryMinutes = model.NewPointer(*c.RecordingsS3SignedURLExpiryMinutes)
+	}
+
+	if c.RecordingsS3RetentionDays != nil {
+		cfg.RecordingsS3RetentionDays = model.NewPointer(*c.RecordingsS3RetentionDays)
+	}
+
+	if c.EnableSIPGateway != nil {
+		cfg.EnableSIPGateway = model.NewPointer(*c.EnableSIPGateway)
+	}
+
+	if c.EnableGuestInvites != nil {
+		cfg.EnableGuestInvites = model.NewPointer(*c.EnableGuestInvites)
+	}
+
+	if c.GuestInvitesMaxExpiryMinutes != nil {
+		cfg.GuestInvitesMaxExpiryMinutes = model.NewPointer(*c.GuestInvitesMaxExpiryMinutes)
+	}
+
 	if c.EnableSimulcast != nil {
 		cfg.EnableSimulcast = model.NewPointer(*c.EnableSimulcast)
 	}
@@ -501,6 +764,85 @@ func (c *configuration) transcriptionsEnabled() bool {
 	return false
 }
 
+func (c *configuration) recordingsS3StorageEnabled() bool {
+	return c.recordingsEnabled() && c.EnableRecordingsS3Storage != nil && *c.EnableRecordingsS3Storage
+}
+
+func (c *configuration) sipGatewayEnabled() bool {
+	return c.EnableSIPGateway != nil && *c.EnableSIPGateway
+}
+
+func (c *configuration) guestInvitesEnabled() bool {
+	return c.EnableGuestInvites != nil && *c.EnableGuestInvites
+}
+
+// getGuestInvitesTrustedProxies parses the list of trusted proxies. Single IP
+// addresses are returned as single address prefixes.
+func (c *configuration) getGuestInvitesTrustedProxies() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(c.GuestInvitesTrustedProxies, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+
+	return prefixes, nil
+}
+
+func (c *configuration) transcriptionJobOptionsEnabled() bool {
+	return c.transcriptionsEnabled() && c.EnableTranscriptionJobOptions != nil && *c.EnableTranscriptionJobOptions
+}
+
+func (c *configuration) azureTranscribeAPIAvailable() bool {
+	return c.TranscribeAPIAzureSpeechKey != "" && c.TranscribeAPIAzureSpeechRegion != ""
+}
+
+func (c *configuration) getTranscriptionAllowedLanguages() []string {
+	var langs []string
+	for _, lang := range strings.Split(c.TranscriptionAllowedLanguages, ",") {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			langs = append(langs, lang)
+		}
+	}
+	return langs
+}
+
+// isTranscriptionLanguageAllowed returns whether the given language can be used
+// for transcriptions. A blank language (i.e. the transcriber's default) is only
+// allowed if no restrictions are in place.
+func (c *configuration) isTranscriptionLanguageAllowed(lang string) bool {
+	langs := c.getTranscriptionAllowedLanguages()
+	if lang == "" {
+		return len(langs) == 0
+	}
+	return isValidCaptionsLanguage(lang) && (len(langs) == 0 || slices.Contains(langs, lang))
+}
+
+func (c *configuration) isTranscriberModelSizeAllowed(size transcriber.ModelSize) bool {
+	maxSize := c.TranscriberMaxModelSize
+	if maxSize == "" {
+		maxSize = c.TranscriberModelSize
+	}
+	rank := modelSizeRank(size)
+	return rank >= 0 && rank <= modelSizeRank(maxSize)
+}
+
 func (c *configuration) liveCaptionsEnabled() bool {
 	if c.recordingsEnabled() && c.transcriptionsEnabled() &&
 		c.EnableLiveCaptions != nil && *c.EnableLiveCaptions {
@@ -509,6 +851,14 @@ func (c *configuration) liveCaptionsEnabled() bool {
 	return false
 }
 
+func (c *configuration) liveCaptionsTranslationEnabled() bool {
+	return c.liveCaptionsEnabled() && c.EnableLiveCaptionsTranslation != nil && *c.EnableLiveCaptionsTranslation
+}
+
+func (c *configuration) summariesEnabled() bool {
+	return c.transcriptionsEnabled() && c.SummarizerAPIURL != ""
+}
+
 func (p *Plugin) getClientConfig(c *configuration) ClientConfig {
 	skuShortName := "starter"
 	license := p.API.GetLicense()
@@ -517,25 +867,33 @@ func (p *Plugin) getClientConfig(c *configuration) ClientConfig {
 	}
 
 	return ClientConfig{
-		AllowEnableCalls:     model.NewPointer(true), // always true
-		DefaultEnabled:       c.DefaultEnabled,
-		ICEServers:           c.ICEServers,
-		ICEServersConfigs:    c.getICEServers(true),
-		MaxCallParticipants:  c.MaxCallParticipants,
-		NeedsTURNCredentials: model.NewPointer(c.TURNStaticAuthSecret != "" && len(c.ICEServersConfigs.getTURNConfigsForCredentials()) > 0),
-		AllowScreenSharing:   c.AllowScreenSharing,
-		EnableRecordings:     c.EnableRecordings,
-		EnableTranscriptions: c.EnableTranscriptions,
-		EnableLiveCaptions:   c.EnableLiveCaptions,
-		MaxRecordingDuration: c.MaxRecordingDuration,
-		EnableSimulcast:      c.EnableSimulcast,
-		EnableRinging:        c.EnableRinging,
-		SkuShortName:         skuShortName,
-		HostControlsAllowed:  p.licenseChecker.HostControlsAllowed(),
-		EnableAV1:            c.EnableAV1,
-		GroupCallsAllowed:    p.licenseChecker.GroupCallsAllowed(),
-		EnableDCSignaling:    c.EnableDCSignaling,
-		EnableVideo:          c.EnableVideo,
+		AllowEnableCalls:              model.NewPointer(true), // always true
+		DefaultEnabled:                c.DefaultEnabled,
+		ICEServers:                    c.ICEServers,
+		ICEServersConfigs:             c.getICEServers(true),
+		MaxCallParticipants:           c.MaxCallParticipants,
+		NeedsTURNCredentials:          model.NewPointer(c.TURNStaticAuthSecret != "" && len(c.ICEServersConfigs.getTURNConfigsForCredentials()) > 0),
+		AllowScreenSharing:            c.AllowScreenSharing,
+		EnableRecordings:              c.EnableRecordings,
+		EnableTranscriptions:          c.EnableTranscriptions,
+		EnableLiveCaptions:            c.EnableLiveCaptions,
+		EnableLiveCaptionsTranslation: c.EnableLiveCaptionsTranslation,
+		MaxRecordingDuration:          c.MaxRecordingDuration,
+		EnableSimulcast:               c.EnableSimulcast,
+		EnableRinging:                 c.EnableRinging,
+		SkuShortName:                  skuShortName,
+		HostControlsAllowed:           p.licenseChecker.HostControlsAllowed(),
+		EnableAV1:                     c.EnableAV1,
+		GroupCallsAllowed:             p.licenseChecker.GroupCallsAllowed(),
+		EnableDCSignaling:             c.EnableDCSignaling,
+		EnableVideo:                   c.EnableVideo,
+	}
+}
+
+func (p *Plugin) getClientConfigResponse(c *configuration) clientConfigResponse {
+	return clientConfigResponse{
+		ClientConfig:          p.getClientConfig(c),
+		JobServiceUnavailable: c.recordingsEnabled() && !p.jobServiceAvailable(),
 	}
 }
 
@@ -549,6 +907,13 @@ func (p *Plugin) getAdminClientConfig(c *configuration) configuration {
 	return *cfg
 }
 
+func (p *Plugin) getAdminClientConfigResponse(c *configuration) adminClientConfigResponse {
+	return adminClientConfigResponse{
+		configuration:         p.getAdminClientConfig(c),
+		JobServiceUnavailable: c.recordingsEnabled() && !p.jobServiceAvailable(),
+	}
+}
+
 // getConfiguration retrieves the active configuration under lock, making it safe to use
 // concurrently. The active configuration may change underneath the client of this method, but
 // the struct returned by this API call is considered immutable.
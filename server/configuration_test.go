@@ -4,6 +4,7 @@
 package main
 
 import (
+	"encoding/json"
 	"errors"
 	"testing"
 
@@ -196,6 +197,326 @@ func TestConfigurationIsValid(t *testing.T) {
 			}(),
 			err: "TranscriberNumThreads is not valid: should be greater than 0",
 		},
+		{
+			name: "invalid SummarizerAPIURL",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.EnableTranscriptions = model.NewPointer(true)
+				cfg.SummarizerAPIURL = "localhost:8080"
+				return cfg
+			}(),
+			err: "SummarizerAPIURL is not valid: should be an http(s) URL",
+		},
+		{
+			name: "SummarizerAPIURL is ignored with transcriptions disabled",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.SummarizerAPIURL = "localhost:8080"
+				return cfg
+			}(),
+		},
+		{
+			name: "missing SummarizerModel",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.EnableTranscriptions = model.NewPointer(true)
+				cfg.SummarizerAPIURL = "https://api.example.com/v1"
+				cfg.SummarizerModel = ""
+				return cfg
+			}(),
+			err: "SummarizerModel should not be empty",
+		},
+		{
+			name: "missing LiveCaptionsTranslationAPIURL",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.EnableTranscriptions = model.NewPointer(true)
+				cfg.EnableLiveCaptions = model.NewPointer(true)
+				cfg.EnableLiveCaptionsTranslation = model.NewPointer(true)
+				return cfg
+			}(),
+			err: "LiveCaptionsTranslationAPIURL is not valid: should be an http(s) URL",
+		},
+		{
+			name: "invalid LiveCaptionsTranslationAPI",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.EnableTranscriptions = model.NewPointer(true)
+				cfg.EnableLiveCaptions = model.NewPointer(true)
+				cfg.EnableLiveCaptionsTranslation = model.NewPointer(true)
+				cfg.LiveCaptionsTranslationAPI = "invalid"
+				return cfg
+			}(),
+			err: "LiveCaptionsTranslationAPI is not valid",
+		},
+		{
+			name: "stub LiveCaptionsTranslationAPI doesn't require a URL",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.EnableTranscriptions = model.NewPointer(true)
+				cfg.EnableLiveCaptions = model.NewPointer(true)
+				cfg.EnableLiveCaptionsTranslation = model.NewPointer(true)
+				cfg.LiveCaptionsTranslationAPI = translatorAPIStub
+				return cfg
+			}(),
+		},
+		{
+			name: "invalid TranscriptionAllowedLanguages",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.EnableTranscriptions = model.NewPointer(true)
+				cfg.EnableTranscriptionJobOptions = model.NewPointer(true)
+				cfg.TranscriptionAllowedLanguages = "en, eng"
+				return cfg
+			}(),
+			err: "TranscriptionAllowedLanguages is not valid: should be a comma separated list of 2-letter ISO 639 set 1 language codes",
+		},
+		{
+			name: "invalid TranscriberMaxModelSize",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.EnableTranscriptions = model.NewPointer(true)
+				cfg.EnableTranscriptionJobOptions = model.NewPointer(true)
+				cfg.TranscriberMaxModelSize = "huge"
+				return cfg
+			}(),
+			err: "TranscriberMaxModelSize is not valid",
+		},
+		{
+			name: "TranscriberModelSize larger than TranscriberMaxModelSize",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.EnableTranscriptions = model.NewPointer(true)
+				cfg.EnableTranscriptionJobOptions = model.NewPointer(true)
+				cfg.TranscriberModelSize = transcriber.ModelSizeSmall
+				cfg.TranscriberMaxModelSize = transcriber.ModelSizeBase
+				return cfg
+			}(),
+			err: "TranscriberModelSize is not valid: should not be larger than TranscriberMaxModelSize",
+		},
+		{
+			name: "LiveCaptionsLanguage not in TranscriptionAllowedLanguages",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.EnableTranscriptions = model.NewPointer(true)
+				cfg.EnableLiveCaptions = model.NewPointer(true)
+				cfg.EnableTranscriptionJobOptions = model.NewPointer(true)
+				cfg.TranscriptionAllowedLanguages = "es,it"
+				cfg.LiveCaptionsLanguage = "en"
+				return cfg
+			}(),
+			err: "LiveCaptionsLanguage is not valid: should be one of TranscriptionAllowedLanguages",
+		},
+		{
+			name: "TranscriptionAllowedLanguages is ignored with job options disabled",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.EnableTranscriptions = model.NewPointer(true)
+				cfg.TranscriptionAllowedLanguages = "invalid"
+				return cfg
+			}(),
+		},
+		{
+			name: "missing Azure credentials",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.EnableTranscriptions = model.NewPointer(true)
+				cfg.TranscribeAPI = transcriber.TranscribeAPIAzure
+				cfg.TranscribeAPIAzureSpeechKey = "key"
+				return cfg
+			}(),
+			err: "TranscribeAPI is not valid: TranscribeAPIAzureSpeechKey and TranscribeAPIAzureSpeechRegion should be set",
+		},
+		{
+			name: "invalid RecordingsS3Endpoint",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.EnableRecordingsS3Storage = model.NewPointer(true)
+				cfg.RecordingsS3Endpoint = "minio:9000"
+				return cfg
+			}(),
+			err: "RecordingsS3Endpoint is not valid: should be an http(s) URL with no path",
+		},
+		{
+			name: "RecordingsS3Endpoint with path",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.EnableRecordingsS3Storage = model.NewPointer(true)
+				cfg.RecordingsS3Endpoint = "http://minio:9000/recordings"
+				return cfg
+			}(),
+			err: "RecordingsS3Endpoint is not valid: should be an http(s) URL with no path",
+		},
+		{
+			name: "missing RecordingsS3Bucket",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.EnableRecordingsS3Storage = model.NewPointer(true)
+				cfg.RecordingsS3Endpoint = "http://minio:9000"
+				return cfg
+			}(),
+			err: "RecordingsS3Bucket should not be empty",
+		},
+		{
+			name: "missing RecordingsS3 credentials",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.EnableRecordingsS3Storage = model.NewPointer(true)
+				cfg.RecordingsS3Endpoint = "http://minio:9000"
+				cfg.RecordingsS3Bucket = "recordings"
+				cfg.RecordingsS3AccessKeyID = "accessKeyID"
+				return cfg
+			}(),
+			err: "RecordingsS3AccessKeyID and RecordingsS3SecretAccessKey should not be empty",
+		},
+		{
+			name: "invalid RecordingsS3SignedURLExpiryMinutes",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.EnableRecordingsS3Storage = model.NewPointer(true)
+				cfg.RecordingsS3Endpoint = "http://minio:9000"
+				cfg.RecordingsS3Bucket = "recordings"
+				cfg.RecordingsS3AccessKeyID = "accessKeyID"
+				cfg.RecordingsS3SecretAccessKey = "secretAccessKey"
+				cfg.RecordingsS3SignedURLExpiryMinutes = model.NewPointer(maxS3URLExpiryMinutes + 1)
+				return cfg
+			}(),
+			err: "RecordingsS3SignedURLExpiryMinutes is not valid: range should be [1, 10080]",
+		},
+		{
+			name: "invalid RecordingsS3RetentionDays",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.EnableRecordingsS3Storage = model.NewPointer(true)
+				cfg.RecordingsS3Endpoint = "http://minio:9000"
+				cfg.RecordingsS3Bucket = "recordings"
+				cfg.RecordingsS3AccessKeyID = "accessKeyID"
+				cfg.RecordingsS3SecretAccessKey = "secretAccessKey"
+				cfg.RecordingsS3RetentionDays = model.NewPointer(-1)
+				return cfg
+			}(),
+			err: "RecordingsS3RetentionDays is not valid: should be a positive number or zero",
+		},
+		{
+			name: "RecordingsS3 settings are ignored with S3 storage disabled",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableRecordings = model.NewPointer(true)
+				cfg.RecordingsS3Endpoint = "minio:9000"
+				return cfg
+			}(),
+		},
+		{
+			name: "invalid SIPGatewayURL",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableSIPGateway = model.NewPointer(true)
+				cfg.SIPGatewayURL = "sip-gateway:8080"
+				cfg.SIPGatewayToken = "token"
+				return cfg
+			}(),
+			err: "SIPGatewayURL is not valid: should be an http(s) URL",
+		},
+		{
+			name: "missing SIPGatewayToken",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableSIPGateway = model.NewPointer(true)
+				cfg.SIPGatewayURL = "http://sip-gateway:8080"
+				return cfg
+			}(),
+			err: "SIPGatewayToken should not be empty",
+		},
+		{
+			name: "SIP gateway settings are ignored with gateway disabled",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.SIPGatewayURL = "sip-gateway:8080"
+				return cfg
+			}(),
+		},
+		{
+			name: "invalid GuestInvitesMaxExpiryMinutes",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableGuestInvites = model.NewPointer(true)
+				cfg.GuestInvitesMaxExpiryMinutes = model.NewPointer(0)
+				return cfg
+			}(),
+			err: "GuestInvitesMaxExpiryMinutes is not valid: range should be [1, 10080]",
+		},
+		{
+			name: "GuestInvitesMaxExpiryMinutes too large",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableGuestInvites = model.NewPointer(true)
+				cfg.GuestInvitesMaxExpiryMinutes = model.NewPointer(10081)
+				return cfg
+			}(),
+			err: "GuestInvitesMaxExpiryMinutes is not valid: range should be [1, 10080]",
+		},
+		{
+			name: "invalid GuestInvitesTrustedProxies",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableGuestInvites = model.NewPointer(true)
+				cfg.GuestInvitesTrustedProxies = "10.0.0.1, proxy"
+				return cfg
+			}(),
+			err: `GuestInvitesTrustedProxies is not valid: failed to parse "proxy": ParseAddr("proxy"): unable to parse IP`,
+		},
+		{
+			name: "valid GuestInvitesTrustedProxies",
+			input: func() configuration {
+				var cfg configuration
+				cfg.SetDefaults()
+				cfg.EnableGuestInvites = model.NewPointer(true)
+				cfg.GuestInvitesTrustedProxies = "10.0.0.1, 172.16.0.0/12,::1"
+				return cfg
+			}(),
+		},
 		{
 			name:  "defaults",
 			input: defaultConfig,
@@ -257,6 +578,24 @@ func TestGetClientConfig(t *testing.T) {
 	// admin config
 	adminClientCfg := p.getAdminClientConfig(p.getConfiguration())
 	require.Equal(t, transcriber.TranscribeAPI(transcriber.TranscribeAPIWhisperCPP), adminClientCfg.TranscribeAPI)
+
+	// Job service availability is only part of the responses.
+	require.False(t, p.getClientConfigResponse(p.getConfiguration()).JobServiceUnavailable)
+	*p.configuration.EnableRecordings = true
+	require.True(t, p.getClientConfigResponse(p.getConfiguration()).JobServiceUnavailable)
+	require.True(t, p.getAdminClientConfigResponse(p.getConfiguration()).JobServiceUnavailable)
+	p.jobService = &jobService{ctx: p}
+	require.False(t, p.getClientConfigResponse(p.getConfiguration()).JobServiceUnavailable)
+	require.False(t, p.getAdminClientConfigResponse(p.getConfiguration()).JobServiceUnavailable)
+
+	data, err := json.Marshal(p.getClientConfigResponse(p.getConfiguration()))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"job_service_unavailable":false`)
+	require.Contains(t, string(data), `"EnableRecordings":true`)
+	data, err = json.Marshal(p.getAdminClientConfigResponse(p.getConfiguration()))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"job_service_unavailable":false`)
+	require.Contains(t, string(data), `"TranscribeAPI":"whisper.cpp"`)
 }
 
 func TestConfigurationWillBeSaved(t *testing.T) {
@@ -0,0 +1,152 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	sq "github.com/mattermost/squirrel"
+)
+
+var callsCaptionsColumns = []string{"ID", "CallID", "SessionID", "UserID", "CreateAt", "Text", "Language"}
+
+// CreateCallCaptions inserts the given captions in a single query.
+func (s *Store) CreateCallCaptions(captions []*public.CallCaption) error {
+	s.metrics.IncStoreOp("CreateCallCaptions")
+	defer func(start time.Time) {
+		s.metrics.ObserveStoreMethodsTime("CreateCallCaptions", time.Since(start).Seconds())
+	}(time.Now())
+
+	if len(captions) == 0 {
+		return nil
+	}
+
+	qb := getQueryBuilder().
+		Insert("calls_captions").
+		Columns(callsCaptionsColumns...)
+
+	for _, caption := range captions {
+		if err := caption.IsValid(); err != nil {
+			return fmt.Errorf("invalid call caption: %w", err)
+		}
+		qb = qb.Values(caption.ID, caption.CallID, caption.SessionID, caption.UserID, caption.CreateAt, caption.Text, caption.Language)
+	}
+
+	q, args, err := qb.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(*s.settings.QueryTimeout)*time.Second)
+	defer cancel()
+	_, err = s.wDB.ExecContext(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("failed to run query: %w", err)
+	}
+
+	return nil
+}
+
+// GetCallCaptions returns the captions for the given call, sorted by creation
+// time. If opts.Limit is set, only the most recent captions are returned.
+func (s *Store) GetCallCaptions(callID string, opts GetCallCaptionsOpts) ([]*public.CallCaption, error) {
+	s.metrics.IncStoreOp("GetCallCaptions")
+	defer func(start time.Time) {
+		s.metrics.ObserveStoreMethodsTime("GetCallCaptions", time.Since(start).Seconds())
+	}(time.Now())
+
+	qb := getQueryBuilder().Select(callsCaptionsColumns...).
+		From("calls_captions").
+		Where(sq.And{
+			sq.Eq{"CallID": callID},
+			sq.Gt{"CreateAt": opts.Since},
+		})
+
+	if opts.Limit > 0 {
+		qb = qb.OrderBy("CreateAt DESC, ID DESC").Limit(opts.Limit)
+	} else {
+		qb = qb.OrderBy("CreateAt, ID")
+	}
+
+	q, args, err := qb.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+
+	captions := []*public.CallCaption{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(*s.settings.QueryTimeout)*time.Second)
+	defer cancel()
+	if err := s.dbXFromGetOpts(opts).SelectContext(ctx, &captions, q, args...); err != nil {
+		return nil, fmt.Errorf("failed to get call captions: %w", err)
+	}
+
+	if opts.Limit > 0 {
+		slices.Reverse(captions)
+	}
+
+	return captions, nil
+}
+
+// DeleteCallCaptions deletes all the captions for the given call.
+func (s *Store) DeleteCallCaptions(callID string) error {
+	s.metrics.IncStoreOp("DeleteCallCaptions")
+	defer func(start time.Time) {
+		s.metrics.ObserveStoreMethodsTime("DeleteCallCaptions", time.Since(start).Seconds())
+	}(time.Now())
+
+	qb := getQueryBuilder().
+		Delete("calls_captions").
+		Where(sq.Eq{"CallID": callID})
+
+	q, args, err := qb.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(*s.settings.QueryTimeout)*time.Second)
+	defer cancel()
+	_, err = s.wDB.ExecContext(ctx, q, args...)
+	if err != nil {
+		return fmt.Errorf("failed to run query: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteCallCaptionsBefore deletes all the captions created before the given
+// time, returning how many were deleted.
+func (s *Store) DeleteCallCaptionsBefore(createAt int64) (int64, error) {
+	s.metrics.IncStoreOp("DeleteCallCaptionsBefore")
+	defer func(start time.Time) {
+		s.metrics.ObserveStoreMethodsTime("DeleteCallCaptionsBefore", time.Since(start).Seconds())
+	}(time.Now())
+
+	qb := getQueryBuilder().
+		Delete("calls_captions").
+		Where(sq.Lt{"CreateAt": createAt})
+
+	q, args, err := qb.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare query: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(*s.settings.QueryTimeout)*time.Second)
+	defer cancel()
+	res, err := s.wDB.ExecContext(ctx, q, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to run query: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package db
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallsCaptionsStore(t *testing.T) {
+	testStore(t, map[string]func(t *testing.T, store *Store){
+		"TestCreateCallCaptions": testCreateCallCaptions,
+		"TestGetCallCaptions":    testGetCallCaptions,
+		"TestDeleteCallCaptions": testDeleteCallCaptions,
+	})
+}
+
+func newTestCallCaption(callID string, createAt int64) *public.CallCaption {
+	return &public.CallCaption{
+		ID:        model.NewId(),
+		CallID:    callID,
+		SessionID: model.NewId(),
+		UserID:    model.NewId(),
+		CreateAt:  createAt,
+		Text:      "caption " + model.NewId(),
+		Language:  "en",
+	}
+}
+
+func testCreateCallCaptions(t *testing.T, store *Store) {
+	t.Run("empty", func(t *testing.T) {
+		err := store.CreateCallCaptions(nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		err := store.CreateCallCaptions([]*public.CallCaption{nil})
+		require.EqualError(t, err, "invalid call caption: should not be nil")
+
+		err = store.CreateCallCaptions([]*public.CallCaption{{}})
+		require.EqualError(t, err, "invalid call caption: invalid ID: should not be empty")
+
+		caption := newTestCallCaption(model.NewId(), 0)
+		err = store.CreateCallCaptions([]*public.CallCaption{caption})
+		require.EqualError(t, err, "invalid call caption: invalid CreateAt: should not be zero")
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		callID := model.NewId()
+		captions := []*public.CallCaption{
+			newTestCallCaption(callID, 1000),
+			newTestCallCaption(callID, 2000),
+		}
+
+		err := store.CreateCallCaptions(captions)
+		require.NoError(t, err)
+
+		gotCaptions, err := store.GetCallCaptions(callID, GetCallCaptionsOpts{})
+		require.NoError(t, err)
+		require.Equal(t, captions, gotCaptions)
+	})
+}
+
+func testGetCallCaptions(t *testing.T, store *Store) {
+	t.Run("no captions", func(t *testing.T) {
+		captions, err := store.GetCallCaptions(model.NewId(), GetCallCaptionsOpts{})
+		require.NoError(t, err)
+		require.Empty(t, captions)
+	})
+
+	callID := model.NewId()
+	captions := []*public.CallCaption{
+		newTestCallCaption(callID, 3000),
+		newTestCallCaption(callID, 1000),
+		newTestCallCaption(callID, 2000),
+		newTestCallCaption(callID, 4000),
+	}
+	err := store.CreateCallCaptions(captions)
+	require.NoError(t, err)
+
+	// Captions for a different call should never be returned.
+	err = store.CreateCallCaptions([]*public.CallCaption{newTestCallCaption(model.NewId(), 1500)})
+	require.NoError(t, err)
+
+	t.Run("all", func(t *testing.T) {
+		gotCaptions, err := store.GetCallCaptions(callID, GetCallCaptionsOpts{})
+		require.NoError(t, err)
+		require.Equal(t, []*public.CallCaption{captions[1], captions[2], captions[0], captions[3]}, gotCaptions)
+	})
+
+	t.Run("since", func(t *testing.T) {
+		gotCaptions, err := store.GetCallCaptions(callID, GetCallCaptionsOpts{Since: 2000})
+		require.NoError(t, err)
+		require.Equal(t, []*public.CallCaption{captions[0], captions[3]}, gotCaptions)
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		gotCaptions, err := store.GetCallCaptions(callID, GetCallCaptionsOpts{Limit: 3})
+		require.NoError(t, err)
+		require.Equal(t, []*public.CallCaption{captions[2], captions[0], captions[3]}, gotCaptions)
+	})
+
+	t.Run("since and limit", func(t *testing.T) {
+		gotCaptions, err := store.GetCallCaptions(callID, GetCallCaptionsOpts{Since: 1000, Limit: 2})
+		require.NoError(t, err)
+		require.Equal(t, []*public.CallCaption{captions[0], captions[3]}, gotCaptions)
+	})
+}
+
+func testDeleteCallCaptions(t *testing.T, store *Store) {
+	callID := model.NewId()
+	otherCallID := model.NewId()
+	captions := []*public.CallCaption{
+		newTestCallCaption(callID, 1000),
+		newTestCallCaption(callID, 2000),
+	}
+	otherCaptions := []*public.CallCaption{
+		newTestCallCaption(otherCallID, 1000),
+		newTestCallCaption(otherCallID, 3000),
+	}
+	err := store.CreateCallCaptions(append(captions, otherCaptions...))
+	require.NoError(t, err)
+
+	t.Run("by call", func(t *testing.T) {
+		err := store.DeleteCallCaptions(callID)
+		require.NoError(t, err)
+
+		gotCaptions, err := store.GetCallCaptions(callID, GetCallCaptionsOpts{})
+		require.NoError(t, err)
+		require.Empty(t, gotCaptions)
+
+		// Captions for a different call should be left alone.
+		gotCaptions, err = store.GetCallCaptions(otherCallID, GetCallCaptionsOpts{})
+		require.NoError(t, err)
+		require.Equal(t, otherCaptions, gotCaptions)
+	})
+
+	t.Run("before", func(t *testing.T) {
+		n, err := store.DeleteCallCaptionsBefore(2000)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), n)
+
+		gotCaptions, err := store.GetCallCaptions(otherCallID, GetCallCaptionsOpts{})
+		require.NoError(t, err)
+		require.Equal(t, otherCaptions[1:], gotCaptions)
+	})
+}
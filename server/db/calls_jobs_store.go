@@ -148,3 +148,35 @@ func (s *Store) GetActiveCallJobs(callID string, opts GetCallJobOpts) (map[publi
 
 	return jobsMap, nil
 }
+
+// GetCallJobs returns all the jobs for the given call, sorted by creation
+// time. Ended jobs are only included if opts.IncludeEnded is set.
+func (s *Store) GetCallJobs(callID string, opts GetCallJobOpts) ([]*public.CallJob, error) {
+	s.metrics.IncStoreOp("GetCallJobs")
+	defer func(start time.Time) {
+		s.metrics.ObserveStoreMethodsTime("GetCallJobs", time.Since(start).Seconds())
+	}(time.Now())
+
+	qb := getQueryBuilder().Select(callsJobsColumns...).
+		From("calls_jobs").
+		Where(sq.Eq{"CallID": callID}).
+		OrderBy("InitAt, ID")
+
+	if !opts.IncludeEnded {
+		qb = qb.Where(sq.Eq{"EndAt": 0})
+	}
+
+	q, args, err := qb.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+
+	jobs := []*public.CallJob{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(*s.settings.QueryTimeout)*time.Second)
+	defer cancel()
+	if err := s.dbXFromGetOpts(opts).SelectContext(ctx, &jobs, q, args...); err != nil {
+		return nil, fmt.Errorf("failed to get call jobs: %w", err)
+	}
+
+	return jobs, nil
+}
@@ -20,6 +20,7 @@ func TestCallsJobsStore(t *testing.T) {
 		"TestUpdateCallJob":                testUpdateCallJob,
 		"TestGetCallJob":                   testGetCallJob,
 		"TestGetActiveCallJobs":            testGetActiveCallJobs,
+		"TestGetCallJobs":                  testGetCallJobs,
 		"TestCallsJobsTableColumnAddition": testCallsJobsTableColumnAddition,
 	})
 }
@@ -261,6 +262,47 @@ func testGetActiveCallJobs(t *testing.T, store *Store) {
 	})
 }
 
+func testGetCallJobs(t *testing.T, store *Store) {
+	t.Run("no jobs", func(t *testing.T) {
+		jobs, err := store.GetCallJobs(model.NewId(), GetCallJobOpts{})
+		require.NoError(t, err)
+		require.Empty(t, jobs)
+	})
+
+	t.Run("multiple jobs", func(t *testing.T) {
+		callID := model.NewId()
+		recJob := &public.CallJob{
+			ID:        model.NewId(),
+			CallID:    callID,
+			Type:      public.JobTypeRecording,
+			CreatorID: model.NewId(),
+			InitAt:    1000,
+		}
+		err := store.CreateCallJob(recJob)
+		require.NoError(t, err)
+
+		trJob := &public.CallJob{
+			ID:        model.NewId(),
+			CallID:    callID,
+			Type:      public.JobTypeTranscribing,
+			CreatorID: model.NewId(),
+			InitAt:    2000,
+			StartAt:   2000,
+			EndAt:     3000,
+		}
+		err = store.CreateCallJob(trJob)
+		require.NoError(t, err)
+
+		jobs, err := store.GetCallJobs(callID, GetCallJobOpts{})
+		require.NoError(t, err)
+		require.Equal(t, []*public.CallJob{recJob}, jobs)
+
+		jobs, err = store.GetCallJobs(callID, GetCallJobOpts{IncludeEnded: true})
+		require.NoError(t, err)
+		require.Equal(t, []*public.CallJob{recJob, trJob}, jobs)
+	})
+}
+
 func testCallsJobsTableColumnAddition(t *testing.T, store *Store) {
 	// This test simulates adding a new column to the calls_jobs table
 	// and verifies that existing code can still fetch data correctly
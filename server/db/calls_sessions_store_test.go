@@ -75,6 +75,52 @@ func testCreateCallSession(t *testing.T, store *Store) {
 		require.NoError(t, err)
 		require.Equal(t, session, gotSession)
 	})
+
+	t.Run("phone session", func(t *testing.T) {
+		id := model.NewId()
+		session := &public.CallSession{
+			ID:       id,
+			CallID:   model.NewId(),
+			UserID:   id,
+			JoinAt:   time.Now().UnixMilli(),
+			Type:     public.CallSessionTypePhone,
+			CallerID: "+*******4567",
+		}
+
+		err := store.CreateCallSession(session)
+		require.NoError(t, err)
+
+		gotSession, err := store.GetCallSession(session.ID, GetCallSessionOpts{
+			FromWriter: true,
+		})
+		require.NoError(t, err)
+		require.Equal(t, session, gotSession)
+
+		session.Type = "invalid"
+		err = store.CreateCallSession(session)
+		require.EqualError(t, err, `invalid call session: invalid Type: "invalid"`)
+	})
+
+	t.Run("guest session", func(t *testing.T) {
+		id := model.NewId()
+		session := &public.CallSession{
+			ID:          id,
+			CallID:      model.NewId(),
+			UserID:      id,
+			JoinAt:      time.Now().UnixMilli(),
+			Type:        public.CallSessionTypeGuest,
+			DisplayName: "Jane Doe",
+		}
+
+		err := store.CreateCallSession(session)
+		require.NoError(t, err)
+
+		gotSession, err := store.GetCallSession(session.ID, GetCallSessionOpts{
+			FromWriter: true,
+		})
+		require.NoError(t, err)
+		require.Equal(t, session, gotSession)
+	})
 }
 
 func testUpdateCallSession(t *testing.T, store *Store) {
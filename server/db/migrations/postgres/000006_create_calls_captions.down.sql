@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_calls_captions_call_id_create_at;
+
+DROP TABLE IF EXISTS calls_captions;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS calls_captions (
+    id VARCHAR(26) PRIMARY KEY,
+    callid VARCHAR(26),
+    sessionid VARCHAR(26),
+    userid VARCHAR(26),
+    createat bigint,
+    text text,
+    language VARCHAR(8)
+);
+
+CREATE INDEX IF NOT EXISTS idx_calls_captions_call_id_create_at ON calls_captions (callid, createat);
@@ -0,0 +1,2 @@
+ALTER TABLE calls_sessions DROP COLUMN IF EXISTS callerid;
+ALTER TABLE calls_sessions DROP COLUMN IF EXISTS type;
@@ -0,0 +1,2 @@
+ALTER TABLE calls_sessions ADD COLUMN IF NOT EXISTS type varchar(16) NOT NULL DEFAULT '';
+ALTER TABLE calls_sessions ADD COLUMN IF NOT EXISTS callerid varchar(64) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE calls_sessions DROP COLUMN IF EXISTS displayname;
@@ -0,0 +1 @@
+ALTER TABLE calls_sessions ADD COLUMN IF NOT EXISTS displayname varchar(64) NOT NULL DEFAULT '';
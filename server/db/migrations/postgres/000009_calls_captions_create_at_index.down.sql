@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_calls_captions_create_at;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_calls_captions_create_at ON calls_captions (createat);
@@ -42,6 +42,20 @@ func (o GetCallJobOpts) UseWriter() bool {
 	return o.FromWriter
 }
 
+type GetCallCaptionsOpts struct {
+	FromWriter bool
+	// Since filters out captions created at or before the given timestamp
+	// (in milliseconds).
+	Since int64
+	// Limit is the maximum number of (most recent) captions to return. Zero
+	// means no limit.
+	Limit uint64
+}
+
+func (o GetCallCaptionsOpts) UseWriter() bool {
+	return o.FromWriter
+}
+
 type getOpts interface {
 	UseWriter() bool
 }
@@ -0,0 +1,402 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	"github.com/gorilla/mux"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	rtcd "github.com/mattermost/rtcd/service"
+	"github.com/mattermost/rtcd/service/rtc"
+)
+
+// External sessions are the ones not backed by a Mattermost user and
+// websocket connection (e.g. phone participants and guests). Their clients
+// send messages through HTTP requests and receive events through a
+// type specific channel.
+
+const (
+	externalEventSignal = "signal"
+	externalEventMute   = "mute"
+	externalEventHangup = "hangup"
+)
+
+const externalRequestBodyMaxSize = 1024 * 1024 // 1MB
+
+var errCallParticipantsLimit = errors.New("user cannot join because of limits")
+
+// externalEvent is sent to the client of an external session.
+type externalEvent struct {
+	Type      string `json:"type"`
+	ChannelID string `json:"channel_id"`
+	SessionID string `json:"session_id"`
+	Data      string `json:"data,omitempty"`
+}
+
+// getExternalSession returns the call state and the session of the given type
+// matching the given ID.
+func (p *Plugin) getExternalSession(channelID, sessionID string, sessionType public.CallSessionType) (*callState, *public.CallSession, error) {
+	state, err := p.getCallState(channelID, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if state == nil {
+		return nil, nil, ErrNoCallOngoing
+	}
+
+	ust, ok := state.sessions[sessionID]
+	if !ok || ust.Type != sessionType {
+		return nil, nil, ErrNotInCall
+	}
+
+	return state, ust, nil
+}
+
+// joinExternalSession adds the given session to the ongoing call in the given
+// channel. External sessions can't start calls. The session ID is generated
+// unless already set. The optional checkFn is run while holding the call lock,
+// to allow further validation.
+func (p *Plugin) joinExternalSession(channelID string, ust *public.CallSession, checkFn func(state *callState) error) (*session, error) {
+	state, err := p.lockCallReturnState(channelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock call: %w", err)
+	}
+	defer p.unlockCall(channelID)
+
+	if state == nil {
+		return nil, ErrNoCallOngoing
+	}
+
+	if checkFn != nil {
+		if err := checkFn(state); err != nil {
+			return nil, err
+		}
+	}
+
+	// External sessions don't belong to any user so the session ID is used in
+	// place of the user ID.
+	if ust.ID == "" {
+		ust.ID = model.NewId()
+	}
+	sessionID := ust.ID
+	ust.UserID = sessionID
+	state, err = p.addUserSession(state, nil, ust, channelID, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	handlerID := state.Call.Props.NodeID
+	us := newUserSession(sessionID, channelID, sessionID, state.Call.ID, p.rtcdManager == nil && handlerID == p.nodeID)
+	us.sessionType = ust.Type
+	if ust.IsGuest() {
+		us.guestEventCh = make(chan externalEvent, msgChSize)
+	}
+	p.mut.Lock()
+	p.sessions[sessionID] = us
+	p.mut.Unlock()
+
+	if err := p.initExternalRTCSession(us, state); err != nil {
+		p.mut.Lock()
+		delete(p.sessions, sessionID)
+		p.mut.Unlock()
+		if err := p.store.DeleteCallSession(sessionID); err != nil {
+			p.LogError("failed to delete call session", "sessionID", sessionID, "err", err.Error())
+		}
+		return nil, err
+	}
+
+	p.LogDebug("external session has joined call", "sessionID", sessionID, "type", ust.Type, "channelID", channelID, "callID", state.Call.ID)
+
+	p.publishWebSocketEvent(wsEventUserJoined, map[string]interface{}{
+		"user_id":      sessionID,
+		"session_id":   sessionID,
+		"type":         ust.Type,
+		"caller_id":    ust.CallerID,
+		"display_name": ust.DisplayName,
+	}, &WebSocketBroadcast{ChannelID: channelID, ReliableClusterSend: true})
+
+	go p.externalSessionHandler(us, handlerID)
+
+	return us, nil
+}
+
+func (p *Plugin) initExternalRTCSession(us *session, state *callState) error {
+	handlerID := state.Call.Props.NodeID
+	props := rtc.SessionProps{
+		"channelID":   us.channelID,
+		"sessionType": string(us.sessionType),
+	}
+
+	if p.rtcdManager != nil {
+		msg := rtcd.ClientMessage{
+			Type: rtcd.ClientMessageJoin,
+			Data: map[string]any{
+				"callID":    us.callID,
+				"userID":    us.userID,
+				"sessionID": us.connID,
+				"channelID": us.channelID,
+			},
+		}
+		if err := p.rtcdManager.Send(msg, state.Call.Props.RTCDHost); err != nil {
+			return fmt.Errorf("failed to send client join message: %w", err)
+		}
+		return nil
+	}
+
+	if handlerID != p.nodeID {
+		if err := p.sendClusterMessage(clusterMessage{
+			ConnID:       us.connID,
+			UserID:       us.userID,
+			ChannelID:    us.channelID,
+			CallID:       us.callID,
+			SenderID:     p.nodeID,
+			SessionProps: props,
+		}, clusterMessageTypeConnect, handlerID); err != nil {
+			return fmt.Errorf("failed to send connect message: %w", err)
+		}
+		return nil
+	}
+
+	cfg := rtc.SessionConfig{
+		GroupID:   "default",
+		CallID:    us.callID,
+		UserID:    us.userID,
+		SessionID: us.connID,
+		Props:     props,
+	}
+	if err := p.rtcServer.InitSession(cfg, func() error {
+		if atomic.CompareAndSwapInt32(&us.rtcClosed, 0, 1) {
+			close(us.rtcCloseCh)
+			return p.removeSession(us)
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to init session: %w", err)
+	}
+
+	return nil
+}
+
+// externalSessionHandler waits for an external session to end. Since there's
+// no websocket connection backing it, this happens either when the client
+// leaves or when the RTC connection is closed.
+func (p *Plugin) externalSessionHandler(us *session, handlerID string) {
+	select {
+	case <-us.leaveCh:
+		p.LogDebug("external session left call", "sessionID", us.connID, "channelID", us.channelID)
+	case <-us.rtcCloseCh:
+		p.LogDebug("rtc connection was closed", "sessionID", us.connID, "channelID", us.channelID)
+		return
+	case <-p.stopCh:
+		return
+	}
+
+	if err := p.closeRTCSession(us.userID, us.originalConnID, us.channelID, handlerID, us.callID); err != nil {
+		p.LogError(err.Error())
+	}
+
+	if err := p.removeSession(us); err != nil {
+		p.LogError(err.Error())
+	}
+}
+
+// leaveExternalSession ends the given external session. The request is
+// forwarded to all nodes as the session may be owned by a different one.
+func (p *Plugin) leaveExternalSession(channelID, callID, sessionID string) error {
+	p.mut.RLock()
+	us := p.sessions[sessionID]
+	p.mut.RUnlock()
+
+	if us != nil && us.sessionType != "" {
+		if atomic.CompareAndSwapInt32(&us.left, 0, 1) {
+			close(us.leaveCh)
+		}
+		return nil
+	}
+
+	return p.sendClusterMessage(clusterMessage{
+		ConnID:    sessionID,
+		UserID:    sessionID,
+		ChannelID: channelID,
+		CallID:    callID,
+		SenderID:  p.nodeID,
+	}, clusterMessageTypeLeave, "")
+}
+
+// newExternalSessionProxy returns a lightweight session built from the call
+// state. The session handling the RTC connection could live on any node but
+// this is all that's needed to route client messages.
+func newExternalSessionProxy(state *callState, ust *public.CallSession) *session {
+	return &session{
+		userID:         ust.UserID,
+		channelID:      state.Call.ChannelID,
+		connID:         ust.ID,
+		originalConnID: ust.ID,
+		callID:         state.Call.ID,
+		sessionType:    ust.Type,
+	}
+}
+
+// sendExternalEvent delivers an event to the client of an external session
+// of the given type.
+func (p *Plugin) sendExternalEvent(sessionType public.CallSessionType, ev externalEvent) {
+	switch sessionType {
+	case public.CallSessionTypePhone:
+		p.sendGatewayEvent(ev)
+	case public.CallSessionTypeGuest:
+		p.sendGuestEvent(ev)
+	default:
+		p.LogError("unexpected session type", "type", sessionType, "sessionID", ev.SessionID)
+	}
+}
+
+// relaySignal forwards an RTC signaling message to the client owning the
+// session, be it a websocket connection or an external client.
+func (p *Plugin) relaySignal(us *session, sessionID string, data []byte) {
+	if us.sessionType != "" {
+		p.sendExternalEvent(us.sessionType, externalEvent{
+			Type:      externalEventSignal,
+			ChannelID: us.channelID,
+			SessionID: sessionID,
+			Data:      string(data),
+		})
+		return
+	}
+
+	p.publishWebSocketEvent(wsEventSignal, map[string]interface{}{
+		"data":   string(data),
+		"connID": sessionID,
+	}, &WebSocketBroadcast{ConnectionID: us.connID, ReliableClusterSend: true})
+}
+
+// muteExternalSession mutes an external participant on behalf of the host.
+// Unlike regular clients, which are asked to mute themselves, this is
+// enforced server side. The client is notified so that it can let the
+// participant know.
+func (p *Plugin) muteExternalSession(state *callState, ust *public.CallSession) error {
+	if err := p.handleClientMsg(newExternalSessionProxy(state, ust), clientMessage{Type: clientMessageTypeMute}, state.Call.Props.NodeID); err != nil {
+		return fmt.Errorf("failed to mute session: %w", err)
+	}
+
+	p.sendExternalEvent(ust.Type, externalEvent{
+		Type:      externalEventMute,
+		ChannelID: state.Call.ChannelID,
+		SessionID: ust.ID,
+	})
+
+	return nil
+}
+
+// removeExternalSession removes an external participant on behalf of the
+// host. There's no client to end the session cleanly so we do it right away,
+// letting the client know it should hang up.
+func (p *Plugin) removeExternalSession(state *callState, ust *public.CallSession) error {
+	p.sendExternalEvent(ust.Type, externalEvent{
+		Type:      externalEventHangup,
+		ChannelID: state.Call.ChannelID,
+		SessionID: ust.ID,
+	})
+
+	return p.leaveExternalSession(state.Call.ChannelID, state.Call.ID, ust.ID)
+}
+
+// handleExternalSessionMessage handles signaling and mute/unmute messages for
+// an external session. These are processed the same way as the ones sent by
+// clients over websocket.
+func (p *Plugin) handleExternalSessionMessage(w http.ResponseWriter, r *http.Request, sessionType public.CallSessionType) {
+	var res httpResponse
+	defer p.httpAudit("handleExternalSessionMessage", &res, w, r)
+
+	channelID := mux.Vars(r)["channel_id"]
+	sessionID := mux.Vars(r)["session_id"]
+
+	var data struct {
+		Type string `json:"type"`
+		Data string `json:"data"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, externalRequestBodyMaxSize)).Decode(&data); err != nil {
+		res.Err = "failed to decode request body: " + err.Error()
+		res.Code = http.StatusBadRequest
+		return
+	}
+
+	switch data.Type {
+	case clientMessageTypeSDP, clientMessageTypeICE, clientMessageTypeMute, clientMessageTypeUnmute:
+	default:
+		res.Err = fmt.Sprintf("unsupported message type %q", data.Type)
+		res.Code = http.StatusBadRequest
+		return
+	}
+
+	state, ust, err := p.getExternalSession(channelID, sessionID, sessionType)
+	if err != nil {
+		res.Err = err.Error()
+		res.Code = getExternalSessionErrCode(err)
+		return
+	}
+
+	if err := p.handleClientMsg(newExternalSessionProxy(state, ust), clientMessage{Type: data.Type, Data: []byte(data.Data)}, state.Call.Props.NodeID); err != nil {
+		res.Err = err.Error()
+		res.Code = http.StatusInternalServerError
+		if errors.Is(err, errNotPresenter) {
+			res.Code = http.StatusForbidden
+		}
+		return
+	}
+
+	res.Code = http.StatusOK
+	res.Msg = "success"
+}
+
+// handleExternalSessionLeave ends an external session on behalf of its
+// client.
+func (p *Plugin) handleExternalSessionLeave(w http.ResponseWriter, r *http.Request, sessionType public.CallSessionType) {
+	var res httpResponse
+	defer p.httpAudit("handleExternalSessionLeave", &res, w, r)
+
+	channelID := mux.Vars(r)["channel_id"]
+	sessionID := mux.Vars(r)["session_id"]
+
+	state, _, err := p.getExternalSession(channelID, sessionID, sessionType)
+	if err != nil {
+		res.Err = err.Error()
+		res.Code = getExternalSessionErrCode(err)
+		return
+	}
+
+	if err := p.leaveExternalSession(channelID, state.Call.ID, sessionID); err != nil {
+		res.Err = err.Error()
+		res.Code = http.StatusInternalServerError
+		return
+	}
+
+	res.Code = http.StatusOK
+	res.Msg = "success"
+}
+
+func getExternalSessionErrCode(err error) int {
+	if errors.Is(err, ErrNoCallOngoing) || errors.Is(err, ErrNotInCall) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func getJoinExternalSessionErrCode(err error) int {
+	switch {
+	case errors.Is(err, ErrNoCallOngoing):
+		return http.StatusNotFound
+	case errors.Is(err, errCallParticipantsLimit), errors.Is(err, ErrNotAllowed):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,942 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	"github.com/gorilla/mux"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	guestInviteKeyPrefix       = "guest_invite_"
+	guestInvitesIndexKeyPrefix = "guest_invites_"
+	guestSessionKeyPrefix      = "guest_session_"
+	guestTokenHeader           = "X-Calls-Guest-Token"
+	guestSecretLen             = 32
+	guestNameMaxLen            = 64
+	// Guest sessions credentials outlive any reasonable call.
+	guestSessionExpirySeconds = 24 * 60 * 60
+	guestEventsPollTimeout    = 25 * time.Second
+	// How much longer than guestEventsPollTimeout a node relaying a poll waits
+	// for the node holding the queue to answer.
+	guestEventsRelayGracePeriod = 5 * time.Second
+	guestEventsMaxBatchSize     = 50
+	guestLimitersMaxSize        = 10000
+	// A limiter idle for this long has fully refilled so it's safe to drop it.
+	guestLimiterIdleTimeout = time.Minute
+)
+
+var (
+	errGuestRateLimited    = errors.New("too many requests")
+	errGuestInviteNotFound = errors.New("invite not found")
+	errGuestInviteExpired  = errors.New("invite has expired")
+	errGuestInviteInUse    = errors.New("invite is already in use")
+	errGuestNameInvalid    = errors.New("invalid name")
+)
+
+// guestInvite lets an external person join a specific call as a guest. Only a
+// hash of the secret part of the token is persisted.
+type guestInvite struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	CallID    string `json:"call_id"`
+	CreatorID string `json:"creator_id"`
+	Name      string `json:"name,omitempty"`
+	CreateAt  int64  `json:"create_at"`
+	ExpireAt  int64  `json:"expire_at"`
+	TokenHash string `json:"token_hash,omitempty"`
+	// The ID of the guest session created through this invite, if any.
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// guestSession holds the credentials of a guest which joined a call.
+type guestSession struct {
+	ID         string `json:"id"`
+	ChannelID  string `json:"channel_id"`
+	CallID     string `json:"call_id"`
+	InviteID   string `json:"invite_id"`
+	SecretHash string `json:"secret_hash"`
+}
+
+func hashGuestSecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+func checkGuestSecret(secret, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashGuestSecret(secret)), []byte(hash)) == 1
+}
+
+// newGuestInviteToken returns a new token for the invite with the given ID,
+// along with the hash to be persisted.
+func newGuestInviteToken(inviteID string) (string, string) {
+	secret := model.NewRandomString(guestSecretLen)
+	return inviteID + secret, hashGuestSecret(secret)
+}
+
+// parseGuestInviteToken splits a token into the invite ID and secret parts.
+func parseGuestInviteToken(token string) (string, string, bool) {
+	if len(token) != 26+guestSecretLen {
+		return "", "", false
+	}
+
+	inviteID, secret := token[:26], token[26:]
+	if !model.IsValidId(inviteID) {
+		return "", "", false
+	}
+
+	return inviteID, secret, true
+}
+
+func sanitizeGuestName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: should not be empty", errGuestNameInvalid)
+	}
+	if !utf8.ValidString(name) || utf8.RuneCountInString(name) > guestNameMaxLen {
+		return "", fmt.Errorf("%w: should be valid UTF-8 of at most %d characters", errGuestNameInvalid, guestNameMaxLen)
+	}
+	return name, nil
+}
+
+// validate checks whether the invite can be used to join the given call.
+func (i *guestInvite) validate(state *callState, now time.Time) error {
+	if now.UnixMilli() >= i.ExpireAt {
+		return errGuestInviteExpired
+	}
+
+	if state == nil || state.Call.ID != i.CallID {
+		return ErrNoCallOngoing
+	}
+
+	if i.SessionID != "" {
+		if _, ok := state.sessions[i.SessionID]; ok {
+			return errGuestInviteInUse
+		}
+	}
+
+	return nil
+}
+
+func (i *guestInvite) expirySeconds(now time.Time) int64 {
+	// Keeping at least a second as a zero value would mean no expiry at all.
+	return max(1, (i.ExpireAt-now.UnixMilli())/1000)
+}
+
+// getGuestInvite returns the invite with the given ID, or nil if not found.
+func (p *Plugin) getGuestInvite(id string) (*guestInvite, error) {
+	var invite guestInvite
+	if ok, err := p.kvGetJSON(guestInviteKeyPrefix+id, &invite); err != nil || !ok {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// getGuestInvites returns all the invites for the given call that haven't
+// expired yet.
+func (p *Plugin) getGuestInvites(callID string) ([]*guestInvite, error) {
+	var ids []string
+	if _, err := p.kvGetJSON(guestInvitesIndexKeyPrefix+callID, &ids); err != nil {
+		return nil, err
+	}
+
+	invites := make([]*guestInvite, 0, len(ids))
+	for _, id := range ids {
+		invite, err := p.getGuestInvite(id)
+		if err != nil {
+			return nil, err
+		}
+		// Expired invites are removed from the KV store automatically.
+		if invite == nil {
+			continue
+		}
+		invites = append(invites, invite)
+	}
+
+	return invites, nil
+}
+
+// setGuestInvitesIndex stores the IDs of the invites for a call. It must be
+// called while holding the call lock.
+func (p *Plugin) setGuestInvitesIndex(callID string, invites []*guestInvite) error {
+	ids := make([]string, 0, len(invites))
+	for _, invite := range invites {
+		ids = append(ids, invite.ID)
+	}
+
+	if len(ids) == 0 {
+		if appErr := p.API.KVDelete(guestInvitesIndexKeyPrefix + callID); appErr != nil {
+			return fmt.Errorf("failed to delete invites index: %w", appErr)
+		}
+		return nil
+	}
+
+	return p.kvSetJSON(guestInvitesIndexKeyPrefix+callID, ids, int64(maxGuestInvitesExpiryMinutes*60))
+}
+
+func (p *Plugin) checkGuestInvitesPermissions(requesterID string, state *callState) error {
+	if state == nil {
+		return ErrNoCallOngoing
+	}
+
+	if requesterID != state.Call.GetHostID() {
+		if isAdmin := p.API.HasPermissionTo(requesterID, model.PermissionManageSystem); !isAdmin {
+			return ErrNoPermissions
+		}
+	}
+
+	return nil
+}
+
+// createGuestInvite generates a new invite for the ongoing call in the given
+// channel, returning it along with its token.
+func (p *Plugin) createGuestInvite(requesterID, channelID, name string, expiresIn time.Duration) (*guestInvite, string, error) {
+	state, err := p.lockCallReturnState(channelID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to lock call: %w", err)
+	}
+	defer p.unlockCall(channelID)
+
+	if err := p.checkGuestInvitesPermissions(requesterID, state); err != nil {
+		return nil, "", err
+	}
+
+	invites, err := p.getGuestInvites(state.Call.ID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	invite := &guestInvite{
+		ID:        model.NewId(),
+		ChannelID: channelID,
+		CallID:    state.Call.ID,
+		CreatorID: requesterID,
+		Name:      name,
+		CreateAt:  now.UnixMilli(),
+		ExpireAt:  now.Add(expiresIn).UnixMilli(),
+	}
+	token, hash := newGuestInviteToken(invite.ID)
+	invite.TokenHash = hash
+
+	if err := p.kvSetJSON(guestInviteKeyPrefix+invite.ID, invite, invite.expirySeconds(now)); err != nil {
+		return nil, "", err
+	}
+
+	if err := p.setGuestInvitesIndex(state.Call.ID, append(invites, invite)); err != nil {
+		return nil, "", err
+	}
+
+	return invite, token, nil
+}
+
+// revokeGuestInvite deletes the given invite, removing the guest which joined
+// through it from the call.
+func (p *Plugin) revokeGuestInvite(requesterID, channelID, inviteID string) error {
+	state, err := p.lockCallReturnState(channelID)
+	if err != nil {
+		return fmt.Errorf("failed to lock call: %w", err)
+	}
+	defer p.unlockCall(channelID)
+
+	if err := p.checkGuestInvitesPermissions(requesterID, state); err != nil {
+		return err
+	}
+
+	invites, err := p.getGuestInvites(state.Call.ID)
+	if err != nil {
+		return err
+	}
+
+	for i, invite := range invites {
+		if invite.ID != inviteID {
+			continue
+		}
+
+		if err := p.deleteGuestInvite(state.Call.ID, invite, append(invites[:i:i], invites[i+1:]...)); err != nil {
+			return err
+		}
+
+		if ust, ok := state.sessions[invite.SessionID]; ok && ust.IsGuest() {
+			p.publishWebSocketEvent(wsEventHostRemoved, map[string]interface{}{
+				"call_id":    state.Call.ID,
+				"channel_id": channelID,
+				"session_id": ust.ID,
+				"user_id":    ust.UserID,
+			}, &WebSocketBroadcast{
+				ChannelID:           channelID,
+				ReliableClusterSend: true,
+				UserIDs:             getUserIDsFromSessions(state.sessions),
+			})
+			return p.removeExternalSession(state, ust)
+		}
+
+		return nil
+	}
+
+	return errGuestInviteNotFound
+}
+
+// revokeGuestInviteForSession deletes the invite through which the given guest
+// session joined the call, so that it can't be used again.
+func (p *Plugin) revokeGuestInviteForSession(channelID, sessionID string) error {
+	state, err := p.lockCallReturnState(channelID)
+	if err != nil {
+		return fmt.Errorf("failed to lock call: %w", err)
+	}
+	defer p.unlockCall(channelID)
+
+	if state == nil {
+		return ErrNoCallOngoing
+	}
+
+	invites, err := p.getGuestInvites(state.Call.ID)
+	if err != nil {
+		return err
+	}
+
+	for i, invite := range invites {
+		if invite.SessionID == sessionID {
+			return p.deleteGuestInvite(state.Call.ID, invite, append(invites[:i:i], invites[i+1:]...))
+		}
+	}
+
+	return nil
+}
+
+func (p *Plugin) deleteGuestInvite(callID string, invite *guestInvite, remaining []*guestInvite) error {
+	if appErr := p.API.KVDelete(guestInviteKeyPrefix + invite.ID); appErr != nil {
+		return fmt.Errorf("failed to delete invite: %w", appErr)
+	}
+
+	if invite.SessionID != "" {
+		if appErr := p.API.KVDelete(guestSessionKeyPrefix + invite.SessionID); appErr != nil {
+			p.LogWarn("failed to delete guest session", "sessionID", invite.SessionID, "err", appErr.Error())
+		}
+	}
+
+	return p.setGuestInvitesIndex(callID, remaining)
+}
+
+// joinGuestSession exchanges an invite token for a guest session in the call
+// the invite was generated for. The returned secret authenticates any further
+// request made on behalf of the guest.
+func (p *Plugin) joinGuestSession(token, name string) (*session, string, error) {
+	inviteID, secret, ok := parseGuestInviteToken(token)
+	if !ok {
+		return nil, "", errGuestInviteNotFound
+	}
+
+	invite, err := p.getGuestInvite(inviteID)
+	if err != nil {
+		return nil, "", err
+	}
+	if invite == nil || !checkGuestSecret(secret, invite.TokenHash) {
+		return nil, "", errGuestInviteNotFound
+	}
+
+	if invite.Name != "" {
+		name = invite.Name
+	}
+	name, err = sanitizeGuestName(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sessionSecret := model.NewRandomString(guestSecretLen)
+	ust := &public.CallSession{
+		ID:          model.NewId(),
+		Type:        public.CallSessionTypeGuest,
+		DisplayName: name,
+	}
+
+	us, err := p.joinExternalSession(invite.ChannelID, ust, func(state *callState) error {
+		// Fetching the invite again now that we hold the lock, as it could have
+		// been revoked or used in the meantime.
+		invite, err := p.getGuestInvite(inviteID)
+		if err != nil {
+			return err
+		}
+		if invite == nil {
+			return errGuestInviteNotFound
+		}
+
+		now := time.Now()
+		if err := invite.validate(state, now); err != nil {
+			return err
+		}
+
+		if err := p.kvSetJSON(guestSessionKeyPrefix+ust.ID, guestSession{
+			ID:         ust.ID,
+			ChannelID:  invite.ChannelID,
+			CallID:     invite.CallID,
+			InviteID:   invite.ID,
+			SecretHash: hashGuestSecret(sessionSecret),
+		}, guestSessionExpirySeconds); err != nil {
+			return err
+		}
+
+		invite.SessionID = ust.ID
+		return p.kvSetJSON(guestInviteKeyPrefix+invite.ID, invite, invite.expirySeconds(now))
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return us, sessionSecret, nil
+}
+
+// sendGuestEvent queues an event for a guest client. The queue lives on the
+// node that accepted the join request, so the event is forwarded there if
+// needed. Polls reaching any other node are relayed to it (see
+// pollRemoteGuestEvents).
+func (p *Plugin) sendGuestEvent(ev externalEvent) {
+	p.mut.RLock()
+	us := p.sessions[ev.SessionID]
+	p.mut.RUnlock()
+
+	if us != nil && us.guestEventCh != nil {
+		p.enqueueGuestEvent(us, ev)
+		return
+	}
+
+	if err := p.sendClusterMessage(clusterMessage{
+		ConnID:    ev.SessionID,
+		UserID:    ev.SessionID,
+		ChannelID: ev.ChannelID,
+		SenderID:  p.nodeID,
+		ClientMessage: clientMessage{
+			Type: ev.Type,
+			Data: []byte(ev.Data),
+		},
+	}, clusterMessageTypeGuestEvent, ""); err != nil {
+		p.LogError("failed to send guest event", "sessionID", ev.SessionID, "err", err.Error())
+	}
+}
+
+func (p *Plugin) enqueueGuestEvent(us *session, ev externalEvent) {
+	select {
+	case us.guestEventCh <- ev:
+	default:
+		p.LogError("too many guest events, channel is full, dropping.", "type", ev.Type, "sessionID", ev.SessionID)
+	}
+}
+
+// waitGuestEvents waits for events to be queued for the given guest session
+// and returns them in batches. It returns false if doneCh is closed first.
+func (p *Plugin) waitGuestEvents(us *session, doneCh <-chan struct{}) ([]externalEvent, bool) {
+	events := []externalEvent{}
+	timer := time.NewTimer(guestEventsPollTimeout)
+	defer timer.Stop()
+
+	select {
+	case ev := <-us.guestEventCh:
+		events = append(events, ev)
+	case <-us.leaveCh:
+	case <-us.rtcCloseCh:
+	case <-timer.C:
+	case <-doneCh:
+		return nil, false
+	}
+
+	// Draining whatever else is available without waiting.
+drain:
+	for len(events) < guestEventsMaxBatchSize {
+		select {
+		case ev := <-us.guestEventCh:
+			events = append(events, ev)
+		default:
+			break drain
+		}
+	}
+
+	return events, true
+}
+
+// pollRemoteGuestEvents relays a poll for the given guest session to the node
+// holding its queue and waits for the answer. The owning node isn't known so
+// the poll is sent to all nodes and only that one replies.
+func (p *Plugin) pollRemoteGuestEvents(ctx context.Context, channelID, sessionID string) ([]externalEvent, error) {
+	requestID := model.NewId()
+	eventsCh := make(chan []externalEvent, 1)
+
+	p.guestEventsPollsMut.Lock()
+	if p.guestEventsPolls == nil {
+		p.guestEventsPolls = map[string]chan []externalEvent{}
+	}
+	p.guestEventsPolls[requestID] = eventsCh
+	p.guestEventsPollsMut.Unlock()
+
+	defer func() {
+		p.guestEventsPollsMut.Lock()
+		delete(p.guestEventsPolls, requestID)
+		p.guestEventsPollsMut.Unlock()
+	}()
+
+	if err := p.sendClusterMessage(clusterMessage{
+		ConnID:    sessionID,
+		UserID:    sessionID,
+		ChannelID: channelID,
+		SenderID:  p.nodeID,
+		RequestID: requestID,
+	}, clusterMessageTypeGuestEventsPoll, ""); err != nil {
+		return nil, fmt.Errorf("failed to send poll message: %w", err)
+	}
+
+	timer := time.NewTimer(guestEventsPollTimeout + guestEventsRelayGracePeriod)
+	defer timer.Stop()
+
+	select {
+	case events := <-eventsCh:
+		return events, nil
+	case <-timer.C:
+		// The node holding the queue may have gone away. Answering with no
+		// events so that the client polls again.
+		return []externalEvent{}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// relayGuestEvents answers a poll relayed by the given node with the events
+// queued for the given guest session.
+func (p *Plugin) relayGuestEvents(us *session, nodeID, requestID string) {
+	events, ok := p.waitGuestEvents(us, p.stopCh)
+	if !ok {
+		return
+	}
+
+	data, err := json.Marshal(events)
+	if err != nil {
+		p.LogError("failed to marshal guest events", "sessionID", us.connID, "err", err.Error())
+		return
+	}
+
+	if err := p.sendClusterMessage(clusterMessage{
+		ConnID:    us.connID,
+		UserID:    us.userID,
+		ChannelID: us.channelID,
+		SenderID:  p.nodeID,
+		RequestID: requestID,
+		ClientMessage: clientMessage{
+			Type: string(clusterMessageTypeGuestEvents),
+			Data: data,
+		},
+	}, clusterMessageTypeGuestEvents, nodeID); err != nil {
+		p.LogError("failed to send guest events", "sessionID", us.connID, "err", err.Error())
+	}
+}
+
+// deliverGuestEvents hands the events received from the node holding a guest
+// queue to the pending poll they answer.
+func (p *Plugin) deliverGuestEvents(requestID string, events []externalEvent) {
+	p.guestEventsPollsMut.Lock()
+	defer p.guestEventsPollsMut.Unlock()
+
+	eventsCh := p.guestEventsPolls[requestID]
+	if eventsCh == nil {
+		p.LogWarn("no pending poll for guest events, dropping.", "requestID", requestID, "count", len(events))
+		return
+	}
+
+	select {
+	case eventsCh <- events:
+	default:
+		p.LogWarn("guest events already delivered, dropping.", "requestID", requestID, "count", len(events))
+	}
+}
+
+// guestClientAddr returns the address of the client making an unauthenticated
+// guest request. Forwarding headers are only honored when the request comes
+// from one of the configured trusted proxies since they can otherwise be set
+// to anything by the client.
+func guestClientAddr(r *http.Request, trustedProxies []netip.Prefix) string {
+	addr := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+
+	isTrusted := func(addr string) bool {
+		ip, err := netip.ParseAddr(strings.TrimSpace(addr))
+		if err != nil {
+			return false
+		}
+		for _, prefix := range trustedProxies {
+			if prefix.Contains(ip.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !isTrusted(addr) {
+		return addr
+	}
+
+	// Proxies append the address they received the request from so we walk
+	// the list backwards, skipping our own proxies. Anything further left
+	// could have been set by the client.
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		hops := strings.Split(fwd, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if i == 0 || !isTrusted(hop) {
+				return hop
+			}
+		}
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+
+	return addr
+}
+
+// guestLimiter rate limits the unauthenticated guest requests coming from a
+// single address.
+type guestLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// checkGuestRateLimits rate limits unauthenticated guest requests by client
+// address. Limiters are kept apart from the user based ones and dropped once
+// idle so that requests from many different addresses can't grow the map
+// indefinitely. Should it fill up anyway, new addresses are rejected until
+// some space is freed.
+func (p *Plugin) checkGuestRateLimits(r *http.Request) error {
+	trustedProxies, err := p.getConfiguration().getGuestInvitesTrustedProxies()
+	if err != nil {
+		p.LogWarn("failed to parse trusted proxies", "err", err.Error())
+	}
+	addr := guestClientAddr(r, trustedProxies)
+
+	p.guestLimitersMut.Lock()
+	defer p.guestLimitersMut.Unlock()
+
+	if p.guestLimiters == nil {
+		p.guestLimiters = map[string]*guestLimiter{}
+	}
+
+	now := time.Now()
+	gl := p.guestLimiters[addr]
+	if gl == nil {
+		if len(p.guestLimiters) >= guestLimitersMaxSize {
+			for key, gl := range p.guestLimiters {
+				if now.Sub(gl.lastSeen) > guestLimiterIdleTimeout {
+					delete(p.guestLimiters, key)
+				}
+			}
+		}
+		if len(p.guestLimiters) >= guestLimitersMaxSize {
+			return errGuestRateLimited
+		}
+		gl = &guestLimiter{
+			limiter: rate.NewLimiter(1, 10),
+		}
+		p.guestLimiters[addr] = gl
+	}
+	gl.lastSeen = now
+
+	if !gl.limiter.Allow() {
+		return errGuestRateLimited
+	}
+
+	return nil
+}
+
+// guestInvitesEnabledMiddleware rejects requests when guest invites are
+// disabled.
+func (p *Plugin) guestInvitesEnabledMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !p.getConfiguration().guestInvitesEnabled() {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// guestSessionAuthMiddleware only lets through requests carrying the secret
+// of the guest session they are made for.
+func (p *Plugin) guestSessionAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		channelID := mux.Vars(r)["channel_id"]
+		sessionID := mux.Vars(r)["session_id"]
+
+		secret := r.Header.Get(guestTokenHeader)
+		if secret == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var gs guestSession
+		if ok, err := p.kvGetJSON(guestSessionKeyPrefix+sessionID, &gs); err != nil {
+			p.LogError("failed to get guest session", "sessionID", sessionID, "err", err.Error())
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		} else if !ok || gs.ChannelID != channelID || !checkGuestSecret(secret, gs.SecretHash) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func getGuestInviteErrCode(err error) int {
+	switch {
+	case errors.Is(err, errGuestInviteNotFound), errors.Is(err, errGuestInviteExpired):
+		return http.StatusNotFound
+	case errors.Is(err, errGuestInviteInUse):
+		return http.StatusConflict
+	case errors.Is(err, ErrNoPermissions):
+		return http.StatusForbidden
+	case errors.Is(err, errGuestNameInvalid):
+		return http.StatusBadRequest
+	default:
+		return getJoinExternalSessionErrCode(err)
+	}
+}
+
+func (p *Plugin) handleCreateGuestInvite(w http.ResponseWriter, r *http.Request) {
+	var res httpResponse
+	defer p.httpAudit("handleCreateGuestInvite", &res, w, r)
+
+	userID := r.Header.Get("Mattermost-User-Id")
+	channelID := mux.Vars(r)["call_id"]
+
+	cfg := p.getConfiguration()
+	if !cfg.guestInvitesEnabled() {
+		res.Err = "Forbidden"
+		res.Code = http.StatusForbidden
+		return
+	}
+
+	var data struct {
+		Name             string `json:"name"`
+		ExpiresInMinutes int    `json:"expires_in_minutes"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, requestBodyMaxSizeBytes)).Decode(&data); err != nil {
+		res.Err = "failed to decode request body: " + err.Error()
+		res.Code = http.StatusBadRequest
+		return
+	}
+
+	if data.ExpiresInMinutes == 0 {
+		data.ExpiresInMinutes = *cfg.GuestInvitesMaxExpiryMinutes
+	}
+	if data.ExpiresInMinutes < 0 || data.ExpiresInMinutes > *cfg.GuestInvitesMaxExpiryMinutes {
+		res.Err = fmt.Sprintf("expires_in_minutes is not valid: range should be [1, %d]", *cfg.GuestInvitesMaxExpiryMinutes)
+		res.Code = http.StatusBadRequest
+		return
+	}
+
+	if data.Name != "" {
+		name, err := sanitizeGuestName(data.Name)
+		if err != nil {
+			res.Err = err.Error()
+			res.Code = http.StatusBadRequest
+			return
+		}
+		data.Name = name
+	}
+
+	invite, token, err := p.createGuestInvite(userID, channelID, data.Name, time.Duration(data.ExpiresInMinutes)*time.Minute)
+	if err != nil {
+		res.Err = err.Error()
+		res.Code = getGuestInviteErrCode(err)
+		return
+	}
+
+	invite.TokenHash = ""
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"invite": invite,
+		"token":  token,
+	}); err != nil {
+		p.LogError(err.Error())
+	}
+}
+
+func (p *Plugin) handleGetGuestInvites(w http.ResponseWriter, r *http.Request) {
+	var res httpResponse
+	defer p.httpAudit("handleGetGuestInvites", &res, w, r)
+
+	userID := r.Header.Get("Mattermost-User-Id")
+	channelID := mux.Vars(r)["call_id"]
+
+	state, err := p.getCallState(channelID, false)
+	if err != nil {
+		res.Err = err.Error()
+		res.Code = http.StatusInternalServerError
+		return
+	}
+
+	if err := p.checkGuestInvitesPermissions(userID, state); err != nil {
+		res.Err = err.Error()
+		res.Code = getGuestInviteErrCode(err)
+		return
+	}
+
+	invites, err := p.getGuestInvites(state.Call.ID)
+	if err != nil {
+		res.Err = err.Error()
+		res.Code = http.StatusInternalServerError
+		return
+	}
+
+	for _, invite := range invites {
+		invite.TokenHash = ""
+		if _, ok := state.sessions[invite.SessionID]; !ok {
+			invite.SessionID = ""
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(invites); err != nil {
+		p.LogError(err.Error())
+	}
+}
+
+func (p *Plugin) handleRevokeGuestInvite(w http.ResponseWriter, r *http.Request) {
+	var res httpResponse
+	defer p.httpAudit("handleRevokeGuestInvite", &res, w, r)
+
+	userID := r.Header.Get("Mattermost-User-Id")
+	channelID := mux.Vars(r)["call_id"]
+	inviteID := mux.Vars(r)["invite_id"]
+
+	if err := p.revokeGuestInvite(userID, channelID, inviteID); err != nil {
+		res.Err = err.Error()
+		res.Code = getGuestInviteErrCode(err)
+		return
+	}
+
+	res.Code = http.StatusOK
+	res.Msg = "success"
+}
+
+func (p *Plugin) handleGuestJoin(w http.ResponseWriter, r *http.Request) {
+	var res httpResponse
+	defer p.httpAudit("handleGuestJoin", &res, w, r)
+
+	// This endpoint isn't tied to a user session so we rate limit by address
+	// to slow down any attempt at guessing tokens.
+	if err := p.checkGuestRateLimits(r); err != nil {
+		res.Err = "too many requests"
+		res.Code = http.StatusTooManyRequests
+		return
+	}
+
+	var data struct {
+		Token string `json:"token"`
+		Name  string `json:"name"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, externalRequestBodyMaxSize)).Decode(&data); err != nil {
+		res.Err = "failed to decode request body: " + err.Error()
+		res.Code = http.StatusBadRequest
+		return
+	}
+
+	us, secret, err := p.joinGuestSession(data.Token, data.Name)
+	if err != nil {
+		res.Err = err.Error()
+		res.Code = getGuestInviteErrCode(err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"session_id": us.connID,
+		"call_id":    us.callID,
+		"channel_id": us.channelID,
+		"secret":     secret,
+	}); err != nil {
+		p.LogError(err.Error())
+	}
+}
+
+func (p *Plugin) handleGuestLeave(w http.ResponseWriter, r *http.Request) {
+	p.handleExternalSessionLeave(w, r, public.CallSessionTypeGuest)
+}
+
+func (p *Plugin) handleGuestMessage(w http.ResponseWriter, r *http.Request) {
+	p.handleExternalSessionMessage(w, r, public.CallSessionTypeGuest)
+}
+
+// handleGuestEvents long-polls for the events queued for a guest session.
+func (p *Plugin) handleGuestEvents(w http.ResponseWriter, r *http.Request) {
+	var res httpResponse
+	defer p.httpAudit("handleGuestEvents", &res, w, r)
+
+	channelID := mux.Vars(r)["channel_id"]
+	sessionID := mux.Vars(r)["session_id"]
+
+	p.mut.RLock()
+	us := p.sessions[sessionID]
+	p.mut.RUnlock()
+
+	if us == nil || us.guestEventCh == nil {
+		_, _, err := p.getExternalSession(channelID, sessionID, public.CallSessionTypeGuest)
+		if errors.Is(err, ErrNoCallOngoing) || errors.Is(err, ErrNotInCall) {
+			// The session is gone, letting the client know it should hang up.
+			p.writeGuestEvents(w, []externalEvent{{Type: externalEventHangup, ChannelID: channelID, SessionID: sessionID}})
+			return
+		} else if err != nil {
+			res.Err = err.Error()
+			res.Code = http.StatusInternalServerError
+			return
+		}
+
+		// The queue lives on a different node.
+		events, err := p.pollRemoteGuestEvents(r.Context(), channelID, sessionID)
+		if r.Context().Err() != nil {
+			return
+		} else if err != nil {
+			res.Err = err.Error()
+			res.Code = http.StatusInternalServerError
+			return
+		}
+		p.writeGuestEvents(w, events)
+		return
+	}
+
+	events, ok := p.waitGuestEvents(us, r.Context().Done())
+	if !ok {
+		return
+	}
+
+	p.writeGuestEvents(w, events)
+}
+
+func (p *Plugin) writeGuestEvents(w http.ResponseWriter, events []externalEvent) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		p.LogError(err.Error())
+	}
+}
@@ -0,0 +1,387 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	serverMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost-plugin-calls/server/interfaces"
+	pluginMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"golang.org/x/time/rate"
+)
+
+func TestGuestInviteToken(t *testing.T) {
+	inviteID := model.NewId()
+	token, hash := newGuestInviteToken(inviteID)
+
+	id, secret, ok := parseGuestInviteToken(token)
+	require.True(t, ok)
+	require.Equal(t, inviteID, id)
+	require.True(t, checkGuestSecret(secret, hash))
+	require.NotContains(t, hash, secret)
+
+	t.Run("invalid secret", func(t *testing.T) {
+		require.False(t, checkGuestSecret(strings.Repeat("a", guestSecretLen), hash))
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		for _, token := range []string{
+			"",
+			inviteID,
+			token + "a",
+			strings.Repeat("-", len(token)),
+		} {
+			_, _, ok := parseGuestInviteToken(token)
+			require.False(t, ok, token)
+		}
+	})
+}
+
+func TestSanitizeGuestName(t *testing.T) {
+	name, err := sanitizeGuestName("  Jane Doe ")
+	require.NoError(t, err)
+	require.Equal(t, "Jane Doe", name)
+
+	_, err = sanitizeGuestName("   ")
+	require.ErrorIs(t, err, errGuestNameInvalid)
+
+	_, err = sanitizeGuestName(strings.Repeat("ü", guestNameMaxLen))
+	require.NoError(t, err)
+
+	_, err = sanitizeGuestName(strings.Repeat("a", guestNameMaxLen+1))
+	require.ErrorIs(t, err, errGuestNameInvalid)
+}
+
+func TestGuestInviteValidate(t *testing.T) {
+	now := time.Now()
+	state := &callState{
+		Call: public.Call{
+			ID: model.NewId(),
+		},
+		sessions: map[string]*public.CallSession{},
+	}
+
+	invite := &guestInvite{
+		CallID:   state.Call.ID,
+		ExpireAt: now.Add(time.Minute).UnixMilli(),
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		require.NoError(t, invite.validate(state, now))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		require.ErrorIs(t, invite.validate(state, now.Add(time.Minute)), errGuestInviteExpired)
+	})
+
+	t.Run("no call", func(t *testing.T) {
+		require.ErrorIs(t, invite.validate(nil, now), ErrNoCallOngoing)
+	})
+
+	t.Run("different call", func(t *testing.T) {
+		invite := *invite
+		invite.CallID = model.NewId()
+		require.ErrorIs(t, invite.validate(state, now), ErrNoCallOngoing)
+	})
+
+	t.Run("in use", func(t *testing.T) {
+		invite := *invite
+		invite.SessionID = model.NewId()
+		require.NoError(t, invite.validate(state, now))
+
+		state.sessions[invite.SessionID] = &public.CallSession{ID: invite.SessionID, Type: public.CallSessionTypeGuest}
+		defer delete(state.sessions, invite.SessionID)
+		require.ErrorIs(t, invite.validate(state, now), errGuestInviteInUse)
+	})
+}
+
+func TestGuestClientAddr(t *testing.T) {
+	trustedProxies := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/24"),
+		netip.MustParsePrefix("192.168.1.1/32"),
+	}
+
+	t.Run("no proxy", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/guest/join", nil)
+		r.RemoteAddr = "10.0.0.1:4567"
+		require.Equal(t, "10.0.0.1", guestClientAddr(r, nil))
+	})
+
+	t.Run("untrusted forwarding headers", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/guest/join", nil)
+		r.RemoteAddr = "172.16.0.1:4567"
+		r.Header.Set("X-Real-IP", "172.16.0.2")
+		r.Header.Set("X-Forwarded-For", "172.16.0.3")
+		require.Equal(t, "172.16.0.1", guestClientAddr(r, trustedProxies))
+
+		r.RemoteAddr = "10.0.0.1:4567"
+		require.Equal(t, "10.0.0.1", guestClientAddr(r, nil))
+	})
+
+	t.Run("trusted proxy", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/guest/join", nil)
+		r.RemoteAddr = "10.0.0.1:4567"
+		require.Equal(t, "10.0.0.1", guestClientAddr(r, trustedProxies))
+
+		r.Header.Set("X-Real-IP", "172.16.0.2")
+		require.Equal(t, "172.16.0.2", guestClientAddr(r, trustedProxies))
+
+		r.Header.Set("X-Forwarded-For", "172.16.0.3")
+		require.Equal(t, "172.16.0.3", guestClientAddr(r, trustedProxies))
+	})
+
+	t.Run("spoofed forwarding hops", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/guest/join", nil)
+		r.RemoteAddr = "10.0.0.1:4567"
+		r.Header.Set("X-Forwarded-For", "1.2.3.4, 172.16.0.3, 192.168.1.1")
+		require.Equal(t, "172.16.0.3", guestClientAddr(r, trustedProxies))
+	})
+}
+
+func TestCheckGuestRateLimits(t *testing.T) {
+	p := &Plugin{
+		configuration: &configuration{},
+	}
+
+	newRequest := func(addr string) *http.Request {
+		r := httptest.NewRequest("POST", "/guest/join", nil)
+		r.RemoteAddr = addr + ":4567"
+		return r
+	}
+
+	t.Run("burst", func(t *testing.T) {
+		for range 10 {
+			require.NoError(t, p.checkGuestRateLimits(newRequest("10.0.0.1")))
+		}
+		require.ErrorIs(t, p.checkGuestRateLimits(newRequest("10.0.0.1")), errGuestRateLimited)
+		require.NoError(t, p.checkGuestRateLimits(newRequest("10.0.0.2")))
+
+		// User based limiters are left untouched.
+		require.Empty(t, p.apiLimiters)
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		p.guestLimiters = map[string]*guestLimiter{}
+		for i := range guestLimitersMaxSize {
+			p.guestLimiters[fmt.Sprintf("addr%d", i)] = &guestLimiter{
+				limiter:  rate.NewLimiter(1, 10),
+				lastSeen: time.Now(),
+			}
+		}
+
+		require.ErrorIs(t, p.checkGuestRateLimits(newRequest("10.0.0.3")), errGuestRateLimited)
+		require.Len(t, p.guestLimiters, guestLimitersMaxSize)
+
+		// Idle limiters are dropped to make space.
+		p.guestLimiters["addr0"].lastSeen = time.Now().Add(-2 * guestLimiterIdleTimeout)
+		require.NoError(t, p.checkGuestRateLimits(newRequest("10.0.0.3")))
+		require.Len(t, p.guestLimiters, guestLimitersMaxSize)
+		require.NotContains(t, p.guestLimiters, "addr0")
+		require.Contains(t, p.guestLimiters, "10.0.0.3")
+	})
+}
+
+func TestGuestEventsRelay(t *testing.T) {
+	newNode := func(nodeID string) (*Plugin, *pluginMocks.MockAPI) {
+		mockAPI := &pluginMocks.MockAPI{}
+		mockMetrics := &serverMocks.MockMetrics{}
+		mockMetrics.On("IncClusterEvent", mock.AnythingOfType("string"))
+		mockAPI.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+		mockAPI.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+			mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+		return &Plugin{
+			MattermostPlugin: plugin.MattermostPlugin{
+				API: mockAPI,
+			},
+			metrics:  mockMetrics,
+			nodeID:   nodeID,
+			stopCh:   make(chan struct{}),
+			sessions: map[string]*session{},
+		}, mockAPI
+	}
+
+	// The guest joined through nodeA, which holds its queue, while the poll
+	// reaches nodeB.
+	nodeA, mockAPIA := newNode("nodeA")
+	nodeB, mockAPIB := newNode("nodeB")
+
+	connect := func(mockAPI *pluginMocks.MockAPI, to *Plugin) {
+		mockAPI.On("PublishPluginClusterEvent", mock.AnythingOfType("model.PluginClusterEvent"),
+			mock.AnythingOfType("model.PluginClusterEventSendOptions")).Run(func(args mock.Arguments) {
+			opts := args.Get(1).(model.PluginClusterEventSendOptions)
+			if opts.TargetId != "" && opts.TargetId != to.nodeID {
+				return
+			}
+			go func() {
+				assert.NoError(t, to.handleEvent(args.Get(0).(model.PluginClusterEvent)))
+			}()
+		}).Return(nil)
+	}
+	connect(mockAPIA, nodeB)
+	connect(mockAPIB, nodeA)
+
+	channelID := model.NewId()
+	sessionID := model.NewId()
+	us := newUserSession(sessionID, channelID, sessionID, model.NewId(), true)
+	us.guestEventCh = make(chan externalEvent, msgChSize)
+	nodeA.sessions[sessionID] = us
+	t.Cleanup(func() {
+		close(nodeA.stopCh)
+	})
+
+	ev := externalEvent{
+		Type:      externalEventMute,
+		ChannelID: channelID,
+		SessionID: sessionID,
+	}
+	nodeA.sendGuestEvent(ev)
+
+	events, err := nodeB.pollRemoteGuestEvents(context.Background(), channelID, sessionID)
+	require.NoError(t, err)
+	require.Equal(t, []externalEvent{ev}, events)
+	require.Empty(t, nodeB.guestEventsPolls)
+
+	t.Run("events sent from another node", func(t *testing.T) {
+		nodeB.sendGuestEvent(ev)
+
+		events, err := nodeB.pollRemoteGuestEvents(context.Background(), channelID, sessionID)
+		require.NoError(t, err)
+		require.Equal(t, []externalEvent{ev}, events)
+	})
+
+	t.Run("cancelled poll", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := nodeB.pollRemoteGuestEvents(ctx, channelID, sessionID)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Empty(t, nodeB.guestEventsPolls)
+	})
+}
+
+func TestGuestAPIAuth(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	mockMetrics := &serverMocks.MockMetrics{}
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		metrics:     mockMetrics,
+		apiLimiters: map[string]*rate.Limiter{},
+	}
+
+	mockMetrics.On("Handler").Return(nil).Once()
+
+	// Audit log
+	auditArgs := []any{"handleGuestJoin", "origin", mock.AnythingOfType("string")}
+	for range 16 {
+		auditArgs = append(auditArgs, mock.Anything)
+	}
+	mockAPI.On("LogDebug", auditArgs...)
+
+	apiRouter := p.newAPIRouter()
+
+	do := func(t *testing.T, method, path, body, secret string) *http.Response {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		r.RemoteAddr = "10.0.0.1:4567"
+		if secret != "" {
+			r.Header.Set(guestTokenHeader, secret)
+		}
+		apiRouter.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	channelID := model.NewId()
+	sessionID := model.NewId()
+	sessionPath := "/guest/calls/" + channelID + "/sessions/" + sessionID
+
+	t.Run("disabled", func(t *testing.T) {
+		p.configuration = &configuration{}
+		p.configuration.SetDefaults()
+
+		resp := do(t, "POST", "/guest/join", "{", "")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	p.configuration.EnableGuestInvites = model.NewPointer(true)
+
+	t.Run("join", func(t *testing.T) {
+		resp := do(t, "POST", "/guest/join", "{", "")
+		defer resp.Body.Close()
+		// The request reaches the handler, failing on the malformed body.
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("missing secret", func(t *testing.T) {
+		resp := do(t, "DELETE", sessionPath, "", "")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	secret := model.NewRandomString(guestSecretLen)
+	gsData, err := json.Marshal(guestSession{
+		ID:         sessionID,
+		ChannelID:  channelID,
+		SecretHash: hashGuestSecret(secret),
+	})
+	require.NoError(t, err)
+
+	t.Run("unknown session", func(t *testing.T) {
+		otherSessionID := model.NewId()
+		mockAPI.On("KVGet", guestSessionKeyPrefix+otherSessionID).Return(nil, nil).Once()
+		resp := do(t, "DELETE", "/guest/calls/"+channelID+"/sessions/"+otherSessionID, "", secret)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("invalid secret", func(t *testing.T) {
+		mockAPI.On("KVGet", guestSessionKeyPrefix+sessionID).Return(gsData, nil).Once()
+		resp := do(t, "DELETE", sessionPath, "", "invalid")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("different channel", func(t *testing.T) {
+		mockAPI.On("KVGet", guestSessionKeyPrefix+sessionID).Return(gsData, nil).Once()
+		resp := do(t, "DELETE", "/guest/calls/"+model.NewId()+"/sessions/"+sessionID, "", secret)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		var code int
+		for i := 0; i < 20; i++ {
+			resp := do(t, "POST", "/guest/join", "{", "")
+			resp.Body.Close()
+			code = resp.StatusCode
+			if code == http.StatusTooManyRequests {
+				break
+			}
+		}
+		require.Equal(t, http.StatusTooManyRequests, code)
+	})
+
+	mockAPI.AssertExpectations(t)
+}
@@ -5,6 +5,7 @@ package main
 
 import (
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/mattermost/mattermost-plugin-calls/server/db"
@@ -13,6 +14,8 @@ import (
 	"github.com/pkg/errors"
 )
 
+const maxSpotlightSessions = 4
+
 var (
 	ErrNoCallOngoing = errors.New("no call ongoing")
 	ErrNoPermissions = errors.New("no permissions")
@@ -100,6 +103,10 @@ func (p *Plugin) muteSession(requesterID, channelID, sessionID string) error {
 		return nil
 	}
 
+	if ust.IsExternal() {
+		return p.muteExternalSession(state, ust)
+	}
+
 	p.publishWebSocketEvent(wsEventHostMute, map[string]interface{}{
 		"channel_id": channelID,
 		"session_id": sessionID,
@@ -127,6 +134,12 @@ func (p *Plugin) muteOthers(requesterID, channelID string) error {
 	// Unmute anyone muted (who is not the host/requester).
 	// If there are no unmuted sessions, return without doing anything.
 	for id, s := range state.sessions {
+		if s.Unmuted && s.IsExternal() {
+			if err := p.muteExternalSession(state, s); err != nil {
+				p.LogError("failed to mute external session", "sessionID", id, "err", err.Error())
+			}
+			continue
+		}
 		if s.Unmuted && s.UserID != requesterID {
 			p.publishWebSocketEvent(wsEventHostMute, map[string]interface{}{
 				"channel_id": channelID,
@@ -206,6 +219,238 @@ func (p *Plugin) lowerHand(requesterID, channelID, sessionID string) error {
 	return nil
 }
 
+func (p *Plugin) spotlightSession(requesterID, channelID, sessionID string) error {
+	state, err := p.lockCallReturnState(channelID)
+	if err != nil {
+		return fmt.Errorf("failed to lock call: %w", err)
+	}
+	defer p.unlockCall(channelID)
+
+	if state == nil {
+		return ErrNoCallOngoing
+	}
+
+	if requesterID != state.Call.GetHostID() {
+		if isAdmin := p.API.HasPermissionTo(requesterID, model.PermissionManageSystem); !isAdmin {
+			return ErrNoPermissions
+		}
+	}
+
+	ust, ok := state.sessions[sessionID]
+	if !ok {
+		return ErrNotInCall
+	}
+
+	if ust.UserID == p.getBotID() {
+		return errors.Wrap(ErrNotAllowed, "cannot spotlight the bot")
+	}
+
+	if slices.Contains(state.Call.Props.SpotlightSessionIDs, sessionID) {
+		return nil
+	}
+
+	if len(state.Call.Props.SpotlightSessionIDs) >= maxSpotlightSessions {
+		return errors.Wrapf(ErrNotAllowed, "cannot spotlight more than %d sessions", maxSpotlightSessions)
+	}
+
+	p.updateSpotlight(state, append(slices.Clone(state.Call.Props.SpotlightSessionIDs), sessionID))
+
+	if err := p.store.UpdateCall(&state.Call); err != nil {
+		return fmt.Errorf("failed to update call: %w", err)
+	}
+
+	return nil
+}
+
+// spotlightOff removes the given session from the spotlight. If no session is
+// given, the spotlight is cleared.
+func (p *Plugin) spotlightOff(requesterID, channelID, sessionID string) error {
+	state, err := p.lockCallReturnState(channelID)
+	if err != nil {
+		return fmt.Errorf("failed to lock call: %w", err)
+	}
+	defer p.unlockCall(channelID)
+
+	if state == nil {
+		return ErrNoCallOngoing
+	}
+
+	if requesterID != state.Call.GetHostID() {
+		if isAdmin := p.API.HasPermissionTo(requesterID, model.PermissionManageSystem); !isAdmin {
+			return ErrNoPermissions
+		}
+	}
+
+	var sessionIDs []string
+	if sessionID != "" {
+		if !slices.Contains(state.Call.Props.SpotlightSessionIDs, sessionID) {
+			return nil
+		}
+		sessionIDs = slices.DeleteFunc(slices.Clone(state.Call.Props.SpotlightSessionIDs), func(id string) bool {
+			return id == sessionID
+		})
+	} else if len(state.Call.Props.SpotlightSessionIDs) == 0 {
+		return nil
+	}
+
+	p.updateSpotlight(state, sessionIDs)
+
+	if err := p.store.UpdateCall(&state.Call); err != nil {
+		return fmt.Errorf("failed to update call: %w", err)
+	}
+
+	return nil
+}
+
+// updateSpotlight sets the spotlighted sessions, propagating the change to the
+// ongoing recording, if any, and to the participants. It's up to the caller
+// to persist the call.
+func (p *Plugin) updateSpotlight(state *callState, sessionIDs []string) {
+	if len(sessionIDs) == 0 {
+		sessionIDs = nil
+	}
+	state.Call.Props.SpotlightSessionIDs = sessionIDs
+
+	if state.Recording != nil && state.Recording.EndAt == 0 {
+		state.Recording.Props.SpotlightSessionIDs = slices.Clone(sessionIDs)
+		if err := p.store.UpdateCallJob(state.Recording); err != nil {
+			p.LogError("failed to update call job", "err", err.Error(), "callID", state.Call.ID, "jobID", state.Recording.ID)
+		}
+	}
+
+	p.publishWebSocketEvent(wsEventCallSpotlightChanged, map[string]interface{}{
+		"call_id":     state.Call.ID,
+		"channel_id":  state.Call.ChannelID,
+		"session_ids": sessionIDs,
+	}, &WebSocketBroadcast{
+		ChannelID:           state.Call.ChannelID,
+		ReliableClusterSend: true,
+		UserIDs:             getUserIDsFromSessions(state.sessions),
+	})
+}
+
+// setWebinar turns webinar mode on or off. While on, only the host and the
+// presenters can unmute, share their screen or turn on video.
+func (p *Plugin) setWebinar(requesterID, channelID string, enabled bool) error {
+	state, err := p.lockCallReturnState(channelID)
+	if err != nil {
+		return fmt.Errorf("failed to lock call: %w", err)
+	}
+	defer p.unlockCall(channelID)
+
+	if state == nil {
+		return ErrNoCallOngoing
+	}
+
+	if requesterID != state.Call.GetHostID() {
+		if isAdmin := p.API.HasPermissionTo(requesterID, model.PermissionManageSystem); !isAdmin {
+			return ErrNoPermissions
+		}
+	}
+
+	if state.Call.Props.Webinar == enabled {
+		return nil
+	}
+
+	state.Call.Props.Webinar = enabled
+	// Presenters are only meant to last for the webinar.
+	state.Call.Props.PresenterIDs = nil
+
+	if err := p.store.UpdateCall(&state.Call); err != nil {
+		return fmt.Errorf("failed to update call: %w", err)
+	}
+
+	p.publishWebinarState(state)
+
+	if enabled {
+		p.restrictAttendees(state, "")
+	}
+
+	return nil
+}
+
+func (p *Plugin) addPresenter(requesterID, channelID, userID string) error {
+	state, err := p.lockCallReturnState(channelID)
+	if err != nil {
+		return fmt.Errorf("failed to lock call: %w", err)
+	}
+	defer p.unlockCall(channelID)
+
+	if state == nil {
+		return ErrNoCallOngoing
+	}
+
+	if requesterID != state.Call.GetHostID() {
+		if isAdmin := p.API.HasPermissionTo(requesterID, model.PermissionManageSystem); !isAdmin {
+			return ErrNoPermissions
+		}
+	}
+
+	if !state.Call.Props.Webinar {
+		return errors.Wrap(ErrNotAllowed, "call is not a webinar")
+	}
+
+	if userID == p.getBotID() {
+		return errors.Wrap(ErrNotAllowed, "cannot make the bot a presenter")
+	}
+
+	if !state.isUserIDInCall(userID) {
+		return ErrNotInCall
+	}
+
+	if state.canPresent(userID, p.getBotID()) {
+		return nil
+	}
+
+	state.Call.Props.PresenterIDs = append(state.Call.Props.PresenterIDs, userID)
+
+	if err := p.store.UpdateCall(&state.Call); err != nil {
+		return fmt.Errorf("failed to update call: %w", err)
+	}
+
+	p.publishWebinarState(state)
+
+	return nil
+}
+
+func (p *Plugin) removePresenter(requesterID, channelID, userID string) error {
+	state, err := p.lockCallReturnState(channelID)
+	if err != nil {
+		return fmt.Errorf("failed to lock call: %w", err)
+	}
+	defer p.unlockCall(channelID)
+
+	if state == nil {
+		return ErrNoCallOngoing
+	}
+
+	if requesterID != state.Call.GetHostID() {
+		if isAdmin := p.API.HasPermissionTo(requesterID, model.PermissionManageSystem); !isAdmin {
+			return ErrNoPermissions
+		}
+	}
+
+	if !slices.Contains(state.Call.Props.PresenterIDs, userID) {
+		return nil
+	}
+
+	state.Call.Props.PresenterIDs = slices.DeleteFunc(state.Call.Props.PresenterIDs, func(id string) bool {
+		return id == userID
+	})
+	if len(state.Call.Props.PresenterIDs) == 0 {
+		state.Call.Props.PresenterIDs = nil
+	}
+
+	if err := p.store.UpdateCall(&state.Call); err != nil {
+		return fmt.Errorf("failed to update call: %w", err)
+	}
+
+	p.publishWebinarState(state)
+	p.restrictAttendees(state, userID)
+
+	return nil
+}
+
 func (p *Plugin) hostRemoveSession(requesterID, channelID, sessionID string) error {
 	state, err := p.getCallState(channelID, false)
 	if err != nil {
@@ -240,6 +485,17 @@ func (p *Plugin) hostRemoveSession(requesterID, channelID, sessionID string) err
 		UserIDs:             getUserIDsFromSessions(state.sessions),
 	})
 
+	if ust.IsGuest() {
+		// Kicked guests shouldn't be able to join back using the same invite.
+		if err := p.revokeGuestInviteForSession(channelID, sessionID); err != nil {
+			p.LogError("failed to revoke guest invite", "sessionID", sessionID, "err", err.Error())
+		}
+	}
+
+	if ust.IsExternal() {
+		return p.removeExternalSession(state, ust)
+	}
+
 	go func() {
 		// Wait a few seconds for the client to end their session cleanly. If they don't (like for an
 		// older mobile client) then forcibly end it.
@@ -0,0 +1,514 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/cluster"
+	"github.com/mattermost/mattermost-plugin-calls/server/db"
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	serverMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost-plugin-calls/server/interfaces"
+	pluginMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/gorilla/mux"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// createHostControlsTestCall persists an ongoing call hosted by hostID with a
+// session for each of the given users. Session IDs are prefixed with "conn".
+func createHostControlsTestCall(t *testing.T, p *Plugin, channelID string, userIDs ...string) *public.Call {
+	t.Helper()
+
+	call := &public.Call{
+		ID:        model.NewId(),
+		ChannelID: channelID,
+		StartAt:   time.Now().UnixMilli(),
+		CreateAt:  time.Now().UnixMilli(),
+		OwnerID:   "hostID",
+		Props: public.CallProps{
+			Hosts: []string{"hostID"},
+		},
+	}
+	require.NoError(t, p.store.CreateCall(call))
+
+	for _, userID := range userIDs {
+		require.NoError(t, p.store.CreateCallSession(&public.CallSession{
+			ID:     "conn" + userID,
+			CallID: call.ID,
+			UserID: userID,
+			JoinAt: time.Now().UnixMilli(),
+		}))
+	}
+
+	return call
+}
+
+// mockHostControls mocks the calls locking and the websocket events
+// triggered by host controls.
+func mockHostControls(mockAPI *pluginMocks.MockAPI, mockMetrics *serverMocks.MockMetrics) {
+	mockAPI.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	mockAPI.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	mockAPI.On("KVSetWithOptions", mock.Anything, mock.Anything, mock.Anything).Return(true, nil).Maybe()
+	mockAPI.On("KVDelete", mock.Anything).Return(nil).Maybe()
+	mockAPI.On("PublishWebSocketEvent", mock.Anything, mock.Anything, mock.Anything).Maybe()
+	mockAPI.On("PublishPluginClusterEvent", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+	mockMetrics.On("ObserveClusterMutexGrabTime", "mutex_call", mock.AnythingOfType("float64")).Maybe()
+	mockMetrics.On("ObserveClusterMutexLockedTime", "mutex_call", mock.AnythingOfType("float64")).Maybe()
+	mockMetrics.On("ObserveAppHandlersTime", mock.AnythingOfType("string"), mock.AnythingOfType("float64")).Maybe()
+	mockMetrics.On("IncWebSocketEvent", "out", mock.AnythingOfType("string")).Maybe()
+	mockMetrics.On("IncClusterEvent", mock.AnythingOfType("string")).Maybe()
+}
+
+func TestSpotlight(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	mockMetrics := &serverMocks.MockMetrics{}
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		metrics:           mockMetrics,
+		callsClusterLocks: map[string]*cluster.Mutex{},
+		botSession:        &model.Session{UserId: "botID"},
+		inCallStatusCh:    make(chan inCallStatusUpdate, inCallStatusQueueSize),
+	}
+
+	store, tearDown := NewTestStore(t)
+	t.Cleanup(tearDown)
+	p.store = store
+
+	mockHostControls(mockAPI, mockMetrics)
+
+	getSpotlight := func(t *testing.T, callID string) []string {
+		t.Helper()
+		call, err := p.store.GetCall(callID, db.GetCallOpts{FromWriter: true})
+		require.NoError(t, err)
+		return call.Props.SpotlightSessionIDs
+	}
+
+	t.Run("no call", func(t *testing.T) {
+		require.ErrorIs(t, p.spotlightSession("hostID", model.NewId(), "connhostID"), ErrNoCallOngoing)
+		require.ErrorIs(t, p.spotlightOff("hostID", model.NewId(), ""), ErrNoCallOngoing)
+	})
+
+	t.Run("no permissions", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+		defer mockMetrics.AssertExpectations(t)
+		defer ResetTestStore(t, p.store)
+
+		channelID := model.NewId()
+		createHostControlsTestCall(t, &p, channelID, "hostID", "userA")
+
+		mockAPI.On("HasPermissionTo", "userA", model.PermissionManageSystem).Return(false).Twice()
+
+		require.ErrorIs(t, p.spotlightSession("userA", channelID, "connuserA"), ErrNoPermissions)
+		require.ErrorIs(t, p.spotlightOff("userA", channelID, ""), ErrNoPermissions)
+	})
+
+	t.Run("admin", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+		defer mockMetrics.AssertExpectations(t)
+		defer ResetTestStore(t, p.store)
+
+		channelID := model.NewId()
+		call := createHostControlsTestCall(t, &p, channelID, "hostID", "adminID")
+
+		mockAPI.On("HasPermissionTo", "adminID", model.PermissionManageSystem).Return(true).Once()
+
+		require.NoError(t, p.spotlightSession("adminID", channelID, "connhostID"))
+		require.Equal(t, []string{"connhostID"}, getSpotlight(t, call.ID))
+	})
+
+	t.Run("not in call", func(t *testing.T) {
+		defer ResetTestStore(t, p.store)
+
+		channelID := model.NewId()
+		createHostControlsTestCall(t, &p, channelID, "hostID")
+
+		require.ErrorIs(t, p.spotlightSession("hostID", channelID, "connuserA"), ErrNotInCall)
+	})
+
+	t.Run("bot", func(t *testing.T) {
+		defer ResetTestStore(t, p.store)
+
+		channelID := model.NewId()
+		call := createHostControlsTestCall(t, &p, channelID, "hostID", "botID")
+
+		require.ErrorIs(t, p.spotlightSession("hostID", channelID, "connbotID"), ErrNotAllowed)
+		require.Empty(t, getSpotlight(t, call.ID))
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		defer ResetTestStore(t, p.store)
+
+		userIDs := []string{"hostID", "userA", "userB", "userC", "userD"}
+		channelID := model.NewId()
+		call := createHostControlsTestCall(t, &p, channelID, userIDs...)
+
+		var sessionIDs []string
+		for _, userID := range userIDs[:maxSpotlightSessions] {
+			require.NoError(t, p.spotlightSession("hostID", channelID, "conn"+userID))
+			sessionIDs = append(sessionIDs, "conn"+userID)
+		}
+		require.Equal(t, sessionIDs, getSpotlight(t, call.ID))
+
+		// Spotlighting twice is a no-op.
+		require.NoError(t, p.spotlightSession("hostID", channelID, "connhostID"))
+
+		require.ErrorIs(t, p.spotlightSession("hostID", channelID, "connuserD"), ErrNotAllowed)
+		require.Equal(t, sessionIDs, getSpotlight(t, call.ID))
+
+		require.NoError(t, p.spotlightOff("hostID", channelID, "connuserA"))
+		require.Equal(t, []string{"connhostID", "connuserB", "connuserC"}, getSpotlight(t, call.ID))
+
+		require.NoError(t, p.spotlightSession("hostID", channelID, "connuserD"))
+		require.Equal(t, []string{"connhostID", "connuserB", "connuserC", "connuserD"}, getSpotlight(t, call.ID))
+
+		require.NoError(t, p.spotlightOff("hostID", channelID, ""))
+		require.Empty(t, getSpotlight(t, call.ID))
+	})
+
+	t.Run("recording", func(t *testing.T) {
+		defer ResetTestStore(t, p.store)
+
+		channelID := model.NewId()
+		call := createHostControlsTestCall(t, &p, channelID, "hostID", "userA")
+
+		recJob := &public.CallJob{
+			ID:        model.NewId(),
+			CallID:    call.ID,
+			Type:      public.JobTypeRecording,
+			CreatorID: "hostID",
+			InitAt:    time.Now().UnixMilli(),
+			StartAt:   time.Now().UnixMilli(),
+		}
+		require.NoError(t, p.store.CreateCallJob(recJob))
+
+		getRecordingSpotlight := func(t *testing.T) []string {
+			t.Helper()
+			job, err := p.store.GetCallJob(recJob.ID, db.GetCallJobOpts{FromWriter: true, IncludeEnded: true})
+			require.NoError(t, err)
+			return job.Props.SpotlightSessionIDs
+		}
+
+		require.NoError(t, p.spotlightSession("hostID", channelID, "connuserA"))
+		require.Equal(t, []string{"connuserA"}, getRecordingSpotlight(t))
+
+		require.NoError(t, p.spotlightOff("hostID", channelID, ""))
+		require.Empty(t, getRecordingSpotlight(t))
+
+		// Ended recordings are left as they are.
+		recJob.EndAt = time.Now().UnixMilli()
+		require.NoError(t, p.store.UpdateCallJob(recJob))
+		require.NoError(t, p.spotlightSession("hostID", channelID, "connuserA"))
+		require.Empty(t, getRecordingSpotlight(t))
+	})
+
+	t.Run("removed on leave", func(t *testing.T) {
+		defer ResetTestStore(t, p.store)
+
+		channelID := model.NewId()
+		createHostControlsTestCall(t, &p, channelID, "hostID", "userA", "userB")
+
+		require.NoError(t, p.spotlightSession("hostID", channelID, "connuserA"))
+		require.NoError(t, p.spotlightSession("hostID", channelID, "connuserB"))
+
+		state, err := p.lockCallReturnState(channelID)
+		require.NoError(t, err)
+		err = p.removeUserSession(state, "userA", "connuserA", "connuserA", channelID)
+		p.unlockCall(channelID)
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"connuserB"}, state.Call.Props.SpotlightSessionIDs)
+	})
+}
+
+func TestWebinar(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	mockMetrics := &serverMocks.MockMetrics{}
+
+	// The calls are handled by a different node so that RTC messages are
+	// relayed through the cluster.
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		metrics:           mockMetrics,
+		callsClusterLocks: map[string]*cluster.Mutex{},
+		botSession:        &model.Session{UserId: "botID"},
+		nodeID:            "nodeA",
+	}
+
+	store, tearDown := NewTestStore(t)
+	t.Cleanup(tearDown)
+	p.store = store
+
+	mockHostControls(mockAPI, mockMetrics)
+
+	getCall := func(t *testing.T, callID string) *public.Call {
+		t.Helper()
+		call, err := p.store.GetCall(callID, db.GetCallOpts{FromWriter: true})
+		require.NoError(t, err)
+		return call
+	}
+
+	unmute := func(t *testing.T, call *public.Call, userID string) {
+		t.Helper()
+		require.NoError(t, p.store.UpdateCallSession(&public.CallSession{
+			ID:      "conn" + userID,
+			CallID:  call.ID,
+			UserID:  userID,
+			JoinAt:  call.StartAt,
+			Unmuted: true,
+		}))
+	}
+
+	getSession := func(t *testing.T, userID string) *public.CallSession {
+		t.Helper()
+		session, err := p.store.GetCallSession("conn"+userID, db.GetCallSessionOpts{FromWriter: true})
+		require.NoError(t, err)
+		return session
+	}
+
+	hostMuteData := func(channelID, userID string) []any {
+		return []any{wsEventHostMute, map[string]any{
+			"channel_id": channelID,
+			"session_id": "conn" + userID,
+		}, &model.WebsocketBroadcast{UserId: userID, ReliableClusterSend: true}}
+	}
+
+	rtcData := func(call *public.Call, userID, msgType string) []any {
+		t.Helper()
+		data, err := (&clusterMessage{
+			ConnID:        "conn" + userID,
+			UserID:        userID,
+			ChannelID:     call.ChannelID,
+			CallID:        call.ID,
+			SenderID:      "nodeA",
+			ClientMessage: clientMessage{Type: msgType},
+		}).ToJSON()
+		require.NoError(t, err)
+		return []any{model.PluginClusterEvent{
+			Id:   string(clusterMessageTypeUserState),
+			Data: data,
+		}, model.PluginClusterEventSendOptions{
+			SendType: model.PluginClusterEventSendTypeReliable,
+		}}
+	}
+
+	t.Run("no permissions", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+		defer ResetTestStore(t, p.store)
+
+		channelID := model.NewId()
+		createHostControlsTestCall(t, &p, channelID, "hostID", "userA")
+
+		mockAPI.On("HasPermissionTo", "userA", model.PermissionManageSystem).Return(false).Times(3)
+
+		require.ErrorIs(t, p.setWebinar("userA", channelID, true), ErrNoPermissions)
+		require.ErrorIs(t, p.addPresenter("userA", channelID, "userA"), ErrNoPermissions)
+		require.ErrorIs(t, p.removePresenter("userA", channelID, "userA"), ErrNoPermissions)
+	})
+
+	t.Run("toggle", func(t *testing.T) {
+		defer ResetTestStore(t, p.store)
+
+		channelID := model.NewId()
+		call := createHostControlsTestCall(t, &p, channelID, "hostID", "userA")
+		unmute(t, call, "hostID")
+		unmute(t, call, "userA")
+
+		require.NoError(t, p.setWebinar("hostID", channelID, true))
+		require.True(t, getCall(t, call.ID).Props.Webinar)
+
+		// Attendees get muted on the server side, the host doesn't.
+		require.False(t, getSession(t, "userA").Unmuted)
+		require.True(t, getSession(t, "hostID").Unmuted)
+		mockAPI.AssertCalled(t, "PublishPluginClusterEvent", rtcData(call, "userA", clientMessageTypeMute)...)
+		mockAPI.AssertNotCalled(t, "PublishPluginClusterEvent", rtcData(call, "hostID", clientMessageTypeMute)...)
+		mockAPI.AssertCalled(t, "PublishWebSocketEvent", hostMuteData(channelID, "userA")...)
+		mockAPI.AssertNotCalled(t, "PublishWebSocketEvent", hostMuteData(channelID, "hostID")...)
+
+		require.NoError(t, p.addPresenter("hostID", channelID, "userA"))
+		require.Equal(t, []string{"userA"}, getCall(t, call.ID).Props.PresenterIDs)
+
+		// Presenters are reset along with the webinar.
+		require.NoError(t, p.setWebinar("hostID", channelID, false))
+		call = getCall(t, call.ID)
+		require.False(t, call.Props.Webinar)
+		require.Empty(t, call.Props.PresenterIDs)
+	})
+
+	t.Run("screen sharing and video", func(t *testing.T) {
+		defer ResetTestStore(t, p.store)
+
+		channelID := model.NewId()
+		call := createHostControlsTestCall(t, &p, channelID, "hostID", "userA")
+		call.Props.ScreenSharingSessionID = "connuserA"
+		call.Props.ScreenStartAt = time.Now().Unix()
+		call.Props.VideoStartAt = map[string]int64{"connuserA": time.Now().Unix()}
+		require.NoError(t, p.store.UpdateCall(call))
+		require.NoError(t, p.store.UpdateCallSession(&public.CallSession{
+			ID:     "connuserA",
+			CallID: call.ID,
+			UserID: "userA",
+			JoinAt: call.StartAt,
+			Video:  true,
+		}))
+
+		require.NoError(t, p.setWebinar("hostID", channelID, true))
+
+		updated := getCall(t, call.ID)
+		require.Empty(t, updated.Props.ScreenSharingSessionID)
+		require.Zero(t, updated.Props.ScreenStartAt)
+		require.NotContains(t, updated.Props.VideoStartAt, "connuserA")
+		require.False(t, getSession(t, "userA").Video)
+
+		mockAPI.AssertCalled(t, "PublishPluginClusterEvent", rtcData(call, "userA", clientMessageTypeScreenOff)...)
+		mockAPI.AssertCalled(t, "PublishPluginClusterEvent", rtcData(call, "userA", clientMessageTypeVideoOff)...)
+		mockAPI.AssertNotCalled(t, "PublishPluginClusterEvent", rtcData(call, "userA", clientMessageTypeMute)...)
+	})
+
+	t.Run("add presenter", func(t *testing.T) {
+		defer ResetTestStore(t, p.store)
+
+		channelID := model.NewId()
+		call := createHostControlsTestCall(t, &p, channelID, "hostID", "userA", "botID")
+
+		require.ErrorIs(t, p.addPresenter("hostID", channelID, "userA"), ErrNotAllowed)
+
+		require.NoError(t, p.setWebinar("hostID", channelID, true))
+
+		require.ErrorIs(t, p.addPresenter("hostID", channelID, "botID"), ErrNotAllowed)
+		require.ErrorIs(t, p.addPresenter("hostID", channelID, "userB"), ErrNotInCall)
+
+		require.NoError(t, p.addPresenter("hostID", channelID, "userA"))
+		require.Equal(t, []string{"userA"}, getCall(t, call.ID).Props.PresenterIDs)
+
+		// Adding twice, or adding the host, is a no-op.
+		require.NoError(t, p.addPresenter("hostID", channelID, "userA"))
+		require.NoError(t, p.addPresenter("hostID", channelID, "hostID"))
+		require.Equal(t, []string{"userA"}, getCall(t, call.ID).Props.PresenterIDs)
+	})
+
+	t.Run("remove presenter", func(t *testing.T) {
+		defer ResetTestStore(t, p.store)
+
+		channelID := model.NewId()
+		call := createHostControlsTestCall(t, &p, channelID, "hostID", "userA", "userB")
+
+		require.NoError(t, p.setWebinar("hostID", channelID, true))
+		require.NoError(t, p.addPresenter("hostID", channelID, "userA"))
+		require.NoError(t, p.addPresenter("hostID", channelID, "userB"))
+		unmute(t, call, "userA")
+		unmute(t, call, "userB")
+
+		require.NoError(t, p.removePresenter("hostID", channelID, "userA"))
+		require.Equal(t, []string{"userB"}, getCall(t, call.ID).Props.PresenterIDs)
+
+		// Only the demoted presenter gets muted.
+		require.False(t, getSession(t, "userA").Unmuted)
+		require.True(t, getSession(t, "userB").Unmuted)
+		mockAPI.AssertCalled(t, "PublishPluginClusterEvent", rtcData(call, "userA", clientMessageTypeMute)...)
+		mockAPI.AssertNotCalled(t, "PublishPluginClusterEvent", rtcData(call, "userB", clientMessageTypeMute)...)
+		mockAPI.AssertCalled(t, "PublishWebSocketEvent", hostMuteData(channelID, "userA")...)
+		mockAPI.AssertNotCalled(t, "PublishWebSocketEvent", hostMuteData(channelID, "userB")...)
+
+		// Removing a user that isn't a presenter is a no-op.
+		require.NoError(t, p.removePresenter("hostID", channelID, "userA"))
+
+		require.NoError(t, p.removePresenter("hostID", channelID, "userB"))
+		require.Empty(t, getCall(t, call.ID).Props.PresenterIDs)
+	})
+}
+
+func TestWebinarHandlers(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	mockMetrics := &serverMocks.MockMetrics{}
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		metrics:           mockMetrics,
+		callsClusterLocks: map[string]*cluster.Mutex{},
+		botSession:        &model.Session{UserId: "botID"},
+	}
+
+	store, tearDown := NewTestStore(t)
+	t.Cleanup(tearDown)
+	p.store = store
+
+	mockHostControls(mockAPI, mockMetrics)
+
+	// Audit logs.
+	mockAPI.On("LogDebug", mock.AnythingOfType("string"), mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	mockAPI.On("LogDebug", mock.AnythingOfType("string"), mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	mockAPI.On("LogError", mock.AnythingOfType("string"), "origin", mock.AnythingOfType("string"),
+		"err", mock.AnythingOfType("string")).Maybe()
+
+	serve := func(handler http.HandlerFunc, userID, callID, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/calls/"+callID+"/host", strings.NewReader(body))
+		r.Header.Set("Mattermost-User-Id", userID)
+		handler(w, mux.SetURLVars(r, map[string]string{"call_id": callID}))
+		return w
+	}
+
+	t.Run("bad request", func(t *testing.T) {
+		for _, handler := range []http.HandlerFunc{p.handleWebinar, p.handlePresenter, p.handlePresenterOff} {
+			w := serve(handler, "hostID", model.NewId(), "{")
+			require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		}
+	})
+
+	t.Run("no call", func(t *testing.T) {
+		for _, handler := range []http.HandlerFunc{p.handleWebinar, p.handlePresenter, p.handlePresenterOff} {
+			w := serve(handler, "hostID", model.NewId(), `{"enabled": true, "user_id": "userA"}`)
+			require.Equal(t, http.StatusBadRequest, w.Result().StatusCode)
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		defer ResetTestStore(t, p.store)
+
+		channelID := model.NewId()
+		call := createHostControlsTestCall(t, &p, channelID, "hostID", "userA")
+
+		w := serve(p.handleWebinar, "hostID", channelID, `{"enabled": true}`)
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+		w = serve(p.handlePresenter, "hostID", channelID, `{"user_id": "userA"}`)
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+		updated, err := p.store.GetCall(call.ID, db.GetCallOpts{FromWriter: true})
+		require.NoError(t, err)
+		require.True(t, updated.Props.Webinar)
+		require.Equal(t, []string{"userA"}, updated.Props.PresenterIDs)
+
+		w = serve(p.handlePresenterOff, "hostID", channelID, `{"user_id": "userA"}`)
+		require.Equal(t, http.StatusOK, w.Result().StatusCode)
+
+		updated, err = p.store.GetCall(call.ID, db.GetCallOpts{FromWriter: true})
+		require.NoError(t, err)
+		require.Empty(t, updated.Props.PresenterIDs)
+	})
+}
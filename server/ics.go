@@ -0,0 +1,322 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Minimal support for the iCalendar format (RFC 5545), limited to what's
+// needed to import invites and export scheduled calls as events.
+
+const (
+	icsDateTimeLayout    = "20060102T150405"
+	icsDateTimeUTCLayout = "20060102T150405Z"
+	icsDateLayout        = "20060102"
+	icsLineMaxOctets     = 75
+	icsDefaultDuration   = time.Hour
+)
+
+var errICSInvalid = errors.New("invalid ICS data")
+
+type icsEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	URL         string
+	Start       time.Time
+	End         time.Time
+}
+
+type icsProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// unfoldICSLines splits the data into content lines, joining lines that were
+// folded (continuation lines start with a space or tab).
+func unfoldICSLines(data []byte) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", errICSInvalid, err)
+	}
+	return lines, nil
+}
+
+func parseICSProperty(line string) (icsProperty, error) {
+	// Values can contain colons (e.g. URLs) so we split on the first one
+	// that's not part of a quoted parameter value.
+	var quoted bool
+	sep := -1
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ':' && !quoted {
+			sep = i
+			break
+		}
+	}
+	if sep <= 0 {
+		return icsProperty{}, fmt.Errorf("%w: malformed line %q", errICSInvalid, line)
+	}
+
+	parts := strings.Split(line[:sep], ";")
+	prop := icsProperty{
+		Name:   strings.ToUpper(parts[0]),
+		Params: make(map[string]string, len(parts)-1),
+		Value:  line[sep+1:],
+	}
+	for _, param := range parts[1:] {
+		k, v, _ := strings.Cut(param, "=")
+		prop.Params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+
+	return prop, nil
+}
+
+func parseICSTime(prop icsProperty) (time.Time, error) {
+	if prop.Params["VALUE"] == "DATE" || len(prop.Value) == len(icsDateLayout) {
+		return time.ParseInLocation(icsDateLayout, prop.Value, time.UTC)
+	}
+
+	if strings.HasSuffix(prop.Value, "Z") {
+		return time.Parse(icsDateTimeUTCLayout, prop.Value)
+	}
+
+	loc := time.UTC
+	if tzid := prop.Params["TZID"]; tzid != "" {
+		var err error
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return time.Time{}, fmt.Errorf("unknown timezone %q", tzid)
+		}
+	}
+
+	return time.ParseInLocation(icsDateTimeLayout, prop.Value, loc)
+}
+
+func unescapeICSText(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
+
+func escapeICSText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// parseICSEvent returns the first event found in the given calendar data.
+func parseICSEvent(data []byte) (*icsEvent, error) {
+	lines, err := unfoldICSLines(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lines) == 0 || !strings.EqualFold(lines[0], "BEGIN:VCALENDAR") {
+		return nil, fmt.Errorf("%w: missing calendar", errICSInvalid)
+	}
+
+	var ev *icsEvent
+	var duration time.Duration
+	var depth int
+	for _, line := range lines {
+		prop, err := parseICSProperty(line)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case prop.Name == "BEGIN" && strings.EqualFold(prop.Value, "VEVENT") && ev == nil:
+			ev = &icsEvent{}
+			continue
+		case prop.Name == "END" && strings.EqualFold(prop.Value, "VEVENT") && ev != nil && depth == 0:
+			if ev.Start.IsZero() {
+				return nil, fmt.Errorf("%w: event is missing start time", errICSInvalid)
+			}
+			if ev.End.IsZero() {
+				if duration <= 0 {
+					duration = icsDefaultDuration
+				}
+				ev.End = ev.Start.Add(duration)
+			}
+			if !ev.End.After(ev.Start) {
+				return nil, fmt.Errorf("%w: event ends before it starts", errICSInvalid)
+			}
+			return ev, nil
+		}
+
+		if ev == nil {
+			continue
+		}
+
+		// Skip properties of nested components (e.g. VALARM).
+		if prop.Name == "BEGIN" {
+			depth++
+			continue
+		} else if prop.Name == "END" {
+			depth--
+			continue
+		}
+		if depth > 0 {
+			continue
+		}
+
+		switch prop.Name {
+		case "UID":
+			ev.UID = prop.Value
+		case "SUMMARY":
+			ev.Summary = unescapeICSText(prop.Value)
+		case "DESCRIPTION":
+			ev.Description = unescapeICSText(prop.Value)
+		case "URL":
+			ev.URL = prop.Value
+		case "DTSTART":
+			if ev.Start, err = parseICSTime(prop); err != nil {
+				return nil, fmt.Errorf("%w: invalid start time: %w", errICSInvalid, err)
+			}
+		case "DTEND":
+			if ev.End, err = parseICSTime(prop); err != nil {
+				return nil, fmt.Errorf("%w: invalid end time: %w", errICSInvalid, err)
+			}
+		case "DURATION":
+			if duration, err = parseICSDuration(prop.Value); err != nil {
+				return nil, fmt.Errorf("%w: invalid duration: %w", errICSInvalid, err)
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("%w: no event found", errICSInvalid)
+}
+
+// parseICSDuration parses durations in the subset of the RFC 5545 format
+// generated by calendar apps (e.g. PT1H30M, P1D).
+func parseICSDuration(s string) (time.Duration, error) {
+	rest, ok := strings.CutPrefix(s, "P")
+	if !ok || rest == "" {
+		return 0, fmt.Errorf("malformed duration %q", s)
+	}
+
+	var d time.Duration
+	var inTime bool
+	var num int
+	var hasNum bool
+	for _, c := range rest {
+		switch {
+		case c >= '0' && c <= '9':
+			num = num*10 + int(c-'0')
+			hasNum = true
+			continue
+		case c == 'T':
+			inTime = true
+			continue
+		}
+
+		if !hasNum {
+			return 0, fmt.Errorf("malformed duration %q", s)
+		}
+
+		switch {
+		case c == 'W' && !inTime:
+			d += time.Duration(num) * 7 * 24 * time.Hour
+		case c == 'D' && !inTime:
+			d += time.Duration(num) * 24 * time.Hour
+		case c == 'H' && inTime:
+			d += time.Duration(num) * time.Hour
+		case c == 'M' && inTime:
+			d += time.Duration(num) * time.Minute
+		case c == 'S' && inTime:
+			d += time.Duration(num) * time.Second
+		default:
+			return 0, fmt.Errorf("malformed duration %q", s)
+		}
+		num = 0
+		hasNum = false
+	}
+
+	if hasNum {
+		return 0, fmt.Errorf("malformed duration %q", s)
+	}
+
+	return d, nil
+}
+
+// writeICSLine writes a content line, folding it so that no line is longer
+// than 75 octets, without splitting UTF-8 sequences.
+func writeICSLine(w io.Writer, line string) error {
+	var b strings.Builder
+	limit := icsLineMaxOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space which counts towards the limit.
+		limit = icsLineMaxOctets - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeICSCalendar writes the given events as a calendar.
+func writeICSCalendar(w io.Writer, name string, events []icsEvent, now time.Time) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Mattermost//Calls//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:" + escapeICSText(name),
+	}
+
+	for _, ev := range events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+ev.UID,
+			"DTSTAMP:"+now.UTC().Format(icsDateTimeUTCLayout),
+			"DTSTART:"+ev.Start.UTC().Format(icsDateTimeUTCLayout),
+			"DTEND:"+ev.End.UTC().Format(icsDateTimeUTCLayout),
+			"SUMMARY:"+escapeICSText(ev.Summary),
+		)
+		if ev.Description != "" {
+			lines = append(lines, "DESCRIPTION:"+escapeICSText(ev.Description))
+		}
+		if ev.URL != "" {
+			lines = append(lines, "URL:"+ev.URL, "LOCATION:"+escapeICSText(ev.URL))
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		if err := writeICSLine(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
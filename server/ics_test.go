@@ -0,0 +1,144 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readICSFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "ics", name))
+	require.NoError(t, err)
+	return data
+}
+
+func TestParseICSEvent(t *testing.T) {
+	t.Run("utc", func(t *testing.T) {
+		ev, err := parseICSEvent(readICSFixture(t, "basic_utc.ics"))
+		require.NoError(t, err)
+		require.Equal(t, &icsEvent{
+			UID:         "7kukuqrfedlm2f9t0vmv4r1pcq@google.com",
+			Summary:     "Team sync",
+			Description: "Weekly sync, agenda:\n- Roadmap\n- Hiring",
+			Start:       time.Date(2030, 1, 15, 15, 0, 0, 0, time.UTC),
+			End:         time.Date(2030, 1, 15, 15, 30, 0, 0, time.UTC),
+		}, ev)
+	})
+
+	t.Run("timezone, duration and alarm", func(t *testing.T) {
+		ev, err := parseICSEvent(readICSFixture(t, "tzid_duration_alarm.ics"))
+		require.NoError(t, err)
+
+		loc, err := time.LoadLocation("Europe/Berlin")
+		require.NoError(t, err)
+
+		require.Equal(t, "040000008200E00074C5B7101A82E0080000000010", ev.UID)
+		require.Equal(t, "Quarterly planning", ev.Summary)
+		// The alarm description should not leak into the event.
+		require.Empty(t, ev.Description)
+		require.Equal(t, "https://example.com/meetings/123", ev.URL)
+		require.True(t, time.Date(2030, 3, 1, 10, 0, 0, 0, loc).Equal(ev.Start))
+		require.Equal(t, 90*time.Minute, ev.End.Sub(ev.Start))
+	})
+
+	t.Run("all day", func(t *testing.T) {
+		ev, err := parseICSEvent(readICSFixture(t, "all_day.ics"))
+		require.NoError(t, err)
+		require.Equal(t, time.Date(2030, 4, 10, 0, 0, 0, 0, time.UTC), ev.Start)
+		require.Equal(t, icsDefaultDuration, ev.End.Sub(ev.Start))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, name := range []string{"no_start.ics", "no_event.ics"} {
+			_, err := parseICSEvent(readICSFixture(t, name))
+			require.ErrorIs(t, err, errICSInvalid, name)
+		}
+
+		for _, data := range []string{
+			"",
+			"not a calendar",
+			"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:2030\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:20300101T100000Z\r\nDTEND:20300101T090000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			"BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;TZID=Mars/Olympus:20300101T100000\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+		} {
+			_, err := parseICSEvent([]byte(data))
+			require.ErrorIs(t, err, errICSInvalid, data)
+		}
+	})
+}
+
+func TestParseICSDuration(t *testing.T) {
+	for input, expected := range map[string]time.Duration{
+		"PT15M":     15 * time.Minute,
+		"PT1H30M":   90 * time.Minute,
+		"P1D":       24 * time.Hour,
+		"P1W":       7 * 24 * time.Hour,
+		"P1DT2H":    26 * time.Hour,
+		"PT1H0M30S": time.Hour + 30*time.Second,
+	} {
+		d, err := parseICSDuration(input)
+		require.NoError(t, err, input)
+		require.Equal(t, expected, d, input)
+	}
+
+	for _, input := range []string{"", "P", "1H", "PT1", "PTH", "P1H", "PT1D"} {
+		_, err := parseICSDuration(input)
+		require.Error(t, err, input)
+	}
+}
+
+func TestWriteICSCalendar(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	link := "https://mm.example.com/team/channels/ch7ka5inf3nxfp4kheuy6bvbca?join_call=true"
+
+	var buf bytes.Buffer
+	err := writeICSCalendar(&buf, "Mattermost Calls", []icsEvent{
+		{
+			UID:         "9x1tdkw7ab8fjq1fsmhx3cn7yw",
+			Summary:     "Team sync; weekly, as usual",
+			Description: "Agenda:\n- Roadmap\n\n" + link,
+			URL:         link,
+			Start:       time.Date(2030, 1, 15, 15, 0, 0, 0, time.UTC),
+			End:         time.Date(2030, 1, 15, 15, 30, 0, 0, time.UTC),
+		},
+	}, now)
+	require.NoError(t, err)
+	require.Equal(t, string(readICSFixture(t, "feed.ics")), buf.String())
+
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		require.LessOrEqual(t, len(line), icsLineMaxOctets)
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		ev, err := parseICSEvent(buf.Bytes())
+		require.NoError(t, err)
+		require.Equal(t, "Team sync; weekly, as usual", ev.Summary)
+		require.Equal(t, "Agenda:\n- Roadmap\n\n"+link, ev.Description)
+		require.Equal(t, link, ev.URL)
+	})
+}
+
+func TestWriteICSLine(t *testing.T) {
+	var buf bytes.Buffer
+	line := "SUMMARY:" + strings.Repeat("ü", 60)
+	require.NoError(t, writeICSLine(&buf, line))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+	require.Len(t, lines, 2)
+	for _, l := range lines {
+		require.LessOrEqual(t, len(l), icsLineMaxOctets)
+	}
+
+	unfolded, err := unfoldICSLines(buf.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, []string{line}, unfolded)
+}
@@ -0,0 +1,256 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	inCallStatusKeyPrefix  = "in_call_status_"
+	inCallStatusEmoji      = "telephone_receiver"
+	inCallStatusMaxRetries = 5
+	inCallStatusQueueSize  = 1024
+	// The in-call status is refreshed on every update. It expires so that
+	// sessions that were never removed (e.g. a node crashing) aren't tracked
+	// forever.
+	inCallStatusExpiry = 24 * time.Hour
+)
+
+var errInCallStatusConflict = errors.New("too many concurrent updates")
+
+// inCallStatus tracks the custom status set for a user while in a call. It's
+// stored in the KV store so that sessions joining and leaving through
+// different nodes are accounted for.
+type inCallStatus struct {
+	// Sessions maps the IDs of the user's call sessions to their channel.
+	Sessions map[string]string `json:"sessions"`
+	// Status is the custom status that was set on join.
+	Status *model.CustomStatus `json:"status"`
+	// Previous is the custom status the user had before joining, if any.
+	Previous *model.CustomStatus `json:"previous,omitempty"`
+}
+
+// inCallStatusUpdate is a pending change to the in-call status of a user,
+// following one of their sessions joining or leaving a call.
+type inCallStatusUpdate struct {
+	userID    string
+	channelID string
+	sessionID string
+	join      bool
+}
+
+func inCallStatusKey(userID string) string {
+	return inCallStatusKeyPrefix + userID
+}
+
+// isInCallStatus returns whether the given custom status is the one that was
+// set by us, as opposed to one the user may have set while in the call.
+func (s *inCallStatus) isInCallStatus(cs *model.CustomStatus) bool {
+	return cs != nil && s.Status != nil && cs.Emoji == s.Status.Emoji && cs.Text == s.Status.Text
+}
+
+// updateInCallStatus atomically applies fn to the user's in-call status. A
+// nil result deletes it. It returns the previous and updated values.
+func (p *Plugin) updateInCallStatus(userID string, fn func(*inCallStatus) *inCallStatus) (*inCallStatus, *inCallStatus, error) {
+	key := inCallStatusKey(userID)
+	for range inCallStatusMaxRetries {
+		oldData, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return nil, nil, fmt.Errorf("failed to get in-call status: %w", appErr)
+		}
+
+		var old *inCallStatus
+		if oldData != nil {
+			old = &inCallStatus{}
+			if err := json.Unmarshal(oldData, old); err != nil {
+				return nil, nil, fmt.Errorf("failed to unmarshal in-call status: %w", err)
+			}
+		}
+
+		var cur *inCallStatus
+		if old != nil {
+			clone := *old
+			clone.Sessions = make(map[string]string, len(old.Sessions))
+			for id, channelID := range old.Sessions {
+				clone.Sessions[id] = channelID
+			}
+			cur = &clone
+		}
+
+		updated := fn(cur)
+		if old == nil && updated == nil {
+			return nil, nil, nil
+		}
+
+		var data []byte
+		if updated != nil {
+			var err error
+			if data, err = json.Marshal(updated); err != nil {
+				return nil, nil, fmt.Errorf("failed to marshal in-call status: %w", err)
+			}
+		}
+
+		opts := model.PluginKVSetOptions{
+			Atomic:   true,
+			OldValue: oldData,
+		}
+		if updated != nil {
+			opts.ExpireInSeconds = int64(inCallStatusExpiry.Seconds())
+		}
+
+		ok, appErr := p.API.KVSetWithOptions(key, data, opts)
+		if appErr != nil {
+			return nil, nil, fmt.Errorf("failed to set in-call status: %w", appErr)
+		}
+		if ok {
+			return old, updated, nil
+		}
+	}
+
+	return nil, nil, errInCallStatusConflict
+}
+
+func (p *Plugin) newInCallCustomStatus(user *model.User, channelID string) (*model.CustomStatus, error) {
+	channel, appErr := p.API.GetChannel(channelID)
+	if appErr != nil {
+		return nil, fmt.Errorf("failed to get channel: %w", appErr)
+	}
+
+	T := p.getTranslationFunc(user.Locale)
+	text := T("app.call.in_call_status")
+	if channel.Type == model.ChannelTypeOpen || channel.Type == model.ChannelTypePrivate {
+		text = T("app.call.in_call_status_channel", map[string]any{"ChannelName": channel.Name})
+	}
+
+	return &model.CustomStatus{
+		Emoji: inCallStatusEmoji,
+		Text:  text,
+	}, nil
+}
+
+// queueInCallStatusUpdate queues an update to be applied by a single routine.
+// Updates are queued while holding the call lock, so they are applied in
+// order, but outside of it since they take several API calls.
+func (p *Plugin) queueInCallStatusUpdate(u inCallStatusUpdate) {
+	select {
+	case p.inCallStatusCh <- u:
+	default:
+		p.LogError("too many in-call status updates, channel is full, dropping.", "userID", u.userID, "sessionID", u.sessionID)
+	}
+}
+
+func (p *Plugin) inCallStatusUpdater() {
+	for {
+		select {
+		case u := <-p.inCallStatusCh:
+			if u.join {
+				p.setInCallStatus(u.userID, u.channelID, u.sessionID)
+			} else {
+				p.unsetInCallStatus(u.userID, u.sessionID)
+			}
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+// setInCallStatus sets the user's custom status when they join a call, if
+// they opted in. Any further session only gets tracked so that the previous
+// status is restored once the last one leaves.
+func (p *Plugin) setInCallStatus(userID, channelID, sessionID string) {
+	prefs, err := p.getCallPreferences(userID)
+	if err != nil {
+		p.LogError("failed to get call preferences", "userID", userID, "err", err.Error())
+		return
+	} else if !prefs.InCallStatus {
+		return
+	}
+
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		p.LogError("failed to get user", "userID", userID, "err", appErr.Error())
+		return
+	}
+
+	status, err := p.newInCallCustomStatus(user, channelID)
+	if err != nil {
+		p.LogError("failed to create in-call status", "userID", userID, "err", err.Error())
+		return
+	}
+
+	old, _, err := p.updateInCallStatus(userID, func(s *inCallStatus) *inCallStatus {
+		if s == nil {
+			s = &inCallStatus{
+				Sessions: map[string]string{},
+				Status:   status,
+				Previous: user.GetCustomStatus(),
+			}
+		}
+		s.Sessions[sessionID] = channelID
+		return s
+	})
+	if err != nil {
+		p.LogError("failed to update in-call status", "userID", userID, "err", err.Error())
+		return
+	}
+
+	// The status is only set by the first session.
+	if old != nil {
+		return
+	}
+
+	if appErr := p.API.UpdateUserCustomStatus(userID, status); appErr != nil {
+		p.LogError("failed to set custom status", "userID", userID, "err", appErr.Error())
+	}
+}
+
+// unsetInCallStatus stops tracking the given session, restoring the user's
+// previous custom status when it was the last one.
+func (p *Plugin) unsetInCallStatus(userID, sessionID string) {
+	old, updated, err := p.updateInCallStatus(userID, func(s *inCallStatus) *inCallStatus {
+		if s == nil {
+			return nil
+		}
+		delete(s.Sessions, sessionID)
+		if len(s.Sessions) == 0 {
+			return nil
+		}
+		return s
+	})
+	if err != nil {
+		p.LogError("failed to update in-call status", "userID", userID, "err", err.Error())
+		return
+	}
+
+	if old == nil || updated != nil {
+		return
+	}
+
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		p.LogError("failed to get user", "userID", userID, "err", appErr.Error())
+		return
+	}
+
+	// Users may have changed their status while in the call, in which case we
+	// leave it alone.
+	if !old.isInCallStatus(user.GetCustomStatus()) {
+		return
+	}
+
+	if prev := old.Previous; prev != nil && (prev.ExpiresAt.IsZero() || prev.ExpiresAt.After(time.Now())) {
+		appErr = p.API.UpdateUserCustomStatus(userID, prev)
+	} else {
+		appErr = p.API.RemoveUserCustomStatus(userID)
+	}
+	if appErr != nil {
+		p.LogError("failed to restore custom status", "userID", userID, "err", appErr.Error())
+	}
+}
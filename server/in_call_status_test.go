@@ -0,0 +1,297 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	pluginMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetInCallStatus(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+	}
+
+	userID := model.NewId()
+	channelID := model.NewId()
+	sessionID := model.NewId()
+
+	prev := &model.CustomStatus{Emoji: "palm_tree", Text: "On vacation"}
+	user := &model.User{Id: userID, Locale: "en"}
+	require.NoError(t, user.SetCustomStatus(prev))
+
+	prefsData, err := json.Marshal(callPreferences{Ring: true, InCallStatus: true})
+	require.NoError(t, err)
+
+	matchStatus := func(sessions map[string]string) any {
+		return mock.MatchedBy(func(data []byte) bool {
+			var s inCallStatus
+			if err := json.Unmarshal(data, &s); err != nil {
+				return false
+			}
+			return s.Status != nil && s.Status.Emoji == inCallStatusEmoji &&
+				s.Previous != nil && *s.Previous == *prev &&
+				len(s.Sessions) == len(sessions) && s.Sessions[sessionID] == sessions[sessionID]
+		})
+	}
+
+	t.Run("not opted in", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("KVGet", callPreferencesKey(userID)).Return(nil, nil).Once()
+
+		p.setInCallStatus(userID, channelID, sessionID)
+	})
+
+	t.Run("first session", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("KVGet", callPreferencesKey(userID)).Return(prefsData, nil).Once()
+		mockAPI.On("GetUser", userID).Return(user, nil).Once()
+		mockAPI.On("GetChannel", channelID).Return(&model.Channel{
+			Id:   channelID,
+			Type: model.ChannelTypeOpen,
+			Name: "town-square",
+		}, nil).Once()
+		mockAPI.On("KVGet", inCallStatusKey(userID)).Return(nil, nil).Once()
+		mockAPI.On("KVSetWithOptions", inCallStatusKey(userID), matchStatus(map[string]string{sessionID: channelID}),
+			model.PluginKVSetOptions{
+				Atomic:          true,
+				ExpireInSeconds: int64(inCallStatusExpiry.Seconds()),
+			}).Return(true, nil).Once()
+		mockAPI.On("UpdateUserCustomStatus", userID, mock.MatchedBy(func(cs *model.CustomStatus) bool {
+			return cs.Emoji == inCallStatusEmoji
+		})).Return(nil).Once()
+
+		p.setInCallStatus(userID, channelID, sessionID)
+	})
+
+	t.Run("additional session", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		otherSessionID := model.NewId()
+		oldData, err := json.Marshal(inCallStatus{
+			Sessions: map[string]string{otherSessionID: channelID},
+			Status:   &model.CustomStatus{Emoji: inCallStatusEmoji, Text: "In a call"},
+			Previous: prev,
+		})
+		require.NoError(t, err)
+
+		mockAPI.On("KVGet", callPreferencesKey(userID)).Return(prefsData, nil).Once()
+		mockAPI.On("GetUser", userID).Return(user, nil).Once()
+		mockAPI.On("GetChannel", channelID).Return(&model.Channel{
+			Id:   channelID,
+			Type: model.ChannelTypeDirect,
+		}, nil).Once()
+		mockAPI.On("KVGet", inCallStatusKey(userID)).Return(oldData, nil).Once()
+		mockAPI.On("KVSetWithOptions", inCallStatusKey(userID), matchStatus(map[string]string{
+			sessionID:      channelID,
+			otherSessionID: channelID,
+		}), model.PluginKVSetOptions{
+			Atomic:          true,
+			OldValue:        oldData,
+			ExpireInSeconds: int64(inCallStatusExpiry.Seconds()),
+		}).Return(true, nil).Once()
+
+		// The status should not be set again.
+		p.setInCallStatus(userID, channelID, sessionID)
+	})
+}
+
+func TestUnsetInCallStatus(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+	}
+
+	userID := model.NewId()
+	channelID := model.NewId()
+	sessionID := model.NewId()
+	otherSessionID := model.NewId()
+
+	status := &model.CustomStatus{Emoji: inCallStatusEmoji, Text: "In a call"}
+	prev := &model.CustomStatus{Emoji: "palm_tree", Text: "On vacation"}
+
+	newData := func(t *testing.T, prev *model.CustomStatus, sessionIDs ...string) []byte {
+		t.Helper()
+		s := inCallStatus{
+			Sessions: map[string]string{},
+			Status:   status,
+			Previous: prev,
+		}
+		for _, id := range sessionIDs {
+			s.Sessions[id] = channelID
+		}
+		data, err := json.Marshal(s)
+		require.NoError(t, err)
+		return data
+	}
+
+	newUser := func(t *testing.T, cs *model.CustomStatus) *model.User {
+		t.Helper()
+		user := &model.User{Id: userID}
+		require.NoError(t, user.SetCustomStatus(cs))
+		return user
+	}
+
+	t.Run("no status", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("KVGet", inCallStatusKey(userID)).Return(nil, nil).Once()
+
+		p.unsetInCallStatus(userID, sessionID)
+	})
+
+	t.Run("other sessions left", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		oldData := newData(t, prev, sessionID, otherSessionID)
+		mockAPI.On("KVGet", inCallStatusKey(userID)).Return(oldData, nil).Once()
+		mockAPI.On("KVSetWithOptions", inCallStatusKey(userID), newData(t, prev, otherSessionID), model.PluginKVSetOptions{
+			Atomic:          true,
+			OldValue:        oldData,
+			ExpireInSeconds: int64(inCallStatusExpiry.Seconds()),
+		}).Return(true, nil).Once()
+
+		p.unsetInCallStatus(userID, sessionID)
+	})
+
+	t.Run("last session restores previous status", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		oldData := newData(t, prev, sessionID)
+		mockAPI.On("KVGet", inCallStatusKey(userID)).Return(oldData, nil).Once()
+		mockAPI.On("KVSetWithOptions", inCallStatusKey(userID), []byte(nil), model.PluginKVSetOptions{
+			Atomic:   true,
+			OldValue: oldData,
+		}).Return(true, nil).Once()
+		mockAPI.On("GetUser", userID).Return(newUser(t, status), nil).Once()
+		mockAPI.On("UpdateUserCustomStatus", userID, prev).Return(nil).Once()
+
+		p.unsetInCallStatus(userID, sessionID)
+	})
+
+	t.Run("last session removes status", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		expired := &model.CustomStatus{
+			Emoji:     "palm_tree",
+			Text:      "On vacation",
+			Duration:  "date_and_time",
+			ExpiresAt: time.Now().Add(-time.Hour),
+		}
+
+		for _, prev := range []*model.CustomStatus{nil, expired} {
+			oldData := newData(t, prev, sessionID)
+			mockAPI.On("KVGet", inCallStatusKey(userID)).Return(oldData, nil).Once()
+			mockAPI.On("KVSetWithOptions", inCallStatusKey(userID), []byte(nil), model.PluginKVSetOptions{
+				Atomic:   true,
+				OldValue: oldData,
+			}).Return(true, nil).Once()
+			mockAPI.On("GetUser", userID).Return(newUser(t, status), nil).Once()
+			mockAPI.On("RemoveUserCustomStatus", userID).Return(nil).Once()
+
+			p.unsetInCallStatus(userID, sessionID)
+		}
+	})
+
+	t.Run("status changed during call", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		oldData := newData(t, prev, sessionID)
+		mockAPI.On("KVGet", inCallStatusKey(userID)).Return(oldData, nil).Once()
+		mockAPI.On("KVSetWithOptions", inCallStatusKey(userID), []byte(nil), model.PluginKVSetOptions{
+			Atomic:   true,
+			OldValue: oldData,
+		}).Return(true, nil).Once()
+		mockAPI.On("GetUser", userID).Return(newUser(t, &model.CustomStatus{Emoji: "coffee", Text: "Break"}), nil).Once()
+
+		p.unsetInCallStatus(userID, sessionID)
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		oldData := newData(t, prev, sessionID, otherSessionID)
+		mockAPI.On("KVGet", inCallStatusKey(userID)).Return(oldData, nil).Times(inCallStatusMaxRetries)
+		mockAPI.On("KVSetWithOptions", inCallStatusKey(userID), mock.Anything, mock.Anything).
+			Return(false, nil).Times(inCallStatusMaxRetries)
+		mockAPI.On("LogError", "failed to update in-call status",
+			"origin", mock.AnythingOfType("string"), "userID", userID, "err", mock.AnythingOfType("string")).Once()
+
+		p.unsetInCallStatus(userID, sessionID)
+	})
+}
+
+func TestQueueInCallStatusUpdate(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		stopCh:         make(chan struct{}),
+		inCallStatusCh: make(chan inCallStatusUpdate, 2),
+	}
+
+	userID := model.NewId()
+	channelID := model.NewId()
+	sessionID := model.NewId()
+
+	join := inCallStatusUpdate{userID: userID, channelID: channelID, sessionID: sessionID, join: true}
+	leave := inCallStatusUpdate{userID: userID, channelID: channelID, sessionID: sessionID}
+
+	t.Run("full", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("LogError", "too many in-call status updates, channel is full, dropping.",
+			"origin", mock.AnythingOfType("string"), "userID", userID, "sessionID", sessionID).Once()
+
+		p.queueInCallStatusUpdate(join)
+		p.queueInCallStatusUpdate(leave)
+		p.queueInCallStatusUpdate(join)
+
+		require.Equal(t, join, <-p.inCallStatusCh)
+		require.Equal(t, leave, <-p.inCallStatusCh)
+		require.Empty(t, p.inCallStatusCh)
+	})
+
+	t.Run("applied in order", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		done := make(chan struct{})
+		mockAPI.On("KVGet", callPreferencesKey(userID)).Return(nil, nil).Once()
+		mockAPI.On("KVGet", inCallStatusKey(userID)).Return(nil, nil).Once().Run(func(_ mock.Arguments) {
+			close(done)
+		})
+
+		p.queueInCallStatusUpdate(join)
+		p.queueInCallStatusUpdate(leave)
+
+		go p.inCallStatusUpdater()
+		defer close(p.stopCh)
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			require.Fail(t, "timed out waiting for updates")
+		}
+	})
+}
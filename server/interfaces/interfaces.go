@@ -36,6 +36,8 @@ type Metrics interface {
 	ObserveStoreMethodsTime(method string, elapsed float64)
 	RegisterDBMetrics(db *sql.DB, name string)
 	IncClientICECandidatePairs(p public.ClientICECandidatePairMetricPayload)
+	SetJobServiceAvailability(available bool)
+	ObserveJobServiceRequestTime(op string, elapsed float64)
 }
 
 type StoreMetrics interface {
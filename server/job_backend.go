@@ -0,0 +1,57 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	offloader "github.com/mattermost/calls-offloader/public"
+	"github.com/mattermost/calls-offloader/public/job"
+)
+
+const (
+	jobBackendOffloader = "offloader"
+	jobBackendLocal     = "local"
+)
+
+// jobBackend is where recording and transcribing jobs run. Jobs report
+// their progress directly to the plugin through the bot API and get stopped
+// through websocket events, so backends are only responsible for starting
+// them.
+type jobBackend interface {
+	// Init prepares the backend to run jobs on the given runners (e.g.
+	// prefetching their images).
+	Init(runners []string) error
+	// Check returns an error if the backend can't currently run jobs.
+	Check() error
+	// CreateJob starts a new job, returning its ID.
+	CreateJob(cfg job.Config) (string, error)
+	Close() error
+}
+
+// offloaderJobBackend runs jobs through the calls-offloader service.
+type offloaderJobBackend struct {
+	client *offloader.Client
+}
+
+func (b *offloaderJobBackend) Init(runners []string) error {
+	return b.client.Init(job.ServiceConfig{
+		Runners: runners,
+	})
+}
+
+func (b *offloaderJobBackend) Check() error {
+	_, err := b.client.GetVersionInfo()
+	return err
+}
+
+func (b *offloaderJobBackend) CreateJob(cfg job.Config) (string, error) {
+	jb, err := b.client.CreateJob(cfg)
+	if err != nil {
+		return "", err
+	}
+	return jb.ID, nil
+}
+
+func (b *offloaderJobBackend) Close() error {
+	return b.client.Close()
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/calls-offloader/public/job"
+)
+
+// localJobOutputMaxSize is how much of a job's output is kept to be logged
+// if it fails.
+const localJobOutputMaxSize = 4096
+
+// localJobStopTimeout is how long a job gets to exit after being interrupted
+// before it's killed.
+const localJobStopTimeout = 30 * time.Second
+
+// localJobInheritedEnv lists the only environment variables jobs inherit from
+// the server, so that its secrets don't leak to them.
+var localJobInheritedEnv = []string{"HOME", "PATH"}
+
+// localJobBackend runs jobs as processes on the same host as the Mattermost
+// server, which avoids deploying calls-offloader on dev and small
+// installations. Each job type runs its own command (e.g. a calls-recorder
+// binary) with the job's input data passed as environment variables, the
+// same way calls-offloader passes it to containers.
+type localJobBackend struct {
+	ctx      *Plugin
+	commands map[job.Type][]string
+
+	mut    sync.Mutex
+	jobs   map[string]context.CancelFunc
+	wg     sync.WaitGroup
+	closed bool
+}
+
+func (p *Plugin) newLocalJobBackend(commands map[job.Type]string) (*localJobBackend, error) {
+	b := &localJobBackend{
+		ctx:      p,
+		commands: map[job.Type][]string{},
+		jobs:     map[string]context.CancelFunc{},
+	}
+
+	for jobType, cmd := range commands {
+		if args := strings.Fields(cmd); len(args) > 0 {
+			b.commands[jobType] = args
+		}
+	}
+
+	if _, ok := b.commands[job.TypeRecording]; !ok {
+		return nil, fmt.Errorf("recorder command should not be empty")
+	}
+
+	return b, nil
+}
+
+// Init checks that the configured commands can be found, as runners are
+// container images and don't apply to local jobs.
+func (b *localJobBackend) Init(_ []string) error {
+	return b.Check()
+}
+
+func (b *localJobBackend) Check() error {
+	for jobType, args := range b.commands {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			return fmt.Errorf("failed to find %s command: %w", jobType, err)
+		}
+	}
+	return nil
+}
+
+func (b *localJobBackend) CreateJob(cfg job.Config) (string, error) {
+	args, ok := b.commands[cfg.Type]
+	if !ok {
+		return "", fmt.Errorf("no command configured for %s jobs", cfg.Type)
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	if b.closed {
+		return "", fmt.Errorf("backend is closed")
+	}
+
+	jobID := model.NewId()
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if cfg.MaxDurationSec > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(cfg.MaxDurationSec)*time.Second)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	var output jobOutput
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...) // #nosec G204
+	cmd.Env = jobEnv(cfg.InputData)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// Giving the job a chance to exit cleanly (e.g. to upload what was
+	// recorded so far) before killing it.
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = localJobStopTimeout
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return "", fmt.Errorf("failed to start job: %w", err)
+	}
+
+	b.jobs[jobID] = cancel
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		err := cmd.Wait()
+
+		b.mut.Lock()
+		delete(b.jobs, jobID)
+		b.mut.Unlock()
+		cancel()
+
+		if err != nil {
+			b.ctx.LogError("local job failed", "jobID", jobID, "type", string(cfg.Type), "err", err.Error(), "output", output.String())
+			return
+		}
+		b.ctx.LogDebug("local job done", "jobID", jobID, "type", string(cfg.Type))
+	}()
+
+	b.ctx.LogDebug("local job started", "jobID", jobID, "type", string(cfg.Type), "pid", cmd.Process.Pid)
+
+	return jobID, nil
+}
+
+// Close stops all the running jobs, as they would otherwise outlive the
+// plugin.
+func (b *localJobBackend) Close() error {
+	b.mut.Lock()
+	b.closed = true
+	for _, cancel := range b.jobs {
+		cancel()
+	}
+	b.mut.Unlock()
+
+	b.wg.Wait()
+
+	return nil
+}
+
+// jobEnv returns the environment variables for a job: the few inherited from
+// the server followed by the given input data, with uppercased keys.
+func jobEnv(data job.InputData) []string {
+	env := make([]string, 0, len(localJobInheritedEnv)+len(data))
+	for _, k := range localJobInheritedEnv {
+		if v, ok := os.LookupEnv(k); ok {
+			env = append(env, k+"="+v)
+		}
+	}
+
+	dataEnv := make([]string, 0, len(data))
+	for k, v := range data {
+		dataEnv = append(dataEnv, fmt.Sprintf("%s=%v", strings.ToUpper(k), v))
+	}
+	sort.Strings(dataEnv)
+
+	return append(env, dataEnv...)
+}
+
+// jobOutput keeps the tail of a job's output.
+type jobOutput struct {
+	buf []byte
+}
+
+func (o *jobOutput) Write(p []byte) (int, error) {
+	o.buf = append(o.buf, p...)
+	if len(o.buf) > localJobOutputMaxSize {
+		o.buf = o.buf[len(o.buf)-localJobOutputMaxSize:]
+	}
+	return len(p), nil
+}
+
+func (o *jobOutput) String() string {
+	return string(o.buf)
+}
@@ -66,8 +66,11 @@ var recorderBaseConfigs = map[string]recorder.RecorderConfig{
 }
 
 type jobService struct {
-	ctx    *Plugin
-	client *offloader.Client
+	ctx     *Plugin
+	backend jobBackend
+	health  jobServiceHealth
+	stopCh  chan struct{}
+	doneCh  chan struct{}
 }
 
 func (p *Plugin) getStoredJobServiceClientConfig() (offloader.ClientConfig, error) {
@@ -161,7 +164,7 @@ func (p *Plugin) getJobServiceClientConfig(serviceURL string) (offloader.ClientC
 	return cfg, nil
 }
 
-func (p *Plugin) newJobService(serviceURL string) (*jobService, error) {
+func (p *Plugin) newOffloaderJobBackend(serviceURL string) (*offloaderJobBackend, error) {
 	if serviceURL == "" {
 		return nil, fmt.Errorf("serviceURL should not be empty")
 	}
@@ -197,12 +200,38 @@ func (p *Plugin) newJobService(serviceURL string) (*jobService, error) {
 		return nil, err
 	}
 
-	return &jobService{
-		ctx:    p,
+	return &offloaderJobBackend{
 		client: client,
 	}, nil
 }
 
+// newJobService creates a job service running jobs through the backend
+// selected by the MM_CALLS_JOB_SERVICE_BACKEND environment variable, the
+// calls-offloader service being the default.
+func (p *Plugin) newJobService() (*jobService, error) {
+	var backend jobBackend
+	var err error
+	switch name := os.Getenv("MM_CALLS_JOB_SERVICE_BACKEND"); name {
+	case "", jobBackendOffloader:
+		backend, err = p.newOffloaderJobBackend(p.getConfiguration().getJobServiceURL())
+	case jobBackendLocal:
+		backend, err = p.newLocalJobBackend(map[job.Type]string{
+			job.TypeRecording:    os.Getenv("MM_CALLS_JOB_SERVICE_LOCAL_RECORDER_CMD"),
+			job.TypeTranscribing: os.Getenv("MM_CALLS_JOB_SERVICE_LOCAL_TRANSCRIBER_CMD"),
+		})
+	default:
+		return nil, fmt.Errorf("unknown job service backend %q", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &jobService{
+		ctx:     p,
+		backend: backend,
+	}, nil
+}
+
 func (p *Plugin) getJobService() *jobService {
 	p.mut.RLock()
 	defer p.mut.RUnlock()
@@ -253,12 +282,16 @@ func (s *jobService) Init(runners []string) error {
 	}
 	defer mutex.Unlock()
 
-	return s.client.Init(job.ServiceConfig{
-		Runners: runners,
-	})
+	return s.backend.Init(runners)
 }
 
-func (s *jobService) RunJob(jobType job.Type, callID, postID, jobID, authToken string) (string, error) {
+// RunJob starts a new job of the given type. trOpts are only applicable to
+// transcribing jobs and, if nil, the plugin defaults are used.
+func (s *jobService) RunJob(jobType job.Type, callID, postID, jobID, authToken string, trOpts *TranscriptionOptions) (string, error) {
+	if !s.Available() {
+		return "", errJobServiceUnavailable
+	}
+
 	cfg := s.ctx.getConfiguration()
 	if cfg == nil {
 		return "", fmt.Errorf("failed to get plugin configuration")
@@ -304,32 +337,7 @@ func (s *jobService) RunJob(jobType job.Type, callID, postID, jobID, authToken s
 
 		applyEnvOverrides(jobCfg.InputData, "MM_CALLS_RECORDER_")
 	case job.TypeTranscribing:
-		var transcriberConfig transcriber.CallTranscriberConfig
-		transcriberConfig.SetDefaults()
-		transcriberConfig.SiteURL = siteURL
-		if siteURLOverride := os.Getenv("MM_CALLS_TRANSCRIBER_SITE_URL"); siteURLOverride != "" {
-			s.ctx.LogInfo("using SiteURL override for transcriber job", "siteURL", siteURL, "siteURLOverride", siteURLOverride)
-			transcriberConfig.SiteURL = siteURLOverride
-		}
-		transcriberConfig.CallID = callID
-		transcriberConfig.PostID = postID
-		transcriberConfig.TranscriptionID = jobID
-		transcriberConfig.AuthToken = authToken
-		transcriberConfig.ModelSize = cfg.TranscriberModelSize
-		transcriberConfig.TranscribeAPI = cfg.TranscribeAPI
-		if cfg.TranscribeAPI == transcriber.TranscribeAPIAzure {
-			transcriberConfig.TranscribeAPIOptions = map[string]any{
-				"AZURE_SPEECH_KEY":    cfg.TranscribeAPIAzureSpeechKey,
-				"AZURE_SPEECH_REGION": cfg.TranscribeAPIAzureSpeechRegion,
-			}
-		}
-		transcriberConfig.LiveCaptionsOn = cfg.liveCaptionsEnabled()
-		transcriberConfig.LiveCaptionsModelSize = cfg.LiveCaptionsModelSize
-		transcriberConfig.LiveCaptionsNumTranscribers = *cfg.LiveCaptionsNumTranscribers
-		transcriberConfig.NumThreads = *cfg.TranscriberNumThreads
-		transcriberConfig.LiveCaptionsNumThreadsPerTranscriber = *cfg.LiveCaptionsNumThreadsPerTranscriber
-		transcriberConfig.LiveCaptionsLanguage = cfg.LiveCaptionsLanguage
-
+		transcriberConfig := s.newTranscriberConfig(cfg, siteURL, callID, postID, jobID, authToken, trOpts)
 		if err := transcriberConfig.IsValid(); err != nil {
 			return "", fmt.Errorf("transcriber config is not valid: %w", err)
 		}
@@ -345,17 +353,61 @@ func (s *jobService) RunJob(jobType job.Type, callID, postID, jobID, authToken s
 		applyEnvOverrides(jobCfg.InputData, "MM_CALLS_TRANSCRIBER_")
 	}
 
-	jb, err := s.client.CreateJob(jobCfg)
-	if err != nil {
-		return "", err
+	start := time.Now()
+	id, err := s.backend.CreateJob(jobCfg)
+	var transportErr error
+	if isJobServiceTransportError(err) {
+		transportErr = err
 	}
+	s.observe("create_job", start, transportErr)
 
-	return jb.ID, nil
+	return id, err
+}
+
+// newTranscriberConfig returns the configuration for a transcribing job. trOpts
+// can be nil, in which case the plugin defaults are used.
+func (s *jobService) newTranscriberConfig(cfg *configuration, siteURL, callID, postID, jobID, authToken string, trOpts *TranscriptionOptions) transcriber.CallTranscriberConfig {
+	var transcriberConfig transcriber.CallTranscriberConfig
+	transcriberConfig.SetDefaults()
+	transcriberConfig.SiteURL = siteURL
+	if siteURLOverride := os.Getenv("MM_CALLS_TRANSCRIBER_SITE_URL"); siteURLOverride != "" {
+		s.ctx.LogInfo("using SiteURL override for transcriber job", "siteURL", siteURL, "siteURLOverride", siteURLOverride)
+		transcriberConfig.SiteURL = siteURLOverride
+	}
+	transcriberConfig.CallID = callID
+	transcriberConfig.PostID = postID
+	transcriberConfig.TranscriptionID = jobID
+	transcriberConfig.AuthToken = authToken
+	if trOpts == nil {
+		trOpts = &TranscriptionOptions{
+			Language:  cfg.LiveCaptionsLanguage,
+			API:       cfg.TranscribeAPI,
+			ModelSize: cfg.TranscriberModelSize,
+		}
+	}
+	transcriberConfig.ModelSize = trOpts.ModelSize
+	transcriberConfig.TranscribeAPI = trOpts.API
+	if trOpts.API == transcriber.TranscribeAPIAzure {
+		transcriberConfig.TranscribeAPIOptions = map[string]any{
+			"AZURE_SPEECH_KEY":    cfg.TranscribeAPIAzureSpeechKey,
+			"AZURE_SPEECH_REGION": cfg.TranscribeAPIAzureSpeechRegion,
+		}
+	}
+	transcriberConfig.LiveCaptionsOn = cfg.liveCaptionsEnabled()
+	transcriberConfig.LiveCaptionsModelSize = cfg.LiveCaptionsModelSize
+	transcriberConfig.LiveCaptionsNumTranscribers = *cfg.LiveCaptionsNumTranscribers
+	transcriberConfig.NumThreads = *cfg.TranscriberNumThreads
+	transcriberConfig.LiveCaptionsNumThreadsPerTranscriber = *cfg.LiveCaptionsNumThreadsPerTranscriber
+	// The transcriber applies this language to both live captions and the
+	// post-call transcription.
+	transcriberConfig.LiveCaptionsLanguage = trOpts.Language
+
+	return transcriberConfig
 }
 
 // applyEnvOverrides reads environment variables with the given prefix and merges
 // them into inputData, stripping the prefix and lowercasing the key.
-// The offloader will uppercase the keys again when setting container env vars.
+// Backends uppercase the keys again when setting the job's env vars.
 func applyEnvOverrides(inputData job.InputData, prefix string) {
 	for _, env := range os.Environ() {
 		if strings.HasPrefix(env, prefix) {
@@ -368,7 +420,11 @@ func applyEnvOverrides(inputData job.InputData, prefix string) {
 }
 
 func (s *jobService) Close() error {
-	return s.client.Close()
+	if s.stopCh != nil {
+		close(s.stopCh)
+		<-s.doneCh
+	}
+	return s.backend.Close()
 }
 
 func (p *Plugin) jobServiceVersionCheck(client *offloader.Client) error {
@@ -427,7 +483,7 @@ func (p *Plugin) initJobService() error {
 		runners = append(runners, transcriberJobRunner)
 	}
 
-	jobService, err := p.newJobService(p.getConfiguration().getJobServiceURL())
+	jobService, err := p.newJobService()
 	if err != nil {
 		return fmt.Errorf("failed to create job service: %w", err)
 	}
@@ -436,6 +492,8 @@ func (p *Plugin) initJobService() error {
 		return err
 	}
 
+	jobService.startHealthProbes(jobServiceProbeInterval)
+
 	p.mut.Lock()
 	p.jobService = jobService
 	p.mut.Unlock()
@@ -0,0 +1,115 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	jobServiceProbeInterval = 30 * time.Second
+	// The number of consecutive failed requests after which the job service
+	// is considered unavailable.
+	jobServiceFailureThreshold = 3
+)
+
+var errJobServiceUnavailable = errors.New("job service is currently unavailable, please try again later or contact a system admin")
+
+// jobServiceHealth is a circuit breaker tracking the availability of the job
+// service. It opens after jobServiceFailureThreshold consecutive failed
+// requests, making new jobs fail fast instead of timing out, and closes again
+// on the first successful one, usually a health probe.
+type jobServiceHealth struct {
+	mut      sync.RWMutex
+	failures int
+	open     bool
+}
+
+// Available returns whether the job service is currently able to run jobs.
+func (s *jobService) Available() bool {
+	s.health.mut.RLock()
+	defer s.health.mut.RUnlock()
+	return !s.health.open
+}
+
+// observe records the outcome of a request to the job service.
+func (s *jobService) observe(op string, start time.Time, err error) {
+	s.ctx.metrics.ObserveJobServiceRequestTime(op, time.Since(start).Seconds())
+
+	s.health.mut.Lock()
+	wasOpen := s.health.open
+	if err != nil {
+		s.health.failures++
+		s.health.open = s.health.failures >= jobServiceFailureThreshold
+	} else {
+		s.health.failures = 0
+		s.health.open = false
+	}
+	isOpen := s.health.open
+	s.health.mut.Unlock()
+
+	if isOpen == wasOpen {
+		return
+	}
+
+	s.ctx.metrics.SetJobServiceAvailability(!isOpen)
+	if isOpen {
+		s.ctx.LogError("job service is unavailable", "err", err.Error())
+	} else {
+		s.ctx.LogInfo("job service is available again")
+	}
+
+	// Letting clients know so they can disable recording controls without
+	// having to refetch the config.
+	s.ctx.publishWebSocketEvent(wsEventJobServiceAvailability, map[string]interface{}{
+		"available": !isOpen,
+	}, &WebSocketBroadcast{ReliableClusterSend: true})
+}
+
+// isJobServiceTransportError returns whether err is due to failing to reach
+// the job service. Requests it rejects (e.g. an invalid job config) don't
+// make it unavailable.
+func isJobServiceTransportError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// probe checks the health of the job service.
+func (s *jobService) probe() {
+	start := time.Now()
+	err := s.backend.Check()
+	if err != nil {
+		s.ctx.LogWarn("job service health probe failed", "err", err.Error())
+	}
+	s.observe("probe", start, err)
+}
+
+// startHealthProbes periodically probes the job service until it's closed.
+func (s *jobService) startHealthProbes(interval time.Duration) {
+	s.stopCh = make(chan struct{})
+	s.doneCh = make(chan struct{})
+	s.ctx.metrics.SetJobServiceAvailability(s.Available())
+
+	go func() {
+		defer close(s.doneCh)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.probe()
+			case <-s.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (p *Plugin) jobServiceAvailable() bool {
+	jobService := p.getJobService()
+	return jobService != nil && jobService.Available()
+}
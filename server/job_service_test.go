@@ -4,20 +4,516 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/mattermost/mattermost-plugin-calls/server/cluster"
+	"github.com/mattermost/mattermost-plugin-calls/server/db"
+	"github.com/mattermost/mattermost-plugin-calls/server/enterprise"
 	serverMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost-plugin-calls/server/interfaces"
 	pluginMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/plugin"
 
 	"github.com/mattermost/calls-offloader/public/job"
+	transcriber "github.com/mattermost/calls-transcriber/cmd/transcriber/config"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
+// fakeJobBackend is a jobBackend keeping track of the jobs it's asked to run,
+// optionally failing to. Given the plugin's API, it can also simulate jobs
+// reporting their progress the way the recorder and transcriber do.
+type fakeJobBackend struct {
+	mut       sync.Mutex
+	initErr   error
+	checkErr  error
+	createErr error
+	checks    int
+	runners   []string
+	jobs      map[string]job.Config
+	closed    bool
+
+	botAPI    http.Handler
+	botUserID string
+}
+
+func newFakeJobBackend() *fakeJobBackend {
+	return &fakeJobBackend{
+		jobs: map[string]job.Config{},
+	}
+}
+
+func (b *fakeJobBackend) Init(runners []string) error {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.runners = runners
+	return b.initErr
+}
+
+func (b *fakeJobBackend) Check() error {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.checks++
+	return b.checkErr
+}
+
+func (b *fakeJobBackend) CreateJob(cfg job.Config) (string, error) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	if b.createErr != nil {
+		return "", b.createErr
+	}
+	jobID := fmt.Sprintf("job%d", len(b.jobs)+1)
+	b.jobs[jobID] = cfg
+	return jobID, nil
+}
+
+func (b *fakeJobBackend) Close() error {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	b.closed = true
+	return nil
+}
+
+// setJobStatus reports the status of a job through the bot API, returning the
+// response code.
+func (b *fakeJobBackend) setJobStatus(t *testing.T, channelID, jobID string, status public.JobStatus) int {
+	t.Helper()
+
+	data, err := json.Marshal(status)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", fmt.Sprintf("/bot/calls/%s/jobs/%s/status", channelID, jobID), bytes.NewReader(data))
+	r.Header.Set("Mattermost-User-Id", b.botUserID)
+	b.botAPI.ServeHTTP(w, r)
+
+	return w.Result().StatusCode
+}
+
+func TestJobServiceRunJob(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	mockMetrics := &serverMocks.MockMetrics{}
+	defer mockAPI.AssertExpectations(t)
+
+	p := &Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		metrics: mockMetrics,
+	}
+
+	mockMetrics.On("ObserveJobServiceRequestTime", "create_job", mock.AnythingOfType("float64"))
+	cfg := p.getConfiguration()
+
+	siteURL := "http://localhost:8065"
+	mockAPI.On("GetConfig").Return(&model.Config{
+		ServiceSettings: model.ServiceSettings{
+			SiteURL: &siteURL,
+		},
+	})
+
+	backend := newFakeJobBackend()
+	p.jobService = &jobService{
+		ctx:     p,
+		backend: backend,
+	}
+
+	t.Run("recording", func(t *testing.T) {
+		t.Setenv("MM_CALLS_RECORDER_TLS_INSECURE_SKIP_VERIFY", "true")
+
+		jobID, err := p.jobService.RunJob(job.TypeRecording, "callID", "postID", "recID", "authToken", nil)
+		require.NoError(t, err)
+
+		jobCfg, ok := backend.jobs[jobID]
+		require.True(t, ok)
+		require.Equal(t, job.TypeRecording, jobCfg.Type)
+		require.Equal(t, recorderJobRunner, jobCfg.Runner)
+		require.Equal(t, int64(*cfg.MaxRecordingDuration*60), jobCfg.MaxDurationSec)
+		require.Equal(t, "true", jobCfg.InputData["tls_insecure_skip_verify"])
+	})
+
+	t.Run("transcribing", func(t *testing.T) {
+		jobID, err := p.jobService.RunJob(job.TypeTranscribing, "callID", "postID", "trID", "authToken", &TranscriptionOptions{
+			Language:  "fr",
+			API:       transcriber.TranscribeAPIDefault,
+			ModelSize: transcriber.ModelSizeDefault,
+		})
+		require.NoError(t, err)
+
+		jobCfg, ok := backend.jobs[jobID]
+		require.True(t, ok)
+		require.Equal(t, job.TypeTranscribing, jobCfg.Type)
+		require.Equal(t, transcriberJobRunner, jobCfg.Runner)
+		require.Equal(t, int64(*cfg.MaxRecordingDuration*60)*2, jobCfg.MaxDurationSec)
+	})
+
+	t.Run("backend failure", func(t *testing.T) {
+		backend.createErr = fmt.Errorf("no capacity")
+		defer func() { backend.createErr = nil }()
+
+		_, err := p.jobService.RunJob(job.TypeRecording, "callID", "postID", "recID", "authToken", nil)
+		require.EqualError(t, err, "no capacity")
+	})
+
+	t.Run("close", func(t *testing.T) {
+		require.NoError(t, p.jobService.Close())
+		require.True(t, backend.closed)
+	})
+}
+
+func TestJobServiceLifecycle(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	mockMetrics := &serverMocks.MockMetrics{}
+
+	botUserID := model.NewId()
+	channelID := model.NewId()
+	p := &Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		metrics:           mockMetrics,
+		callsClusterLocks: map[string]*cluster.Mutex{},
+		botSession: &model.Session{
+			UserId: botUserID,
+			Token:  "authToken",
+		},
+	}
+	p.licenseChecker = enterprise.NewLicenseChecker(p.API)
+
+	store, tearDown := NewTestStore(t)
+	t.Cleanup(tearDown)
+	p.store = store
+
+	mockHostControls(mockAPI, mockMetrics)
+	mockAPI.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything).Maybe()
+	mockAPI.On("LogError", "failed to save recording metadata", "origin", mock.AnythingOfType("string"),
+		"err", mock.AnythingOfType("string")).Maybe()
+	// The checker timing out recordings that don't start can outlive the test.
+	mockAPI.On("LogError", "failed to lock call", "origin", mock.AnythingOfType("string"),
+		"err", mock.AnythingOfType("string"), "callID", channelID, "jobID", mock.AnythingOfType("string")).Maybe()
+	mockAPI.On("LogWarn", "failed to get recording state", "origin", mock.AnythingOfType("string"),
+		"err", mock.AnythingOfType("string"), "callID", channelID, "jobID", mock.AnythingOfType("string")).Maybe()
+	mockMetrics.On("Handler").Return(nil).Once()
+	mockMetrics.On("ObserveJobServiceRequestTime", "create_job", mock.AnythingOfType("float64"))
+
+	// Audit log
+	auditArgs := []any{"handleBotPostJobsStatus", "origin", mock.AnythingOfType("string")}
+	for range 14 {
+		auditArgs = append(auditArgs, mock.Anything)
+	}
+	mockAPI.On("LogDebug", auditArgs...).Maybe()
+
+	siteURL := "http://localhost:8065"
+	mockAPI.On("GetConfig").Return(&model.Config{
+		ServiceSettings: model.ServiceSettings{
+			SiteURL: &siteURL,
+		},
+	})
+	mockAPI.On("GetLicense").Return(&model.License{
+		SkuShortName: "enterprise",
+	}, nil)
+
+	backend := newFakeJobBackend()
+	backend.botAPI = p.newAPIRouter()
+	backend.botUserID = botUserID
+	p.jobService = &jobService{
+		ctx:     p,
+		backend: backend,
+	}
+
+	call := createHostControlsTestCall(t, p, channelID, "hostID")
+
+	startRecording := func(t *testing.T) (*public.CallJob, error) {
+		t.Helper()
+
+		state, err := p.lockCallReturnState(channelID)
+		require.NoError(t, err)
+		_, _, err = p.startRecordingJob(state, channelID, "hostID", TranscriptionOptions{})
+		p.unlockCall(channelID)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs, err := p.store.GetActiveCallJobs(call.ID, db.GetCallJobOpts{FromWriter: true})
+		require.NoError(t, err)
+		require.NotNil(t, jobs[public.JobTypeRecording])
+		return jobs[public.JobTypeRecording], nil
+	}
+
+	getJob := func(t *testing.T, jobID string) *public.CallJob {
+		t.Helper()
+		jb, err := p.store.GetCallJob(jobID, db.GetCallJobOpts{FromWriter: true, IncludeEnded: true})
+		require.NoError(t, err)
+		return jb
+	}
+
+	t.Run("started", func(t *testing.T) {
+		recJob, err := startRecording(t)
+		require.NoError(t, err)
+		require.Len(t, backend.jobs, 1)
+		require.NotEmpty(t, recJob.Props.JobID)
+		require.Zero(t, recJob.StartAt)
+
+		code := backend.setJobStatus(t, channelID, recJob.ID, public.JobStatus{
+			JobType: public.JobTypeRecording,
+			Status:  public.JobStatusTypeStarted,
+		})
+		require.Equal(t, http.StatusOK, code)
+
+		recJob = getJob(t, recJob.ID)
+		require.NotZero(t, recJob.StartAt)
+		require.Zero(t, recJob.EndAt)
+
+		// A job can only start once.
+		code = backend.setJobStatus(t, channelID, recJob.ID, public.JobStatus{
+			JobType: public.JobTypeRecording,
+			Status:  public.JobStatusTypeStarted,
+		})
+		require.Equal(t, http.StatusBadRequest, code)
+
+		code = backend.setJobStatus(t, channelID, recJob.ID, public.JobStatus{
+			JobType: public.JobTypeRecording,
+			Status:  public.JobStatusTypeFailed,
+			Error:   "upload failed",
+		})
+		require.Equal(t, http.StatusOK, code)
+
+		recJob = getJob(t, recJob.ID)
+		require.NotZero(t, recJob.EndAt)
+		require.Equal(t, "upload failed", recJob.Props.Err)
+	})
+
+	t.Run("failed before starting", func(t *testing.T) {
+		recJob, err := startRecording(t)
+		require.NoError(t, err)
+		require.Len(t, backend.jobs, 2)
+
+		code := backend.setJobStatus(t, channelID, recJob.ID, public.JobStatus{
+			JobType: public.JobTypeRecording,
+			Status:  public.JobStatusTypeFailed,
+			Error:   "failed to join call",
+		})
+		require.Equal(t, http.StatusOK, code)
+
+		recJob = getJob(t, recJob.ID)
+		require.Zero(t, recJob.StartAt)
+		require.NotZero(t, recJob.EndAt)
+		require.Equal(t, "failed to join call", recJob.Props.Err)
+
+		// An ended job can't start anymore.
+		code = backend.setJobStatus(t, channelID, recJob.ID, public.JobStatus{
+			JobType: public.JobTypeRecording,
+			Status:  public.JobStatusTypeStarted,
+		})
+		require.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("backend failure", func(t *testing.T) {
+		backend.createErr = fmt.Errorf("no capacity")
+		defer func() { backend.createErr = nil }()
+
+		_, err := startRecording(t)
+		require.EqualError(t, err, "failed to create recording job: no capacity")
+		require.Len(t, backend.jobs, 2)
+
+		jobs, err := p.store.GetCallJobs(call.ID, db.GetCallJobOpts{FromWriter: true, IncludeEnded: true})
+		require.NoError(t, err)
+		require.Len(t, jobs, 3)
+		require.NotZero(t, jobs[2].EndAt)
+		require.Equal(t, "no capacity", jobs[2].Props.Err)
+	})
+}
+
+func TestJobServiceNewTranscriberConfig(t *testing.T) {
+	p := &Plugin{}
+	s := &jobService{ctx: p}
+
+	cfg := p.getConfiguration().Clone()
+	cfg.LiveCaptionsLanguage = "en"
+	cfg.TranscribeAPI = transcriber.TranscribeAPIDefault
+	cfg.TranscriberModelSize = transcriber.ModelSizeDefault
+	cfg.TranscribeAPIAzureSpeechKey = "key"
+	cfg.TranscribeAPIAzureSpeechRegion = "region"
+
+	t.Run("defaults", func(t *testing.T) {
+		trCfg := s.newTranscriberConfig(cfg, "http://localhost:8065", "callID", "postID", "trID", "authToken", nil)
+		require.Equal(t, "http://localhost:8065", trCfg.SiteURL)
+		require.Equal(t, "callID", trCfg.CallID)
+		require.Equal(t, "postID", trCfg.PostID)
+		require.Equal(t, "trID", trCfg.TranscriptionID)
+		require.Equal(t, "authToken", trCfg.AuthToken)
+		require.Equal(t, "en", trCfg.LiveCaptionsLanguage)
+		require.Equal(t, transcriber.TranscribeAPIDefault, trCfg.TranscribeAPI)
+		require.Equal(t, transcriber.ModelSizeDefault, trCfg.ModelSize)
+		require.Empty(t, trCfg.TranscribeAPIOptions)
+	})
+
+	t.Run("options", func(t *testing.T) {
+		trCfg := s.newTranscriberConfig(cfg, "http://localhost:8065", "callID", "postID", "trID", "authToken", &TranscriptionOptions{
+			Language:  "fr",
+			API:       transcriber.TranscribeAPIAzure,
+			ModelSize: transcriber.ModelSizeDefault,
+		})
+		require.Equal(t, "fr", trCfg.LiveCaptionsLanguage)
+		require.Equal(t, transcriber.TranscribeAPIAzure, trCfg.TranscribeAPI)
+		require.Equal(t, map[string]any{
+			"AZURE_SPEECH_KEY":    "key",
+			"AZURE_SPEECH_REGION": "region",
+		}, trCfg.TranscribeAPIOptions)
+	})
+}
+
+func TestJobServiceHealth(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	mockMetrics := &serverMocks.MockMetrics{}
+	defer mockAPI.AssertExpectations(t)
+	defer mockMetrics.AssertExpectations(t)
+
+	p := &Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		metrics: mockMetrics,
+	}
+
+	backend := newFakeJobBackend()
+	p.jobService = &jobService{
+		ctx:     p,
+		backend: backend,
+	}
+
+	mockMetrics.On("ObserveJobServiceRequestTime", "probe", mock.AnythingOfType("float64"))
+
+	t.Run("opens after consecutive failures", func(t *testing.T) {
+		backend.checkErr = fmt.Errorf("connection refused")
+
+		mockAPI.On("LogWarn", "job service health probe failed", "origin", mock.AnythingOfType("string"),
+			"err", "connection refused").Times(jobServiceFailureThreshold)
+		mockAPI.On("LogError", "job service is unavailable", "origin", mock.AnythingOfType("string"),
+			"err", "connection refused").Once()
+		mockMetrics.On("SetJobServiceAvailability", false).Once()
+		mockMetrics.On("IncWebSocketEvent", "out", wsEventJobServiceAvailability).Once()
+		mockAPI.On("PublishWebSocketEvent", wsEventJobServiceAvailability, map[string]interface{}{
+			"available": false,
+		}, &model.WebsocketBroadcast{ReliableClusterSend: true}).Once()
+
+		for i := range jobServiceFailureThreshold {
+			require.True(t, p.jobServiceAvailable(), "failure %d", i)
+			p.jobService.probe()
+		}
+		require.False(t, p.jobServiceAvailable())
+
+		// Failing fast without reaching the backend.
+		_, err := p.jobService.RunJob(job.TypeRecording, "callID", "postID", "recID", "authToken", nil)
+		require.Equal(t, errJobServiceUnavailable, err)
+		require.Empty(t, backend.jobs)
+	})
+
+	t.Run("closes on success", func(t *testing.T) {
+		backend.checkErr = nil
+
+		mockAPI.On("LogInfo", "job service is available again", "origin", mock.AnythingOfType("string")).Once()
+		mockMetrics.On("SetJobServiceAvailability", true).Once()
+		mockMetrics.On("IncWebSocketEvent", "out", wsEventJobServiceAvailability).Once()
+		mockAPI.On("PublishWebSocketEvent", wsEventJobServiceAvailability, map[string]interface{}{
+			"available": true,
+		}, &model.WebsocketBroadcast{ReliableClusterSend: true}).Once()
+
+		p.jobService.probe()
+		require.True(t, p.jobServiceAvailable())
+	})
+
+	t.Run("failures are reset on success", func(t *testing.T) {
+		mockAPI.On("LogWarn", "job service health probe failed", "origin", mock.AnythingOfType("string"),
+			"err", "timeout").Times(2 * (jobServiceFailureThreshold - 1))
+
+		for range 2 {
+			backend.checkErr = fmt.Errorf("timeout")
+			for range jobServiceFailureThreshold - 1 {
+				p.jobService.probe()
+			}
+			backend.checkErr = nil
+			p.jobService.probe()
+		}
+		require.True(t, p.jobServiceAvailable())
+	})
+
+	t.Run("only transport errors count when creating jobs", func(t *testing.T) {
+		siteURL := "http://localhost:8065"
+		mockAPI.On("GetConfig").Return(&model.Config{
+			ServiceSettings: model.ServiceSettings{
+				SiteURL: &siteURL,
+			},
+		})
+		mockMetrics.On("ObserveJobServiceRequestTime", "create_job", mock.AnythingOfType("float64"))
+
+		backend.createErr = fmt.Errorf("invalid job config")
+		for range jobServiceFailureThreshold {
+			_, err := p.jobService.RunJob(job.TypeRecording, "callID", "postID", "recID", "authToken", nil)
+			require.EqualError(t, err, "invalid job config")
+		}
+		require.True(t, p.jobServiceAvailable())
+
+		backend.createErr = &url.Error{Op: "Post", URL: "http://localhost:4545/jobs", Err: fmt.Errorf("connection refused")}
+		defer func() { backend.createErr = nil }()
+
+		mockAPI.On("LogError", "job service is unavailable", "origin", mock.AnythingOfType("string"),
+			"err", backend.createErr.Error()).Once()
+		mockMetrics.On("SetJobServiceAvailability", false).Once()
+		mockMetrics.On("IncWebSocketEvent", "out", wsEventJobServiceAvailability).Once()
+		mockAPI.On("PublishWebSocketEvent", wsEventJobServiceAvailability, map[string]interface{}{
+			"available": false,
+		}, &model.WebsocketBroadcast{ReliableClusterSend: true}).Once()
+
+		for range jobServiceFailureThreshold {
+			_, err := p.jobService.RunJob(job.TypeRecording, "callID", "postID", "recID", "authToken", nil)
+			require.Error(t, err)
+		}
+		require.False(t, p.jobServiceAvailable())
+
+		mockAPI.On("LogInfo", "job service is available again", "origin", mock.AnythingOfType("string")).Once()
+		mockMetrics.On("SetJobServiceAvailability", true).Once()
+		mockMetrics.On("IncWebSocketEvent", "out", wsEventJobServiceAvailability).Once()
+		mockAPI.On("PublishWebSocketEvent", wsEventJobServiceAvailability, map[string]interface{}{
+			"available": true,
+		}, &model.WebsocketBroadcast{ReliableClusterSend: true}).Once()
+
+		p.jobService.probe()
+		require.True(t, p.jobServiceAvailable())
+	})
+
+	t.Run("probes", func(t *testing.T) {
+		mockMetrics.On("SetJobServiceAvailability", true).Once()
+
+		p.jobService.startHealthProbes(time.Millisecond)
+		checks := backend.checks
+		require.Eventually(t, func() bool {
+			backend.mut.Lock()
+			defer backend.mut.Unlock()
+			return backend.checks > checks
+		}, 5*time.Second, time.Millisecond)
+		require.NoError(t, p.jobService.Close())
+		require.True(t, backend.closed)
+	})
+
+	t.Run("no job service", func(t *testing.T) {
+		p.jobService = nil
+		require.False(t, p.jobServiceAvailable())
+	})
+}
+
 func TestJobServiceStopJob(t *testing.T) {
 	mockAPI := &pluginMocks.MockAPI{}
 	mockMetrics := &serverMocks.MockMetrics{}
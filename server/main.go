@@ -28,12 +28,15 @@ func main() {
 	p := &Plugin{
 		stopCh:                 make(chan struct{}),
 		clusterEvCh:            make(chan model.PluginClusterEvent, clusterEventQueueSize),
+		gatewayEventCh:         make(chan externalEvent, gatewayEventQueueSize),
+		inCallStatusCh:         make(chan inCallStatusUpdate, inCallStatusQueueSize),
 		sessions:               map[string]*session{},
 		metrics:                performance.NewMetrics(),
 		apiLimiters:            map[string]*rate.Limiter{},
 		callsClusterLocks:      map[string]*cluster.Mutex{},
 		addSessionsBatchers:    map[string]*batching.Batcher{},
 		removeSessionsBatchers: map[string]*batching.Batcher{},
+		captionsBatchers:       map[string]*batching.Batcher{},
 	}
 	p.apiRouter = p.newAPIRouter()
 	plugin.ClientMain(p)
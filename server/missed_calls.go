@@ -0,0 +1,238 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	"github.com/gorilla/mux"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	callBackKeyPrefix = "call_back_"
+	// How long the user calling back has to actually start the call.
+	callBackExpirySeconds = 60
+)
+
+var (
+	errCallBackInvalidPost = errors.New("post is not a missed call")
+	errCallBackOngoingCall = errors.New("a call is already ongoing in the channel")
+)
+
+// callBack tracks a user calling back after a missed call. It's consumed
+// when the user starts the new call so that only the original caller gets
+// rung.
+type callBack struct {
+	UserID   string `json:"user_id"`
+	CallerID string `json:"caller_id"`
+	PostID   string `json:"post_id"`
+	CreateAt int64  `json:"create_at"`
+}
+
+// callBackKey returns the key of the call back the given user requested in
+// the given channel. Keying by user means calls started by anyone else in the
+// channel can't pick it up.
+func callBackKey(channelID, userID string) string {
+	return callBackKeyPrefix + channelID + "_" + userID
+}
+
+// isMissedCall returns true if nobody other than the caller (and the bot) ever
+// joined the call.
+func isMissedCall(call *public.Call, botID string) bool {
+	for userID := range call.Props.Participants {
+		if userID != call.OwnerID && userID != botID {
+			return false
+		}
+	}
+	return true
+}
+
+// checkMissedCall returns true if the given call went unanswered. Only DM and
+// GM calls can be missed since ringing doesn't apply to regular channels.
+func (p *Plugin) checkMissedCall(call *public.Call) bool {
+	if call.PostID == "" || !isMissedCall(call, p.getBotID()) {
+		return false
+	}
+
+	channel, appErr := p.API.GetChannel(call.ChannelID)
+	if appErr != nil {
+		p.LogError("failed to get channel", "channelID", call.ChannelID, "err", appErr.Error())
+		return false
+	}
+
+	return channel.Type == model.ChannelTypeDirect || channel.Type == model.ChannelTypeGroup
+}
+
+// notifyMissedCall lets the channel members that didn't answer know they
+// missed the call, both through a websocket event and a push notification.
+func (p *Plugin) notifyMissedCall(call public.Call) {
+	channel, appErr := p.API.GetChannel(call.ChannelID)
+	if appErr != nil {
+		p.LogError("failed to get channel", "channelID", call.ChannelID, "err", appErr.Error())
+		return
+	}
+
+	caller, appErr := p.API.GetUser(call.OwnerID)
+	if appErr != nil {
+		p.LogError("failed to get caller", "userID", call.OwnerID, "err", appErr.Error())
+		return
+	}
+
+	members, appErr := p.API.GetUsersInChannel(call.ChannelID, model.ChannelSortByUsername, 0, 8)
+	if appErr != nil {
+		p.LogError("failed to get channel users", "channelID", call.ChannelID, "err", appErr.Error())
+		return
+	}
+
+	config := p.API.GetConfig()
+	canPush := p.canSendPushNotifications(config, p.API.GetLicense()) == nil &&
+		config.EmailSettings.SendPushNotifications != nil && *config.EmailSettings.SendPushNotifications
+
+	for _, member := range members {
+		if member.Id == caller.Id || member.IsBot {
+			continue
+		}
+
+		p.publishWebSocketEvent(wsEventCallMissed, map[string]interface{}{
+			"call_id":    call.ID,
+			"channel_id": call.ChannelID,
+			"post_id":    call.PostID,
+			"caller_id":  caller.Id,
+		}, &WebSocketBroadcast{UserID: member.Id, ReliableClusterSend: true})
+
+		if !canPush || !p.userAllowsCallNotification(member, call.ChannelID) {
+			continue
+		}
+
+		msg, err := p.newMissedCallPushNotification(channel, call.PostID, call.ThreadID, caller, member.Id, members, config)
+		if err != nil {
+			p.LogError("failed to create push notification", "userID", member.Id, "err", err.Error())
+			continue
+		}
+		if appErr := p.API.SendPushNotification(msg, member.Id); appErr != nil {
+			p.LogError("failed to send push notification", "userID", member.Id, "err", appErr.Error())
+		}
+	}
+}
+
+// consumeCallBack returns the pending call back the given user requested in
+// the given channel, removing it so it's only applied once. It's meant to be
+// called when the user starts a call.
+func (p *Plugin) consumeCallBack(channelID, userID string) (*callBack, error) {
+	var cb callBack
+	if ok, err := p.kvGetJSON(callBackKey(channelID, userID), &cb); err != nil {
+		return nil, err
+	} else if !ok || cb.UserID != userID {
+		return nil, nil
+	}
+
+	if appErr := p.API.KVDelete(callBackKey(channelID, userID)); appErr != nil {
+		return nil, fmt.Errorf("failed to delete call back: %w", appErr)
+	}
+
+	return &cb, nil
+}
+
+// callBackMissedCall validates that the given post is a missed call the user
+// can call back on and records it for that user, so that the next call they
+// start in the channel within callBackExpirySeconds only rings the original
+// caller.
+func (p *Plugin) callBackMissedCall(userID, channelID, postID string) (*callBack, error) {
+	post, appErr := p.API.GetPost(postID)
+	if appErr != nil && appErr.StatusCode == http.StatusNotFound {
+		return nil, errCallBackInvalidPost
+	} else if appErr != nil {
+		return nil, fmt.Errorf("failed to get post: %w", appErr)
+	}
+
+	if post.ChannelId != channelID || post.Type != callStartPostType || post.DeleteAt > 0 {
+		return nil, errCallBackInvalidPost
+	}
+	if missed, _ := post.GetProp("missed").(bool); !missed {
+		return nil, errCallBackInvalidPost
+	}
+	if post.UserId == userID {
+		return nil, ErrNotAllowed
+	}
+
+	state, err := p.getCallState(channelID, false)
+	if err != nil {
+		return nil, err
+	}
+	if state != nil {
+		return nil, errCallBackOngoingCall
+	}
+
+	cb := &callBack{
+		UserID:   userID,
+		CallerID: post.UserId,
+		PostID:   postID,
+		CreateAt: time.Now().UnixMilli(),
+	}
+	if err := p.kvSetJSON(callBackKey(channelID, userID), cb, callBackExpirySeconds); err != nil {
+		return nil, err
+	}
+
+	return cb, nil
+}
+
+func (p *Plugin) handleCallBack(w http.ResponseWriter, r *http.Request) {
+	var res httpResponse
+	defer p.httpAudit("handleCallBack", &res, w, r)
+
+	userID := r.Header.Get("Mattermost-User-Id")
+	channelID := mux.Vars(r)["channel_id"]
+
+	if !p.API.HasPermissionToChannel(userID, channelID, model.PermissionCreatePost) {
+		res.Err = "Forbidden"
+		res.Code = http.StatusForbidden
+		return
+	}
+
+	var payload struct {
+		PostID string `json:"post_id"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, requestBodyMaxSizeBytes)).Decode(&payload); err != nil {
+		res.Err = err.Error()
+		res.Code = http.StatusBadRequest
+		return
+	}
+	if !model.IsValidId(payload.PostID) {
+		res.Err = "invalid post id"
+		res.Code = http.StatusBadRequest
+		return
+	}
+
+	cb, err := p.callBackMissedCall(userID, channelID, payload.PostID)
+	if err != nil {
+		res.Err = err.Error()
+		switch {
+		case errors.Is(err, errCallBackInvalidPost):
+			res.Code = http.StatusBadRequest
+		case errors.Is(err, ErrNotAllowed):
+			res.Code = http.StatusForbidden
+		case errors.Is(err, errCallBackOngoingCall):
+			res.Code = http.StatusConflict
+		default:
+			res.Code = http.StatusInternalServerError
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"channel_id": channelID,
+		"caller_id":  cb.CallerID,
+	}); err != nil {
+		p.LogError(err.Error())
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	pluginMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost/server/public/plugin"
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsMissedCall(t *testing.T) {
+	ownerID := model.NewId()
+	botID := model.NewId()
+
+	call := &public.Call{
+		OwnerID: ownerID,
+	}
+
+	t.Run("no participants", func(t *testing.T) {
+		require.True(t, isMissedCall(call, botID))
+	})
+
+	t.Run("owner and bot only", func(t *testing.T) {
+		call.Props.Participants = map[string]struct{}{
+			ownerID: {},
+			botID:   {},
+		}
+		require.True(t, isMissedCall(call, botID))
+	})
+
+	t.Run("answered", func(t *testing.T) {
+		call.Props.Participants = map[string]struct{}{
+			ownerID:       {},
+			model.NewId(): {},
+		}
+		require.False(t, isMissedCall(call, botID))
+	})
+}
+
+func TestCallBackMissedCall(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+	}
+
+	userID := model.NewId()
+	callerID := model.NewId()
+	channelID := model.NewId()
+	postID := model.NewId()
+
+	newPost := func(missed bool) *model.Post {
+		post := &model.Post{
+			Id:        postID,
+			UserId:    callerID,
+			ChannelId: channelID,
+			Type:      callStartPostType,
+		}
+		if missed {
+			post.AddProp("missed", true)
+		}
+		return post
+	}
+
+	t.Run("post not found", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+		mockAPI.On("GetPost", postID).Return(nil, model.NewAppError("GetPost", "", nil, "", http.StatusNotFound)).Once()
+		_, err := p.callBackMissedCall(userID, channelID, postID)
+		require.ErrorIs(t, err, errCallBackInvalidPost)
+	})
+
+	t.Run("not missed", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+		mockAPI.On("GetPost", postID).Return(newPost(false), nil).Once()
+		_, err := p.callBackMissedCall(userID, channelID, postID)
+		require.ErrorIs(t, err, errCallBackInvalidPost)
+	})
+
+	t.Run("different channel", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+		mockAPI.On("GetPost", postID).Return(newPost(true), nil).Once()
+		_, err := p.callBackMissedCall(userID, model.NewId(), postID)
+		require.ErrorIs(t, err, errCallBackInvalidPost)
+	})
+
+	t.Run("caller", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+		mockAPI.On("GetPost", postID).Return(newPost(true), nil).Once()
+		_, err := p.callBackMissedCall(callerID, channelID, postID)
+		require.ErrorIs(t, err, ErrNotAllowed)
+	})
+}
+
+func TestConsumeCallBack(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+	}
+
+	channelID := model.NewId()
+	cb := callBack{
+		UserID:   model.NewId(),
+		CallerID: model.NewId(),
+		PostID:   model.NewId(),
+	}
+	data, err := json.Marshal(cb)
+	require.NoError(t, err)
+
+	t.Run("none", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+		mockAPI.On("KVGet", callBackKey(channelID, cb.UserID)).Return(nil, nil).Once()
+		got, err := p.consumeCallBack(channelID, cb.UserID)
+		require.NoError(t, err)
+		require.Nil(t, got)
+	})
+
+	t.Run("call started by someone else", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+		otherUserID := model.NewId()
+		mockAPI.On("KVGet", callBackKey(channelID, otherUserID)).Return(nil, nil).Once()
+		got, err := p.consumeCallBack(channelID, otherUserID)
+		require.NoError(t, err)
+		require.Nil(t, got)
+	})
+
+	t.Run("consumed", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+		mockAPI.On("KVGet", callBackKey(channelID, cb.UserID)).Return(data, nil).Once()
+		mockAPI.On("KVDelete", callBackKey(channelID, cb.UserID)).Return(nil).Once()
+		got, err := p.consumeCallBack(channelID, cb.UserID)
+		require.NoError(t, err)
+		require.Equal(t, &cb, got)
+	})
+}
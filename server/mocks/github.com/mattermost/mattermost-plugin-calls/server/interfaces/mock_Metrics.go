@@ -500,6 +500,40 @@ func (_c *MockMetrics_ObserveClusterMutexLockedTime_Call) RunAndReturn(run func(
 	return _c
 }
 
+// ObserveJobServiceRequestTime provides a mock function with given fields: op, elapsed
+func (_m *MockMetrics) ObserveJobServiceRequestTime(op string, elapsed float64) {
+	_m.Called(op, elapsed)
+}
+
+// MockMetrics_ObserveJobServiceRequestTime_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ObserveJobServiceRequestTime'
+type MockMetrics_ObserveJobServiceRequestTime_Call struct {
+	*mock.Call
+}
+
+// ObserveJobServiceRequestTime is a helper method to define mock.On call
+//   - op string
+//   - elapsed float64
+func (_e *MockMetrics_Expecter) ObserveJobServiceRequestTime(op interface{}, elapsed interface{}) *MockMetrics_ObserveJobServiceRequestTime_Call {
+	return &MockMetrics_ObserveJobServiceRequestTime_Call{Call: _e.mock.On("ObserveJobServiceRequestTime", op, elapsed)}
+}
+
+func (_c *MockMetrics_ObserveJobServiceRequestTime_Call) Run(run func(op string, elapsed float64)) *MockMetrics_ObserveJobServiceRequestTime_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(float64))
+	})
+	return _c
+}
+
+func (_c *MockMetrics_ObserveJobServiceRequestTime_Call) Return() *MockMetrics_ObserveJobServiceRequestTime_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockMetrics_ObserveJobServiceRequestTime_Call) RunAndReturn(run func(string, float64)) *MockMetrics_ObserveJobServiceRequestTime_Call {
+	_c.Run(run)
+	return _c
+}
+
 // ObserveLiveCaptionsAudioLen provides a mock function with given fields: elapsed
 func (_m *MockMetrics) ObserveLiveCaptionsAudioLen(elapsed float64) {
 	_m.Called(elapsed)
@@ -648,6 +682,39 @@ func (_c *MockMetrics_RegisterDBMetrics_Call) RunAndReturn(run func(*sql.DB, str
 	return _c
 }
 
+// SetJobServiceAvailability provides a mock function with given fields: available
+func (_m *MockMetrics) SetJobServiceAvailability(available bool) {
+	_m.Called(available)
+}
+
+// MockMetrics_SetJobServiceAvailability_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SetJobServiceAvailability'
+type MockMetrics_SetJobServiceAvailability_Call struct {
+	*mock.Call
+}
+
+// SetJobServiceAvailability is a helper method to define mock.On call
+//   - available bool
+func (_e *MockMetrics_Expecter) SetJobServiceAvailability(available interface{}) *MockMetrics_SetJobServiceAvailability_Call {
+	return &MockMetrics_SetJobServiceAvailability_Call{Call: _e.mock.On("SetJobServiceAvailability", available)}
+}
+
+func (_c *MockMetrics_SetJobServiceAvailability_Call) Run(run func(available bool)) *MockMetrics_SetJobServiceAvailability_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(bool))
+	})
+	return _c
+}
+
+func (_c *MockMetrics_SetJobServiceAvailability_Call) Return() *MockMetrics_SetJobServiceAvailability_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockMetrics_SetJobServiceAvailability_Call) RunAndReturn(run func(bool)) *MockMetrics_SetJobServiceAvailability_Call {
+	_c.Run(run)
+	return _c
+}
+
 // NewMockMetrics creates a new instance of MockMetrics. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockMetrics(t interface {
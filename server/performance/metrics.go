@@ -54,6 +54,9 @@ type Metrics struct {
 
 	ClientICECandidatePairsCounter *prometheus.CounterVec
 
+	JobServiceAvailableGauge        prometheus.Gauge
+	JobServiceRequestTimeHistograms *prometheus.HistogramVec
+
 	// Historical statistics gauges
 	HistoricalDailyCallsGauge   *prometheus.GaugeVec
 	HistoricalMonthlyCallsGauge *prometheus.GaugeVec
@@ -215,6 +218,25 @@ func NewMetrics() *Metrics {
 	)
 	m.registry.MustRegister(m.ClientICECandidatePairsCounter)
 
+	m.JobServiceAvailableGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: metricsSubSystemJobs,
+		Name:      "service_available",
+		Help:      "Whether the job service is available (1) or not (0)",
+	})
+	m.registry.MustRegister(m.JobServiceAvailableGauge)
+
+	m.JobServiceRequestTimeHistograms = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: metricsSubSystemJobs,
+			Name:      "service_request_time",
+			Help:      "Time to execute job service requests",
+		},
+		[]string{"op"},
+	)
+	m.registry.MustRegister(m.JobServiceRequestTimeHistograms)
+
 	// Historical statistics gauges
 	m.HistoricalDailyCallsGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -339,6 +361,18 @@ func (m *Metrics) IncClientICECandidatePairs(p public.ClientICECandidatePairMetr
 	}).Inc()
 }
 
+func (m *Metrics) SetJobServiceAvailability(available bool) {
+	if available {
+		m.JobServiceAvailableGauge.Set(1)
+	} else {
+		m.JobServiceAvailableGauge.Set(0)
+	}
+}
+
+func (m *Metrics) ObserveJobServiceRequestTime(op string, elapsed float64) {
+	m.JobServiceRequestTimeHistograms.With(prometheus.Labels{"op": op}).Observe(elapsed)
+}
+
 // UpdateHistoricalMetrics updates the historical statistics gauges with data from the database
 func (m *Metrics) UpdateHistoricalMetrics(stats *public.CallsStats, callsByDay, callsByMonth map[string]int64) {
 	// Reset gauges before updating to remove old dates
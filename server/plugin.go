@@ -4,6 +4,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"sync"
@@ -17,6 +18,7 @@ import (
 	"github.com/mattermost/mattermost-plugin-calls/server/db"
 	"github.com/mattermost/mattermost-plugin-calls/server/enterprise"
 	"github.com/mattermost/mattermost-plugin-calls/server/interfaces"
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
 
 	rtcd "github.com/mattermost/rtcd/service"
 	"github.com/mattermost/rtcd/service/rtc"
@@ -55,6 +57,12 @@ type Plugin struct {
 	clusterEvCh chan model.PluginClusterEvent
 	sessions    map[string]*session
 
+	// events to be sent to the SIP gateway.
+	gatewayEventCh chan externalEvent
+
+	// updates to the users' in-call custom status.
+	inCallStatusCh chan inCallStatusUpdate
+
 	rtcServer       *rtc.Server
 	rtcdManager     *rtcdClientManager
 	rtcdVersionInfo rtcd.VersionInfo
@@ -66,6 +74,18 @@ type Plugin struct {
 	apiLimiters    map[string]*rate.Limiter
 	apiLimitersMut sync.RWMutex
 
+	ringLimiters    map[string]*rate.Limiter
+	ringLimitersMut sync.Mutex
+
+	// A map of address -> limiter to rate-limit unauthenticated guest requests.
+	guestLimiters    map[string]*guestLimiter
+	guestLimitersMut sync.Mutex
+
+	// A map of requestID -> pending guest events poll relayed to the node
+	// holding the guest's queue.
+	guestEventsPolls    map[string]chan []externalEvent
+	guestEventsPollsMut sync.Mutex
+
 	botSession *model.Session
 
 	// A map of callID -> *cluster.Mutex to guarantee atomicity of call state
@@ -73,12 +93,21 @@ type Plugin struct {
 	callsClusterLocks    map[string]*cluster.Mutex
 	callsClusterLocksMut sync.RWMutex
 
+	// A map of channelID -> recently read call state (see getCachedCallState).
+	callStateCache    map[string]cachedCallState
+	callStateCacheMut sync.Mutex
+
+	// A map of callID/language -> ordered worker translating live captions.
+	translationWorkers    map[string]*translationWorker
+	translationWorkersMut sync.Mutex
+
 	// Database
 	store *db.Store
 
 	// Batchers
 	addSessionsBatchers    map[string]*batching.Batcher
 	removeSessionsBatchers map[string]*batching.Batcher
+	captionsBatchers       map[string]*batching.Batcher
 
 	// Historical metrics update ticker
 	metricsUpdateTicker *time.Ticker
@@ -163,6 +192,9 @@ func (p *Plugin) handleEvent(ev model.PluginClusterEvent) error {
 				us.userID, msg.ConnID, us.channelID)
 		}
 		us = newUserSession(msg.UserID, msg.ChannelID, msg.ConnID, msg.CallID, true)
+		if sessionType, ok := msg.SessionProps["sessionType"].(string); ok {
+			us.sessionType = public.CallSessionType(sessionType)
+		}
 		p.sessions[msg.ConnID] = us
 		go p.startSession(us, msg.SenderID, msg.SessionProps)
 		return nil
@@ -207,6 +239,9 @@ func (p *Plugin) handleEvent(ev model.PluginClusterEvent) error {
 			p.LogDebug("closing leaveCh", "connID", msg.ConnID)
 			close(us.leaveCh)
 		}
+	case clusterMessageTypeTransfer:
+		p.LogDebug("transfer event", "UserID", msg.UserID, "ConnID", msg.ConnID, "NewConnID", msg.NewConnID)
+		p.leaveTransferredSession(msg.ConnID)
 	case clusterMessageTypeDisconnect:
 		p.LogDebug("disconnect event", "ChannelID", msg.ChannelID, "UserID", msg.UserID, "ConnID", msg.ConnID)
 		p.mut.RLock()
@@ -254,22 +289,9 @@ func (p *Plugin) handleEvent(ev model.PluginClusterEvent) error {
 				ev.Id, msg.UserID, msg.ConnID, msg.ChannelID)
 		}
 
-		var msgType rtc.MessageType
-		switch msg.ClientMessage.Type {
-		case clientMessageTypeMute:
-			msgType = rtc.MuteMessage
-		case clientMessageTypeUnmute:
-			msgType = rtc.UnmuteMessage
-		case clientMessageTypeScreenOn:
-			msgType = rtc.ScreenOnMessage
-		case clientMessageTypeScreenOff:
-			msgType = rtc.ScreenOffMessage
-		case clientMessageTypeVideoOn:
-			msgType = rtc.VideoOnMessage
-		case clientMessageTypeVideoOff:
-			msgType = rtc.VideoOffMessage
-		default:
-			return fmt.Errorf("unexpected client message type %q", msg.ClientMessage.Type)
+		msgType, err := getRTCUserStateMessageType(msg.ClientMessage.Type)
+		if err != nil {
+			return err
 		}
 
 		rtcMsg := rtc.Message{
@@ -281,6 +303,45 @@ func (p *Plugin) handleEvent(ev model.PluginClusterEvent) error {
 		if err := p.sendRTCMessage(rtcMsg, us.callID); err != nil {
 			return fmt.Errorf("failed to send RTC message: %w", err)
 		}
+	case clusterMessageTypeGuestEvent:
+		p.LogDebug("guest event", "ChannelID", msg.ChannelID, "ConnID", msg.ConnID, "type", msg.ClientMessage.Type)
+
+		p.mut.RLock()
+		us := p.sessions[msg.ConnID]
+		p.mut.RUnlock()
+
+		// Only the node that accepted the guest join request holds the queue.
+		if us == nil || us.guestEventCh == nil {
+			return nil
+		}
+
+		p.enqueueGuestEvent(us, externalEvent{
+			Type:      msg.ClientMessage.Type,
+			ChannelID: msg.ChannelID,
+			SessionID: msg.ConnID,
+			Data:      string(msg.ClientMessage.Data),
+		})
+	case clusterMessageTypeGuestEventsPoll:
+		p.LogDebug("guest events poll", "ChannelID", msg.ChannelID, "ConnID", msg.ConnID, "SenderID", msg.SenderID)
+
+		p.mut.RLock()
+		us := p.sessions[msg.ConnID]
+		p.mut.RUnlock()
+
+		if us == nil || us.guestEventCh == nil {
+			return nil
+		}
+
+		go p.relayGuestEvents(us, msg.SenderID, msg.RequestID)
+	case clusterMessageTypeGuestEvents:
+		p.LogDebug("guest events", "ChannelID", msg.ChannelID, "ConnID", msg.ConnID, "RequestID", msg.RequestID)
+
+		var events []externalEvent
+		if err := json.Unmarshal(msg.ClientMessage.Data, &events); err != nil {
+			return fmt.Errorf("failed to unmarshal guest events: %w", err)
+		}
+
+		p.deliverGuestEvents(msg.RequestID, events)
 	default:
 		return fmt.Errorf("unexpected event type %q", ev.Id)
 	}
@@ -355,7 +416,7 @@ func (p *Plugin) createCallStartedPost(state *callState, userID, channelID, titl
 	return createdPost.Id, threadID, nil
 }
 
-func (p *Plugin) updateCallPostEnded(postID string, participants []string) (float64, error) {
+func (p *Plugin) updateCallPostEnded(postID string, participants []string, missed bool) (float64, error) {
 	if postID == "" {
 		return 0, fmt.Errorf("postID should not be empty")
 	}
@@ -368,6 +429,9 @@ func (p *Plugin) updateCallPostEnded(postID string, participants []string) (floa
 	T := p.getTranslationFunc("")
 
 	postMsg := T("app.call.ended_message")
+	if missed {
+		postMsg = T("app.call.missed_message")
+	}
 	msgAttachment := model.MessageAttachment{
 		Fallback: postMsg,
 		Title:    postMsg,
@@ -379,6 +443,9 @@ func (p *Plugin) updateCallPostEnded(postID string, participants []string) (floa
 	post.AddProp("attachments", []*model.MessageAttachment{&msgAttachment})
 	post.AddProp("end_at", time.Now().UnixMilli())
 	post.AddProp("participants", participants)
+	if missed {
+		post.AddProp("missed", true)
+	}
 
 	if _, appErr := p.API.UpdatePost(post); appErr != nil {
 		return 0, appErr
@@ -411,6 +478,16 @@ func (p *Plugin) MessageWillBeUpdated(c *plugin.Context, newPost, oldPost *model
 	return newPost, ""
 }
 
+// MessageHasBeenDeleted cleans up any call recording stored in S3 along with
+// the post it belongs to.
+func (p *Plugin) MessageHasBeenDeleted(_ *plugin.Context, post *model.Post) {
+	if post == nil || (post.Type != callRecordingPostType && post.Type != callStartPostType) {
+		return
+	}
+
+	p.deletePostS3Recordings(post)
+}
+
 func (p *Plugin) UserHasLeftChannel(_ *plugin.Context, cm *model.ChannelMember, _ *model.User) {
 	if cm == nil {
 		p.LogWarn("UserHasLeftChannel: unexpected nil channel member")
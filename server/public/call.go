@@ -74,6 +74,26 @@ type CallProps struct {
 	// VideoStartAt tracks when each session started video, keyed by session ID.
 	// Used to calculate accumulated video duration.
 	VideoStartAt map[string]int64 `json:"video_start_at,omitempty"`
+	// CaptionsLanguages tracks the preferred live captions language for each
+	// session that requested one, keyed by session ID.
+	CaptionsLanguages map[string]CaptionsLanguage `json:"captions_languages,omitempty"`
+	// SpotlightSessionIDs holds the sessions the host has put in the spotlight,
+	// in the order they were added.
+	SpotlightSessionIDs []string `json:"spotlight_session_ids,omitempty"`
+	// Webinar restricts unmuting, screen sharing and video to the host and
+	// presenters.
+	Webinar bool `json:"webinar,omitempty"`
+	// PresenterIDs holds the users the host has allowed to present during a
+	// webinar.
+	PresenterIDs []string `json:"presenter_ids,omitempty"`
+}
+
+type CaptionsLanguage struct {
+	// Language is the 2-letter ISO 639 set 1 code captions should be
+	// translated into.
+	Language string `json:"language"`
+	// ConnID is the current WebSocket connection ID for the session.
+	ConnID string `json:"conn_id"`
 }
 
 type CallStats struct {
@@ -0,0 +1,46 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package public
+
+import (
+	"fmt"
+)
+
+type CallCaption struct {
+	ID        string `json:"id"`
+	CallID    string `json:"call_id"`
+	SessionID string `json:"session_id"`
+	UserID    string `json:"user_id"`
+	CreateAt  int64  `json:"create_at"`
+	Text      string `json:"text"`
+	Language  string `json:"language"`
+}
+
+func (c *CallCaption) IsValid() error {
+	if c == nil {
+		return fmt.Errorf("should not be nil")
+	}
+
+	if c.ID == "" {
+		return fmt.Errorf("invalid ID: should not be empty")
+	}
+
+	if c.CallID == "" {
+		return fmt.Errorf("invalid CallID: should not be empty")
+	}
+
+	if c.SessionID == "" {
+		return fmt.Errorf("invalid SessionID: should not be empty")
+	}
+
+	if c.UserID == "" {
+		return fmt.Errorf("invalid UserID: should not be empty")
+	}
+
+	if c.CreateAt == 0 {
+		return fmt.Errorf("invalid CreateAt: should not be zero")
+	}
+
+	return nil
+}
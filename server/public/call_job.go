@@ -46,8 +46,29 @@ func (j *CallJob) IsValid() error {
 	return nil
 }
 
+type SummaryStatus string
+
+const (
+	SummaryStatusInProgress SummaryStatus = "in_progress"
+	SummaryStatusCompleted  SummaryStatus = "completed"
+	SummaryStatusFailed     SummaryStatus = "failed"
+)
+
 type CallJobProps struct {
 	JobID     string `json:"job_id,omitempty"`
 	BotConnID string `json:"bot_conn_id,omitempty"`
 	Err       string `json:"err,omitempty"`
+
+	// Transcription options, only applicable to transcribing and captioning jobs.
+	Language      string `json:"language,omitempty"`
+	TranscribeAPI string `json:"transcribe_api,omitempty"`
+	ModelSize     string `json:"model_size,omitempty"`
+
+	// Summary related props, only applicable to transcribing jobs.
+	SummaryStatus SummaryStatus `json:"summary_status,omitempty"`
+	SummaryPostID string        `json:"summary_post_id,omitempty"`
+	SummaryErr    string        `json:"summary_err,omitempty"`
+
+	// Spotlighted sessions, only applicable to recording jobs.
+	SpotlightSessionIDs []string `json:"spotlight_session_ids,omitempty"`
 }
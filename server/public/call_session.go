@@ -7,6 +7,19 @@ import (
 	"fmt"
 )
 
+type CallSessionType string
+
+const (
+	// CallSessionTypePhone is the type of sessions joined through a SIP gateway
+	// on behalf of a phone (PSTN) participant. These sessions don't belong to a
+	// Mattermost user so their UserID matches the session ID.
+	CallSessionTypePhone CallSessionType = "phone"
+	// CallSessionTypeGuest is the type of sessions joined by external guests
+	// through an invite token. These sessions don't belong to a Mattermost user
+	// so their UserID matches the session ID.
+	CallSessionTypeGuest CallSessionType = "guest"
+)
+
 type CallSession struct {
 	ID         string `json:"id"`
 	CallID     string `json:"call_id"`
@@ -15,6 +28,26 @@ type CallSession struct {
 	Unmuted    bool   `json:"unmuted"`
 	RaisedHand int64  `json:"raised_hand"`
 	Video      bool   `json:"video"`
+	// The type of session. Empty for regular (user) sessions.
+	Type CallSessionType `json:"type,omitempty"`
+	// The masked caller ID for phone sessions.
+	CallerID string `json:"caller_id,omitempty"`
+	// The name guests chose to be displayed with.
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+func (s *CallSession) IsPhone() bool {
+	return s.Type == CallSessionTypePhone
+}
+
+func (s *CallSession) IsGuest() bool {
+	return s.Type == CallSessionTypeGuest
+}
+
+// IsExternal returns whether the session doesn't belong to a Mattermost user
+// (e.g. phone participants and guests).
+func (s *CallSession) IsExternal() bool {
+	return s.Type != ""
 }
 
 func (s *CallSession) IsValid() error {
@@ -38,5 +71,9 @@ func (s *CallSession) IsValid() error {
 		return fmt.Errorf("invalid JoinAt: should not be zero")
 	}
 
+	if s.Type != "" && s.Type != CallSessionTypePhone && s.Type != CallSessionTypeGuest {
+		return fmt.Errorf("invalid Type: %q", s.Type)
+	}
+
 	return nil
 }
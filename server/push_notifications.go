@@ -29,15 +29,16 @@ func (p *Plugin) NotificationWillBePushed(notification *model.PushNotification,
 		p.LogError("store.GetActiveCallByChannelID failed", "err", err.Error())
 	}
 
+	if notification.PostType != callStartPostType {
+		return nil, ""
+	}
+
 	// We will use our own notifications if:
 	// 1. This is a call start post
 	// 2. We have enabled ringing
 	// 3. The channel is a DM or GM
-	if notification.PostType != callStartPostType || !*p.getConfiguration().EnableRinging {
-		return nil, ""
-	}
-
-	if notification.ChannelType == model.ChannelTypeDirect || notification.ChannelType == model.ChannelTypeGroup {
+	if *p.getConfiguration().EnableRinging &&
+		(notification.ChannelType == model.ChannelTypeDirect || notification.ChannelType == model.ChannelTypeGroup) {
 		return nil, "calls plugin will handle this notification"
 	}
 
@@ -47,6 +48,14 @@ func (p *Plugin) NotificationWillBePushed(notification *model.PushNotification,
 		return nil, ""
 	}
 
+	if !p.userAllowsCallNotification(receiver, notification.ChannelId) {
+		return nil, "calls: notification suppressed by user preferences"
+	}
+
+	if !*p.getConfiguration().EnableRinging {
+		return nil, ""
+	}
+
 	// If it's a regular channel, then the user must have notifications set to all.
 	// In that case, make the notification nicer.
 	if notification.IsIdLoaded {
@@ -87,49 +96,35 @@ func (p *Plugin) sendPushNotifications(channelID, createdPostID, threadID string
 		return
 	}
 
+	// When calling back after a missed call only the original caller is rung.
+	cb, err := p.consumeCallBack(channelID, sender.Id)
+	if err != nil {
+		p.LogError("failed to get call back", "error", err.Error())
+	}
+
 	for _, member := range members {
 		if member.Id == sender.Id {
 			continue
 		}
-
-		receiver, appErr := p.API.GetUser(member.Id)
-		if appErr != nil {
-			p.LogError("failed to get receiver user", "error", appErr.Error())
+		if cb != nil && member.Id != cb.CallerID {
 			continue
 		}
 
-		msg := &model.PushNotification{
-			Version:     model.PushMessageV2,
-			Type:        model.PushTypeMessage,
-			SubType:     model.PushSubTypeCalls,
-			Transport:   model.PushTransportVoIP,
-			TeamId:      channel.TeamId,
-			ChannelId:   channelID,
-			PostId:      createdPostID,
-			RootId:      threadID,
-			SenderId:    sender.Id,
-			ChannelType: channel.Type,
-			Message:     buildGenericPushNotificationMessage(receiver.Locale),
-		}
-
-		// This is ugly because it's a little complicated. We need to special case IdLoaded notifications (don't expose
-		// any details of the push notification on the wire). Otherwise, we can send more information, unless the server
-		// has set GenericNoChannel.
-		if *config.EmailSettings.PushNotificationContents == model.IdLoadedNotification {
-			msg.IsIdLoaded = p.checkLicenseForIDLoaded()
+		// Users who don't want to get rung may still want to know about the
+		// call, in which case they get a regular notification instead.
+		var msg *model.PushNotification
+		if p.userAllowsRinging(member, channel) {
+			msg, err = p.newCallPushNotification(channel, createdPostID, threadID, sender, member.Id, members, config)
+		} else if p.userAllowsCallNotification(member, channelID) {
+			p.LogDebug("sending regular notification as per user preferences", "userID", member.Id, "channelID", channelID)
+			msg, err = p.newNonRingingCallPushNotification(channel, createdPostID, threadID, sender, member.Id, members, config)
 		} else {
-			nameFormat := p.getNotificationNameFormat(member.Id)
-			channelName := getChannelNameForNotification(channel, sender, members, nameFormat, member.Id)
-			senderName := sender.GetDisplayName(nameFormat)
-			msg.SenderName = senderName
-			msg.ChannelName = channelName
-
-			if *config.EmailSettings.PushNotificationContents == model.GenericNoChannelNotification && channel.Type != model.ChannelTypeDirect {
-				msg.ChannelName = ""
-			}
-			if *config.EmailSettings.PushNotificationContents == model.FullNotification {
-				msg.Message = buildPushNotificationMessage(senderName, receiver.Locale)
-			}
+			p.LogDebug("skipping notification as per user preferences", "userID", member.Id, "channelID", channelID)
+			continue
+		}
+		if err != nil {
+			p.LogError("failed to create push notification", "error", err.Error())
+			continue
 		}
 
 		if err := p.API.SendPushNotification(msg, member.Id); err != nil {
@@ -138,6 +133,96 @@ func (p *Plugin) sendPushNotifications(channelID, createdPostID, threadID string
 	}
 }
 
+// newCallPushNotification builds the notification used to ring the given
+// receiver. Members are only needed to name GM channels.
+func (p *Plugin) newCallPushNotification(channel *model.Channel, postID, threadID string, sender *model.User, receiverID string, members []*model.User, config *model.Config) (*model.PushNotification, error) {
+	receiver, appErr := p.API.GetUser(receiverID)
+	if appErr != nil {
+		return nil, fmt.Errorf("failed to get receiver user: %w", appErr)
+	}
+
+	msg := &model.PushNotification{
+		Version:     model.PushMessageV2,
+		Type:        model.PushTypeMessage,
+		SubType:     model.PushSubTypeCalls,
+		Transport:   model.PushTransportVoIP,
+		TeamId:      channel.TeamId,
+		ChannelId:   channel.Id,
+		PostId:      postID,
+		RootId:      threadID,
+		SenderId:    sender.Id,
+		ChannelType: channel.Type,
+		Message:     buildGenericPushNotificationMessage(receiver.Locale),
+	}
+
+	// This is ugly because it's a little complicated. We need to special case IdLoaded notifications (don't expose
+	// any details of the push notification on the wire). Otherwise, we can send more information, unless the server
+	// has set GenericNoChannel.
+	if *config.EmailSettings.PushNotificationContents == model.IdLoadedNotification {
+		msg.IsIdLoaded = p.checkLicenseForIDLoaded()
+	} else {
+		nameFormat := p.getNotificationNameFormat(receiverID)
+		channelName := getChannelNameForNotification(channel, sender, members, nameFormat, receiverID)
+		senderName := sender.GetDisplayName(nameFormat)
+		msg.SenderName = senderName
+		msg.ChannelName = channelName
+
+		if *config.EmailSettings.PushNotificationContents == model.GenericNoChannelNotification && channel.Type != model.ChannelTypeDirect {
+			msg.ChannelName = ""
+		}
+		if *config.EmailSettings.PushNotificationContents == model.FullNotification {
+			msg.Message = buildPushNotificationMessage(senderName, receiver.Locale)
+		}
+	}
+
+	return msg, nil
+}
+
+// newNonRingingCallPushNotification builds the notification used to let the
+// receiver know a call has started without ringing them.
+func (p *Plugin) newNonRingingCallPushNotification(channel *model.Channel, postID, threadID string, sender *model.User, receiverID string, members []*model.User, config *model.Config) (*model.PushNotification, error) {
+	msg, err := p.newCallPushNotification(channel, postID, threadID, sender, receiverID, members, config)
+	if err != nil {
+		return nil, err
+	}
+
+	msg.SubType = ""
+	msg.Transport = ""
+
+	return msg, nil
+}
+
+// newMissedCallPushNotification builds the notification used to let the
+// receiver know they missed a call. Contrary to ringing, it's a regular
+// message notification.
+func (p *Plugin) newMissedCallPushNotification(channel *model.Channel, postID, threadID string, caller *model.User, receiverID string, members []*model.User, config *model.Config) (*model.PushNotification, error) {
+	msg, err := p.newCallPushNotification(channel, postID, threadID, caller, receiverID, members, config)
+	if err != nil {
+		return nil, err
+	}
+
+	msg.SubType = ""
+	msg.Transport = ""
+
+	if msg.IsIdLoaded {
+		return msg, nil
+	}
+
+	locale := ""
+	if receiver, appErr := p.API.GetUser(receiverID); appErr == nil {
+		locale = receiver.Locale
+	}
+	T := i18n.GetUserTranslations(locale)
+
+	if *config.EmailSettings.PushNotificationContents == model.FullNotification {
+		msg.Message = fmt.Sprintf("\u200b%s", T("app.push_notification.missed_call_message", map[string]any{"SenderName": msg.SenderName}))
+	} else {
+		msg.Message = T("app.push_notification.generic_missed_call_message")
+	}
+
+	return msg, nil
+}
+
 func (p *Plugin) checkLicenseForIDLoaded() bool {
 	licence := p.API.GetLicense()
 	if licence == nil || licence.Features == nil || licence.Features.IDLoadedPushNotifications == nil {
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -122,6 +123,9 @@ func TestNotificationWillBePushed(t *testing.T) {
 			require.NoError(t, err)
 			require.False(t, *p.getConfiguration().EnableRinging)
 
+			mockAPI.On("GetUser", "userID").Return(&model.User{Id: "userID"}, nil).Once()
+			mockAPI.On("KVGet", callPreferencesKey("userID")).Return(nil, nil).Once()
+
 			res, msg := p.NotificationWillBePushed(&model.PushNotification{
 				PostType: callStartPostType,
 			}, "userID")
@@ -152,9 +156,11 @@ func TestNotificationWillBePushed(t *testing.T) {
 
 		t.Run("regular channel", func(t *testing.T) {
 			mockAPI.On("GetUser", "receiverID").Return(&model.User{
+				Id:        "receiverID",
 				FirstName: "Firstname",
 				LastName:  "Lastname",
 			}, nil).Twice()
+			mockAPI.On("KVGet", callPreferencesKey("receiverID")).Return(nil, nil).Twice()
 
 			var serverConfig model.Config
 			serverConfig.SetDefaults()
@@ -199,3 +205,71 @@ func TestNotificationWillBePushed(t *testing.T) {
 		})
 	})
 }
+
+func TestSendPushNotifications(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+	}
+
+	config := &model.Config{}
+	config.SetDefaults()
+	config.EmailSettings.SendPushNotifications = model.NewPointer(true)
+	config.EmailSettings.PushNotificationServer = model.NewPointer("https://push.example.com")
+	config.EmailSettings.PushNotificationContents = model.NewPointer(model.GenericNotification)
+	config.PrivacySettings.ShowFullName = model.NewPointer(false)
+
+	sender := &model.User{Id: model.NewId(), Username: "sender"}
+	ringing := &model.User{Id: model.NewId(), Username: "ringing"}
+	nonRinging := &model.User{Id: model.NewId(), Username: "nonringing"}
+	muted := &model.User{Id: model.NewId(), Username: "muted"}
+	channel := &model.Channel{Id: model.NewId(), Type: model.ChannelTypeGroup}
+
+	setPrefs := func(userID string, prefs *callPreferences) {
+		data, err := json.Marshal(prefs)
+		require.NoError(t, err)
+		mockAPI.On("KVGet", callPreferencesKey(userID)).Return(data, nil)
+	}
+	setPrefs(ringing.Id, newCallPreferences())
+	setPrefs(nonRinging.Id, &callPreferences{Ring: false})
+	setPrefs(muted.Id, &callPreferences{Ring: false, MutedChannels: []string{channel.Id}})
+
+	mockAPI.On("GetLicense").Return(nil)
+	mockAPI.On("GetConfig").Return(config)
+	mockAPI.On("GetChannel", channel.Id).Return(channel, nil)
+	mockAPI.On("GetUsersInChannel", channel.Id, model.ChannelSortByUsername, 0, 8).
+		Return([]*model.User{sender, ringing, nonRinging, muted}, nil)
+	mockAPI.On("KVGet", callBackKey(channel.Id, sender.Id)).Return(nil, nil)
+	for _, user := range []*model.User{ringing, nonRinging, muted} {
+		mockAPI.On("GetUser", user.Id).Return(user, nil)
+	}
+	mockAPI.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything)
+
+	sent := map[string]*model.PushNotification{}
+	mockAPI.On("SendPushNotification", mock.AnythingOfType("*model.PushNotification"), mock.AnythingOfType("string")).
+		Run(func(args mock.Arguments) {
+			sent[args.String(1)] = args.Get(0).(*model.PushNotification)
+		}).Return(nil)
+
+	p.sendPushNotifications(channel.Id, "postID", "threadID", sender, config)
+
+	require.Len(t, sent, 2)
+
+	// Users allowing ringing get rung.
+	require.NotNil(t, sent[ringing.Id])
+	require.Equal(t, model.PushSubTypeCalls, sent[ringing.Id].SubType)
+	require.Equal(t, model.PushTransportVoIP, sent[ringing.Id].Transport)
+
+	// Users not allowing ringing get a regular notification.
+	require.NotNil(t, sent[nonRinging.Id])
+	require.Empty(t, sent[nonRinging.Id].SubType)
+	require.Empty(t, sent[nonRinging.Id].Transport)
+	require.Equal(t, "postID", sent[nonRinging.Id].PostId)
+	require.Equal(t, channel.Id, sent[nonRinging.Id].ChannelId)
+
+	// Users muting the channel aren't notified at all.
+	require.NotContains(t, sent, muted.Id)
+}
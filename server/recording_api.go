@@ -5,8 +5,11 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/mattermost/mattermost-plugin-calls/server/public"
@@ -73,7 +76,7 @@ func (p *Plugin) recJobTimeoutChecker(callID, jobID string) {
 	}
 }
 
-func (p *Plugin) startRecordingJob(state *callState, callID, userID string) (rst *JobStateClient, rcode int, rerr error) {
+func (p *Plugin) startRecordingJob(state *callState, callID, userID string, trOpts TranscriptionOptions) (rst *JobStateClient, rcode int, rerr error) {
 	if state.Recording != nil && state.Recording.EndAt == 0 {
 		return nil, http.StatusForbidden, fmt.Errorf("recording already in progress")
 	}
@@ -84,6 +87,7 @@ func (p *Plugin) startRecordingJob(state *callState, callID, userID string) (rst
 	recState.Type = public.JobTypeRecording
 	recState.CreatorID = userID
 	recState.InitAt = time.Now().UnixMilli()
+	recState.Props.SpotlightSessionIDs = slices.Clone(state.Call.Props.SpotlightSessionIDs)
 
 	if err := p.store.CreateCallJob(recState); err != nil {
 		return nil, http.StatusInternalServerError, fmt.Errorf("failed to create call job: %w", err)
@@ -120,7 +124,7 @@ func (p *Plugin) startRecordingJob(state *callState, callID, userID string) (rst
 	// We don't want to keep the lock while making the API call to the service since it
 	// could take a while to return. We lock again as soon as this returns.
 	p.unlockCall(callID)
-	recJobID, jobErr := p.getJobService().RunJob(job.TypeRecording, callID, state.Call.PostID, recState.ID, p.botSession.Token)
+	recJobID, jobErr := p.getJobService().RunJob(job.TypeRecording, callID, state.Call.PostID, recState.ID, p.botSession.Token, nil)
 	state, err := p.lockCallReturnState(callID)
 	if err != nil {
 		return nil, http.StatusInternalServerError, fmt.Errorf("failed to lock call: %w", err)
@@ -155,7 +159,7 @@ func (p *Plugin) startRecordingJob(state *callState, callID, userID string) (rst
 	if cfg := p.getConfiguration(); cfg.transcriptionsEnabled() {
 		trID = model.NewId()
 		p.LogDebug("transcriptions enabled, starting job", "callID", callID)
-		if err := p.startTranscribingJob(state, callID, userID, trID); err != nil {
+		if err := p.startTranscribingJob(state, callID, userID, trID, trOpts); err != nil {
 			p.LogError("failed to start transcribing job", "callID", callID, "err", err.Error())
 			return nil, http.StatusInternalServerError, fmt.Errorf("failed to start transcribing job: %w", err)
 		}
@@ -262,6 +266,33 @@ func (p *Plugin) handleRecordingAction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Failing fast rather than having the client wait for the job to time out.
+	if action == "start" && !p.getJobService().Available() {
+		res.Err = errJobServiceUnavailable.Error()
+		res.Code = http.StatusServiceUnavailable
+		return
+	}
+
+	// Transcription options can optionally be passed when starting a recording.
+	var trOpts TranscriptionOptions
+	if action == "start" {
+		var req struct {
+			Transcription TranscriptionOptions `json:"transcription"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, requestBodyMaxSizeBytes)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			res.Err = "failed to decode request body: " + err.Error()
+			res.Code = http.StatusBadRequest
+			return
+		}
+
+		var err error
+		if trOpts, err = p.resolveTranscriptionOptions(callID, req.Transcription); err != nil {
+			res.Err = err.Error()
+			res.Code = http.StatusBadRequest
+			return
+		}
+	}
+
 	state, err := p.lockCallReturnState(callID)
 	if err != nil {
 		res.Err = fmt.Errorf("failed to lock call: %w", err).Error()
@@ -285,7 +316,7 @@ func (p *Plugin) handleRecordingAction(w http.ResponseWriter, r *http.Request) {
 	var recState *JobStateClient
 	switch action {
 	case "start":
-		recState, code, err = p.startRecordingJob(state, callID, userID)
+		recState, code, err = p.startRecordingJob(state, callID, userID, trOpts)
 	case "stop":
 		recState, code, err = p.stopRecordingJob(state, callID)
 	default:
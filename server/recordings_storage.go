@@ -0,0 +1,533 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	s3UploadKeyPrefix    = "s3_upload_"
+	s3RecordingKeyPrefix = "s3_recording_"
+	// Uploads that aren't completed within this time are considered abandoned.
+	s3UploadExpirySeconds = 24 * 60 * 60
+	// S3 requires all parts of a multipart upload, except for the last one, to
+	// be at least 5MB in size. Data received in smaller chunks is buffered in a
+	// temporary object until enough has been accumulated.
+	s3UploadPartSize    = 16 * 1024 * 1024
+	s3ObjectKeyPrefix   = "recordings"
+	s3PendingDataSuffix = ".pending"
+	// How often recordings past the retention period are looked for.
+	s3RetentionInterval = time.Hour
+)
+
+var errS3UploadSizeExceeded = errors.New("data exceeds the expected file size")
+
+// s3Upload tracks the state of a recording file being uploaded to S3. Since
+// uploads happen through multiple requests, possibly handled by different
+// nodes, the state is persisted in the KV store.
+type s3Upload struct {
+	ID         string `json:"id"`
+	ChannelID  string `json:"channel_id"`
+	Filename   string `json:"filename"`
+	Key        string `json:"key"`
+	UploadID   string `json:"upload_id"`
+	CreateAt   int64  `json:"create_at"`
+	FileSize   int64  `json:"file_size"`
+	FileOffset int64  `json:"file_offset"`
+	// The amount of data received but not yet uploaded as a part.
+	PendingSize int64    `json:"pending_size"`
+	Parts       []s3Part `json:"parts"`
+}
+
+func (u *s3Upload) pendingKey() string {
+	return u.Key + s3PendingDataSuffix
+}
+
+func (u *s3Upload) toUploadSession(userID string) *model.UploadSession {
+	return &model.UploadSession{
+		Id:         u.ID,
+		Type:       model.UploadTypeAttachment,
+		CreateAt:   u.CreateAt,
+		UserId:     userID,
+		ChannelId:  u.ChannelID,
+		Filename:   u.Filename,
+		FileSize:   u.FileSize,
+		FileOffset: u.FileOffset,
+	}
+}
+
+// s3Recording holds the information about a recording file stored in S3.
+type s3Recording struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	Key       string `json:"key"`
+	Name      string `json:"name"`
+	MimeType  string `json:"mime_type"`
+	Size      int64  `json:"size"`
+	CreateAt  int64  `json:"create_at"`
+}
+
+func (r *s3Recording) toPostProp() map[string]any {
+	return map[string]any{
+		"id":        r.ID,
+		"name":      r.Name,
+		"mime_type": r.MimeType,
+		"size":      r.Size,
+	}
+}
+
+// isRecordingFile returns whether the given file should be stored in S3.
+// Only recordings are, as any other file uploaded by jobs (e.g.
+// transcriptions) is small enough to be kept in the Mattermost file storage.
+func isRecordingFile(filename string) bool {
+	mimeType := mime.TypeByExtension(filepath.Ext(filename))
+	return strings.HasPrefix(mimeType, "video/") || strings.HasPrefix(mimeType, "audio/")
+}
+
+func (p *Plugin) newRecordingsS3Client() (*s3Client, error) {
+	cfg := p.getConfiguration()
+	if !cfg.recordingsS3StorageEnabled() {
+		return nil, fmt.Errorf("recordings S3 storage is not enabled")
+	}
+
+	return newS3Client(cfg.RecordingsS3Endpoint, cfg.RecordingsS3Bucket, cfg.RecordingsS3Region,
+		cfg.RecordingsS3AccessKeyID, cfg.RecordingsS3SecretAccessKey)
+}
+
+func (p *Plugin) kvGetJSON(key string, v any) (bool, error) {
+	data, appErr := p.API.KVGet(key)
+	if appErr != nil {
+		return false, fmt.Errorf("failed to get %q: %w", key, appErr)
+	}
+	if data == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to unmarshal %q: %w", key, err)
+	}
+	return true, nil
+}
+
+func (p *Plugin) kvSetJSON(key string, v any, expirySeconds int64) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %q: %w", key, err)
+	}
+	if appErr := p.API.KVSetWithExpiry(key, data, expirySeconds); appErr != nil {
+		return fmt.Errorf("failed to set %q: %w", key, appErr)
+	}
+	return nil
+}
+
+// getS3Upload returns the S3 upload with the given ID, or nil if not found.
+func (p *Plugin) getS3Upload(id string) (*s3Upload, error) {
+	var u s3Upload
+	if ok, err := p.kvGetJSON(s3UploadKeyPrefix+id, &u); err != nil || !ok {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// getS3Recording returns the S3 recording with the given ID, or nil if not found.
+func (p *Plugin) getS3Recording(id string) (*s3Recording, error) {
+	var rec s3Recording
+	if ok, err := p.kvGetJSON(s3RecordingKeyPrefix+id, &rec); err != nil || !ok {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// createS3Upload starts uploading a new recording file to S3.
+func (p *Plugin) createS3Upload(us *model.UploadSession) (*s3Upload, error) {
+	if us.FileSize <= 0 {
+		return nil, fmt.Errorf("invalid file size")
+	}
+
+	client, err := p.newRecordingsS3Client()
+	if err != nil {
+		return nil, err
+	}
+
+	u := &s3Upload{
+		ID:        model.NewId(),
+		ChannelID: us.ChannelId,
+		Filename:  filepath.Base(us.Filename),
+		CreateAt:  model.GetMillis(),
+		FileSize:  us.FileSize,
+	}
+	u.Key = fmt.Sprintf("%s/%s/%s/%s", s3ObjectKeyPrefix, u.ChannelID, u.ID, u.Filename)
+
+	u.UploadID, err = client.CreateMultipartUpload(context.Background(), u.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+
+	if err := p.kvSetJSON(s3UploadKeyPrefix+u.ID, u, s3UploadExpirySeconds); err != nil {
+		if err := client.AbortMultipartUpload(context.Background(), u.Key, u.UploadID); err != nil {
+			p.LogError("failed to abort multipart upload", "key", u.Key, "err", err.Error())
+		}
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// uploadS3Data uploads the data read from r to S3. The returned file info is
+// nil until the upload is complete.
+func (p *Plugin) uploadS3Data(u *s3Upload, r io.Reader) (*model.FileInfo, error) {
+	client, err := p.newRecordingsS3Client()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+
+	buf := make([]byte, 0, s3UploadPartSize)
+	if u.PendingSize > 0 {
+		data, err := client.GetObject(ctx, u.pendingKey())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pending data: %w", err)
+		}
+		if int64(len(data)) != u.PendingSize {
+			return nil, fmt.Errorf("unexpected pending data size %d", len(data))
+		}
+		buf = append(buf, data...)
+	}
+
+	// The file offset at which the buffered data starts.
+	offset := u.FileOffset - int64(len(buf))
+
+	for {
+		n, err := io.ReadFull(r, buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if offset+int64(len(buf)) > u.FileSize {
+			return nil, errS3UploadSizeExceeded
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read data: %w", err)
+		}
+
+		part, err := client.UploadPart(ctx, u.Key, u.UploadID, len(u.Parts)+1, buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload part: %w", err)
+		}
+		offset += int64(len(buf))
+		buf = buf[:0]
+
+		// Saving progress after every part so that nothing is lost if the
+		// request fails midway.
+		u.Parts = append(u.Parts, part)
+		u.FileOffset = offset
+		u.PendingSize = 0
+		if err := p.kvSetJSON(s3UploadKeyPrefix+u.ID, u, s3UploadExpirySeconds); err != nil {
+			return nil, err
+		}
+	}
+
+	u.FileOffset = offset + int64(len(buf))
+	if u.FileOffset < u.FileSize {
+		// Upload is incomplete. Any leftover data is stored until there's enough
+		// to upload a part.
+		if len(buf) > 0 {
+			if err := client.PutObject(ctx, u.pendingKey(), buf); err != nil {
+				return nil, fmt.Errorf("failed to store pending data: %w", err)
+			}
+		}
+		u.PendingSize = int64(len(buf))
+		return nil, p.kvSetJSON(s3UploadKeyPrefix+u.ID, u, s3UploadExpirySeconds)
+	}
+
+	if len(buf) > 0 {
+		part, err := client.UploadPart(ctx, u.Key, u.UploadID, len(u.Parts)+1, buf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload part: %w", err)
+		}
+		u.Parts = append(u.Parts, part)
+	}
+
+	if err := client.CompleteMultipartUpload(ctx, u.Key, u.UploadID, u.Parts); err != nil {
+		return nil, fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+
+	if err := client.DeleteObject(ctx, u.pendingKey()); err != nil {
+		p.LogWarn("failed to delete pending data", "key", u.pendingKey(), "err", err.Error())
+	}
+
+	rec := &s3Recording{
+		ID:        u.ID,
+		ChannelID: u.ChannelID,
+		Key:       u.Key,
+		Name:      u.Filename,
+		MimeType:  mime.TypeByExtension(filepath.Ext(u.Filename)),
+		Size:      u.FileSize,
+		CreateAt:  model.GetMillis(),
+	}
+	if err := p.kvSetJSON(s3RecordingKeyPrefix+rec.ID, rec, 0); err != nil {
+		return nil, err
+	}
+
+	if appErr := p.API.KVDelete(s3UploadKeyPrefix + u.ID); appErr != nil {
+		p.LogWarn("failed to delete upload state", "uploadID", u.ID, "err", appErr.Error())
+	}
+
+	return &model.FileInfo{
+		Id:        rec.ID,
+		CreatorId: p.getBotID(),
+		ChannelId: rec.ChannelID,
+		CreateAt:  rec.CreateAt,
+		UpdateAt:  rec.CreateAt,
+		Name:      rec.Name,
+		Extension: strings.TrimPrefix(filepath.Ext(rec.Name), "."),
+		Size:      rec.Size,
+		MimeType:  rec.MimeType,
+	}, nil
+}
+
+// handleGetRecordingLink returns a time-limited link to download a recording
+// stored in S3.
+func (p *Plugin) handleGetRecordingLink(w http.ResponseWriter, r *http.Request) {
+	var res httpResponse
+	defer p.httpResponseHandler(&res, w)
+
+	userID := r.Header.Get("Mattermost-User-Id")
+	channelID := mux.Vars(r)["call_id"]
+	fileID := mux.Vars(r)["file_id"]
+
+	if !p.API.HasPermissionToChannel(userID, channelID, model.PermissionReadChannel) {
+		res.Err = "Forbidden"
+		res.Code = http.StatusForbidden
+		return
+	}
+
+	client, err := p.newRecordingsS3Client()
+	if err != nil {
+		res.Err = err.Error()
+		res.Code = http.StatusBadRequest
+		return
+	}
+
+	rec, err := p.getS3Recording(fileID)
+	if err != nil {
+		res.Err = err.Error()
+		res.Code = http.StatusInternalServerError
+		return
+	}
+	if rec == nil || rec.ChannelID != channelID {
+		res.Err = "recording not found"
+		res.Code = http.StatusNotFound
+		return
+	}
+
+	expiry := time.Duration(*p.getConfiguration().RecordingsS3SignedURLExpiryMinutes) * time.Minute
+	signedURL, err := client.PresignGetObject(r.Context(), rec.Key, rec.Name, rec.MimeType, expiry)
+	if err != nil {
+		res.Err = "failed to sign recording link: " + err.Error()
+		res.Code = http.StatusInternalServerError
+		return
+	}
+	link := map[string]any{
+		"url":        signedURL,
+		"expires_at": time.Now().Add(expiry).UnixMilli(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(link); err != nil {
+		p.LogError(err.Error())
+	}
+}
+
+// deleteS3Recording deletes the recording file with the given ID from S3,
+// along with its record.
+func (p *Plugin) deleteS3Recording(client *s3Client, id string) error {
+	rec, err := p.getS3Recording(id)
+	if err != nil {
+		return err
+	}
+	if rec == nil {
+		return nil
+	}
+
+	if err := client.DeleteObject(context.Background(), rec.Key); err != nil {
+		return fmt.Errorf("failed to delete object: %w", err)
+	}
+
+	if appErr := p.API.KVDelete(s3RecordingKeyPrefix + id); appErr != nil {
+		return fmt.Errorf("failed to delete recording: %w", appErr)
+	}
+
+	p.LogDebug("deleted S3 recording", "id", id, "key", rec.Key)
+
+	return nil
+}
+
+// getPostS3RecordingIDs returns the IDs of the recording files stored in S3
+// that belong to the given post, being either a recording post or the call
+// post that holds the metadata of all the call's recordings.
+func getPostS3RecordingIDs(post *model.Post) []string {
+	switch post.Type {
+	case callRecordingPostType:
+		if file, ok := post.GetProp("recording_file").(map[string]any); ok {
+			if id, _ := file["id"].(string); id != "" {
+				return []string{id}
+			}
+		}
+	case callStartPostType:
+		recordings, _ := post.GetProp("recordings").(map[string]any)
+		ids := make([]string, 0, len(recordings))
+		for _, data := range recordings {
+			var rm jobMetadata
+			rm.fromMap(data)
+			if rm.FileID != "" {
+				ids = append(ids, rm.FileID)
+			}
+		}
+		return ids
+	}
+
+	return nil
+}
+
+// deletePostS3Recordings deletes the recording files stored in S3 that belong
+// to the given post, which is being deleted.
+func (p *Plugin) deletePostS3Recordings(post *model.Post) {
+	ids := getPostS3RecordingIDs(post)
+	if len(ids) == 0 {
+		return
+	}
+
+	client, err := p.newRecordingsS3Client()
+	if err != nil {
+		p.LogWarn("cannot delete S3 recordings", "postID", post.Id, "err", err.Error())
+		return
+	}
+
+	for _, id := range ids {
+		if err := p.deleteS3Recording(client, id); err != nil {
+			p.LogError("failed to delete S3 recording", "postID", post.Id, "id", id, "err", err.Error())
+		}
+	}
+}
+
+// s3RecordingIDFromKey returns the ID of the recording (or upload) that the
+// given object belongs to.
+func s3RecordingIDFromKey(key string) string {
+	// Keys have the form recordings/<channelID>/<recordingID>/<filename>.
+	parts := strings.Split(key, "/")
+	if len(parts) != 4 || parts[0] != s3ObjectKeyPrefix {
+		return ""
+	}
+	return parts[2]
+}
+
+// deleteExpiredS3Recordings deletes the recording files (and any leftover
+// upload data) that are older than the configured retention period.
+func (p *Plugin) deleteExpiredS3Recordings() error {
+	cfg := p.getConfiguration()
+	if !cfg.recordingsS3StorageEnabled() || *cfg.RecordingsS3RetentionDays <= 0 {
+		return nil
+	}
+
+	client, err := p.newRecordingsS3Client()
+	if err != nil {
+		return err
+	}
+
+	objects, err := client.ListObjects(context.Background(), s3ObjectKeyPrefix+"/")
+	if err != nil {
+		return fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -*cfg.RecordingsS3RetentionDays)
+	for _, obj := range objects {
+		if !obj.LastModified.Before(cutoff) {
+			continue
+		}
+
+		if err := client.DeleteObject(context.Background(), obj.Key); err != nil {
+			p.LogError("failed to delete expired S3 object", "key", obj.Key, "err", err.Error())
+			continue
+		}
+
+		if id := s3RecordingIDFromKey(obj.Key); id != "" && !strings.HasSuffix(obj.Key, s3PendingDataSuffix) {
+			if appErr := p.API.KVDelete(s3RecordingKeyPrefix + id); appErr != nil {
+				p.LogError("failed to delete expired S3 recording", "id", id, "err", appErr.Error())
+			}
+		}
+
+		p.LogDebug("deleted expired S3 object", "key", obj.Key)
+	}
+
+	return nil
+}
+
+// abortAbandonedS3Uploads aborts the multipart uploads that weren't completed
+// in time, as their parts would otherwise be kept forever.
+func (p *Plugin) abortAbandonedS3Uploads() error {
+	if !p.getConfiguration().recordingsS3StorageEnabled() {
+		return nil
+	}
+
+	client, err := p.newRecordingsS3Client()
+	if err != nil {
+		return err
+	}
+
+	uploads, err := client.ListMultipartUploads(context.Background(), s3ObjectKeyPrefix+"/")
+	if err != nil {
+		return fmt.Errorf("failed to list multipart uploads: %w", err)
+	}
+
+	cutoff := time.Now().Add(-s3UploadExpirySeconds * time.Second)
+	for _, upload := range uploads {
+		if !upload.Initiated.Before(cutoff) {
+			continue
+		}
+
+		if err := client.AbortMultipartUpload(context.Background(), upload.Key, upload.UploadID); err != nil {
+			p.LogError("failed to abort abandoned multipart upload", "key", upload.Key, "err", err.Error())
+			continue
+		}
+
+		p.LogDebug("aborted abandoned multipart upload", "key", upload.Key)
+	}
+
+	return nil
+}
+
+// runS3RecordingsRetentionJob periodically deletes recording files that
+// outlived the configured retention period, along with abandoned uploads.
+func (p *Plugin) runS3RecordingsRetentionJob() {
+	ticker := time.NewTicker(s3RetentionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.deleteExpiredS3Recordings(); err != nil {
+				p.LogError("failed to delete expired S3 recordings", "err", err.Error())
+			}
+			if err := p.abortAbandonedS3Uploads(); err != nil {
+				p.LogError("failed to abort abandoned S3 uploads", "err", err.Error())
+			}
+		case <-p.stopCh:
+			return
+		}
+	}
+}
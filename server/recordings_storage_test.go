@@ -0,0 +1,363 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	serverMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost-plugin-calls/server/interfaces"
+	pluginMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"golang.org/x/time/rate"
+)
+
+func TestIsRecordingFile(t *testing.T) {
+	require.True(t, isRecordingFile("Call_recording.mp4"))
+	require.True(t, isRecordingFile("Call_recording.webm"))
+	require.False(t, isRecordingFile("Call_transcription.vtt"))
+	require.False(t, isRecordingFile("Call_transcription.txt"))
+	require.False(t, isRecordingFile("noext"))
+}
+
+func setupS3StorageTest(t *testing.T) (*Plugin, *pluginMocks.MockAPI, *fakeS3Server, map[string][]byte) {
+	t.Helper()
+
+	mockAPI := &pluginMocks.MockAPI{}
+	fs, srv := newFakeS3Server(t)
+
+	var cfg configuration
+	cfg.SetDefaults()
+	cfg.EnableRecordings = model.NewPointer(true)
+	cfg.EnableRecordingsS3Storage = model.NewPointer(true)
+	cfg.RecordingsS3Endpoint = srv.URL
+	cfg.RecordingsS3Bucket = "bucket"
+	cfg.RecordingsS3AccessKeyID = "accessKeyID"
+	cfg.RecordingsS3SecretAccessKey = "secretAccessKey"
+
+	p := &Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		configuration: &cfg,
+		botSession: &model.Session{
+			UserId: model.NewId(),
+		},
+		apiLimiters: map[string]*rate.Limiter{},
+	}
+
+	// Simple in-memory KV store.
+	kv := map[string][]byte{}
+	mockAPI.On("KVGet", mock.AnythingOfType("string")).Return(func(key string) []byte {
+		return kv[key]
+	}, nil).Maybe()
+	mockAPI.On("KVSetWithExpiry", mock.AnythingOfType("string"), mock.Anything, mock.AnythingOfType("int64")).Run(func(args mock.Arguments) {
+		kv[args.String(0)] = args.Get(1).([]byte)
+	}).Return(nil).Maybe()
+	mockAPI.On("KVDelete", mock.AnythingOfType("string")).Run(func(args mock.Arguments) {
+		delete(kv, args.String(0))
+	}).Return(nil).Maybe()
+
+	return p, mockAPI, fs, kv
+}
+
+func TestS3RecordingUpload(t *testing.T) {
+	t.Run("multiple requests", func(t *testing.T) {
+		p, _, fs, kv := setupS3StorageTest(t)
+
+		channelID := model.NewId()
+		data := bytes.Repeat([]byte("a"), s3UploadPartSize+10)
+
+		u, err := p.createS3Upload(&model.UploadSession{
+			ChannelId: channelID,
+			Filename:  "Call_recording.mp4",
+			FileSize:  int64(len(data)),
+		})
+		require.NoError(t, err)
+		require.Equal(t, "recordings/"+channelID+"/"+u.ID+"/Call_recording.mp4", u.Key)
+		require.Contains(t, kv, s3UploadKeyPrefix+u.ID)
+
+		// Less than a part worth of data is kept pending.
+		fi, err := p.uploadS3Data(u, bytes.NewReader(data[:10]))
+		require.NoError(t, err)
+		require.Nil(t, fi)
+		require.Equal(t, int64(10), u.FileOffset)
+		require.Equal(t, int64(10), u.PendingSize)
+		require.Empty(t, u.Parts)
+		require.Equal(t, data[:10], fs.objects["/bucket/"+u.pendingKey()])
+
+		// The upload state should be retrieved from the store.
+		u, err = p.getS3Upload(u.ID)
+		require.NoError(t, err)
+		require.NotNil(t, u)
+		require.Equal(t, int64(10), u.FileOffset)
+
+		fi, err = p.uploadS3Data(u, bytes.NewReader(data[10:]))
+		require.NoError(t, err)
+		require.NotNil(t, fi)
+		require.Equal(t, u.ID, fi.Id)
+		require.Equal(t, channelID, fi.ChannelId)
+		require.Equal(t, "Call_recording.mp4", fi.Name)
+		require.Equal(t, "video/mp4", fi.MimeType)
+		require.Equal(t, int64(len(data)), fi.Size)
+
+		require.Len(t, u.Parts, 2)
+		require.Equal(t, data, fs.objects["/bucket/"+u.Key])
+		require.NotContains(t, fs.objects, "/bucket/"+u.pendingKey())
+		require.NotContains(t, kv, s3UploadKeyPrefix+u.ID)
+
+		rec, err := p.getS3Recording(u.ID)
+		require.NoError(t, err)
+		require.Equal(t, &s3Recording{
+			ID:        u.ID,
+			ChannelID: channelID,
+			Key:       u.Key,
+			Name:      "Call_recording.mp4",
+			MimeType:  "video/mp4",
+			Size:      int64(len(data)),
+			CreateAt:  rec.CreateAt,
+		}, rec)
+	})
+
+	t.Run("size exceeded", func(t *testing.T) {
+		p, _, _, _ := setupS3StorageTest(t)
+
+		u, err := p.createS3Upload(&model.UploadSession{
+			ChannelId: model.NewId(),
+			Filename:  "Call_recording.mp4",
+			FileSize:  10,
+		})
+		require.NoError(t, err)
+
+		fi, err := p.uploadS3Data(u, bytes.NewReader(make([]byte, 11)))
+		require.ErrorIs(t, err, errS3UploadSizeExceeded)
+		require.Nil(t, fi)
+	})
+
+	t.Run("invalid file size", func(t *testing.T) {
+		p, _, _, _ := setupS3StorageTest(t)
+
+		u, err := p.createS3Upload(&model.UploadSession{
+			ChannelId: model.NewId(),
+			Filename:  "Call_recording.mp4",
+		})
+		require.EqualError(t, err, "invalid file size")
+		require.Nil(t, u)
+	})
+}
+
+func TestHandleGetRecordingLink(t *testing.T) {
+	p, mockAPI, _, kv := setupS3StorageTest(t)
+	mockMetrics := &serverMocks.MockMetrics{}
+	p.metrics = mockMetrics
+
+	mockMetrics.On("ObserveAppHandlersTime", mock.AnythingOfType("string"), mock.AnythingOfType("float64"))
+	mockMetrics.On("Handler").Return(nil).Once()
+
+	apiRouter := p.newAPIRouter()
+
+	channelID := model.NewId()
+	userID := model.NewId()
+	rec := &s3Recording{
+		ID:        model.NewId(),
+		ChannelID: channelID,
+		Key:       "recordings/" + channelID + "/Call_recording.mp4",
+		Name:      "Call_recording.mp4",
+		MimeType:  "video/mp4",
+	}
+	data, err := json.Marshal(rec)
+	require.NoError(t, err)
+	kv[s3RecordingKeyPrefix+rec.ID] = data
+
+	getLink := func(userID, channelID, fileID string) *http.Response {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/calls/"+channelID+"/recordings/"+fileID+"/link", nil)
+		r.Header.Set("Mattermost-User-Id", userID)
+		apiRouter.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	t.Run("no permission", func(t *testing.T) {
+		mockAPI.On("HasPermissionToChannel", userID, channelID, model.PermissionReadChannel).Return(false).Once()
+
+		resp := getLink(userID, channelID, rec.ID)
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockAPI.On("HasPermissionToChannel", userID, channelID, model.PermissionReadChannel).Return(true).Once()
+
+		resp := getLink(userID, channelID, model.NewId())
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("different channel", func(t *testing.T) {
+		otherChannelID := model.NewId()
+		mockAPI.On("HasPermissionToChannel", userID, otherChannelID, model.PermissionReadChannel).Return(true).Once()
+
+		resp := getLink(userID, otherChannelID, rec.ID)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		mockAPI.On("HasPermissionToChannel", userID, channelID, model.PermissionReadChannel).Return(true).Once()
+
+		resp := getLink(userID, channelID, rec.ID)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var link struct {
+			URL       string `json:"url"`
+			ExpiresAt int64  `json:"expires_at"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&link))
+		require.NotZero(t, link.ExpiresAt)
+
+		u, err := url.Parse(link.URL)
+		require.NoError(t, err)
+		require.Equal(t, "/bucket/"+rec.Key, u.Path)
+		require.Equal(t, "3600", u.Query().Get("X-Amz-Expires"))
+		require.NotEmpty(t, u.Query().Get("X-Amz-Signature"))
+	})
+
+	mockAPI.AssertExpectations(t)
+}
+
+func TestGetPostS3RecordingIDs(t *testing.T) {
+	t.Run("recording post", func(t *testing.T) {
+		post := &model.Post{Type: callRecordingPostType}
+		post.AddProp("recording_file", map[string]any{"id": "fileID"})
+		require.Equal(t, []string{"fileID"}, getPostS3RecordingIDs(post))
+	})
+
+	t.Run("call post", func(t *testing.T) {
+		post := &model.Post{Type: callStartPostType}
+		post.AddProp("recordings", map[string]any{
+			"recA": map[string]any{"file_id": "fileA"},
+			"recB": map[string]any{"post_id": "postB"},
+		})
+		require.Equal(t, []string{"fileA"}, getPostS3RecordingIDs(post))
+	})
+
+	t.Run("other post", func(t *testing.T) {
+		post := &model.Post{Type: model.PostTypeDefault}
+		post.AddProp("recording_file", map[string]any{"id": "fileID"})
+		require.Empty(t, getPostS3RecordingIDs(post))
+	})
+}
+
+func TestS3RecordingsDeletion(t *testing.T) {
+	storeRecording := func(t *testing.T, p *Plugin, kv map[string][]byte) *s3Recording {
+		t.Helper()
+
+		channelID := model.NewId()
+		rec := &s3Recording{
+			ID:        model.NewId(),
+			ChannelID: channelID,
+			Name:      "Call_recording.mp4",
+			MimeType:  "video/mp4",
+		}
+		rec.Key = "recordings/" + channelID + "/" + rec.ID + "/" + rec.Name
+
+		client, err := p.newRecordingsS3Client()
+		require.NoError(t, err)
+		require.NoError(t, client.PutObject(context.Background(), rec.Key, []byte("data")))
+
+		data, err := json.Marshal(rec)
+		require.NoError(t, err)
+		kv[s3RecordingKeyPrefix+rec.ID] = data
+
+		return rec
+	}
+
+	t.Run("post deleted", func(t *testing.T) {
+		p, mockAPI, fs, kv := setupS3StorageTest(t)
+		rec := storeRecording(t, p, kv)
+
+		mockAPI.On("LogDebug", "deleted S3 recording", "origin", mock.AnythingOfType("string"),
+			"id", rec.ID, "key", rec.Key).Once()
+
+		post := &model.Post{Id: model.NewId(), Type: callRecordingPostType}
+		post.AddProp("recording_file", rec.toPostProp())
+		p.MessageHasBeenDeleted(nil, post)
+
+		require.NotContains(t, fs.objects, "/bucket/"+rec.Key)
+		require.NotContains(t, kv, s3RecordingKeyPrefix+rec.ID)
+		mockAPI.AssertExpectations(t)
+	})
+
+	t.Run("unrelated post deleted", func(t *testing.T) {
+		p, _, fs, kv := setupS3StorageTest(t)
+		rec := storeRecording(t, p, kv)
+
+		p.MessageHasBeenDeleted(nil, &model.Post{Id: model.NewId(), Type: model.PostTypeDefault})
+
+		require.Contains(t, fs.objects, "/bucket/"+rec.Key)
+		require.Contains(t, kv, s3RecordingKeyPrefix+rec.ID)
+	})
+
+	t.Run("retention disabled", func(t *testing.T) {
+		p, _, fs, kv := setupS3StorageTest(t)
+		rec := storeRecording(t, p, kv)
+		fs.modTimes["/bucket/"+rec.Key] = time.Now().AddDate(-1, 0, 0)
+
+		require.NoError(t, p.deleteExpiredS3Recordings())
+		require.Contains(t, fs.objects, "/bucket/"+rec.Key)
+		require.Contains(t, kv, s3RecordingKeyPrefix+rec.ID)
+	})
+
+	t.Run("retention", func(t *testing.T) {
+		p, mockAPI, fs, kv := setupS3StorageTest(t)
+		p.configuration.RecordingsS3RetentionDays = model.NewPointer(30)
+
+		expired := storeRecording(t, p, kv)
+		fs.modTimes["/bucket/"+expired.Key] = time.Now().AddDate(0, 0, -31)
+		recent := storeRecording(t, p, kv)
+
+		mockAPI.On("LogDebug", "deleted expired S3 object", "origin", mock.AnythingOfType("string"),
+			"key", expired.Key).Once()
+
+		require.NoError(t, p.deleteExpiredS3Recordings())
+		require.NotContains(t, fs.objects, "/bucket/"+expired.Key)
+		require.NotContains(t, kv, s3RecordingKeyPrefix+expired.ID)
+		require.Contains(t, fs.objects, "/bucket/"+recent.Key)
+		require.Contains(t, kv, s3RecordingKeyPrefix+recent.ID)
+		mockAPI.AssertExpectations(t)
+	})
+
+	t.Run("abandoned uploads", func(t *testing.T) {
+		p, mockAPI, fs, _ := setupS3StorageTest(t)
+
+		client, err := p.newRecordingsS3Client()
+		require.NoError(t, err)
+
+		abandonedID, err := client.CreateMultipartUpload(context.Background(), "recordings/channelID/abandoned/Call_recording.mp4")
+		require.NoError(t, err)
+		abandoned := fs.initiated[abandonedID]
+		abandoned.Initiated = time.Now().Add(-2 * s3UploadExpirySeconds * time.Second)
+		fs.initiated[abandonedID] = abandoned
+
+		activeID, err := client.CreateMultipartUpload(context.Background(), "recordings/channelID/active/Call_recording.mp4")
+		require.NoError(t, err)
+
+		mockAPI.On("LogDebug", "aborted abandoned multipart upload", "origin", mock.AnythingOfType("string"),
+			"key", abandoned.Key).Once()
+
+		require.NoError(t, p.abortAbandonedS3Uploads())
+		require.NotContains(t, fs.uploads, abandonedID)
+		require.Contains(t, fs.uploads, activeID)
+		mockAPI.AssertExpectations(t)
+	})
+}
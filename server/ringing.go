@@ -0,0 +1,511 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	callInviteKeyPrefix = "call_invite_"
+	// Invites are kept around a little longer than the ringing so that late
+	// responses can still be matched.
+	callInviteExpirySeconds = 5 * 60
+	callInviteMaxUsers      = 10
+)
+
+// How long invited users are rung for before the invite is considered
+// missed. This is a variable so that it can be shortened in tests.
+var callInviteRingTimeout = 30 * time.Second
+
+const (
+	callInviteResponseAccepted = "accepted"
+	callInviteResponseDeclined = "declined"
+	callInviteResponseMissed   = "missed"
+)
+
+var (
+	// Users can ring up to a handful of times in a row, then once every 10
+	// seconds.
+	callInviteRateLimit = rate.Every(10 * time.Second)
+	callInviteRateBurst = 3
+)
+
+var (
+	errCallInviteNotFound         = errors.New("invite not found")
+	errCallInviteAlreadyResponded = errors.New("invite was already responded to")
+	errCallInviteTooManyRequests  = errors.New("too many requests")
+)
+
+// callInvite tracks a user being rung to join an ongoing call. It's stored in
+// the KV store so that responses can be handled by any node.
+type callInvite struct {
+	CallID    string `json:"call_id"`
+	ChannelID string `json:"channel_id"`
+	InviterID string `json:"inviter_id"`
+	UserID    string `json:"user_id"`
+	CreateAt  int64  `json:"create_at"`
+	Response  string `json:"response,omitempty"`
+}
+
+func callInviteKey(callID, userID string) string {
+	return callInviteKeyPrefix + callID + "_" + userID
+}
+
+// getCallInvite returns the invite for the given user in the given call, along
+// with its raw value, or nil if not found.
+func (p *Plugin) getCallInvite(callID, userID string) (*callInvite, []byte, error) {
+	data, appErr := p.API.KVGet(callInviteKey(callID, userID))
+	if appErr != nil {
+		return nil, nil, fmt.Errorf("failed to get invite: %w", appErr)
+	}
+	if data == nil {
+		return nil, nil, nil
+	}
+
+	var invite callInvite
+	if err := json.Unmarshal(data, &invite); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal invite: %w", err)
+	}
+
+	return &invite, data, nil
+}
+
+// setCallInviteResponse atomically sets the response to a pending invite,
+// failing if it was responded to in the meantime.
+func (p *Plugin) setCallInviteResponse(callID, userID, response string) (*callInvite, error) {
+	invite, oldData, err := p.getCallInvite(callID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if invite == nil {
+		return nil, errCallInviteNotFound
+	}
+	if invite.Response != "" {
+		return nil, errCallInviteAlreadyResponded
+	}
+
+	invite.Response = response
+	data, err := json.Marshal(invite)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal invite: %w", err)
+	}
+
+	ok, appErr := p.API.KVSetWithOptions(callInviteKey(callID, userID), data, model.PluginKVSetOptions{
+		Atomic:          true,
+		OldValue:        oldData,
+		ExpireInSeconds: callInviteExpirySeconds,
+	})
+	if appErr != nil {
+		return nil, fmt.Errorf("failed to set invite: %w", appErr)
+	}
+	if !ok {
+		return nil, errCallInviteAlreadyResponded
+	}
+
+	return invite, nil
+}
+
+// createCallInvite stores the given invite unless there's a pending one for
+// the same user and call. Invites that were already responded to are
+// replaced, so users can be rung again.
+func (p *Plugin) createCallInvite(invite *callInvite, data []byte) (bool, error) {
+	existing, oldData, err := p.getCallInvite(invite.CallID, invite.UserID)
+	if err != nil {
+		return false, err
+	}
+	if existing != nil && existing.Response == "" {
+		return false, nil
+	}
+
+	ok, appErr := p.API.KVSetWithOptions(callInviteKey(invite.CallID, invite.UserID), data, model.PluginKVSetOptions{
+		Atomic:          true,
+		OldValue:        oldData,
+		ExpireInSeconds: callInviteExpirySeconds,
+	})
+	if appErr != nil {
+		return false, fmt.Errorf("failed to set invite: %w", appErr)
+	}
+
+	return ok, nil
+}
+
+func (p *Plugin) checkCallInviteRateLimits(userID string) error {
+	p.ringLimitersMut.Lock()
+	defer p.ringLimitersMut.Unlock()
+
+	if p.ringLimiters == nil {
+		p.ringLimiters = map[string]*rate.Limiter{}
+	}
+
+	limiter := p.ringLimiters[userID]
+	if limiter == nil {
+		limiter = rate.NewLimiter(callInviteRateLimit, callInviteRateBurst)
+		p.ringLimiters[userID] = limiter
+	}
+
+	if !limiter.Allow() {
+		return errCallInviteTooManyRequests
+	}
+
+	return nil
+}
+
+// ringUsers rings the given channel members, inviting them to join the
+// ongoing call. It returns the IDs of the users that were actually rung.
+func (p *Plugin) ringUsers(inviterID, channelID string, userIDs []string) ([]string, error) {
+	if !*p.getConfiguration().EnableRinging {
+		return nil, ErrNotAllowed
+	}
+
+	if len(userIDs) == 0 || len(userIDs) > callInviteMaxUsers {
+		return nil, fmt.Errorf("invalid number of users: should be between 1 and %d", callInviteMaxUsers)
+	}
+
+	state, err := p.getCallState(channelID, false)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, ErrNoCallOngoing
+	}
+
+	if !state.isUserIDInCall(inviterID) {
+		return nil, ErrNotInCall
+	}
+
+	channel, appErr := p.API.GetChannel(channelID)
+	if appErr != nil {
+		return nil, fmt.Errorf("failed to get channel: %w", appErr)
+	}
+
+	// Users are validated upfront so that requests which wouldn't ring anyone
+	// don't count toward the rate limit.
+	users := make([]*model.User, 0, len(userIDs))
+	seen := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		if seen[userID] || userID == inviterID || state.isUserIDInCall(userID) || !model.IsValidId(userID) {
+			continue
+		}
+		seen[userID] = true
+
+		if _, appErr := p.API.GetChannelMember(channelID, userID); appErr != nil {
+			p.LogDebug("skipping ringing of non channel member", "userID", userID, "channelID", channelID)
+			continue
+		}
+
+		user, appErr := p.API.GetUser(userID)
+		if appErr != nil {
+			p.LogError("failed to get user", "userID", userID, "err", appErr.Error())
+			continue
+		}
+		if user.IsBot || user.DeleteAt > 0 {
+			continue
+		}
+		if !p.userAllowsRinging(user, channel) {
+			p.LogDebug("skipping ringing as per user preferences", "userID", userID, "channelID", channelID)
+			continue
+		}
+
+		users = append(users, user)
+	}
+
+	if len(users) == 0 {
+		return []string{}, nil
+	}
+
+	if err := p.checkCallInviteRateLimits(inviterID); err != nil {
+		return nil, err
+	}
+
+	inviter, appErr := p.API.GetUser(inviterID)
+	if appErr != nil {
+		return nil, fmt.Errorf("failed to get inviter: %w", appErr)
+	}
+
+	// Members are only needed to name group channels in notifications.
+	var members []*model.User
+	if channel.Type == model.ChannelTypeGroup {
+		members, appErr = p.API.GetUsersInChannel(channelID, model.ChannelSortByUsername, 0, 8)
+		if appErr != nil {
+			return nil, fmt.Errorf("failed to get channel users: %w", appErr)
+		}
+	}
+
+	config := p.API.GetConfig()
+	canPush := p.canSendPushNotifications(config, p.API.GetLicense()) == nil &&
+		config.EmailSettings.SendPushNotifications != nil && *config.EmailSettings.SendPushNotifications
+
+	rung := make([]string, 0, len(users))
+	for _, user := range users {
+		userID := user.Id
+		invite := &callInvite{
+			CallID:    state.Call.ID,
+			ChannelID: channelID,
+			InviterID: inviterID,
+			UserID:    userID,
+			CreateAt:  time.Now().UnixMilli(),
+		}
+		data, err := json.Marshal(invite)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal invite: %w", err)
+		}
+
+		// Only one pending invite per user and call. This avoids ringing the
+		// same user repeatedly.
+		if ok, err := p.createCallInvite(invite, data); err != nil {
+			p.LogError("failed to create invite", "userID", userID, "err", err.Error())
+			continue
+		} else if !ok {
+			p.LogDebug("user has already been invited", "userID", userID, "callID", state.Call.ID)
+			continue
+		}
+
+		p.publishWebSocketEvent(wsEventCallInvite, map[string]interface{}{
+			"call_id":    state.Call.ID,
+			"channel_id": channelID,
+			"inviter_id": inviterID,
+		}, &WebSocketBroadcast{UserID: userID, ReliableClusterSend: true})
+
+		if canPush {
+			msg, err := p.newCallPushNotification(channel, state.Call.PostID, state.Call.ThreadID, inviter, userID, members, config)
+			if err != nil {
+				p.LogError("failed to create push notification", "userID", userID, "err", err.Error())
+			} else if appErr := p.API.SendPushNotification(msg, userID); appErr != nil {
+				p.LogError("failed to send push notification", "userID", userID, "err", appErr.Error())
+			}
+		}
+
+		go p.callInviteTimeoutHandler(invite)
+
+		rung = append(rung, userID)
+	}
+
+	return rung, nil
+}
+
+// respondToCallInvite reports the user's response to a pending invite back to
+// the inviter.
+func (p *Plugin) respondToCallInvite(userID, channelID, response string) error {
+	state, err := p.getCallState(channelID, false)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return ErrNoCallOngoing
+	}
+
+	invite, err := p.setCallInviteResponse(state.Call.ID, userID, response)
+	if err != nil {
+		return err
+	}
+
+	p.publishCallInviteResponse(invite)
+
+	return nil
+}
+
+// publishCallInviteResponse lets the inviter know about the response. The
+// invited user is notified as well so that any other client of theirs can
+// stop ringing.
+func (p *Plugin) publishCallInviteResponse(invite *callInvite) {
+	p.publishWebSocketEvent(wsEventCallInviteResponse, map[string]interface{}{
+		"call_id":    invite.CallID,
+		"channel_id": invite.ChannelID,
+		"user_id":    invite.UserID,
+		"response":   invite.Response,
+	}, &WebSocketBroadcast{UserIDs: []string{invite.InviterID, invite.UserID}, ReliableClusterSend: true})
+}
+
+// callInviteTimeoutHandler marks the invite as missed if it wasn't responded
+// to in time.
+func (p *Plugin) callInviteTimeoutHandler(invite *callInvite) {
+	select {
+	case <-time.After(callInviteRingTimeout):
+	case <-p.stopCh:
+		return
+	}
+
+	response := callInviteResponseMissed
+	// Users may join straight away without responding to the invite.
+	if state, err := p.getCallState(invite.ChannelID, false); err != nil {
+		p.LogError("failed to get call state", "channelID", invite.ChannelID, "err", err.Error())
+	} else if state != nil && state.Call.ID == invite.CallID && state.isUserIDInCall(invite.UserID) {
+		response = callInviteResponseAccepted
+	}
+
+	updated, err := p.setCallInviteResponse(invite.CallID, invite.UserID, response)
+	if errors.Is(err, errCallInviteAlreadyResponded) || errors.Is(err, errCallInviteNotFound) {
+		return
+	} else if err != nil {
+		p.LogError("failed to set invite response", "userID", invite.UserID, "callID", invite.CallID, "err", err.Error())
+		return
+	}
+
+	p.publishCallInviteResponse(updated)
+
+	if response == callInviteResponseMissed {
+		if err := p.createMissedCallInvitePost(updated); err != nil {
+			p.LogError("failed to create missed call post", "userID", invite.UserID, "callID", invite.CallID, "err", err.Error())
+		}
+	}
+}
+
+// createMissedCallInvitePost lets the invited user know they missed a call
+// through a direct message from the bot.
+func (p *Plugin) createMissedCallInvitePost(invite *callInvite) error {
+	botID := p.getBotID()
+	if botID == "" {
+		return fmt.Errorf("bot user not available")
+	}
+
+	user, appErr := p.API.GetUser(invite.UserID)
+	if appErr != nil {
+		return fmt.Errorf("failed to get user: %w", appErr)
+	}
+
+	inviter, appErr := p.API.GetUser(invite.InviterID)
+	if appErr != nil {
+		return fmt.Errorf("failed to get inviter: %w", appErr)
+	}
+
+	channel, appErr := p.API.GetChannel(invite.ChannelID)
+	if appErr != nil {
+		return fmt.Errorf("failed to get channel: %w", appErr)
+	}
+
+	// Group channels have no display name of their own so they are named
+	// after their members, the same as in push notifications.
+	var members []*model.User
+	if channel.Type == model.ChannelTypeGroup {
+		members, appErr = p.API.GetUsersInChannel(channel.Id, model.ChannelSortByUsername, 0, 8)
+		if appErr != nil {
+			return fmt.Errorf("failed to get channel users: %w", appErr)
+		}
+	}
+
+	dm, appErr := p.API.GetDirectChannel(invite.UserID, botID)
+	if appErr != nil {
+		return fmt.Errorf("failed to get direct channel: %w", appErr)
+	}
+
+	nameFormat := p.getNotificationNameFormat(invite.UserID)
+	T := p.getTranslationFunc(user.Locale)
+	msgID := "app.call.missed_call_invite_message"
+	if channel.Type == model.ChannelTypeDirect {
+		msgID = "app.call.missed_call_invite_dm_message"
+	}
+	post := &model.Post{
+		UserId:    botID,
+		ChannelId: dm.Id,
+		Message: T(msgID, map[string]any{
+			"SenderName":  inviter.GetDisplayNameWithPrefix(nameFormat, "@"),
+			"ChannelName": getChannelNameForNotification(channel, inviter, members, nameFormat, invite.UserID),
+		}),
+	}
+	post.AddProp("call_id", invite.CallID)
+	post.AddProp("channel_id", invite.ChannelID)
+
+	if _, appErr := p.API.CreatePost(post); appErr != nil {
+		return fmt.Errorf("failed to create post: %w", appErr)
+	}
+
+	return nil
+}
+
+func getCallInviteErrCode(err error) int {
+	switch {
+	case errors.Is(err, ErrNoCallOngoing), errors.Is(err, errCallInviteNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrNotAllowed), errors.Is(err, ErrNotInCall):
+		return http.StatusForbidden
+	case errors.Is(err, errCallInviteAlreadyResponded):
+		return http.StatusConflict
+	case errors.Is(err, errCallInviteTooManyRequests):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (p *Plugin) handleRingUsers(w http.ResponseWriter, r *http.Request) {
+	var res httpResponse
+	defer p.httpAudit("handleRingUsers", &res, w, r)
+
+	userID := r.Header.Get("Mattermost-User-Id")
+	channelID := mux.Vars(r)["channel_id"]
+
+	if !p.API.HasPermissionToChannel(userID, channelID, model.PermissionReadChannel) {
+		res.Err = "Forbidden"
+		res.Code = http.StatusForbidden
+		return
+	}
+
+	var payload struct {
+		UserIDs []string `json:"user_ids"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, requestBodyMaxSizeBytes)).Decode(&payload); err != nil {
+		res.Err = err.Error()
+		res.Code = http.StatusBadRequest
+		return
+	}
+	if len(payload.UserIDs) == 0 || len(payload.UserIDs) > callInviteMaxUsers {
+		res.Err = fmt.Sprintf("invalid number of users: should be between 1 and %d", callInviteMaxUsers)
+		res.Code = http.StatusBadRequest
+		return
+	}
+
+	rung, err := p.ringUsers(userID, channelID, payload.UserIDs)
+	if err != nil {
+		res.Err = err.Error()
+		res.Code = getCallInviteErrCode(err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"user_ids": rung,
+	}); err != nil {
+		p.LogError(err.Error())
+	}
+}
+
+func (p *Plugin) handleCallInviteResponse(w http.ResponseWriter, r *http.Request) {
+	var res httpResponse
+	defer p.httpAudit("handleCallInviteResponse", &res, w, r)
+
+	userID := r.Header.Get("Mattermost-User-Id")
+	channelID := mux.Vars(r)["channel_id"]
+
+	var response string
+	switch mux.Vars(r)["action"] {
+	case "accept":
+		response = callInviteResponseAccepted
+	case "decline":
+		response = callInviteResponseDeclined
+	default:
+		res.Err = "Invalid action"
+		res.Code = http.StatusBadRequest
+		return
+	}
+
+	if err := p.respondToCallInvite(userID, channelID, response); err != nil {
+		res.Err = err.Error()
+		res.Code = getCallInviteErrCode(err)
+		return
+	}
+
+	res.Code = http.StatusOK
+	res.Msg = "success"
+}
@@ -0,0 +1,265 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	serverMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost-plugin-calls/server/interfaces"
+	pluginMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckCallInviteRateLimits(t *testing.T) {
+	var p Plugin
+
+	userID := model.NewId()
+	for i := 0; i < callInviteRateBurst; i++ {
+		require.NoError(t, p.checkCallInviteRateLimits(userID))
+	}
+	require.ErrorIs(t, p.checkCallInviteRateLimits(userID), errCallInviteTooManyRequests)
+
+	// Limits are per user.
+	require.NoError(t, p.checkCallInviteRateLimits(model.NewId()))
+}
+
+func TestCallInviteStore(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+	}
+
+	invite := &callInvite{
+		CallID:    model.NewId(),
+		ChannelID: model.NewId(),
+		InviterID: model.NewId(),
+		UserID:    model.NewId(),
+		CreateAt:  model.GetMillis(),
+	}
+	key := callInviteKey(invite.CallID, invite.UserID)
+
+	pending, err := json.Marshal(invite)
+	require.NoError(t, err)
+
+	responded := *invite
+	responded.Response = callInviteResponseDeclined
+	respondedData, err := json.Marshal(responded)
+	require.NoError(t, err)
+
+	t.Run("create", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("KVGet", key).Return(nil, nil).Once()
+		mockAPI.On("KVSetWithOptions", key, pending, model.PluginKVSetOptions{
+			Atomic:          true,
+			ExpireInSeconds: callInviteExpirySeconds,
+		}).Return(true, nil).Once()
+
+		ok, err := p.createCallInvite(invite, pending)
+		require.NoError(t, err)
+		require.True(t, ok)
+	})
+
+	t.Run("create with pending invite", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("KVGet", key).Return(pending, nil).Once()
+
+		ok, err := p.createCallInvite(invite, pending)
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("create replacing responded invite", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("KVGet", key).Return(respondedData, nil).Once()
+		mockAPI.On("KVSetWithOptions", key, pending, model.PluginKVSetOptions{
+			Atomic:          true,
+			OldValue:        respondedData,
+			ExpireInSeconds: callInviteExpirySeconds,
+		}).Return(true, nil).Once()
+
+		ok, err := p.createCallInvite(invite, pending)
+		require.NoError(t, err)
+		require.True(t, ok)
+	})
+
+	t.Run("respond not found", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("KVGet", key).Return(nil, nil).Once()
+
+		_, err := p.setCallInviteResponse(invite.CallID, invite.UserID, callInviteResponseAccepted)
+		require.ErrorIs(t, err, errCallInviteNotFound)
+	})
+
+	t.Run("respond already responded", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("KVGet", key).Return(respondedData, nil).Once()
+
+		_, err := p.setCallInviteResponse(invite.CallID, invite.UserID, callInviteResponseAccepted)
+		require.ErrorIs(t, err, errCallInviteAlreadyResponded)
+	})
+
+	accepted := *invite
+	accepted.Response = callInviteResponseAccepted
+	acceptedData, err := json.Marshal(accepted)
+	require.NoError(t, err)
+
+	t.Run("respond race", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("KVGet", key).Return(pending, nil).Once()
+		mockAPI.On("KVSetWithOptions", key, acceptedData, model.PluginKVSetOptions{
+			Atomic:          true,
+			OldValue:        pending,
+			ExpireInSeconds: callInviteExpirySeconds,
+		}).Return(false, nil).Once()
+
+		_, err := p.setCallInviteResponse(invite.CallID, invite.UserID, callInviteResponseAccepted)
+		require.ErrorIs(t, err, errCallInviteAlreadyResponded)
+	})
+
+	t.Run("respond", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("KVGet", key).Return(pending, nil).Once()
+		mockAPI.On("KVSetWithOptions", key, acceptedData, model.PluginKVSetOptions{
+			Atomic:          true,
+			OldValue:        pending,
+			ExpireInSeconds: callInviteExpirySeconds,
+		}).Return(true, nil).Once()
+
+		got, err := p.setCallInviteResponse(invite.CallID, invite.UserID, callInviteResponseAccepted)
+		require.NoError(t, err)
+		require.Equal(t, &accepted, got)
+	})
+}
+
+func TestCallInviteTimeoutHandler(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	mockMetrics := &serverMocks.MockMetrics{}
+	defer mockAPI.AssertExpectations(t)
+
+	p := &Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		metrics: mockMetrics,
+		stopCh:  make(chan struct{}),
+	}
+
+	store, tearDown := NewTestStore(t)
+	t.Cleanup(tearDown)
+	p.store = store
+
+	defer func(timeout time.Duration) { callInviteRingTimeout = timeout }(callInviteRingTimeout)
+	callInviteRingTimeout = 0
+
+	mockMetrics.On("ObserveAppHandlersTime", "getCallState", mock.AnythingOfType("float64"))
+
+	invite := &callInvite{
+		CallID:    model.NewId(),
+		ChannelID: model.NewId(),
+		InviterID: model.NewId(),
+		UserID:    model.NewId(),
+		CreateAt:  model.GetMillis(),
+	}
+	key := callInviteKey(invite.CallID, invite.UserID)
+	pending, err := json.Marshal(invite)
+	require.NoError(t, err)
+
+	t.Run("failing to set response", func(t *testing.T) {
+		mockAPI.On("KVGet", key).Return(pending, nil).Once()
+		mockAPI.On("KVSetWithOptions", key, mock.Anything, mock.Anything).
+			Return(false, model.NewAppError("KVSetWithOptions", "error", nil, "", 500)).Once()
+		mockAPI.On("LogError", "failed to set invite response", "origin", mock.AnythingOfType("string"),
+			"userID", invite.UserID, "callID", invite.CallID, "err", mock.AnythingOfType("string")).Once()
+
+		require.NotPanics(t, func() {
+			p.callInviteTimeoutHandler(invite)
+		})
+	})
+}
+
+func TestCreateMissedCallInvitePost(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	p := &Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		botSession: &model.Session{
+			UserId: model.NewId(),
+		},
+	}
+
+	user := &model.User{Id: model.NewId(), Username: "user", Locale: "en"}
+	inviter := &model.User{Id: model.NewId(), Username: "inviter"}
+	other := &model.User{Id: model.NewId(), Username: "other"}
+	dm := &model.Channel{Id: model.NewId(), Type: model.ChannelTypeDirect}
+
+	cfg := &model.Config{}
+	cfg.SetDefaults()
+	cfg.PrivacySettings.ShowFullName = model.NewPointer(false)
+
+	mockAPI.On("GetUser", user.Id).Return(user, nil)
+	mockAPI.On("GetUser", inviter.Id).Return(inviter, nil)
+	mockAPI.On("GetDirectChannel", user.Id, p.botSession.UserId).Return(dm, nil)
+	mockAPI.On("GetConfig").Return(cfg)
+
+	createPost := func(t *testing.T, channel *model.Channel) *model.Post {
+		t.Helper()
+
+		mockAPI.On("GetChannel", channel.Id).Return(channel, nil).Once()
+
+		var post *model.Post
+		mockAPI.On("CreatePost", mock.AnythingOfType("*model.Post")).Run(func(args mock.Arguments) {
+			post = args.Get(0).(*model.Post)
+		}).Return(nil, nil).Once()
+
+		require.NoError(t, p.createMissedCallInvitePost(&callInvite{
+			CallID:    model.NewId(),
+			ChannelID: channel.Id,
+			InviterID: inviter.Id,
+			UserID:    user.Id,
+		}))
+		require.NotNil(t, post)
+		require.Equal(t, dm.Id, post.ChannelId)
+
+		return post
+	}
+
+	t.Run("direct channel", func(t *testing.T) {
+		post := createPost(t, &model.Channel{Id: model.NewId(), Type: model.ChannelTypeDirect, Name: "userA__userB"})
+		require.Equal(t, "app.call.missed_call_invite_dm_message", post.Message)
+	})
+
+	t.Run("group channel", func(t *testing.T) {
+		channel := &model.Channel{Id: model.NewId(), Type: model.ChannelTypeGroup, Name: model.NewId()}
+		mockAPI.On("GetUsersInChannel", channel.Id, model.ChannelSortByUsername, 0, 8).
+			Return([]*model.User{inviter, other, user}, nil).Once()
+
+		post := createPost(t, channel)
+		require.Equal(t, "app.call.missed_call_invite_message", post.Message)
+	})
+
+	t.Run("open channel", func(t *testing.T) {
+		post := createPost(t, &model.Channel{Id: model.NewId(), Type: model.ChannelTypeOpen, Name: "town-square", DisplayName: "Town Square"})
+		require.Equal(t, "app.call.missed_call_invite_message", post.Message)
+	})
+
+	mockAPI.AssertExpectations(t)
+}
@@ -669,10 +669,7 @@ func (m *rtcdClientManager) handleClientMsg(msg rtcd.ClientMessage) error {
 		return fmt.Errorf("failed to find session by originalConnID: %s", rtcMsg.SessionID)
 	}
 
-	m.ctx.publishWebSocketEvent(wsEventSignal, map[string]interface{}{
-		"data":   string(rtcMsg.Data),
-		"connID": rtcMsg.SessionID,
-	}, &WebSocketBroadcast{ConnectionID: us.connID, ReliableClusterSend: true})
+	m.ctx.relaySignal(us, rtcMsg.SessionID, rtcMsg.Data)
 
 	return nil
 }
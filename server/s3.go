@@ -0,0 +1,217 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const s3RequestTimeout = 5 * time.Minute
+
+// s3Client wraps a MinIO client with the few operations needed to store call
+// recordings. Requests are path-style so that it works with self-hosted
+// services (e.g. MinIO) out of the box.
+type s3Client struct {
+	core   *minio.Core
+	bucket string
+}
+
+type s3Part struct {
+	PartNumber int    `json:"part_number"`
+	ETag       string `json:"etag"`
+}
+
+// s3Object holds the information about an object listed from the bucket.
+type s3Object struct {
+	Key          string
+	LastModified time.Time
+}
+
+func newS3Client(endpoint, bucket, region, accessKeyID, secretAccessKey string) (*s3Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse endpoint: %w", err)
+	}
+	if u.Path != "" {
+		return nil, fmt.Errorf("endpoint should not have a path")
+	}
+
+	core, err := minio.NewCore(u.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
+		Secure:       u.Scheme == "https",
+		Region:       region,
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %w", err)
+	}
+
+	return &s3Client{
+		core:   core,
+		bucket: bucket,
+	}, nil
+}
+
+// CreateMultipartUpload starts a new multipart upload for the given key and
+// returns its ID.
+func (c *s3Client) CreateMultipartUpload(ctx context.Context, key string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+	defer cancel()
+	return c.core.NewMultipartUpload(ctx, c.bucket, key, minio.PutObjectOptions{})
+}
+
+// UploadPart uploads a single part of a multipart upload.
+func (c *s3Client) UploadPart(ctx context.Context, key, uploadID string, partNumber int, data []byte) (s3Part, error) {
+	ctx, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+	defer cancel()
+	part, err := c.core.PutObjectPart(ctx, c.bucket, key, uploadID, partNumber, bytes.NewReader(data), int64(len(data)), minio.PutObjectPartOptions{})
+	if err != nil {
+		return s3Part{}, err
+	}
+	return s3Part{PartNumber: part.PartNumber, ETag: part.ETag}, nil
+}
+
+// CompleteMultipartUpload assembles the uploaded parts into the final object.
+func (c *s3Client) CompleteMultipartUpload(ctx context.Context, key, uploadID string, parts []s3Part) error {
+	completeParts := make([]minio.CompletePart, 0, len(parts))
+	for _, part := range parts {
+		completeParts = append(completeParts, minio.CompletePart{
+			PartNumber: part.PartNumber,
+			ETag:       part.ETag,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+	defer cancel()
+	_, err := c.core.CompleteMultipartUpload(ctx, c.bucket, key, uploadID, completeParts, minio.PutObjectOptions{})
+	return err
+}
+
+// AbortMultipartUpload cancels a multipart upload, freeing any uploaded parts.
+func (c *s3Client) AbortMultipartUpload(ctx context.Context, key, uploadID string) error {
+	ctx, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+	defer cancel()
+	return c.core.AbortMultipartUpload(ctx, c.bucket, key, uploadID)
+}
+
+// PutObject uploads the given data as a single object.
+func (c *s3Client) PutObject(ctx context.Context, key string, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+	defer cancel()
+	_, err := c.core.Client.PutObject(ctx, c.bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{})
+	return err
+}
+
+// GetObject downloads the object with the given key.
+func (c *s3Client) GetObject(ctx context.Context, key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+	defer cancel()
+
+	rd, _, _, err := c.core.GetObject(ctx, c.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object: %w", err)
+	}
+
+	return data, nil
+}
+
+// DeleteObject removes the object with the given key. Removing an object that
+// doesn't exist is not an error.
+func (c *s3Client) DeleteObject(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+	defer cancel()
+	return c.core.Client.RemoveObject(ctx, c.bucket, key, minio.RemoveObjectOptions{})
+}
+
+// ListObjects returns all the objects with the given key prefix.
+func (c *s3Client) ListObjects(ctx context.Context, prefix string) ([]s3Object, error) {
+	ctx, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+	defer cancel()
+
+	var objects []s3Object
+	for obj := range c.core.Client.ListObjects(ctx, c.bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		objects = append(objects, s3Object{
+			Key:          obj.Key,
+			LastModified: obj.LastModified,
+		})
+	}
+
+	return objects, nil
+}
+
+// s3MultipartUpload holds the information about an incomplete multipart
+// upload.
+type s3MultipartUpload struct {
+	Key       string
+	UploadID  string
+	Initiated time.Time
+}
+
+// ListMultipartUploads returns all the incomplete multipart uploads with the
+// given key prefix.
+func (c *s3Client) ListMultipartUploads(ctx context.Context, prefix string) ([]s3MultipartUpload, error) {
+	ctx, cancel := context.WithTimeout(ctx, s3RequestTimeout)
+	defer cancel()
+
+	var uploads []s3MultipartUpload
+	var keyMarker, uploadIDMarker string
+	for {
+		res, err := c.core.ListMultipartUploads(ctx, c.bucket, prefix, keyMarker, uploadIDMarker, "", 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, upload := range res.Uploads {
+			uploads = append(uploads, s3MultipartUpload{
+				Key:       upload.Key,
+				UploadID:  upload.UploadID,
+				Initiated: upload.Initiated,
+			})
+		}
+		if !res.IsTruncated {
+			return uploads, nil
+		}
+		keyMarker, uploadIDMarker = res.NextKeyMarker, res.NextUploadIDMarker
+	}
+}
+
+// PresignGetObject returns a URL that grants temporary read access to the
+// object with the given key. The file will be downloaded with the given
+// filename and content type.
+func (c *s3Client) PresignGetObject(ctx context.Context, key, filename, contentType string, expiry time.Duration) (string, error) {
+	query := url.Values{}
+	if filename != "" {
+		query.Set("response-content-disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	}
+	if contentType != "" {
+		query.Set("response-content-type", contentType)
+	}
+
+	u, err := c.core.Client.PresignedGetObject(ctx, c.bucket, key, expiry, query)
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
@@ -0,0 +1,275 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewS3Client(t *testing.T) {
+	_, err := newS3Client("http://localhost:9000/", "recordings", "us-east-1", "accessKeyID", "secretAccessKey")
+	require.NoError(t, err)
+
+	_, err = newS3Client("http://localhost:9000/path", "recordings", "us-east-1", "accessKeyID", "secretAccessKey")
+	require.EqualError(t, err, "endpoint should not have a path")
+}
+
+func TestS3ClientPresignGetObject(t *testing.T) {
+	client, err := newS3Client("http://localhost:9000/", "recordings", "us-east-1", "accessKeyID", "secretAccessKey")
+	require.NoError(t, err)
+
+	link, err := client.PresignGetObject(context.Background(), "recordings/channelID/file name.mp4", "file name.mp4", "video/mp4", time.Hour)
+	require.NoError(t, err)
+
+	signed, err := url.Parse(link)
+	require.NoError(t, err)
+	require.Equal(t, "localhost:9000", signed.Host)
+	require.Equal(t, "/recordings/recordings/channelID/file name.mp4", signed.Path)
+	require.Equal(t, "/recordings/recordings/channelID/file%20name.mp4", signed.EscapedPath())
+
+	query := signed.Query()
+	require.Equal(t, `attachment; filename="file name.mp4"`, query.Get("response-content-disposition"))
+	require.Equal(t, "video/mp4", query.Get("response-content-type"))
+	require.Equal(t, "AWS4-HMAC-SHA256", query.Get("X-Amz-Algorithm"))
+	require.True(t, strings.HasPrefix(query.Get("X-Amz-Credential"), "accessKeyID/"))
+	require.Equal(t, "3600", query.Get("X-Amz-Expires"))
+	require.NotEmpty(t, query.Get("X-Amz-Signature"))
+}
+
+// fakeS3Server is a minimal in-memory implementation of the S3 API calls made
+// by s3Client.
+type fakeS3Server struct {
+	objects  map[string][]byte
+	modTimes map[string]time.Time
+	uploads  map[string]map[int][]byte
+	// initiated holds the key and start time of the incomplete multipart
+	// uploads.
+	initiated map[string]s3MultipartUpload
+}
+
+// decodeAWSChunked strips the chunk headers added by the streaming signature
+// from a request body.
+func decodeAWSChunked(t *testing.T, body []byte) []byte {
+	t.Helper()
+	var data []byte
+	for len(body) > 0 {
+		header, rest, ok := strings.Cut(string(body), "\r\n")
+		if !assert.True(t, ok) {
+			return nil
+		}
+		sizeHex, _, _ := strings.Cut(header, ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if !assert.NoError(t, err) {
+			return nil
+		}
+		if size == 0 {
+			break
+		}
+		data = append(data, rest[:size]...)
+		body = []byte(strings.TrimPrefix(rest[size:], "\r\n"))
+	}
+	return data
+}
+
+func newFakeS3Server(t *testing.T) (*fakeS3Server, *httptest.Server) {
+	fs := &fakeS3Server{
+		objects:   map[string][]byte{},
+		modTimes:  map[string]time.Time{},
+		uploads:   map[string]map[int][]byte{},
+		initiated: map[string]s3MultipartUpload{},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Handlers run outside of the test goroutine so we can't use require here.
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential="))
+		assert.NotEmpty(t, r.Header.Get("X-Amz-Date"))
+		assert.NotEmpty(t, r.Header.Get("X-Amz-Content-Sha256"))
+
+		key := r.URL.Path
+		query := r.URL.Query()
+		body, err := io.ReadAll(r.Body)
+		if !assert.NoError(t, err) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			body = decodeAWSChunked(t, body)
+		}
+
+		switch {
+		case r.Method == http.MethodPost && query.Has("uploads"):
+			uploadID := "upload" + key
+			fs.uploads[uploadID] = map[int][]byte{}
+			fs.initiated[uploadID] = s3MultipartUpload{
+				Key:       strings.TrimPrefix(key, "/bucket/"),
+				UploadID:  uploadID,
+				Initiated: time.Now(),
+			}
+			_, _ = w.Write([]byte("<InitiateMultipartUploadResult><UploadId>" + uploadID + "</UploadId></InitiateMultipartUploadResult>"))
+		case r.Method == http.MethodGet && query.Has("uploads"):
+			res := "<ListMultipartUploadsResult>"
+			for _, upload := range fs.initiated {
+				if !strings.HasPrefix(upload.Key, query.Get("prefix")) {
+					continue
+				}
+				res += "<Upload><Key>" + upload.Key + "</Key><UploadId>" + upload.UploadID +
+					"</UploadId><Initiated>" + upload.Initiated.UTC().Format(time.RFC3339) + "</Initiated></Upload>"
+			}
+			res += "</ListMultipartUploadsResult>"
+			_, _ = w.Write([]byte(res))
+		case r.Method == http.MethodGet && query.Get("list-type") == "2":
+			res := "<ListBucketResult>"
+			for objKey := range fs.objects {
+				name := strings.TrimPrefix(objKey, "/bucket/")
+				if !strings.HasPrefix(name, query.Get("prefix")) {
+					continue
+				}
+				res += "<Contents><Key>" + name + "</Key><LastModified>" +
+					fs.modTimes[objKey].UTC().Format(time.RFC3339) + "</LastModified></Contents>"
+			}
+			res += "</ListBucketResult>"
+			_, _ = w.Write([]byte(res))
+		case r.Method == http.MethodPut && query.Has("uploadId"):
+			parts, ok := fs.uploads[query.Get("uploadId")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			partNumber, err := strconv.Atoi(query.Get("partNumber"))
+			if !assert.NoError(t, err) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			parts[partNumber] = body
+			w.Header().Set("ETag", `"etag`+query.Get("partNumber")+`"`)
+		case r.Method == http.MethodPost && query.Has("uploadId"):
+			parts, ok := fs.uploads[query.Get("uploadId")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			var req struct {
+				Parts []s3Part `xml:"Part"`
+			}
+			if !assert.NoError(t, xml.Unmarshal(body, &req)) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			var data []byte
+			for i, part := range req.Parts {
+				assert.Equal(t, i+1, part.PartNumber)
+				data = append(data, parts[part.PartNumber]...)
+			}
+			fs.objects[key] = data
+			fs.modTimes[key] = time.Now()
+			delete(fs.uploads, query.Get("uploadId"))
+			delete(fs.initiated, query.Get("uploadId"))
+			_, _ = w.Write([]byte("<CompleteMultipartUploadResult><Bucket>bucket</Bucket><Key>" + key + "</Key></CompleteMultipartUploadResult>"))
+		case r.Method == http.MethodDelete && query.Has("uploadId"):
+			delete(fs.uploads, query.Get("uploadId"))
+			delete(fs.initiated, query.Get("uploadId"))
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPut:
+			fs.objects[key] = body
+			fs.modTimes[key] = time.Now()
+		case r.Method == http.MethodGet:
+			data, ok := fs.objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Last-Modified", fs.modTimes[key].UTC().Format(http.TimeFormat))
+			_, _ = w.Write(data)
+		case r.Method == http.MethodDelete:
+			delete(fs.objects, key)
+			delete(fs.modTimes, key)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return fs, srv
+}
+
+func TestS3Client(t *testing.T) {
+	fs, srv := newFakeS3Server(t)
+
+	client, err := newS3Client(srv.URL, "bucket", "us-east-1", "accessKeyID", "secretAccessKey")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	t.Run("objects", func(t *testing.T) {
+		require.NoError(t, client.PutObject(ctx, "key", []byte("data")))
+		require.Equal(t, []byte("data"), fs.objects["/bucket/key"])
+
+		data, err := client.GetObject(ctx, "key")
+		require.NoError(t, err)
+		require.Equal(t, []byte("data"), data)
+
+		require.NoError(t, client.DeleteObject(ctx, "key"))
+		require.Empty(t, fs.objects)
+
+		_, err = client.GetObject(ctx, "key")
+		require.Error(t, err)
+		require.Equal(t, http.StatusNotFound, minio.ToErrorResponse(err).StatusCode)
+	})
+
+	t.Run("multipart upload", func(t *testing.T) {
+		uploadID, err := client.CreateMultipartUpload(ctx, "recording.mp4")
+		require.NoError(t, err)
+		require.NotEmpty(t, uploadID)
+
+		part1, err := client.UploadPart(ctx, "recording.mp4", uploadID, 1, []byte("first"))
+		require.NoError(t, err)
+		require.Equal(t, s3Part{PartNumber: 1, ETag: "etag1"}, part1)
+
+		part2, err := client.UploadPart(ctx, "recording.mp4", uploadID, 2, []byte("second"))
+		require.NoError(t, err)
+
+		require.NoError(t, client.CompleteMultipartUpload(ctx, "recording.mp4", uploadID, []s3Part{part1, part2}))
+		require.Equal(t, []byte("firstsecond"), fs.objects["/bucket/recording.mp4"])
+	})
+
+	t.Run("abort multipart upload", func(t *testing.T) {
+		uploadID, err := client.CreateMultipartUpload(ctx, "aborted.mp4")
+		require.NoError(t, err)
+		require.Contains(t, fs.uploads, uploadID)
+
+		uploads, err := client.ListMultipartUploads(ctx, "abort")
+		require.NoError(t, err)
+		require.Len(t, uploads, 1)
+		require.Equal(t, "aborted.mp4", uploads[0].Key)
+		require.Equal(t, uploadID, uploads[0].UploadID)
+
+		require.NoError(t, client.AbortMultipartUpload(ctx, "aborted.mp4", uploadID))
+		require.NotContains(t, fs.uploads, uploadID)
+	})
+
+	t.Run("list objects", func(t *testing.T) {
+		require.NoError(t, client.PutObject(ctx, "recordings/a.mp4", []byte("a")))
+		require.NoError(t, client.PutObject(ctx, "other/b.mp4", []byte("b")))
+
+		objects, err := client.ListObjects(ctx, "recordings/")
+		require.NoError(t, err)
+		require.Len(t, objects, 1)
+		require.Equal(t, "recordings/a.mp4", objects[0].Key)
+		require.False(t, objects[0].LastModified.IsZero())
+	})
+}
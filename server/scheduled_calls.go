@@ -0,0 +1,542 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	scheduledCallKeyPrefix       = "scheduled_call_"
+	scheduledCallsIndexKeyPrefix = "scheduled_calls_"
+	calendarFeedKeyPrefix        = "calendar_feed_"
+	// Upper bound on the number of upcoming scheduled calls in a channel.
+	scheduledCallsMaxUpcomingPerChannel = 100
+	scheduledCallsIndexMaxRetries       = 5
+	scheduledCallTitleMaxLen            = 256
+	scheduledCallDescriptionMaxLen      = 4096
+	// Scheduled calls are kept around for a while after they end.
+	scheduledCallRetention  = 24 * time.Hour
+	scheduledCallTimeLayout = "Mon Jan 2, 2006 15:04 MST"
+	calendarFeedSecretLen   = 32
+	icsUploadMaxSizeBytes   = requestBodyMaxSizeBytes
+)
+
+var (
+	errScheduledCallExists       = errors.New("event has already been imported in this channel")
+	errScheduledCallEnded        = errors.New("event has already ended")
+	errScheduledCallsLimit       = errors.New("too many upcoming scheduled calls in channel")
+	errScheduledCallsConflict    = errors.New("too many concurrent updates")
+	errScheduledCallsTeamChannel = errors.New("scheduled calls are only supported in team channels")
+)
+
+// scheduledCall is a call announced ahead of time in a channel, currently
+// created by importing calendar invites.
+type scheduledCall struct {
+	ID          string `json:"id"`
+	ChannelID   string `json:"channel_id"`
+	TeamID      string `json:"team_id"`
+	CreatorID   string `json:"creator_id"`
+	UID         string `json:"uid"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	StartAt     int64  `json:"start_at"`
+	EndAt       int64  `json:"end_at"`
+	PostID      string `json:"post_id"`
+	CreateAt    int64  `json:"create_at"`
+}
+
+func (sc *scheduledCall) expirySeconds(now time.Time) int64 {
+	return scheduledCallExpirySeconds(sc.EndAt, now)
+}
+
+func scheduledCallExpirySeconds(endAt int64, now time.Time) int64 {
+	return max(1, (endAt-now.UnixMilli())/1000+int64(scheduledCallRetention.Seconds()))
+}
+
+// scheduledCallsIndexEntry references a scheduled call. Each channel keeps
+// its own index so that imports in different channels don't contend on the
+// same key.
+type scheduledCallsIndexEntry struct {
+	ID    string `json:"id"`
+	UID   string `json:"uid"`
+	EndAt int64  `json:"end_at"`
+}
+
+type calendarFeed struct {
+	SecretHash string `json:"secret_hash"`
+	CreateAt   int64  `json:"create_at"`
+}
+
+func hashCalendarFeedSecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+func checkCalendarFeedSecret(secret, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashCalendarFeedSecret(secret)), []byte(hash)) == 1
+}
+
+// callJoinLink returns a link that opens the channel and joins its call.
+func callJoinLink(siteURL, teamName, channelID string) string {
+	return fmt.Sprintf("%s/%s/channels/%s?join_call=true", siteURL, teamName, channelID)
+}
+
+func (p *Plugin) getSiteURL() (string, error) {
+	cfg := p.API.GetConfig()
+	if cfg == nil || cfg.ServiceSettings.SiteURL == nil || *cfg.ServiceSettings.SiteURL == "" {
+		return "", fmt.Errorf("site URL not configured")
+	}
+	return strings.TrimRight(*cfg.ServiceSettings.SiteURL, "/"), nil
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// updateScheduledCallsIndex atomically applies fn to the index of the given
+// channel. Entries for calls that have ended are pruned beforehand.
+func (p *Plugin) updateScheduledCallsIndex(channelID string, fn func([]scheduledCallsIndexEntry) ([]scheduledCallsIndexEntry, error)) error {
+	key := scheduledCallsIndexKeyPrefix + channelID
+	for range scheduledCallsIndexMaxRetries {
+		oldData, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return fmt.Errorf("failed to get scheduled calls index: %w", appErr)
+		}
+
+		var entries []scheduledCallsIndexEntry
+		if oldData != nil {
+			if err := json.Unmarshal(oldData, &entries); err != nil {
+				return fmt.Errorf("failed to unmarshal scheduled calls index: %w", err)
+			}
+		}
+
+		now := time.Now()
+		upcoming := entries[:0]
+		for _, e := range entries {
+			if e.EndAt > now.UnixMilli() {
+				upcoming = append(upcoming, e)
+			}
+		}
+
+		updated, err := fn(upcoming)
+		if err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(updated)
+		if err != nil {
+			return fmt.Errorf("failed to marshal scheduled calls index: %w", err)
+		}
+
+		// The index goes away along with the last of its scheduled calls.
+		endAt := now.UnixMilli()
+		for _, e := range updated {
+			endAt = max(endAt, e.EndAt)
+		}
+
+		ok, appErr := p.API.KVSetWithOptions(key, data, model.PluginKVSetOptions{
+			Atomic:          true,
+			OldValue:        oldData,
+			ExpireInSeconds: scheduledCallExpirySeconds(endAt, now),
+		})
+		if appErr != nil {
+			return fmt.Errorf("failed to set scheduled calls index: %w", appErr)
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	return errScheduledCallsConflict
+}
+
+// addScheduledCallsIndexEntry appends the given entry to the channel's index,
+// making sure the event wasn't already imported and the channel is below its
+// limit of upcoming calls.
+func addScheduledCallsIndexEntry(entries []scheduledCallsIndexEntry, entry scheduledCallsIndexEntry) ([]scheduledCallsIndexEntry, error) {
+	for _, e := range entries {
+		if entry.UID != "" && e.UID == entry.UID {
+			return nil, errScheduledCallExists
+		}
+	}
+	if len(entries) >= scheduledCallsMaxUpcomingPerChannel {
+		return nil, errScheduledCallsLimit
+	}
+	return append(entries, entry), nil
+}
+
+func (p *Plugin) getScheduledCallsIndex(channelID string) ([]scheduledCallsIndexEntry, error) {
+	var entries []scheduledCallsIndexEntry
+	if _, err := p.kvGetJSON(scheduledCallsIndexKeyPrefix+channelID, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (p *Plugin) getScheduledCall(id string) (*scheduledCall, error) {
+	var sc scheduledCall
+	if ok, err := p.kvGetJSON(scheduledCallKeyPrefix+id, &sc); err != nil || !ok {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+func (p *Plugin) removeScheduledCall(sc *scheduledCall) {
+	if err := p.updateScheduledCallsIndex(sc.ChannelID, func(entries []scheduledCallsIndexEntry) ([]scheduledCallsIndexEntry, error) {
+		filtered := entries[:0]
+		for _, e := range entries {
+			if e.ID != sc.ID {
+				filtered = append(filtered, e)
+			}
+		}
+		return filtered, nil
+	}); err != nil {
+		p.LogError("failed to remove scheduled call from index", "id", sc.ID, "err", err.Error())
+	}
+
+	if appErr := p.API.KVDelete(scheduledCallKeyPrefix + sc.ID); appErr != nil {
+		p.LogError("failed to delete scheduled call", "id", sc.ID, "err", appErr.Error())
+	}
+}
+
+// importScheduledCall creates a scheduled call in the given channel out of
+// a calendar invite, announcing it through a post.
+func (p *Plugin) importScheduledCall(userID, channelID string, data []byte) (*scheduledCall, error) {
+	ev, err := parseICSEvent(data)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if !ev.End.After(now) {
+		return nil, errScheduledCallEnded
+	}
+
+	channel, appErr := p.API.GetChannel(channelID)
+	if appErr != nil {
+		return nil, fmt.Errorf("failed to get channel: %w", appErr)
+	}
+	if channel.TeamId == "" {
+		return nil, errScheduledCallsTeamChannel
+	}
+
+	team, appErr := p.API.GetTeam(channel.TeamId)
+	if appErr != nil {
+		return nil, fmt.Errorf("failed to get team: %w", appErr)
+	}
+
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		return nil, fmt.Errorf("failed to get user: %w", appErr)
+	}
+
+	siteURL, err := p.getSiteURL()
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &scheduledCall{
+		ID:          model.NewId(),
+		ChannelID:   channelID,
+		TeamID:      channel.TeamId,
+		CreatorID:   userID,
+		UID:         ev.UID,
+		Title:       truncateRunes(strings.TrimSpace(ev.Summary), scheduledCallTitleMaxLen),
+		Description: truncateRunes(strings.TrimSpace(ev.Description), scheduledCallDescriptionMaxLen),
+		StartAt:     ev.Start.UnixMilli(),
+		EndAt:       ev.End.UnixMilli(),
+		CreateAt:    now.UnixMilli(),
+	}
+
+	T := p.getTranslationFunc("")
+	if sc.Title == "" {
+		sc.Title = T("app.call.scheduled_default_title")
+	}
+
+	if err := p.updateScheduledCallsIndex(channelID, func(entries []scheduledCallsIndexEntry) ([]scheduledCallsIndexEntry, error) {
+		return addScheduledCallsIndexEntry(entries, scheduledCallsIndexEntry{
+			ID:    sc.ID,
+			UID:   sc.UID,
+			EndAt: sc.EndAt,
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	post := &model.Post{
+		UserId:    userID,
+		ChannelId: channelID,
+		Message: T("app.call.scheduled_message", map[string]any{
+			"Username": user.Username,
+			"Title":    sc.Title,
+			"StartAt":  ev.Start.UTC().Format(scheduledCallTimeLayout),
+			"Link":     callJoinLink(siteURL, team.Name, channelID),
+		}),
+	}
+	post.AddProp("scheduled_call_id", sc.ID)
+	post.AddProp("scheduled_start_at", sc.StartAt)
+	post.AddProp("scheduled_end_at", sc.EndAt)
+
+	createdPost, appErr := p.API.CreatePost(post)
+	if appErr != nil {
+		p.removeScheduledCall(sc)
+		return nil, fmt.Errorf("failed to create post: %w", appErr)
+	}
+	sc.PostID = createdPost.Id
+
+	if err := p.kvSetJSON(scheduledCallKeyPrefix+sc.ID, sc, sc.expirySeconds(now)); err != nil {
+		p.removeScheduledCall(sc)
+		return nil, err
+	}
+
+	return sc, nil
+}
+
+// getUpcomingScheduledCalls returns the scheduled calls that haven't ended
+// yet in the channels the given user is a member of.
+func (p *Plugin) getUpcomingScheduledCalls(userID string) ([]*scheduledCall, error) {
+	var channelIDs []string
+	var page int
+	perPage := 200
+	for {
+		cms, appErr := p.API.GetChannelMembersForUser("", userID, page, perPage)
+		if appErr != nil {
+			return nil, fmt.Errorf("failed to get channel members: %w", appErr)
+		}
+		for _, cm := range cms {
+			channelIDs = append(channelIDs, cm.ChannelId)
+		}
+		if len(cms) < perPage {
+			break
+		}
+		page++
+	}
+
+	now := time.Now().UnixMilli()
+	var calls []*scheduledCall
+	for _, channelID := range channelIDs {
+		entries, err := p.getScheduledCallsIndex(channelID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			if e.EndAt <= now {
+				continue
+			}
+
+			sc, err := p.getScheduledCall(e.ID)
+			if err != nil {
+				return nil, err
+			}
+			if sc == nil {
+				continue
+			}
+			calls = append(calls, sc)
+		}
+	}
+
+	return calls, nil
+}
+
+// scheduledCallsToICSEvents converts the given calls to calendar events,
+// with links to join them.
+func (p *Plugin) scheduledCallsToICSEvents(calls []*scheduledCall, siteURL string) []icsEvent {
+	teamNames := map[string]string{}
+	events := make([]icsEvent, 0, len(calls))
+	for _, sc := range calls {
+		teamName, ok := teamNames[sc.TeamID]
+		if !ok {
+			team, appErr := p.API.GetTeam(sc.TeamID)
+			if appErr != nil {
+				p.LogError("failed to get team", "teamID", sc.TeamID, "err", appErr.Error())
+				continue
+			}
+			teamName = team.Name
+			teamNames[sc.TeamID] = teamName
+		}
+
+		link := callJoinLink(siteURL, teamName, sc.ChannelID)
+		description := link
+		if sc.Description != "" {
+			description = sc.Description + "\n\n" + link
+		}
+
+		events = append(events, icsEvent{
+			UID:         sc.ID,
+			Summary:     sc.Title,
+			Description: description,
+			URL:         link,
+			Start:       time.UnixMilli(sc.StartAt),
+			End:         time.UnixMilli(sc.EndAt),
+		})
+	}
+	return events
+}
+
+func (p *Plugin) handleImportScheduledCall(w http.ResponseWriter, r *http.Request) {
+	var res httpResponse
+	defer p.httpAudit("handleImportScheduledCall", &res, w, r)
+
+	userID := r.Header.Get("Mattermost-User-Id")
+	channelID := mux.Vars(r)["channel_id"]
+
+	if !p.API.HasPermissionToChannel(userID, channelID, model.PermissionCreatePost) {
+		res.Err = "Forbidden"
+		res.Code = http.StatusForbidden
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, icsUploadMaxSizeBytes)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		res.Err = fmt.Sprintf("failed to get file: %s", err.Error())
+		res.Code = http.StatusBadRequest
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		res.Err = fmt.Sprintf("failed to read file: %s", err.Error())
+		res.Code = http.StatusBadRequest
+		return
+	}
+
+	sc, err := p.importScheduledCall(userID, channelID, data)
+	if err != nil {
+		res.Err = err.Error()
+		switch {
+		case errors.Is(err, errICSInvalid), errors.Is(err, errScheduledCallEnded),
+			errors.Is(err, errScheduledCallsTeamChannel), errors.Is(err, errScheduledCallsLimit):
+			res.Code = http.StatusBadRequest
+		case errors.Is(err, errScheduledCallExists), errors.Is(err, errScheduledCallsConflict):
+			res.Code = http.StatusConflict
+		default:
+			res.Code = http.StatusInternalServerError
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(sc); err != nil {
+		p.LogError(err.Error())
+	}
+}
+
+// handleCreateCalendarFeed generates the secret URL of the user's calendar
+// feed, invalidating any previous one.
+func (p *Plugin) handleCreateCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	var res httpResponse
+	defer p.httpAudit("handleCreateCalendarFeed", &res, w, r)
+
+	userID := r.Header.Get("Mattermost-User-Id")
+
+	siteURL, err := p.getSiteURL()
+	if err != nil {
+		res.Err = err.Error()
+		res.Code = http.StatusInternalServerError
+		return
+	}
+
+	secret := model.NewRandomString(calendarFeedSecretLen)
+	if err := p.kvSetJSON(calendarFeedKeyPrefix+userID, calendarFeed{
+		SecretHash: hashCalendarFeedSecret(secret),
+		CreateAt:   time.Now().UnixMilli(),
+	}, 0); err != nil {
+		res.Err = err.Error()
+		res.Code = http.StatusInternalServerError
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"url": fmt.Sprintf("%s/plugins/%s/calendar/feed/%s/%s.ics", siteURL, manifest.Id, userID, secret),
+	}); err != nil {
+		p.LogError(err.Error())
+	}
+}
+
+func (p *Plugin) handleRevokeCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	var res httpResponse
+	defer p.httpAudit("handleRevokeCalendarFeed", &res, w, r)
+
+	userID := r.Header.Get("Mattermost-User-Id")
+
+	if appErr := p.API.KVDelete(calendarFeedKeyPrefix + userID); appErr != nil {
+		res.Err = appErr.Error()
+		res.Code = http.StatusInternalServerError
+		return
+	}
+
+	res.Code = http.StatusOK
+	res.Msg = "success"
+}
+
+// handleGetCalendarFeed serves the user's calendar feed. Calendar apps can't
+// authenticate so the feed is protected by the secret in its URL instead.
+func (p *Plugin) handleGetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	var res httpResponse
+	defer p.httpResponseHandler(&res, w)
+
+	userID := mux.Vars(r)["user_id"]
+	secret := mux.Vars(r)["secret"]
+
+	var feed calendarFeed
+	if ok, err := p.kvGetJSON(calendarFeedKeyPrefix+userID, &feed); err != nil {
+		res.Err = err.Error()
+		res.Code = http.StatusInternalServerError
+		return
+	} else if !ok || !checkCalendarFeedSecret(secret, feed.SecretHash) {
+		res.Err = "Not found"
+		res.Code = http.StatusNotFound
+		return
+	}
+
+	if user, appErr := p.API.GetUser(userID); appErr != nil || user.DeleteAt > 0 {
+		res.Err = "Not found"
+		res.Code = http.StatusNotFound
+		return
+	}
+
+	siteURL, err := p.getSiteURL()
+	if err != nil {
+		res.Err = err.Error()
+		res.Code = http.StatusInternalServerError
+		return
+	}
+
+	calls, err := p.getUpcomingScheduledCalls(userID)
+	if err != nil {
+		res.Err = err.Error()
+		res.Code = http.StatusInternalServerError
+		return
+	}
+
+	T := p.getTranslationFunc("")
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	if err := writeICSCalendar(w, T("app.call.calendar_feed_name"), p.scheduledCallsToICSEvents(calls, siteURL), time.Now()); err != nil {
+		p.LogError("failed to write calendar feed", "err", err.Error())
+	}
+}
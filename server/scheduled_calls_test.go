@@ -0,0 +1,235 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	serverMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost-plugin-calls/server/interfaces"
+	pluginMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"golang.org/x/time/rate"
+)
+
+func TestUpdateScheduledCallsIndex(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+	}
+
+	channelID := model.NewId()
+	indexKey := scheduledCallsIndexKeyPrefix + channelID
+
+	now := time.Now()
+	ended := scheduledCallsIndexEntry{ID: model.NewId(), EndAt: now.Add(-time.Minute).UnixMilli()}
+	upcoming := scheduledCallsIndexEntry{ID: model.NewId(), EndAt: now.Add(time.Hour).UnixMilli()}
+	added := scheduledCallsIndexEntry{ID: model.NewId(), EndAt: now.Add(2 * time.Hour).UnixMilli()}
+
+	oldData, err := json.Marshal([]scheduledCallsIndexEntry{ended, upcoming})
+	require.NoError(t, err)
+	newData, err := json.Marshal([]scheduledCallsIndexEntry{upcoming, added})
+	require.NoError(t, err)
+
+	add := func(entries []scheduledCallsIndexEntry) ([]scheduledCallsIndexEntry, error) {
+		return append(entries, added), nil
+	}
+
+	// The index expires along with the last of its scheduled calls.
+	setOpts := mock.MatchedBy(func(opts model.PluginKVSetOptions) bool {
+		expiry := scheduledCallExpirySeconds(added.EndAt, time.Now())
+		return opts.Atomic && bytes.Equal(opts.OldValue, oldData) &&
+			opts.ExpireInSeconds >= expiry && opts.ExpireInSeconds <= expiry+1
+	})
+
+	t.Run("prunes ended calls", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("KVGet", indexKey).Return(oldData, nil).Once()
+		mockAPI.On("KVSetWithOptions", indexKey, newData, setOpts).Return(true, nil).Once()
+
+		require.NoError(t, p.updateScheduledCallsIndex(channelID, add))
+	})
+
+	t.Run("retries on conflict", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("KVGet", indexKey).Return(oldData, nil).Times(2)
+		mockAPI.On("KVSetWithOptions", indexKey, newData, setOpts).Return(false, nil).Once()
+		mockAPI.On("KVSetWithOptions", indexKey, newData, setOpts).Return(true, nil).Once()
+
+		require.NoError(t, p.updateScheduledCallsIndex(channelID, add))
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("KVGet", indexKey).Return(oldData, nil).Times(scheduledCallsIndexMaxRetries)
+		mockAPI.On("KVSetWithOptions", indexKey, newData, mock.Anything).
+			Return(false, nil).Times(scheduledCallsIndexMaxRetries)
+
+		require.ErrorIs(t, p.updateScheduledCallsIndex(channelID, add), errScheduledCallsConflict)
+	})
+
+	t.Run("callback error", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		mockAPI.On("KVGet", indexKey).Return(oldData, nil).Once()
+
+		require.ErrorIs(t, p.updateScheduledCallsIndex(channelID, func(_ []scheduledCallsIndexEntry) ([]scheduledCallsIndexEntry, error) {
+			return nil, errScheduledCallExists
+		}), errScheduledCallExists)
+	})
+}
+
+func TestAddScheduledCallsIndexEntry(t *testing.T) {
+	entries := []scheduledCallsIndexEntry{
+		{ID: model.NewId(), UID: "uid"},
+	}
+
+	t.Run("added", func(t *testing.T) {
+		entry := scheduledCallsIndexEntry{ID: model.NewId(), UID: "other"}
+		updated, err := addScheduledCallsIndexEntry(slices.Clone(entries), entry)
+		require.NoError(t, err)
+		require.Equal(t, append(slices.Clone(entries), entry), updated)
+	})
+
+	t.Run("already imported", func(t *testing.T) {
+		entry := scheduledCallsIndexEntry{ID: model.NewId(), UID: "uid"}
+		_, err := addScheduledCallsIndexEntry(slices.Clone(entries), entry)
+		require.ErrorIs(t, err, errScheduledCallExists)
+	})
+
+	t.Run("channel limit", func(t *testing.T) {
+		full := slices.Clone(entries)
+		for range scheduledCallsMaxUpcomingPerChannel - 1 {
+			full = append(full, scheduledCallsIndexEntry{ID: model.NewId()})
+		}
+
+		_, err := addScheduledCallsIndexEntry(full, scheduledCallsIndexEntry{ID: model.NewId()})
+		require.ErrorIs(t, err, errScheduledCallsLimit)
+	})
+}
+
+func TestCalendarFeed(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	mockMetrics := &serverMocks.MockMetrics{}
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		metrics:     mockMetrics,
+		apiLimiters: map[string]*rate.Limiter{},
+	}
+
+	mockMetrics.On("Handler").Return(nil).Once()
+	apiRouter := p.newAPIRouter()
+
+	userID := model.NewId()
+	secret := model.NewRandomString(calendarFeedSecretLen)
+	feedData, err := json.Marshal(calendarFeed{SecretHash: hashCalendarFeedSecret(secret)})
+	require.NoError(t, err)
+
+	get := func(t *testing.T, secret string) *http.Response {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/calendar/feed/"+userID+"/"+secret+".ics", nil)
+		apiRouter.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	t.Run("no feed", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+		mockAPI.On("KVGet", calendarFeedKeyPrefix+userID).Return(nil, nil).Once()
+
+		resp := get(t, secret)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("invalid secret", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+		mockAPI.On("KVGet", calendarFeedKeyPrefix+userID).Return(feedData, nil).Once()
+
+		resp := get(t, model.NewRandomString(calendarFeedSecretLen))
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		channelID := model.NewId()
+		emptyChannelID := model.NewId()
+		teamID := model.NewId()
+
+		sc := scheduledCall{
+			ID:        model.NewId(),
+			ChannelID: channelID,
+			TeamID:    teamID,
+			Title:     "Team sync",
+			StartAt:   time.Now().Add(time.Hour).UnixMilli(),
+			EndAt:     time.Now().Add(2 * time.Hour).UnixMilli(),
+		}
+		scData, err := json.Marshal(sc)
+		require.NoError(t, err)
+
+		indexData, err := json.Marshal([]scheduledCallsIndexEntry{
+			{ID: sc.ID, EndAt: sc.EndAt},
+			// Already ended
+			{ID: model.NewId(), EndAt: time.Now().Add(-time.Hour).UnixMilli()},
+		})
+		require.NoError(t, err)
+
+		mockAPI.On("KVGet", calendarFeedKeyPrefix+userID).Return(feedData, nil).Once()
+		mockAPI.On("GetUser", userID).Return(&model.User{Id: userID}, nil).Once()
+		mockAPI.On("GetConfig").Return(&model.Config{
+			ServiceSettings: model.ServiceSettings{
+				SiteURL: model.NewPointer("https://mm.example.com/"),
+			},
+		}).Twice()
+		// Only the indexes of the channels the user is a member of are read.
+		mockAPI.On("GetChannelMembersForUser", "", userID, 0, 200).Return([]*model.ChannelMember{
+			{ChannelId: channelID},
+			{ChannelId: emptyChannelID},
+		}, nil).Once()
+		mockAPI.On("KVGet", scheduledCallsIndexKeyPrefix+channelID).Return(indexData, nil).Once()
+		mockAPI.On("KVGet", scheduledCallsIndexKeyPrefix+emptyChannelID).Return(nil, nil).Once()
+		mockAPI.On("KVGet", scheduledCallKeyPrefix+sc.ID).Return(scData, nil).Once()
+		mockAPI.On("GetTeam", teamID).Return(&model.Team{Id: teamID, Name: "team"}, nil).Once()
+
+		resp := get(t, secret)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "text/calendar; charset=utf-8", resp.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, 1, strings.Count(string(body), "BEGIN:VEVENT"))
+
+		ev, err := parseICSEvent(body)
+		require.NoError(t, err)
+		require.Equal(t, sc.ID, ev.UID)
+		require.Equal(t, "Team sync", ev.Summary)
+		require.Equal(t, "https://mm.example.com/team/channels/"+channelID+"?join_call=true", ev.URL)
+		require.Equal(t, time.UnixMilli(sc.StartAt).Truncate(time.Second).UTC(), ev.Start)
+	})
+}
@@ -6,6 +6,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"sync/atomic"
 	"time"
 
@@ -60,6 +61,14 @@ type session struct {
 
 	// rate limiter for incoming WebSocket messages.
 	wsMsgLimiter *rate.Limiter
+
+	// sessionType is set for external sessions (e.g. phone participants
+	// joined through the SIP gateway, guests), in which case signaling goes
+	// through their client's own channel instead of a WebSocket connection.
+	sessionType public.CallSessionType
+	// guestEventCh holds the events to be delivered to a guest client. Only set
+	// on the node that owns the session.
+	guestEventCh chan externalEvent
 }
 
 func newUserSession(userID, channelID, connID, callID string, rtc bool) *session {
@@ -80,11 +89,16 @@ func newUserSession(userID, channelID, connID, callID string, rtc bool) *session
 	}
 }
 
-func (p *Plugin) addUserSession(state *callState, callsEnabled *bool, userID, connID, channelID, jobID string, ct model.ChannelType) (retState *callState, retErr error) {
+// addUserSession adds the given session to the call in the given channel,
+// starting a new call if none is ongoing. Only the ID and UserID of the session
+// are required, along with the Type and related fields for external sessions.
+func (p *Plugin) addUserSession(state *callState, callsEnabled *bool, ust *public.CallSession, channelID, jobID string, ct model.ChannelType) (retState *callState, retErr error) {
 	defer func(start time.Time) {
 		p.metrics.ObserveAppHandlersTime("addUserSession", time.Since(start).Seconds())
 	}(time.Now())
 
+	userID, connID := ust.UserID, ust.ID
+
 	// We need to make sure to keep the state consistent in case of error since it can be shared
 	// with other operations in the same batch. To do this we make a deep copy so that we can
 	// return the original state in case of error.
@@ -154,7 +168,7 @@ func (p *Plugin) addUserSession(state *callState, callsEnabled *bool, userID, co
 		if err != nil {
 			p.LogError("joinAllowed failed", "error", err.Error())
 		}
-		return nil, fmt.Errorf("user cannot join because of limits")
+		return nil, errCallParticipantsLimit
 	}
 
 	// When the bot joins the call it means a job (recording, transcription) is
@@ -196,12 +210,9 @@ func (p *Plugin) addUserSession(state *callState, callsEnabled *bool, userID, co
 		}
 	}
 
-	state.sessions[connID] = &public.CallSession{
-		ID:     connID,
-		CallID: state.Call.ID,
-		UserID: userID,
-		JoinAt: time.Now().UnixMilli(),
-	}
+	ust.CallID = state.Call.ID
+	ust.JoinAt = time.Now().UnixMilli()
+	state.sessions[connID] = ust
 
 	if newHostID := state.getHostID(p.getBotID()); newHostID != state.Call.GetHostID() {
 		state.Call.Props.Hosts = []string{newHostID}
@@ -223,7 +234,8 @@ func (p *Plugin) addUserSession(state *callState, callsEnabled *bool, userID, co
 		state.Call.Props.Participants = map[string]struct{}{}
 	}
 
-	if userID != p.getBotID() {
+	// External sessions don't map to actual users.
+	if userID != p.getBotID() && !ust.IsExternal() {
 		state.Call.Props.Participants[userID] = struct{}{}
 	}
 
@@ -300,6 +312,14 @@ func (p *Plugin) removeUserSession(state *callState, userID, originalConnID, con
 	delete(state.sessions, originalConnID)
 	p.LogDebug("session was removed from state", "userID", userID, "connID", connID, "originalConnID", originalConnID)
 
+	if !us.IsExternal() && userID != p.getBotID() {
+		p.queueInCallStatusUpdate(inCallStatusUpdate{
+			userID:    userID,
+			channelID: channelID,
+			sessionID: originalConnID,
+		})
+	}
+
 	// Check if leaving session was screen sharing.
 	if state.Call.Props.ScreenSharingSessionID == originalConnID {
 		state.Call.Props.ScreenSharingSessionID = ""
@@ -315,6 +335,14 @@ func (p *Plugin) removeUserSession(state *callState, userID, originalConnID, con
 		})
 	}
 
+	delete(state.Call.Props.CaptionsLanguages, originalConnID)
+
+	if slices.Contains(state.Call.Props.SpotlightSessionIDs, originalConnID) {
+		p.updateSpotlight(state, slices.DeleteFunc(slices.Clone(state.Call.Props.SpotlightSessionIDs), func(id string) bool {
+			return id == originalConnID
+		}))
+	}
+
 	// Check if leaving session had video on.
 	if us.Video {
 		p.LogDebug("removed session had video on, sending video off event", "userID", userID, "connID", connID, "originalConnID", originalConnID)
@@ -475,11 +503,19 @@ func (p *Plugin) removeUserSession(state *callState, userID, originalConnID, con
 		}
 		setCallEnded(&state.Call)
 
+		if cfg := p.getConfiguration(); cfg.liveCaptionsEnabled() {
+			go p.postCaptionsTranscript(state.Call)
+		}
+
 		defer func() {
-			_, err := p.updateCallPostEnded(state.Call.PostID, mapKeys(state.Call.Props.Participants))
+			missed := p.checkMissedCall(&state.Call)
+			_, err := p.updateCallPostEnded(state.Call.PostID, mapKeys(state.Call.Props.Participants), missed)
 			if err != nil {
 				p.LogError("failed to update call post ended", "err", err.Error(), "channelID", channelID)
 			}
+			if missed {
+				go p.notifyMissedCall(state.Call)
+			}
 		}()
 	}
 
@@ -557,6 +593,10 @@ func (p *Plugin) removeSession(us *session) error {
 					p.LogDebug("stopped removeSessionsBatcher for call", "channelID", channelID, "callID", callID)
 				}()
 			}
+
+			if p.captionsBatchers[callID] != nil {
+				go p.stopCaptionsBatcher(callID)
+			}
 		}
 		p.mut.Unlock()
 
@@ -0,0 +1,139 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync/atomic"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+)
+
+var errTransferSessionNotFound = errors.New("session to transfer not found")
+
+// getSessionToTransfer returns the user session a new connection is going to
+// take over.
+func (cs *callState) getSessionToTransfer(userID, sessionID string) (*public.CallSession, error) {
+	if cs == nil {
+		return nil, ErrNoCallOngoing
+	}
+
+	session := cs.sessions[sessionID]
+	if session == nil || session.UserID != userID || session.IsExternal() {
+		return nil, errTransferSessionNotFound
+	}
+
+	return session, nil
+}
+
+// handleTransfer moves the user's ongoing call session to a new connection
+// (e.g. joining from mobile while on desktop). The new connection joins the
+// call claiming the previous session's state, after which the previous
+// connection gets notified and leaves.
+func (p *Plugin) handleTransfer(userID, connID, authSessionID string, joinData callsJoinData) error {
+	p.LogDebug("handleTransfer", "userID", userID, "connID", connID, "channelID", joinData.ChannelID,
+		"transferSessionID", joinData.TransferSessionID)
+
+	if p.isBot(userID) {
+		return fmt.Errorf("forbidden")
+	}
+
+	state, err := p.getCallState(joinData.ChannelID, false)
+	if err != nil {
+		return err
+	}
+	if _, err := state.getSessionToTransfer(userID, joinData.TransferSessionID); err != nil {
+		return err
+	}
+
+	return p.handleJoin(userID, connID, authSessionID, joinData)
+}
+
+// transferUserSession carries over the state of the previous session to the
+// newly added one and makes the previous connection leave the call. It must be
+// called with the call locked.
+func (p *Plugin) transferUserSession(state *callState, userID, fromID, connID string) error {
+	from, err := state.getSessionToTransfer(userID, fromID)
+	if err != nil {
+		return err
+	}
+
+	to := state.sessions[connID]
+	if to == nil {
+		return fmt.Errorf("session is missing from call state")
+	}
+
+	// The new client gets told through the join response whether it should
+	// pick up the microphone and unmute.
+	to.JoinAt = from.JoinAt
+	to.Unmuted = from.Unmuted
+	to.RaisedHand = from.RaisedHand
+	if err := p.store.UpdateCallSession(to); err != nil {
+		return fmt.Errorf("failed to update call session: %w", err)
+	}
+
+	// The new session takes the place of the previous one in the spotlight.
+	if idx := slices.Index(state.Call.Props.SpotlightSessionIDs, from.ID); idx >= 0 {
+		sessionIDs := slices.Clone(state.Call.Props.SpotlightSessionIDs)
+		sessionIDs[idx] = to.ID
+		p.updateSpotlight(state, sessionIDs)
+		if err := p.store.UpdateCall(&state.Call); err != nil {
+			return fmt.Errorf("failed to update call: %w", err)
+		}
+	}
+
+	if to.RaisedHand > 0 {
+		p.publishWebSocketEvent(wsEventUserRaiseHand, map[string]interface{}{
+			"userID":      to.UserID,
+			"session_id":  to.ID,
+			"raised_hand": to.RaisedHand,
+		}, &WebSocketBroadcast{
+			ChannelID:           state.Call.ChannelID,
+			ReliableClusterSend: true,
+			UserIDs:             getUserIDsFromSessions(state.sessions),
+		})
+	}
+
+	// Clients match the session ID against their own to tell whether they
+	// should leave the call.
+	p.publishWebSocketEvent(wsEventCallTransferred, map[string]interface{}{
+		"channel_id":     state.Call.ChannelID,
+		"session_id":     from.ID,
+		"new_session_id": to.ID,
+		"unmuted":        to.Unmuted,
+	}, &WebSocketBroadcast{UserID: from.UserID, ReliableClusterSend: true})
+
+	p.leaveTransferredSession(from.ID)
+
+	// The session may be handled by other nodes (WebSocket and RTC sides).
+	if err := p.sendClusterMessage(clusterMessage{
+		ConnID:    from.ID,
+		NewConnID: to.ID,
+		UserID:    from.UserID,
+		ChannelID: state.Call.ChannelID,
+		CallID:    state.Call.ID,
+		SenderID:  p.nodeID,
+	}, clusterMessageTypeTransfer, ""); err != nil {
+		return fmt.Errorf("failed to send transfer message: %w", err)
+	}
+
+	return nil
+}
+
+// leaveTransferredSession makes the locally tracked session, if any, leave
+// the call. The session is then removed through the regular leave flow.
+func (p *Plugin) leaveTransferredSession(originalConnID string) {
+	us := p.getSessionByOriginalID(originalConnID)
+	if us == nil {
+		return
+	}
+
+	if atomic.CompareAndSwapInt32(&us.left, 0, 1) {
+		p.LogDebug("closing leaveCh for transferred session", "userID", us.userID, "connID", us.connID,
+			"originalConnID", originalConnID)
+		close(us.leaveCh)
+	}
+}
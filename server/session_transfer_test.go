@@ -0,0 +1,226 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/db"
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	serverMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost-plugin-calls/server/interfaces"
+	pluginMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSessionToTransfer(t *testing.T) {
+	var nilState *callState
+	_, err := nilState.getSessionToTransfer("userA", "sessionA")
+	require.ErrorIs(t, err, ErrNoCallOngoing)
+
+	state := &callState{
+		sessions: map[string]*public.CallSession{
+			"sessionA": {ID: "sessionA", UserID: "userA"},
+			"sessionB": {ID: "sessionB", UserID: "userB"},
+			"guestA":   {ID: "guestA", UserID: "guestA", Type: public.CallSessionTypeGuest},
+		},
+	}
+
+	session, err := state.getSessionToTransfer("userA", "sessionA")
+	require.NoError(t, err)
+	require.Equal(t, "sessionA", session.ID)
+
+	for _, tc := range []struct {
+		userID    string
+		sessionID string
+	}{
+		{"userA", "sessionC"},
+		{"userA", "sessionB"},
+		{"guestA", "guestA"},
+	} {
+		_, err := state.getSessionToTransfer(tc.userID, tc.sessionID)
+		require.ErrorIs(t, err, errTransferSessionNotFound, tc.sessionID)
+	}
+}
+
+func TestLeaveTransferredSession(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	defer mockAPI.AssertExpectations(t)
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		sessions: map[string]*session{},
+	}
+
+	// The session reconnected with a new connection ID.
+	us := newUserSession("userA", "channelID", "connB", "callID", false)
+	us.originalConnID = "connA"
+	p.sessions["connB"] = us
+
+	mockAPI.On("LogDebug", "closing leaveCh for transferred session",
+		"origin", mock.AnythingOfType("string"), "userID", "userA", "connID", "connB",
+		"originalConnID", "connA").Once()
+
+	p.leaveTransferredSession("connA")
+	select {
+	case <-us.leaveCh:
+	default:
+		require.Fail(t, "leaveCh should be closed")
+	}
+
+	// Should be idempotent.
+	p.leaveTransferredSession("connA")
+
+	// Unknown sessions are ignored.
+	p.leaveTransferredSession(model.NewId())
+}
+
+func TestTransferUserSession(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	mockMetrics := &serverMocks.MockMetrics{}
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		metrics:  mockMetrics,
+		sessions: map[string]*session{},
+		nodeID:   "nodeID",
+	}
+
+	store, tearDown := NewTestStore(t)
+	t.Cleanup(tearDown)
+	p.store = store
+
+	newState := func(t *testing.T) *callState {
+		t.Helper()
+
+		state := &callState{
+			Call: public.Call{
+				ID:        model.NewId(),
+				ChannelID: model.NewId(),
+				StartAt:   100,
+				CreateAt:  100,
+				OwnerID:   "userA",
+			},
+			sessions: map[string]*public.CallSession{
+				"sessionA": {ID: "sessionA", UserID: "userA", JoinAt: 100, Unmuted: true, RaisedHand: 150},
+				"sessionB": {ID: "sessionB", UserID: "userA", JoinAt: 200},
+				"sessionC": {ID: "sessionC", UserID: "userB", JoinAt: 120},
+			},
+		}
+		require.NoError(t, p.store.CreateCall(&state.Call))
+		for _, session := range state.sessions {
+			session.CallID = state.Call.ID
+			require.NoError(t, p.store.CreateCallSession(session))
+		}
+
+		return state
+	}
+
+	t.Run("session not found", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+		defer mockMetrics.AssertExpectations(t)
+		defer ResetTestStore(t, p.store)
+
+		state := newState(t)
+		err := p.transferUserSession(state, "userA", "sessionC", "sessionB")
+		require.ErrorIs(t, err, errTransferSessionNotFound)
+	})
+
+	t.Run("new session missing", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+		defer mockMetrics.AssertExpectations(t)
+		defer ResetTestStore(t, p.store)
+
+		state := newState(t)
+		err := p.transferUserSession(state, "userA", "sessionA", "sessionD")
+		require.EqualError(t, err, "session is missing from call state")
+	})
+
+	t.Run("transferred", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+		defer mockMetrics.AssertExpectations(t)
+		defer ResetTestStore(t, p.store)
+
+		state := newState(t)
+
+		// The previous connection is tracked locally.
+		us := newUserSession("userA", state.Call.ChannelID, "sessionA", state.Call.ID, false)
+		p.sessions["sessionA"] = us
+		defer delete(p.sessions, "sessionA")
+
+		mockMetrics.On("IncWebSocketEvent", "out", wsEventUserRaiseHand).Once()
+		for _, userID := range []string{"userA", "userB"} {
+			mockAPI.On("PublishWebSocketEvent", wsEventUserRaiseHand, map[string]any{
+				"userID":      "userA",
+				"session_id":  "sessionB",
+				"raised_hand": int64(150),
+			}, &model.WebsocketBroadcast{
+				UserId:              userID,
+				ChannelId:           state.Call.ChannelID,
+				ReliableClusterSend: true,
+			}).Once()
+		}
+
+		mockMetrics.On("IncWebSocketEvent", "out", wsEventCallTransferred).Once()
+		mockAPI.On("PublishWebSocketEvent", wsEventCallTransferred, map[string]any{
+			"channel_id":     state.Call.ChannelID,
+			"session_id":     "sessionA",
+			"new_session_id": "sessionB",
+			"unmuted":        true,
+		}, &model.WebsocketBroadcast{
+			UserId:              "userA",
+			ReliableClusterSend: true,
+		}).Once()
+
+		mockAPI.On("LogDebug", "closing leaveCh for transferred session",
+			"origin", mock.AnythingOfType("string"), "userID", "userA", "connID", "sessionA",
+			"originalConnID", "sessionA").Once()
+
+		msg := clusterMessage{
+			ConnID:    "sessionA",
+			NewConnID: "sessionB",
+			UserID:    "userA",
+			ChannelID: state.Call.ChannelID,
+			CallID:    state.Call.ID,
+			SenderID:  "nodeID",
+		}
+		msgData, err := msg.ToJSON()
+		require.NoError(t, err)
+		mockMetrics.On("IncClusterEvent", string(clusterMessageTypeTransfer)).Once()
+		mockAPI.On("PublishPluginClusterEvent", model.PluginClusterEvent{
+			Id:   string(clusterMessageTypeTransfer),
+			Data: msgData,
+		}, model.PluginClusterEventSendOptions{
+			SendType: model.PluginClusterEventSendTypeReliable,
+		}).Return(nil).Once()
+
+		err = p.transferUserSession(state, "userA", "sessionA", "sessionB")
+		require.NoError(t, err)
+
+		// Join time, unmuted state and raised hand carry over.
+		to := state.sessions["sessionB"]
+		require.Equal(t, int64(100), to.JoinAt)
+		require.Equal(t, int64(150), to.RaisedHand)
+		require.True(t, to.Unmuted)
+
+		session, err := p.store.GetCallSession("sessionB", db.GetCallSessionOpts{FromWriter: true})
+		require.NoError(t, err)
+		require.Equal(t, to, session)
+
+		select {
+		case <-us.leaveCh:
+		default:
+			require.Fail(t, "leaveCh should be closed")
+		}
+	})
+}
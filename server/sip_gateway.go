@@ -0,0 +1,202 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	gatewayTokenHeader       = "X-Calls-Gateway-Token"
+	gatewayRequestTimeout    = 10 * time.Second
+	gatewayEventQueueSize    = 1024
+	gatewayErrResponseMaxLen = 1024
+	callerIDMaxLen           = 64
+	// The number of trailing digits of the caller ID that are left visible to
+	// call participants.
+	callerIDVisibleDigits = 4
+)
+
+// maskCallerID hides all but the last few digits of a caller ID (e.g. +1 555
+// 123 4567 becomes +*******4567) so that phone numbers aren't exposed to call
+// participants.
+func maskCallerID(callerID string) string {
+	var prefix string
+	if strings.HasPrefix(callerID, "+") {
+		prefix = "+"
+	}
+
+	var digits int
+	for _, r := range callerID {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	if digits == 0 {
+		return ""
+	}
+
+	visible := 0
+	if digits > callerIDVisibleDigits {
+		visible = callerIDVisibleDigits
+	}
+
+	var b strings.Builder
+	b.WriteString(prefix)
+	var i int
+	for _, r := range callerID {
+		if r < '0' || r > '9' {
+			continue
+		}
+		if i < digits-visible {
+			b.WriteByte('*')
+		} else {
+			b.WriteRune(r)
+		}
+		i++
+	}
+
+	return b.String()
+}
+
+// gatewayAuthMiddleware only lets through requests carrying the token shared
+// with the SIP gateway.
+func (p *Plugin) gatewayAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := p.getConfiguration()
+		if !cfg.sipGatewayEnabled() {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		token := r.Header.Get(gatewayTokenHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.SIPGatewayToken)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sendGatewayEvent queues an event to be sent to the SIP gateway. Events are
+// sent in order by a single routine so that signaling messages for a session
+// aren't reordered.
+func (p *Plugin) sendGatewayEvent(ev externalEvent) {
+	select {
+	case p.gatewayEventCh <- ev:
+	default:
+		p.LogError("too many gateway events, channel is full, dropping.", "type", ev.Type, "sessionID", ev.SessionID)
+	}
+}
+
+func (p *Plugin) gatewayEventsSender() {
+	client := &http.Client{
+		Timeout: gatewayRequestTimeout,
+	}
+
+	for {
+		select {
+		case ev := <-p.gatewayEventCh:
+			if err := p.postGatewayEvent(client, ev); err != nil {
+				p.LogError("failed to send gateway event", "type", ev.Type, "sessionID", ev.SessionID, "err", err.Error())
+			}
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+func (p *Plugin) postGatewayEvent(client *http.Client, ev externalEvent) error {
+	cfg := p.getConfiguration()
+	if !cfg.sipGatewayEnabled() {
+		return fmt.Errorf("SIP gateway is not enabled")
+	}
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, cfg.SIPGatewayURL, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(gatewayTokenHeader, cfg.SIPGatewayToken)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		errBody, _ := io.ReadAll(io.LimitReader(resp.Body, gatewayErrResponseMaxLen))
+		return fmt.Errorf("unexpected response status code %d: %s", resp.StatusCode, errBody)
+	}
+
+	return nil
+}
+
+func (p *Plugin) handleGatewayJoin(w http.ResponseWriter, r *http.Request) {
+	var res httpResponse
+	defer p.httpAudit("handleGatewayJoin", &res, w, r)
+
+	channelID := mux.Vars(r)["channel_id"]
+
+	var data struct {
+		CallerID string `json:"caller_id"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, externalRequestBodyMaxSize)).Decode(&data); err != nil {
+		res.Err = "failed to decode request body: " + err.Error()
+		res.Code = http.StatusBadRequest
+		return
+	}
+	if len(data.CallerID) > callerIDMaxLen {
+		res.Err = "caller_id is too long"
+		res.Code = http.StatusBadRequest
+		return
+	}
+
+	us, err := p.joinExternalSession(channelID, &public.CallSession{
+		Type:     public.CallSessionTypePhone,
+		CallerID: maskCallerID(data.CallerID),
+	}, nil)
+	if err != nil {
+		res.Err = err.Error()
+		res.Code = getJoinExternalSessionErrCode(err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]string{
+		"session_id": us.connID,
+		"call_id":    us.callID,
+	}); err != nil {
+		p.LogError(err.Error())
+	}
+}
+
+func (p *Plugin) handleGatewayLeave(w http.ResponseWriter, r *http.Request) {
+	p.handleExternalSessionLeave(w, r, public.CallSessionTypePhone)
+}
+
+// handleGatewayMessage handles signaling and mute/unmute messages for a phone
+// session.
+func (p *Plugin) handleGatewayMessage(w http.ResponseWriter, r *http.Request) {
+	p.handleExternalSessionMessage(w, r, public.CallSessionTypePhone)
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	serverMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost-plugin-calls/server/interfaces"
+	pluginMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"golang.org/x/time/rate"
+)
+
+func TestMaskCallerID(t *testing.T) {
+	tcs := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"anonymous", ""},
+		{"1234", "****"},
+		{"12345", "*2345"},
+		{"+15551234567", "+*******4567"},
+		{"+1 (555) 123-4567", "+*******4567"},
+		{"sip:5551234567@example.com", "******4567"},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.input, func(t *testing.T) {
+			require.Equal(t, tc.expected, maskCallerID(tc.input))
+		})
+	}
+}
+
+func TestGatewayAPIAuth(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	mockMetrics := &serverMocks.MockMetrics{}
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		metrics:     mockMetrics,
+		apiLimiters: map[string]*rate.Limiter{},
+	}
+
+	mockMetrics.On("Handler").Return(nil).Once()
+
+	// Audit log
+	auditArgs := []any{"handleGatewayJoin", "origin", mock.AnythingOfType("string")}
+	for range 16 {
+		auditArgs = append(auditArgs, mock.Anything)
+	}
+	mockAPI.On("LogDebug", auditArgs...)
+
+	apiRouter := p.newAPIRouter()
+
+	join := func(t *testing.T, token string) *http.Response {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/gateway/calls/"+model.NewId()+"/sessions", strings.NewReader("{"))
+		if token != "" {
+			r.Header.Set(gatewayTokenHeader, token)
+		}
+		apiRouter.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		p.configuration = &configuration{}
+		p.configuration.SetDefaults()
+		p.configuration.SIPGatewayToken = "token"
+
+		resp := join(t, "token")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	p.configuration.EnableSIPGateway = model.NewPointer(true)
+	p.configuration.SIPGatewayURL = "http://localhost:8080"
+
+	t.Run("missing token", func(t *testing.T) {
+		resp := join(t, "")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		resp := join(t, "invalid")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("valid token", func(t *testing.T) {
+		resp := join(t, "token")
+		defer resp.Body.Close()
+		// The request reaches the handler, failing on the malformed body.
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+}
+
+func TestPostGatewayEvent(t *testing.T) {
+	var received externalEvent
+	var token string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get(gatewayTokenHeader)
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if received.Type == externalEventHangup {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+	}))
+	defer ts.Close()
+
+	p := Plugin{
+		configuration: &configuration{},
+	}
+	p.configuration.SetDefaults()
+	p.configuration.EnableSIPGateway = model.NewPointer(true)
+	p.configuration.SIPGatewayURL = ts.URL
+	p.configuration.SIPGatewayToken = "token"
+
+	ev := externalEvent{
+		Type:      externalEventSignal,
+		ChannelID: model.NewId(),
+		SessionID: model.NewId(),
+		Data:      `{"type":"offer"}`,
+	}
+
+	err := p.postGatewayEvent(ts.Client(), ev)
+	require.NoError(t, err)
+	require.Equal(t, ev, received)
+	require.Equal(t, "token", token)
+
+	ev.Type = externalEventHangup
+	err = p.postGatewayEvent(ts.Client(), ev)
+	require.EqualError(t, err, "unexpected response status code 404: ")
+
+	p.configuration.EnableSIPGateway = model.NewPointer(false)
+	err = p.postGatewayEvent(ts.Client(), ev)
+	require.EqualError(t, err, "SIP gateway is not enabled")
+}
@@ -25,6 +25,7 @@ const (
 	recordingCommandTrigger = "recording"
 	hostCommandTrigger      = "host"
 	logsCommandTrigger      = "logs"
+	settingsCommandTrigger  = "settings"
 )
 
 var subCommands = []string{
@@ -36,6 +37,7 @@ var subCommands = []string{
 	statsCommandTrigger,
 	recordingCommandTrigger,
 	logsCommandTrigger,
+	settingsCommandTrigger,
 }
 
 func (p *Plugin) getAutocompleteData() *model.AutocompleteData {
@@ -52,9 +54,13 @@ func (p *Plugin) getAutocompleteData() *model.AutocompleteData {
 	data.AddCommand(model.NewAutocompleteData(logsCommandTrigger, "", "Show client logs."))
 
 	recordingCmdData := model.NewAutocompleteData(recordingCommandTrigger, "", "Manage calls recordings")
-	recordingCmdData.AddTextArgument("Available options: start, stop", "", "start|stop")
+	recordingCmdData.AddTextArgument("Available options: start [language=<code>] [api=<api>] [model=<size>], stop", "", "start|stop")
 	data.AddCommand(recordingCmdData)
 
+	settingsCmdData := model.NewAutocompleteData(settingsCommandTrigger, "", "Show or change your call settings")
+	settingsCmdData.AddTextArgument("Available options: ring on|off, dms-only on|off, status on|off, dnd <HH:MM-HH:MM>|off, mute, unmute", "", "")
+	data.AddCommand(settingsCmdData)
+
 	if p.licenseChecker.HostControlsAllowed() {
 		subCommands = append(subCommands, hostCommandTrigger)
 		hostCmdData := model.NewAutocompleteData(hostCommandTrigger, "", "Change the host (system admins only).")
@@ -98,7 +104,7 @@ func (p *Plugin) handleLinkCommand(args *model.CommandArgs) (*model.CommandRespo
 		return nil, appErr
 	}
 
-	link := fmt.Sprintf("%s/%s/channels/%s?join_call=true", args.SiteURL, team.Name, channel.Id)
+	link := callJoinLink(args.SiteURL, team.Name, channel.Id)
 
 	return &model.CommandResponse{
 		ResponseType: model.CommandResponseTypeEphemeral,
@@ -140,17 +146,48 @@ func (p *Plugin) handleEndCallCommand() (*model.CommandResponse, error) {
 }
 
 func (p *Plugin) handleRecordingCommand(fields []string) (*model.CommandResponse, error) {
-	if len(fields) != 3 {
+	if len(fields) < 3 {
 		return nil, fmt.Errorf("invalid number of arguments provided")
 	}
 
-	if subCmd := fields[2]; subCmd != "start" && subCmd != "stop" {
+	switch subCmd := fields[2]; subCmd {
+	case "start":
+		// Transcription options can optionally follow (e.g. language=es).
+		if _, err := parseTranscriptionOptions(fields[3:]); err != nil {
+			return nil, err
+		}
+	case "stop":
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid number of arguments provided")
+		}
+	default:
 		return nil, fmt.Errorf("invalid subcommand %q", subCmd)
 	}
 
 	return &model.CommandResponse{}, nil
 }
 
+func (p *Plugin) handleSettingsCommand(args *model.CommandArgs, fields []string) (*model.CommandResponse, error) {
+	prefs, err := p.getCallPreferences(args.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) > 2 {
+		if err := applyCallPreferencesCommand(prefs, args.ChannelId, fields[2:]); err != nil {
+			return nil, err
+		}
+		if err := p.saveCallPreferences(args.UserId, prefs); err != nil {
+			return nil, err
+		}
+	}
+
+	return &model.CommandResponse{
+		ResponseType: model.CommandResponseTypeEphemeral,
+		Text:         prefs.String(),
+	}, nil
+}
+
 func (p *Plugin) handleHostCommand(args *model.CommandArgs, fields []string) (*model.CommandResponse, error) {
 	if len(fields) != 3 {
 		return nil, fmt.Errorf("invalid number of arguments provided")
@@ -216,6 +253,10 @@ func (p *Plugin) ExecuteCommand(_ *plugin.Context, args *model.CommandArgs) (*mo
 		return buildCommandResponse(p.handleRecordingCommand(fields))
 	}
 
+	if subCmd == settingsCommandTrigger {
+		return buildCommandResponse(p.handleSettingsCommand(args, fields))
+	}
+
 	if subCmd == hostCommandTrigger && p.licenseChecker.HostControlsAllowed() {
 		return buildCommandResponse(p.handleHostCommand(args, fields))
 	}
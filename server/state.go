@@ -52,6 +52,20 @@ func (cs *callState) Clone() *callState {
 			csCopy.Props.Participants[k] = v
 		}
 	}
+	if cs.Props.SpotlightSessionIDs != nil {
+		csCopy.Props.SpotlightSessionIDs = make([]string, len(cs.Call.Props.SpotlightSessionIDs))
+		copy(csCopy.Call.Props.SpotlightSessionIDs, cs.Call.Props.SpotlightSessionIDs)
+	}
+	if cs.Props.PresenterIDs != nil {
+		csCopy.Props.PresenterIDs = make([]string, len(cs.Call.Props.PresenterIDs))
+		copy(csCopy.Call.Props.PresenterIDs, cs.Call.Props.PresenterIDs)
+	}
+	if cs.Props.CaptionsLanguages != nil {
+		csCopy.Props.CaptionsLanguages = make(map[string]public.CaptionsLanguage, len(cs.Call.Props.CaptionsLanguages))
+		for k, v := range cs.Call.Props.CaptionsLanguages {
+			csCopy.Props.CaptionsLanguages[k] = v
+		}
+	}
 
 	// Sessions
 	if cs.sessions != nil {
@@ -85,6 +99,10 @@ type UserStateClient struct {
 	Unmuted    bool   `json:"unmuted"`
 	RaisedHand int64  `json:"raised_hand"`
 	Video      bool   `json:"video"`
+
+	Type        public.CallSessionType `json:"type,omitempty"`
+	CallerID    string                 `json:"caller_id,omitempty"`
+	DisplayName string                 `json:"display_name,omitempty"`
 }
 
 type CallStateClient struct {
@@ -103,6 +121,11 @@ type CallStateClient struct {
 	Transcription          *JobStateClient `json:"transcription,omitempty"`
 	LiveCaptions           *JobStateClient `json:"live_captions,omitempty"`
 	DismissedNotification  map[string]bool `json:"dismissed_notification,omitempty"`
+	SpotlightSessionIDs    []string        `json:"spotlight_session_ids,omitempty"`
+	Webinar                bool            `json:"webinar,omitempty"`
+	PresenterIDs           []string        `json:"presenter_ids,omitempty"`
+	// Captions holds the most recent live captions, only sent on join.
+	Captions []*public.CallCaption `json:"captions,omitempty"`
 }
 
 type JobStateClient struct {
@@ -181,8 +204,8 @@ func (cs *callState) getHostID(botID string) string {
 			return cs.Call.GetHostID()
 		}
 
-		// bot can't be host
-		if session.UserID == botID {
+		// bot and external participants can't be host
+		if session.UserID == botID || session.IsExternal() {
 			continue
 		}
 
@@ -234,6 +257,9 @@ func (cs *callState) getClientState(botID, userID string) *CallStateClient {
 		Transcription:          getClientStateFromCallJob(cs.Transcription),
 		LiveCaptions:           getClientStateFromCallJob(cs.LiveCaptions),
 		DismissedNotification:  dismissed,
+		SpotlightSessionIDs:    cs.Props.SpotlightSessionIDs,
+		Webinar:                cs.Props.Webinar,
+		PresenterIDs:           cs.Props.PresenterIDs,
 	}
 }
 
@@ -245,11 +271,14 @@ func (cs *callState) getStates(botID string) []UserStateClient {
 			continue
 		}
 		states = append(states, UserStateClient{
-			SessionID:  session.ID,
-			UserID:     session.UserID,
-			Unmuted:    session.Unmuted,
-			RaisedHand: session.RaisedHand,
-			Video:      session.Video,
+			SessionID:   session.ID,
+			UserID:      session.UserID,
+			Unmuted:     session.Unmuted,
+			RaisedHand:  session.RaisedHand,
+			Video:       session.Video,
+			Type:        session.Type,
+			CallerID:    session.CallerID,
+			DisplayName: session.DisplayName,
 		})
 	}
 	return states
@@ -266,6 +295,16 @@ func (cs *callState) onlyUserLeft(userID string) bool {
 	return found
 }
 
+// getSession returns the session with the given ID if the state is for the
+// given call.
+func (cs *callState) getSession(callID, sessionID string) (*public.CallSession, bool) {
+	if cs == nil || cs.Call.ID != callID {
+		return nil, false
+	}
+	session, ok := cs.sessions[sessionID]
+	return session, ok
+}
+
 func (p *Plugin) getCallStateFromCall(call *public.Call, fromWriter bool) (*callState, error) {
 	if call == nil {
 		return nil, fmt.Errorf("call should not be nil")
@@ -320,6 +359,66 @@ func (p *Plugin) getCallState(channelID string, fromWriter bool) (*callState, er
 	return p.getCallStateFromCall(call, fromWriter)
 }
 
+// callStateCacheTTL is for how long a call state read outside of the call
+// lock is reused. Hot paths (e.g. live captions, media permission checks)
+// would otherwise hit the database on every message.
+const callStateCacheTTL = time.Second
+
+type cachedCallState struct {
+	state     *callState
+	expiresAt time.Time
+}
+
+// getCachedCallState returns a recent, read-only copy of the call state for
+// the given channel. Changes made on this node are visible as soon as the call
+// is unlocked, changes made on other nodes after at most callStateCacheTTL.
+// It must not be used to mutate the call state, lockCallReturnState should be
+// used for that.
+func (p *Plugin) getCachedCallState(channelID string) (*callState, error) {
+	p.callStateCacheMut.Lock()
+	entry, ok := p.callStateCache[channelID]
+	p.callStateCacheMut.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.state.Clone(), nil
+	}
+
+	return p.refreshCachedCallState(channelID)
+}
+
+// refreshCachedCallState reads the call state for the given channel and
+// caches it.
+func (p *Plugin) refreshCachedCallState(channelID string) (*callState, error) {
+	state, err := p.getCallState(channelID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	p.callStateCacheMut.Lock()
+	if p.callStateCache == nil {
+		p.callStateCache = map[string]cachedCallState{}
+	}
+	for id, entry := range p.callStateCache {
+		if now.After(entry.expiresAt) {
+			delete(p.callStateCache, id)
+		}
+	}
+	p.callStateCache[channelID] = cachedCallState{
+		state:     state,
+		expiresAt: now.Add(callStateCacheTTL),
+	}
+	p.callStateCacheMut.Unlock()
+
+	return state.Clone(), nil
+}
+
+// invalidateCachedCallState drops the cached state for the given channel.
+func (p *Plugin) invalidateCachedCallState(channelID string) {
+	p.callStateCacheMut.Lock()
+	delete(p.callStateCache, channelID)
+	p.callStateCacheMut.Unlock()
+}
+
 func (p *Plugin) cleanUpState() error {
 	p.LogDebug("cleaning up calls state")
 
@@ -359,7 +458,9 @@ func (p *Plugin) cleanCallState(call *public.Call) error {
 		return nil
 	}
 
-	if _, err := p.updateCallPostEnded(call.PostID, mapKeys(call.Props.Participants)); err != nil {
+	// Calls cleaned up this way may have been interrupted (e.g. server restart)
+	// so we only mark them as missed without notifying.
+	if _, err := p.updateCallPostEnded(call.PostID, mapKeys(call.Props.Participants), p.checkMissedCall(call)); err != nil {
 		p.LogError("failed to update call post", "err", err.Error())
 	}
 
@@ -367,6 +468,23 @@ func (p *Plugin) cleanCallState(call *public.Call) error {
 		setCallEnded(call)
 	}
 
+	// Restore the custom status of any user that was still in the call.
+	sessions, err := p.store.GetCallSessions(call.ID, db.GetCallSessionOpts{
+		FromWriter: true,
+	})
+	if err != nil {
+		p.LogError("failed to get call sessions", "err", err.Error())
+	}
+	for _, session := range sessions {
+		if session.UserID != p.getBotID() && !session.IsExternal() {
+			p.queueInCallStatusUpdate(inCallStatusUpdate{
+				userID:    session.UserID,
+				channelID: call.ChannelID,
+				sessionID: session.ID,
+			})
+		}
+	}
+
 	if err := p.store.DeleteCallsSessions(call.ID); err != nil {
 		p.LogError("failed to delete calls sessions", "err", err.Error())
 	}
@@ -403,5 +521,6 @@ func setCallEnded(call *public.Call) {
 	call.Props.DismissedNotification = nil
 	call.Props.NodeID = ""
 	call.Props.Hosts = nil
+	call.Props.SpotlightSessionIDs = nil
 	call.Props.Participants = nil
 }
@@ -43,6 +43,9 @@ func TestCallStateGetClientState(t *testing.T) {
 				Props: public.CallProps{
 					Hosts:                  []string{"hostID"},
 					ScreenSharingSessionID: "sessionA",
+					SpotlightSessionIDs:    []string{"sessionA"},
+					Webinar:                true,
+					PresenterIDs:           []string{"userA"},
 				},
 			},
 			sessions: map[string]*public.CallSession{
@@ -68,6 +71,9 @@ func TestCallStateGetClientState(t *testing.T) {
 			ScreenSharingSessionID: cs.Props.ScreenSharingSessionID,
 			OwnerID:                cs.OwnerID,
 			HostID:                 cs.Props.Hosts[0],
+			SpotlightSessionIDs:    []string{"sessionA"},
+			Webinar:                true,
+			PresenterIDs:           []string{"userA"},
 		}
 
 		require.Equal(t, &ccs, cs.getClientState("botID", "userID"))
@@ -252,6 +258,34 @@ func TestCallStateGetHostID(t *testing.T) {
 		require.Equal(t, "userA", cs.getHostID("botID"))
 	})
 
+	t.Run("skip phone sessions", func(t *testing.T) {
+		cs := &callState{
+			Call: public.Call{
+				ID:      "test",
+				StartAt: 100,
+			},
+			sessions: map[string]*public.CallSession{
+				"phoneSessionID": {
+					ID:       "phoneSessionID",
+					UserID:   "phoneSessionID",
+					JoinAt:   800,
+					Type:     public.CallSessionTypePhone,
+					CallerID: "*******4567",
+				},
+				"sessionA": {
+					ID:     "sessionA",
+					UserID: "userA",
+					JoinAt: 1000,
+				},
+			},
+		}
+
+		require.Equal(t, "userA", cs.getHostID("botID"))
+
+		delete(cs.sessions, "sessionA")
+		require.Empty(t, cs.getHostID("botID"))
+	})
+
 	t.Run("returns existing host", func(t *testing.T) {
 		cs := &callState{
 			Call: public.Call{
@@ -349,6 +383,9 @@ func TestCallStateClone(t *testing.T) {
 					Hosts:                  []string{model.NewId()},
 					RTCDHost:               model.NewId(),
 					ScreenSharingSessionID: model.NewId(),
+					SpotlightSessionIDs:    []string{model.NewId(), model.NewId()},
+					Webinar:                true,
+					PresenterIDs:           []string{model.NewId()},
 					DismissedNotification: map[string]bool{
 						model.NewId(): true,
 						model.NewId(): true,
@@ -422,6 +459,8 @@ func TestCallStateClone(t *testing.T) {
 		require.Equal(t, cs, csCopy)
 
 		require.False(t, samePointer(t, cs.sessions, csCopy.sessions))
+		require.False(t, samePointer(t, cs.Props.SpotlightSessionIDs, csCopy.Props.SpotlightSessionIDs))
+		require.False(t, samePointer(t, cs.Props.PresenterIDs, csCopy.Props.PresenterIDs))
 
 		for k := range cs.sessions {
 			require.False(t, samePointer(t, cs.sessions[k], csCopy.sessions[k]))
@@ -516,6 +555,51 @@ func BenchmarkCallStateClone(b *testing.B) {
 	}
 }
 
+func TestGetCachedCallState(t *testing.T) {
+	p := Plugin{}
+
+	state := &callState{
+		Call: public.Call{
+			ID:        "callID",
+			ChannelID: "channelID",
+			Props: public.CallProps{
+				CaptionsLanguages: map[string]public.CaptionsLanguage{
+					"originalConnA": {Language: "it", ConnID: "connA"},
+				},
+			},
+		},
+		sessions: map[string]*public.CallSession{
+			"connA": {ID: "connA", CallID: "callID", UserID: "userA"},
+		},
+	}
+
+	p.callStateCache = map[string]cachedCallState{
+		"channelID": {state: state, expiresAt: time.Now().Add(time.Minute)},
+	}
+
+	t.Run("cached", func(t *testing.T) {
+		cached, err := p.getCachedCallState("channelID")
+		require.NoError(t, err)
+		require.Equal(t, state, cached)
+
+		// Callers get a copy they can't use to alter the cache.
+		cached.Props.CaptionsLanguages["originalConnA"] = public.CaptionsLanguage{Language: "fr"}
+		require.Equal(t, "it", state.Props.CaptionsLanguages["originalConnA"].Language)
+
+		session, ok := cached.getSession("callID", "connA")
+		require.True(t, ok)
+		require.Equal(t, "userA", session.UserID)
+
+		_, ok = cached.getSession("otherCallID", "connA")
+		require.False(t, ok)
+	})
+
+	t.Run("invalidated", func(t *testing.T) {
+		p.invalidateCachedCallState("channelID")
+		require.Empty(t, p.callStateCache)
+	})
+}
+
 func TestCleanUpState(t *testing.T) {
 	mockAPI := &pluginMocks.MockAPI{}
 	mockMetrics := &serverMocks.MockMetrics{}
@@ -526,6 +610,7 @@ func TestCleanUpState(t *testing.T) {
 		},
 		metrics:           mockMetrics,
 		callsClusterLocks: map[string]*cluster.Mutex{},
+		inCallStatusCh:    make(chan inCallStatusUpdate, inCallStatusQueueSize),
 	}
 
 	store, tearDown := NewTestStore(t)
@@ -585,6 +670,10 @@ func TestCleanUpState(t *testing.T) {
 			mockMetrics.On("ObserveClusterMutexGrabTime", "mutex_call", mock.AnythingOfType("float64"))
 			mockMetrics.On("ObserveClusterMutexLockedTime", "mutex_call", mock.AnythingOfType("float64"))
 
+			mockAPI.On("GetChannel", channelID).Return(&model.Channel{
+				Id:   channelID,
+				Type: model.ChannelTypeOpen,
+			}, nil).Once()
 			mockAPI.On("UpdatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{Id: postID}, nil).Once()
 			mockAPI.On("GetConfig").Return(&model.Config{}, nil).Once()
 			mockAPI.On("KVDelete", "mutex_call_"+channelID).Return(nil)
@@ -676,6 +765,10 @@ func TestCleanUpState(t *testing.T) {
 			mockMetrics.On("ObserveClusterMutexGrabTime", "mutex_call", mock.AnythingOfType("float64"))
 			mockMetrics.On("ObserveClusterMutexLockedTime", "mutex_call", mock.AnythingOfType("float64"))
 
+			mockAPI.On("GetChannel", channelID).Return(&model.Channel{
+				Id:   channelID,
+				Type: model.ChannelTypeOpen,
+			}, nil).Once()
 			mockAPI.On("UpdatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{Id: postID}, nil).Once()
 			mockAPI.On("GetConfig").Return(&model.Config{}, nil).Once()
 			mockAPI.On("KVDelete", "mutex_call_"+channelID).Return(nil)
@@ -758,6 +851,10 @@ func TestCleanUpState(t *testing.T) {
 			mockMetrics.On("ObserveClusterMutexGrabTime", "mutex_call", mock.AnythingOfType("float64"))
 			mockMetrics.On("ObserveClusterMutexLockedTime", "mutex_call", mock.AnythingOfType("float64"))
 
+			mockAPI.On("GetChannel", channelID).Return(&model.Channel{
+				Id:   channelID,
+				Type: model.ChannelTypeOpen,
+			}, nil).Once()
 			mockAPI.On("UpdatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{Id: postID}, nil).Once()
 			mockAPI.On("GetConfig").Return(&model.Config{}, nil).Once()
 			mockAPI.On("KVDelete", "mutex_call_"+channelID).Return(nil)
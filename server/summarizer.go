@@ -0,0 +1,267 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/db"
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	summarizerRequestTimeout   = 2 * time.Minute
+	summarizerMaxAttempts      = 3
+	summarizerRetryDelay       = 5 * time.Second
+	summarizerMaxResponseBytes = 1024 * 1024 // 1MB
+	summarizerSystemPrompt     = `You are given the transcript of a meeting. Reply with a JSON object having the following fields:
+"summary": a short paragraph summarizing the meeting,
+"action_items": a list of strings, one for each action item that was agreed upon,
+"decisions": a list of strings, one for each decision that was made.
+Reply with the JSON object only.`
+)
+
+// CallSummary holds the result of summarizing a call's transcript.
+type CallSummary struct {
+	Summary     string   `json:"summary"`
+	ActionItems []string `json:"action_items"`
+	Decisions   []string `json:"decisions"`
+}
+
+// Summarizer generates a summary out of a call's transcript.
+type Summarizer interface {
+	Summarize(ctx context.Context, transcript string) (*CallSummary, error)
+}
+
+// httpSummarizer implements Summarizer against an OpenAI compatible
+// chat completions API.
+type httpSummarizer struct {
+	apiURL      string
+	apiKey      string
+	model       string
+	client      *http.Client
+	maxAttempts int
+	retryDelay  time.Duration
+}
+
+func newHTTPSummarizer(apiURL, apiKey, model string) *httpSummarizer {
+	return &httpSummarizer{
+		apiURL:      strings.TrimSuffix(apiURL, "/"),
+		apiKey:      apiKey,
+		model:       model,
+		client:      &http.Client{},
+		maxAttempts: summarizerMaxAttempts,
+		retryDelay:  summarizerRetryDelay,
+	}
+}
+
+type chatCompletionMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatCompletionRequest struct {
+	Model          string                  `json:"model"`
+	Messages       []chatCompletionMessage `json:"messages"`
+	ResponseFormat map[string]string       `json:"response_format,omitempty"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message chatCompletionMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// errSummarizerPermanent wraps errors that should not be retried.
+var errSummarizerPermanent = errors.New("permanent failure")
+
+func (s *httpSummarizer) Summarize(ctx context.Context, transcript string) (*CallSummary, error) {
+	if strings.TrimSpace(transcript) == "" {
+		return nil, fmt.Errorf("transcript should not be empty")
+	}
+
+	body, err := json.Marshal(chatCompletionRequest{
+		Model: s.model,
+		Messages: []chatCompletionMessage{
+			{Role: "system", Content: summarizerSystemPrompt},
+			{Role: "user", Content: transcript},
+		},
+		ResponseFormat: map[string]string{"type": "json_object"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		summary, err := s.doRequest(ctx, body)
+		if err == nil {
+			return summary, nil
+		}
+
+		if errors.Is(err, errSummarizerPermanent) || attempt >= s.maxAttempts {
+			return nil, fmt.Errorf("failed to summarize transcript after %d attempt(s): %w", attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to summarize transcript: %w", ctx.Err())
+		case <-time.After(s.retryDelay * time.Duration(attempt)):
+		}
+	}
+}
+
+func (s *httpSummarizer) doRequest(ctx context.Context, body []byte) (*CallSummary, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.apiURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w: %w", err, errSummarizerPermanent)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, summarizerMaxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("unexpected response status code %d", resp.StatusCode)
+		// Rate limiting and server side errors are worth retrying, anything
+		// else is likely a configuration issue.
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < http.StatusInternalServerError {
+			err = fmt.Errorf("%w: %w", err, errSummarizerPermanent)
+		}
+		return nil, err
+	}
+
+	var completion chatCompletionResponse
+	if err := json.Unmarshal(data, &completion); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(completion.Choices) == 0 {
+		return nil, fmt.Errorf("unexpected empty choices in response")
+	}
+
+	var summary CallSummary
+	content := strings.TrimSpace(completion.Choices[0].Message.Content)
+	if err := json.Unmarshal([]byte(content), &summary); err != nil {
+		return nil, fmt.Errorf("failed to decode summary: %w", err)
+	}
+
+	if summary.Summary == "" {
+		return nil, fmt.Errorf("unexpected empty summary")
+	}
+
+	return &summary, nil
+}
+
+// getSummarizer returns the Summarizer to use to generate call summaries, or
+// nil if summaries are not enabled.
+func (p *Plugin) getSummarizer() Summarizer {
+	cfg := p.getConfiguration()
+	if !cfg.summariesEnabled() {
+		return nil
+	}
+
+	return newHTTPSummarizer(cfg.SummarizerAPIURL, cfg.SummarizerAPIKey, cfg.SummarizerModel)
+}
+
+func (p *Plugin) updateTranscriptionSummaryStatus(trID string, status public.SummaryStatus, postID, errMsg string) {
+	trJob, err := p.store.GetCallJob(trID, db.GetCallJobOpts{FromWriter: true, IncludeEnded: true})
+	if err != nil {
+		p.LogError("failed to get transcription job", "trID", trID, "err", err.Error())
+		return
+	}
+
+	trJob.Props.SummaryStatus = status
+	trJob.Props.SummaryPostID = postID
+	trJob.Props.SummaryErr = errMsg
+
+	if err := p.store.UpdateCallJob(trJob); err != nil {
+		p.LogError("failed to update transcription job", "trID", trID, "err", err.Error())
+	}
+}
+
+// summarizeTranscription generates a summary for the given transcription file
+// and posts it as a reply in the call thread.
+func (p *Plugin) summarizeTranscription(summarizer Summarizer, callID, threadID, callPostID, trID, fileID string) {
+	p.LogDebug("summarizing transcription", "callID", callID, "trID", trID)
+
+	p.updateTranscriptionSummaryStatus(trID, public.SummaryStatusInProgress, "", "")
+
+	postID, err := func() (string, error) {
+		transcript, appErr := p.API.GetFile(fileID)
+		if appErr != nil {
+			return "", fmt.Errorf("failed to get transcription file: %w", appErr)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), summarizerRequestTimeout*summarizerMaxAttempts)
+		defer cancel()
+		summary, err := summarizer.Summarize(ctx, string(transcript))
+		if err != nil {
+			return "", err
+		}
+
+		summaryPost := &model.Post{
+			UserId:    p.getBotID(),
+			ChannelId: callID,
+			Message:   p.formatCallSummary(summary),
+			RootId:    threadID,
+		}
+		summaryPost.AddProp("call_post_id", callPostID)
+		summaryPost.AddProp("transcription_id", trID)
+		post, appErr := p.API.CreatePost(summaryPost)
+		if appErr != nil {
+			return "", fmt.Errorf("failed to create post: %w", appErr)
+		}
+
+		return post.Id, nil
+	}()
+	if err != nil {
+		p.LogError("failed to summarize transcription", "callID", callID, "trID", trID, "err", err.Error())
+		p.updateTranscriptionSummaryStatus(trID, public.SummaryStatusFailed, "", err.Error())
+		return
+	}
+
+	p.updateTranscriptionSummaryStatus(trID, public.SummaryStatusCompleted, postID, "")
+}
+
+func (p *Plugin) formatCallSummary(summary *CallSummary) string {
+	T := p.getTranslationFunc("")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#### %s\n%s\n", T("app.call.summary.title"), summary.Summary)
+
+	writeList := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n#### %s\n", title)
+		for _, item := range items {
+			fmt.Fprintf(&b, "- %s\n", item)
+		}
+	}
+	writeList(T("app.call.summary.action_items"), summary.ActionItems)
+	writeList(T("app.call.summary.decisions"), summary.Decisions)
+
+	return strings.TrimSpace(b.String())
+}
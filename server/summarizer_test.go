@@ -0,0 +1,259 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/db"
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	serverMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost-plugin-calls/server/interfaces"
+	pluginMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeSummarizerServer(t *testing.T, handler func(w http.ResponseWriter, req chatCompletionRequest)) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Handlers run outside of the test goroutine so we can't use require here.
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer apiKey", r.Header.Get("Authorization"))
+
+		var req chatCompletionRequest
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&req)) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		handler(w, req)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func writeFakeCompletion(t *testing.T, w http.ResponseWriter, content string) {
+	t.Helper()
+	err := json.NewEncoder(w).Encode(map[string]any{
+		"choices": []map[string]any{
+			{
+				"message": map[string]any{
+					"role":    "assistant",
+					"content": content,
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+}
+
+func TestHTTPSummarizer(t *testing.T) {
+	summaryContent := `{"summary": "We talked", "action_items": ["Do this"], "decisions": ["Decided that"]}`
+
+	t.Run("empty transcript", func(t *testing.T) {
+		s := newHTTPSummarizer("http://localhost", "apiKey", "model")
+		summary, err := s.Summarize(context.Background(), " ")
+		require.EqualError(t, err, "transcript should not be empty")
+		require.Nil(t, summary)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		srv := newFakeSummarizerServer(t, func(w http.ResponseWriter, req chatCompletionRequest) {
+			assert.Equal(t, "model", req.Model)
+			if assert.Len(t, req.Messages, 2) {
+				assert.Equal(t, "user", req.Messages[1].Role)
+				assert.Equal(t, "transcript", req.Messages[1].Content)
+			}
+			writeFakeCompletion(t, w, summaryContent)
+		})
+
+		s := newHTTPSummarizer(srv.URL+"/v1/", "apiKey", "model")
+		summary, err := s.Summarize(context.Background(), "transcript")
+		require.NoError(t, err)
+		require.Equal(t, &CallSummary{
+			Summary:     "We talked",
+			ActionItems: []string{"Do this"},
+			Decisions:   []string{"Decided that"},
+		}, summary)
+	})
+
+	t.Run("retry on server error", func(t *testing.T) {
+		var calls atomic.Int32
+		srv := newFakeSummarizerServer(t, func(w http.ResponseWriter, _ chatCompletionRequest) {
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			writeFakeCompletion(t, w, summaryContent)
+		})
+
+		s := newHTTPSummarizer(srv.URL+"/v1", "apiKey", "model")
+		s.retryDelay = 0
+		summary, err := s.Summarize(context.Background(), "transcript")
+		require.NoError(t, err)
+		require.Equal(t, "We talked", summary.Summary)
+		require.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("max attempts", func(t *testing.T) {
+		var calls atomic.Int32
+		srv := newFakeSummarizerServer(t, func(w http.ResponseWriter, _ chatCompletionRequest) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+
+		s := newHTTPSummarizer(srv.URL+"/v1", "apiKey", "model")
+		s.retryDelay = 0
+		summary, err := s.Summarize(context.Background(), "transcript")
+		require.EqualError(t, err, "failed to summarize transcript after 3 attempt(s): unexpected response status code 429")
+		require.Nil(t, summary)
+		require.Equal(t, int32(summarizerMaxAttempts), calls.Load())
+	})
+
+	t.Run("no retry on client error", func(t *testing.T) {
+		var calls atomic.Int32
+		srv := newFakeSummarizerServer(t, func(w http.ResponseWriter, _ chatCompletionRequest) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+
+		s := newHTTPSummarizer(srv.URL+"/v1", "apiKey", "model")
+		s.retryDelay = 0
+		summary, err := s.Summarize(context.Background(), "transcript")
+		require.EqualError(t, err, "failed to summarize transcript after 1 attempt(s): unexpected response status code 401: permanent failure")
+		require.Nil(t, summary)
+		require.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("invalid content", func(t *testing.T) {
+		srv := newFakeSummarizerServer(t, func(w http.ResponseWriter, _ chatCompletionRequest) {
+			writeFakeCompletion(t, w, "not JSON")
+		})
+
+		s := newHTTPSummarizer(srv.URL+"/v1", "apiKey", "model")
+		s.maxAttempts = 1
+		summary, err := s.Summarize(context.Background(), "transcript")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to decode summary")
+		require.Nil(t, summary)
+	})
+}
+
+type fakeSummarizer struct {
+	summary *CallSummary
+	err     error
+}
+
+func (s *fakeSummarizer) Summarize(_ context.Context, _ string) (*CallSummary, error) {
+	return s.summary, s.err
+}
+
+func TestSummarizeTranscription(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	mockMetrics := &serverMocks.MockMetrics{}
+
+	botUserID := model.NewId()
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		metrics: mockMetrics,
+		botSession: &model.Session{
+			UserId: botUserID,
+		},
+	}
+
+	store, tearDown := NewTestStore(t)
+	t.Cleanup(tearDown)
+	p.store = store
+
+	mockAPI.On("GetConfig").Return(&model.Config{})
+	mockAPI.On("LogDebug", "summarizing transcription",
+		"origin", mock.AnythingOfType("string"), "callID", mock.AnythingOfType("string"), "trID", mock.AnythingOfType("string"))
+
+	createTrJob := func(t *testing.T, callID string) *public.CallJob {
+		t.Helper()
+		trJob := &public.CallJob{
+			ID:        model.NewId(),
+			CallID:    callID,
+			Type:      public.JobTypeTranscribing,
+			CreatorID: botUserID,
+			InitAt:    45,
+			StartAt:   45,
+			EndAt:     100,
+		}
+		require.NoError(t, store.CreateCallJob(trJob))
+		return trJob
+	}
+
+	t.Run("success", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		callID := model.NewId()
+		threadID := model.NewId()
+		fileID := model.NewId()
+		trJob := createTrJob(t, callID)
+
+		mockAPI.On("GetFile", fileID).Return([]byte("transcript"), nil).Once()
+		mockAPI.On("CreatePost", mock.AnythingOfType("*model.Post")).Run(func(args mock.Arguments) {
+			post := args.Get(0).(*model.Post)
+			require.Equal(t, botUserID, post.UserId)
+			require.Equal(t, callID, post.ChannelId)
+			require.Equal(t, threadID, post.RootId)
+			require.Equal(t, "#### app.call.summary.title\nWe talked\n\n#### app.call.summary.action_items\n- Do this", post.Message)
+			require.Equal(t, trJob.ID, post.GetProp("transcription_id"))
+		}).Return(&model.Post{Id: "summaryPostID"}, nil).Once()
+
+		p.summarizeTranscription(&fakeSummarizer{
+			summary: &CallSummary{
+				Summary:     "We talked",
+				ActionItems: []string{"Do this"},
+			},
+		}, callID, threadID, threadID, trJob.ID, fileID)
+
+		job, err := store.GetCallJob(trJob.ID, db.GetCallJobOpts{IncludeEnded: true})
+		require.NoError(t, err)
+		require.Equal(t, public.SummaryStatusCompleted, job.Props.SummaryStatus)
+		require.Equal(t, "summaryPostID", job.Props.SummaryPostID)
+		require.Empty(t, job.Props.SummaryErr)
+	})
+
+	t.Run("summarizer failure", func(t *testing.T) {
+		defer mockAPI.AssertExpectations(t)
+
+		callID := model.NewId()
+		threadID := model.NewId()
+		fileID := model.NewId()
+		trJob := createTrJob(t, callID)
+
+		mockAPI.On("GetFile", fileID).Return([]byte("transcript"), nil).Once()
+		mockAPI.On("LogError", "failed to summarize transcription",
+			"origin", mock.AnythingOfType("string"), "callID", callID, "trID", trJob.ID, "err", "summarizer failed").Once()
+
+		p.summarizeTranscription(&fakeSummarizer{
+			err: fmt.Errorf("summarizer failed"),
+		}, callID, threadID, threadID, trJob.ID, fileID)
+
+		job, err := store.GetCallJob(trJob.ID, db.GetCallJobOpts{IncludeEnded: true})
+		require.NoError(t, err)
+		require.Equal(t, public.SummaryStatusFailed, job.Props.SummaryStatus)
+		require.Empty(t, job.Props.SummaryPostID)
+		require.Equal(t, "summarizer failed", job.Props.SummaryErr)
+	})
+}
@@ -64,6 +64,9 @@ func (p *Plugin) lockCallReturnState(channelID string) (*callState, error) {
 
 // unlockCall unlocks the global (cluster) mutex for the given channelID.
 func (p *Plugin) unlockCall(channelID string) {
+	// The call state may have changed while locked.
+	p.invalidateCachedCallState(channelID)
+
 	p.callsClusterLocksMut.RLock()
 	defer p.callsClusterLocksMut.RUnlock()
 
@@ -99,7 +99,7 @@ func (p *Plugin) transcriptionJobTimeoutChecker(callID, jobID string) {
 	}
 }
 
-func (p *Plugin) startTranscribingJob(state *callState, callID, userID, trID string) (rerr error) {
+func (p *Plugin) startTranscribingJob(state *callState, callID, userID, trID string, opts TranscriptionOptions) (rerr error) {
 	if state.Transcription != nil && state.Transcription.EndAt == 0 {
 		return fmt.Errorf("transcription already in progress")
 	}
@@ -114,6 +114,9 @@ func (p *Plugin) startTranscribingJob(state *callState, callID, userID, trID str
 	trState.Type = public.JobTypeTranscribing
 	trState.CreatorID = userID
 	trState.InitAt = time.Now().UnixMilli()
+	trState.Props.Language = opts.Language
+	trState.Props.TranscribeAPI = string(opts.API)
+	trState.Props.ModelSize = string(opts.ModelSize)
 
 	if err := p.store.CreateCallJob(trState); err != nil {
 		return fmt.Errorf("failed to create call job: %w", err)
@@ -128,6 +131,7 @@ func (p *Plugin) startTranscribingJob(state *callState, callID, userID, trID str
 		lcState.Type = public.JobTypeCaptioning
 		lcState.CreatorID = userID
 		lcState.InitAt = time.Now().UnixMilli()
+		lcState.Props.Language = opts.Language
 		if err := p.store.CreateCallJob(lcState); err != nil {
 			return fmt.Errorf("failed to create call job: %w", err)
 		}
@@ -167,7 +171,7 @@ func (p *Plugin) startTranscribingJob(state *callState, callID, userID, trID str
 	// We don't want to keep the lock while making the API call to the service since it
 	// could take a while to return. We lock again as soon as this returns.
 	p.unlockCall(callID)
-	trJobID, jobErr := p.getJobService().RunJob(job.TypeTranscribing, callID, state.Call.PostID, trState.ID, p.botSession.Token)
+	trJobID, jobErr := p.getJobService().RunJob(job.TypeTranscribing, callID, state.Call.PostID, trState.ID, p.botSession.Token, &opts)
 	state, err := p.lockCallReturnState(callID)
 	if err != nil {
 		return fmt.Errorf("failed to lock call: %w", err)
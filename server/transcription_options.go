@@ -0,0 +1,160 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/db"
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	transcriber "github.com/mattermost/calls-transcriber/cmd/transcriber/config"
+)
+
+// The calls channel prop holding the default transcription language for the channel.
+const channelPropTranscriptionLanguage = "transcription_language"
+
+// TranscriptionOptions holds the settings to use for a single transcription
+// job. Any empty field falls back to the channel or plugin defaults.
+type TranscriptionOptions struct {
+	Language  string                    `json:"language,omitempty"`
+	API       transcriber.TranscribeAPI `json:"api,omitempty"`
+	ModelSize transcriber.ModelSize     `json:"model_size,omitempty"`
+}
+
+func (o TranscriptionOptions) isEmpty() bool {
+	return o == TranscriptionOptions{}
+}
+
+// modelSizeRank returns the position of the given model size from smallest to
+// largest, or -1 if unknown.
+func modelSizeRank(size transcriber.ModelSize) int {
+	switch size {
+	case transcriber.ModelSizeTiny:
+		return 0
+	case transcriber.ModelSizeBase:
+		return 1
+	case transcriber.ModelSizeSmall:
+		return 2
+	default:
+		return -1
+	}
+}
+
+// parseTranscriptionOptions parses options given as key=value pairs (e.g.
+// from a slash command).
+func parseTranscriptionOptions(fields []string) (TranscriptionOptions, error) {
+	var opts TranscriptionOptions
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || value == "" {
+			return opts, fmt.Errorf("invalid option %q: should be in the key=value format", field)
+		}
+		switch key {
+		case "language":
+			opts.Language = value
+		case "api":
+			opts.API = transcriber.TranscribeAPI(value)
+		case "model":
+			opts.ModelSize = transcriber.ModelSize(value)
+		default:
+			return opts, fmt.Errorf("unknown option %q", key)
+		}
+	}
+	return opts, nil
+}
+
+// getChannelTranscriptionLanguage returns the default transcription language
+// set for the given channel, if any.
+func (p *Plugin) getChannelTranscriptionLanguage(channelID string) (string, error) {
+	channel, err := p.store.GetCallsChannel(channelID, db.GetCallsChannelOpts{})
+	if errors.Is(err, db.ErrNotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get calls channel: %w", err)
+	}
+
+	lang, _ := channel.Props[channelPropTranscriptionLanguage].(string)
+	return lang, nil
+}
+
+// validateChannelTranscriptionProps checks that the transcription related
+// props being set on a calls channel are allowed.
+func (p *Plugin) validateChannelTranscriptionProps(props public.StringMap) error {
+	val, ok := props[channelPropTranscriptionLanguage]
+	if !ok {
+		return nil
+	}
+
+	cfg := p.getConfiguration()
+	if !cfg.transcriptionJobOptionsEnabled() {
+		return fmt.Errorf("transcription options are not enabled")
+	}
+
+	if lang, ok := val.(string); !ok || !cfg.isTranscriptionLanguageAllowed(lang) {
+		return fmt.Errorf("transcription language is not allowed")
+	}
+
+	return nil
+}
+
+// resolveTranscriptionOptions merges the requested options with the channel
+// and plugin defaults, making sure the result is within the limits set by
+// the admin.
+func (p *Plugin) resolveTranscriptionOptions(channelID string, req TranscriptionOptions) (TranscriptionOptions, error) {
+	cfg := p.getConfiguration()
+
+	opts := TranscriptionOptions{
+		Language:  cfg.LiveCaptionsLanguage,
+		API:       cfg.TranscribeAPI,
+		ModelSize: cfg.TranscriberModelSize,
+	}
+
+	if !cfg.transcriptionJobOptionsEnabled() {
+		if !req.isEmpty() {
+			return opts, fmt.Errorf("transcription options are not enabled")
+		}
+		return opts, nil
+	}
+
+	lang, err := p.getChannelTranscriptionLanguage(channelID)
+	if err != nil {
+		return opts, err
+	}
+	if lang != "" {
+		opts.Language = lang
+	}
+
+	if req.Language != "" {
+		opts.Language = req.Language
+	}
+	if req.API != "" {
+		opts.API = req.API
+	}
+	if req.ModelSize != "" {
+		opts.ModelSize = req.ModelSize
+	}
+
+	if !cfg.isTranscriptionLanguageAllowed(opts.Language) {
+		return opts, fmt.Errorf("transcription language %q is not allowed", opts.Language)
+	}
+
+	switch opts.API {
+	case transcriber.TranscribeAPIWhisperCPP:
+	case transcriber.TranscribeAPIAzure:
+		if !cfg.azureTranscribeAPIAvailable() {
+			return opts, fmt.Errorf("transcription API %q is not configured", opts.API)
+		}
+	default:
+		return opts, fmt.Errorf("transcription API %q is not allowed", opts.API)
+	}
+
+	if !cfg.isTranscriberModelSizeAllowed(opts.ModelSize) {
+		return opts, fmt.Errorf("transcription model size %q is not allowed", opts.ModelSize)
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	serverMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost-plugin-calls/server/interfaces"
+
+	transcriber "github.com/mattermost/calls-transcriber/cmd/transcriber/config"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTranscriptionOptions(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		opts, err := parseTranscriptionOptions(nil)
+		require.NoError(t, err)
+		require.True(t, opts.isEmpty())
+	})
+
+	t.Run("valid", func(t *testing.T) {
+		opts, err := parseTranscriptionOptions([]string{"language=it", "api=azure", "model=small"})
+		require.NoError(t, err)
+		require.Equal(t, TranscriptionOptions{
+			Language:  "it",
+			API:       transcriber.TranscribeAPIAzure,
+			ModelSize: transcriber.ModelSizeSmall,
+		}, opts)
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		_, err := parseTranscriptionOptions([]string{"language"})
+		require.EqualError(t, err, `invalid option "language": should be in the key=value format`)
+
+		_, err = parseTranscriptionOptions([]string{"language="})
+		require.EqualError(t, err, `invalid option "language=": should be in the key=value format`)
+	})
+
+	t.Run("unknown option", func(t *testing.T) {
+		_, err := parseTranscriptionOptions([]string{"speed=fast"})
+		require.EqualError(t, err, `unknown option "speed"`)
+	})
+}
+
+func TestResolveTranscriptionOptions(t *testing.T) {
+	newConfig := func() *configuration {
+		var cfg configuration
+		cfg.SetDefaults()
+		cfg.EnableRecordings = model.NewPointer(true)
+		cfg.EnableTranscriptions = model.NewPointer(true)
+		cfg.LiveCaptionsLanguage = "en"
+		return &cfg
+	}
+
+	t.Run("job options disabled", func(t *testing.T) {
+		p := Plugin{
+			configuration: newConfig(),
+		}
+
+		opts, err := p.resolveTranscriptionOptions(model.NewId(), TranscriptionOptions{})
+		require.NoError(t, err)
+		require.Equal(t, TranscriptionOptions{
+			Language:  "en",
+			API:       transcriber.TranscribeAPIDefault,
+			ModelSize: transcriber.ModelSizeDefault,
+		}, opts)
+
+		_, err = p.resolveTranscriptionOptions(model.NewId(), TranscriptionOptions{Language: "it"})
+		require.EqualError(t, err, "transcription options are not enabled")
+	})
+
+	t.Run("job options enabled", func(t *testing.T) {
+		mockMetrics := &serverMocks.MockMetrics{}
+		mockMetrics.On("IncStoreOp", mock.AnythingOfType("string"))
+		mockMetrics.On("ObserveStoreMethodsTime", mock.AnythingOfType("string"), mock.AnythingOfType("float64"))
+
+		cfg := newConfig()
+		cfg.EnableTranscriptionJobOptions = model.NewPointer(true)
+		cfg.TranscriptionAllowedLanguages = "en,it,es"
+		cfg.TranscriberModelSize = transcriber.ModelSizeTiny
+		cfg.TranscriberMaxModelSize = transcriber.ModelSizeBase
+
+		p := Plugin{
+			configuration: cfg,
+			metrics:       mockMetrics,
+		}
+
+		store, tearDown := NewTestStore(t)
+		t.Cleanup(tearDown)
+		p.store = store
+
+		channelID := model.NewId()
+		require.NoError(t, store.CreateCallsChannel(&public.CallsChannel{
+			ChannelID: channelID,
+			Enabled:   true,
+			Props: public.StringMap{
+				channelPropTranscriptionLanguage: "es",
+			},
+		}))
+
+		t.Run("channel default", func(t *testing.T) {
+			opts, err := p.resolveTranscriptionOptions(channelID, TranscriptionOptions{})
+			require.NoError(t, err)
+			require.Equal(t, "es", opts.Language)
+		})
+
+		t.Run("no channel default", func(t *testing.T) {
+			opts, err := p.resolveTranscriptionOptions(model.NewId(), TranscriptionOptions{})
+			require.NoError(t, err)
+			require.Equal(t, "en", opts.Language)
+		})
+
+		t.Run("requested", func(t *testing.T) {
+			opts, err := p.resolveTranscriptionOptions(channelID, TranscriptionOptions{
+				Language:  "it",
+				ModelSize: transcriber.ModelSizeBase,
+			})
+			require.NoError(t, err)
+			require.Equal(t, TranscriptionOptions{
+				Language:  "it",
+				API:       transcriber.TranscribeAPIDefault,
+				ModelSize: transcriber.ModelSizeBase,
+			}, opts)
+		})
+
+		t.Run("language not allowed", func(t *testing.T) {
+			_, err := p.resolveTranscriptionOptions(channelID, TranscriptionOptions{Language: "fr"})
+			require.EqualError(t, err, `transcription language "fr" is not allowed`)
+		})
+
+		t.Run("api not configured", func(t *testing.T) {
+			_, err := p.resolveTranscriptionOptions(channelID, TranscriptionOptions{API: transcriber.TranscribeAPIAzure})
+			require.EqualError(t, err, `transcription API "azure" is not configured`)
+		})
+
+		t.Run("model size too large", func(t *testing.T) {
+			_, err := p.resolveTranscriptionOptions(channelID, TranscriptionOptions{ModelSize: transcriber.ModelSizeSmall})
+			require.EqualError(t, err, `transcription model size "small" is not allowed`)
+		})
+	})
+}
@@ -0,0 +1,348 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+)
+
+const (
+	translatorAPIHTTP = "http"
+	translatorAPIStub = "stub"
+
+	translatorRequestTimeout   = 5 * time.Second
+	translatorMaxResponseBytes = 64 * 1024 // 64KB
+
+	// translatorQueueSize is how many captions can be waiting to be translated
+	// into a given language before new ones are dropped.
+	translatorQueueSize = 32
+	// translatorWorkerIdleTimeout is for how long a translation worker is kept
+	// around without captions to translate.
+	translatorWorkerIdleTimeout = 30 * time.Second
+)
+
+// Translator translates live captions text between languages. Languages are
+// expressed as 2-letter ISO 639 set 1 codes.
+type Translator interface {
+	Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error)
+}
+
+// httpTranslator implements Translator against a LibreTranslate compatible
+// API.
+type httpTranslator struct {
+	apiURL string
+	apiKey string
+	client *http.Client
+}
+
+func newHTTPTranslator(apiURL, apiKey string) *httpTranslator {
+	return &httpTranslator{
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+		apiKey: apiKey,
+		client: &http.Client{},
+	}
+}
+
+type translateRequest struct {
+	Q      string `json:"q"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Format string `json:"format"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+type translateResponse struct {
+	TranslatedText string `json:"translatedText"`
+}
+
+func (t *httpTranslator) Translate(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	body, err := json.Marshal(translateRequest{
+		Q:      text,
+		Source: sourceLang,
+		Target: targetLang,
+		Format: "text",
+		APIKey: t.apiKey,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.apiURL+"/translate", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected response status code %d", resp.StatusCode)
+	}
+
+	var res translateResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, translatorMaxResponseBytes)).Decode(&res); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return res.TranslatedText, nil
+}
+
+// stubTranslator is a local Translator that doesn't perform any actual
+// translation but tags the text with the target language. Useful for
+// development and testing.
+type stubTranslator struct{}
+
+func (stubTranslator) Translate(_ context.Context, text, _, targetLang string) (string, error) {
+	return fmt.Sprintf("[%s] %s", targetLang, text), nil
+}
+
+// getTranslator returns the Translator to use for live captions, or nil if
+// translation is not enabled.
+func (p *Plugin) getTranslator() Translator {
+	cfg := p.getConfiguration()
+	if !cfg.liveCaptionsTranslationEnabled() {
+		return nil
+	}
+
+	switch cfg.LiveCaptionsTranslationAPI {
+	case translatorAPIStub:
+		return stubTranslator{}
+	default:
+		return newHTTPTranslator(cfg.LiveCaptionsTranslationAPIURL, cfg.LiveCaptionsTranslationAPIKey)
+	}
+}
+
+func isValidCaptionsLanguage(lang string) bool {
+	if len(lang) != 2 {
+		return false
+	}
+	for _, r := range lang {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// handleCaptionsLanguageMessage sets (or clears if empty) the preferred
+// live captions language for the given session.
+func (p *Plugin) handleCaptionsLanguageMessage(us *session, msg clientMessage) error {
+	if p.getTranslator() == nil {
+		return fmt.Errorf("live captions translation is not enabled")
+	}
+
+	var data struct {
+		Language string `json:"language"`
+	}
+	if err := json.Unmarshal(msg.Data, &data); err != nil {
+		return fmt.Errorf("failed to unmarshal captions language data: %w", err)
+	}
+	if data.Language != "" && !isValidCaptionsLanguage(data.Language) {
+		return fmt.Errorf("invalid captions language %q", data.Language)
+	}
+
+	state, err := p.lockCallReturnState(us.channelID)
+	if err != nil {
+		return fmt.Errorf("failed to lock call: %w", err)
+	}
+	defer p.unlockCall(us.channelID)
+	if state == nil {
+		return fmt.Errorf("no call ongoing")
+	}
+	if _, ok := state.sessions[us.originalConnID]; !ok {
+		return fmt.Errorf("user session is missing from call state")
+	}
+
+	if data.Language == "" {
+		delete(state.Call.Props.CaptionsLanguages, us.originalConnID)
+	} else {
+		if state.Call.Props.CaptionsLanguages == nil {
+			state.Call.Props.CaptionsLanguages = make(map[string]public.CaptionsLanguage)
+		}
+		state.Call.Props.CaptionsLanguages[us.originalConnID] = public.CaptionsLanguage{
+			Language: data.Language,
+			ConnID:   us.connID,
+		}
+	}
+
+	if err := p.store.UpdateCall(&state.Call); err != nil {
+		return fmt.Errorf("failed to update call: %w", err)
+	}
+
+	return nil
+}
+
+// updateCaptionsLanguageConnID keeps track of the current connection ID for
+// sessions that have a preferred captions language so that translated
+// captions keep flowing after a reconnect.
+func (p *Plugin) updateCaptionsLanguageConnID(channelID, originalConnID, connID string) error {
+	state, err := p.lockCallReturnState(channelID)
+	if err != nil {
+		return fmt.Errorf("failed to lock call: %w", err)
+	}
+	defer p.unlockCall(channelID)
+	if state == nil {
+		return fmt.Errorf("no call ongoing")
+	}
+
+	pref, ok := state.Call.Props.CaptionsLanguages[originalConnID]
+	if !ok || pref.ConnID == connID {
+		return nil
+	}
+	pref.ConnID = connID
+	state.Call.Props.CaptionsLanguages[originalConnID] = pref
+
+	if err := p.store.UpdateCall(&state.Call); err != nil {
+		return fmt.Errorf("failed to update call: %w", err)
+	}
+
+	return nil
+}
+
+// translateCaption queues the given caption to be translated into each of the
+// requested languages and sent only to the connections that asked for them.
+// Each language of a call is handled by its own worker so that translations
+// are delivered in order and a slow language doesn't hold back the others.
+func (p *Plugin) translateCaption(translator Translator, callID string, prefs map[string]public.CaptionsLanguage, data map[string]interface{}, sourceLang string) {
+	connsByLang := make(map[string][]string)
+	for _, pref := range prefs {
+		if pref.Language == sourceLang || pref.ConnID == "" {
+			continue
+		}
+		connsByLang[pref.Language] = append(connsByLang[pref.Language], pref.ConnID)
+	}
+
+	for lang, connIDs := range connsByLang {
+		p.queueCaptionTranslation(callID+"_"+lang, captionTranslation{
+			translator: translator,
+			data:       data,
+			sourceLang: sourceLang,
+			targetLang: lang,
+			connIDs:    connIDs,
+		})
+	}
+}
+
+// getTranslatedConnIDs returns, by user, the connections getting captions
+// translated from the given language.
+func getTranslatedConnIDs(state *callState, sourceLang string) map[string][]string {
+	connIDs := make(map[string][]string)
+	for originalConnID, pref := range state.Props.CaptionsLanguages {
+		if pref.Language == sourceLang || pref.ConnID == "" {
+			continue
+		}
+		ust := state.sessions[originalConnID]
+		if ust == nil {
+			continue
+		}
+		connIDs[ust.UserID] = append(connIDs[ust.UserID], pref.ConnID)
+	}
+	return connIDs
+}
+
+type captionTranslation struct {
+	translator Translator
+	data       map[string]interface{}
+	sourceLang string
+	targetLang string
+	connIDs    []string
+}
+
+type translationWorker struct {
+	queue chan captionTranslation
+}
+
+// queueCaptionTranslation hands the translation over to the worker for the
+// given key, starting one if needed. Captions are dropped if the worker can't
+// keep up, as late captions aren't useful anyway.
+func (p *Plugin) queueCaptionTranslation(key string, tr captionTranslation) {
+	p.translationWorkersMut.Lock()
+	defer p.translationWorkersMut.Unlock()
+
+	if p.translationWorkers == nil {
+		p.translationWorkers = map[string]*translationWorker{}
+	}
+
+	w := p.translationWorkers[key]
+	if w == nil {
+		w = &translationWorker{
+			queue: make(chan captionTranslation, translatorQueueSize),
+		}
+		p.translationWorkers[key] = w
+		go p.runTranslationWorker(key, w)
+	}
+
+	select {
+	case w.queue <- tr:
+	default:
+		p.LogWarn("translation queue is full, dropping caption", "language", tr.targetLang)
+	}
+}
+
+func (p *Plugin) runTranslationWorker(key string, w *translationWorker) {
+	idleTimer := time.NewTimer(translatorWorkerIdleTimeout)
+	defer idleTimer.Stop()
+
+	for {
+		select {
+		case tr := <-w.queue:
+			p.sendCaptionTranslation(tr)
+			idleTimer.Reset(translatorWorkerIdleTimeout)
+		case <-idleTimer.C:
+			// Captions are queued while holding the lock so checking the queue
+			// under it guarantees none is left behind.
+			p.translationWorkersMut.Lock()
+			if len(w.queue) == 0 {
+				delete(p.translationWorkers, key)
+				p.translationWorkersMut.Unlock()
+				return
+			}
+			p.translationWorkersMut.Unlock()
+			idleTimer.Reset(translatorWorkerIdleTimeout)
+		case <-p.stopCh:
+			return
+		}
+	}
+}
+
+func (p *Plugin) sendCaptionTranslation(tr captionTranslation) {
+	text, _ := tr.data["text"].(string)
+
+	ctx, cancel := context.WithTimeout(context.Background(), translatorRequestTimeout)
+	defer cancel()
+	translated, err := tr.translator.Translate(ctx, text, tr.sourceLang, tr.targetLang)
+	if err != nil {
+		// These connections don't get the original caption so we fall back to
+		// sending it rather than leaving them without any.
+		p.LogError("failed to translate caption", "language", tr.targetLang, "err", err.Error())
+	}
+
+	for _, connID := range tr.connIDs {
+		ev := make(map[string]interface{}, len(tr.data)+1)
+		for k, v := range tr.data {
+			ev[k] = v
+		}
+		if err == nil {
+			ev["text"] = translated
+			ev["language"] = tr.targetLang
+		}
+		p.publishWebSocketEvent(wsEventCaption, ev, &WebSocketBroadcast{
+			ConnectionID:        connID,
+			ReliableClusterSend: true,
+		})
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	serverMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost-plugin-calls/server/interfaces"
+	pluginMocks "github.com/mattermost/mattermost-plugin-calls/server/mocks/github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPTranslator(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Handlers run outside of the test goroutine so we can't use require here.
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "/translate", r.URL.Path)
+
+			var req translateRequest
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, translateRequest{
+				Q:      "hello",
+				Source: "en",
+				Target: "it",
+				Format: "text",
+				APIKey: "apiKey",
+			}, req)
+
+			assert.NoError(t, json.NewEncoder(w).Encode(translateResponse{TranslatedText: "ciao"}))
+		}))
+		defer srv.Close()
+
+		tr := newHTTPTranslator(srv.URL+"/", "apiKey")
+		text, err := tr.Translate(context.Background(), "hello", "en", "it")
+		require.NoError(t, err)
+		require.Equal(t, "ciao", text)
+	})
+
+	t.Run("unexpected status code", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer srv.Close()
+
+		tr := newHTTPTranslator(srv.URL, "")
+		text, err := tr.Translate(context.Background(), "hello", "en", "it")
+		require.EqualError(t, err, "unexpected response status code 400")
+		require.Empty(t, text)
+	})
+}
+
+func TestIsValidCaptionsLanguage(t *testing.T) {
+	require.True(t, isValidCaptionsLanguage("en"))
+	require.True(t, isValidCaptionsLanguage("it"))
+	require.False(t, isValidCaptionsLanguage(""))
+	require.False(t, isValidCaptionsLanguage("EN"))
+	require.False(t, isValidCaptionsLanguage("eng"))
+	require.False(t, isValidCaptionsLanguage("e1"))
+}
+
+type fakeTranslator struct {
+	fail    map[string]bool
+	delays  map[string]time.Duration
+	started chan struct{}
+	release chan struct{}
+}
+
+func (tr *fakeTranslator) Translate(_ context.Context, text, _, targetLang string) (string, error) {
+	if tr.started != nil {
+		select {
+		case tr.started <- struct{}{}:
+		default:
+		}
+	}
+	if tr.release != nil {
+		<-tr.release
+	}
+	time.Sleep(tr.delays[text])
+	if tr.fail[targetLang] {
+		return "", fmt.Errorf("translation failed")
+	}
+	return stubTranslator{}.Translate(context.Background(), text, "", targetLang)
+}
+
+func TestGetTranslatedConnIDs(t *testing.T) {
+	state := &callState{
+		Call: public.Call{
+			Props: public.CallProps{
+				CaptionsLanguages: map[string]public.CaptionsLanguage{
+					"originalConnA": {Language: "it", ConnID: "connA"},
+					"originalConnB": {Language: "en", ConnID: "connB"},
+					"originalConnC": {Language: "fr", ConnID: "connC"},
+					"originalConnD": {Language: "fr"},
+					"originalConnE": {Language: "fr", ConnID: "connE"},
+				},
+			},
+		},
+		sessions: map[string]*public.CallSession{
+			"originalConnA": {ID: "originalConnA", UserID: "userA"},
+			"originalConnB": {ID: "originalConnB", UserID: "userB"},
+			"originalConnC": {ID: "originalConnC", UserID: "userA"},
+			"originalConnD": {ID: "originalConnD", UserID: "userD"},
+		},
+	}
+
+	connIDs := getTranslatedConnIDs(state, "en")
+	require.Len(t, connIDs, 1)
+	require.ElementsMatch(t, []string{"connA", "connC"}, connIDs["userA"])
+}
+
+func TestTranslateCaption(t *testing.T) {
+	mockAPI := &pluginMocks.MockAPI{}
+	mockMetrics := &serverMocks.MockMetrics{}
+
+	p := Plugin{
+		MattermostPlugin: plugin.MattermostPlugin{
+			API: mockAPI,
+		},
+		metrics: mockMetrics,
+		stopCh:  make(chan struct{}),
+	}
+	defer close(p.stopCh)
+
+	defer mockAPI.AssertExpectations(t)
+	defer mockMetrics.AssertExpectations(t)
+
+	var mut sync.Mutex
+	var sent []string
+	record := func(args mock.Arguments) {
+		mut.Lock()
+		defer mut.Unlock()
+		if args.Get(0) == wsEventCaption {
+			sent = append(sent, args.Get(1).(map[string]interface{})["text"].(string))
+			return
+		}
+		sent = append(sent, args.Get(0).(string))
+	}
+	waitSent := func(n int) []string {
+		t.Helper()
+		require.Eventually(t, func() bool {
+			mut.Lock()
+			defer mut.Unlock()
+			return len(sent) == n
+		}, 2*time.Second, 10*time.Millisecond)
+		mut.Lock()
+		defer mut.Unlock()
+		res := sent
+		sent = nil
+		return res
+	}
+
+	t.Run("multiple languages", func(t *testing.T) {
+		prefs := map[string]public.CaptionsLanguage{
+			"originalConnA": {Language: "it", ConnID: "connA"},
+			"originalConnB": {Language: "it", ConnID: "connB"},
+			"originalConnC": {Language: "en", ConnID: "connC"},
+			"originalConnD": {Language: "fr", ConnID: "connD"},
+		}
+
+		data := map[string]interface{}{
+			"session_id": "sessionID",
+			"user_id":    "userID",
+			"text":       "hello",
+			"language":   "en",
+		}
+
+		mockMetrics.On("IncWebSocketEvent", "out", wsEventCaption).Times(2)
+		for _, connID := range []string{"connA", "connB"} {
+			mockAPI.On("PublishWebSocketEvent", wsEventCaption, map[string]interface{}{
+				"session_id": "sessionID",
+				"user_id":    "userID",
+				"text":       "[it] hello",
+				"language":   "it",
+			}, &model.WebsocketBroadcast{ConnectionId: connID, ReliableClusterSend: true}).Run(record).Once()
+		}
+		mockAPI.On("LogError", "failed to translate caption",
+			"origin", mock.AnythingOfType("string"), "language", "fr", "err", "translation failed").Once()
+
+		// Failed translations fall back to the original caption.
+		mockMetrics.On("IncWebSocketEvent", "out", wsEventCaption).Once()
+		mockAPI.On("PublishWebSocketEvent", wsEventCaption, map[string]interface{}{
+			"session_id": "sessionID",
+			"user_id":    "userID",
+			"text":       "hello",
+			"language":   "en",
+		}, &model.WebsocketBroadcast{ConnectionId: "connD", ReliableClusterSend: true}).Run(record).Once()
+
+		p.translateCaption(&fakeTranslator{fail: map[string]bool{"fr": true}}, "callID", prefs, data, "en")
+
+		require.ElementsMatch(t, []string{"[it] hello", "[it] hello", "hello"}, waitSent(3))
+
+		// Original data should not be modified.
+		require.Equal(t, "hello", data["text"])
+		require.Equal(t, "en", data["language"])
+	})
+
+	t.Run("ordering", func(t *testing.T) {
+		prefs := map[string]public.CaptionsLanguage{
+			"originalConnA": {Language: "it", ConnID: "connA"},
+		}
+
+		// Earlier captions taking longer to translate should still be
+		// delivered first.
+		tr := &fakeTranslator{delays: map[string]time.Duration{
+			"one": 100 * time.Millisecond,
+			"two": 50 * time.Millisecond,
+		}}
+
+		mockMetrics.On("IncWebSocketEvent", "out", wsEventCaption).Times(3)
+		mockAPI.On("PublishWebSocketEvent", wsEventCaption, mock.Anything,
+			&model.WebsocketBroadcast{ConnectionId: "connA", ReliableClusterSend: true}).Run(record).Times(3)
+
+		for _, text := range []string{"one", "two", "three"} {
+			p.translateCaption(tr, "callID", prefs, map[string]interface{}{"text": text}, "en")
+		}
+
+		require.Equal(t, []string{"[it] one", "[it] two", "[it] three"}, waitSent(3))
+	})
+
+	t.Run("full queue", func(t *testing.T) {
+		prefs := map[string]public.CaptionsLanguage{
+			"originalConnA": {Language: "de", ConnID: "connA"},
+		}
+
+		tr := &fakeTranslator{
+			started: make(chan struct{}, 1),
+			release: make(chan struct{}),
+		}
+
+		mockMetrics.On("IncWebSocketEvent", "out", wsEventCaption).Times(translatorQueueSize + 1)
+		mockAPI.On("PublishWebSocketEvent", wsEventCaption, mock.Anything,
+			&model.WebsocketBroadcast{ConnectionId: "connA", ReliableClusterSend: true}).Run(record).Times(translatorQueueSize + 1)
+		mockAPI.On("LogWarn", "translation queue is full, dropping caption",
+			"origin", mock.AnythingOfType("string"), "language", "de").Run(record).Once()
+
+		// The first caption is picked up by the worker, which then gets stuck.
+		p.translateCaption(tr, "callID", prefs, map[string]interface{}{"text": "first"}, "en")
+		<-tr.started
+
+		for i := 0; i < translatorQueueSize+1; i++ {
+			p.translateCaption(tr, "callID", prefs, map[string]interface{}{"text": fmt.Sprintf("%d", i)}, "en")
+		}
+
+		require.Equal(t, []string{"translation queue is full, dropping caption"}, waitSent(1))
+		close(tr.release)
+
+		sent := waitSent(translatorQueueSize + 1)
+		require.Equal(t, "[de] first", sent[0])
+		require.Equal(t, fmt.Sprintf("[de] %d", translatorQueueSize-1), sent[translatorQueueSize])
+	})
+}
@@ -221,6 +221,10 @@ func getUserIDsFromSessions(sessions map[string]*public.CallSession) []string {
 	var userIDs []string
 	dedup := map[string]bool{}
 	for _, session := range sessions {
+		// External sessions don't map to actual users.
+		if session.IsExternal() {
+			continue
+		}
 		if !dedup[session.UserID] {
 			userIDs = append(userIDs, session.UserID)
 			dedup[session.UserID] = true
@@ -229,6 +233,16 @@ func getUserIDsFromSessions(sessions map[string]*public.CallSession) []string {
 	return userIDs
 }
 
+func countUserSessions(sessions map[string]*public.CallSession, userID string) int {
+	var count int
+	for _, session := range sessions {
+		if session.UserID == userID {
+			count++
+		}
+	}
+	return count
+}
+
 func (p *Plugin) getTranslationFunc(locale string) i18n.TranslateFunc {
 	if locale != "" {
 		return i18n.GetUserTranslations(locale)
@@ -351,4 +351,20 @@ func TestGetUserIDsFromSessions(t *testing.T) {
 			"userC",
 		}, userIDs)
 	})
+	t.Run("external sessions", func(t *testing.T) {
+		userIDs := getUserIDsFromSessions(map[string]*public.CallSession{
+			"connUserA": {
+				UserID: "userA",
+			},
+			"phoneSession": {
+				UserID: "phoneSession",
+				Type:   public.CallSessionTypePhone,
+			},
+			"guestSession": {
+				UserID: "guestSession",
+				Type:   public.CallSessionTypeGuest,
+			},
+		})
+		require.Equal(t, []string{"userA"}, userIDs)
+	})
 }
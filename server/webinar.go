@@ -0,0 +1,198 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	"github.com/mattermost/rtcd/service/rtc"
+)
+
+var errNotPresenter = errors.New("only presenters are allowed to unmute, share their screen or turn on video")
+
+// canPresent returns whether the user is allowed to unmute, share their screen
+// or turn on video. In webinars only the host and the designated presenters
+// can, everyone else attends muted.
+func (cs *callState) canPresent(userID, botID string) bool {
+	if !cs.Call.Props.Webinar {
+		return true
+	}
+
+	return userID == botID || userID == cs.Call.GetHostID() || slices.Contains(cs.Call.Props.PresenterIDs, userID)
+}
+
+// checkCanPresent enforces the webinar restrictions on the session, letting
+// the client know to revert its local state in case the action is rejected.
+// It runs on every unmute, so the cached call state is used.
+func (p *Plugin) checkCanPresent(us *session, msgType string) error {
+	state, err := p.getCachedCallState(us.channelID)
+	if err != nil {
+		return fmt.Errorf("failed to get call state: %w", err)
+	}
+	if state == nil {
+		return fmt.Errorf("no call ongoing")
+	}
+
+	if state.canPresent(us.userID, p.getBotID()) {
+		return nil
+	}
+
+	var evType string
+	switch msgType {
+	case clientMessageTypeUnmute:
+		evType = wsEventHostMute
+	case clientMessageTypeScreenOn:
+		evType = wsEventHostScreenOff
+	case clientMessageTypeVideoOn:
+		evType = wsEventHostVideoOff
+	}
+
+	if evType != "" {
+		p.publishWebSocketEvent(evType, map[string]interface{}{
+			"channel_id": us.channelID,
+			"session_id": us.originalConnID,
+		}, &WebSocketBroadcast{UserID: us.userID, ReliableClusterSend: true})
+	}
+
+	return errNotPresenter
+}
+
+// restrictAttendees mutes, stops the screen sharing and turns off the video
+// of the sessions that are no longer allowed to present. If userID is given,
+// only that user's sessions are considered. It must be called while holding
+// the call lock.
+func (p *Plugin) restrictAttendees(state *callState, userID string) {
+	botID := p.getBotID()
+	for id, s := range state.sessions {
+		if (userID != "" && s.UserID != userID) || state.canPresent(s.UserID, botID) {
+			continue
+		}
+
+		if err := p.restrictSession(state, s); err != nil {
+			p.LogError("failed to restrict session", "sessionID", id, "err", err.Error())
+		}
+	}
+}
+
+// restrictSession turns off the media of the given session on the server side
+// so that it applies even if the client doesn't comply. The client is asked
+// to do the same to keep its local state in sync.
+func (p *Plugin) restrictSession(state *callState, ust *public.CallSession) error {
+	var callChanged bool
+	data := map[string]interface{}{
+		"channel_id": state.Call.ChannelID,
+		"session_id": ust.ID,
+	}
+
+	if ust.Unmuted {
+		if err := p.sendSessionStateToRTC(state, ust, clientMessageTypeMute); err != nil {
+			return fmt.Errorf("failed to mute session: %w", err)
+		}
+		ust.Unmuted = false
+		p.publishSessionStateEvent(state, ust, wsEventUserMuted)
+
+		if ust.IsExternal() {
+			p.sendExternalEvent(ust.Type, externalEvent{
+				Type:      externalEventMute,
+				ChannelID: state.Call.ChannelID,
+				SessionID: ust.ID,
+			})
+		} else {
+			p.publishWebSocketEvent(wsEventHostMute, data, &WebSocketBroadcast{UserID: ust.UserID, ReliableClusterSend: true})
+		}
+	}
+
+	if state.Call.Props.ScreenSharingSessionID == ust.ID {
+		if err := p.sendSessionStateToRTC(state, ust, clientMessageTypeScreenOff); err != nil {
+			return fmt.Errorf("failed to stop screen sharing: %w", err)
+		}
+		state.Call.Props.ScreenSharingSessionID = ""
+		if state.Call.Props.ScreenStartAt > 0 {
+			state.Call.Stats.ScreenDuration = secondsSinceTimestamp(state.Call.Props.ScreenStartAt)
+			state.Call.Props.ScreenStartAt = 0
+		}
+		callChanged = true
+		p.publishSessionStateEvent(state, ust, wsEventUserScreenOff)
+		p.publishWebSocketEvent(wsEventHostScreenOff, data, &WebSocketBroadcast{UserID: ust.UserID, ReliableClusterSend: true})
+	}
+
+	if ust.Video {
+		if err := p.sendSessionStateToRTC(state, ust, clientMessageTypeVideoOff); err != nil {
+			return fmt.Errorf("failed to turn off video: %w", err)
+		}
+		ust.Video = false
+		if startTime := state.Call.Props.VideoStartAt[ust.ID]; startTime > 0 {
+			state.Call.Stats.VideoDuration += secondsSinceTimestamp(startTime)
+			delete(state.Call.Props.VideoStartAt, ust.ID)
+			callChanged = true
+		}
+		p.publishSessionStateEvent(state, ust, wsEventUserVideoOff)
+		p.publishWebSocketEvent(wsEventHostVideoOff, data, &WebSocketBroadcast{UserID: ust.UserID, ReliableClusterSend: true})
+	}
+
+	if err := p.store.UpdateCallSession(ust); err != nil {
+		return fmt.Errorf("failed to update call session: %w", err)
+	}
+
+	if callChanged {
+		if err := p.store.UpdateCall(&state.Call); err != nil {
+			return fmt.Errorf("failed to update call: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// sendSessionStateToRTC applies a media state change to the RTC side of the
+// given session, relaying it to the node handling the call if needed.
+func (p *Plugin) sendSessionStateToRTC(state *callState, ust *public.CallSession, msgType string) error {
+	if handlerID := state.Call.Props.NodeID; handlerID != p.nodeID {
+		return p.sendClusterMessage(clusterMessage{
+			ConnID:        ust.ID,
+			UserID:        ust.UserID,
+			ChannelID:     state.Call.ChannelID,
+			CallID:        state.Call.ID,
+			SenderID:      p.nodeID,
+			ClientMessage: clientMessage{Type: msgType},
+		}, clusterMessageTypeUserState, handlerID)
+	}
+
+	rtcMsgType, err := getRTCUserStateMessageType(msgType)
+	if err != nil {
+		return err
+	}
+
+	return p.sendRTCMessage(rtc.Message{
+		SessionID: ust.ID,
+		Type:      rtcMsgType,
+	}, state.Call.ID)
+}
+
+func (p *Plugin) publishSessionStateEvent(state *callState, ust *public.CallSession, evType string) {
+	p.publishWebSocketEvent(evType, map[string]interface{}{
+		"userID":     ust.UserID,
+		"session_id": ust.ID,
+	}, &WebSocketBroadcast{
+		ChannelID:           state.Call.ChannelID,
+		ReliableClusterSend: true,
+		UserIDs:             getUserIDsFromSessions(state.sessions),
+	})
+}
+
+func (p *Plugin) publishWebinarState(state *callState) {
+	p.publishWebSocketEvent(wsEventCallWebinarChanged, map[string]interface{}{
+		"call_id":       state.Call.ID,
+		"channel_id":    state.Call.ChannelID,
+		"webinar":       state.Call.Props.Webinar,
+		"presenter_ids": state.Call.Props.PresenterIDs,
+	}, &WebSocketBroadcast{
+		ChannelID:           state.Call.ChannelID,
+		ReliableClusterSend: true,
+		UserIDs:             getUserIDsFromSessions(state.sessions),
+	})
+}
@@ -0,0 +1,37 @@
+// Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-plugin-calls/server/public"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallStateCanPresent(t *testing.T) {
+	cs := &callState{
+		Call: public.Call{
+			Props: public.CallProps{
+				Hosts:        []string{"hostID"},
+				PresenterIDs: []string{"presenterID"},
+			},
+		},
+	}
+
+	t.Run("not a webinar", func(t *testing.T) {
+		for _, userID := range []string{"hostID", "presenterID", "attendeeID", "botID"} {
+			require.True(t, cs.canPresent(userID, "botID"), userID)
+		}
+	})
+
+	t.Run("webinar", func(t *testing.T) {
+		cs.Call.Props.Webinar = true
+		require.True(t, cs.canPresent("hostID", "botID"))
+		require.True(t, cs.canPresent("presenterID", "botID"))
+		require.True(t, cs.canPresent("botID", "botID"))
+		require.False(t, cs.canPresent("attendeeID", "botID"))
+	})
+}
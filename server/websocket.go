@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -53,6 +54,14 @@ const (
 	wsEventHostScreenOff             = "host_screen_off"
 	wsEventHostLowerHand             = "host_lower_hand"
 	wsEventHostRemoved               = "host_removed"
+	wsEventHostVideoOff              = "host_video_off"
+	wsEventCallSpotlightChanged      = "call_spotlight_changed"
+	wsEventCallWebinarChanged        = "call_webinar_changed"
+	wsEventCallInvite                = "call_invite"
+	wsEventCallInviteResponse        = "call_invite_response"
+	wsEventCallMissed                = "call_missed"
+	wsEventCallTransferred           = "call_transferred"
+	wsEventJobServiceAvailability    = "job_service_availability"
 
 	wsReconnectionTimeout = 10 * time.Second
 )
@@ -87,6 +96,10 @@ type CallsClientJoinData struct {
 	// a call (e.g. recording, transcription). It's a parameter reserved to the
 	// Calls bot only.
 	JobID string
+
+	// TransferSessionID is the id of the user's existing session the
+	// connection is taking over when moving the call to a different device.
+	TransferSessionID string
 }
 
 type callsJoinData struct {
@@ -99,6 +112,7 @@ type WebSocketBroadcast struct {
 	ChannelID           string
 	UserID              string
 	ConnectionID        string
+	OmitConnectionID    string
 	ReliableClusterSend bool
 	OmitUsers           map[string]bool
 	UserIDs             []string
@@ -113,6 +127,7 @@ func (wsb *WebSocketBroadcast) ToModel() *model.WebsocketBroadcast {
 		ChannelId:           wsb.ChannelID,
 		UserId:              wsb.UserID,
 		ConnectionId:        wsb.ConnectionID,
+		OmitConnectionId:    wsb.OmitConnectionID,
 		ReliableClusterSend: wsb.ReliableClusterSend,
 		OmitUsers:           wsb.OmitUsers,
 	}
@@ -190,7 +205,9 @@ func (p *Plugin) handleClientMessageTypeScreen(us *session, msg clientMessage, h
 			state.Call.Stats.HasUsedScreenShare = true
 		}
 	} else {
-		if state.Call.Props.ScreenSharingSessionID != us.originalConnID {
+		// The screen sharing may have been stopped on the server side already
+		// (e.g. when restricting webinar attendees).
+		if id := state.Call.Props.ScreenSharingSessionID; id != "" && id != us.originalConnID {
 			return fmt.Errorf("cannot stop screen sharing, someone else is sharing already: connID=%s", state.Call.Props.ScreenSharingSessionID)
 		}
 		state.Call.Props.ScreenSharingSessionID = ""
@@ -316,6 +333,12 @@ func (p *Plugin) handleClientMsg(us *session, msg clientMessage, handlerID strin
 			}
 		}
 	case clientMessageTypeMute, clientMessageTypeUnmute:
+		if msg.Type == clientMessageTypeUnmute {
+			if err := p.checkCanPresent(us, msg.Type); err != nil {
+				return err
+			}
+		}
+
 		if handlerID != p.nodeID {
 			// need to relay track event.
 			if err := p.sendClusterMessage(clusterMessage{
@@ -376,10 +399,22 @@ func (p *Plugin) handleClientMsg(us *session, msg clientMessage, handlerID strin
 			UserIDs:             getUserIDsFromSessions(state.sessions),
 		})
 	case clientMessageTypeScreenOn, clientMessageTypeScreenOff:
+		if msg.Type == clientMessageTypeScreenOn {
+			if err := p.checkCanPresent(us, msg.Type); err != nil {
+				return err
+			}
+		}
+
 		if err := p.handleClientMessageTypeScreen(us, msg, handlerID); err != nil {
 			return err
 		}
 	case clientMessageTypeVideoOn, clientMessageTypeVideoOff:
+		if msg.Type == clientMessageTypeVideoOn {
+			if err := p.checkCanPresent(us, msg.Type); err != nil {
+				return err
+			}
+		}
+
 		if handlerID != p.nodeID {
 			// need to relay track event.
 			if err := p.sendClusterMessage(clusterMessage{
@@ -529,6 +564,10 @@ func (p *Plugin) handleClientMsg(us *session, msg clientMessage, handlerID strin
 			ChannelID: us.channelID,
 			UserIDs:   getUserIDsFromSessions(sessions),
 		})
+	case clientMessageTypeCaptionsLanguage:
+		if err := p.handleCaptionsLanguageMessage(us, msg); err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("invalid client message type %q", msg.Type)
 	}
@@ -679,10 +718,7 @@ func (p *Plugin) wsWriter() {
 				continue
 			}
 
-			p.publishWebSocketEvent(wsEventSignal, map[string]interface{}{
-				"data":   string(msg.Data),
-				"connID": msg.SessionID,
-			}, &WebSocketBroadcast{ConnectionID: us.connID, ReliableClusterSend: true})
+			p.relaySignal(us, msg.SessionID, msg.Data)
 		case <-p.stopCh:
 			return
 		}
@@ -782,7 +818,14 @@ func (p *Plugin) handleJoin(userID, connID, authSessionID string, joinData calls
 	addSessionToCall := func(state *callState) *callState {
 		var err error
 
-		state, err = p.addUserSession(state, callsEnabled, userID, connID, channelID, joinData.JobID, channel.Type)
+		// The session to transfer may have left in the meantime.
+		if joinData.TransferSessionID != "" {
+			_, err = state.getSessionToTransfer(userID, joinData.TransferSessionID)
+		}
+
+		if err == nil {
+			state, err = p.addUserSession(state, callsEnabled, &public.CallSession{ID: connID, UserID: userID}, channelID, joinData.JobID, channel.Type)
+		}
 		if err != nil {
 			p.LogError("failed to add user session", "err", err.Error())
 			p.publishWebSocketEvent(wsEventError, map[string]interface{}{
@@ -834,6 +877,29 @@ func (p *Plugin) handleJoin(userID, connID, authSessionID string, joinData calls
 			"remoteAddr", joinData.remoteAddr, "xForwardedFor", joinData.xff,
 		)
 
+		if userID != p.getBotID() {
+			p.queueInCallStatusUpdate(inCallStatusUpdate{
+				userID:    userID,
+				channelID: channelID,
+				sessionID: connID,
+				join:      true,
+			})
+		}
+
+		joinResp := map[string]interface{}{
+			"connID": connID,
+		}
+		if joinData.TransferSessionID != "" {
+			if err := p.transferUserSession(state, userID, joinData.TransferSessionID, connID); err != nil {
+				p.LogError("failed to transfer session", "err", err.Error(), "userID", userID,
+					"connID", connID, "transferSessionID", joinData.TransferSessionID)
+			} else {
+				// Lets the new client know it should unmute once it has
+				// acquired the microphone.
+				joinResp["unmuted"] = state.sessions[connID].Unmuted
+			}
+		}
+
 		handlerID := state.Call.Props.NodeID
 		p.LogDebug("got handlerID", "handlerID", handlerID)
 
@@ -926,9 +992,7 @@ func (p *Plugin) handleJoin(userID, connID, authSessionID string, joinData calls
 		}
 
 		// send successful join response
-		p.publishWebSocketEvent(wsEventJoin, map[string]interface{}{
-			"connID": connID,
-		}, &WebSocketBroadcast{ConnectionID: connID, ReliableClusterSend: true})
+		p.publishWebSocketEvent(wsEventJoin, joinResp, &WebSocketBroadcast{ConnectionID: connID, ReliableClusterSend: true})
 
 		p.publishWebSocketEvent(wsEventUserJoined, map[string]interface{}{
 			"user_id":    userID,
@@ -946,7 +1010,9 @@ func (p *Plugin) handleJoin(userID, connID, authSessionID string, joinData calls
 			})
 		}
 
-		clientStateData, err := json.Marshal(state.getClientState(p.getBotID(), userID))
+		clientState := state.getClientState(p.getBotID(), userID)
+		clientState.Captions = p.getRecentCaptions(state.Call.ID)
+		clientStateData, err := json.Marshal(clientState)
 		if err != nil {
 			p.LogError("failed to marshal client state", "err", err.Error())
 		} else {
@@ -1126,6 +1192,12 @@ func (p *Plugin) handleReconnect(userID, connID, channelID, originalConnID, prev
 		p.LogError(err.Error())
 	}
 
+	if _, ok := state.Call.Props.CaptionsLanguages[originalConnID]; ok {
+		if err := p.updateCaptionsLanguageConnID(channelID, originalConnID, connID); err != nil {
+			p.LogError("failed to update captions language connection", "err", err.Error(), "originalConnID", originalConnID)
+		}
+	}
+
 	if p.rtcdManager != nil {
 		msg := rtcd.ClientMessage{
 			Type: rtcd.ClientMessageReconnect,
@@ -1211,7 +1283,7 @@ func (p *Plugin) WebSocketMessageHasBeenPosted(connID, userID string, req *model
 		// Only a few events don't require a user session to exist. For anything else
 		// we should return.
 		switch msg.Type {
-		case clientMessageTypeJoin, clientMessageTypeLeave, clientMessageTypeReconnect, clientMessageTypeCallState:
+		case clientMessageTypeJoin, clientMessageTypeLeave, clientMessageTypeReconnect, clientMessageTypeTransfer, clientMessageTypeCallState:
 		default:
 			return
 		}
@@ -1272,6 +1344,45 @@ func (p *Plugin) WebSocketMessageHasBeenPosted(connID, userID string, req *model
 			}
 		}()
 		return
+	case clientMessageTypeTransfer:
+		channelID, _ := req.Data["channelID"].(string)
+		if channelID == "" {
+			p.LogError("missing channelID")
+			return
+		}
+		sessionID, _ := req.Data["sessionID"].(string)
+		if sessionID == "" {
+			p.LogError("missing sessionID")
+			return
+		}
+
+		av1Support, _ := req.Data["av1Support"].(bool)
+		dcSignaling, _ := req.Data["dcSignaling"].(bool)
+
+		remoteAddr, _ := req.Data[model.WebSocketRemoteAddr].(string)
+		xff, _ := req.Data[model.WebSocketXForwardedFor].(string)
+
+		joinData := callsJoinData{
+			CallsClientJoinData{
+				ChannelID:         channelID,
+				AV1Support:        av1Support,
+				DCSignaling:       dcSignaling,
+				TransferSessionID: sessionID,
+			},
+			remoteAddr,
+			xff,
+		}
+
+		go func() {
+			if err := p.handleTransfer(userID, connID, req.Session.Id, joinData); err != nil {
+				p.LogWarn(err.Error(), "userID", userID, "connID", connID, "channelID", channelID, "sessionID", sessionID)
+				p.publishWebSocketEvent(wsEventError, map[string]interface{}{
+					"data":   err.Error(),
+					"connID": connID,
+				}, &WebSocketBroadcast{ConnectionID: connID, ReliableClusterSend: true})
+			}
+		}()
+		return
 	case clientMessageTypeReconnect:
 		channelID, _ := req.Data["channelID"].(string)
 		if channelID == "" {
@@ -1352,6 +1463,13 @@ func (p *Plugin) WebSocketMessageHasBeenPosted(connID, userID string, req *model
 			return
 		}
 		msg.Data = []byte(msgData)
+	case clientMessageTypeCaptionsLanguage:
+		msgData, ok := req.Data["data"].(string)
+		if !ok {
+			p.LogError("invalid or missing captions language data")
+			return
+		}
+		msg.Data = []byte(msgData)
 	case clientMessageTypeCaption:
 		// Sent from the transcriber.
 		p.metrics.IncWebSocketEvent("in", msg.Type)
@@ -1485,26 +1603,89 @@ func (p *Plugin) handleBotWSReconnect(connID, prevConnID, originalConnID, channe
 }
 
 func (p *Plugin) handleCaptionMessage(callID, channelID, captionFromSessionID, text string, newAudioLenMs float64) error {
-	sessions, err := p.store.GetCallSessions(callID, db.GetCallSessionOpts{})
+	// Captions come in at a high rate so the call state is read from cache.
+	state, err := p.getCachedCallState(channelID)
 	if err != nil {
-		return fmt.Errorf("failed to get call sessions: %w", err)
+		return fmt.Errorf("failed to get call state: %w", err)
+	}
+	captionSession, ok := state.getSession(callID, captionFromSessionID)
+	if !ok {
+		// The session may have joined after the state was cached.
+		state, err = p.refreshCachedCallState(channelID)
+		if err != nil {
+			return fmt.Errorf("failed to get call state: %w", err)
+		}
+		captionSession, ok = state.getSession(callID, captionFromSessionID)
 	}
-
-	captionSession, ok := sessions[captionFromSessionID]
 	if !ok {
 		return fmt.Errorf("user session for caption missing from call")
 	}
 
-	p.publishWebSocketEvent(wsEventCaption, map[string]interface{}{
+	sourceLang := p.getConfiguration().LiveCaptionsLanguage
+	// The language may have been chosen when starting the job.
+	if state.LiveCaptions != nil && state.LiveCaptions.Props.Language != "" {
+		sourceLang = state.LiveCaptions.Props.Language
+	}
+
+	caption := &public.CallCaption{
+		ID:        model.NewId(),
+		CallID:    callID,
+		SessionID: captionSession.ID,
+		UserID:    captionSession.UserID,
+		CreateAt:  model.GetMillis(),
+		Text:      text,
+		Language:  sourceLang,
+	}
+
+	// Persisting is best effort, captions should keep flowing regardless.
+	if err := p.storeCaption(caption); err != nil {
+		p.LogError("failed to store caption", "callID", callID, "err", err.Error())
+	}
+
+	data := map[string]interface{}{
 		"channel_id": channelID,
-		"user_id":    captionSession.UserID,
-		"session_id": captionSession.ID,
-		"text":       text,
-	}, &WebSocketBroadcast{
-		ChannelID:           channelID,
-		ReliableClusterSend: true,
-		UserIDs:             getUserIDsFromSessions(sessions),
-	})
+		"user_id":    caption.UserID,
+		"session_id": caption.SessionID,
+		"text":       caption.Text,
+		"language":   caption.Language,
+		"create_at":  caption.CreateAt,
+	}
+
+	// Translations are sent only to the connections that requested them, in
+	// place of the original caption.
+	var translatedConnIDs map[string][]string
+	if translator := p.getTranslator(); translator != nil && len(state.Props.CaptionsLanguages) > 0 {
+		p.translateCaption(translator, callID, state.Props.CaptionsLanguages, maps.Clone(data), sourceLang)
+		translatedConnIDs = getTranslatedConnIDs(state, sourceLang)
+	}
+
+	var userIDs []string
+	for _, userID := range getUserIDsFromSessions(state.sessions) {
+		connIDs := translatedConnIDs[userID]
+		if len(connIDs) == 0 {
+			userIDs = append(userIDs, userID)
+			continue
+		}
+		if len(connIDs) >= countUserSessions(state.sessions, userID) {
+			continue
+		}
+		// Only one connection can be omitted from a broadcast. Users having
+		// more than one translated connection alongside other ones are
+		// uncommon enough that we accept they get some captions twice.
+		p.publishWebSocketEvent(wsEventCaption, data, &WebSocketBroadcast{
+			UserID:              userID,
+			OmitConnectionID:    connIDs[0],
+			ReliableClusterSend: true,
+		})
+	}
+
+	if len(userIDs) > 0 {
+		p.publishWebSocketEvent(wsEventCaption, data, &WebSocketBroadcast{
+			ChannelID:           channelID,
+			ReliableClusterSend: true,
+			UserIDs:             userIDs,
+		})
+	}
 
 	p.metrics.ObserveLiveCaptionsAudioLen(newAudioLenMs)
 
@@ -493,6 +493,7 @@ func TestWebSocketBroadcastToModel(t *testing.T) {
 			ChannelID:           "channelID",
 			UserID:              "userID",
 			ConnectionID:        "connID",
+			OmitConnectionID:    "omitConnID",
 			ReliableClusterSend: true,
 			OmitUsers: map[string]bool{
 				"userA": true,
@@ -507,6 +508,7 @@ func TestWebSocketBroadcastToModel(t *testing.T) {
 			ChannelId:           wsb.ChannelID,
 			UserId:              wsb.UserID,
 			ConnectionId:        wsb.ConnectionID,
+			OmitConnectionId:    wsb.OmitConnectionID,
 			ReliableClusterSend: wsb.ReliableClusterSend,
 			OmitUsers:           wsb.OmitUsers,
 		}, wsb.ToModel())
@@ -742,7 +744,7 @@ func TestWebSocketMessageHasBeenPostedUTF8Validation(t *testing.T) {
 	t.Run("valid message types recognized", func(t *testing.T) {
 		// Test that isValidClientMessageType recognizes all defined message types
 		validTypes := []string{
-			"join", "leave", "reconnect", "sdp", "ice",
+			"join", "leave", "reconnect", "transfer", "sdp", "ice",
 			"mute", "unmute", "voice_on", "voice_off",
 			"screen_on", "screen_off", "raise_hand", "unraise_hand",
 			"react", "caption", "metric", "call_state", "ping",
@@ -786,6 +788,7 @@ func TestHandleJoin(t *testing.T) {
 		sessions:               map[string]*session{},
 		addSessionsBatchers:    map[string]*batching.Batcher{},
 		removeSessionsBatchers: map[string]*batching.Batcher{},
+		inCallStatusCh:         make(chan inCallStatusUpdate, inCallStatusQueueSize),
 	}
 
 	p.licenseChecker = enterprise.NewLicenseChecker(p.API)
@@ -938,6 +941,10 @@ func TestHandleJoin(t *testing.T) {
 		mockAPI.On("PublishWebSocketEvent", wsEventUserLeft, map[string]any{"session_id": connID, "user_id": userID},
 			&model.WebsocketBroadcast{ChannelId: channelID, ReliableClusterSend: true}).Once()
 
+		mockAPI.On("GetChannel", channelID).Return(&model.Channel{
+			Id:   channelID,
+			Type: model.ChannelTypeOpen,
+		}, nil).Once()
 		mockAPI.On("UpdatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{Id: postID}, nil).Once()
 
 		// Call unlock
@@ -1077,6 +1084,10 @@ func TestHandleJoin(t *testing.T) {
 		mockAPI.On("PublishWebSocketEvent", wsEventUserLeft, mock.Anything,
 			&model.WebsocketBroadcast{ChannelId: channelID, ReliableClusterSend: true}).Times(10)
 
+		mockAPI.On("GetChannel", channelID).Return(&model.Channel{
+			Id:   channelID,
+			Type: model.ChannelTypeOpen,
+		}, nil).Once()
 		mockAPI.On("UpdatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{Id: postID}, nil).Once()
 
 		// Call unlock
@@ -1405,6 +1416,10 @@ func TestHandleJoin(t *testing.T) {
 		// Call unlock
 		mockAPI.On("KVDelete", "mutex_call_"+channelID).Return(nil).Once()
 
+		mockAPI.On("GetChannel", channelID).Return(&model.Channel{
+			Id:   channelID,
+			Type: model.ChannelTypeOpen,
+		}, nil).Once()
 		mockAPI.On("UpdatePost", mock.AnythingOfType("*model.Post")).Return(&model.Post{Id: postID}, nil).Once()
 
 		mockAPI.On("LogWarn", "The number of active call sessions is high. Consider deploying a dedicated RTCD service.", mock.Anything, mock.Anything)
@@ -1569,4 +1584,54 @@ func TestHandleClientMsgVideoStats(t *testing.T) {
 		require.NotContains(t, state.Call.Props.VideoStartAt, connID, "VideoStartAt entry must be cleared on video off")
 		require.False(t, state.sessions[connID].Video, "session video flag must be cleared")
 	})
+
+	t.Run("rejected for webinar attendees", func(t *testing.T) {
+		defer ResetTestStore(t, p.store)
+
+		channelID, callID, connID, userID := setupCall(t)
+
+		call, err := p.store.GetActiveCallByChannelID(channelID, db.GetCallOpts{})
+		require.NoError(t, err)
+		call.Props.Hosts = []string{model.NewId()}
+		call.Props.Webinar = true
+		require.NoError(t, p.store.UpdateCall(call))
+		p.invalidateCachedCallState(channelID)
+
+		us := &session{
+			userID:         userID,
+			channelID:      channelID,
+			connID:         connID,
+			originalConnID: connID,
+			callID:         callID,
+		}
+
+		for msgType, evType := range map[string]string{
+			clientMessageTypeUnmute:   wsEventHostMute,
+			clientMessageTypeScreenOn: wsEventHostScreenOff,
+			clientMessageTypeVideoOn:  wsEventHostVideoOff,
+		} {
+			err = p.handleClientMsg(us, clientMessage{Type: msgType}, handlerID)
+			require.ErrorIs(t, err, errNotPresenter, msgType)
+
+			// The client is asked to revert its local state.
+			mockAPI.AssertCalled(t, "PublishWebSocketEvent", evType, map[string]any{
+				"channel_id": channelID,
+				"session_id": connID,
+			}, &model.WebsocketBroadcast{UserId: userID, ReliableClusterSend: true})
+		}
+
+		state, err := p.getCallState(channelID, false)
+		require.NoError(t, err)
+		require.False(t, state.sessions[connID].Unmuted)
+		require.Empty(t, state.Call.Props.ScreenSharingSessionID)
+		require.False(t, state.sessions[connID].Video)
+
+		// Presenters are allowed.
+		call.Props.PresenterIDs = []string{userID}
+		require.NoError(t, p.store.UpdateCall(call))
+		p.invalidateCachedCallState(channelID)
+
+		err = p.handleClientMsg(us, clientMessage{Type: clientMessageTypeVideoOn}, handlerID)
+		require.NoError(t, err)
+	})
 }
@@ -69,6 +69,7 @@ import {
     handleHostMute,
     handleHostRemoved,
     handleHostScreenOff,
+    handleJobServiceAvailability,
     handleUserDismissedNotification,
     handleUserJoined,
     handleUserLeft,
@@ -86,7 +87,7 @@ import {
     handleUserVoiceOn,
 } from 'plugin/websocket_handlers';
 import {Reducer} from 'redux';
-import {CallActions, CallsClientConfig, CurrentCallData, CurrentCallDataDefault} from 'src/types/types';
+import {CallActions, CallsClientConfig, CurrentCallData, CurrentCallDataDefault, JobServiceAvailabilityData} from 'src/types/types';
 
 import {
     getCallID,
@@ -321,6 +322,9 @@ export default async function init(cfg: InitConfig) {
         case `custom_${pluginId}_user_video_off`:
             handleUserVideoOff(store, ev as WebSocketMessage<UserVideoOnOffData>);
             break;
+        case `custom_${pluginId}_job_service_availability`:
+            handleJobServiceAvailability(store, ev as WebSocketMessage<JobServiceAvailabilityData>);
+            break;
         case 'user_removed':
             handleUserRemovedFromChannel(store, ev as WebSocketMessage<UserRemovedData>);
             break;
@@ -54,6 +54,7 @@ export const RECORDINGS_ENABLED = pluginId + '_recordings_enabled';
 export const TRANSCRIPTIONS_ENABLED = pluginId + '_transcriptions_enabled';
 export const LIVE_CAPTIONS_ENABLED = pluginId + '_live_captions_enabled';
 export const RTCD_ENABLED = pluginId + '_rtcd_enabled';
+export const JOB_SERVICE_AVAILABLE = pluginId + '_job_service_available';
 export const TRANSCRIBE_API = pluginId + '_transcribe_api';
 export const RECEIVED_CHANNEL_STATE = pluginId + 'received_channel_state';
 export const RECEIVED_CALLS_USER_PREFERENCES = pluginId + '_received_calls_user_preferences';
@@ -159,6 +159,13 @@ export const getCallActive = async (channelID: string) => {
     }
 };
 
+export const getRecordingLink = (channelID: string, fileID: string) => {
+    return RestClient.fetch<{ url: string, expires_at: number }>(
+        `${getPluginPath()}/calls/${channelID}/recordings/${fileID}/link`,
+        {method: 'get'},
+    );
+};
+
 export const setRecordingsEnabled = (enabled: boolean) => (dispatch: Dispatch) => {
     dispatch({
         type: RECORDINGS_ENABLED,
@@ -254,6 +261,18 @@ export const requestOnPremTrialLicense = async (users: number, termsAccepted: bo
     }
 };
 
+// callBackMissedCall registers a call back on a missed call for the current
+// user. The next call they start in the channel only rings the original caller.
+export const callBackMissedCall = (channelID: string, postID: string) => {
+    return RestClient.fetch<{ channel_id: string, caller_id: string }>(
+        `${getPluginPath()}/calls/${channelID}/call-back`,
+        {
+            method: 'post',
+            body: JSON.stringify({post_id: postID}),
+        },
+    );
+};
+
 export const endCall = (channelID: string) => {
     return RestClient.fetch(
         `${getPluginPath()}/calls/${channelID}/host/end`,
@@ -284,10 +303,13 @@ export function prefetchThread(postId: string) {
     };
 }
 
-export const startCallRecording = (callID: string) => (dispatch: Dispatch) => {
+export const startCallRecording = (callID: string, transcriptionOptions?: Record<string, string>) => (dispatch: Dispatch) => {
     RestClient.fetch(
         `${getPluginPath()}/calls/${callID}/recording/start`,
-        {method: 'post'},
+        {
+            method: 'post',
+            body: transcriptionOptions ? JSON.stringify({transcription: transcriptionOptions}) : undefined,
+        },
     ).catch((err) => {
         dispatch({
             type: CALL_RECORDING_STATE,
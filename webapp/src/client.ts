@@ -521,12 +521,16 @@ export default class CallsClient extends EventEmitter {
             }
         });
 
-        ws.on('join', async () => {
+        ws.on('join', async (data?: {unmuted?: boolean}) => {
             if (this.closed) {
                 return;
             }
             logDebug('join ack received, initializing connection');
 
+            // A session transferred from another device keeps its unmuted state
+            // so we pick up the microphone as soon as we are connected.
+            const shouldUnmute = Boolean(data?.unmuted);
+
             const peer = new RTCPeer({
                 iceServers: this.config.iceServers || [],
                 logger: {
@@ -634,6 +638,10 @@ export default class CallsClient extends EventEmitter {
                 this.emit('connect');
                 this.rtcMonitor?.start();
                 this.connected = true;
+
+                if (shouldUnmute) {
+                    this.unmute();
+                }
             });
 
             peer.on('close', () => {
@@ -78,6 +78,7 @@ const props: Props = {
     startCallRecording: jest.fn(),
     stopCallRecording: jest.fn(),
     recordingsEnabled: false,
+    jobServiceAvailable: true,
     openModal: jest.fn(),
     openCallsUserSettings: jest.fn(),
     enableVideo: false,
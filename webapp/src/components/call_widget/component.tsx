@@ -139,6 +139,7 @@ interface Props {
     startCallRecording: (channelID: string) => void,
     stopCallRecording: (channelID: string) => void,
     recordingsEnabled: boolean,
+    jobServiceAvailable: boolean,
     openModal: <P>(modalData: ModalData<P>) => void;
     openCallsUserSettings: () => void;
     enableVideo: boolean,
@@ -845,7 +846,7 @@ export default class CallWidget extends React.PureComponent<Props, State> {
                     },
                 });
             }
-        } else {
+        } else if (this.props.jobServiceAvailable) {
             await this.props.startCallRecording(this.props.channel.id);
         }
 
@@ -1609,6 +1610,7 @@ export default class CallWidget extends React.PureComponent<Props, State> {
         const RecordIcon = this.props.isRecording ? RecordSquareIcon : RecordCircleIcon;
 
         const recordingActionLabel = this.props.isRecording ? formatMessage({defaultMessage: 'Stop recording'}) : formatMessage({defaultMessage: 'Record call'});
+        const isDisabled = !this.props.isRecording && !this.props.jobServiceAvailable;
 
         return (
             <React.Fragment>
@@ -1623,7 +1625,9 @@ export default class CallWidget extends React.PureComponent<Props, State> {
                         style={{
                             display: 'flex',
                             flexDirection: 'column',
+                            color: isDisabled ? 'rgba(var(--center-channel-color-rgb), 0.32)' : '',
                         }}
+                        disabled={isDisabled}
                         onClick={() => this.onRecordToggle()}
                     >
                         <div
@@ -1642,6 +1646,20 @@ export default class CallWidget extends React.PureComponent<Props, State> {
                             <span>{recordingActionLabel}</span>
                         </div>
 
+                        {isDisabled &&
+                            <span
+                                style={{
+                                    color: 'rgba(var(--center-channel-color-rgb), 0.32)',
+                                    fontSize: '12px',
+                                    width: '100%',
+                                    lineHeight: '16px',
+                                    whiteSpace: 'initial',
+                                }}
+                            >
+                                {formatMessage({defaultMessage: 'Recording is temporarily unavailable'})}
+                            </span>
+                        }
+
                     </button>
                 </li>
             </React.Fragment>
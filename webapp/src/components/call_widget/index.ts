@@ -28,6 +28,7 @@ import {
     hostControlNoticesForCurrentCall,
     hostIDForCurrentCall,
     isRecordingInCurrentCall,
+    jobServiceAvailable,
     profilesInCurrentCallMap,
     recentlyJoinedUsersInCurrentCall,
     recordingForCurrentCall,
@@ -97,6 +98,7 @@ const mapStateToProps = (state: GlobalState) => {
         clientConnecting: clientConnecting(state),
         callThreadID,
         recordingsEnabled: recordingsEnabled(state),
+        jobServiceAvailable: jobServiceAvailable(state),
         enableVideo: callsConfig(state).EnableVideo && isDMChannel(channel),
         connectedDMUser,
         otherSessions: sessionsForOtherUsersInCall(state),
@@ -6,11 +6,13 @@ import {GlobalState} from '@mattermost/types/store';
 import {UserProfile} from '@mattermost/types/users';
 import {DateTime, Duration as LuxonDuration} from 'luxon';
 import {getChannel} from 'mattermost-redux/selectors/entities/channels';
+import {getCurrentUserId} from 'mattermost-redux/selectors/entities/common';
 import {getUser, isCurrentUserSystemAdmin} from 'mattermost-redux/selectors/entities/users';
 import React, {useCallback} from 'react';
 import {OverlayTrigger, Tooltip} from 'react-bootstrap';
 import {useIntl} from 'react-intl';
 import {useSelector} from 'react-redux';
+import {callBackMissedCall} from 'src/actions';
 import ConnectedProfiles from 'src/components/connected_profiles';
 import DotMenu, {DotMenuButton} from 'src/components/dot_menu/dot_menu';
 import ActiveCallIcon from 'src/components/icons/active_call_icon';
@@ -21,6 +23,7 @@ import {useDismissJoin} from 'src/components/incoming_calls/hooks';
 import {LeaveCallMenu} from 'src/components/leave_call_menu';
 import {Header, SubHeader} from 'src/components/shared';
 import Timestamp from 'src/components/timestamp';
+import {logErr} from 'src/log';
 import {idForCallInChannel} from 'src/selectors';
 import {
     callStartedTimestampFn,
@@ -68,6 +71,7 @@ const PostType = ({
     const [, onJoin] = useDismissJoin(post.channel_id, callID);
     const channel = useSelector((state: GlobalState) => getChannel(state, post.channel_id));
     const isAdmin = useSelector(isCurrentUserSystemAdmin);
+    const currentUserID = useSelector(getCurrentUserId);
 
     const timestampFn = useCallback(() => {
         return callStartedTimestampFn(intl, callProps.start_at);
@@ -84,6 +88,18 @@ const PostType = ({
         }
     };
 
+    // Calling back first lets the server know that only the original caller
+    // should be rung, then the call is started as usual.
+    const onCallBackButtonClick = async () => {
+        try {
+            await callBackMissedCall(post.channel_id, post.id);
+        } catch (err) {
+            logErr('failed to call back', err);
+            return;
+        }
+        window.postMessage({type: 'connectCall', channelID: post.channel_id}, window.origin);
+    };
+
     const recordings = Object.values(callProps.recordings).filter(job => Boolean(job.file_id)).map(job => job.file_id);
     const transcriptions = Object.values(callProps.transcriptions).filter(job => Boolean(job.file_id)).map(job => job.file_id);
 
@@ -175,6 +191,8 @@ const PostType = ({
     const compactTitle = compactDisplay && !isRHS ? <br/> : <></>;
     const title = callProps.title ? <h3 className='markdown__heading'>{callProps.title}</h3> : compactTitle;
     const callActive = callProps.end_at === 0;
+    const missed = post.props?.missed === true;
+    const canCallBack = !callActive && missed && !callID && post.user_id !== currentUserID;
     const inCall = connectedID === post.channel_id;
     const iconAndText = (
         <>
@@ -239,14 +257,17 @@ const PostType = ({
                                 {callActive &&
                                     formatMessage({defaultMessage: 'Call started'})
                                 }
-                                {!callActive &&
+                                {!callActive && !missed &&
                                     formatMessage({defaultMessage: 'Call ended'})
                                 }
+                                {!callActive && missed &&
+                                    formatMessage({defaultMessage: 'Missed call'})
+                                }
                             </Message>
                             <SubMessage>{subMessage}</SubMessage>
                         </MessageWrapper>
                     </Left>
-                    { (recordings.length > 0 || callActive) && <RowDivider/> }
+                    { (recordings.length > 0 || callActive || canCallBack) && <RowDivider/> }
                     <Right>
                         {callActive &&
                             <>
@@ -262,6 +283,15 @@ const PostType = ({
                                 {button}
                             </>
                         }
+                        {canCallBack &&
+                            <JoinButton onClick={onCallBackButtonClick}>
+                                <CallIcon
+                                    fill='var(--center-channel-bg)'
+                                    style={{width: '16px', height: '16px'}}
+                                />
+                                <ButtonText>{formatMessage({defaultMessage: 'Call back'})}</ButtonText>
+                            </JoinButton>
+                        }
                         {recordings.length > 0 && recordingsSubMessage}
                         {transcriptions.length > 0 && transcriptionsSubMessage}
                     </Right>
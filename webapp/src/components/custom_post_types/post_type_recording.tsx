@@ -1,19 +1,65 @@
 // Copyright (c) 2020-present Mattermost, Inc. All Rights Reserved.
 // See LICENSE.txt for license information.
 
-import React from 'react';
+import React, {useState} from 'react';
 import {FormattedMessage} from 'react-intl';
 import {useSelector} from 'react-redux';
+import {getRecordingLink} from 'src/actions';
+import {logErr} from 'src/log';
 import {transcriptionsEnabled} from 'src/selectors';
+import styled from 'styled-components';
 
-export const PostTypeRecording = () => {
+interface Props {
+    post: {
+        id: string;
+        channel_id: string;
+        props?: Record<string, any>;
+    };
+}
+
+export const PostTypeRecording = (props: Props) => {
     const hasTranscriptions = useSelector(transcriptionsEnabled);
+    const [linkError, setLinkError] = useState(false);
 
     const msg = hasTranscriptions ? <FormattedMessage defaultMessage={'Here\'s the call recording. Transcription is processing and will be posted when ready.'}/> : <FormattedMessage defaultMessage={'Here\'s the call recording'}/>;
 
+    // Recordings stored externally are not attached to the post, so we need to
+    // request a (time-limited) link to download them.
+    const recordingFile = props.post?.props?.recording_file;
+    const onDownload = async () => {
+        try {
+            setLinkError(false);
+            const res = await getRecordingLink(props.post.channel_id, recordingFile.id);
+            window.open(res.url, '_blank', 'noopener,noreferrer');
+        } catch (err) {
+            logErr(err);
+            setLinkError(true);
+        }
+    };
+
     return (
         <>
             {msg}
+            {typeof recordingFile?.id === 'string' &&
+                <div>
+                    <DownloadButton
+                        className='btn btn-tertiary btn-sm'
+                        onClick={onDownload}
+                    >
+                        <FormattedMessage
+                            defaultMessage={'Download {filename}'}
+                            values={{filename: recordingFile.name}}
+                        />
+                    </DownloadButton>
+                    {linkError &&
+                        <FormattedMessage defaultMessage={'Failed to get the recording link. Please try again.'}/>
+                    }
+                </div>
+            }
         </>
     );
 };
+
+const DownloadButton = styled.button`
+    margin: 8px 8px 8px 0;
+`;
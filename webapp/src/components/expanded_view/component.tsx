@@ -122,6 +122,7 @@ interface Props extends RouteComponentProps {
     rhsSelectedThreadID?: string,
     allowScreenSharing: boolean,
     recordingsEnabled: boolean,
+    jobServiceAvailable: boolean,
     recordingMaxDuration: number,
     startCallRecording: (callID: string) => void,
     recordingPromptDismissedAt: (callID: string, dismissedAt: number) => void,
@@ -516,7 +517,7 @@ export default class ExpandedView extends React.PureComponent<Props, State> {
                     channelID: this.props.channel.id,
                 },
             });
-        } else {
+        } else if (this.props.jobServiceAvailable) {
             await this.props.startCallRecording(this.props.channel.id);
         }
     };
@@ -1267,6 +1268,8 @@ export default class ExpandedView extends React.PureComponent<Props, State> {
         const isRecording = isHost && this.props.isRecording;
 
         const recordTooltipText = isRecording ? formatMessage({defaultMessage: 'Stop recording'}) : formatMessage({defaultMessage: 'Record call'});
+        const recordUnavailable = !isRecording && !this.props.jobServiceAvailable;
+        const recordTooltipSubtext = recordUnavailable ? formatMessage({defaultMessage: 'Recording is temporarily unavailable'}) : '';
         const RecordIcon = isRecording ? RecordSquareIcon : RecordCircleIcon;
         const ShareIcon = isSharing ? UnshareScreenIcon : ShareScreenIcon;
 
@@ -1496,6 +1499,8 @@ export default class ExpandedView extends React.PureComponent<Props, State> {
                                     ariaLabel={recordTooltipText}
                                     onToggle={() => this.onRecordToggle()}
                                     tooltipText={recordTooltipText}
+                                    tooltipSubtext={recordTooltipSubtext}
+                                    disabled={recordUnavailable}
                                     // eslint-disable-next-line no-undefined
                                     shortcut={reverseKeyMappings.popout[RECORDING_TOGGLE][0]}
                                     bgColor={isRecording ? 'rgba(var(--dnd-indicator-rgb), 0.16)' : ''}
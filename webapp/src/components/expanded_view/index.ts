@@ -25,6 +25,7 @@ import {
     hostChangeAtForCurrentCall,
     hostIDForCurrentCall,
     isRecordingInCurrentCall,
+    jobServiceAvailable,
     profilesInCurrentCallMap,
     recordingForCurrentCall,
     recordingMaxDuration,
@@ -90,6 +91,7 @@ const mapStateToProps = (state: GlobalState) => {
         isRhsOpen: getIsRhsOpen?.(state),
         allowScreenSharing: allowScreenSharing(state),
         recordingsEnabled: recordingsEnabled(state),
+        jobServiceAvailable: jobServiceAvailable(state),
         recordingMaxDuration: recordingMaxDuration(state),
         transcriptionsEnabled: transcriptionsEnabled(state),
         isAdmin: isCurrentUserSystemAdmin(state),
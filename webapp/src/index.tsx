@@ -184,6 +184,7 @@ import {
     handleHostMute,
     handleHostRemoved,
     handleHostScreenOff,
+    handleJobServiceAvailability,
     handleUserDismissedNotification,
     handleUserJoined,
     handleUserLeft,
@@ -329,6 +330,10 @@ export default class Plugin {
         registry.registerWebSocketEventHandler(`custom_${pluginId}_user_video_off`, (ev) => {
             handleUserVideoOff(store, ev);
         });
+
+        registry.registerWebSocketEventHandler(`custom_${pluginId}_job_service_availability`, (ev) => {
+            handleJobServiceAvailability(store, ev);
+        });
     }
 
     private initialize(registry: PluginRegistry, store: Store) {
@@ -14,6 +14,7 @@ import {
     HostControlNotice,
     HostControlNoticeTimeout,
     IncomingCallNotification,
+    JobServiceAvailabilityConfig,
     LiveCaptions,
 } from 'src/types/types';
 
@@ -35,6 +36,7 @@ import {
     HIDE_SWITCH_CALL_MODAL,
     HOST_CONTROL_NOTICE,
     HOST_CONTROL_NOTICE_TIMEOUT_EVENT,
+    JOB_SERVICE_AVAILABLE,
     LIVE_CAPTION,
     LIVE_CAPTION_TIMEOUT_EVENT,
     LIVE_CAPTIONS_ENABLED,
@@ -874,6 +876,17 @@ const rtcdEnabled = (state = false, action: {type: string, data: boolean}) => {
     }
 };
 
+const jobServiceAvailable = (state = true, action: {type: string, data: boolean | JobServiceAvailabilityConfig}) => {
+    switch (action.type) {
+    case RECEIVED_CALLS_CONFIG:
+        return !(action.data as JobServiceAvailabilityConfig).job_service_unavailable;
+    case JOB_SERVICE_AVAILABLE:
+        return action.data as boolean;
+    default:
+        return state;
+    }
+};
+
 const callsUserPreferences = (state = CallsUserPreferencesDefault, action: { type: string, data: CallsUserPreferences }) => {
     switch (action.type) {
     case RECEIVED_CALLS_USER_PREFERENCES:
@@ -1089,6 +1102,7 @@ export default combineReducers({
     callsConfigEnvOverrides,
     callsVersionInfo,
     rtcdEnabled,
+    jobServiceAvailable,
     callsUserPreferences,
     recordings,
     callLiveCaptionsState,
@@ -466,6 +466,9 @@ export const recordingMaxDuration = (state: GlobalState) =>
 export const rtcdEnabled = (state: GlobalState) =>
     pluginState(state).rtcdEnabled;
 
+export const jobServiceAvailable = (state: GlobalState): boolean =>
+    pluginState(state).jobServiceAvailable;
+
 export const ringingEnabled = (state: GlobalState) =>
     callsConfig(state).EnableRinging;
 
@@ -241,7 +241,18 @@ export default async function slashCommandsHandler(store: Store, joinCall: joinC
                 return {};
             }
 
-            await store.dispatch(startCallRecording(connectedID));
+            // Optional transcription options (e.g. language=es model=small).
+            let transcriptionOptions: Record<string, string> | undefined;
+            const optionKeys: Record<string, string> = {language: 'language', api: 'api', model: 'model_size'};
+            for (const field of fields.slice(3)) {
+                const [key, value] = field.split('=');
+                if (!optionKeys[key] || !value) {
+                    return {message, args};
+                }
+                transcriptionOptions = {...transcriptionOptions, [optionKeys[key]]: value};
+            }
+
+            await store.dispatch(startCallRecording(connectedID, transcriptionOptions));
         }
 
         if (fields[2] === 'stop') {
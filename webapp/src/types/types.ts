@@ -230,6 +230,16 @@ export type LiveCaptions = {
     [sessionID: string]: LiveCaption;
 }
 
+// The job service availability is initially part of the config and later
+// updated through websocket events.
+export type JobServiceAvailabilityConfig = {
+    job_service_unavailable?: boolean,
+};
+
+export type JobServiceAvailabilityData = {
+    available: boolean,
+};
+
 // Matching the type in server/public/stats.go
 export type CallsStats = {
     total_calls: number;
@@ -51,6 +51,7 @@ import {
 import {
     HostControlNotice,
     HostControlNoticeType,
+    JobServiceAvailabilityData,
 } from 'src/types/types';
 
 import {
@@ -61,6 +62,7 @@ import {
     DISMISS_CALL,
     HOST_CONTROL_NOTICE,
     HOST_CONTROL_NOTICE_TIMEOUT_EVENT,
+    JOB_SERVICE_AVAILABLE,
     LIVE_CAPTION,
     LIVE_CAPTION_TIMEOUT_EVENT,
     USER_JOINED,
@@ -645,3 +647,10 @@ export function handleUserVideoOff(store: Store, ev: WebSocketMessage<UserVideoO
         },
     });
 }
+
+export function handleJobServiceAvailability(store: Store, ev: WebSocketMessage<JobServiceAvailabilityData>) {
+    store.dispatch({
+        type: JOB_SERVICE_AVAILABLE,
+        data: ev.data.available,
+    });
+}